@@ -0,0 +1,131 @@
+// Package cache 儲存 gateway 快取的 GET response，提供記憶體（LRU）與 Redis 兩種後端。
+//
+// 快取規則（Cache-Control、Vary、重新驗證）由 middleware.Cache 處理，這裡只負責存取與清除。
+package cache
+
+import (
+	"context"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Entry 一筆快取的 response
+//
+// Vary 不為空時這筆只是索引：實際的 response 依 Vary 中各個請求 header 的值存在 VariantKey 算出的 key。
+// 從 Store 取出的 Entry 可能與其他請求共用，不可修改，需要修改時先 Clone
+type Entry struct {
+	Status   int         `json:"status,omitempty"`
+	Header   http.Header `json:"header,omitempty"`
+	Body     []byte      `json:"body,omitempty"`
+	StoredAt time.Time   `json:"stored_at"` // 存入或最近一次向上游確認沒有變更的時間，用於計算 Age
+	Expires  time.Time   `json:"expires"`   // 過了之後需向上游重新驗證
+	Vary     []string    `json:"vary,omitempty"`
+}
+
+// Fresh 回傳 now 時是否還不需要重新驗證
+func (e *Entry) Fresh(now time.Time) bool {
+	return now.Before(e.Expires)
+}
+
+// Age 回傳 response 已經在快取中多久（Age header 的值）
+func (e *Entry) Age(now time.Time) time.Duration {
+	if age := now.Sub(e.StoredAt); age > 0 {
+		return age
+	}
+	return 0
+}
+
+// Clone 回傳可以修改的複本，Body 共用（不會被修改）
+func (e *Entry) Clone() *Entry {
+	clone := *e
+	clone.Header = e.Header.Clone()
+	clone.Vary = append([]string(nil), e.Vary...)
+	return &clone
+}
+
+// size 估計這筆資料佔用的記憶體
+func (e *Entry) size() int {
+	n := len(e.Body)
+	for key, values := range e.Header {
+		n += len(key)
+		for _, value := range values {
+			n += len(value)
+		}
+	}
+	for _, name := range e.Vary {
+		n += len(name)
+	}
+	return n
+}
+
+// Store 快取的後端；查無資料時回傳 nil, nil
+type Store interface {
+	Get(ctx context.Context, key string) (*Entry, error)
+	// Set 存入 entry，ttl 是保留多久（可能比 Entry.Expires 長，留給重新驗證使用）
+	Set(ctx context.Context, key string, entry *Entry, ttl time.Duration) error
+	// Purge 清除路徑符合 pathPrefix（以 / 分段比對）的所有資料，pathPrefix 為空時清除全部；回傳清除的筆數
+	Purge(ctx context.Context, pathPrefix string) (int, error)
+}
+
+// Key 一個 GET 請求的快取 key：路徑、正規化後的 query 與用戶 ID（不區分用戶時為空）。
+// path 需是 escaped path，不會含有 ? 與 #，Purge 以此取回路徑
+func Key(path, query, user string) string {
+	return path + "?" + query + "#" + user
+}
+
+// VariantKey 在 base 後面加上 vary 中各個請求 header 的值，同一個網址依這些 header 存成不同的 response
+func VariantKey(base string, vary []string, h http.Header) string {
+	var b strings.Builder
+	b.WriteString(base)
+	for _, name := range vary {
+		b.WriteString("#")
+		b.WriteString(strings.ToLower(name))
+		b.WriteString("=")
+		b.WriteString(strings.Join(h.Values(name), ","))
+	}
+	return b.String()
+}
+
+// keyPath 取出 key 中的路徑
+func keyPath(key string) string {
+	path, _, _ := strings.Cut(key, "?")
+	return path
+}
+
+// matchPath 以 / 分段比對路徑前綴，/api/notes 符合 /api/notes 與 /api/notes/1，但不符合 /api/notes-archive
+func matchPath(path, prefix string) bool {
+	prefix = strings.TrimSuffix(prefix, "/")
+	return prefix == "" || path == prefix || strings.HasPrefix(path, prefix+"/")
+}
+
+// PurgeHandler 管理員清除快取的 API：body 的 path_prefix 為空（或沒有 body）時清除全部
+func PurgeHandler(store Store) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var req struct {
+			PathPrefix string `json:"path_prefix"`
+		}
+		if c.Request.ContentLength != 0 {
+			if err := c.ShouldBindJSON(&req); err != nil {
+				c.JSON(http.StatusBadRequest, gin.H{"error": "請求格式錯誤"})
+				return
+			}
+		}
+		if req.PathPrefix != "" && !strings.HasPrefix(req.PathPrefix, "/") {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "path_prefix 必須以 / 開頭"})
+			return
+		}
+
+		n, err := store.Purge(c.Request.Context(), req.PathPrefix)
+		if err != nil {
+			log.Printf("[Gateway] 清除快取失敗 path_prefix=%q err=%v", req.PathPrefix, err)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "清除快取失敗"})
+			return
+		}
+		log.Printf("[Gateway] 清除快取 path_prefix=%q purged=%d", req.PathPrefix, n)
+		c.JSON(http.StatusOK, gin.H{"purged": n})
+	}
+}
@@ -0,0 +1,124 @@
+package cache
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+)
+
+// cacheTestNow 測試用的固定時間
+var cacheTestNow = time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+
+func TestKey(t *testing.T) {
+	key := Key("/api/users/me", "fields=email", "uuid-001")
+	assert.Equal(t, "/api/users/me?fields=email#uuid-001", key)
+	assert.Equal(t, "/api/users/me", keyPath(key))
+	assert.Equal(t, "/api/users", keyPath(Key("/api/users", "", "")))
+}
+
+func TestVariantKey(t *testing.T) {
+	base := Key("/api/users", "", "")
+	h := http.Header{}
+	h.Set("Accept-Language", "zh-TW")
+
+	assert.Equal(t, base+"#accept-language=zh-TW#accept=", VariantKey(base, []string{"Accept-Language", "Accept"}, h))
+	// Purge 以路徑比對，加上 Vary 的值之後仍能取回路徑
+	assert.Equal(t, "/api/users", keyPath(VariantKey(base, []string{"Accept-Language"}, h)))
+}
+
+func TestMatchPath(t *testing.T) {
+	tests := []struct {
+		path   string
+		prefix string
+		want   bool
+	}{
+		{path: "/api/notes", prefix: "/api/notes", want: true},
+		{path: "/api/notes/1", prefix: "/api/notes", want: true},
+		{path: "/api/notes/1", prefix: "/api/notes/", want: true},
+		{path: "/api/notes-archive", prefix: "/api/notes", want: false},
+		{path: "/api/note", prefix: "/api/notes", want: false},
+		{path: "/api/notes", prefix: "", want: true},
+		{path: "/api/notes", prefix: "/", want: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.path+" "+tt.prefix, func(t *testing.T) {
+			assert.Equal(t, tt.want, matchPath(tt.path, tt.prefix))
+		})
+	}
+}
+
+func TestEntry(t *testing.T) {
+	entry := &Entry{
+		Status:   http.StatusOK,
+		Header:   http.Header{"Etag": {`"v1"`}},
+		StoredAt: cacheTestNow,
+		Expires:  cacheTestNow.Add(time.Minute),
+		Vary:     []string{"Accept-Language"},
+	}
+
+	assert.True(t, entry.Fresh(cacheTestNow.Add(59*time.Second)))
+	assert.False(t, entry.Fresh(cacheTestNow.Add(time.Minute)))
+	assert.Equal(t, 30*time.Second, entry.Age(cacheTestNow.Add(30*time.Second)))
+	assert.Equal(t, time.Duration(0), entry.Age(cacheTestNow.Add(-time.Second)))
+
+	clone := entry.Clone()
+	clone.Header.Set("Etag", `"v2"`)
+	clone.Vary[0] = "Accept"
+	assert.Equal(t, `"v1"`, entry.Header.Get("Etag"))
+	assert.Equal(t, "Accept-Language", entry.Vary[0])
+}
+
+// purgeStore 記錄 Purge 的參數
+type purgeStore struct {
+	Store
+	prefix string
+	err    error
+}
+
+func (s *purgeStore) Purge(_ context.Context, pathPrefix string) (int, error) {
+	s.prefix = pathPrefix
+	return 3, s.err
+}
+
+func TestPurgeHandler(t *testing.T) {
+	tests := []struct {
+		name       string
+		body       string
+		storeErr   error
+		wantCode   int
+		wantPrefix string
+	}{
+		{name: "purge all", body: "", wantCode: http.StatusOK, wantPrefix: ""},
+		{name: "purge prefix", body: `{"path_prefix":"/api/users"}`, wantCode: http.StatusOK, wantPrefix: "/api/users"},
+		{name: "relative prefix", body: `{"path_prefix":"api/users"}`, wantCode: http.StatusBadRequest},
+		{name: "malformed body", body: `{`, wantCode: http.StatusBadRequest},
+		{name: "store error", body: "", storeErr: errors.New("connection refused"), wantCode: http.StatusInternalServerError},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			gin.SetMode(gin.TestMode)
+			store := &purgeStore{prefix: "untouched", err: tt.storeErr}
+			router := gin.New()
+			router.POST("/admin/cache/purge", PurgeHandler(store))
+
+			w := httptest.NewRecorder()
+			r, _ := http.NewRequest("POST", "/admin/cache/purge", strings.NewReader(tt.body))
+			r.Header.Set("Content-Type", "application/json")
+			router.ServeHTTP(w, r)
+
+			assert.Equal(t, tt.wantCode, w.Code)
+			if tt.wantCode == http.StatusOK {
+				assert.Equal(t, tt.wantPrefix, store.prefix)
+				assert.JSONEq(t, `{"purged":3}`, w.Body.String())
+			}
+		})
+	}
+}
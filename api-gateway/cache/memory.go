@@ -0,0 +1,95 @@
+package cache
+
+import (
+	"container/list"
+	"context"
+	"sync"
+	"time"
+)
+
+// MemoryStore 存在 gateway 記憶體中的 LRU 快取，每個 gateway 實例各自一份
+type MemoryStore struct {
+	mu       sync.Mutex
+	maxBytes int
+	size     int
+	lru      *list.List // 最近使用的在前面
+	items    map[string]*list.Element
+	now      func() time.Time
+}
+
+type memoryItem struct {
+	key       string
+	entry     *Entry
+	expiresAt time.Time
+	size      int
+}
+
+// NewMemoryStore 建立最多使用約 maxBytes 記憶體的快取，超過時淘汰最久沒有使用的資料
+func NewMemoryStore(maxBytes int) *MemoryStore {
+	return &MemoryStore{
+		maxBytes: maxBytes,
+		lru:      list.New(),
+		items:    map[string]*list.Element{},
+		now:      time.Now,
+	}
+}
+
+// Get 取出 key 的資料，已經超過保留期限的視為不存在
+func (s *MemoryStore) Get(_ context.Context, key string) (*Entry, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	el, ok := s.items[key]
+	if !ok {
+		return nil, nil
+	}
+	item := el.Value.(*memoryItem)
+	if !s.now().Before(item.expiresAt) {
+		s.remove(el)
+		return nil, nil
+	}
+	s.lru.MoveToFront(el)
+	return item.entry, nil
+}
+
+// Set 存入資料；單筆就超過上限的不存
+func (s *MemoryStore) Set(_ context.Context, key string, entry *Entry, ttl time.Duration) error {
+	size := len(key) + entry.size()
+	if size > s.maxBytes || ttl <= 0 {
+		return nil
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if el, ok := s.items[key]; ok {
+		s.remove(el)
+	}
+	s.items[key] = s.lru.PushFront(&memoryItem{key: key, entry: entry, expiresAt: s.now().Add(ttl), size: size})
+	s.size += size
+	for s.size > s.maxBytes {
+		s.remove(s.lru.Back())
+	}
+	return nil
+}
+
+// Purge 清除路徑符合 pathPrefix 的資料
+func (s *MemoryStore) Purge(_ context.Context, pathPrefix string) (int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	n := 0
+	for key, el := range s.items {
+		if matchPath(keyPath(key), pathPrefix) {
+			s.remove(el)
+			n++
+		}
+	}
+	return n, nil
+}
+
+func (s *MemoryStore) remove(el *list.Element) {
+	item := s.lru.Remove(el).(*memoryItem)
+	delete(s.items, item.key)
+	s.size -= item.size
+}
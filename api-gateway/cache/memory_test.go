@@ -0,0 +1,134 @@
+package cache
+
+import (
+	"context"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// newTestMemoryStore 建立時間固定在 *clock 的 MemoryStore
+func newTestMemoryStore(maxBytes int, clock *time.Time) *MemoryStore {
+	s := NewMemoryStore(maxBytes)
+	s.now = func() time.Time { return *clock }
+	return s
+}
+
+func bodyEntry(body string) *Entry {
+	return &Entry{Status: http.StatusOK, Body: []byte(body), StoredAt: cacheTestNow, Expires: cacheTestNow.Add(time.Minute)}
+}
+
+func TestMemoryStore_TTL(t *testing.T) {
+	ctx := context.Background()
+	clock := cacheTestNow
+	s := newTestMemoryStore(1<<20, &clock)
+
+	require.NoError(t, s.Set(ctx, "/api/users?#", bodyEntry("users"), time.Minute))
+
+	clock = cacheTestNow.Add(59 * time.Second)
+	entry, err := s.Get(ctx, "/api/users?#")
+	require.NoError(t, err)
+	require.NotNil(t, entry)
+	assert.Equal(t, "users", string(entry.Body))
+
+	clock = cacheTestNow.Add(time.Minute)
+	entry, err = s.Get(ctx, "/api/users?#")
+	require.NoError(t, err)
+	assert.Nil(t, entry)
+	assert.Equal(t, 0, s.size)
+
+	t.Run("zero ttl is not stored", func(t *testing.T) {
+		require.NoError(t, s.Set(ctx, "/api/users?#", bodyEntry("users"), 0))
+		entry, _ := s.Get(ctx, "/api/users?#")
+		assert.Nil(t, entry)
+	})
+}
+
+func TestMemoryStore_LRU(t *testing.T) {
+	ctx := context.Background()
+	clock := cacheTestNow
+	body := strings.Repeat("x", 90)
+	// 每筆約 100 bytes（key + body），最多放得下三筆
+	s := newTestMemoryStore(300, &clock)
+
+	for _, key := range []string{"/a?#", "/b?#", "/c?#"} {
+		require.NoError(t, s.Set(ctx, key, bodyEntry(body), time.Minute))
+	}
+	// 讀取 /a 讓它變成最近使用的，接下來淘汰的是 /b
+	entry, _ := s.Get(ctx, "/a?#")
+	require.NotNil(t, entry)
+
+	require.NoError(t, s.Set(ctx, "/d?#", bodyEntry(body), time.Minute))
+
+	for key, want := range map[string]bool{"/a?#": true, "/b?#": false, "/c?#": true, "/d?#": true} {
+		entry, _ := s.Get(ctx, key)
+		assert.Equal(t, want, entry != nil, key)
+	}
+	assert.LessOrEqual(t, s.size, 300)
+
+	t.Run("replacing a key does not double count", func(t *testing.T) {
+		before := s.size
+		require.NoError(t, s.Set(ctx, "/a?#", bodyEntry(body), time.Minute))
+		assert.Equal(t, before, s.size)
+		assert.Equal(t, 3, s.lru.Len())
+	})
+
+	t.Run("entry larger than the limit is not stored", func(t *testing.T) {
+		require.NoError(t, s.Set(ctx, "/huge?#", bodyEntry(strings.Repeat("x", 400)), time.Minute))
+		entry, _ := s.Get(ctx, "/huge?#")
+		assert.Nil(t, entry)
+		// 也不會為了它淘汰既有的資料
+		assert.Equal(t, 3, s.lru.Len())
+	})
+}
+
+func TestMemoryStore_Purge(t *testing.T) {
+	ctx := context.Background()
+	clock := cacheTestNow
+	keys := []string{
+		Key("/api/notes", "", ""),
+		Key("/api/notes", "page=2", "uuid-001"),
+		Key("/api/notes/1", "", ""),
+		VariantKey(Key("/api/notes/1", "", ""), []string{"Accept-Language"}, http.Header{"Accept-Language": {"en"}}),
+		Key("/api/notes-archive", "", ""),
+		Key("/api/users/me", "", "uuid-001"),
+	}
+
+	tests := []struct {
+		name       string
+		pathPrefix string
+		wantPurged int
+		wantLeft   []string
+	}{
+		{name: "collection and children", pathPrefix: "/api/notes", wantPurged: 4, wantLeft: []string{keys[4], keys[5]}},
+		{name: "single item", pathPrefix: "/api/notes/1", wantPurged: 2, wantLeft: []string{keys[0], keys[1], keys[4], keys[5]}},
+		{name: "trailing slash", pathPrefix: "/api/notes-archive/", wantPurged: 1, wantLeft: []string{keys[0], keys[1], keys[2], keys[3], keys[5]}},
+		{name: "everything", pathPrefix: "", wantPurged: 6},
+		{name: "no match", pathPrefix: "/api/orders", wantPurged: 0, wantLeft: keys},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := newTestMemoryStore(1<<20, &clock)
+			for _, key := range keys {
+				require.NoError(t, s.Set(ctx, key, bodyEntry("body"), time.Minute))
+			}
+
+			n, err := s.Purge(ctx, tt.pathPrefix)
+
+			require.NoError(t, err)
+			assert.Equal(t, tt.wantPurged, n)
+			var left []string
+			for _, key := range keys {
+				if entry, _ := s.Get(ctx, key); entry != nil {
+					left = append(left, key)
+				}
+			}
+			assert.Equal(t, tt.wantLeft, left)
+		})
+	}
+}
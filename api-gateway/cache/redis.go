@@ -0,0 +1,101 @@
+package cache
+
+import (
+	"context"
+	"encoding/json"
+	"strings"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// redisKeyPrefix gateway 快取在 Redis 中的 key 前綴
+const redisKeyPrefix = "gateway:cache:"
+
+// purgeBatchSize Purge 時每次 SCAN 與 DEL 的筆數
+const purgeBatchSize = 100
+
+// RedisStore 存在 Redis 的快取，所有 gateway 實例共用；容量與淘汰交給 Redis 的 maxmemory-policy
+type RedisStore struct {
+	client *redis.Client
+}
+
+// NewRedisStore 建立以 Redis 為後端的 Store
+func NewRedisStore(client *redis.Client) *RedisStore {
+	return &RedisStore{client: client}
+}
+
+// Get 取出 key 的資料
+func (s *RedisStore) Get(ctx context.Context, key string) (*Entry, error) {
+	data, err := s.client.Get(ctx, redisKeyPrefix+key).Bytes()
+	if err == redis.Nil {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	var entry Entry
+	if err := json.Unmarshal(data, &entry); err != nil {
+		return nil, err
+	}
+	return &entry, nil
+}
+
+// Set 存入資料，保留 ttl 後由 Redis 自動刪除
+func (s *RedisStore) Set(ctx context.Context, key string, entry *Entry, ttl time.Duration) error {
+	if ttl <= 0 {
+		return nil
+	}
+	data, err := json.Marshal(entry)
+	if err != nil {
+		return err
+	}
+	return s.client.Set(ctx, redisKeyPrefix+key, data, ttl).Err()
+}
+
+// Purge 以 SCAN 找出路徑符合 pathPrefix 的 key 並刪除，不會像 KEYS 一樣阻塞 Redis
+func (s *RedisStore) Purge(ctx context.Context, pathPrefix string) (int, error) {
+	pattern := redisKeyPrefix + escapeGlob(strings.TrimSuffix(pathPrefix, "/")) + "*"
+	iter := s.client.Scan(ctx, 0, pattern, purgeBatchSize).Iterator()
+
+	n := 0
+	batch := make([]string, 0, purgeBatchSize)
+	flush := func() error {
+		if len(batch) == 0 {
+			return nil
+		}
+		deleted, err := s.client.Del(ctx, batch...).Result()
+		n += int(deleted)
+		batch = batch[:0]
+		return err
+	}
+	for iter.Next(ctx) {
+		key := iter.Val()
+		// glob 只比對字元前綴，/api/notes* 也會掃到 /api/notes-archive，這裡再以路徑分段確認
+		if !matchPath(keyPath(strings.TrimPrefix(key, redisKeyPrefix)), pathPrefix) {
+			continue
+		}
+		batch = append(batch, key)
+		if len(batch) == purgeBatchSize {
+			if err := flush(); err != nil {
+				return n, err
+			}
+		}
+	}
+	if err := iter.Err(); err != nil {
+		return n, err
+	}
+	return n, flush()
+}
+
+// escapeGlob 跳脫 SCAN MATCH 的特殊字元，路徑中的 * ? [ ] 只當一般字元比對
+func escapeGlob(s string) string {
+	var b strings.Builder
+	for _, r := range s {
+		if strings.ContainsRune(`*?[]\`, r) {
+			b.WriteByte('\\')
+		}
+		b.WriteRune(r)
+	}
+	return b.String()
+}
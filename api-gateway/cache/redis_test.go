@@ -0,0 +1,121 @@
+package cache
+
+import (
+	"context"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/redis/go-redis/v9"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// newTestRedisStore 以 in-memory 的 Redis 建立 RedisStore，測試結束時自動關閉
+func newTestRedisStore(t *testing.T) (*miniredis.Miniredis, *RedisStore) {
+	t.Helper()
+	server := miniredis.RunT(t)
+	client := redis.NewClient(&redis.Options{Addr: server.Addr()})
+	t.Cleanup(func() { client.Close() })
+	return server, NewRedisStore(client)
+}
+
+func TestRedisStore_GetSet(t *testing.T) {
+	ctx := context.Background()
+	server, s := newTestRedisStore(t)
+	key := Key("/api/users/me", "", "uuid-001")
+	entry := &Entry{
+		Status:   http.StatusOK,
+		Header:   http.Header{"Content-Type": {"application/json"}, "Etag": {`"v1"`}},
+		Body:     []byte(`{"id":"uuid-001"}`),
+		StoredAt: cacheTestNow,
+		Expires:  cacheTestNow.Add(time.Minute),
+	}
+
+	missing, err := s.Get(ctx, key)
+	require.NoError(t, err)
+	assert.Nil(t, missing)
+
+	require.NoError(t, s.Set(ctx, key, entry, time.Minute))
+	got, err := s.Get(ctx, key)
+	require.NoError(t, err)
+	require.NotNil(t, got)
+	assert.Equal(t, entry.Status, got.Status)
+	assert.Equal(t, entry.Header, got.Header)
+	assert.Equal(t, entry.Body, got.Body)
+	assert.True(t, entry.Expires.Equal(got.Expires))
+	assert.Equal(t, time.Minute, server.TTL(redisKeyPrefix+key))
+
+	// 保留期限由 Redis 處理
+	server.FastForward(time.Minute)
+	got, err = s.Get(ctx, key)
+	require.NoError(t, err)
+	assert.Nil(t, got)
+
+	t.Run("zero ttl is not stored", func(t *testing.T) {
+		require.NoError(t, s.Set(ctx, key, entry, 0))
+		assert.False(t, server.Exists(redisKeyPrefix+key))
+	})
+
+	t.Run("corrupt data", func(t *testing.T) {
+		server.Set(redisKeyPrefix+key, "not json")
+		_, err := s.Get(ctx, key)
+		assert.Error(t, err)
+	})
+
+	t.Run("redis unavailable", func(t *testing.T) {
+		server.Close()
+		_, err := s.Get(ctx, key)
+		assert.Error(t, err)
+		assert.Error(t, s.Set(ctx, key, entry, time.Minute))
+	})
+}
+
+func TestRedisStore_Purge(t *testing.T) {
+	ctx := context.Background()
+	keys := []string{
+		Key("/api/notes", "", ""),
+		Key("/api/notes", "page=2", "uuid-001"),
+		Key("/api/notes/1", "", ""),
+		Key("/api/notes-archive", "", ""),
+		Key("/api/[draft]*", "", ""),
+		Key("/api/users/me", "", "uuid-001"),
+	}
+
+	tests := []struct {
+		name       string
+		pathPrefix string
+		wantPurged int
+		wantLeft   []string
+	}{
+		{name: "collection and children", pathPrefix: "/api/notes", wantPurged: 3, wantLeft: []string{keys[3], keys[4], keys[5]}},
+		{name: "segment boundary", pathPrefix: "/api/notes-archive", wantPurged: 1, wantLeft: []string{keys[0], keys[1], keys[2], keys[4], keys[5]}},
+		{name: "glob characters are literal", pathPrefix: "/api/[draft]*", wantPurged: 1, wantLeft: []string{keys[0], keys[1], keys[2], keys[3], keys[5]}},
+		{name: "everything", pathPrefix: "", wantPurged: 6},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server, s := newTestRedisStore(t)
+			for _, key := range keys {
+				require.NoError(t, s.Set(ctx, key, &Entry{Status: http.StatusOK}, time.Minute))
+			}
+			// 不是 gateway 快取的 key 不可被清除
+			server.Set("auth:revoked_before:uuid-001", "1")
+
+			n, err := s.Purge(ctx, tt.pathPrefix)
+
+			require.NoError(t, err)
+			assert.Equal(t, tt.wantPurged, n)
+			var left []string
+			for _, key := range keys {
+				if server.Exists(redisKeyPrefix + key) {
+					left = append(left, key)
+				}
+			}
+			assert.Equal(t, tt.wantLeft, left)
+			assert.True(t, server.Exists("auth:revoked_before:uuid-001"))
+		})
+	}
+}
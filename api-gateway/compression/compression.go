@@ -0,0 +1,141 @@
+// Package compression 處理 HTTP 內容編碼：依 Accept-Encoding 協商編碼，
+// 並提供可重複使用的 gzip / brotli / zstd 壓縮與解壓縮。
+package compression
+
+import (
+	"compress/gzip"
+	"compress/zlib"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/andybalholm/brotli"
+	"github.com/klauspost/compress/zstd"
+)
+
+// 支援的內容編碼，值為 Content-Encoding 中的名稱
+const (
+	Gzip   = "gzip"
+	Brotli = "br"
+	Zstd   = "zstd"
+)
+
+// Negotiate 依 Accept-Encoding 從 preferred 中挑出客戶端接受的編碼，沒有可用的編碼時回傳 ""。
+// q 值較高者優先，q 相同時依 preferred 的順序；"*" 代表其他沒列出的編碼，q=0 代表拒絕。
+func Negotiate(acceptEncoding string, preferred []string) string {
+	weights := parseAcceptEncoding(acceptEncoding)
+	best, bestQ := "", 0.0
+	for _, encoding := range preferred {
+		q, ok := weights[encoding]
+		if !ok {
+			q = weights["*"]
+		}
+		if q > bestQ {
+			best, bestQ = encoding, q
+		}
+	}
+	return best
+}
+
+// Accepts 回傳客戶端是否接受 encoding（包含由 "*" 涵蓋的情況）
+func Accepts(acceptEncoding, encoding string) bool {
+	return Negotiate(acceptEncoding, []string{encoding}) != ""
+}
+
+// parseAcceptEncoding 將 Accept-Encoding 轉成 編碼 → q 值，名稱一律轉小寫
+func parseAcceptEncoding(header string) map[string]float64 {
+	weights := map[string]float64{}
+	for _, part := range strings.Split(header, ",") {
+		name, params, _ := strings.Cut(strings.TrimSpace(part), ";")
+		name = strings.ToLower(strings.TrimSpace(name))
+		if name == "" {
+			continue
+		}
+		q := 1.0
+		for _, param := range strings.Split(params, ";") {
+			key, value, ok := strings.Cut(strings.TrimSpace(param), "=")
+			if ok && strings.EqualFold(strings.TrimSpace(key), "q") {
+				if parsed, err := strconv.ParseFloat(strings.TrimSpace(value), 64); err == nil {
+					q = parsed
+				}
+			}
+		}
+		weights[name] = q
+	}
+	return weights
+}
+
+// resetWriter 可以換一個底層 writer 重複使用的壓縮 writer
+type resetWriter interface {
+	io.WriteCloser
+	Reset(w io.Writer)
+	Flush() error
+}
+
+// zstdWriter 讓 *zstd.Encoder 符合 resetWriter（Reset 沒有回傳值）
+type zstdWriter struct{ *zstd.Encoder }
+
+func (z zstdWriter) Reset(w io.Writer) { z.Encoder.Reset(w) }
+
+// 每種編碼一個 pool：zstd 與 brotli 的 encoder 建立成本很高，每個 response 都新建會浪費大量記憶體
+var writerPools = map[string]*sync.Pool{
+	Gzip: {New: func() interface{} {
+		w, _ := gzip.NewWriterLevel(nil, gzip.DefaultCompression)
+		return w
+	}},
+	Brotli: {New: func() interface{} {
+		return brotli.NewWriterLevel(nil, brotli.DefaultCompression)
+	}},
+	Zstd: {New: func() interface{} {
+		// 只在錯誤的選項時失敗，這裡的選項是固定的
+		enc, _ := zstd.NewWriter(nil, zstd.WithEncoderConcurrency(1), zstd.WithEncoderLevel(zstd.SpeedDefault))
+		return zstdWriter{enc}
+	}},
+}
+
+// Writer 將寫入的內容以指定編碼壓縮後寫到底層 writer，Close 之後放回 pool，不可再使用
+type Writer struct {
+	resetWriter
+	pool *sync.Pool
+}
+
+// NewWriter 建立寫到 w 的壓縮 writer
+func NewWriter(encoding string, w io.Writer) (*Writer, error) {
+	pool, ok := writerPools[encoding]
+	if !ok {
+		return nil, fmt.Errorf("unsupported content encoding %q", encoding)
+	}
+	enc := pool.Get().(resetWriter)
+	enc.Reset(w)
+	return &Writer{resetWriter: enc, pool: pool}, nil
+}
+
+// Close 寫出剩下的壓縮資料並將 encoder 放回 pool
+func (w *Writer) Close() error {
+	err := w.resetWriter.Close()
+	w.resetWriter.Reset(nil)
+	w.pool.Put(w.resetWriter)
+	return err
+}
+
+// NewReader 建立解壓縮 r 的 reader；deflate 只用於解讀上游的回應，gateway 不會以 deflate 壓縮
+func NewReader(encoding string, r io.Reader) (io.ReadCloser, error) {
+	switch strings.ToLower(encoding) {
+	case Gzip, "x-gzip":
+		return gzip.NewReader(r)
+	case Brotli:
+		return io.NopCloser(brotli.NewReader(r)), nil
+	case Zstd:
+		dec, err := zstd.NewReader(r, zstd.WithDecoderConcurrency(1))
+		if err != nil {
+			return nil, err
+		}
+		return dec.IOReadCloser(), nil
+	case "deflate":
+		// HTTP 的 deflate 實際上是 zlib 格式（RFC 9110 8.4.1.2）
+		return zlib.NewReader(r)
+	}
+	return nil, fmt.Errorf("unsupported content encoding %q", encoding)
+}
@@ -0,0 +1,79 @@
+package compression
+
+import (
+	"bytes"
+	"io"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNegotiate(t *testing.T) {
+	preferred := []string{Brotli, Zstd, Gzip}
+
+	tests := []struct {
+		name           string
+		acceptEncoding string
+		want           string
+	}{
+		{name: "no header", acceptEncoding: "", want: ""},
+		{name: "single encoding", acceptEncoding: "gzip", want: Gzip},
+		{name: "ties follow preferred order", acceptEncoding: "gzip, br, zstd", want: Brotli},
+		{name: "higher q wins", acceptEncoding: "br;q=0.5, gzip;q=0.8", want: Gzip},
+		{name: "q parameter is case insensitive", acceptEncoding: "br;Q=0.1, gzip", want: Gzip},
+		{name: "encoding names are case insensitive", acceptEncoding: "GZIP", want: Gzip},
+		{name: "q=0 rejects the encoding", acceptEncoding: "br;q=0, gzip", want: Gzip},
+		{name: "wildcard covers unlisted encodings", acceptEncoding: "*", want: Brotli},
+		{name: "wildcard does not override explicit rejection", acceptEncoding: "br;q=0, zstd;q=0, *;q=0.5", want: Gzip},
+		{name: "explicit q beats wildcard", acceptEncoding: "*;q=0.2, gzip;q=0.9", want: Gzip},
+		{name: "wildcard q=0 rejects everything unlisted", acceptEncoding: "identity, *;q=0", want: ""},
+		{name: "unsupported encodings only", acceptEncoding: "compress, identity", want: ""},
+		{name: "malformed q is treated as 1", acceptEncoding: "gzip;q=abc", want: Gzip},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, Negotiate(tt.acceptEncoding, preferred))
+		})
+	}
+}
+
+func TestAccepts(t *testing.T) {
+	assert.True(t, Accepts("gzip, deflate", Gzip))
+	assert.True(t, Accepts("*", Zstd))
+	assert.False(t, Accepts("gzip", Brotli))
+	assert.False(t, Accepts("gzip;q=0", Gzip))
+	assert.False(t, Accepts("", Gzip))
+}
+
+func TestWriterReaderRoundTrip(t *testing.T) {
+	body := strings.Repeat(`{"id":"uuid-001","email":"user@example.com"}`, 100)
+
+	for _, encoding := range []string{Gzip, Brotli, Zstd} {
+		t.Run(encoding, func(t *testing.T) {
+			var buf bytes.Buffer
+			w, err := NewWriter(encoding, &buf)
+			require.NoError(t, err)
+			_, err = io.WriteString(w, body)
+			require.NoError(t, err)
+			require.NoError(t, w.Close())
+			assert.Less(t, buf.Len(), len(body))
+
+			r, err := NewReader(encoding, &buf)
+			require.NoError(t, err)
+			defer r.Close()
+			decoded, err := io.ReadAll(r)
+			require.NoError(t, err)
+			assert.Equal(t, body, string(decoded))
+		})
+	}
+
+	t.Run("unsupported encoding", func(t *testing.T) {
+		_, err := NewWriter("compress", io.Discard)
+		assert.Error(t, err)
+		_, err = NewReader("compress", strings.NewReader(""))
+		assert.Error(t, err)
+	})
+}
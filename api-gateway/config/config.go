@@ -1,26 +1,366 @@
 package config
 
-import "os"
+import (
+	"errors"
+	"fmt"
+	"mime"
+	"net"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// 與 user-service 溝通的協定
+const (
+	TransportGRPC = "grpc"
+	TransportHTTP = "http"
+)
+
+// 快取 response 的後端
+const (
+	CacheBackendMemory = "memory"
+	CacheBackendRedis  = "redis"
+)
+
+// 執行環境，production 會拒絕使用預設的 secret 啟動
+const (
+	EnvDevelopment = "development"
+	EnvProduction  = "production"
+)
 
 // Config 儲存 API Gateway 所有執行時的設定。
+//
+// 每個欄位的值依序來自：Defaults → CONFIG_FILE 指定的 YAML 檔 → 環境變數（env tag）。
+// 標記 secret 的欄位也可以改用 <env>_FILE 指向檔案（例如 Docker secrets），印出設定時會被遮蔽。
 type Config struct {
-	Port           string
-	JWTSecret      string
-	UserServiceURL string
+	Environment string `yaml:"environment" env:"APP_ENV"`
+	Port        string `yaml:"port" env:"PORT"`
+	// 直接連到 gateway 的來源在這些網段（IP 或 CIDR）內時，才採信它帶來的 X-Forwarded-For 等 header；
+	// 留空表示不信任任何 proxy，客戶端 IP 一律取連線來源。gateway 前面有 load balancer 或 nginx 時需設定
+	TrustedProxies []string `yaml:"trusted_proxies" env:"TRUSTED_PROXIES"`
+	JWTSecret      string   `yaml:"jwt_secret" env:"JWT_SECRET" secret:"true"`
+	InternalSecret string   `yaml:"internal_secret" env:"INTERNAL_AUTH_SECRET" secret:"true"` // 簽署轉發請求用，需與下游服務的 INTERNAL_AUTH_SECRET 相同
+	// user-service 的實例由 service registry 動態取得；
+	// Redis 與 RegistryFile 都查不到實例時，才退回 UserServiceURL / UserServiceGRPCAddr
+	UserServiceName string `yaml:"user_service_name" env:"USER_SERVICE_NAME"`
+	UserServiceURL  string `yaml:"user_service_url" env:"USER_SERVICE_URL"`
+	// /api/users 路由改走 gRPC 時使用；管理員路由仍以 HTTP 轉發
+	UserServiceTransport      string        `yaml:"user_service_transport" env:"USER_SERVICE_TRANSPORT"`             // grpc 或 http
+	UserServiceGRPCAddr       string        `yaml:"user_service_grpc_addr" env:"USER_SERVICE_GRPC_ADDR"`             // host:port
+	UserServiceTimeout        time.Duration `yaml:"user_service_timeout" env:"USER_SERVICE_TIMEOUT"`                 // 每個請求（HTTP 或 gRPC）的逾時，剩餘時間會傳到 user-service
+	UserServiceConnectTimeout time.Duration `yaml:"user_service_connect_timeout" env:"USER_SERVICE_CONNECT_TIMEOUT"` // 建立連線的逾時
+	// 轉發 HTTP 到 user-service 的連線池，調整方式見 proxy.TransportConfig；
+	// gRPC 連線只套用 USER_SERVICE_CONNECT_TIMEOUT 與 USER_SERVICE_KEEP_ALIVE，見 proxy.GRPCDialOptions
+	UserServiceKeepAlive           time.Duration `yaml:"user_service_keep_alive" env:"USER_SERVICE_KEEP_ALIVE"`
+	UserServiceMaxIdleConnsPerHost int           `yaml:"user_service_max_idle_conns_per_host" env:"USER_SERVICE_MAX_IDLE_CONNS_PER_HOST"`
+	UserServiceMaxConnsPerHost     int           `yaml:"user_service_max_conns_per_host" env:"USER_SERVICE_MAX_CONNS_PER_HOST"` // 0 表示不限制
+	UserServiceIdleConnTimeout     time.Duration `yaml:"user_service_idle_conn_timeout" env:"USER_SERVICE_IDLE_CONN_TIMEOUT"`
+	UserServiceHTTP2               bool          `yaml:"user_service_http2" env:"USER_SERVICE_HTTP2"`               // 以 h2c 連到 user-service，user-service 需設定 HTTP2_CLEARTEXT=true
+	RegistryFile                   string        `yaml:"registry_file" env:"REGISTRY_FILE"`                         // 靜態的服務實例清單（JSON），Redis 無法使用時的備援，留空表示不使用
+	RegistryRefresh                time.Duration `yaml:"registry_refresh_interval" env:"REGISTRY_REFRESH_INTERVAL"` // 重新查詢 registry 的間隔
+	RedisHost                      string        `yaml:"redis_host" env:"REDIS_HOST"`
+	RedisPort                      string        `yaml:"redis_port" env:"REDIS_PORT"`
+	// 收到 SIGTERM 後先讓 /health 回 503 並等待 ShutdownDrainPeriod，再花最多 ShutdownTimeout 等進行中的請求結束
+	ShutdownDrainPeriod time.Duration `yaml:"shutdown_drain_period" env:"SHUTDOWN_DRAIN_PERIOD"`
+	ShutdownTimeout     time.Duration `yaml:"shutdown_timeout" env:"SHUTDOWN_TIMEOUT"`
+	// /readyz 檢查 Redis 與上游服務：每一項的逾時、結果快取多久、是否回傳每一項的結果（只應在內部網路開啟）
+	HealthCheckTimeout  time.Duration `yaml:"health_check_timeout" env:"HEALTH_CHECK_TIMEOUT"`
+	HealthCacheTTL      time.Duration `yaml:"health_cache_ttl" env:"HEALTH_CACHE_TTL"`
+	HealthExposeDetails bool          `yaml:"health_expose_details" env:"HEALTH_EXPOSE_DETAILS"`
+	// Prometheus 指標另外監聽這個 port，不經過對外的路由；留空表示不開啟
+	MetricsPort string     `yaml:"metrics_port" env:"METRICS_PORT"`
+	CORS        CORSConfig `yaml:"cors"`
+	// 針對路徑前綴覆蓋 USER_SERVICE_TIMEOUT，例如較慢的匯出或較快失敗的登入；只能在 YAML 設定
+	RouteTimeouts []RouteTimeout `yaml:"route_timeouts"`
+	// 轉發前檢查請求 body：超過大小回 413、Content-Type 不在清單內回 415、JSON 巢狀太深回 400
+	MaxBodyBytes        int         `yaml:"max_body_bytes" env:"MAX_BODY_BYTES"`
+	AllowedContentTypes []string    `yaml:"allowed_content_types" env:"ALLOWED_CONTENT_TYPES"` // 空的表示不限制
+	MaxJSONDepth        int         `yaml:"max_json_depth" env:"MAX_JSON_DEPTH"`               // 0 表示不檢查
+	BodyRoutes          []BodyRoute `yaml:"body_routes"`                                       // 針對路徑前綴覆蓋，只能在 YAML 設定
+	// 依 Accept-Encoding 壓縮 response；下游已經壓縮的 response 不會重複壓縮
+	CompressionEnabled      bool     `yaml:"compression_enabled" env:"COMPRESSION_ENABLED"`
+	CompressionEncodings    []string `yaml:"compression_encodings" env:"COMPRESSION_ENCODINGS"`         // 依偏好排列，支援 br、zstd、gzip
+	CompressionMinSize      int      `yaml:"compression_min_size" env:"COMPRESSION_MIN_SIZE"`           // bytes，較小的 response 不壓縮
+	CompressionContentTypes []string `yaml:"compression_content_types" env:"COMPRESSION_CONTENT_TYPES"` // 可用 text/* 代表所有 text 類型
+	// 快取 GET response：只有 CacheRoutes 列出的路徑前綴會快取（只能在 YAML 設定），預設不快取任何路由。
+	// memory 每個 gateway 實例各自一份，redis 則由所有實例共用
+	CacheBackend        string       `yaml:"cache_backend" env:"CACHE_BACKEND"`
+	CacheMemoryMaxBytes int          `yaml:"cache_memory_max_bytes" env:"CACHE_MEMORY_MAX_BYTES"` // memory 後端的容量上限，超過時淘汰最久沒用的
+	CacheMaxEntryBytes  int          `yaml:"cache_max_entry_bytes" env:"CACHE_MAX_ENTRY_BYTES"`   // 超過的 response 不快取
+	CacheRoutes         []CacheRoute `yaml:"cache_routes"`
 }
 
-// Load 從環境變數讀取設定，若未設定則使用預設值。
-func Load() *Config {
+// BodyRoute 針對某個路徑前綴覆蓋全域的 body 限制，沒有設定的欄位沿用全域值
+type BodyRoute struct {
+	PathPrefix          string   `yaml:"path_prefix"`
+	MaxBodyBytes        int      `yaml:"max_body_bytes"`
+	AllowedContentTypes []string `yaml:"allowed_content_types"`
+	MaxJSONDepth        int      `yaml:"max_json_depth"`
+}
+
+// ForBodyRoute 回傳 route 套用後的完整限制，沒有設定的欄位填入全域值
+func (c *Config) ForBodyRoute(route BodyRoute) BodyRoute {
+	merged := route
+	if merged.MaxBodyBytes == 0 {
+		merged.MaxBodyBytes = c.MaxBodyBytes
+	}
+	if len(merged.AllowedContentTypes) == 0 {
+		merged.AllowedContentTypes = c.AllowedContentTypes
+	}
+	if merged.MaxJSONDepth == 0 {
+		merged.MaxJSONDepth = c.MaxJSONDepth
+	}
+	return merged
+}
+
+// CacheRoute 開啟快取的路徑前綴
+type CacheRoute struct {
+	PathPrefix string        `yaml:"path_prefix"`
+	TTL        time.Duration `yaml:"ttl"`         // 下游沒有指定 Cache-Control max-age 等新鮮期間時使用，0 表示只依下游的指示
+	KeyByUser  bool          `yaml:"key_by_user"` // 依登入的用戶分開快取；沒開啟時，需登入的路由只會快取下游標示 public 的 response
+}
+
+// RouteTimeout 某個路徑前綴的請求逾時，超過時回 504
+type RouteTimeout struct {
+	PathPrefix string        `yaml:"path_prefix"` // 例如 /api/admin，同時套用到 /api/admin/...
+	Timeout    time.Duration `yaml:"timeout"`
+}
+
+// CORSConfig 全域的 CORS 規則，Routes 可針對特定路徑前綴覆蓋。
+//
+// origin 支援子網域萬用字元，例如 https://*.example.com；"*" 代表允許所有 origin，
+// 但不可與 AllowCredentials 同時使用（瀏覽器會拒絕這種回應）。
+type CORSConfig struct {
+	AllowOrigins     []string      `yaml:"allow_origins" env:"CORS_ALLOW_ORIGINS"` // 環境變數以逗號分隔
+	AllowMethods     []string      `yaml:"allow_methods" env:"CORS_ALLOW_METHODS"`
+	AllowHeaders     []string      `yaml:"allow_headers" env:"CORS_ALLOW_HEADERS"`
+	ExposeHeaders    []string      `yaml:"expose_headers" env:"CORS_EXPOSE_HEADERS"`
+	AllowCredentials bool          `yaml:"allow_credentials" env:"CORS_ALLOW_CREDENTIALS"`
+	MaxAge           time.Duration `yaml:"max_age" env:"CORS_MAX_AGE"` // preflight 結果可被瀏覽器快取多久
+	Routes           []CORSRoute   `yaml:"routes"`                     // 只能在 YAML 設定
+}
+
+// CORSRoute 針對某個路徑前綴覆蓋全域的 CORS 規則，沒有設定的欄位沿用全域值。
+type CORSRoute struct {
+	PathPrefix       string        `yaml:"path_prefix"` // 例如 /api/admin，同時套用到 /api/admin/...
+	AllowOrigins     []string      `yaml:"allow_origins"`
+	AllowMethods     []string      `yaml:"allow_methods"`
+	AllowHeaders     []string      `yaml:"allow_headers"`
+	ExposeHeaders    []string      `yaml:"expose_headers"`
+	AllowCredentials *bool         `yaml:"allow_credentials"`
+	MaxAge           time.Duration `yaml:"max_age"`
+}
+
+// ForRoute 回傳 route 套用後的完整規則（不含 Routes）。
+func (c CORSConfig) ForRoute(route CORSRoute) CORSConfig {
+	merged := c
+	merged.Routes = nil
+	if len(route.AllowOrigins) > 0 {
+		merged.AllowOrigins = route.AllowOrigins
+	}
+	if len(route.AllowMethods) > 0 {
+		merged.AllowMethods = route.AllowMethods
+	}
+	if len(route.AllowHeaders) > 0 {
+		merged.AllowHeaders = route.AllowHeaders
+	}
+	if len(route.ExposeHeaders) > 0 {
+		merged.ExposeHeaders = route.ExposeHeaders
+	}
+	if route.AllowCredentials != nil {
+		merged.AllowCredentials = *route.AllowCredentials
+	}
+	if route.MaxAge > 0 {
+		merged.MaxAge = route.MaxAge
+	}
+	return merged
+}
+
+// validate 檢查一組 CORS 規則，name 用於錯誤訊息
+func (c CORSConfig) validate(name string) []error {
+	var errs []error
+	if len(c.AllowOrigins) == 0 {
+		errs = append(errs, fmt.Errorf("%s 至少要允許一個 origin", name))
+	}
+	for _, origin := range c.AllowOrigins {
+		switch {
+		case origin == "*":
+			if c.AllowCredentials {
+				errs = append(errs, fmt.Errorf("%s 允許所有 origin（*）時不可開啟 allow_credentials", name))
+			}
+		case !strings.HasPrefix(origin, "http://") && !strings.HasPrefix(origin, "https://"):
+			errs = append(errs, fmt.Errorf("%s 的 origin 必須以 http:// 或 https:// 開頭，目前為 %q", name, origin))
+		case strings.Count(origin, "*") > 1:
+			errs = append(errs, fmt.Errorf("%s 的 origin 只能有一個 *，目前為 %q", name, origin))
+		}
+	}
+	if c.MaxAge < 0 {
+		errs = append(errs, fmt.Errorf("%s 的 max_age 不可為負數", name))
+	}
+	return errs
+}
+
+// Defaults 回傳本機開發可直接使用的預設值。
+// 其中的 secret 只適用於本機開發，production 模式下沿用會拒絕啟動。
+func Defaults() *Config {
 	return &Config{
-		Port:           getEnv("PORT", "8080"),
-		JWTSecret:      getEnv("JWT_SECRET", "dev-secret-change-in-production"),
-		UserServiceURL: getEnv("USER_SERVICE_URL", "http://localhost:8081"),
+		Environment:                    EnvDevelopment,
+		Port:                           "8080",
+		TrustedProxies:                 nil,
+		JWTSecret:                      "dev-secret-change-in-production",
+		InternalSecret:                 "dev-internal-secret-change-in-production",
+		UserServiceName:                "user-service",
+		UserServiceURL:                 "http://localhost:8081",
+		UserServiceTransport:           TransportGRPC,
+		UserServiceGRPCAddr:            "localhost:9081",
+		UserServiceTimeout:             10 * time.Second,
+		UserServiceConnectTimeout:      2 * time.Second,
+		UserServiceKeepAlive:           30 * time.Second,
+		UserServiceMaxIdleConnsPerHost: 32,
+		UserServiceMaxConnsPerHost:     0,
+		UserServiceIdleConnTimeout:     90 * time.Second,
+		UserServiceHTTP2:               false,
+		RegistryFile:                   "",
+		RegistryRefresh:                5 * time.Second,
+		RedisHost:                      "localhost",
+		RedisPort:                      "6379",
+		ShutdownDrainPeriod:            5 * time.Second,
+		ShutdownTimeout:                20 * time.Second,
+		HealthCheckTimeout:             2 * time.Second,
+		HealthCacheTTL:                 2 * time.Second,
+		HealthExposeDetails:            false,
+		MetricsPort:                    "9090",
+		MaxBodyBytes:                   1 << 20, // 1 MiB，目前的 API 都是小型 JSON
+		AllowedContentTypes:            []string{"application/json", "application/merge-patch+json"},
+		MaxJSONDepth:                   32,
+		CompressionEnabled:             true,
+		CompressionEncodings:           []string{"br", "zstd", "gzip"},
+		CompressionMinSize:             1024,
+		CompressionContentTypes:        []string{"application/json", "text/*"},
+		CacheBackend:                   CacheBackendMemory,
+		CacheMemoryMaxBytes:            64 << 20, // 64 MiB
+		CacheMaxEntryBytes:             1 << 20,  // 1 MiB
+		CORS: CORSConfig{
+			AllowOrigins:     []string{"http://localhost:3000"},
+			AllowMethods:     []string{"GET", "POST", "PUT", "PATCH", "DELETE", "OPTIONS"},
+			AllowHeaders:     []string{"Origin", "Content-Type", "Authorization", "If-Match", "X-Request-ID"},
+			ExposeHeaders:    []string{"Content-Length", "ETag", "X-Request-ID"},
+			AllowCredentials: true,
+			MaxAge:           12 * time.Hour,
+		},
+	}
+}
+
+// Validate 檢查設定值是否合理，回傳所有問題而不是只有第一個。
+func (c *Config) Validate() error {
+	var errs []error
+	check := func(ok bool, format string, args ...interface{}) {
+		if !ok {
+			errs = append(errs, fmt.Errorf(format, args...))
+		}
+	}
+
+	check(c.Environment == EnvDevelopment || c.Environment == EnvProduction,
+		"APP_ENV 必須是 %q 或 %q，目前為 %q", EnvDevelopment, EnvProduction, c.Environment)
+	check(validPort(c.Port), "PORT 必須是 port 號碼，目前為 %q", c.Port)
+	for _, cidr := range c.TrustedProxies {
+		check(validIPOrCIDR(cidr), "TRUSTED_PROXIES 必須是 IP 或 CIDR，目前為 %q", cidr)
+	}
+	check(c.JWTSecret != "", "必須設定 JWT_SECRET")
+	check(c.InternalSecret != "", "必須設定 INTERNAL_AUTH_SECRET")
+	check(c.UserServiceName != "", "必須設定 USER_SERVICE_NAME")
+	check(c.UserServiceTransport == TransportGRPC || c.UserServiceTransport == TransportHTTP,
+		"USER_SERVICE_TRANSPORT 必須是 %q 或 %q，目前為 %q", TransportGRPC, TransportHTTP, c.UserServiceTransport)
+	check(c.UserServiceTimeout > 0, "USER_SERVICE_TIMEOUT 必須大於 0")
+	check(c.UserServiceConnectTimeout > 0, "USER_SERVICE_CONNECT_TIMEOUT 必須大於 0")
+	check(c.UserServiceKeepAlive >= 0, "USER_SERVICE_KEEP_ALIVE 不可為負數")
+	check(c.UserServiceMaxIdleConnsPerHost > 0, "USER_SERVICE_MAX_IDLE_CONNS_PER_HOST 必須大於 0")
+	check(c.UserServiceMaxConnsPerHost >= 0, "USER_SERVICE_MAX_CONNS_PER_HOST 不可為負數")
+	check(c.UserServiceMaxConnsPerHost == 0 || c.UserServiceMaxConnsPerHost >= c.UserServiceMaxIdleConnsPerHost,
+		"USER_SERVICE_MAX_CONNS_PER_HOST 不可小於 USER_SERVICE_MAX_IDLE_CONNS_PER_HOST")
+	check(c.UserServiceIdleConnTimeout > 0, "USER_SERVICE_IDLE_CONN_TIMEOUT 必須大於 0")
+	check(c.RegistryRefresh > 0, "REGISTRY_REFRESH_INTERVAL 必須大於 0")
+	check(c.RedisHost != "", "必須設定 REDIS_HOST")
+	check(validPort(c.RedisPort), "REDIS_PORT 必須是 port 號碼，目前為 %q", c.RedisPort)
+	check(c.ShutdownDrainPeriod >= 0, "SHUTDOWN_DRAIN_PERIOD 不可為負數")
+	check(c.ShutdownTimeout > 0, "SHUTDOWN_TIMEOUT 必須大於 0")
+	check(c.HealthCheckTimeout > 0, "HEALTH_CHECK_TIMEOUT 必須大於 0")
+	check(c.HealthCacheTTL >= 0, "HEALTH_CACHE_TTL 不可為負數")
+	check(c.MetricsPort == "" || validPort(c.MetricsPort), "METRICS_PORT 必須是 port 號碼，目前為 %q", c.MetricsPort)
+	check(c.MetricsPort != c.Port, "METRICS_PORT 不可與 PORT 相同")
+	errs = append(errs, c.CORS.validate("CORS")...)
+	for i, route := range c.CORS.Routes {
+		name := fmt.Sprintf("cors.routes[%d]", i)
+		check(strings.HasPrefix(route.PathPrefix, "/"), "%s 的 path_prefix 必須以 / 開頭，目前為 %q", name, route.PathPrefix)
+		errs = append(errs, c.CORS.ForRoute(route).validate(name)...)
 	}
+	for i, route := range c.RouteTimeouts {
+		name := fmt.Sprintf("route_timeouts[%d]", i)
+		check(strings.HasPrefix(route.PathPrefix, "/"), "%s 的 path_prefix 必須以 / 開頭，目前為 %q", name, route.PathPrefix)
+		check(route.Timeout > 0, "%s 的 timeout 必須大於 0", name)
+	}
+	check(c.MaxBodyBytes > 0, "MAX_BODY_BYTES 必須大於 0")
+	check(c.MaxJSONDepth >= 0, "MAX_JSON_DEPTH 不可為負數")
+	errs = append(errs, validateContentTypes("ALLOWED_CONTENT_TYPES", c.AllowedContentTypes)...)
+	for i, route := range c.BodyRoutes {
+		name := fmt.Sprintf("body_routes[%d]", i)
+		check(strings.HasPrefix(route.PathPrefix, "/"), "%s 的 path_prefix 必須以 / 開頭，目前為 %q", name, route.PathPrefix)
+		check(route.MaxBodyBytes >= 0, "%s 的 max_body_bytes 不可為負數", name)
+		check(route.MaxJSONDepth >= 0, "%s 的 max_json_depth 不可為負數", name)
+		errs = append(errs, validateContentTypes(name+" 的 allowed_content_types", route.AllowedContentTypes)...)
+	}
+	if c.CompressionEnabled {
+		check(len(c.CompressionEncodings) > 0, "開啟壓縮時 COMPRESSION_ENCODINGS 不可為空")
+		for _, encoding := range c.CompressionEncodings {
+			check(encoding == "br" || encoding == "zstd" || encoding == "gzip",
+				"COMPRESSION_ENCODINGS 只支援 br、zstd、gzip，目前為 %q", encoding)
+		}
+		check(c.CompressionMinSize >= 0, "COMPRESSION_MIN_SIZE 不可為負數")
+		for _, t := range c.CompressionContentTypes {
+			if !strings.HasSuffix(t, "/*") {
+				errs = append(errs, validateContentTypes("COMPRESSION_CONTENT_TYPES", []string{t})...)
+			}
+		}
+	}
+
+	check(c.CacheBackend == CacheBackendMemory || c.CacheBackend == CacheBackendRedis,
+		"CACHE_BACKEND 必須是 %q 或 %q，目前為 %q", CacheBackendMemory, CacheBackendRedis, c.CacheBackend)
+	check(c.CacheBackend != CacheBackendMemory || c.CacheMemoryMaxBytes > 0, "CACHE_MEMORY_MAX_BYTES 必須大於 0")
+	check(c.CacheMaxEntryBytes > 0, "CACHE_MAX_ENTRY_BYTES 必須大於 0")
+	for i, route := range c.CacheRoutes {
+		name := fmt.Sprintf("cache_routes[%d]", i)
+		check(strings.HasPrefix(route.PathPrefix, "/"), "%s 的 path_prefix 必須以 / 開頭，目前為 %q", name, route.PathPrefix)
+		check(route.TTL >= 0, "%s 的 ttl 不可為負數", name)
+	}
+
+	if c.Environment == EnvProduction {
+		for _, name := range defaultSecrets(c, Defaults()) {
+			errs = append(errs, fmt.Errorf("production 模式不可使用預設的 %s", name))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// validateContentTypes Content-Type 清單只能是 media type，不含參數（例如 charset）
+func validateContentTypes(name string, types []string) []error {
+	var errs []error
+	for _, t := range types {
+		mediaType, params, err := mime.ParseMediaType(t)
+		if err != nil || len(params) > 0 || mediaType != strings.ToLower(t) {
+			errs = append(errs, fmt.Errorf("%s 必須是不含參數的小寫 media type，目前為 %q", name, t))
+		}
+	}
+	return errs
 }
 
-func getEnv(key, defaultValue string) string {
-	if value := os.Getenv(key); value != "" {
-		return value
+func validIPOrCIDR(value string) bool {
+	if _, _, err := net.ParseCIDR(value); err == nil {
+		return true
 	}
-	return defaultValue
+	return net.ParseIP(value) != nil
+}
+
+func validPort(port string) bool {
+	n, err := strconv.Atoi(port)
+	return err == nil && n > 0 && n <= 65535
 }
@@ -0,0 +1,376 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// writeFile 在暫存目錄寫入檔案並回傳路徑
+func writeFile(t *testing.T, name, content string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), name)
+	require.NoError(t, os.WriteFile(path, []byte(content), 0o600))
+	return path
+}
+
+func TestLoad_Defaults(t *testing.T) {
+	cfg, err := Load()
+	require.NoError(t, err)
+
+	assert.Equal(t, EnvDevelopment, cfg.Environment)
+	assert.Equal(t, "8080", cfg.Port)
+	assert.Equal(t, TransportGRPC, cfg.UserServiceTransport)
+	assert.Equal(t, 10*time.Second, cfg.UserServiceTimeout)
+}
+
+func TestLoad_Precedence(t *testing.T) {
+	t.Setenv("CONFIG_FILE", writeFile(t, "config.yaml", `
+port: "9000"
+user_service_timeout: 3s
+cors:
+  allow_origins: ["https://app.example.com"]
+  routes:
+    - path_prefix: /api/admin
+      allow_origins: ["https://admin.example.com"]
+route_timeouts:
+  - path_prefix: /api/admin
+    timeout: 30s
+`))
+	// 環境變數優先於 YAML
+	t.Setenv("USER_SERVICE_TIMEOUT", "5s")
+	t.Setenv("TRUSTED_PROXIES", "10.1.0.0/16, 10.2.0.5")
+	t.Setenv("COMPRESSION_ENABLED", "false")
+
+	cfg, err := Load()
+	require.NoError(t, err)
+
+	assert.Equal(t, "9000", cfg.Port)
+	assert.Equal(t, 5*time.Second, cfg.UserServiceTimeout)
+	assert.Equal(t, []string{"10.1.0.0/16", "10.2.0.5"}, cfg.TrustedProxies)
+	assert.False(t, cfg.CompressionEnabled)
+	assert.Equal(t, []string{"https://app.example.com"}, cfg.CORS.AllowOrigins)
+	assert.Equal(t, []RouteTimeout{{PathPrefix: "/api/admin", Timeout: 30 * time.Second}}, cfg.RouteTimeouts)
+	// 檔案與環境變數都沒設定的欄位維持預設值
+	assert.Equal(t, "6379", cfg.RedisPort)
+	assert.True(t, cfg.CORS.AllowCredentials)
+}
+
+func TestLoad_Errors(t *testing.T) {
+	tests := []struct {
+		name    string
+		env     map[string]string
+		file    string
+		wantErr []string
+	}{
+		{
+			name:    "unknown yaml key",
+			file:    "user_servce_timeout: 3s\n",
+			wantErr: []string{"user_servce_timeout"},
+		},
+		{
+			name:    "missing config file",
+			env:     map[string]string{"CONFIG_FILE": "/nonexistent/config.yaml"},
+			wantErr: []string{"讀取設定檔失敗"},
+		},
+		{
+			// 格式錯誤不再默默改用預設值，而是一次列出所有錯誤
+			name: "malformed env values",
+			env: map[string]string{
+				"USER_SERVICE_TIMEOUT":                 "ten seconds",
+				"COMPRESSION_ENABLED":                  "sometimes",
+				"USER_SERVICE_MAX_IDLE_CONNS_PER_HOST": "many",
+			},
+			wantErr: []string{
+				`USER_SERVICE_TIMEOUT: 時間長度格式錯誤："ten seconds"`,
+				`COMPRESSION_ENABLED: 布林值格式錯誤："sometimes"`,
+				`USER_SERVICE_MAX_IDLE_CONNS_PER_HOST: 整數格式錯誤："many"`,
+			},
+		},
+		{
+			name:    "invalid value fails validation",
+			env:     map[string]string{"USER_SERVICE_TRANSPORT": "websocket"},
+			wantErr: []string{"設定有誤", `USER_SERVICE_TRANSPORT 必須是 "grpc" 或 "http"，目前為 "websocket"`},
+		},
+		{
+			name:    "production with default secrets",
+			env:     map[string]string{"APP_ENV": EnvProduction},
+			wantErr: []string{"production 模式不可使用預設的 JWT_SECRET", "production 模式不可使用預設的 INTERNAL_AUTH_SECRET"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if tt.file != "" {
+				t.Setenv("CONFIG_FILE", writeFile(t, "config.yaml", tt.file))
+			}
+			for key, value := range tt.env {
+				t.Setenv(key, value)
+			}
+
+			_, err := Load()
+			require.Error(t, err)
+			for _, want := range tt.wantErr {
+				assert.Contains(t, err.Error(), want)
+			}
+		})
+	}
+}
+
+func TestLoad_SecretFile(t *testing.T) {
+	t.Run("reads secret from file", func(t *testing.T) {
+		t.Setenv("JWT_SECRET_FILE", writeFile(t, "jwt_secret", "from-docker-secret\n"))
+
+		cfg, err := Load()
+		require.NoError(t, err)
+		assert.Equal(t, "from-docker-secret", cfg.JWTSecret)
+	})
+
+	t.Run("rejects both value and file", func(t *testing.T) {
+		t.Setenv("JWT_SECRET", "inline")
+		t.Setenv("JWT_SECRET_FILE", writeFile(t, "jwt_secret", "from-file"))
+
+		_, err := Load()
+		assert.ErrorContains(t, err, "JWT_SECRET 與 JWT_SECRET_FILE 不可同時設定")
+	})
+
+	t.Run("missing file", func(t *testing.T) {
+		t.Setenv("INTERNAL_AUTH_SECRET_FILE", filepath.Join(t.TempDir(), "missing"))
+
+		_, err := Load()
+		assert.ErrorContains(t, err, "INTERNAL_AUTH_SECRET_FILE")
+	})
+}
+
+func TestValidate(t *testing.T) {
+	tests := []struct {
+		name    string
+		modify  func(cfg *Config)
+		wantErr []string // 空的表示設定合法
+	}{
+		{
+			name:   "defaults",
+			modify: func(*Config) {},
+		},
+		{
+			name: "collects every problem",
+			modify: func(cfg *Config) {
+				cfg.Environment = "staging"
+				cfg.Port = "http"
+				cfg.TrustedProxies = []string{"10.0.0.0/8", "load-balancer"}
+				cfg.UserServiceTimeout = 0
+				cfg.MaxBodyBytes = 0
+			},
+			wantErr: []string{
+				`APP_ENV 必須是 "development" 或 "production"，目前為 "staging"`,
+				`PORT 必須是 port 號碼，目前為 "http"`,
+				`TRUSTED_PROXIES 必須是 IP 或 CIDR，目前為 "load-balancer"`,
+				"USER_SERVICE_TIMEOUT 必須大於 0",
+				"MAX_BODY_BYTES 必須大於 0",
+			},
+		},
+		{
+			name: "missing secrets",
+			modify: func(cfg *Config) {
+				cfg.JWTSecret = ""
+				cfg.InternalSecret = ""
+			},
+			wantErr: []string{"必須設定 JWT_SECRET", "必須設定 INTERNAL_AUTH_SECRET"},
+		},
+		{
+			name:    "metrics port collides with the public port",
+			modify:  func(cfg *Config) { cfg.MetricsPort = cfg.Port },
+			wantErr: []string{"METRICS_PORT 不可與 PORT 相同"},
+		},
+		{
+			name:   "empty metrics port disables the metrics server",
+			modify: func(cfg *Config) { cfg.MetricsPort = "" },
+		},
+		{
+			name: "connection pool limits",
+			modify: func(cfg *Config) {
+				cfg.UserServiceMaxIdleConnsPerHost = 32
+				cfg.UserServiceMaxConnsPerHost = 8
+				cfg.UserServiceKeepAlive = -time.Second
+			},
+			wantErr: []string{
+				"USER_SERVICE_MAX_CONNS_PER_HOST 不可小於 USER_SERVICE_MAX_IDLE_CONNS_PER_HOST",
+				"USER_SERVICE_KEEP_ALIVE 不可為負數",
+			},
+		},
+		{
+			name: "route overrides",
+			modify: func(cfg *Config) {
+				cfg.RouteTimeouts = []RouteTimeout{{PathPrefix: "api/admin", Timeout: 0}}
+				cfg.CacheRoutes = []CacheRoute{{PathPrefix: "/api/users", TTL: -time.Second}}
+				cfg.BodyRoutes = []BodyRoute{{PathPrefix: "/api/users", AllowedContentTypes: []string{"application/json; charset=utf-8"}}}
+			},
+			wantErr: []string{
+				`route_timeouts[0] 的 path_prefix 必須以 / 開頭，目前為 "api/admin"`,
+				"route_timeouts[0] 的 timeout 必須大於 0",
+				"cache_routes[0] 的 ttl 不可為負數",
+				`body_routes[0] 的 allowed_content_types 必須是不含參數的小寫 media type，目前為 "application/json; charset=utf-8"`,
+			},
+		},
+		{
+			name: "compression settings",
+			modify: func(cfg *Config) {
+				cfg.CompressionEncodings = []string{"gzip", "deflate"}
+				cfg.CompressionContentTypes = []string{"text/*", "application/json; charset=utf-8"}
+			},
+			wantErr: []string{
+				`COMPRESSION_ENCODINGS 只支援 br、zstd、gzip，目前為 "deflate"`,
+				`COMPRESSION_CONTENT_TYPES 必須是不含參數的小寫 media type，目前為 "application/json; charset=utf-8"`,
+			},
+		},
+		{
+			name: "compression settings are ignored when disabled",
+			modify: func(cfg *Config) {
+				cfg.CompressionEnabled = false
+				cfg.CompressionEncodings = nil
+			},
+		},
+		{
+			name:    "unknown cache backend",
+			modify:  func(cfg *Config) { cfg.CacheBackend = "memcached" },
+			wantErr: []string{`CACHE_BACKEND 必須是 "memory" 或 "redis"，目前為 "memcached"`},
+		},
+		{
+			name: "production refuses default secrets",
+			modify: func(cfg *Config) {
+				cfg.Environment = EnvProduction
+				cfg.JWTSecret = "a-real-secret"
+			},
+			wantErr: []string{"production 模式不可使用預設的 INTERNAL_AUTH_SECRET"},
+		},
+		{
+			name: "production with own secrets",
+			modify: func(cfg *Config) {
+				cfg.Environment = EnvProduction
+				cfg.JWTSecret = "a-real-secret"
+				cfg.InternalSecret = "another-real-secret"
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := Defaults()
+			tt.modify(cfg)
+
+			err := cfg.Validate()
+			if len(tt.wantErr) == 0 {
+				assert.NoError(t, err)
+				return
+			}
+			require.Error(t, err)
+			for _, want := range tt.wantErr {
+				assert.Contains(t, err.Error(), want)
+			}
+		})
+	}
+}
+
+func TestValidate_CORS(t *testing.T) {
+	enabled := true
+
+	tests := []struct {
+		name    string
+		modify  func(cors *CORSConfig)
+		wantErr []string // 空的表示設定合法
+	}{
+		{
+			name: "star without credentials",
+			modify: func(cors *CORSConfig) {
+				cors.AllowOrigins = []string{"*"}
+				cors.AllowCredentials = false
+			},
+		},
+		{
+			// 瀏覽器會拒絕 Access-Control-Allow-Origin: * 搭配 credentials 的回應
+			name:    "star with credentials",
+			modify:  func(cors *CORSConfig) { cors.AllowOrigins = []string{"*"} },
+			wantErr: []string{"CORS 允許所有 origin（*）時不可開啟 allow_credentials"},
+		},
+		{
+			name: "route enables credentials on a star origin",
+			modify: func(cors *CORSConfig) {
+				cors.AllowOrigins = []string{"*"}
+				cors.AllowCredentials = false
+				cors.Routes = []CORSRoute{{PathPrefix: "/api/admin", AllowCredentials: &enabled}}
+			},
+			wantErr: []string{"cors.routes[0] 允許所有 origin（*）時不可開啟 allow_credentials"},
+		},
+		{
+			name:   "subdomain wildcard with credentials",
+			modify: func(cors *CORSConfig) { cors.AllowOrigins = []string{"https://*.example.com"} },
+		},
+		{
+			name:    "more than one wildcard",
+			modify:  func(cors *CORSConfig) { cors.AllowOrigins = []string{"https://*.*.example.com"} },
+			wantErr: []string{`CORS 的 origin 只能有一個 *，目前為 "https://*.*.example.com"`},
+		},
+		{
+			name:    "origin without scheme",
+			modify:  func(cors *CORSConfig) { cors.AllowOrigins = []string{"app.example.com"} },
+			wantErr: []string{`CORS 的 origin 必須以 http:// 或 https:// 開頭，目前為 "app.example.com"`},
+		},
+		{
+			name:    "no origins",
+			modify:  func(cors *CORSConfig) { cors.AllowOrigins = nil },
+			wantErr: []string{"CORS 至少要允許一個 origin"},
+		},
+		{
+			name: "route path prefix",
+			modify: func(cors *CORSConfig) {
+				cors.Routes = []CORSRoute{{PathPrefix: "api/admin", MaxAge: time.Minute}}
+			},
+			wantErr: []string{`cors.routes[0] 的 path_prefix 必須以 / 開頭，目前為 "api/admin"`},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := Defaults()
+			tt.modify(&cfg.CORS)
+
+			err := cfg.Validate()
+			if len(tt.wantErr) == 0 {
+				assert.NoError(t, err)
+				return
+			}
+			require.Error(t, err)
+			for _, want := range tt.wantErr {
+				assert.Contains(t, err.Error(), want)
+			}
+		})
+	}
+}
+
+func TestValidate_ProductionOnlyReportsDefaultSecrets(t *testing.T) {
+	cfg := Defaults()
+	cfg.Environment = EnvProduction
+	cfg.JWTSecret = "a-real-secret"
+
+	err := cfg.Validate()
+	require.Error(t, err)
+	assert.NotContains(t, err.Error(), "JWT_SECRET")
+}
+
+func TestRedacted(t *testing.T) {
+	cfg := Defaults()
+	cfg.JWTSecret = "super-secret"
+	cfg.CORS.Routes = []CORSRoute{{PathPrefix: "/api/admin", AllowOrigins: []string{"https://admin.example.com"}}}
+
+	out := strings.Join(cfg.Redacted(), "\n")
+	assert.NotContains(t, out, "super-secret")
+	assert.Contains(t, out, "jwt_secret=******")
+	assert.Contains(t, out, "internal_secret=******")
+	assert.Contains(t, out, "user_service_timeout=10s")
+	assert.Contains(t, out, "cors.routes[0].path_prefix=/api/admin")
+}
@@ -0,0 +1,217 @@
+package config
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+// redacted 印出設定時取代 secret 的字串
+const redacted = "******"
+
+// Load 依序套用預設值、CONFIG_FILE 指定的 YAML 檔與環境變數，並驗證結果。
+//
+// 環境變數優先於 YAML 檔；空字串視為未設定。
+// secret 欄位可改設 <env>_FILE 指向存放值的檔案（例如 JWT_SECRET_FILE=/run/secrets/jwt_secret），
+// 兩者同時設定時視為錯誤，避免不確定到底用了哪一個
+func Load() (*Config, error) {
+	cfg := Defaults()
+	if path := os.Getenv("CONFIG_FILE"); path != "" {
+		if err := loadFile(cfg, path); err != nil {
+			return nil, err
+		}
+	}
+	if err := applyEnv(cfg); err != nil {
+		return nil, err
+	}
+	if err := cfg.Validate(); err != nil {
+		return nil, fmt.Errorf("設定有誤：\n%w", err)
+	}
+	return cfg, nil
+}
+
+// Redacted 以 key=value 列出生效的設定，secret 欄位只顯示是否有值
+func (c *Config) Redacted() []string {
+	var lines []string
+	for _, f := range fields(c) {
+		if f.secret {
+			value := fmt.Sprint(f.value.Interface())
+			if value != "" {
+				value = redacted
+			}
+			lines = append(lines, f.path+"="+value)
+			continue
+		}
+		lines = appendValue(lines, f.path, f.value)
+	}
+	return lines
+}
+
+// appendValue 將 struct 清單（例如 cors.routes）展開成 cors.routes[0].path_prefix=... 的形式
+func appendValue(lines []string, path string, v reflect.Value) []string {
+	switch {
+	case v.Kind() == reflect.Pointer:
+		if v.IsNil() {
+			return lines
+		}
+		return appendValue(lines, path, v.Elem())
+	case v.Kind() == reflect.Slice && v.Type().Elem().Kind() == reflect.Struct:
+		for i := 0; i < v.Len(); i++ {
+			lines = appendValue(lines, fmt.Sprintf("%s[%d]", path, i), v.Index(i))
+		}
+		return lines
+	case v.Kind() == reflect.Struct:
+		t := v.Type()
+		for i := 0; i < t.NumField(); i++ {
+			name := strings.Split(t.Field(i).Tag.Get("yaml"), ",")[0]
+			lines = appendValue(lines, path+"."+name, v.Field(i))
+		}
+		return lines
+	default:
+		return append(lines, path+"="+fmt.Sprint(v.Interface()))
+	}
+}
+
+// loadFile 以 YAML 檔覆蓋預設值，未知的 key 視為錯誤，避免拼錯的設定被默默忽略
+func loadFile(cfg *Config, path string) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return fmt.Errorf("讀取設定檔失敗：%w", err)
+	}
+	dec := yaml.NewDecoder(bytes.NewReader(data))
+	dec.KnownFields(true)
+	if err := dec.Decode(cfg); err != nil && !errors.Is(err, io.EOF) {
+		return fmt.Errorf("設定檔 %s 格式錯誤：%w", path, err)
+	}
+	return nil
+}
+
+// applyEnv 以環境變數覆蓋設定，格式錯誤時回傳所有錯誤，不再默默改用預設值
+func applyEnv(cfg *Config) error {
+	var errs []error
+	for _, f := range fields(cfg) {
+		if f.env == "" {
+			continue
+		}
+
+		raw, source := os.Getenv(f.env), f.env
+		if f.secret {
+			if path := os.Getenv(f.env + "_FILE"); path != "" {
+				if raw != "" {
+					errs = append(errs, fmt.Errorf("%s 與 %s_FILE 不可同時設定", f.env, f.env))
+					continue
+				}
+				data, err := os.ReadFile(path)
+				if err != nil {
+					errs = append(errs, fmt.Errorf("%s_FILE: %w", f.env, err))
+					continue
+				}
+				raw, source = strings.TrimRight(string(data), "\r\n"), f.env+"_FILE"
+			}
+		}
+		if raw == "" {
+			continue
+		}
+
+		if err := setValue(f.value, raw); err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", source, err))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+var durationType = reflect.TypeOf(time.Duration(0))
+
+// setValue 將字串轉成欄位的型別後寫入
+func setValue(v reflect.Value, raw string) error {
+	switch {
+	case v.Type() == durationType:
+		d, err := time.ParseDuration(raw)
+		if err != nil {
+			return fmt.Errorf("時間長度格式錯誤：%q", raw)
+		}
+		v.SetInt(int64(d))
+	case v.Kind() == reflect.String:
+		v.SetString(raw)
+	case v.Kind() == reflect.Bool:
+		b, err := strconv.ParseBool(raw)
+		if err != nil {
+			return fmt.Errorf("布林值格式錯誤：%q", raw)
+		}
+		v.SetBool(b)
+	case v.Kind() == reflect.Int:
+		n, err := strconv.Atoi(raw)
+		if err != nil {
+			return fmt.Errorf("整數格式錯誤：%q", raw)
+		}
+		v.SetInt(int64(n))
+	case v.Kind() == reflect.Slice && v.Type().Elem().Kind() == reflect.String:
+		// 逗號分隔，例如 "https://a.example.com, https://b.example.com"
+		var items []string
+		for _, item := range strings.Split(raw, ",") {
+			if item = strings.TrimSpace(item); item != "" {
+				items = append(items, item)
+			}
+		}
+		v.Set(reflect.ValueOf(items))
+	default:
+		return fmt.Errorf("不支援的設定型別 %s", v.Type())
+	}
+	return nil
+}
+
+// field 一個可設定的欄位
+type field struct {
+	path   string // YAML 路徑，例如 database.password
+	env    string
+	secret bool
+	value  reflect.Value
+}
+
+// fields 依宣告順序列出所有可設定的欄位，巢狀的設定 struct 會展開
+func fields(cfg *Config) []field {
+	var out []field
+	collect(reflect.ValueOf(cfg).Elem(), "", &out)
+	return out
+}
+
+func collect(v reflect.Value, prefix string, out *[]field) {
+	t := v.Type()
+	for i := 0; i < t.NumField(); i++ {
+		sf := t.Field(i)
+		path := strings.Split(sf.Tag.Get("yaml"), ",")[0]
+		if prefix != "" {
+			path = prefix + "." + path
+		}
+		if sf.Type.Kind() == reflect.Struct && sf.Tag.Get("env") == "" {
+			collect(v.Field(i), path, out)
+			continue
+		}
+		*out = append(*out, field{
+			path:   path,
+			env:    sf.Tag.Get("env"),
+			secret: sf.Tag.Get("secret") == "true",
+			value:  v.Field(i),
+		})
+	}
+}
+
+// defaultSecrets 回傳仍沿用預設值的 secret 欄位（以環境變數名稱表示）
+func defaultSecrets(cfg, defaults *Config) []string {
+	current, initial := fields(cfg), fields(defaults)
+	var names []string
+	for i, f := range current {
+		if f.secret && f.value.Interface() == initial[i].value.Interface() {
+			names = append(names, f.env)
+		}
+	}
+	return names
+}
@@ -0,0 +1,138 @@
+package discovery
+
+import (
+	"context"
+	"errors"
+	"log"
+	"reflect"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// ErrNoInstances 目前查不到任何可用的實例
+var ErrNoInstances = errors.New("沒有可用的服務實例")
+
+// DefaultRefreshInterval Pool 重新查詢 Source 的預設間隔
+const DefaultRefreshInterval = 5 * time.Second
+
+// Pool 持有一個服務目前的實例清單，定期從 Source 刷新，並以 round-robin 挑選實例
+//
+// 刷新失敗時保留上一次的清單，registry 短暫故障不會讓 gateway 立刻失去所有上游
+type Pool struct {
+	service  string
+	source   Source
+	interval time.Duration
+
+	mu        sync.RWMutex
+	instances []Instance
+	watchers  map[int]func([]Instance)
+	nextWatch int
+
+	next atomic.Uint64
+}
+
+// NewPool 建立 Pool，interval <= 0 時使用 DefaultRefreshInterval
+func NewPool(service string, source Source, interval time.Duration) *Pool {
+	if interval <= 0 {
+		interval = DefaultRefreshInterval
+	}
+	return &Pool{
+		service:  service,
+		source:   source,
+		interval: interval,
+		watchers: make(map[int]func([]Instance)),
+	}
+}
+
+// Service 回傳 Pool 對應的服務名稱
+func (p *Pool) Service() string {
+	return p.service
+}
+
+// Refresh 立即從 Source 重新查詢；清單有變動時通知所有 watcher
+// 查無實例也視為失敗，不會把既有清單清空
+func (p *Pool) Refresh(ctx context.Context) error {
+	instances, err := p.source.Instances(ctx, p.service)
+	if err != nil {
+		return err
+	}
+	if len(instances) == 0 {
+		return ErrNoInstances
+	}
+
+	p.mu.Lock()
+	if reflect.DeepEqual(p.instances, instances) {
+		p.mu.Unlock()
+		return nil
+	}
+	p.instances = instances
+	watchers := make([]func([]Instance), 0, len(p.watchers))
+	for _, fn := range p.watchers {
+		watchers = append(watchers, fn)
+	}
+	p.mu.Unlock()
+
+	log.Printf("[Gateway] 上游實例更新 service=%s count=%d", p.service, len(instances))
+	for _, fn := range watchers {
+		fn(instances)
+	}
+	return nil
+}
+
+// Run 每隔 interval 刷新一次，直到 ctx 結束
+func (p *Pool) Run(ctx context.Context) {
+	ticker := time.NewTicker(p.interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if err := p.Refresh(ctx); err != nil && ctx.Err() == nil {
+				log.Printf("[Gateway] 刷新上游實例失敗 service=%s err=%v", p.service, err)
+			}
+		}
+	}
+}
+
+// Instances 回傳目前的實例清單
+func (p *Pool) Instances() []Instance {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+	return p.instances
+}
+
+// Watch 註冊清單變動時的 callback，註冊當下若已有實例會立即呼叫一次
+// 回傳的函式用來取消註冊
+func (p *Pool) Watch(fn func([]Instance)) (cancel func()) {
+	p.mu.Lock()
+	id := p.nextWatch
+	p.nextWatch++
+	p.watchers[id] = fn
+	current := p.instances
+	p.mu.Unlock()
+
+	if len(current) > 0 {
+		fn(current)
+	}
+	return func() {
+		p.mu.Lock()
+		delete(p.watchers, id)
+		p.mu.Unlock()
+	}
+}
+
+// NextHTTPAddr 以 round-robin 挑出下一個有 HTTP 位址的實例
+func (p *Pool) NextHTTPAddr() (string, error) {
+	instances := p.Instances()
+	n := len(instances)
+	for i := 0; i < n; i++ {
+		instance := instances[int(p.next.Add(1)-1)%n]
+		if instance.HTTPAddr != "" {
+			return instance.HTTPAddr, nil
+		}
+	}
+	return "", ErrNoInstances
+}
@@ -0,0 +1,241 @@
+package discovery
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// fakeSource 回傳可隨時替換的實例清單或錯誤，並記錄被查詢的次數
+type fakeSource struct {
+	mu        sync.Mutex
+	instances []Instance
+	err       error
+	calls     int
+}
+
+func (s *fakeSource) set(instances []Instance, err error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.instances, s.err = instances, err
+}
+
+func (s *fakeSource) callCount() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.calls
+}
+
+func (s *fakeSource) Instances(_ context.Context, _ string) ([]Instance, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.calls++
+	return s.instances, s.err
+}
+
+var (
+	instanceA = Instance{ID: "user-1", Service: "user-service", HTTPAddr: "http://10.0.0.1:8081", GRPCAddr: "10.0.0.1:9081"}
+	instanceB = Instance{ID: "user-2", Service: "user-service", HTTPAddr: "http://10.0.0.2:8081", GRPCAddr: "10.0.0.2:9081"}
+	grpcOnly  = Instance{ID: "user-3", Service: "user-service", GRPCAddr: "10.0.0.3:9081"}
+)
+
+func TestPoolRefresh(t *testing.T) {
+	errRegistry := errors.New("registry down")
+
+	tests := []struct {
+		name         string
+		initial      []Instance // 先成功刷新一次的清單，nil 表示 Pool 還是空的
+		next         []Instance
+		nextErr      error
+		wantErr      error
+		want         []Instance
+		wantNotified bool
+	}{
+		{
+			name:         "first refresh stores instances",
+			next:         []Instance{instanceA},
+			want:         []Instance{instanceA},
+			wantNotified: true,
+		},
+		{
+			name:         "added instance",
+			initial:      []Instance{instanceA},
+			next:         []Instance{instanceA, instanceB},
+			want:         []Instance{instanceA, instanceB},
+			wantNotified: true,
+		},
+		{
+			name:         "removed instance",
+			initial:      []Instance{instanceA, instanceB},
+			next:         []Instance{instanceB},
+			want:         []Instance{instanceB},
+			wantNotified: true,
+		},
+		{
+			name:    "unchanged list does not notify",
+			initial: []Instance{instanceA, instanceB},
+			next:    []Instance{instanceA, instanceB},
+			want:    []Instance{instanceA, instanceB},
+		},
+		{
+			name:    "source error keeps the previous list",
+			initial: []Instance{instanceA},
+			nextErr: errRegistry,
+			wantErr: errRegistry,
+			want:    []Instance{instanceA},
+		},
+		{
+			name:    "empty result keeps the previous list",
+			initial: []Instance{instanceA},
+			next:    []Instance{},
+			wantErr: ErrNoInstances,
+			want:    []Instance{instanceA},
+		},
+		{
+			name:    "empty result on an empty pool",
+			next:    nil,
+			wantErr: ErrNoInstances,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			source := &fakeSource{}
+			pool := NewPool("user-service", source, 0)
+			if tt.initial != nil {
+				source.set(tt.initial, nil)
+				require.NoError(t, pool.Refresh(context.Background()))
+			}
+
+			notified := false
+			cancel := pool.Watch(func([]Instance) { notified = true })
+			defer cancel()
+			notified = false // 註冊當下的通知不算
+
+			source.set(tt.next, tt.nextErr)
+			err := pool.Refresh(context.Background())
+
+			assert.ErrorIs(t, err, tt.wantErr)
+			assert.Equal(t, tt.want, pool.Instances())
+			assert.Equal(t, tt.wantNotified, notified)
+		})
+	}
+}
+
+func TestPoolNextHTTPAddr(t *testing.T) {
+	tests := []struct {
+		name      string
+		instances []Instance
+		want      []string // 連續呼叫依序拿到的位址
+		wantErr   error
+	}{
+		{
+			name:    "empty pool",
+			wantErr: ErrNoInstances,
+		},
+		{
+			name:      "round robin",
+			instances: []Instance{instanceA, instanceB},
+			want:      []string{instanceA.HTTPAddr, instanceB.HTTPAddr, instanceA.HTTPAddr, instanceB.HTTPAddr},
+		},
+		{
+			name:      "skips instances without an HTTP address",
+			instances: []Instance{instanceA, grpcOnly},
+			want:      []string{instanceA.HTTPAddr, instanceA.HTTPAddr, instanceA.HTTPAddr},
+		},
+		{
+			name:      "no instance has an HTTP address",
+			instances: []Instance{grpcOnly},
+			wantErr:   ErrNoInstances,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			pool := NewPool("user-service", &fakeSource{instances: tt.instances}, 0)
+			if len(tt.instances) > 0 {
+				require.NoError(t, pool.Refresh(context.Background()))
+			}
+
+			if tt.wantErr != nil {
+				_, err := pool.NextHTTPAddr()
+				assert.ErrorIs(t, err, tt.wantErr)
+				return
+			}
+			for i, want := range tt.want {
+				got, err := pool.NextHTTPAddr()
+				require.NoError(t, err)
+				assert.Equal(t, want, got, "call %d", i)
+			}
+		})
+	}
+}
+
+func TestPoolWatch(t *testing.T) {
+	t.Run("called immediately when instances exist", func(t *testing.T) {
+		pool := NewPool("user-service", &fakeSource{instances: []Instance{instanceA}}, 0)
+		require.NoError(t, pool.Refresh(context.Background()))
+
+		var got []Instance
+		cancel := pool.Watch(func(instances []Instance) { got = instances })
+		defer cancel()
+
+		assert.Equal(t, []Instance{instanceA}, got)
+	})
+
+	t.Run("not called on an empty pool", func(t *testing.T) {
+		pool := NewPool("user-service", &fakeSource{}, 0)
+
+		called := false
+		cancel := pool.Watch(func([]Instance) { called = true })
+		defer cancel()
+
+		assert.False(t, called)
+	})
+
+	t.Run("cancel stops notifications", func(t *testing.T) {
+		source := &fakeSource{instances: []Instance{instanceA}}
+		pool := NewPool("user-service", source, 0)
+
+		calls := 0
+		cancel := pool.Watch(func([]Instance) { calls++ })
+		require.NoError(t, pool.Refresh(context.Background()))
+		cancel()
+		source.set([]Instance{instanceA, instanceB}, nil)
+		require.NoError(t, pool.Refresh(context.Background()))
+
+		assert.Equal(t, 1, calls)
+	})
+}
+
+func TestPoolRun(t *testing.T) {
+	source := &fakeSource{instances: []Instance{instanceA}}
+	pool := NewPool("user-service", source, 10*time.Millisecond)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		pool.Run(ctx)
+		close(done)
+	}()
+
+	// 之後的刷新會帶入新的實例
+	require.Eventually(t, func() bool { return len(pool.Instances()) == 1 }, time.Second, 5*time.Millisecond)
+	source.set([]Instance{instanceA, instanceB}, nil)
+	require.Eventually(t, func() bool { return len(pool.Instances()) == 2 }, time.Second, 5*time.Millisecond)
+
+	cancel()
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("Run did not return after the context was cancelled")
+	}
+	calls := source.callCount()
+	time.Sleep(30 * time.Millisecond)
+	assert.Equal(t, calls, source.callCount(), "no refresh after Run returns")
+}
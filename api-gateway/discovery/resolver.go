@@ -0,0 +1,78 @@
+package discovery
+
+import (
+	"fmt"
+
+	"google.golang.org/grpc/resolver"
+)
+
+// Scheme gRPC target 使用的 scheme，例如 grpc.Dial("registry:///user-service", ...)
+const Scheme = "registry"
+
+// RoundRobinServiceConfig 讓 gRPC client 把請求平均分散到 resolver 給出的所有位址
+// 預設的 pick_first 只會連第一個實例
+const RoundRobinServiceConfig = `{"loadBalancingConfig":[{"round_robin":{}}]}`
+
+// Target 回傳指向 pool 所屬服務的 gRPC target
+func Target(pool *Pool) string {
+	return Scheme + ":///" + pool.Service()
+}
+
+// NewResolverBuilder 建立 gRPC resolver，把 Pool 的 gRPC 位址交給 gRPC client 做負載平衡；
+// 實例清單變動時即時更新連線，不需要重新 Dial
+func NewResolverBuilder(pools ...*Pool) resolver.Builder {
+	b := &resolverBuilder{pools: make(map[string]*Pool, len(pools))}
+	for _, pool := range pools {
+		b.pools[pool.Service()] = pool
+	}
+	return b
+}
+
+type resolverBuilder struct {
+	pools map[string]*Pool
+}
+
+func (b *resolverBuilder) Scheme() string {
+	return Scheme
+}
+
+func (b *resolverBuilder) Build(target resolver.Target, cc resolver.ClientConn, _ resolver.BuildOptions) (resolver.Resolver, error) {
+	pool, ok := b.pools[target.Endpoint()]
+	if !ok {
+		return nil, fmt.Errorf("registry 中沒有服務 %q", target.Endpoint())
+	}
+
+	r := &poolResolver{cc: cc}
+	r.cancel = pool.Watch(r.update)
+	if len(pool.Instances()) == 0 {
+		cc.ReportError(ErrNoInstances)
+	}
+	return r, nil
+}
+
+// poolResolver 將 Pool 的變動轉成 gRPC resolver state
+type poolResolver struct {
+	cc     resolver.ClientConn
+	cancel func()
+}
+
+func (r *poolResolver) update(instances []Instance) {
+	addrs := make([]resolver.Address, 0, len(instances))
+	for _, instance := range instances {
+		if instance.GRPCAddr != "" {
+			addrs = append(addrs, resolver.Address{Addr: instance.GRPCAddr})
+		}
+	}
+	if len(addrs) == 0 {
+		r.cc.ReportError(ErrNoInstances)
+		return
+	}
+	r.cc.UpdateState(resolver.State{Addresses: addrs})
+}
+
+// ResolveNow 清單由 Pool 定期刷新，這裡不另外查詢
+func (r *poolResolver) ResolveNow(resolver.ResolveNowOptions) {}
+
+func (r *poolResolver) Close() {
+	r.cancel()
+}
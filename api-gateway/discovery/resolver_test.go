@@ -0,0 +1,151 @@
+package discovery
+
+import (
+	"context"
+	"net/url"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc/resolver"
+)
+
+// fakeClientConn 記錄 resolver 回報的位址與錯誤
+// 未實作的方法沿用嵌入的 nil interface（呼叫到會 panic）
+type fakeClientConn struct {
+	resolver.ClientConn
+
+	mu     sync.Mutex
+	states []resolver.State
+	errs   []error
+}
+
+func (c *fakeClientConn) UpdateState(state resolver.State) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.states = append(c.states, state)
+	return nil
+}
+
+func (c *fakeClientConn) ReportError(err error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.errs = append(c.errs, err)
+}
+
+// lastAddrs 最後一次回報的位址
+func (c *fakeClientConn) lastAddrs() []string {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if len(c.states) == 0 {
+		return nil
+	}
+	var addrs []string
+	for _, addr := range c.states[len(c.states)-1].Addresses {
+		addrs = append(addrs, addr.Addr)
+	}
+	return addrs
+}
+
+// buildResolver 以 registry:///<service> 建立 resolver
+func buildResolver(t *testing.T, service string, pools ...*Pool) (*fakeClientConn, resolver.Resolver, error) {
+	t.Helper()
+	cc := &fakeClientConn{}
+	target := resolver.Target{URL: url.URL{Scheme: Scheme, Path: "/" + service}}
+	r, err := NewResolverBuilder(pools...).Build(target, cc, resolver.BuildOptions{})
+	if r != nil {
+		t.Cleanup(r.Close)
+	}
+	return cc, r, err
+}
+
+func TestTarget(t *testing.T) {
+	pool := NewPool("user-service", &fakeSource{}, 0)
+
+	assert.Equal(t, "registry:///user-service", Target(pool))
+}
+
+func TestResolverBuild(t *testing.T) {
+	tests := []struct {
+		name      string
+		instances []Instance
+		wantAddrs []string
+		wantErr   error
+	}{
+		{
+			name:      "reports every gRPC address",
+			instances: []Instance{instanceA, instanceB},
+			wantAddrs: []string{instanceA.GRPCAddr, instanceB.GRPCAddr},
+		},
+		{
+			name:      "skips instances without a gRPC address",
+			instances: []Instance{{ID: "http-only", HTTPAddr: "http://10.0.0.9:8081"}, instanceB},
+			wantAddrs: []string{instanceB.GRPCAddr},
+		},
+		{
+			name:      "no instance has a gRPC address",
+			instances: []Instance{{ID: "http-only", HTTPAddr: "http://10.0.0.9:8081"}},
+			wantErr:   ErrNoInstances,
+		},
+		{
+			name:    "empty pool",
+			wantErr: ErrNoInstances,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			pool := NewPool("user-service", &fakeSource{instances: tt.instances}, 0)
+			if len(tt.instances) > 0 {
+				require.NoError(t, pool.Refresh(context.Background()))
+			}
+
+			cc, _, err := buildResolver(t, "user-service", pool)
+
+			require.NoError(t, err)
+			assert.Equal(t, tt.wantAddrs, cc.lastAddrs())
+			if tt.wantErr != nil {
+				require.NotEmpty(t, cc.errs)
+				assert.ErrorIs(t, cc.errs[0], tt.wantErr)
+			} else {
+				assert.Empty(t, cc.errs)
+			}
+		})
+	}
+}
+
+func TestResolverBuildUnknownService(t *testing.T) {
+	pool := NewPool("user-service", &fakeSource{}, 0)
+
+	_, _, err := buildResolver(t, "order-service", pool)
+
+	assert.Error(t, err)
+}
+
+func TestResolverRefresh(t *testing.T) {
+	source := &fakeSource{}
+	pool := NewPool("user-service", source, 0)
+	cc, r, err := buildResolver(t, "user-service", pool)
+	require.NoError(t, err)
+
+	// Pool 刷新後不需要重新 Dial，resolver 直接收到新的位址
+	source.set([]Instance{instanceA}, nil)
+	require.NoError(t, pool.Refresh(context.Background()))
+	assert.Equal(t, []string{instanceA.GRPCAddr}, cc.lastAddrs())
+
+	source.set([]Instance{instanceA, instanceB}, nil)
+	require.NoError(t, pool.Refresh(context.Background()))
+	assert.Equal(t, []string{instanceA.GRPCAddr, instanceB.GRPCAddr}, cc.lastAddrs())
+
+	// registry 查不到實例時保留原本的位址
+	source.set(nil, nil)
+	assert.ErrorIs(t, pool.Refresh(context.Background()), ErrNoInstances)
+	assert.Equal(t, []string{instanceA.GRPCAddr, instanceB.GRPCAddr}, cc.lastAddrs())
+
+	// Close 之後不再更新
+	r.Close()
+	source.set([]Instance{instanceB}, nil)
+	require.NoError(t, pool.Refresh(context.Background()))
+	assert.Equal(t, []string{instanceA.GRPCAddr, instanceB.GRPCAddr}, cc.lastAddrs())
+}
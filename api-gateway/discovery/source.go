@@ -0,0 +1,151 @@
+package discovery
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"sort"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// instanceKeyPrefix 需與 user-service registry.InstanceKeyPrefix 相同，
+// 每個服務實例啟動時寫入 <prefix><service>:<instance id>，並以 heartbeat 延長 TTL
+const instanceKeyPrefix = "registry:instances:"
+
+// Instance 一個下游服務實例的位址
+type Instance struct {
+	ID       string `json:"id"`
+	Service  string `json:"service"`
+	HTTPAddr string `json:"http_addr,omitempty"` // 含 scheme，例如 http://user-service:8081
+	GRPCAddr string `json:"grpc_addr,omitempty"` // host:port
+}
+
+// Source 查詢某個服務目前有哪些實例
+type Source interface {
+	Instances(ctx context.Context, service string) ([]Instance, error)
+}
+
+// ── Redis ───────────────────────────────────────────────────────────────────
+
+// RedisSource 讀取服務實例自行登記在 Redis 的紀錄，紀錄過期即代表實例已不存在
+type RedisSource struct {
+	client *redis.Client
+}
+
+// NewRedisSource 建立以 Redis 為後端的 Source
+func NewRedisSource(client *redis.Client) *RedisSource {
+	return &RedisSource{client: client}
+}
+
+// Instances 以 SCAN 找出該服務所有尚未過期的實例
+func (s *RedisSource) Instances(ctx context.Context, service string) ([]Instance, error) {
+	var keys []string
+	iter := s.client.Scan(ctx, 0, instanceKeyPrefix+service+":*", 100).Iterator()
+	for iter.Next(ctx) {
+		keys = append(keys, iter.Val())
+	}
+	if err := iter.Err(); err != nil {
+		return nil, err
+	}
+	if len(keys) == 0 {
+		return nil, nil
+	}
+
+	// SCAN 與 MGET 之間紀錄可能剛好過期，拿到 nil 就略過
+	values, err := s.client.MGet(ctx, keys...).Result()
+	if err != nil {
+		return nil, err
+	}
+	instances := make([]Instance, 0, len(values))
+	for i, value := range values {
+		raw, ok := value.(string)
+		if !ok {
+			continue
+		}
+		var instance Instance
+		if err := json.Unmarshal([]byte(raw), &instance); err != nil {
+			log.Printf("[Gateway] registry 紀錄格式錯誤 key=%s err=%v", keys[i], err)
+			continue
+		}
+		instances = append(instances, instance)
+	}
+	// SCAN 的順序不固定，排序後 Pool 才能正確判斷清單是否有變動
+	sort.Slice(instances, func(i, j int) bool { return instances[i].ID < instances[j].ID })
+	return instances, nil
+}
+
+// ── 靜態檔案 ─────────────────────────────────────────────────────────────────
+
+// FileSource 從 JSON 檔讀取服務實例，Redis 無法使用時作為備援
+//
+// 檔案格式為服務名稱對應實例清單：
+//
+//	{
+//	  "user-service": [
+//	    {"id": "user-1", "http_addr": "http://user-service:8081", "grpc_addr": "user-service:9081"}
+//	  ]
+//	}
+//
+// 每次查詢都重新讀檔，修改檔案後不需要重啟 gateway
+type FileSource struct {
+	path string
+}
+
+// NewFileSource 建立讀取 path 的 FileSource
+func NewFileSource(path string) *FileSource {
+	return &FileSource{path: path}
+}
+
+// Instances 讀檔並回傳該服務的實例
+func (s *FileSource) Instances(_ context.Context, service string) ([]Instance, error) {
+	data, err := os.ReadFile(s.path)
+	if err != nil {
+		return nil, err
+	}
+	var services map[string][]Instance
+	if err := json.Unmarshal(data, &services); err != nil {
+		return nil, fmt.Errorf("registry 檔案格式錯誤：%w", err)
+	}
+	instances := services[service]
+	for i := range instances {
+		instances[i].Service = service
+	}
+	return instances, nil
+}
+
+// ── 固定清單 ─────────────────────────────────────────────────────────────────
+
+// StaticSource 固定的實例清單，通常由環境變數組成，是最後一層備援
+type StaticSource map[string][]Instance
+
+// Instances 回傳該服務的固定實例
+func (s StaticSource) Instances(_ context.Context, service string) ([]Instance, error) {
+	return s[service], nil
+}
+
+// ── 依序備援 ─────────────────────────────────────────────────────────────────
+
+// FallbackSource 依序查詢各個 Source，採用第一個查得到實例的結果
+//
+// 前面的 Source 出錯或查無實例時才往下找，例如 Redis → 靜態檔案 → 環境變數
+type FallbackSource []Source
+
+// Instances 回傳第一個非空的結果；全部查無實例時回傳途中遇到的所有錯誤
+func (s FallbackSource) Instances(ctx context.Context, service string) ([]Instance, error) {
+	var errs []error
+	for _, source := range s {
+		instances, err := source.Instances(ctx, service)
+		if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		if len(instances) > 0 {
+			return instances, nil
+		}
+	}
+	return nil, errors.Join(errs...)
+}
@@ -0,0 +1,143 @@
+package discovery
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/redis/go-redis/v9"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRedisSource(t *testing.T) {
+	server := miniredis.RunT(t)
+	client := redis.NewClient(&redis.Options{Addr: server.Addr()})
+	t.Cleanup(func() { client.Close() })
+
+	require.NoError(t, server.Set(instanceKeyPrefix+"user-service:user-2", `{"id":"user-2","service":"user-service","http_addr":"http://10.0.0.2:8081","grpc_addr":"10.0.0.2:9081"}`))
+	require.NoError(t, server.Set(instanceKeyPrefix+"user-service:user-1", `{"id":"user-1","service":"user-service","http_addr":"http://10.0.0.1:8081","grpc_addr":"10.0.0.1:9081"}`))
+	require.NoError(t, server.Set(instanceKeyPrefix+"user-service:broken", `not json`))
+	require.NoError(t, server.Set(instanceKeyPrefix+"user-service:expired", `{"id":"user-0","service":"user-service"}`))
+	server.SetTTL(instanceKeyPrefix+"user-service:expired", time.Second)
+	require.NoError(t, server.Set(instanceKeyPrefix+"order-service:order-1", `{"id":"order-1","service":"order-service"}`))
+	server.FastForward(2 * time.Second)
+
+	source := NewRedisSource(client)
+
+	t.Run("returns live instances sorted by id", func(t *testing.T) {
+		instances, err := source.Instances(context.Background(), "user-service")
+
+		require.NoError(t, err)
+		assert.Equal(t, []Instance{instanceA, instanceB}, instances)
+	})
+
+	t.Run("unknown service", func(t *testing.T) {
+		instances, err := source.Instances(context.Background(), "billing-service")
+
+		assert.NoError(t, err)
+		assert.Empty(t, instances)
+	})
+
+	t.Run("redis unavailable", func(t *testing.T) {
+		down := redis.NewClient(&redis.Options{Addr: "127.0.0.1:1", MaxRetries: -1})
+		t.Cleanup(func() { down.Close() })
+
+		_, err := NewRedisSource(down).Instances(context.Background(), "user-service")
+
+		assert.Error(t, err)
+	})
+}
+
+func TestFileSource(t *testing.T) {
+	dir := t.TempDir()
+	valid := filepath.Join(dir, "registry.json")
+	require.NoError(t, os.WriteFile(valid, []byte(`{
+		"user-service": [
+			{"id": "user-1", "http_addr": "http://10.0.0.1:8081", "grpc_addr": "10.0.0.1:9081"}
+		]
+	}`), 0o600))
+	broken := filepath.Join(dir, "broken.json")
+	require.NoError(t, os.WriteFile(broken, []byte(`{"user-service":`), 0o600))
+
+	tests := []struct {
+		name    string
+		path    string
+		service string
+		want    []Instance
+		wantErr bool
+	}{
+		{name: "fills in the service name", path: valid, service: "user-service", want: []Instance{instanceA}},
+		{name: "unknown service", path: valid, service: "order-service"},
+		{name: "missing file", path: filepath.Join(dir, "missing.json"), service: "user-service", wantErr: true},
+		{name: "malformed file", path: broken, service: "user-service", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			instances, err := NewFileSource(tt.path).Instances(context.Background(), tt.service)
+
+			if tt.wantErr {
+				assert.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tt.want, instances)
+		})
+	}
+}
+
+func TestFallbackSource(t *testing.T) {
+	errRedis := errors.New("redis down")
+	errFile := errors.New("file missing")
+
+	tests := []struct {
+		name    string
+		sources FallbackSource
+		want    []Instance
+		wantErr []error
+	}{
+		{
+			name:    "first source wins",
+			sources: FallbackSource{&fakeSource{instances: []Instance{instanceA}}, StaticSource{"user-service": {instanceB}}},
+			want:    []Instance{instanceA},
+		},
+		{
+			name:    "falls through on error",
+			sources: FallbackSource{&fakeSource{err: errRedis}, StaticSource{"user-service": {instanceB}}},
+			want:    []Instance{instanceB},
+		},
+		{
+			name:    "falls through on an empty result",
+			sources: FallbackSource{&fakeSource{}, StaticSource{"user-service": {instanceB}}},
+			want:    []Instance{instanceB},
+		},
+		{
+			name:    "every source fails",
+			sources: FallbackSource{&fakeSource{err: errRedis}, &fakeSource{err: errFile}, StaticSource{}},
+			wantErr: []error{errRedis, errFile},
+		},
+		{
+			name:    "every source is empty",
+			sources: FallbackSource{&fakeSource{}, StaticSource{}},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			instances, err := tt.sources.Instances(context.Background(), "user-service")
+
+			assert.Equal(t, tt.want, instances)
+			if len(tt.wantErr) == 0 {
+				assert.NoError(t, err)
+			}
+			for _, want := range tt.wantErr {
+				assert.ErrorIs(t, err, want)
+			}
+		})
+	}
+}
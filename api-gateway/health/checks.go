@@ -0,0 +1,57 @@
+package health
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+
+	"github.com/redis/go-redis/v9"
+	"google.golang.org/grpc"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+)
+
+// Redis 確認 Redis 連線可用（token 撤銷檢查與 service registry 都依賴它）
+func Redis(client *redis.Client) CheckFunc {
+	return func(ctx context.Context) error {
+		return client.Ping(ctx).Err()
+	}
+}
+
+// GRPCUpstream 以標準的 gRPC health checking protocol 確認上游的 service 處於 SERVING
+// conn 若以 round-robin 連到多個實例，每次檢查會落在其中一個
+func GRPCUpstream(conn grpc.ClientConnInterface, service string) CheckFunc {
+	client := healthpb.NewHealthClient(conn)
+	return func(ctx context.Context) error {
+		resp, err := client.Check(ctx, &healthpb.HealthCheckRequest{Service: service})
+		if err != nil {
+			return err
+		}
+		if resp.GetStatus() != healthpb.HealthCheckResponse_SERVING {
+			return fmt.Errorf("%s 狀態為 %s", service, resp.GetStatus())
+		}
+		return nil
+	}
+}
+
+// HTTPUpstream 呼叫上游實例的 /readyz，resolve 每次挑一個實例的 base URL
+func HTTPUpstream(client *http.Client, resolve func() (string, error)) CheckFunc {
+	return func(ctx context.Context) error {
+		baseURL, err := resolve()
+		if err != nil {
+			return err
+		}
+		req, err := http.NewRequestWithContext(ctx, http.MethodGet, baseURL+"/readyz", nil)
+		if err != nil {
+			return err
+		}
+		resp, err := client.Do(req)
+		if err != nil {
+			return err
+		}
+		defer resp.Body.Close()
+		if resp.StatusCode != http.StatusOK {
+			return fmt.Errorf("%s/readyz 回傳 %d", baseURL, resp.StatusCode)
+		}
+		return nil
+	}
+}
@@ -0,0 +1,89 @@
+package health
+
+import (
+	"context"
+	"errors"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/redis/go-redis/v9"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials/insecure"
+	grpchealth "google.golang.org/grpc/health"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+)
+
+func TestRedis(t *testing.T) {
+	server := miniredis.RunT(t)
+	client := redis.NewClient(&redis.Options{Addr: server.Addr()})
+	defer client.Close()
+
+	assert.NoError(t, Redis(client)(context.Background()))
+
+	server.Close()
+	assert.Error(t, Redis(client)(context.Background()))
+}
+
+func TestHTTPUpstream(t *testing.T) {
+	upstream := func(code int) *httptest.Server {
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if r.URL.Path != "/readyz" {
+				w.WriteHeader(http.StatusNotFound)
+				return
+			}
+			w.WriteHeader(code)
+		}))
+		t.Cleanup(server.Close)
+		return server
+	}
+	ready := upstream(http.StatusOK)
+	draining := upstream(http.StatusServiceUnavailable)
+
+	tests := []struct {
+		name    string
+		resolve func() (string, error)
+		wantErr string
+	}{
+		{name: "ready", resolve: func() (string, error) { return ready.URL, nil }},
+		{name: "not ready", resolve: func() (string, error) { return draining.URL, nil }, wantErr: "回傳 503"},
+		{name: "no instance", resolve: func() (string, error) { return "", errors.New("沒有可用的實例") }, wantErr: "沒有可用的實例"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := HTTPUpstream(http.DefaultClient, tt.resolve)(context.Background())
+			if tt.wantErr == "" {
+				assert.NoError(t, err)
+				return
+			}
+			assert.ErrorContains(t, err, tt.wantErr)
+		})
+	}
+}
+
+func TestGRPCUpstream(t *testing.T) {
+	lis, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	healthServer := grpchealth.NewServer()
+	server := grpc.NewServer()
+	healthpb.RegisterHealthServer(server, healthServer)
+	go server.Serve(lis)
+	defer server.Stop()
+
+	conn, err := grpc.Dial(lis.Addr().String(), grpc.WithTransportCredentials(insecure.NewCredentials()))
+	require.NoError(t, err)
+	defer conn.Close()
+
+	check := GRPCUpstream(conn, "user.v1.UserService")
+
+	healthServer.SetServingStatus("user.v1.UserService", healthpb.HealthCheckResponse_SERVING)
+	assert.NoError(t, check(context.Background()))
+
+	// user-service 關閉時會把狀態改成 NOT_SERVING
+	healthServer.SetServingStatus("user.v1.UserService", healthpb.HealthCheckResponse_NOT_SERVING)
+	assert.ErrorContains(t, check(context.Background()), "NOT_SERVING")
+}
@@ -0,0 +1,212 @@
+package health
+
+import (
+	"context"
+	"net/http"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// 檢查結果的狀態
+const (
+	StatusUp   = "up"
+	StatusDown = "down"
+)
+
+// 預設值
+const (
+	DefaultTimeout  = 2 * time.Second
+	DefaultCacheTTL = 2 * time.Second
+)
+
+// CheckFunc 檢查一個相依服務，回傳 nil 代表正常；需遵守 ctx 的 deadline
+type CheckFunc func(ctx context.Context) error
+
+// CheckResult 單一檢查的結果
+type CheckResult struct {
+	Status    string    `json:"status"`
+	Error     string    `json:"error,omitempty"`
+	Duration  string    `json:"duration"`
+	CheckedAt time.Time `json:"checked_at"`
+}
+
+// Report 一次 readiness 檢查的彙整結果
+type Report struct {
+	Status string                 `json:"status"`
+	Checks map[string]CheckResult `json:"checks,omitempty"`
+}
+
+type check struct {
+	name    string
+	timeout time.Duration
+	fn      CheckFunc
+}
+
+// Health 管理 liveness 與 readiness 檢查
+//
+// liveness 只代表 process 還活著，不檢查任何相依服務，避免上游故障時 gateway 被不斷重啟；
+// readiness 則檢查 Redis 與各上游服務，任何一項失敗就不該接收流量。
+// 檢查結果會快取一小段時間，探針頻繁呼叫也不會對上游造成額外負擔
+type Health struct {
+	ready    func() bool
+	timeout  time.Duration
+	cacheTTL time.Duration
+	details  bool
+	now      func() time.Time
+
+	checks []check
+
+	mu        sync.Mutex
+	cached    Report
+	expiresAt time.Time
+}
+
+// Option 用來調整 Health 的選用設定
+type Option func(*Health)
+
+// WithTimeout 設定檢查的預設逾時，個別檢查可在 AddCheck 時另外指定
+func WithTimeout(d time.Duration) Option {
+	return func(h *Health) {
+		h.timeout = d
+	}
+}
+
+// WithCacheTTL 設定檢查結果快取多久，0 表示每次都重新檢查
+func WithCacheTTL(d time.Duration) Option {
+	return func(h *Health) {
+		h.cacheTTL = d
+	}
+}
+
+// WithDetails 讓 /readyz 回傳每一項檢查的結果與錯誤訊息
+// 錯誤訊息可能帶有內部主機名稱等資訊，只應在內部網路開啟
+func WithDetails(enabled bool) Option {
+	return func(h *Health) {
+		h.details = enabled
+	}
+}
+
+// WithClock 替換現在時間，測試時可注入固定時間
+func WithClock(now func() time.Time) Option {
+	return func(h *Health) {
+		h.now = now
+	}
+}
+
+// New 創建 Health，ready 回傳 false 時（例如服務正在關閉）readiness 直接失敗，不再檢查相依服務
+func New(ready func() bool, opts ...Option) *Health {
+	h := &Health{
+		ready:    ready,
+		timeout:  DefaultTimeout,
+		cacheTTL: DefaultCacheTTL,
+		now:      time.Now,
+	}
+	for _, opt := range opts {
+		opt(h)
+	}
+	return h
+}
+
+// AddCheck 加入一項 readiness 檢查，timeout <= 0 時使用預設逾時
+func (h *Health) AddCheck(name string, timeout time.Duration, fn CheckFunc) {
+	if timeout <= 0 {
+		timeout = h.timeout
+	}
+	h.checks = append(h.checks, check{name: name, timeout: timeout, fn: fn})
+}
+
+// Check 執行所有檢查（快取未過期時直接回傳上一次的結果）
+//
+// 各項檢查同時執行，總耗時取決於最慢的一項；同一時間只會有一輪檢查，
+// 其他呼叫者等這一輪結束後共用結果。結果會被快取，因此不受呼叫者 ctx 取消的影響，只受各項檢查的逾時限制
+func (h *Health) Check(ctx context.Context) Report {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	if h.now().Before(h.expiresAt) {
+		return h.cached
+	}
+	ctx = context.WithoutCancel(ctx)
+
+	results := make([]CheckResult, len(h.checks))
+	var wg sync.WaitGroup
+	for i, c := range h.checks {
+		wg.Add(1)
+		go func(i int, c check) {
+			defer wg.Done()
+			results[i] = h.run(ctx, c)
+		}(i, c)
+	}
+	wg.Wait()
+
+	report := Report{Status: StatusUp, Checks: make(map[string]CheckResult, len(h.checks))}
+	for i, c := range h.checks {
+		report.Checks[c.name] = results[i]
+		if results[i].Status != StatusUp {
+			report.Status = StatusDown
+		}
+	}
+
+	h.cached = report
+	h.expiresAt = h.now().Add(h.cacheTTL)
+	return report
+}
+
+func (h *Health) run(ctx context.Context, c check) CheckResult {
+	ctx, cancel := context.WithTimeout(ctx, c.timeout)
+	defer cancel()
+
+	start := h.now()
+	err := c.fn(ctx)
+	result := CheckResult{Status: StatusUp, Duration: h.now().Sub(start).String(), CheckedAt: start}
+	if err != nil {
+		result.Status = StatusDown
+		result.Error = err.Error()
+	}
+	return result
+}
+
+// Ready 回傳服務是否仍在接收流量（尚未開始關閉），不檢查相依服務
+func (h *Health) Ready() bool {
+	return h.ready == nil || h.ready()
+}
+
+// Livez liveness probe：process 能回應就是活著
+func (h *Health) Livez(c *gin.Context) {
+	c.JSON(http.StatusOK, gin.H{"status": StatusUp})
+}
+
+// Readyz readiness probe：正在關閉或任何一項檢查失敗時回 503
+// 未開啟 details 時只回整體狀態與失敗的檢查名稱
+func (h *Health) Readyz(c *gin.Context) {
+	if !h.Ready() {
+		c.JSON(http.StatusServiceUnavailable, gin.H{"status": "shutting down"})
+		return
+	}
+
+	report := h.Check(c.Request.Context())
+	code := http.StatusOK
+	if report.Status != StatusUp {
+		code = http.StatusServiceUnavailable
+	}
+
+	if h.details {
+		c.JSON(code, report)
+		return
+	}
+	resp := gin.H{"status": report.Status}
+	var failed []string
+	for name, result := range report.Checks {
+		if result.Status != StatusUp {
+			failed = append(failed, name)
+		}
+	}
+	if len(failed) > 0 {
+		sort.Strings(failed)
+		resp["failed"] = failed
+	}
+	c.JSON(code, resp)
+}
@@ -0,0 +1,155 @@
+package health
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"api-gateway/lifecycle"
+)
+
+// healthTestNow 測試用的固定時間
+var healthTestNow = time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+
+func setupHealthRouter(h *Health) *gin.Engine {
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	r.GET("/livez", h.Livez)
+	r.GET("/readyz", h.Readyz)
+	return r
+}
+
+func get(router http.Handler, path string) (*httptest.ResponseRecorder, map[string]interface{}) {
+	w := httptest.NewRecorder()
+	r, _ := http.NewRequest("GET", path, nil)
+	router.ServeHTTP(w, r)
+	var body map[string]interface{}
+	json.Unmarshal(w.Body.Bytes(), &body)
+	return w, body
+}
+
+func ok(context.Context) error { return nil }
+
+func TestLivez(t *testing.T) {
+	h := New(nil)
+	h.AddCheck("user-service", 0, func(context.Context) error { return errors.New("connection refused") })
+
+	// 上游故障不影響 gateway 的 liveness
+	w, body := get(setupHealthRouter(h), "/livez")
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, StatusUp, body["status"])
+}
+
+func TestReadyz(t *testing.T) {
+	t.Run("all checks pass", func(t *testing.T) {
+		h := New(nil)
+		h.AddCheck("redis", 0, ok)
+		h.AddCheck("user-service", 0, ok)
+
+		w, body := get(setupHealthRouter(h), "/readyz")
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.Equal(t, StatusUp, body["status"])
+		assert.NotContains(t, body, "checks")
+	})
+
+	t.Run("failed upstream returns 503 with its name only", func(t *testing.T) {
+		h := New(nil)
+		h.AddCheck("redis", 0, ok)
+		h.AddCheck("user-service", 0, func(context.Context) error { return errors.New("dial tcp user-service:9081: connection refused") })
+
+		w, body := get(setupHealthRouter(h), "/readyz")
+		assert.Equal(t, http.StatusServiceUnavailable, w.Code)
+		assert.Equal(t, StatusDown, body["status"])
+		assert.Equal(t, []interface{}{"user-service"}, body["failed"])
+		assert.NotContains(t, w.Body.String(), "user-service:9081")
+	})
+
+	t.Run("details include per-check results", func(t *testing.T) {
+		h := New(nil, WithDetails(true))
+		h.AddCheck("redis", 0, ok)
+		h.AddCheck("user-service", 0, func(context.Context) error { return errors.New("connection refused") })
+
+		w := httptest.NewRecorder()
+		r, _ := http.NewRequest("GET", "/readyz", nil)
+		setupHealthRouter(h).ServeHTTP(w, r)
+
+		var report Report
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), &report))
+		assert.Equal(t, StatusDown, report.Status)
+		assert.Equal(t, StatusUp, report.Checks["redis"].Status)
+		assert.Equal(t, "connection refused", report.Checks["user-service"].Error)
+	})
+
+	t.Run("draining flips readiness without running checks", func(t *testing.T) {
+		readiness := &lifecycle.Readiness{}
+		var calls atomic.Int32
+		h := New(readiness.Ready, WithCacheTTL(time.Hour))
+		h.AddCheck("redis", 0, func(context.Context) error {
+			calls.Add(1)
+			return nil
+		})
+		router := setupHealthRouter(h)
+
+		w, _ := get(router, "/readyz")
+		assert.Equal(t, http.StatusOK, w.Code)
+
+		// 快取的結果仍是 up，開始關閉後也要立即回 503
+		readiness.SetDraining()
+		w, body := get(router, "/readyz")
+		assert.Equal(t, http.StatusServiceUnavailable, w.Code)
+		assert.Equal(t, "shutting down", body["status"])
+		assert.False(t, h.Ready())
+		assert.Equal(t, int32(1), calls.Load())
+	})
+}
+
+func TestCheck(t *testing.T) {
+	t.Run("per-check timeout", func(t *testing.T) {
+		h := New(nil)
+		h.AddCheck("slow", 10*time.Millisecond, func(ctx context.Context) error {
+			<-ctx.Done()
+			return ctx.Err()
+		})
+
+		report := h.Check(context.Background())
+		assert.Equal(t, StatusDown, report.Status)
+		assert.Equal(t, context.DeadlineExceeded.Error(), report.Checks["slow"].Error)
+	})
+
+	t.Run("results are cached until ttl expires", func(t *testing.T) {
+		clock := healthTestNow
+		var calls atomic.Int32
+		h := New(nil, WithCacheTTL(5*time.Second), WithClock(func() time.Time { return clock }))
+		h.AddCheck("redis", 0, func(context.Context) error {
+			calls.Add(1)
+			return nil
+		})
+
+		h.Check(context.Background())
+		clock = healthTestNow.Add(4 * time.Second)
+		h.Check(context.Background())
+		assert.Equal(t, int32(1), calls.Load())
+
+		clock = healthTestNow.Add(5 * time.Second)
+		h.Check(context.Background())
+		assert.Equal(t, int32(2), calls.Load())
+	})
+
+	t.Run("cancelled caller does not poison the cache", func(t *testing.T) {
+		h := New(nil)
+		h.AddCheck("redis", 0, func(ctx context.Context) error { return ctx.Err() })
+
+		ctx, cancel := context.WithCancel(context.Background())
+		cancel()
+		assert.Equal(t, StatusUp, h.Check(ctx).Status)
+	})
+}
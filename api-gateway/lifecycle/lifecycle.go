@@ -0,0 +1,77 @@
+package lifecycle
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"sync/atomic"
+	"time"
+)
+
+// Readiness 標記 gateway 是否願意接收新流量
+//
+// 關閉流程一開始就切成 draining，/health 跟著回 503，
+// 讓前面的 load balancer 先停止送新請求，再等進行中的請求結束
+type Readiness struct {
+	draining atomic.Bool
+}
+
+// Ready 尚未開始關閉時回傳 true
+func (r *Readiness) Ready() bool {
+	return !r.draining.Load()
+}
+
+// SetDraining 開始關閉，之後 Ready 一律回傳 false
+func (r *Readiness) SetDraining() {
+	r.draining.Store(true)
+}
+
+// Shutdown 依註冊順序執行關閉步驟
+//
+// 所有步驟共用同一個 timeout；某一步失敗或逾時仍會繼續執行後面的步驟，
+// 確保 Redis 等連線最後一定會被關閉
+type Shutdown struct {
+	steps []step
+}
+
+type step struct {
+	name string
+	fn   func(ctx context.Context) error
+}
+
+// Add 加入一個關閉步驟
+func (s *Shutdown) Add(name string, fn func(ctx context.Context) error) {
+	s.steps = append(s.steps, step{name: name, fn: fn})
+}
+
+// Run 依序執行所有步驟，回傳各步驟的錯誤
+func (s *Shutdown) Run(timeout time.Duration) error {
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+
+	var errs []error
+	for _, st := range s.steps {
+		start := time.Now()
+		if err := st.fn(ctx); err != nil {
+			log.Printf("[Gateway] 關閉 %s 失敗 latency=%s err=%v", st.name, time.Since(start), err)
+			errs = append(errs, fmt.Errorf("%s: %w", st.name, err))
+			continue
+		}
+		log.Printf("[Gateway] 關閉 %s 完成 latency=%s", st.name, time.Since(start))
+	}
+	return errors.Join(errs...)
+}
+
+// Wait 等待 d 或 ctx 結束，用於關閉流程中的 drain 期間
+func Wait(ctx context.Context, d time.Duration) error {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+
+	select {
+	case <-timer.C:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
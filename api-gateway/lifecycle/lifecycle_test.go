@@ -0,0 +1,100 @@
+package lifecycle
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestReadiness(t *testing.T) {
+	var r Readiness
+	assert.True(t, r.Ready())
+
+	r.SetDraining()
+	assert.False(t, r.Ready())
+}
+
+func TestShutdown(t *testing.T) {
+	t.Run("runs steps in order and continues after failure", func(t *testing.T) {
+		var order []string
+		var s Shutdown
+		for _, name := range []string{"readiness", "drain", "http server", "metrics server", "registry refresh", "grpc conn", "redis"} {
+			name := name
+			s.Add(name, func(context.Context) error {
+				order = append(order, name)
+				if name == "grpc conn" {
+					return errors.New("boom")
+				}
+				return nil
+			})
+		}
+
+		err := s.Run(time.Second)
+
+		assert.Equal(t, []string{"readiness", "drain", "http server", "metrics server", "registry refresh", "grpc conn", "redis"}, order)
+		assert.ErrorContains(t, err, "grpc conn: boom")
+	})
+
+	t.Run("steps share one deadline", func(t *testing.T) {
+		var s Shutdown
+		s.Add("drain", func(ctx context.Context) error {
+			return Wait(ctx, time.Hour)
+		})
+		var remaining error
+		s.Add("redis", func(ctx context.Context) error {
+			remaining = ctx.Err()
+			return nil
+		})
+
+		err := s.Run(10 * time.Millisecond)
+
+		assert.ErrorIs(t, err, context.DeadlineExceeded)
+		// 前一步耗盡時間後，後面的步驟仍會執行，只是拿到已逾時的 ctx
+		assert.ErrorIs(t, remaining, context.DeadlineExceeded)
+	})
+
+	t.Run("keeps serving during drain while reporting not ready", func(t *testing.T) {
+		readiness := &Readiness{}
+		server := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if r.URL.Path == "/health" && !readiness.Ready() {
+				w.WriteHeader(http.StatusServiceUnavailable)
+				return
+			}
+			w.WriteHeader(http.StatusOK)
+		}))
+		server.Start()
+		defer server.Close()
+
+		status := func(path string) int {
+			resp, err := http.Get(server.URL + path)
+			require.NoError(t, err)
+			resp.Body.Close()
+			return resp.StatusCode
+		}
+
+		var duringDrain []int
+		var s Shutdown
+		s.Add("readiness", func(context.Context) error {
+			readiness.SetDraining()
+			return nil
+		})
+		s.Add("drain", func(ctx context.Context) error {
+			// drain 期間 /health 已回 503，但一般請求仍照常處理
+			duringDrain = append(duringDrain, status("/health"), status("/api/users"))
+			return Wait(ctx, 10*time.Millisecond)
+		})
+		s.Add("http server", server.Config.Shutdown)
+
+		require.NoError(t, s.Run(time.Second))
+
+		assert.Equal(t, []int{http.StatusServiceUnavailable, http.StatusOK}, duringDrain)
+		_, err := http.Get(server.URL + "/api/users")
+		assert.Error(t, err, "server must stop accepting connections after shutdown")
+	})
+}
@@ -1,25 +1,177 @@
 package main
 
 import (
+	"context"
+	"errors"
+	"fmt"
 	"log"
+	"net/http"
+	"os"
+	"os/signal"
+	"syscall"
 
 	"github.com/gin-gonic/gin"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+	"github.com/redis/go-redis/v9"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/backoff"
+	"google.golang.org/grpc/credentials/insecure"
 
+	"api-gateway/cache"
 	"api-gateway/config"
+	"api-gateway/discovery"
+	"api-gateway/gen/userv1"
+	"api-gateway/health"
+	"api-gateway/lifecycle"
+	"api-gateway/middleware"
+	"api-gateway/proxy"
 	"api-gateway/routes"
+	"api-gateway/usergrpc"
 )
 
 func main() {
-	// 讀取設定（port、下游服務 URL）
-	cfg := config.Load()
+	// 讀取設定：預設值 → CONFIG_FILE → 環境變數，設定有誤時直接拒絕啟動
+	cfg, err := config.Load()
+	if err != nil {
+		log.Fatal(err)
+	}
+	for _, line := range cfg.Redacted() {
+		log.Printf("[Gateway] 設定 %s", line)
+	}
+
+	// Redis 存放 user-service 寫入的 token 撤銷紀錄，供 RequireAuth 查詢
+	redisClient := redis.NewClient(&redis.Options{
+		Addr: fmt.Sprintf("%s:%s", cfg.RedisHost, cfg.RedisPort),
+	})
 
+	// user-service 的實例依序從 Redis registry、靜態檔案、環境變數取得
+	sources := discovery.FallbackSource{discovery.NewRedisSource(redisClient)}
+	if cfg.RegistryFile != "" {
+		sources = append(sources, discovery.NewFileSource(cfg.RegistryFile))
+	}
+	sources = append(sources, discovery.StaticSource{
+		cfg.UserServiceName: {{
+			ID:       "static",
+			Service:  cfg.UserServiceName,
+			HTTPAddr: cfg.UserServiceURL,
+			GRPCAddr: cfg.UserServiceGRPCAddr,
+		}},
+	})
+	userPool := discovery.NewPool(cfg.UserServiceName, sources, cfg.RegistryRefresh)
+	if err := userPool.Refresh(context.Background()); err != nil {
+		log.Printf("[Gateway] 查詢 %s 實例失敗：%v", cfg.UserServiceName, err)
+	}
+	refreshCtx, stopRefresh := context.WithCancel(context.Background())
+	defer stopRefresh()
+	go userPool.Run(refreshCtx)
+
+	// /api/users 路由預設以 gRPC 呼叫 user-service；連線是 lazy 建立的，user-service 尚未啟動也不影響 gateway 啟動
+	// 位址由 registry resolver 提供，實例增減時 gRPC client 會自動調整連線並以 round-robin 分散請求
+	// 連線與進行中請求的指標，HTTP 轉發與 gRPC 呼叫共用
+	metrics := proxy.NewMetrics(prometheus.DefaultRegisterer)
+	var users *usergrpc.Translator
+	var conn *grpc.ClientConn
+	if cfg.UserServiceTransport == config.TransportGRPC {
+		dialOpts := []grpc.DialOption{
+			grpc.WithResolvers(discovery.NewResolverBuilder(userPool)),
+			grpc.WithDefaultServiceConfig(discovery.RoundRobinServiceConfig),
+			grpc.WithTransportCredentials(insecure.NewCredentials()),
+			grpc.WithConnectParams(grpc.ConnectParams{
+				Backoff:           backoff.DefaultConfig,
+				MinConnectTimeout: cfg.UserServiceConnectTimeout,
+			}),
+			grpc.WithUnaryInterceptor(proxy.NewSigner(cfg.InternalSecret).UnaryClientInterceptor()),
+		}
+		// 建立連線的逾時與 keep-alive 沿用 HTTP 轉發的設定，連線池的設定只對 HTTP 有效
+		dialOpts = append(dialOpts, proxy.GRPCDialOptions(proxy.TransportConfig{
+			DialTimeout: cfg.UserServiceConnectTimeout,
+			KeepAlive:   cfg.UserServiceKeepAlive,
+		}, cfg.UserServiceName, metrics)...)
+		conn, err = grpc.Dial(discovery.Target(userPool), dialOpts...)
+		if err != nil {
+			log.Fatal("建立 user-service gRPC 連線失敗：", err)
+		}
+		users = usergrpc.New(conn, usergrpc.WithTimeout(cfg.UserServiceTimeout))
+	}
+
+	readiness := &lifecycle.Readiness{}
 	// 使用 gin.New() 而非 gin.Default()，
 	// 因為 Recovery 與 Logger 已在 routes.Setup 中手動掛載，避免重複。
 	router := gin.New()
-	routes.Setup(router, cfg)
+	probes := health.New(readiness.Ready,
+		health.WithTimeout(cfg.HealthCheckTimeout),
+		health.WithCacheTTL(cfg.HealthCacheTTL),
+		health.WithDetails(cfg.HealthExposeDetails),
+	)
+	probes.AddCheck("redis", 0, health.Redis(redisClient))
+	// 走哪個協定呼叫 user-service，就用同一個協定檢查它
+	if conn != nil {
+		probes.AddCheck(cfg.UserServiceName, 0, health.GRPCUpstream(conn, userv1.UserService_ServiceDesc.ServiceName))
+	} else {
+		probes.AddCheck(cfg.UserServiceName, 0, health.HTTPUpstream(http.DefaultClient, userPool.NextHTTPAddr))
+	}
+	var responses cache.Store = cache.NewMemoryStore(cfg.CacheMemoryMaxBytes)
+	if cfg.CacheBackend == config.CacheBackendRedis {
+		responses = cache.NewRedisStore(redisClient)
+	}
+	routes.Setup(router, cfg, middleware.NewRedisRevocationChecker(redisClient), userPool, users, probes, metrics, responses)
+
+	// 用 http.Server 而不是 router.Run，收到 SIGTERM 時才能等進行中的請求結束
+	server := &http.Server{Addr: ":" + cfg.Port, Handler: router}
+	go func() {
+		log.Printf("API Gateway 啟動，監聽 port %s", cfg.Port)
+		if err := server.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			log.Fatal("API Gateway 啟動失敗：", err)
+		}
+	}()
+
+	// Prometheus 指標只在內部的 port 提供，不經過對外的 router
+	var metricsServer *http.Server
+	if cfg.MetricsPort != "" {
+		mux := http.NewServeMux()
+		mux.Handle("/metrics", promhttp.Handler())
+		metricsServer = &http.Server{Addr: ":" + cfg.MetricsPort, Handler: mux}
+		go func() {
+			log.Printf("[Gateway] 指標監聽 port %s", cfg.MetricsPort)
+			if err := metricsServer.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+				log.Fatal("指標 server 啟動失敗：", err)
+			}
+		}()
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	<-ctx.Done()
+	stop() // 關閉途中再收到一次 SIGINT / SIGTERM 就直接結束
+	log.Println("[Gateway] 收到關閉訊號，開始關閉")
+
+	// 關閉順序：/health 先回 503 → 等待 drain 期間 → 停止接受新連線並等進行中的請求結束
+	// → 關閉指標 server → 停止刷新上游清單 → 關閉 gRPC 連線 → 關閉 Redis
+	var shutdown lifecycle.Shutdown
+	shutdown.Add("readiness", func(context.Context) error {
+		readiness.SetDraining()
+		return nil
+	})
+	shutdown.Add("drain", func(ctx context.Context) error {
+		return lifecycle.Wait(ctx, cfg.ShutdownDrainPeriod)
+	})
+	shutdown.Add("http server", server.Shutdown)
+	if metricsServer != nil {
+		shutdown.Add("metrics server", metricsServer.Shutdown)
+	}
+	shutdown.Add("registry refresh", func(context.Context) error {
+		stopRefresh()
+		return nil
+	})
+	if conn != nil {
+		shutdown.Add("user-service gRPC 連線", func(context.Context) error { return conn.Close() })
+	}
+	shutdown.Add("redis", func(context.Context) error { return redisClient.Close() })
 
-	log.Printf("API Gateway 啟動，監聽 port %s", cfg.Port)
-	if err := router.Run(":" + cfg.Port); err != nil {
-		log.Fatal("API Gateway 啟動失敗：", err)
+	if err := shutdown.Run(cfg.ShutdownDrainPeriod + cfg.ShutdownTimeout); err != nil {
+		log.Printf("[Gateway] 關閉時發生錯誤：%v", err)
+		os.Exit(1)
 	}
+	log.Println("[Gateway] 已關閉")
 }
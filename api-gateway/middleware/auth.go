@@ -2,6 +2,7 @@ package middleware
 
 import (
 	"fmt"
+	"log"
 	"net/http"
 	"strings"
 
@@ -11,8 +12,10 @@ import (
 
 // Claims 定義從 JWT payload 中讀取的欄位，需與 user-service 簽發時的結構相同
 type Claims struct {
-	UserID string `json:"user_id"`
-	Email  string `json:"email"`
+	UserID    string `json:"user_id"`
+	Email     string `json:"email"`
+	Role      string `json:"role"`
+	SessionID string `json:"sid,omitempty"`
 	jwt.RegisteredClaims
 }
 
@@ -23,8 +26,12 @@ type Claims struct {
 //  2. 解析 token，確認簽章演算法為 HS256
 //  3. 用 JWT_SECRET 驗證簽章是否正確
 //  4. 確認 token 尚未過期（jwt 套件自動處理）
-//  5. 將 user_id 存入 gin.Context，讓後續 handler 可以使用
-func RequireAuth(jwtSecret string) gin.HandlerFunc {
+//  5. 確認 token 沒有被撤銷（例如用戶重設了密碼，或在其他裝置登出了這個 session）
+//  6. 將 user_id、email、role 存入 gin.Context，讓後續 handler 可以使用
+//  7. 將身份寫入 X-User-* header，轉發後下游服務不必再解析 token
+//
+// revocations 為 nil 時略過撤銷檢查
+func RequireAuth(jwtSecret string, revocations RevocationChecker) gin.HandlerFunc {
 	return func(c *gin.Context) {
 		// ── 1. 取出 header ─────────────────────────────────────────────────
 		authHeader := c.GetHeader("Authorization")
@@ -61,9 +68,44 @@ func RequireAuth(jwtSecret string) gin.HandlerFunc {
 			return
 		}
 
-		// ── 4. 將 user_id 存入 context，後續 handler 可透過 c.GetString("user_id") 取得
+		// ── 4. 檢查撤銷狀態 ────────────────────────────────────────────────
+		// 查不到撤銷狀態時拒絕請求（fail closed），避免已撤銷的 token 趁 Redis 故障時通過
+		if revocations != nil {
+			revoked, err := revocations.IsRevoked(c.Request.Context(), claims)
+			if err != nil {
+				log.Printf("[Gateway] 檢查 token 撤銷狀態失敗：%v", err)
+				c.AbortWithStatusJSON(http.StatusServiceUnavailable, gin.H{
+					"error": "暫時無法驗證 token，請稍後再試",
+				})
+				return
+			}
+			if revoked {
+				c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{
+					"error": "token 已被撤銷，請重新登入",
+				})
+				return
+			}
+		}
+
+		// ── 5. 將 user_id、email、role 存入 context，後續 handler 可透過 c.GetString("user_id") 取得
 		c.Set("user_id", claims.UserID)
 		c.Set("email", claims.Email)
+		c.Set("role", claims.Role)
+		setIdentityHeaders(c, claims)
+
+		c.Next()
+	}
+}
+
+// RequireRole 限制只有特定角色可以存取，必須掛在 RequireAuth 之後
+func RequireRole(role string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if c.GetString("role") != role {
+			c.AbortWithStatusJSON(http.StatusForbidden, gin.H{
+				"error": "權限不足",
+			})
+			return
+		}
 
 		c.Next()
 	}
@@ -0,0 +1,157 @@
+package middleware
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"sort"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+// BodyLimit 請求 body 的限制
+type BodyLimit struct {
+	MaxBytes     int64    // 超過時回 413
+	ContentTypes []string // 允許的 media type（不含參數，例如 application/json），空的表示不限制；不符時回 415
+	MaxJSONDepth int      // JSON body 的最大巢狀層數，0 表示不檢查；超過或格式錯誤時回 400
+}
+
+// BodyRule 讓某個路徑前綴套用不同的 body 限制
+type BodyRule struct {
+	PathPrefix string
+	Limit      BodyLimit
+}
+
+// errJSONTooDeep JSON 巢狀層數超過上限
+var errJSONTooDeep = errors.New("json nested too deep")
+
+// BodyLimits 依請求路徑挑選 body 限制（最長的 PathPrefix 優先，都不符合時套用 global），
+// 在轉發之前檢查大小、Content-Type 與 JSON 結構。
+//
+// body 最多只讀到上限多一個 byte，讀完後放回 c.Request.Body，後面的 proxy 與 gRPC translator 照常讀取；
+// 沒有 body 的請求（例如 GET）不檢查。
+func BodyLimits(global BodyLimit, rules ...BodyRule) gin.HandlerFunc {
+	sorted := make([]BodyRule, len(rules))
+	for i, rule := range rules {
+		sorted[i] = BodyRule{PathPrefix: strings.TrimSuffix(rule.PathPrefix, "/"), Limit: rule.Limit}
+	}
+	sort.SliceStable(sorted, func(i, j int) bool {
+		return len(sorted[i].PathPrefix) > len(sorted[j].PathPrefix)
+	})
+
+	return func(c *gin.Context) {
+		if c.Request.Body == nil || c.Request.Body == http.NoBody || c.Request.ContentLength == 0 {
+			c.Next()
+			return
+		}
+
+		limit := global
+		for _, rule := range sorted {
+			if hasPathPrefix(c.Request.URL.Path, rule.PathPrefix) {
+				limit = rule.Limit
+				break
+			}
+		}
+
+		// ── 1. Content-Type ──────────────────────────────────────────────────
+		mediaType, _, err := mime.ParseMediaType(c.GetHeader("Content-Type"))
+		if err != nil {
+			mediaType = ""
+		}
+		if len(limit.ContentTypes) > 0 && !containsFold(limit.ContentTypes, mediaType) {
+			c.AbortWithStatusJSON(http.StatusUnsupportedMediaType, gin.H{
+				"error": fmt.Sprintf("不支援的 Content-Type：%q，允許 %s", mediaType, strings.Join(limit.ContentTypes, ", ")),
+			})
+			return
+		}
+
+		// ── 2. 大小：Content-Length 已經超過就不必讀 ──────────────────────────
+		if c.Request.ContentLength > limit.MaxBytes {
+			tooLarge(c, limit.MaxBytes)
+			return
+		}
+		body, err := io.ReadAll(io.LimitReader(c.Request.Body, limit.MaxBytes+1))
+		if err != nil {
+			c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": "讀取請求內容失敗"})
+			return
+		}
+		if int64(len(body)) > limit.MaxBytes {
+			tooLarge(c, limit.MaxBytes)
+			return
+		}
+
+		// ── 3. JSON 結構 ─────────────────────────────────────────────────────
+		if limit.MaxJSONDepth > 0 && isJSON(mediaType) {
+			if err := checkJSONDepth(body, limit.MaxJSONDepth); err != nil {
+				message := "JSON 格式錯誤"
+				if errors.Is(err, errJSONTooDeep) {
+					message = fmt.Sprintf("JSON 巢狀層數超過上限 %d", limit.MaxJSONDepth)
+				}
+				c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": message})
+				return
+			}
+		}
+
+		c.Request.Body = io.NopCloser(bytes.NewReader(body))
+		c.Request.ContentLength = int64(len(body))
+		c.Next()
+	}
+}
+
+func tooLarge(c *gin.Context, max int64) {
+	c.AbortWithStatusJSON(http.StatusRequestEntityTooLarge, gin.H{
+		"error": fmt.Sprintf("請求內容過大，上限為 %d bytes", max),
+	})
+}
+
+// isJSON application/json 與 application/*+json（例如 application/merge-patch+json）都視為 JSON
+func isJSON(mediaType string) bool {
+	return mediaType == "application/json" ||
+		(strings.HasPrefix(mediaType, "application/") && strings.HasSuffix(mediaType, "+json"))
+}
+
+func containsFold(list []string, value string) bool {
+	for _, item := range list {
+		if strings.EqualFold(item, value) {
+			return true
+		}
+	}
+	return false
+}
+
+// checkJSONDepth 以 token 逐一讀過 body，確認是單一個合法的 JSON 值且巢狀層數不超過 max；
+// 不建立任何物件，深度超過時立刻停止，惡意的深層 JSON 不會讓下游的 parser 耗盡 stack
+func checkJSONDepth(body []byte, max int) error {
+	dec := json.NewDecoder(bytes.NewReader(body))
+	depth := 0
+	for {
+		tok, err := dec.Token()
+		if errors.Is(err, io.EOF) {
+			if depth != 0 {
+				return io.ErrUnexpectedEOF
+			}
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		switch tok {
+		case json.Delim('{'), json.Delim('['):
+			depth++
+			if depth > max {
+				return errJSONTooDeep
+			}
+		case json.Delim('}'), json.Delim(']'):
+			depth--
+		}
+		// 第一個值結束後不可再有其他內容
+		if depth == 0 && dec.More() {
+			return errors.New("unexpected data after top-level value")
+		}
+	}
+}
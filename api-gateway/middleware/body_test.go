@@ -0,0 +1,84 @@
+package middleware
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+
+	"api-gateway/config"
+)
+
+// setupBodyRouter 掛上 BodyLimits，handler 回傳轉發時讀到的 body
+func setupBodyRouter(global BodyLimit, rules ...BodyRule) *gin.Engine {
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	r.Use(BodyLimits(global, rules...))
+	r.Any("/*path", func(c *gin.Context) {
+		body, _ := io.ReadAll(c.Request.Body)
+		c.String(http.StatusOK, string(body))
+	})
+	return r
+}
+
+func TestBodyLimits(t *testing.T) {
+	defaults := config.Defaults()
+	global := BodyLimit{
+		MaxBytes:     64,
+		ContentTypes: defaults.AllowedContentTypes,
+		MaxJSONDepth: 3,
+	}
+	login := BodyRule{PathPrefix: "/api/users/login", Limit: BodyLimit{MaxBytes: 16, ContentTypes: []string{"application/json"}}}
+
+	tests := []struct {
+		name        string
+		method      string
+		path        string
+		contentType string
+		body        string
+		wantCode    int
+	}{
+		{name: "json", method: "POST", path: "/api/users/register", contentType: "application/json", body: `{"a":1}`, wantCode: http.StatusOK},
+		{name: "json with charset", method: "POST", path: "/api/users/register", contentType: "application/json; charset=utf-8", body: `{"a":1}`, wantCode: http.StatusOK},
+		{name: "merge patch", method: "PATCH", path: "/api/users/me", contentType: "application/merge-patch+json", body: `{"bio":null}`, wantCode: http.StatusOK},
+		{name: "merge patch too deep", method: "PATCH", path: "/api/users/me", contentType: "application/merge-patch+json", body: `{"a":{"b":{"c":{}}}}`, wantCode: http.StatusBadRequest},
+		{name: "unsupported content type", method: "POST", path: "/api/users/register", contentType: "text/plain", body: `hello`, wantCode: http.StatusUnsupportedMediaType},
+		{name: "missing content type", method: "POST", path: "/api/users/register", body: `{"a":1}`, wantCode: http.StatusUnsupportedMediaType},
+		{name: "too large", method: "POST", path: "/api/users/register", contentType: "application/json", body: `{"a":"` + strings.Repeat("x", 64) + `"}`, wantCode: http.StatusRequestEntityTooLarge},
+		{name: "malformed json", method: "POST", path: "/api/users/register", contentType: "application/json", body: `{"a":`, wantCode: http.StatusBadRequest},
+		{name: "trailing data", method: "POST", path: "/api/users/register", contentType: "application/json", body: `{}{}`, wantCode: http.StatusBadRequest},
+		{name: "route rule is stricter", method: "POST", path: "/api/users/login", contentType: "application/json", body: `{"email":"a@b.c"}`, wantCode: http.StatusRequestEntityTooLarge},
+		{name: "route rule only matches whole segments", method: "POST", path: "/api/users/loginx", contentType: "application/json", body: `{"email":"a@b.c"}`, wantCode: http.StatusOK},
+		{name: "route rule content types", method: "POST", path: "/api/users/login", contentType: "application/merge-patch+json", body: `{}`, wantCode: http.StatusUnsupportedMediaType},
+	}
+
+	router := setupBodyRouter(global, login)
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			r, _ := http.NewRequest(tt.method, tt.path, strings.NewReader(tt.body))
+			if tt.contentType != "" {
+				r.Header.Set("Content-Type", tt.contentType)
+			}
+			router.ServeHTTP(w, r)
+
+			assert.Equal(t, tt.wantCode, w.Code, w.Body.String())
+			if tt.wantCode == http.StatusOK {
+				// 檢查過的 body 要原封不動交給後面的 handler
+				assert.Equal(t, tt.body, w.Body.String())
+			}
+		})
+	}
+
+	t.Run("requests without body are not checked", func(t *testing.T) {
+		w := httptest.NewRecorder()
+		r, _ := http.NewRequest("GET", "/api/users/me", http.NoBody)
+		router.ServeHTTP(w, r)
+
+		assert.Equal(t, http.StatusOK, w.Code)
+	})
+}
@@ -0,0 +1,504 @@
+package middleware
+
+import (
+	"context"
+	"log"
+	"net/http"
+	"net/textproto"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"api-gateway/cache"
+
+	"github.com/gin-gonic/gin"
+)
+
+// CacheRule 針對某個路徑前綴開啟 GET response 快取，沒有符合任何規則的路徑不快取
+type CacheRule struct {
+	PathPrefix string
+	TTL        time.Duration // 上游沒有指定 s-maxage、max-age 或 Expires 時的新鮮期間，0 表示只依上游的指示
+	KeyByUser  bool          // 依登入的用戶分開快取，response 因人而異時必須開啟；沒有登入的請求不快取
+}
+
+// CacheHeader 標示 response 是否來自快取：HIT、MISS、REVALIDATED（向上游確認沒有變更）或 BYPASS
+const CacheHeader = "X-Cache"
+
+const (
+	cacheHit         = "HIT"
+	cacheMiss        = "MISS"
+	cacheRevalidated = "REVALIDATED"
+	cacheBypass      = "BYPASS"
+)
+
+// staleRetention 過期但帶有 ETag 的 response 再保留多久：期間內以 If-None-Match 向上游重新驗證，
+// 沒有變更時上游只需回 304，不必重新產生內容
+const staleRetention = 10 * time.Minute
+
+// uncachedHeaders 每個 response 各自產生，不可從快取重播的 header；帶有 Set-Cookie 的 response 一律不存
+var uncachedHeaders = []string{"Date", "Age", "Content-Length", RequestIDHeader, CacheHeader}
+
+// Cache 依請求路徑挑選快取規則（最長的 PathPrefix 優先），快取上游回應的 GET response。
+//
+// 遵守 Cache-Control（no-store、no-cache、private、max-age、s-maxage）與 Vary；
+// 過期的 response 帶有 ETag 時以 If-None-Match 向上游重新驗證，客戶端的 If-None-Match 符合時直接回 304。
+// 同一路徑的 POST、PUT、PATCH、DELETE 成功後會清除該路徑所在集合（含子路徑）的快取。
+//
+// 必須掛在 RequireAuth 之後（KeyByUser 需要驗證過的用戶 ID，快取的內容也不能略過驗證），
+// 並在 Compress 之內，快取中存的是壓縮前的 response。
+func Cache(store cache.Store, maxEntryBytes int, rules ...CacheRule) gin.HandlerFunc {
+	sorted := make([]CacheRule, len(rules))
+	for i, rule := range rules {
+		sorted[i] = rule
+		sorted[i].PathPrefix = strings.TrimSuffix(rule.PathPrefix, "/")
+	}
+	sort.SliceStable(sorted, func(i, j int) bool {
+		return len(sorted[i].PathPrefix) > len(sorted[j].PathPrefix)
+	})
+
+	return func(c *gin.Context) {
+		var rule *CacheRule
+		for i := range sorted {
+			if hasPathPrefix(c.Request.URL.Path, sorted[i].PathPrefix) {
+				rule = &sorted[i]
+				break
+			}
+		}
+		if rule == nil {
+			c.Next()
+			return
+		}
+
+		switch c.Request.Method {
+		case http.MethodGet:
+			serveCached(c, store, maxEntryBytes, *rule)
+		case http.MethodPost, http.MethodPut, http.MethodPatch, http.MethodDelete:
+			// 修改成功後清除快取，否則要等到過期才看得到新的內容（RFC 9111 4.4）
+			c.Next()
+			purgeOnSuccess(c, store, purgePrefix(c.Request.URL.EscapedPath()))
+		default:
+			c.Next()
+		}
+	}
+}
+
+// PurgeCache 請求成功後清除 pathPrefix 底下的快取，給會改變其他路徑內容的路由使用，
+// 例如確認新 email 的連結不帶用戶 ID、管理員還原用戶走的是 /api/admin，Cache 依請求路徑推不出要清除哪裡
+func PurgeCache(store cache.Store, pathPrefix string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		c.Next()
+		purgeOnSuccess(c, store, pathPrefix)
+	}
+}
+
+// purgeOnSuccess response 為 2xx 或 3xx 時清除 prefix 底下的快取；請求已經結束，清除不受 client 斷線影響
+func purgeOnSuccess(c *gin.Context, store cache.Store, prefix string) {
+	if status := c.Writer.Status(); status < 200 || status >= 400 {
+		return
+	}
+	if _, err := store.Purge(context.WithoutCancel(c.Request.Context()), prefix); err != nil {
+		log.Printf("[Gateway] 清除快取失敗 path_prefix=%s err=%v", prefix, err)
+	}
+}
+
+// purgePrefix 修改 path 之後要清除的路徑前綴：path 所在的上層集合（含 path 本身與子路徑），
+// 例如 DELETE /api/users/me/sessions/1 會讓 /api/users/me/sessions 的列表也跟著改變。
+// 上層已經是根目錄時只清除 path，不清除整個快取
+func purgePrefix(p string) string {
+	p = strings.TrimSuffix(p, "/")
+	if parent := path.Dir(p); parent != "/" && parent != "." {
+		return parent
+	}
+	return p
+}
+
+// serveCached 處理符合快取規則的 GET 請求
+func serveCached(c *gin.Context, store cache.Store, maxEntryBytes int, rule CacheRule) {
+	ctx := c.Request.Context()
+	now := time.Now()
+	reqCC := requestCacheControl(c.Request.Header)
+	if _, ok := reqCC["no-store"]; ok {
+		c.Header(CacheHeader, cacheBypass)
+		c.Next()
+		return
+	}
+	user := ""
+	if rule.KeyByUser {
+		if user = c.Request.Header.Get(HeaderUserID); user == "" {
+			c.Header(CacheHeader, cacheBypass)
+			c.Next()
+			return
+		}
+	}
+	base := cache.Key(c.Request.URL.EscapedPath(), c.Request.URL.Query().Encode(), user)
+
+	entry, err := lookup(ctx, store, base, c.Request.Header)
+	if err != nil {
+		// 快取無法使用時照常轉發，只是少了快取的效果
+		log.Printf("[Gateway] 讀取快取失敗 path=%s err=%v", c.Request.URL.Path, err)
+	}
+	if entry != nil && usable(entry, reqCC, now) {
+		writeEntry(c, entry, now, cacheHit)
+		c.Abort()
+		return
+	}
+	if _, ok := reqCC["only-if-cached"]; ok {
+		c.AbortWithStatusJSON(http.StatusGatewayTimeout, gin.H{"error": "快取中沒有可用的回應"})
+		return
+	}
+
+	// 過期但帶有 ETag 時向上游確認是否變更；客戶端自己帶了 If-None-Match 時以客戶端的條件為準
+	revalidate := ""
+	if entry != nil && c.Request.Header.Get("If-None-Match") == "" {
+		if revalidate = entry.Header.Get("ETag"); revalidate != "" {
+			c.Request.Header.Set("If-None-Match", revalidate)
+		}
+	}
+
+	c.Header(CacheHeader, cacheMiss)
+	before := headerCounts(c.Writer.Header())
+	w := &cacheWriter{ResponseWriter: c.Writer, limit: maxEntryBytes}
+	c.Writer = w
+	c.Next()
+	c.Writer = w.ResponseWriter
+	if revalidate != "" {
+		c.Request.Header.Del("If-None-Match")
+	}
+	if w.passthrough {
+		return // 太大，已經直接送出
+	}
+
+	header := addedHeader(c.Writer.Header(), before)
+	status := w.Status()
+	switch {
+	case revalidate != "" && status == http.StatusNotModified:
+		// 內容沒有變更：沿用快取的 body，以上游這次的 header 更新新鮮期間
+		refreshed := entry.Clone()
+		for key, values := range header {
+			refreshed.Header[key] = values
+		}
+		refreshed.StoredAt = now
+		if lifetime, ok := freshness(refreshed.Header, c.Request.Header, rule, now); ok {
+			refreshed.Expires = now.Add(lifetime)
+			save(ctx, store, base, c.Request.Header, refreshed, lifetime)
+		}
+		restoreHeader(c.Writer.Header(), before)
+		writeEntry(c, refreshed, now, cacheRevalidated)
+		return
+	case status == http.StatusOK:
+		if lifetime, ok := freshness(header, c.Request.Header, rule, now); ok {
+			save(ctx, store, base, c.Request.Header, &cache.Entry{
+				Status:   status,
+				Header:   header,
+				Body:     w.buf,
+				StoredAt: now,
+				Expires:  now.Add(lifetime),
+			}, lifetime)
+		}
+		if etagMatches(c.GetHeader("If-None-Match"), header.Get("ETag")) {
+			c.Writer.Header().Del("Content-Length")
+			c.Writer.WriteHeader(http.StatusNotModified)
+			c.Writer.WriteHeaderNow()
+			return
+		}
+	}
+	w.writeBuffered()
+}
+
+// lookup 取出 base 對應的 response，Vary 索引會再依請求的 header 取出對應的版本
+func lookup(ctx context.Context, store cache.Store, base string, reqHeader http.Header) (*cache.Entry, error) {
+	entry, err := store.Get(ctx, base)
+	if err != nil || entry == nil || len(entry.Vary) == 0 {
+		return entry, err
+	}
+	return store.Get(ctx, cache.VariantKey(base, entry.Vary, reqHeader))
+}
+
+// save 存入 response；response 帶有 Vary 時另外存一筆索引，記錄要依哪些請求 header 區分版本
+func save(ctx context.Context, store cache.Store, base string, reqHeader http.Header, entry *cache.Entry, lifetime time.Duration) {
+	retention := lifetime
+	if entry.Header.Get("ETag") != "" {
+		retention += staleRetention
+	}
+	key := base
+	if vary := varyFields(entry.Header); len(vary) > 0 {
+		index := &cache.Entry{StoredAt: entry.StoredAt, Expires: entry.Expires, Vary: vary}
+		if err := store.Set(ctx, base, index, retention); err != nil {
+			log.Printf("[Gateway] 寫入快取失敗 key=%s err=%v", base, err)
+			return
+		}
+		key = cache.VariantKey(base, vary, reqHeader)
+	}
+	if err := store.Set(ctx, key, entry, retention); err != nil {
+		log.Printf("[Gateway] 寫入快取失敗 key=%s err=%v", key, err)
+	}
+}
+
+// usable 快取中的 response 是否可以直接回給這個請求
+func usable(entry *cache.Entry, reqCC map[string]string, now time.Time) bool {
+	if _, ok := reqCC["no-cache"]; ok {
+		return false
+	}
+	if v, ok := reqCC["max-age"]; ok && entry.Age(now) > seconds(v) {
+		return false
+	}
+	return entry.Fresh(now)
+}
+
+// freshness 回傳 response 可以直接使用多久，ok 為 false 表示不可存入快取（RFC 9111 3、4.2.1）
+func freshness(h, reqHeader http.Header, rule CacheRule, now time.Time) (lifetime time.Duration, ok bool) {
+	cc := parseCacheControl(h.Values("Cache-Control"))
+	_, noStore := cc["no-store"]
+	_, private := cc["private"]
+	if noStore || (private && !rule.KeyByUser) || h.Get("Set-Cookie") != "" {
+		return 0, false
+	}
+	for _, field := range varyFields(h) {
+		if field == "*" {
+			return 0, false
+		}
+	}
+	// 帶 Authorization 的請求，共用的快取只能存上游明確標示可以共用的 response（RFC 9111 3.5）
+	_, public := cc["public"]
+	sMaxAge, hasSMaxAge := cc["s-maxage"]
+	if reqHeader.Get("Authorization") != "" && !rule.KeyByUser && !public && !hasSMaxAge {
+		return 0, false
+	}
+
+	maxAge, hasMaxAge := cc["max-age"]
+	_, noCache := cc["no-cache"]
+	switch {
+	case noCache:
+		lifetime = 0
+	case hasSMaxAge:
+		lifetime = seconds(sMaxAge)
+	case hasMaxAge:
+		lifetime = seconds(maxAge)
+	case h.Get("Expires") != "":
+		// 格式錯誤的 Expires 代表已經過期
+		if expires, err := http.ParseTime(h.Get("Expires")); err == nil {
+			lifetime = expires.Sub(now)
+		}
+	default:
+		lifetime = rule.TTL
+	}
+	// 沒有新鮮期間又無法重新驗證的 response 存了也用不到
+	if lifetime <= 0 && h.Get("ETag") == "" {
+		return 0, false
+	}
+	if lifetime < 0 {
+		lifetime = 0
+	}
+	return lifetime, true
+}
+
+// writeEntry 送出快取中的 response，客戶端的 If-None-Match 符合時只回 304
+func writeEntry(c *gin.Context, entry *cache.Entry, now time.Time, state string) {
+	h := c.Writer.Header()
+	for key, values := range entry.Header {
+		h[key] = append(h[key], values...)
+	}
+	h.Set("Age", strconv.Itoa(int(entry.Age(now).Seconds())))
+	h.Set(CacheHeader, state)
+	if entry.Status == http.StatusOK && etagMatches(c.GetHeader("If-None-Match"), entry.Header.Get("ETag")) {
+		c.Writer.WriteHeader(http.StatusNotModified)
+		c.Writer.WriteHeaderNow()
+		return
+	}
+	c.Writer.WriteHeader(entry.Status)
+	_, _ = c.Writer.Write(entry.Body)
+}
+
+// requestCacheControl 請求的 Cache-Control；沒有 Cache-Control 時 Pragma: no-cache 視同 no-cache
+func requestCacheControl(h http.Header) map[string]string {
+	cc := parseCacheControl(h.Values("Cache-Control"))
+	if len(h.Values("Cache-Control")) == 0 && strings.Contains(strings.ToLower(h.Get("Pragma")), "no-cache") {
+		cc["no-cache"] = ""
+	}
+	return cc
+}
+
+// parseCacheControl 將 Cache-Control 轉成 directive → 值，directive 一律轉小寫
+func parseCacheControl(values []string) map[string]string {
+	cc := map[string]string{}
+	for _, value := range values {
+		for _, part := range strings.Split(value, ",") {
+			name, arg, _ := strings.Cut(strings.TrimSpace(part), "=")
+			if name = strings.ToLower(strings.TrimSpace(name)); name != "" {
+				cc[name] = strings.Trim(strings.TrimSpace(arg), `"`)
+			}
+		}
+	}
+	return cc
+}
+
+// seconds 解析 delta-seconds，格式錯誤視為 0（需要重新驗證）
+func seconds(value string) time.Duration {
+	n, err := strconv.ParseInt(value, 10, 64)
+	if err != nil || n < 0 {
+		return 0
+	}
+	return time.Duration(n) * time.Second
+}
+
+// varyFields 取出 Vary 中的 header 名稱，排序後去除重複，讓同一組 header 算出相同的 key
+func varyFields(h http.Header) []string {
+	var fields []string
+	seen := map[string]bool{}
+	for _, value := range h.Values("Vary") {
+		for _, field := range strings.Split(value, ",") {
+			field = textproto.CanonicalMIMEHeaderKey(strings.TrimSpace(field))
+			if field != "" && !seen[field] {
+				seen[field] = true
+				fields = append(fields, field)
+			}
+		}
+	}
+	sort.Strings(fields)
+	return fields
+}
+
+// etagMatches 以 weak comparison 比對 If-None-Match 與 ETag（RFC 9110 13.1.2）
+func etagMatches(ifNoneMatch, etag string) bool {
+	if ifNoneMatch == "" || etag == "" {
+		return false
+	}
+	if strings.TrimSpace(ifNoneMatch) == "*" {
+		return true
+	}
+	etag = strings.TrimPrefix(etag, "W/")
+	for _, candidate := range strings.Split(ifNoneMatch, ",") {
+		if strings.TrimPrefix(strings.TrimSpace(candidate), "W/") == etag {
+			return true
+		}
+	}
+	return false
+}
+
+// headerCounts 記錄每個 header 目前有幾個值，之後用來分辨哪些是下游加上的
+func headerCounts(h http.Header) map[string]int {
+	counts := make(map[string]int, len(h))
+	for key, values := range h {
+		counts[key] = len(values)
+	}
+	return counts
+}
+
+// addedHeader 取出 before 之後才加上的 header，也就是下游的 response header；
+// 外層 middleware 加上的 header（CORS、X-Request-ID 等）每個請求各自計算，不存入快取
+func addedHeader(h http.Header, before map[string]int) http.Header {
+	added := http.Header{}
+	for key, values := range h {
+		if n := before[key]; len(values) > n {
+			added[key] = append([]string(nil), values[n:]...)
+		}
+	}
+	for _, key := range uncachedHeaders {
+		added.Del(key)
+	}
+	return added
+}
+
+// restoreHeader 移除 before 之後才加上的 header
+func restoreHeader(h http.Header, before map[string]int) {
+	for key, values := range h {
+		if n := before[key]; n == 0 {
+			delete(h, key)
+		} else if len(values) > n {
+			h[key] = values[:n]
+		}
+	}
+}
+
+// cacheWriter 暫存下游的 response，決定是否存入快取之後才送出；
+// 超過 limit 時放棄快取，改為直接送出
+type cacheWriter struct {
+	gin.ResponseWriter
+	limit int
+
+	status      int
+	buf         []byte
+	passthrough bool
+}
+
+func (w *cacheWriter) WriteHeader(code int) {
+	if w.passthrough {
+		w.ResponseWriter.WriteHeader(code)
+		return
+	}
+	w.status = code
+}
+
+func (w *cacheWriter) WriteHeaderNow() {
+	if w.passthrough {
+		w.ResponseWriter.WriteHeaderNow()
+	}
+}
+
+func (w *cacheWriter) Write(p []byte) (int, error) {
+	if !w.passthrough && len(w.buf)+len(p) > w.limit {
+		w.startPassthrough()
+	}
+	if w.passthrough {
+		return w.ResponseWriter.Write(p)
+	}
+	w.buf = append(w.buf, p...)
+	return len(p), nil
+}
+
+func (w *cacheWriter) WriteString(s string) (int, error) {
+	return w.Write([]byte(s))
+}
+
+func (w *cacheWriter) Status() int {
+	if w.passthrough {
+		return w.ResponseWriter.Status()
+	}
+	if w.status == 0 {
+		return http.StatusOK
+	}
+	return w.status
+}
+
+func (w *cacheWriter) Size() int {
+	if w.passthrough {
+		return w.ResponseWriter.Size()
+	}
+	return len(w.buf)
+}
+
+func (w *cacheWriter) Written() bool {
+	return w.status != 0 || len(w.buf) > 0 || w.ResponseWriter.Written()
+}
+
+// Flush 串流的 response 不快取
+func (w *cacheWriter) Flush() {
+	w.startPassthrough()
+	w.ResponseWriter.Flush()
+}
+
+// startPassthrough 送出目前暫存的內容，之後的寫入直接交給底層的 writer
+func (w *cacheWriter) startPassthrough() {
+	if w.passthrough {
+		return
+	}
+	w.passthrough = true
+	w.writeBuffered()
+}
+
+// writeBuffered 送出暫存的 status 與內容
+func (w *cacheWriter) writeBuffered() {
+	status := w.status
+	if status == 0 {
+		status = http.StatusOK
+	}
+	w.ResponseWriter.WriteHeader(status)
+	if len(w.buf) == 0 {
+		w.ResponseWriter.WriteHeaderNow()
+		return
+	}
+	_, _ = w.ResponseWriter.Write(w.buf)
+	w.buf = nil
+}
@@ -0,0 +1,446 @@
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+
+	"api-gateway/cache"
+)
+
+// cacheUpstream 模擬下游服務：記錄收到幾個請求，以 respond 產生 response
+type cacheUpstream struct {
+	calls   int
+	lastINM string // 最近一次收到的 If-None-Match
+	respond func(c *gin.Context, call int)
+}
+
+func (u *cacheUpstream) handle(c *gin.Context) {
+	u.calls++
+	u.lastINM = c.GetHeader("If-None-Match")
+	u.respond(c, u.calls)
+}
+
+// jsonResponse 回傳帶有 headers 的 JSON response，body 包含第幾次呼叫，用來分辨是否來自快取
+func jsonResponse(headers ...string) func(c *gin.Context, call int) {
+	return func(c *gin.Context, call int) {
+		for i := 0; i+1 < len(headers); i += 2 {
+			c.Header(headers[i], headers[i+1])
+		}
+		c.Data(http.StatusOK, "application/json", []byte(`{"call":`+strconv.Itoa(call)+`}`))
+	}
+}
+
+// setupCacheRouter 以 in-memory 的 Store 掛上 Cache，/api 底下的請求都交給 upstream
+func setupCacheRouter(upstream *cacheUpstream, rules ...CacheRule) *gin.Engine {
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	r.Use(Cache(cache.NewMemoryStore(1<<20), 256, rules...))
+	r.Any("/api/*path", upstream.handle)
+	return r
+}
+
+// doCached 送出請求，headers 為成對的 name、value
+func doCached(router http.Handler, method, path string, headers ...string) *httptest.ResponseRecorder {
+	w := httptest.NewRecorder()
+	r, _ := http.NewRequest(method, path, nil)
+	for i := 0; i+1 < len(headers); i += 2 {
+		r.Header.Set(headers[i], headers[i+1])
+	}
+	router.ServeHTTP(w, r)
+	return w
+}
+
+func TestCache_Freshness(t *testing.T) {
+	rule := CacheRule{PathPrefix: "/api/notes", TTL: time.Minute}
+
+	tests := []struct {
+		name      string
+		response  []string // 下游 response 的 headers
+		request   []string // 第二個請求的 headers
+		wantCalls int      // 送出兩次相同請求後，下游收到幾個請求
+		wantCache string   // 第二個請求的 X-Cache
+	}{
+		{name: "rule ttl", wantCalls: 1, wantCache: cacheHit},
+		{name: "max-age", response: []string{"Cache-Control", "max-age=60"}, wantCalls: 1, wantCache: cacheHit},
+		{name: "s-maxage overrides max-age", response: []string{"Cache-Control", "max-age=0, s-maxage=60"}, wantCalls: 1, wantCache: cacheHit},
+		{name: "expires in the future", response: []string{"Expires", time.Now().Add(time.Hour).UTC().Format(http.TimeFormat)}, wantCalls: 1, wantCache: cacheHit},
+		{name: "malformed expires", response: []string{"Expires", "0"}, wantCalls: 2, wantCache: cacheMiss},
+		{name: "max-age=0 without etag", response: []string{"Cache-Control", "max-age=0"}, wantCalls: 2, wantCache: cacheMiss},
+		{name: "no-store", response: []string{"Cache-Control", "no-store"}, wantCalls: 2, wantCache: cacheMiss},
+		{name: "private", response: []string{"Cache-Control", "private, max-age=60"}, wantCalls: 2, wantCache: cacheMiss},
+		{name: "set-cookie", response: []string{"Set-Cookie", "session=1"}, wantCalls: 2, wantCache: cacheMiss},
+		{name: "vary star", response: []string{"Vary", "*"}, wantCalls: 2, wantCache: cacheMiss},
+		{name: "request no-cache", request: []string{"Cache-Control", "no-cache"}, wantCalls: 2, wantCache: cacheMiss},
+		{name: "request pragma no-cache", request: []string{"Pragma", "no-cache"}, wantCalls: 2, wantCache: cacheMiss},
+		{name: "request no-store", request: []string{"Cache-Control", "no-store"}, wantCalls: 2, wantCache: cacheBypass},
+		{name: "request max-age=0", request: []string{"Cache-Control", "max-age=0"}, wantCalls: 2, wantCache: cacheMiss},
+		{name: "request max-age within age", request: []string{"Cache-Control", "max-age=60"}, wantCalls: 1, wantCache: cacheHit},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			upstream := &cacheUpstream{respond: jsonResponse(tt.response...)}
+			router := setupCacheRouter(upstream, rule)
+
+			first := doCached(router, "GET", "/api/notes?page=1")
+			assert.Equal(t, http.StatusOK, first.Code)
+			second := doCached(router, "GET", "/api/notes?page=1", tt.request...)
+
+			assert.Equal(t, http.StatusOK, second.Code)
+			assert.Equal(t, tt.wantCalls, upstream.calls)
+			assert.Equal(t, tt.wantCache, second.Header().Get(CacheHeader))
+			if tt.wantCache == cacheHit {
+				assert.Equal(t, first.Body.String(), second.Body.String())
+				assert.Equal(t, "0", second.Header().Get("Age"))
+			}
+		})
+	}
+
+	t.Run("query is part of the key", func(t *testing.T) {
+		upstream := &cacheUpstream{respond: jsonResponse()}
+		router := setupCacheRouter(upstream, rule)
+
+		doCached(router, "GET", "/api/notes?page=1&sort=asc")
+		// 順序不同的相同 query 正規化後是同一個 key
+		assert.Equal(t, cacheHit, doCached(router, "GET", "/api/notes?sort=asc&page=1").Header().Get(CacheHeader))
+		assert.Equal(t, cacheMiss, doCached(router, "GET", "/api/notes?page=2").Header().Get(CacheHeader))
+	})
+
+	t.Run("paths without a rule are not cached", func(t *testing.T) {
+		upstream := &cacheUpstream{respond: jsonResponse()}
+		router := setupCacheRouter(upstream, rule)
+
+		doCached(router, "GET", "/api/notes-archive")
+		w := doCached(router, "GET", "/api/notes-archive")
+		assert.Equal(t, 2, upstream.calls)
+		assert.Empty(t, w.Header().Get(CacheHeader))
+	})
+
+	t.Run("authorization requires an explicit public response", func(t *testing.T) {
+		upstream := &cacheUpstream{respond: jsonResponse()}
+		router := setupCacheRouter(upstream, rule)
+
+		doCached(router, "GET", "/api/notes", "Authorization", "Bearer t")
+		doCached(router, "GET", "/api/notes", "Authorization", "Bearer t")
+		assert.Equal(t, 2, upstream.calls)
+
+		upstream = &cacheUpstream{respond: jsonResponse("Cache-Control", "public, max-age=60")}
+		router = setupCacheRouter(upstream, rule)
+		doCached(router, "GET", "/api/notes", "Authorization", "Bearer t")
+		doCached(router, "GET", "/api/notes", "Authorization", "Bearer t")
+		assert.Equal(t, 1, upstream.calls)
+	})
+
+	t.Run("only-if-cached miss", func(t *testing.T) {
+		upstream := &cacheUpstream{respond: jsonResponse()}
+		router := setupCacheRouter(upstream, rule)
+
+		w := doCached(router, "GET", "/api/notes", "Cache-Control", "only-if-cached")
+		assert.Equal(t, http.StatusGatewayTimeout, w.Code)
+		assert.Equal(t, 0, upstream.calls)
+	})
+
+	t.Run("responses over the entry limit are sent but not cached", func(t *testing.T) {
+		large := strings.Repeat("x", 512)
+		upstream := &cacheUpstream{respond: func(c *gin.Context, _ int) {
+			c.Data(http.StatusOK, "text/plain", []byte(large))
+		}}
+		router := setupCacheRouter(upstream, rule)
+
+		assert.Equal(t, large, doCached(router, "GET", "/api/notes").Body.String())
+		assert.Equal(t, large, doCached(router, "GET", "/api/notes").Body.String())
+		assert.Equal(t, 2, upstream.calls)
+	})
+
+	t.Run("headers from outer middleware are not replayed", func(t *testing.T) {
+		upstream := &cacheUpstream{respond: jsonResponse("ETag", `"v1"`)}
+		gin.SetMode(gin.TestMode)
+		router := gin.New()
+		request := 0
+		router.Use(func(c *gin.Context) {
+			request++
+			c.Header(RequestIDHeader, "req-"+strconv.Itoa(request))
+			c.Next()
+		})
+		router.Use(Cache(cache.NewMemoryStore(1<<20), 256, rule))
+		router.GET("/api/*path", upstream.handle)
+
+		doCached(router, "GET", "/api/notes")
+		w := doCached(router, "GET", "/api/notes")
+		assert.Equal(t, cacheHit, w.Header().Get(CacheHeader))
+		assert.Equal(t, []string{"req-2"}, w.Header().Values(RequestIDHeader))
+		assert.Equal(t, `"v1"`, w.Header().Get("ETag"))
+	})
+}
+
+func TestCache_Vary(t *testing.T) {
+	upstream := &cacheUpstream{respond: func(c *gin.Context, call int) {
+		c.Header("Vary", "Accept-Language")
+		c.Data(http.StatusOK, "application/json", []byte(`{"lang":"`+c.GetHeader("Accept-Language")+`"}`))
+	}}
+	router := setupCacheRouter(upstream, CacheRule{PathPrefix: "/api/notes", TTL: time.Minute})
+
+	doCached(router, "GET", "/api/notes", "Accept-Language", "en")
+	doCached(router, "GET", "/api/notes", "Accept-Language", "zh-TW")
+	assert.Equal(t, 2, upstream.calls)
+
+	en := doCached(router, "GET", "/api/notes", "Accept-Language", "en")
+	zh := doCached(router, "GET", "/api/notes", "Accept-Language", "zh-TW")
+	none := doCached(router, "GET", "/api/notes")
+
+	assert.Equal(t, cacheHit, en.Header().Get(CacheHeader))
+	assert.Equal(t, `{"lang":"en"}`, en.Body.String())
+	assert.Equal(t, cacheHit, zh.Header().Get(CacheHeader))
+	assert.Equal(t, `{"lang":"zh-TW"}`, zh.Body.String())
+	assert.Equal(t, cacheMiss, none.Header().Get(CacheHeader))
+	assert.Equal(t, 3, upstream.calls)
+}
+
+func TestCache_Revalidation(t *testing.T) {
+	rule := CacheRule{PathPrefix: "/api/notes"}
+
+	t.Run("stale response is revalidated with the cached etag", func(t *testing.T) {
+		upstream := &cacheUpstream{respond: func(c *gin.Context, call int) {
+			c.Header("ETag", `"v1"`)
+			c.Header("Cache-Control", "max-age=0")
+			if c.GetHeader("If-None-Match") == `"v1"` {
+				c.Status(http.StatusNotModified)
+				return
+			}
+			c.Data(http.StatusOK, "application/json", []byte(`{"call":`+strconv.Itoa(call)+`}`))
+		}}
+		router := setupCacheRouter(upstream, rule)
+
+		doCached(router, "GET", "/api/notes")
+		w := doCached(router, "GET", "/api/notes")
+
+		assert.Equal(t, 2, upstream.calls)
+		assert.Equal(t, `"v1"`, upstream.lastINM)
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.Equal(t, cacheRevalidated, w.Header().Get(CacheHeader))
+		assert.Equal(t, `{"call":1}`, w.Body.String())
+		assert.Equal(t, []string{`"v1"`}, w.Header().Values("ETag"))
+	})
+
+	t.Run("changed response replaces the cached one", func(t *testing.T) {
+		upstream := &cacheUpstream{respond: func(c *gin.Context, call int) {
+			c.Header("ETag", `"v`+strconv.Itoa(call)+`"`)
+			c.Header("Cache-Control", "max-age=0")
+			c.Data(http.StatusOK, "application/json", []byte(`{"call":`+strconv.Itoa(call)+`}`))
+		}}
+		router := setupCacheRouter(upstream, rule)
+
+		doCached(router, "GET", "/api/notes")
+		w := doCached(router, "GET", "/api/notes")
+		assert.Equal(t, cacheMiss, w.Header().Get(CacheHeader))
+		assert.Equal(t, `{"call":2}`, w.Body.String())
+
+		doCached(router, "GET", "/api/notes")
+		assert.Equal(t, `"v2"`, upstream.lastINM)
+	})
+
+	t.Run("client etag match on a hit returns 304", func(t *testing.T) {
+		upstream := &cacheUpstream{respond: jsonResponse("ETag", `"v1"`, "Cache-Control", "max-age=60")}
+		router := setupCacheRouter(upstream, rule)
+
+		doCached(router, "GET", "/api/notes")
+		w := doCached(router, "GET", "/api/notes", "If-None-Match", `W/"v1"`)
+
+		assert.Equal(t, http.StatusNotModified, w.Code)
+		assert.Empty(t, w.Body.String())
+		assert.Equal(t, 1, upstream.calls)
+	})
+
+	t.Run("client etag match on a miss returns 304 and still caches", func(t *testing.T) {
+		upstream := &cacheUpstream{respond: jsonResponse("ETag", `"v1"`, "Cache-Control", "max-age=60")}
+		router := setupCacheRouter(upstream, rule)
+
+		w := doCached(router, "GET", "/api/notes", "If-None-Match", `"v0", "v1"`)
+		assert.Equal(t, http.StatusNotModified, w.Code)
+		assert.Empty(t, w.Body.String())
+
+		w = doCached(router, "GET", "/api/notes")
+		assert.Equal(t, cacheHit, w.Header().Get(CacheHeader))
+		assert.Equal(t, `{"call":1}`, w.Body.String())
+	})
+
+	t.Run("client condition is forwarded instead of the cached etag", func(t *testing.T) {
+		upstream := &cacheUpstream{respond: jsonResponse("ETag", `"v1"`, "Cache-Control", "max-age=0")}
+		router := setupCacheRouter(upstream, rule)
+
+		doCached(router, "GET", "/api/notes")
+		doCached(router, "GET", "/api/notes", "If-None-Match", `"v0"`)
+		assert.Equal(t, `"v0"`, upstream.lastINM)
+	})
+}
+
+func TestCache_KeyByUser(t *testing.T) {
+	upstream := &cacheUpstream{respond: func(c *gin.Context, _ int) {
+		c.Header("Cache-Control", "private, max-age=60")
+		c.Data(http.StatusOK, "application/json", []byte(`{"id":"`+c.GetHeader(HeaderUserID)+`"}`))
+	}}
+	router := setupCacheRouter(upstream, CacheRule{PathPrefix: "/api/users/me", KeyByUser: true})
+
+	doCached(router, "GET", "/api/users/me", HeaderUserID, "uuid-001")
+	a := doCached(router, "GET", "/api/users/me", HeaderUserID, "uuid-001")
+	b := doCached(router, "GET", "/api/users/me", HeaderUserID, "uuid-002")
+
+	assert.Equal(t, cacheHit, a.Header().Get(CacheHeader))
+	assert.Equal(t, `{"id":"uuid-001"}`, a.Body.String())
+	assert.Equal(t, cacheMiss, b.Header().Get(CacheHeader))
+	assert.Equal(t, `{"id":"uuid-002"}`, b.Body.String())
+
+	// 沒有登入的請求不快取，避免不同人共用同一份 response
+	anonymous := doCached(router, "GET", "/api/users/me")
+	assert.Equal(t, cacheBypass, anonymous.Header().Get(CacheHeader))
+	assert.Equal(t, 3, upstream.calls)
+}
+
+func TestCache_PurgeOnMutation(t *testing.T) {
+	rule := CacheRule{PathPrefix: "/api/users/me", TTL: time.Minute, KeyByUser: true}
+
+	tests := []struct {
+		name       string
+		method     string
+		path       string
+		status     int
+		wantPurged []string // 之後需要重新向下游取得的 GET 路徑
+	}{
+		{
+			name:       "delete item purges its collection",
+			method:     "DELETE",
+			path:       "/api/users/me/sessions/sess-1",
+			status:     http.StatusNoContent,
+			wantPurged: []string{"/api/users/me/sessions", "/api/users/me/sessions/sess-1", "/api/users/me/sessions/sess-2"},
+		},
+		{
+			name:       "post to collection purges the parent",
+			method:     "POST",
+			path:       "/api/users/me/sessions",
+			status:     http.StatusCreated,
+			wantPurged: []string{"/api/users/me", "/api/users/me/sessions", "/api/users/me/sessions/sess-1", "/api/users/me/sessions/sess-2", "/api/users/me/audit"},
+		},
+		{
+			name:   "failed mutation keeps the cache",
+			method: "DELETE",
+			path:   "/api/users/me/sessions/sess-1",
+			status: http.StatusNotFound,
+		},
+	}
+
+	paths := []string{"/api/users/me", "/api/users/me/sessions", "/api/users/me/sessions/sess-1", "/api/users/me/sessions/sess-2", "/api/users/me/audit"}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			upstream := &cacheUpstream{respond: func(c *gin.Context, call int) {
+				if c.Request.Method != http.MethodGet {
+					c.Status(tt.status)
+					return
+				}
+				jsonResponse()(c, call)
+			}}
+			router := setupCacheRouter(upstream, rule)
+			for _, path := range paths {
+				doCached(router, "GET", path, HeaderUserID, "uuid-001")
+			}
+
+			doCached(router, tt.method, tt.path, HeaderUserID, "uuid-001")
+
+			var purged []string
+			for _, path := range paths {
+				if doCached(router, "GET", path, HeaderUserID, "uuid-001").Header().Get(CacheHeader) == cacheMiss {
+					purged = append(purged, path)
+				}
+			}
+			assert.Equal(t, tt.wantPurged, purged)
+		})
+	}
+}
+
+func TestPurgeCache(t *testing.T) {
+	tests := []struct {
+		name       string
+		path       string
+		status     int
+		wantPurged []string // 之後需要重新向下游取得的 GET 路徑
+	}{
+		{
+			// 確認連結不帶用戶 ID，清除所有用戶的快取
+			name:       "email confirmation purges every user",
+			path:       "/api/users/email/confirm",
+			status:     http.StatusOK,
+			wantPurged: []string{"/api/users", "/api/users/me", "/api/users/uuid-002"},
+		},
+		{
+			name:       "admin restore purges users outside its own path",
+			path:       "/api/admin/users/uuid-002/restore",
+			status:     http.StatusOK,
+			wantPurged: []string{"/api/users", "/api/users/me", "/api/users/uuid-002"},
+		},
+		{
+			name:   "failed request keeps the cache",
+			path:   "/api/users/email/confirm",
+			status: http.StatusBadRequest,
+		},
+	}
+
+	paths := []string{"/api/users", "/api/users/me", "/api/users/uuid-002", "/api/admin/audit-events"}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			upstream := &cacheUpstream{respond: func(c *gin.Context, call int) {
+				if c.Request.Method != http.MethodGet {
+					c.Status(tt.status)
+					return
+				}
+				jsonResponse()(c, call)
+			}}
+			store := cache.NewMemoryStore(1 << 20)
+			purgeUsers := PurgeCache(store, "/api/users")
+			gin.SetMode(gin.TestMode)
+			router := gin.New()
+			router.Use(Cache(store, 256,
+				CacheRule{PathPrefix: "/api/users", TTL: time.Minute, KeyByUser: true},
+				CacheRule{PathPrefix: "/api/admin", TTL: time.Minute, KeyByUser: true}))
+			router.GET("/api/*path", upstream.handle)
+			router.POST("/api/users/email/confirm", purgeUsers, upstream.handle)
+			router.POST("/api/admin/users/:id/restore", purgeUsers, upstream.handle)
+			for _, path := range paths {
+				doCached(router, "GET", path, HeaderUserID, "uuid-001")
+			}
+
+			doCached(router, "POST", tt.path, HeaderUserID, "uuid-001")
+
+			var purged []string
+			for _, path := range paths {
+				if doCached(router, "GET", path, HeaderUserID, "uuid-001").Header().Get(CacheHeader) == cacheMiss {
+					purged = append(purged, path)
+				}
+			}
+			assert.Equal(t, tt.wantPurged, purged)
+		})
+	}
+}
+
+func TestPurgePrefix(t *testing.T) {
+	tests := []struct {
+		path string
+		want string
+	}{
+		{path: "/api/users/me/sessions/sess-1", want: "/api/users/me/sessions"},
+		{path: "/api/users/me/sessions/", want: "/api/users/me"},
+		{path: "/api/users", want: "/api"},
+		{path: "/api", want: "/api"},
+		{path: "/", want: ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.path, func(t *testing.T) {
+			assert.Equal(t, tt.want, purgePrefix(tt.path))
+		})
+	}
+}
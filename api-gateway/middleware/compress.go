@@ -0,0 +1,172 @@
+package middleware
+
+import (
+	"log"
+	"mime"
+	"net/http"
+	"strings"
+
+	"api-gateway/compression"
+
+	"github.com/gin-gonic/gin"
+)
+
+// CompressConfig response 壓縮的設定
+type CompressConfig struct {
+	Encodings    []string // 依偏好排列，例如 br、zstd、gzip
+	MinSize      int      // 小於這個大小的 response 不壓縮，壓縮的 header 與 CPU 成本不划算
+	ContentTypes []string // 可壓縮的 media type，"text/*" 代表所有 text 類型；圖片等已壓縮的格式不應列入
+}
+
+// Compress 依 Accept-Encoding 壓縮 response。
+//
+// 下游已經壓縮（帶有 Content-Encoding）的 response 原封不動轉送；
+// ETag 維持不變：user-service 的 ETag 代表資源版本、用於 If-Match，與傳輸時的編碼無關，改成 weak 會讓 If-Match 一律失敗。
+func Compress(cfg CompressConfig) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		encoding := compression.Negotiate(c.GetHeader("Accept-Encoding"), cfg.Encodings)
+		if encoding == "" || c.Request.Method == http.MethodHead {
+			c.Next()
+			return
+		}
+
+		w := &compressWriter{ResponseWriter: c.Writer, cfg: cfg, encoding: encoding}
+		c.Writer = w
+		defer w.finish()
+		c.Next()
+	}
+}
+
+// compressWriter 先暫存 MinSize 以內的內容，確定夠大且可以壓縮時才開始以 encoding 壓縮
+type compressWriter struct {
+	gin.ResponseWriter
+	cfg      CompressConfig
+	encoding string
+
+	decided bool   // 是否已經決定要不要壓縮
+	buf     []byte // 決定之前暫存的內容
+	enc     *compression.Writer
+}
+
+func (w *compressWriter) Write(p []byte) (int, error) {
+	if w.decided {
+		if w.enc != nil {
+			return w.enc.Write(p)
+		}
+		return w.ResponseWriter.Write(p)
+	}
+	if !w.compressible() {
+		w.decided = true
+		return w.ResponseWriter.Write(p)
+	}
+
+	w.buf = append(w.buf, p...)
+	if len(w.buf) >= w.cfg.MinSize {
+		if err := w.start(); err != nil {
+			return 0, err
+		}
+	}
+	return len(p), nil
+}
+
+func (w *compressWriter) WriteString(s string) (int, error) {
+	return w.Write([]byte(s))
+}
+
+// Flush 串流的 response 需要立刻送出：還在暫存時放棄壓縮，直接送出暫存的內容
+func (w *compressWriter) Flush() {
+	if !w.decided {
+		w.flushPlain()
+	}
+	if w.enc != nil {
+		_ = w.enc.Flush()
+	}
+	w.ResponseWriter.Flush()
+}
+
+// compressible 依 status 與 handler 設定的 header 判斷這個 response 能不能壓縮
+func (w *compressWriter) compressible() bool {
+	h := w.Header()
+	status := w.Status()
+	if status < 200 || status == http.StatusNoContent || status == http.StatusPartialContent || status == http.StatusNotModified {
+		return false
+	}
+	if h.Get("Content-Encoding") != "" || strings.Contains(h.Get("Cache-Control"), "no-transform") {
+		return false
+	}
+	mediaType, _, err := mime.ParseMediaType(h.Get("Content-Type"))
+	if err != nil || !matchContentType(w.cfg.ContentTypes, mediaType) {
+		return false
+	}
+	// 會不會壓縮取決於 Accept-Encoding，快取需依此區分
+	addVary(h, "Accept-Encoding")
+	return true
+}
+
+// addVary 在 Vary 中加上 name，已經有了就不重複加
+func addVary(h http.Header, name string) {
+	for _, value := range h.Values("Vary") {
+		for _, field := range strings.Split(value, ",") {
+			if field = strings.TrimSpace(field); field == "*" || strings.EqualFold(field, name) {
+				return
+			}
+		}
+	}
+	h.Add("Vary", name)
+}
+
+// start 開始壓縮：設定 header 並寫出暫存的內容
+func (w *compressWriter) start() error {
+	w.decided = true
+	enc, err := compression.NewWriter(w.encoding, w.ResponseWriter)
+	if err != nil {
+		return err
+	}
+	h := w.Header()
+	h.Set("Content-Encoding", w.encoding)
+	h.Del("Content-Length") // 轉送的下游 Content-Length 是壓縮前的大小
+	h.Del("Accept-Ranges")  // range 是以壓縮後的內容計算，不再適用
+	w.enc = enc
+	buf := w.buf
+	w.buf = nil
+	_, err = enc.Write(buf)
+	return err
+}
+
+// flushPlain 不壓縮，直接送出暫存的內容
+func (w *compressWriter) flushPlain() {
+	w.decided = true
+	if len(w.buf) > 0 {
+		_, _ = w.ResponseWriter.Write(w.buf)
+		w.buf = nil
+	}
+}
+
+// finish handler 結束後送出暫存的內容或壓縮剩下的資料
+func (w *compressWriter) finish() {
+	if !w.decided {
+		w.flushPlain()
+	}
+	if w.enc != nil {
+		if err := w.enc.Close(); err != nil {
+			log.Printf("[Gateway] 壓縮 response 失敗 encoding=%s err=%v", w.encoding, err)
+		}
+		w.enc = nil
+	}
+}
+
+// matchContentType 比對 media type，清單中的 "text/*" 符合所有 text 類型
+func matchContentType(allowed []string, mediaType string) bool {
+	for _, t := range allowed {
+		if strings.HasSuffix(t, "/*") {
+			if strings.HasPrefix(mediaType, strings.TrimSuffix(t, "*")) {
+				return true
+			}
+			continue
+		}
+		if t == mediaType {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,236 @@
+package middleware
+
+import (
+	"bytes"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"api-gateway/compression"
+)
+
+var testCompressConfig = CompressConfig{
+	Encodings:    []string{compression.Brotli, compression.Zstd, compression.Gzip},
+	MinSize:      64,
+	ContentTypes: []string{"application/json", "text/*"},
+}
+
+// setupCompressRouter 掛上 Compress，handler 分多次寫出 body，模擬轉發時分段寫入
+func setupCompressRouter(handler gin.HandlerFunc) *gin.Engine {
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	r.Use(Compress(testCompressConfig))
+	r.GET("/*path", handler)
+	return r
+}
+
+// writeChunks 以 contentType 分 n 次寫出 body
+func writeChunks(contentType, body string, n int) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		c.Header("Content-Type", contentType)
+		c.Header("Content-Length", "999")
+		c.Status(http.StatusOK)
+		size := (len(body) + n - 1) / n
+		for i := 0; i < len(body); i += size {
+			end := i + size
+			if end > len(body) {
+				end = len(body)
+			}
+			c.Writer.WriteString(body[i:end])
+		}
+	}
+}
+
+// encodeBody 以 encoding 壓縮 body，模擬下游已經壓縮的 response
+func encodeBody(t *testing.T, encoding, body string) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	w, err := compression.NewWriter(encoding, &buf)
+	require.NoError(t, err)
+	_, err = io.WriteString(w, body)
+	require.NoError(t, err)
+	require.NoError(t, w.Close())
+	return buf.Bytes()
+}
+
+// decodeBody 依 Content-Encoding 解壓縮 response body
+func decodeBody(t *testing.T, w *httptest.ResponseRecorder) string {
+	t.Helper()
+	encoding := w.Header().Get("Content-Encoding")
+	if encoding == "" {
+		return w.Body.String()
+	}
+	r, err := compression.NewReader(encoding, bytes.NewReader(w.Body.Bytes()))
+	require.NoError(t, err)
+	defer r.Close()
+	body, err := io.ReadAll(r)
+	require.NoError(t, err)
+	return string(body)
+}
+
+func TestCompress(t *testing.T) {
+	large := `{"users":"` + strings.Repeat("x", 200) + `"}`
+	small := `{"id":"uuid-001"}`
+	encoded := encodeBody(t, compression.Brotli, large)
+
+	tests := []struct {
+		name           string
+		acceptEncoding string
+		handler        gin.HandlerFunc
+		wantBody       string
+		wantEncoding   string
+		wantVary       bool
+	}{
+		{
+			name:           "below min size is sent as is",
+			acceptEncoding: "gzip",
+			handler:        writeChunks("application/json", small, 2),
+			wantBody:       small,
+			wantVary:       true,
+		},
+		{
+			name:           "above min size is compressed",
+			acceptEncoding: "gzip",
+			handler:        writeChunks("application/json", large, 1),
+			wantBody:       large,
+			wantEncoding:   compression.Gzip,
+			wantVary:       true,
+		},
+		{
+			name:           "crossing min size across writes is compressed",
+			acceptEncoding: "br;q=0.5, zstd",
+			handler:        writeChunks("application/json; charset=utf-8", large, 8),
+			wantBody:       large,
+			wantEncoding:   compression.Zstd,
+			wantVary:       true,
+		},
+		{
+			name:           "text wildcard content type",
+			acceptEncoding: "br",
+			handler:        writeChunks("text/html", large, 3),
+			wantBody:       large,
+			wantEncoding:   compression.Brotli,
+			wantVary:       true,
+		},
+		{
+			name:           "content type not listed",
+			acceptEncoding: "gzip",
+			handler:        writeChunks("image/png", large, 1),
+			wantBody:       large,
+		},
+		{
+			name:           "client does not accept any encoding",
+			acceptEncoding: "identity",
+			handler:        writeChunks("application/json", large, 1),
+			wantBody:       large,
+		},
+		{
+			name:           "already encoded response passes through",
+			acceptEncoding: "gzip",
+			handler: func(c *gin.Context) {
+				c.Header("Content-Encoding", compression.Brotli)
+				c.Data(http.StatusOK, "application/json", encoded)
+			},
+			wantBody:     large,
+			wantEncoding: compression.Brotli,
+		},
+		{
+			name:           "no-transform",
+			acceptEncoding: "gzip",
+			handler: func(c *gin.Context) {
+				c.Header("Cache-Control", "no-transform")
+				c.Data(http.StatusOK, "application/json", []byte(large))
+			},
+			wantBody: large,
+		},
+		{
+			name:           "no content",
+			acceptEncoding: "gzip",
+			handler:        func(c *gin.Context) { c.Status(http.StatusNoContent) },
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			r, _ := http.NewRequest("GET", "/api/users", nil)
+			r.Header.Set("Accept-Encoding", tt.acceptEncoding)
+			setupCompressRouter(tt.handler).ServeHTTP(w, r)
+
+			assert.Equal(t, tt.wantEncoding, w.Header().Get("Content-Encoding"))
+			assert.Equal(t, tt.wantBody, decodeBody(t, w))
+			if tt.wantVary {
+				assert.Equal(t, []string{"Accept-Encoding"}, w.Header().Values("Vary"))
+			} else {
+				assert.Empty(t, w.Header().Values("Vary"))
+			}
+			if tt.wantEncoding == compression.Gzip || tt.wantEncoding == compression.Zstd {
+				// 轉送的 Content-Length 是壓縮前的大小，壓縮後不能留著
+				assert.Empty(t, w.Header().Get("Content-Length"))
+			}
+		})
+	}
+
+	t.Run("etag is kept", func(t *testing.T) {
+		router := setupCompressRouter(func(c *gin.Context) {
+			c.Header("ETag", `"v3"`)
+			c.Data(http.StatusOK, "application/json", []byte(large))
+		})
+		w := httptest.NewRecorder()
+		r, _ := http.NewRequest("GET", "/api/users/me", nil)
+		r.Header.Set("Accept-Encoding", "gzip")
+		router.ServeHTTP(w, r)
+
+		assert.Equal(t, compression.Gzip, w.Header().Get("Content-Encoding"))
+		assert.Equal(t, `"v3"`, w.Header().Get("ETag"))
+	})
+
+	t.Run("existing vary is extended once", func(t *testing.T) {
+		router := setupCompressRouter(func(c *gin.Context) {
+			c.Header("Vary", "Authorization, accept-encoding")
+			c.Data(http.StatusOK, "application/json", []byte(large))
+		})
+		w := httptest.NewRecorder()
+		r, _ := http.NewRequest("GET", "/api/users/me", nil)
+		r.Header.Set("Accept-Encoding", "gzip")
+		router.ServeHTTP(w, r)
+
+		assert.Equal(t, []string{"Authorization, accept-encoding"}, w.Header().Values("Vary"))
+	})
+
+	t.Run("flush before min size sends buffered data uncompressed", func(t *testing.T) {
+		router := setupCompressRouter(func(c *gin.Context) {
+			c.Header("Content-Type", "text/event-stream")
+			c.Status(http.StatusOK)
+			c.Writer.WriteString("data: 1\n\n")
+			c.Writer.Flush()
+			c.Writer.WriteString(strings.Repeat("x", 100))
+		})
+		w := httptest.NewRecorder()
+		r, _ := http.NewRequest("GET", "/api/events", nil)
+		r.Header.Set("Accept-Encoding", "gzip")
+		router.ServeHTTP(w, r)
+
+		assert.Empty(t, w.Header().Get("Content-Encoding"))
+		assert.Equal(t, "data: 1\n\n"+strings.Repeat("x", 100), w.Body.String())
+		assert.True(t, w.Flushed)
+	})
+
+	t.Run("head is not compressed", func(t *testing.T) {
+		router := gin.New()
+		router.Use(Compress(testCompressConfig))
+		router.HEAD("/api/users", writeChunks("application/json", large, 1))
+		w := httptest.NewRecorder()
+		r, _ := http.NewRequest("HEAD", "/api/users", nil)
+		r.Header.Set("Accept-Encoding", "gzip")
+		router.ServeHTTP(w, r)
+
+		assert.Empty(t, w.Header().Get("Content-Encoding"))
+	})
+}
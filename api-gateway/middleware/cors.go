@@ -0,0 +1,53 @@
+package middleware
+
+import (
+	"sort"
+	"strings"
+
+	"github.com/gin-contrib/cors"
+	"github.com/gin-gonic/gin"
+)
+
+// CORSRule 讓某個路徑前綴套用不同的 CORS 規則
+type CORSRule struct {
+	PathPrefix string // 例如 /api/admin，同時套用到 /api/admin/...，但不包含 /api/administrator
+	Config     cors.Config
+}
+
+// CORS 依請求路徑挑選 CORS 規則：符合的 PathPrefix 中最長的優先，都不符合時套用 global。
+//
+// 必須以 r.Use 掛在全域：preflight 的 OPTIONS 請求不會對應到任何已註冊的路由，
+// 掛在 route group 上的 middleware 根本不會被執行。
+func CORS(global cors.Config, rules ...CORSRule) gin.HandlerFunc {
+	type compiled struct {
+		prefix  string
+		handler gin.HandlerFunc
+	}
+	handlers := make([]compiled, 0, len(rules))
+	for _, rule := range rules {
+		handlers = append(handlers, compiled{
+			prefix:  strings.TrimSuffix(rule.PathPrefix, "/"),
+			handler: cors.New(rule.Config),
+		})
+	}
+	sort.SliceStable(handlers, func(i, j int) bool {
+		return len(handlers[i].prefix) > len(handlers[j].prefix)
+	})
+	fallback := cors.New(global)
+
+	return func(c *gin.Context) {
+		path := c.Request.URL.Path
+		for _, h := range handlers {
+			if hasPathPrefix(path, h.prefix) {
+				h.handler(c)
+				return
+			}
+		}
+		fallback(c)
+	}
+}
+
+// hasPathPrefix 以路徑段比對前綴：/api/admin 符合 /api/admin 與 /api/admin/...，但不符合 /api/administrator
+func hasPathPrefix(path, prefix string) bool {
+	return path == prefix || strings.HasPrefix(path, prefix+"/")
+}
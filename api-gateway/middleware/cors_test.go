@@ -0,0 +1,158 @@
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gin-contrib/cors"
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+)
+
+// setupCORSRouter 以 r.Use 掛上 CORS（與 routes.Setup 相同），只註冊 GET 路由，
+// preflight 的 OPTIONS 請求不會對應到任何路由
+func setupCORSRouter(global cors.Config, rules ...CORSRule) *gin.Engine {
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	r.Use(CORS(global, rules...))
+	r.GET("/*path", func(c *gin.Context) {
+		c.String(http.StatusOK, "ok")
+	})
+	return r
+}
+
+func TestCORS(t *testing.T) {
+	global := cors.Config{
+		AllowOrigins:     []string{"https://app.example.com"},
+		AllowMethods:     []string{"GET", "POST"},
+		AllowHeaders:     []string{"Content-Type", "Authorization"},
+		ExposeHeaders:    []string{"ETag"},
+		AllowCredentials: true,
+		MaxAge:           time.Hour,
+	}
+	admin := CORSRule{PathPrefix: "/api/admin", Config: cors.Config{
+		AllowOrigins: []string{"https://admin.example.com"},
+		AllowMethods: []string{"GET", "DELETE"},
+	}}
+	adminAudit := CORSRule{PathPrefix: "/api/admin/audit-events/", Config: cors.Config{
+		AllowOrigins: []string{"https://audit.example.com"},
+		AllowMethods: []string{"GET"},
+	}}
+
+	tests := []struct {
+		name        string
+		method      string
+		path        string
+		origin      string
+		header      http.Header
+		wantCode    int
+		wantOrigin  string // Access-Control-Allow-Origin
+		wantMethods string // Access-Control-Allow-Methods（只有 preflight 會帶）
+		wantMaxAge  string
+		wantCreds   string
+		wantExpose  string
+	}{
+		{
+			name:     "same-origin request without Origin",
+			method:   "GET",
+			path:     "/api/users/me",
+			wantCode: http.StatusOK,
+		},
+		{
+			name:       "allowed origin",
+			method:     "GET",
+			path:       "/api/users/me",
+			origin:     "https://app.example.com",
+			wantCode:   http.StatusOK,
+			wantOrigin: "https://app.example.com",
+			wantCreds:  "true",
+			wantExpose: "Etag",
+		},
+		{
+			name:     "denied origin",
+			method:   "GET",
+			path:     "/api/users/me",
+			origin:   "https://evil.example.com",
+			wantCode: http.StatusForbidden,
+		},
+		{
+			name:        "preflight for an allowed origin",
+			method:      "OPTIONS",
+			path:        "/api/users/me",
+			origin:      "https://app.example.com",
+			header:      http.Header{"Access-Control-Request-Method": {"POST"}},
+			wantCode:    http.StatusNoContent,
+			wantOrigin:  "https://app.example.com",
+			wantMethods: "GET,POST",
+			wantMaxAge:  "3600",
+			wantCreds:   "true",
+		},
+		{
+			name:     "preflight for a denied origin",
+			method:   "OPTIONS",
+			path:     "/api/users/me",
+			origin:   "https://evil.example.com",
+			header:   http.Header{"Access-Control-Request-Method": {"POST"}},
+			wantCode: http.StatusForbidden,
+		},
+		{
+			name:        "route rule overrides global",
+			method:      "OPTIONS",
+			path:        "/api/admin/users/abc-123",
+			origin:      "https://admin.example.com",
+			header:      http.Header{"Access-Control-Request-Method": {"DELETE"}},
+			wantCode:    http.StatusNoContent,
+			wantOrigin:  "https://admin.example.com",
+			wantMethods: "GET,DELETE",
+		},
+		{
+			name:     "global origin is not allowed on a route rule",
+			method:   "GET",
+			path:     "/api/admin/users",
+			origin:   "https://app.example.com",
+			wantCode: http.StatusForbidden,
+		},
+		{
+			name:       "longest prefix wins",
+			method:     "GET",
+			path:       "/api/admin/audit-events",
+			origin:     "https://audit.example.com",
+			wantCode:   http.StatusOK,
+			wantOrigin: "https://audit.example.com",
+		},
+		{
+			name:       "prefix matches whole path segments only",
+			method:     "GET",
+			path:       "/api/administrator",
+			origin:     "https://app.example.com",
+			wantCode:   http.StatusOK,
+			wantOrigin: "https://app.example.com",
+			wantCreds:  "true",
+			wantExpose: "Etag",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			router := setupCORSRouter(global, admin, adminAudit)
+			w := httptest.NewRecorder()
+			r, _ := http.NewRequest(tt.method, tt.path, nil)
+			if tt.origin != "" {
+				r.Header.Set("Origin", tt.origin)
+			}
+			for name, values := range tt.header {
+				r.Header[name] = values
+			}
+			router.ServeHTTP(w, r)
+
+			assert.Equal(t, tt.wantCode, w.Code)
+			assert.Equal(t, tt.wantOrigin, w.Header().Get("Access-Control-Allow-Origin"))
+			assert.Equal(t, tt.wantMethods, w.Header().Get("Access-Control-Allow-Methods"))
+			assert.Equal(t, tt.wantMaxAge, w.Header().Get("Access-Control-Max-Age"))
+			assert.Equal(t, tt.wantCreds, w.Header().Get("Access-Control-Allow-Credentials"))
+			assert.Equal(t, tt.wantExpose, w.Header().Get("Access-Control-Expose-Headers"))
+		})
+	}
+}
@@ -0,0 +1,38 @@
+package middleware
+
+import (
+	"github.com/gin-gonic/gin"
+)
+
+// 通過 RequireAuth 後注入給下游服務的身份 header，需與 user-service handlers 中的名稱相同
+const (
+	HeaderUserID    = "X-User-ID"
+	HeaderUserEmail = "X-User-Email"
+	HeaderUserRoles = "X-User-Roles" // 以逗號分隔，目前每個用戶只有一個角色
+	HeaderSessionID = "X-Session-ID"
+)
+
+// identityHeaders 下游服務會信任的所有身份 header
+var identityHeaders = []string{HeaderUserID, HeaderUserEmail, HeaderUserRoles, HeaderSessionID}
+
+// StripIdentityHeaders 移除客戶端自帶的身份 header，必須掛在所有路由之前。
+// 這些 header 只能由 RequireAuth 在驗證 token 後寫入，否則任何人都能冒充其他用戶。
+func StripIdentityHeaders() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		for _, h := range identityHeaders {
+			c.Request.Header.Del(h)
+		}
+
+		c.Next()
+	}
+}
+
+// setIdentityHeaders 將驗證過的 claims 寫入請求 header，由 proxy 轉發給下游服務
+func setIdentityHeaders(c *gin.Context, claims *Claims) {
+	c.Request.Header.Set(HeaderUserID, claims.UserID)
+	c.Request.Header.Set(HeaderUserEmail, claims.Email)
+	c.Request.Header.Set(HeaderUserRoles, claims.Role)
+	if claims.SessionID != "" {
+		c.Request.Header.Set(HeaderSessionID, claims.SessionID)
+	}
+}
@@ -14,12 +14,13 @@ func Logger() gin.HandlerFunc {
 
 		c.Next()
 
-		log.Printf("[Gateway] %s %s | status=%d | latency=%s | ip=%s",
+		log.Printf("[Gateway] %s %s | status=%d | latency=%s | ip=%s | request_id=%s",
 			c.Request.Method,
 			c.Request.URL.Path,
 			c.Writer.Status(),
 			time.Since(start),
 			c.ClientIP(),
+			c.GetString("request_id"),
 		)
 	}
 }
@@ -0,0 +1,38 @@
+package middleware
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+
+	"github.com/gin-gonic/gin"
+)
+
+// RequestIDHeader 每個請求的追蹤 ID，轉發給下游服務並寫進稽核紀錄
+const RequestIDHeader = "X-Request-ID"
+
+// maxRequestIDLength 客戶端自帶的 request ID 超過此長度就重新產生，避免塞爆 log 與稽核紀錄
+const maxRequestIDLength = 128
+
+// RequestID 確保每個請求都帶有 X-Request-ID：
+// 客戶端有帶就沿用，沒有就產生一個，並同時寫回 response header 方便前端回報問題。
+func RequestID() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		id := c.GetHeader(RequestIDHeader)
+		if id == "" || len(id) > maxRequestIDLength {
+			id = newRequestID()
+		}
+
+		c.Request.Header.Set(RequestIDHeader, id)
+		c.Header(RequestIDHeader, id)
+		c.Set("request_id", id)
+
+		c.Next()
+	}
+}
+
+// newRequestID 產生 16 bytes 的隨機 hex 字串
+func newRequestID() string {
+	b := make([]byte, 16)
+	rand.Read(b)
+	return hex.EncodeToString(b)
+}
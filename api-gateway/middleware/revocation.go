@@ -0,0 +1,91 @@
+package middleware
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/redis/go-redis/v9"
+)
+
+func init() {
+	// user-service 簽發的 iat 精確到微秒；jwt 套件預設會把解析出的時間截到秒，
+	// 撤銷檢查就分不出同一秒內的登入與撤銷誰先誰後
+	jwt.TimePrecision = time.Nanosecond
+}
+
+// revokedBeforeKeyPrefix 需與 user-service repository.RevokedBeforeKeyPrefix 相同，
+// user-service 在密碼重設等情境寫入「此時間點之前簽發的 token 一律失效」
+const revokedBeforeKeyPrefix = "auth:revoked_before:"
+
+// revokedSessionKeyPrefix 需與 user-service repository.RevokedSessionKeyPrefix 相同，
+// 用戶撤銷單一 session 時寫入，對應 token 的 sid claim
+const revokedSessionKeyPrefix = "auth:revoked_session:"
+
+// RevocationChecker 判斷一個簽章合法的 token 是否已被撤銷
+type RevocationChecker interface {
+	IsRevoked(ctx context.Context, claims *Claims) (bool, error)
+}
+
+// RedisRevocationChecker 讀取 user-service 寫在 Redis 的撤銷紀錄
+type RedisRevocationChecker struct {
+	client *redis.Client
+}
+
+// NewRedisRevocationChecker 建立以 Redis 為後端的 RevocationChecker
+func NewRedisRevocationChecker(client *redis.Client) *RedisRevocationChecker {
+	return &RedisRevocationChecker{client: client}
+}
+
+// IsRevoked 若 token 所屬的 session 已被撤銷，或簽發時間早於該用戶的撤銷時間點，視為已撤銷
+func (r *RedisRevocationChecker) IsRevoked(ctx context.Context, claims *Claims) (bool, error) {
+	if claims.SessionID != "" {
+		n, err := r.client.Exists(ctx, revokedSessionKeyPrefix+claims.SessionID).Result()
+		if err != nil {
+			return false, err
+		}
+		if n > 0 {
+			return true, nil
+		}
+	}
+
+	value, err := r.client.Get(ctx, revokedBeforeKeyPrefix+claims.UserID).Result()
+	if err == redis.Nil {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	revokedBefore, err := parseRevokedBefore(value)
+	if err != nil {
+		return false, err
+	}
+
+	// 沒有 iat 的 token 無法判斷簽發時間，保守起見視為已撤銷
+	if claims.IssuedAt == nil {
+		return true, nil
+	}
+	// iat 在 JSON 中是浮點數，解析後可能差幾百奈秒，先四捨五入回簽發時的微秒再比較
+	return claims.IssuedAt.Round(time.Microsecond).UnixMicro() <= revokedBefore, nil
+}
+
+// parseRevokedBefore 解析 user-service 寫入的撤銷時間點，回傳 Unix 微秒
+// 格式為「秒.微秒」；舊版只記到秒，語意是同一秒內簽發的 token 也失效，因此視為該秒的最後一微秒
+func parseRevokedBefore(value string) (int64, error) {
+	secPart, fracPart, hasFrac := strings.Cut(value, ".")
+	sec, err := strconv.ParseInt(secPart, 10, 64)
+	if err != nil {
+		return 0, fmt.Errorf("撤銷時間格式錯誤：%q", value)
+	}
+	if !hasFrac {
+		return sec*1e6 + 999999, nil
+	}
+	micro, err := strconv.ParseUint(fracPart, 10, 32)
+	if err != nil || len(fracPart) != 6 {
+		return 0, fmt.Errorf("撤銷時間格式錯誤：%q", value)
+	}
+	return sec*1e6 + int64(micro), nil
+}
@@ -0,0 +1,148 @@
+package middleware
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/redis/go-redis/v9"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// newTestRedis 啟動 in-memory 的 Redis，測試結束時自動關閉
+func newTestRedis(t *testing.T) (*miniredis.Miniredis, *redis.Client) {
+	t.Helper()
+	server := miniredis.RunT(t)
+	client := redis.NewClient(&redis.Options{Addr: server.Addr()})
+	t.Cleanup(func() { client.Close() })
+	return server, client
+}
+
+func TestRedisRevocationChecker(t *testing.T) {
+	// user-service 以「秒.微秒」記錄撤銷時間點
+	revokedAt := time.Date(2024, 1, 1, 12, 0, 0, 500000000, time.UTC)
+	record := "1704110400.500000"
+
+	tests := []struct {
+		name      string
+		record    string
+		issuedAt  *jwt.NumericDate
+		sessionID string
+		want      bool
+	}{
+		{name: "issued before revocation", record: record, issuedAt: jwt.NewNumericDate(revokedAt.Add(-time.Second)), want: true},
+		{name: "issued earlier in the same second", record: record, issuedAt: jwt.NewNumericDate(revokedAt.Add(-300 * time.Millisecond)), want: true},
+		{name: "issued at the revocation time", record: record, issuedAt: jwt.NewNumericDate(revokedAt), want: true},
+		{name: "login later in the same second", record: record, issuedAt: jwt.NewNumericDate(revokedAt.Add(300 * time.Millisecond)), want: false},
+		{name: "issued after revocation", record: record, issuedAt: jwt.NewNumericDate(revokedAt.Add(time.Second)), want: false},
+		{name: "missing iat", record: record, issuedAt: nil, want: true},
+		{name: "legacy record revokes the whole second", record: "1704110400", issuedAt: jwt.NewNumericDate(revokedAt.Add(300 * time.Millisecond)), want: true},
+		{name: "legacy record and next second", record: "1704110400", issuedAt: jwt.NewNumericDate(revokedAt.Add(time.Second)), want: false},
+		{name: "revoked session", record: record, issuedAt: jwt.NewNumericDate(revokedAt.Add(time.Hour)), sessionID: "sess-1", want: true},
+		{name: "other session", record: record, issuedAt: jwt.NewNumericDate(revokedAt.Add(time.Hour)), sessionID: "sess-2", want: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server, client := newTestRedis(t)
+			server.Set(revokedBeforeKeyPrefix+"uuid-001", tt.record)
+			server.Set(revokedSessionKeyPrefix+"sess-1", "1")
+
+			claims := &Claims{UserID: "uuid-001", SessionID: tt.sessionID}
+			claims.IssuedAt = tt.issuedAt
+
+			revoked, err := NewRedisRevocationChecker(client).IsRevoked(context.Background(), claims)
+
+			require.NoError(t, err)
+			assert.Equal(t, tt.want, revoked)
+		})
+	}
+
+	t.Run("no revocation record", func(t *testing.T) {
+		_, client := newTestRedis(t)
+
+		claims := &Claims{UserID: "uuid-001"}
+		revoked, err := NewRedisRevocationChecker(client).IsRevoked(context.Background(), claims)
+
+		require.NoError(t, err)
+		assert.False(t, revoked)
+	})
+
+	t.Run("malformed record", func(t *testing.T) {
+		server, client := newTestRedis(t)
+		server.Set(revokedBeforeKeyPrefix+"uuid-001", "yesterday")
+
+		claims := &Claims{UserID: "uuid-001"}
+		claims.IssuedAt = jwt.NewNumericDate(revokedAt)
+		_, err := NewRedisRevocationChecker(client).IsRevoked(context.Background(), claims)
+
+		assert.Error(t, err)
+	})
+}
+
+// 改密碼後同一秒內重新登入：iat 經過 JWT 的 JSON 浮點數編碼後仍要能分辨先後
+func TestRedisRevocationChecker_SameSecondLogin(t *testing.T) {
+	revokedAt := time.Date(2024, 1, 1, 12, 0, 0, 123456000, time.UTC)
+
+	tests := []struct {
+		name     string
+		issuedAt time.Time
+		want     bool
+	}{
+		{name: "one microsecond after", issuedAt: revokedAt.Add(time.Microsecond), want: false},
+		{name: "same microsecond", issuedAt: revokedAt, want: true},
+		{name: "one microsecond before", issuedAt: revokedAt.Add(-time.Microsecond), want: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server, client := newTestRedis(t)
+			server.Set(revokedBeforeKeyPrefix+"uuid-001", "1704110400.123456")
+
+			issued := &Claims{UserID: "uuid-001"}
+			issued.IssuedAt = jwt.NewNumericDate(tt.issuedAt)
+			tokenString, err := jwt.NewWithClaims(jwt.SigningMethodHS256, issued).SignedString([]byte("test-secret"))
+			require.NoError(t, err)
+			claims := &Claims{}
+			_, err = jwt.ParseWithClaims(tokenString, claims, func(*jwt.Token) (interface{}, error) {
+				return []byte("test-secret"), nil
+			})
+			require.NoError(t, err)
+
+			revoked, err := NewRedisRevocationChecker(client).IsRevoked(context.Background(), claims)
+
+			require.NoError(t, err)
+			assert.Equal(t, tt.want, revoked)
+		})
+	}
+}
+
+func TestParseRevokedBefore(t *testing.T) {
+	tests := []struct {
+		value   string
+		want    int64
+		wantErr bool
+	}{
+		{value: "1704110400.123456", want: 1704110400123456},
+		{value: "1704110400.000042", want: 1704110400000042},
+		{value: "1704110400", want: 1704110400999999},
+		{value: "1704110400.5", wantErr: true},
+		{value: "1704110400.-12345", wantErr: true},
+		{value: "", wantErr: true},
+		{value: "abc", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.value, func(t *testing.T) {
+			got, err := parseRevokedBefore(tt.value)
+			if tt.wantErr {
+				assert.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
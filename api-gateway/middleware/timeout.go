@@ -0,0 +1,43 @@
+package middleware
+
+import (
+	"context"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// TimeoutRule 某個路徑前綴的請求逾時
+type TimeoutRule struct {
+	PathPrefix string
+	Timeout    time.Duration
+}
+
+// Timeout 依請求路徑為 request context 加上 deadline：符合的 PathPrefix 中最長的優先，
+// 都不符合時不設定，由各上游自己的逾時決定。
+//
+// deadline 會隨 context 傳給 proxy 與 gRPC translator，並以 header / gRPC deadline 告知上游，
+// 路由的逾時因此可以比上游預設的逾時長，也可以更短。
+func Timeout(rules ...TimeoutRule) gin.HandlerFunc {
+	sorted := make([]TimeoutRule, len(rules))
+	for i, rule := range rules {
+		sorted[i] = TimeoutRule{PathPrefix: strings.TrimSuffix(rule.PathPrefix, "/"), Timeout: rule.Timeout}
+	}
+	sort.SliceStable(sorted, func(i, j int) bool {
+		return len(sorted[i].PathPrefix) > len(sorted[j].PathPrefix)
+	})
+
+	return func(c *gin.Context) {
+		for _, rule := range sorted {
+			if hasPathPrefix(c.Request.URL.Path, rule.PathPrefix) {
+				ctx, cancel := context.WithTimeout(c.Request.Context(), rule.Timeout)
+				defer cancel()
+				c.Request = c.Request.WithContext(ctx)
+				break
+			}
+		}
+		c.Next()
+	}
+}
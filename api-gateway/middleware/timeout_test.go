@@ -0,0 +1,107 @@
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"api-gateway/proxy"
+)
+
+func TestTimeoutDeadline(t *testing.T) {
+	rules := []TimeoutRule{
+		{PathPrefix: "/api/admin", Timeout: time.Minute},
+		{PathPrefix: "/api/admin/audit-events/", Timeout: time.Hour},
+		{PathPrefix: "/api/users/login", Timeout: time.Second},
+	}
+
+	tests := []struct {
+		name string
+		path string
+		want time.Duration // 0 表示不設定 deadline
+	}{
+		{name: "matching prefix", path: "/api/users/login", want: time.Second},
+		{name: "nested path", path: "/api/admin/users/abc-123", want: time.Minute},
+		{name: "longest prefix wins", path: "/api/admin/audit-events", want: time.Hour},
+		{name: "prefix matches whole path segments only", path: "/api/administrator"},
+		{name: "no rule", path: "/api/users/me"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var deadline time.Time
+			var hasDeadline bool
+			gin.SetMode(gin.TestMode)
+			r := gin.New()
+			r.Use(Timeout(rules...))
+			r.GET("/*path", func(c *gin.Context) {
+				deadline, hasDeadline = c.Request.Context().Deadline()
+			})
+
+			start := time.Now()
+			r.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", tt.path, nil))
+
+			if tt.want == 0 {
+				assert.False(t, hasDeadline)
+				return
+			}
+			require.True(t, hasDeadline)
+			assert.WithinDuration(t, start.Add(tt.want), deadline, time.Second)
+		})
+	}
+}
+
+func TestTimeoutForward(t *testing.T) {
+	tests := []struct {
+		name     string
+		delay    time.Duration
+		wantCode int
+		wantBody string
+	}{
+		{
+			name:     "upstream finishes before the deadline",
+			wantCode: http.StatusOK,
+			wantBody: `{"id":"uuid-001"}`,
+		},
+		{
+			name:     "upstream is slower than the route timeout",
+			delay:    time.Second,
+			wantCode: http.StatusGatewayTimeout,
+			wantBody: `{"error":"下游服務逾時"}`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				select {
+				case <-time.After(tt.delay):
+				case <-r.Context().Done():
+					return
+				}
+				w.Header().Set("Content-Type", "application/json")
+				w.Write([]byte(`{"id":"uuid-001"}`))
+			}))
+			defer upstream.Close()
+
+			// 路由的逾時比上游預設的逾時短，以路由的為準
+			gin.SetMode(gin.TestMode)
+			r := gin.New()
+			r.Use(Timeout(TimeoutRule{PathPrefix: "/api/users", Timeout: 100 * time.Millisecond}))
+			r.Any("/api/users/*path", proxy.New(proxy.WithTimeout(time.Minute)).Forward(upstream.URL, "/api/users"))
+
+			w := httptest.NewRecorder()
+			start := time.Now()
+			r.ServeHTTP(w, httptest.NewRequest("GET", "/api/users/me", nil))
+
+			assert.Equal(t, tt.wantCode, w.Code)
+			assert.JSONEq(t, tt.wantBody, w.Body.String())
+			assert.Less(t, time.Since(start), 900*time.Millisecond, "should not wait for the upstream")
+		})
+	}
+}
@@ -0,0 +1,141 @@
+package proxy
+
+import (
+	"net"
+	"net/http"
+	"strings"
+)
+
+// hopByHopHeaders 只對單一連線有意義的 header（RFC 9110 7.6.1），不可轉發給下一站
+var hopByHopHeaders = []string{
+	"Connection",
+	"Proxy-Connection",
+	"Keep-Alive",
+	"Proxy-Authenticate",
+	"Proxy-Authorization",
+	"Te",
+	"Trailer",
+	"Transfer-Encoding",
+	"Upgrade",
+}
+
+// removeHopByHop 移除 hop-by-hop header，包含 Connection 中列出的 header
+func removeHopByHop(h http.Header) {
+	for _, value := range h.Values("Connection") {
+		for _, name := range strings.Split(value, ",") {
+			if name = strings.TrimSpace(name); name != "" {
+				h.Del(name)
+			}
+		}
+	}
+	for _, name := range hopByHopHeaders {
+		h.Del(name)
+	}
+}
+
+// ParseTrustedProxies 將 IP 或 CIDR 清單轉成網段，單一 IP 視為 /32（IPv6 為 /128）
+func ParseTrustedProxies(list []string) ([]*net.IPNet, error) {
+	nets := make([]*net.IPNet, 0, len(list))
+	for _, item := range list {
+		if !strings.Contains(item, "/") {
+			ip := net.ParseIP(item)
+			if ip == nil {
+				return nil, &net.ParseError{Type: "IP address", Text: item}
+			}
+			bits := 8 * net.IPv6len
+			if ip.To4() != nil {
+				ip, bits = ip.To4(), 8*net.IPv4len
+			}
+			nets = append(nets, &net.IPNet{IP: ip, Mask: net.CIDRMask(bits, bits)})
+			continue
+		}
+		_, ipNet, err := net.ParseCIDR(item)
+		if err != nil {
+			return nil, err
+		}
+		nets = append(nets, ipNet)
+	}
+	return nets, nil
+}
+
+// trustedPeer 判斷直接連到 gateway 的來源是否為信任的 proxy
+func (p *Proxy) trustedPeer(remoteIP string) bool {
+	ip := net.ParseIP(remoteIP)
+	if ip == nil {
+		return false
+	}
+	for _, ipNet := range p.trustedProxies {
+		if ipNet.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
+
+// setForwardedHeaders 在轉發的請求上加上這一站的 X-Forwarded-For/Proto/Host 與 Forwarded
+//
+// 來源是信任的 proxy 時，沿用它帶來的值並把它的 IP 接在 X-Forwarded-For 與 Forwarded 後面；
+// 否則客戶端自帶的值一律丟棄，避免偽造來源 IP 寫入稽核紀錄
+func (p *Proxy) setForwardedHeaders(out http.Header, in *http.Request) {
+	remoteIP, _, err := net.SplitHostPort(in.RemoteAddr)
+	if err != nil {
+		remoteIP = in.RemoteAddr
+	}
+
+	proto := "http"
+	if in.TLS != nil {
+		proto = "https"
+	}
+	host := in.Host
+	priorFor, priorForwarded := "", ""
+	if p.trustedPeer(remoteIP) {
+		if v := in.Header.Get("X-Forwarded-Proto"); v != "" {
+			proto = v
+		}
+		if v := in.Header.Get("X-Forwarded-Host"); v != "" {
+			host = v
+		}
+		priorFor = strings.Join(in.Header.Values("X-Forwarded-For"), ", ")
+		priorForwarded = strings.Join(in.Header.Values("Forwarded"), ", ")
+	}
+
+	xff := remoteIP
+	if priorFor != "" {
+		xff = priorFor + ", " + remoteIP
+	}
+	out.Set("X-Forwarded-For", xff)
+	out.Set("X-Forwarded-Proto", proto)
+	out.Set("X-Forwarded-Host", host)
+
+	element := "for=" + forwardedNode(remoteIP) + ";host=" + quoteIfNeeded(host) + ";proto=" + proto
+	if priorForwarded != "" {
+		element = priorForwarded + ", " + element
+	}
+	out.Set("Forwarded", element)
+}
+
+// forwardedNode Forwarded 中的 IPv6 位址需加上中括號並以引號包住（RFC 7239 6）
+func forwardedNode(ip string) string {
+	if strings.Contains(ip, ":") {
+		return `"[` + ip + `]"`
+	}
+	return ip
+}
+
+// quoteIfNeeded Forwarded 的值不是 token（例如含 port 的 host）時需以引號包住
+func quoteIfNeeded(value string) string {
+	for _, r := range value {
+		if !isTokenChar(r) {
+			return `"` + strings.ReplaceAll(value, `"`, `\"`) + `"`
+		}
+	}
+	return value
+}
+
+func isTokenChar(r rune) bool {
+	switch {
+	case r >= 'a' && r <= 'z', r >= 'A' && r <= 'Z', r >= '0' && r <= '9':
+		return true
+	}
+	return strings.ContainsRune("!#$%&'*+-.^_`|~", r)
+}
@@ -0,0 +1,252 @@
+package proxy
+
+import (
+	"crypto/tls"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRemoveHopByHop(t *testing.T) {
+	tests := []struct {
+		name   string
+		header http.Header
+		want   http.Header
+	}{
+		{
+			name: "standard hop-by-hop headers",
+			header: http.Header{
+				"Connection":          {"keep-alive"},
+				"Keep-Alive":          {"timeout=5"},
+				"Proxy-Authorization": {"Basic abc"},
+				"Te":                  {"trailers"},
+				"Transfer-Encoding":   {"chunked"},
+				"Upgrade":             {"websocket"},
+				"Authorization":       {"Bearer token"},
+				"Content-Type":        {"application/json"},
+			},
+			want: http.Header{
+				"Authorization": {"Bearer token"},
+				"Content-Type":  {"application/json"},
+			},
+		},
+		{
+			name: "headers listed in Connection",
+			header: http.Header{
+				"Connection":    {"X-Debug, x-trace-token", "Keep-Alive"},
+				"X-Debug":       {"1"},
+				"X-Trace-Token": {"secret"},
+				"X-Request-Id":  {"req-42"},
+			},
+			want: http.Header{
+				"X-Request-Id": {"req-42"},
+			},
+		},
+		{
+			name:   "end-to-end headers are kept",
+			header: http.Header{"Etag": {`"3"`}, "Cache-Control": {"no-store"}},
+			want:   http.Header{"Etag": {`"3"`}, "Cache-Control": {"no-store"}},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			removeHopByHop(tt.header)
+
+			assert.Equal(t, tt.want, tt.header)
+		})
+	}
+}
+
+func TestParseTrustedProxies(t *testing.T) {
+	tests := []struct {
+		name    string
+		list    []string
+		want    []string
+		wantErr bool
+	}{
+		{name: "empty", want: []string{}},
+		{name: "single IPv4 address", list: []string{"10.0.0.5"}, want: []string{"10.0.0.5/32"}},
+		{name: "single IPv6 address", list: []string{"::1"}, want: []string{"::1/128"}},
+		{name: "CIDR", list: []string{"172.16.0.0/12", "fc00::/7"}, want: []string{"172.16.0.0/12", "fc00::/7"}},
+		{name: "invalid address", list: []string{"load-balancer"}, wantErr: true},
+		{name: "invalid CIDR", list: []string{"10.0.0.0/33"}, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			nets, err := ParseTrustedProxies(tt.list)
+
+			if tt.wantErr {
+				assert.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			got := make([]string, 0, len(nets))
+			for _, n := range nets {
+				got = append(got, n.String())
+			}
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
+
+func TestSetForwardedHeaders(t *testing.T) {
+	trusted, err := ParseTrustedProxies([]string{"10.0.0.0/8", "fd00::/8"})
+	require.NoError(t, err)
+
+	tests := []struct {
+		name          string
+		remoteAddr    string
+		host          string
+		tls           bool
+		header        http.Header
+		wantFor       string
+		wantProto     string
+		wantHost      string
+		wantForwarded string
+	}{
+		{
+			name:          "direct client",
+			remoteAddr:    "203.0.113.7:51234",
+			host:          "api.example.com",
+			wantFor:       "203.0.113.7",
+			wantProto:     "http",
+			wantHost:      "api.example.com",
+			wantForwarded: "for=203.0.113.7;host=api.example.com;proto=http",
+		},
+		{
+			name:          "direct client over TLS",
+			remoteAddr:    "203.0.113.7:51234",
+			host:          "api.example.com",
+			tls:           true,
+			wantFor:       "203.0.113.7",
+			wantProto:     "https",
+			wantHost:      "api.example.com",
+			wantForwarded: "for=203.0.113.7;host=api.example.com;proto=https",
+		},
+		{
+			// 客戶端自帶的值一律丟棄，避免偽造來源 IP
+			name:       "untrusted peer values are replaced",
+			remoteAddr: "203.0.113.7:51234",
+			host:       "api.example.com",
+			header: http.Header{
+				"X-Forwarded-For":   {"198.51.100.1"},
+				"X-Forwarded-Proto": {"https"},
+				"X-Forwarded-Host":  {"admin.example.com"},
+				"Forwarded":         {"for=198.51.100.1"},
+			},
+			wantFor:       "203.0.113.7",
+			wantProto:     "http",
+			wantHost:      "api.example.com",
+			wantForwarded: "for=203.0.113.7;host=api.example.com;proto=http",
+		},
+		{
+			name:       "trusted peer values are appended",
+			remoteAddr: "10.0.0.5:41234",
+			host:       "gateway:8080",
+			header: http.Header{
+				"X-Forwarded-For":   {"198.51.100.1, 203.0.113.7"},
+				"X-Forwarded-Proto": {"https"},
+				"X-Forwarded-Host":  {"api.example.com"},
+				"Forwarded":         {"for=203.0.113.7;proto=https"},
+			},
+			wantFor:       "198.51.100.1, 203.0.113.7, 10.0.0.5",
+			wantProto:     "https",
+			wantHost:      "api.example.com",
+			wantForwarded: "for=203.0.113.7;proto=https, for=10.0.0.5;host=api.example.com;proto=https",
+		},
+		{
+			name:       "trusted peer with repeated headers",
+			remoteAddr: "10.0.0.5:41234",
+			host:       "api.example.com",
+			header: http.Header{
+				"X-Forwarded-For": {"198.51.100.1", "203.0.113.7"},
+			},
+			wantFor:       "198.51.100.1, 203.0.113.7, 10.0.0.5",
+			wantProto:     "http",
+			wantHost:      "api.example.com",
+			wantForwarded: "for=10.0.0.5;host=api.example.com;proto=http",
+		},
+		{
+			name:          "trusted peer without forwarded headers",
+			remoteAddr:    "10.0.0.5:41234",
+			host:          "api.example.com",
+			wantFor:       "10.0.0.5",
+			wantProto:     "http",
+			wantHost:      "api.example.com",
+			wantForwarded: "for=10.0.0.5;host=api.example.com;proto=http",
+		},
+		{
+			// IPv6 需加上中括號並以引號包住，含 port 的 host 也需要引號
+			name:          "IPv6 peer and host with port",
+			remoteAddr:    "[2001:db8::1]:51234",
+			host:          "api.example.com:8443",
+			wantFor:       "2001:db8::1",
+			wantProto:     "http",
+			wantHost:      "api.example.com:8443",
+			wantForwarded: `for="[2001:db8::1]";host="api.example.com:8443";proto=http`,
+		},
+		{
+			name:          "trusted IPv6 peer",
+			remoteAddr:    "[fd00::5]:41234",
+			host:          "api.example.com",
+			header:        http.Header{"X-Forwarded-For": {"203.0.113.7"}},
+			wantFor:       "203.0.113.7, fd00::5",
+			wantProto:     "http",
+			wantHost:      "api.example.com",
+			wantForwarded: `for="[fd00::5]";host=api.example.com;proto=http`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p := New(WithTrustedProxies(trusted))
+			in := httptest.NewRequest("GET", "/api/users/me", nil)
+			in.RemoteAddr = tt.remoteAddr
+			in.Host = tt.host
+			if tt.tls {
+				in.TLS = &tls.ConnectionState{}
+			}
+			for name, values := range tt.header {
+				in.Header[name] = values
+			}
+
+			out := http.Header{}
+			p.setForwardedHeaders(out, in)
+
+			assert.Equal(t, tt.wantFor, out.Get("X-Forwarded-For"))
+			assert.Equal(t, tt.wantProto, out.Get("X-Forwarded-Proto"))
+			assert.Equal(t, tt.wantHost, out.Get("X-Forwarded-Host"))
+			assert.Equal(t, tt.wantForwarded, out.Get("Forwarded"))
+		})
+	}
+}
+
+func TestTrustedPeer(t *testing.T) {
+	trusted, err := ParseTrustedProxies([]string{"10.0.0.0/8", "192.168.1.10"})
+	require.NoError(t, err)
+	p := New(WithTrustedProxies(trusted))
+
+	tests := []struct {
+		ip   string
+		want bool
+	}{
+		{ip: "10.1.2.3", want: true},
+		{ip: "192.168.1.10", want: true},
+		{ip: "192.168.1.11", want: false},
+		{ip: "203.0.113.7", want: false},
+		{ip: "not-an-ip", want: false},
+		{ip: "", want: false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.ip, func(t *testing.T) {
+			assert.Equal(t, tt.want, p.trustedPeer(tt.ip))
+		})
+	}
+
+	assert.False(t, New().trustedPeer("10.1.2.3"), "no proxy is trusted by default")
+}
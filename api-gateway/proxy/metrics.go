@@ -0,0 +1,96 @@
+package proxy
+
+import (
+	"context"
+	"net"
+	"net/http/httptrace"
+	"strconv"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"google.golang.org/grpc"
+)
+
+// Metrics 記錄轉發到上游的連線池使用狀況，label upstream 為上游服務名稱；
+// HTTP 轉發與 gRPC 呼叫共用同一組指標，gRPC 適用的範圍見 GRPCDialOptions
+//
+// 開啟中的連線數接近 max_conns_per_host、或 reused="false" 的比例偏高（閒置連線不夠用）時，
+// 應調高對應上游的連線設定
+type Metrics struct {
+	open     *prometheus.GaugeVec
+	dials    *prometheus.CounterVec
+	acquired *prometheus.CounterVec
+	inFlight *prometheus.GaugeVec
+	limits   *prometheus.GaugeVec
+}
+
+// NewMetrics 建立 Metrics 並註冊到 reg
+func NewMetrics(reg prometheus.Registerer) *Metrics {
+	m := &Metrics{
+		open: prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Name: "gateway_upstream_connections_open",
+			Help: "Number of open connections to the upstream, idle or in use.",
+		}, []string{"upstream"}),
+		dials: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "gateway_upstream_dials_total",
+			Help: "Total number of connection attempts to the upstream.",
+		}, []string{"upstream", "result"}),
+		acquired: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "gateway_upstream_connections_acquired_total",
+			Help: "Total number of connections obtained for upstream requests, by whether an existing connection was reused.",
+		}, []string{"upstream", "reused"}),
+		inFlight: prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Name: "gateway_upstream_requests_in_flight",
+			Help: "Number of requests currently being forwarded to the upstream.",
+		}, []string{"upstream"}),
+		limits: prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Name: "gateway_upstream_pool_limit",
+			Help: "Configured connection pool limits per upstream host; 0 means unlimited.",
+		}, []string{"upstream", "limit"}),
+	}
+	reg.MustRegister(m.open, m.dials, m.acquired, m.inFlight, m.limits)
+	return m
+}
+
+// setLimits 記錄上游的連線設定，讓 dashboard 可以算出使用率
+func (m *Metrics) setLimits(upstream string, cfg TransportConfig) {
+	m.limits.WithLabelValues(upstream, "max_idle_conns_per_host").Set(float64(cfg.MaxIdleConnsPerHost))
+	m.limits.WithLabelValues(upstream, "max_conns_per_host").Set(float64(cfg.MaxConnsPerHost))
+}
+
+// dialer 包裝 dial，記錄連線的建立結果與開啟中的連線數
+func (m *Metrics) dialer(upstream string, dial func(ctx context.Context, network, addr string) (net.Conn, error)) func(ctx context.Context, network, addr string) (net.Conn, error) {
+	open := m.open.WithLabelValues(upstream)
+	return func(ctx context.Context, network, addr string) (net.Conn, error) {
+		conn, err := dial(ctx, network, addr)
+		if err != nil {
+			m.dials.WithLabelValues(upstream, "error").Inc()
+			return nil, err
+		}
+		m.dials.WithLabelValues(upstream, "success").Inc()
+		open.Inc()
+		return &countedConn{Conn: conn, onClose: open.Dec}, nil
+	}
+}
+
+// track 在一個轉發請求開始時呼叫：記錄進行中的請求數，並透過 httptrace 記錄取得的連線是否為重複使用。
+// 回傳的函式需在請求結束時呼叫
+func (m *Metrics) track(ctx context.Context, upstream string) (context.Context, func()) {
+	inFlight := m.inFlight.WithLabelValues(upstream)
+	inFlight.Inc()
+	ctx = httptrace.WithClientTrace(ctx, &httptrace.ClientTrace{
+		GotConn: func(info httptrace.GotConnInfo) {
+			m.acquired.WithLabelValues(upstream, strconv.FormatBool(info.Reused)).Inc()
+		},
+	})
+	return ctx, inFlight.Dec
+}
+
+// UnaryClientInterceptor 記錄進行中的 gRPC 呼叫數，與 HTTP 轉發共用 gateway_upstream_requests_in_flight
+func (m *Metrics) UnaryClientInterceptor(upstream string) grpc.UnaryClientInterceptor {
+	inFlight := m.inFlight.WithLabelValues(upstream)
+	return func(ctx context.Context, method string, req, reply interface{}, cc *grpc.ClientConn, invoker grpc.UnaryInvoker, opts ...grpc.CallOption) error {
+		inFlight.Inc()
+		defer inFlight.Dec()
+		return invoker(ctx, method, req, reply, cc, opts...)
+	}
+}
@@ -0,0 +1,144 @@
+package proxy
+
+import (
+	"context"
+	"errors"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials/insecure"
+	grpchealth "google.golang.org/grpc/health"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+)
+
+func TestMetricsForward(t *testing.T) {
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`{}`))
+	}))
+	defer upstream.Close()
+
+	m := NewMetrics(prometheus.NewRegistry())
+	cfg := DefaultTransportConfig()
+	cfg.MaxConnsPerHost = 64
+	p := New(WithTransport(cfg), WithMetrics(m, "user-service"))
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.Any("/api/users/*path", p.Forward(upstream.URL, "/api/users"))
+
+	for i := 0; i < 2; i++ {
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, httptest.NewRequest("GET", "/api/users/me", nil))
+		require.Equal(t, http.StatusOK, w.Code)
+	}
+
+	// 第二個請求重複使用第一個請求的連線
+	assert.Equal(t, 1.0, testutil.ToFloat64(m.dials.WithLabelValues("user-service", "success")))
+	assert.Equal(t, 1.0, testutil.ToFloat64(m.open.WithLabelValues("user-service")))
+	assert.Equal(t, 1.0, testutil.ToFloat64(m.acquired.WithLabelValues("user-service", "false")))
+	assert.Equal(t, 1.0, testutil.ToFloat64(m.acquired.WithLabelValues("user-service", "true")))
+	assert.Equal(t, 0.0, testutil.ToFloat64(m.inFlight.WithLabelValues("user-service")))
+	assert.Equal(t, 32.0, testutil.ToFloat64(m.limits.WithLabelValues("user-service", "max_idle_conns_per_host")))
+	assert.Equal(t, 64.0, testutil.ToFloat64(m.limits.WithLabelValues("user-service", "max_conns_per_host")))
+
+	p.client.CloseIdleConnections()
+	assert.Equal(t, 0.0, testutil.ToFloat64(m.open.WithLabelValues("user-service")))
+}
+
+func TestMetricsDialError(t *testing.T) {
+	upstream := httptest.NewServer(http.NotFoundHandler())
+	upstream.Close() // 位址已經沒有人在聽
+
+	m := NewMetrics(prometheus.NewRegistry())
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.Any("/api/users/*path", New(WithMetrics(m, "user-service")).Forward(upstream.URL, "/api/users"))
+
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest("GET", "/api/users/me", nil))
+
+	assert.Equal(t, http.StatusBadGateway, w.Code)
+	assert.Equal(t, 1.0, testutil.ToFloat64(m.dials.WithLabelValues("user-service", "error")))
+	assert.Equal(t, 0.0, testutil.ToFloat64(m.open.WithLabelValues("user-service")))
+	assert.Equal(t, 0.0, testutil.ToFloat64(m.inFlight.WithLabelValues("user-service")))
+}
+
+func TestCountedConnClosesOnce(t *testing.T) {
+	client, server := net.Pipe()
+	defer server.Close()
+	closed := 0
+	conn := &countedConn{Conn: client, onClose: func() { closed++ }}
+
+	conn.Close()
+	conn.Close()
+
+	assert.Equal(t, 1, closed)
+}
+
+func TestUnaryClientInterceptor(t *testing.T) {
+	errUpstream := errors.New("upstream failed")
+
+	tests := []struct {
+		name string
+		err  error
+	}{
+		{name: "success"},
+		{name: "error is passed through", err: errUpstream},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			m := NewMetrics(prometheus.NewRegistry())
+			inFlight := m.inFlight.WithLabelValues("user-service")
+
+			var during float64
+			err := m.UnaryClientInterceptor("user-service")(context.Background(), "/user.v1.UserService/GetUser", nil, nil, nil,
+				func(ctx context.Context, method string, req, reply interface{}, cc *grpc.ClientConn, opts ...grpc.CallOption) error {
+					during = testutil.ToFloat64(inFlight)
+					return tt.err
+				})
+
+			assert.ErrorIs(t, err, tt.err)
+			assert.Equal(t, 1.0, during)
+			assert.Equal(t, 0.0, testutil.ToFloat64(inFlight))
+		})
+	}
+}
+
+func TestGRPCDialOptions(t *testing.T) {
+	lis, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	server := grpc.NewServer()
+	healthpb.RegisterHealthServer(server, grpchealth.NewServer())
+	go server.Serve(lis)
+	defer server.Stop()
+
+	m := NewMetrics(prometheus.NewRegistry())
+	opts := append(GRPCDialOptions(DefaultTransportConfig(), "user-service", m),
+		grpc.WithTransportCredentials(insecure.NewCredentials()))
+	conn, err := grpc.Dial(lis.Addr().String(), opts...)
+	require.NoError(t, err)
+
+	// 多個呼叫共用同一條連線
+	for i := 0; i < 3; i++ {
+		_, err = healthpb.NewHealthClient(conn).Check(context.Background(), &healthpb.HealthCheckRequest{})
+		require.NoError(t, err)
+	}
+
+	assert.Equal(t, 1.0, testutil.ToFloat64(m.dials.WithLabelValues("user-service", "success")))
+	assert.Equal(t, 1.0, testutil.ToFloat64(m.open.WithLabelValues("user-service")))
+	assert.Equal(t, 0.0, testutil.ToFloat64(m.inFlight.WithLabelValues("user-service")))
+
+	require.NoError(t, conn.Close())
+	assert.Eventually(t, func() bool {
+		return testutil.ToFloat64(m.open.WithLabelValues("user-service")) == 0
+	}, time.Second, 10*time.Millisecond)
+}
@@ -2,26 +2,99 @@ package proxy
 
 import (
 	"bytes"
+	"context"
+	"errors"
 	"io"
+	"log"
+	"net"
 	"net/http"
+	"strconv"
 	"strings"
 	"time"
 
+	"api-gateway/compression"
+
 	"github.com/gin-gonic/gin"
 )
 
-// Proxy 持有一個共用的 HTTP client，用來將請求轉發給下游服務。
+// DefaultTimeout 未設定時整個請求（含讀完 response）的逾時
+const DefaultTimeout = 10 * time.Second
+
+// RequestTimeoutHeader 告知下游這個請求還剩多少毫秒，下游可以據此放棄 gateway 已經不再等待的工作；
+// 需與 user-service middleware.RequestTimeoutHeader 相同
+const RequestTimeoutHeader = "X-Request-Timeout"
+
+// Proxy 持有一個共用的 HTTP client，用來將請求轉發給同一個上游服務。
 // 共用同一個 client 是為了讓 TCP connection pool 能夠被重複利用，避免每次請求都重新建立連線。
 type Proxy struct {
-	client *http.Client
+	client    *http.Client
+	signer    *Signer
+	timeout   time.Duration
+	transport TransportConfig
+	metrics   *Metrics
+	upstream  string
+	// 直接連到 gateway 的來源在這些網段內時，才沿用它帶來的 X-Forwarded-* 與 Forwarded
+	trustedProxies []*net.IPNet
+}
+
+// Option 用來調整 Proxy 的選用設定
+type Option func(*Proxy)
+
+// WithSigner 讓每個轉發出去的請求都帶上 gateway 簽章
+func WithSigner(signer *Signer) Option {
+	return func(p *Proxy) {
+		p.signer = signer
+	}
 }
 
-func New() *Proxy {
-	return &Proxy{
-		client: &http.Client{Timeout: 10 * time.Second},
+// WithTimeout 設定這個上游每個請求的逾時。
+// 路由已經以 middleware.Timeout 設定 deadline 時，以路由的 deadline 為準。
+func WithTimeout(timeout time.Duration) Option {
+	return func(p *Proxy) {
+		p.timeout = timeout
 	}
 }
 
+// WithTransport 設定這個上游的連線池，未設定時使用 DefaultTransportConfig
+func WithTransport(cfg TransportConfig) Option {
+	return func(p *Proxy) {
+		p.transport = cfg
+	}
+}
+
+// WithMetrics 將連線池的使用狀況記錄到 m，upstream 為指標的 label
+func WithMetrics(m *Metrics, upstream string) Option {
+	return func(p *Proxy) {
+		p.metrics = m
+		p.upstream = upstream
+	}
+}
+
+// WithTrustedProxies 設定信任的 proxy 網段，需與 gin Engine.SetTrustedProxies 使用同一份清單，
+// ClientIP 與轉發出去的 X-Forwarded-For 才會一致
+func WithTrustedProxies(nets []*net.IPNet) Option {
+	return func(p *Proxy) {
+		p.trustedProxies = nets
+	}
+}
+
+func New(opts ...Option) *Proxy {
+	p := &Proxy{
+		timeout:   DefaultTimeout,
+		transport: DefaultTransportConfig(),
+	}
+	for _, opt := range opts {
+		opt(p)
+	}
+
+	// 逾時改由每個請求的 context 控制，http.Client 本身不設 Timeout，否則會蓋過路由設定的較長 deadline
+	p.client = &http.Client{Transport: newTransport(p.transport, p.upstream, p.metrics)}
+	if p.metrics != nil {
+		p.metrics.setLimits(p.upstream, p.transport)
+	}
+	return p
+}
+
 // Forward 回傳一個 Gin handler，將收到的請求轉發到 targetBaseURL，
 // 並在轉發前將 pathPrefix 從路徑中去除。
 //
@@ -31,23 +104,55 @@ func New() *Proxy {
 //	去除前綴：/api
 //	轉發目標：GET  http://user-service:8081/users/123
 func (p *Proxy) Forward(targetBaseURL, pathPrefix string) gin.HandlerFunc {
+	return p.ForwardTo(func() (string, error) { return targetBaseURL, nil }, pathPrefix)
+}
+
+// ForwardTo 與 Forward 相同，但每個請求都呼叫 resolve 取得目標 base URL，
+// 用於從 service registry 動態挑選上游實例
+func (p *Proxy) ForwardTo(resolve func() (string, error), pathPrefix string) gin.HandlerFunc {
 	return func(c *gin.Context) {
-		// ── 1. 重寫路徑：去掉 gateway 前綴 ────────────────────────────────
+		// ── 1. 挑選上游並重寫路徑：去掉 gateway 前綴 ──────────────────────
+		targetBaseURL, err := resolve()
+		if err != nil {
+			log.Printf("[Gateway] 找不到上游實例 path=%s err=%v", c.Request.URL.Path, err)
+			c.JSON(http.StatusServiceUnavailable, gin.H{"error": "下游服務暫時無法使用"})
+			return
+		}
 		servicePath := strings.TrimPrefix(c.Request.URL.Path, pathPrefix)
 		targetURL := targetBaseURL + servicePath
 		if c.Request.URL.RawQuery != "" {
 			targetURL += "?" + c.Request.URL.RawQuery
 		}
 
+		// 沒有路由 deadline 時套用這個上游的逾時
+		ctx, cancel := p.requestContext(c.Request.Context())
+		defer cancel()
+		if p.metrics != nil {
+			var done func()
+			ctx, done = p.metrics.track(ctx, p.upstream)
+			defer done()
+		}
+		deadline, _ := ctx.Deadline()
+		remaining := time.Until(deadline)
+		if remaining <= 0 {
+			gatewayTimeout(c, targetURL, context.DeadlineExceeded)
+			return
+		}
+
 		// ── 2. 讀取請求 body ───────────────────────────────────────────────
 		var bodyBytes []byte
 		if c.Request.Body != nil {
-			bodyBytes, _ = io.ReadAll(c.Request.Body)
+			// 讀到一半失敗（客戶端斷線等）時不能轉發不完整的 body，簽章也會對不上
+			if bodyBytes, err = io.ReadAll(c.Request.Body); err != nil {
+				log.Printf("[Gateway] 讀取請求 body 失敗 path=%s err=%v", c.Request.URL.Path, err)
+				c.JSON(http.StatusBadGateway, gin.H{"error": "讀取請求失敗"})
+				return
+			}
 		}
 
 		// ── 3. 建立對下游服務的新請求 ──────────────────────────────────────
 		req, err := http.NewRequestWithContext(
-			c.Request.Context(),
+			ctx,
 			c.Request.Method,
 			targetURL,
 			bytes.NewBuffer(bodyBytes),
@@ -57,16 +162,33 @@ func (p *Proxy) Forward(targetBaseURL, pathPrefix string) gin.HandlerFunc {
 			return
 		}
 
-		// 轉送原始 headers（Authorization、Content-Type 等）
+		// 轉送原始 headers（Authorization、Content-Type 等），hop-by-hop header 只屬於客戶端與 gateway 之間的連線
 		for key, values := range c.Request.Header {
 			for _, value := range values {
 				req.Header.Add(key, value)
 			}
 		}
+		removeHopByHop(req.Header)
+		// 下游看到的連線來源是 gateway，改由 header 告知真正的客戶端 IP（寫入稽核紀錄用）
+		p.setForwardedHeaders(req.Header, c.Request)
+		req.Header.Set(ClientIPHeader, c.ClientIP())
+		// 客戶端自帶的值一律覆蓋，下游看到的剩餘時間只來自 gateway
+		req.Header.Set(RequestTimeoutHeader, strconv.FormatInt(remaining.Milliseconds(), 10))
+
+		// 客戶端自帶的簽章一律覆蓋，下游只信任 gateway 簽過的請求
+		req.Header.Del(gatewayTimestampHeader)
+		req.Header.Del(gatewaySignatureHeader)
+		if p.signer != nil {
+			p.signer.Sign(req, bodyBytes)
+		}
 
 		// ── 4. 發送請求到下游服務 ──────────────────────────────────────────
 		resp, err := p.client.Do(req)
 		if err != nil {
+			if isTimeout(err) {
+				gatewayTimeout(c, targetURL, err)
+				return
+			}
 			c.JSON(http.StatusBadGateway, gin.H{"error": "下游服務無法連線"})
 			return
 		}
@@ -75,10 +197,71 @@ func (p *Proxy) Forward(targetBaseURL, pathPrefix string) gin.HandlerFunc {
 		// ── 5. 將下游的 response 原封不動回傳給前端 ────────────────────────
 		respBody, err := io.ReadAll(resp.Body)
 		if err != nil {
+			if isTimeout(err) {
+				gatewayTimeout(c, targetURL, err)
+				return
+			}
 			c.JSON(http.StatusInternalServerError, gin.H{"error": "讀取回應失敗"})
 			return
 		}
 
+		// 下游已經壓縮時原封不動轉送（gateway 的 Compress 不會重複壓縮）；
+		// 只有客戶端不接受下游用的編碼時才解壓縮，交給 Compress 改用客戶端接受的編碼
+		if ce := resp.Header.Get("Content-Encoding"); ce != "" && !strings.EqualFold(ce, "identity") {
+			// 送出的編碼取決於 Accept-Encoding，快取需依此區分
+			if vary := strings.ToLower(strings.Join(resp.Header.Values("Vary"), ",")); !strings.Contains(vary, "accept-encoding") && !strings.Contains(vary, "*") {
+				resp.Header.Add("Vary", "Accept-Encoding")
+			}
+			if !compression.Accepts(c.GetHeader("Accept-Encoding"), strings.ToLower(ce)) {
+				decoded, err := decode(ce, respBody)
+				if err != nil {
+					log.Printf("[Gateway] 無法解壓縮下游回應 target=%s encoding=%s err=%v", targetURL, ce, err)
+					c.JSON(http.StatusBadGateway, gin.H{"error": "無法解讀下游服務的回應"})
+					return
+				}
+				respBody = decoded
+				resp.Header.Del("Content-Encoding")
+				resp.Header.Del("Content-Length")
+			}
+		}
+
+		// 轉送下游的 response headers（ETag 等），讓前端能做條件式請求
+		removeHopByHop(resp.Header)
+		for key, values := range resp.Header {
+			for _, value := range values {
+				c.Writer.Header().Add(key, value)
+			}
+		}
 		c.Data(resp.StatusCode, resp.Header.Get("Content-Type"), respBody)
 	}
 }
+
+// requestContext 請求已經帶有 deadline（由 middleware.Timeout 設定）時沿用，否則加上這個上游的逾時
+func (p *Proxy) requestContext(parent context.Context) (context.Context, context.CancelFunc) {
+	if _, ok := parent.Deadline(); ok {
+		return context.WithCancel(parent)
+	}
+	return context.WithTimeout(parent, p.timeout)
+}
+
+// isTimeout 判斷錯誤是否來自 deadline 或連線逾時；客戶端自己斷線（context.Canceled）不算
+func isTimeout(err error) bool {
+	var netErr net.Error
+	return errors.Is(err, context.DeadlineExceeded) || (errors.As(err, &netErr) && netErr.Timeout())
+}
+
+// gatewayTimeout 上游沒有在時限內回應時回 504，與一般的連線失敗（502）區分
+func gatewayTimeout(c *gin.Context, target string, err error) {
+	log.Printf("[Gateway] 上游逾時 target=%s err=%v", target, err)
+	c.JSON(http.StatusGatewayTimeout, gin.H{"error": "下游服務逾時"})
+}
+
+// decode 解壓縮整個 body
+func decode(encoding string, body []byte) ([]byte, error) {
+	r, err := compression.NewReader(encoding, bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	defer r.Close()
+	return io.ReadAll(r)
+}
@@ -0,0 +1,250 @@
+package proxy
+
+import (
+	"bytes"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"api-gateway/compression"
+)
+
+// encodeBody 以 encoding 壓縮 body；deflate 不是 gateway 會產生的編碼，測試不需要
+func encodeBody(t *testing.T, encoding, body string) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	w, err := compression.NewWriter(encoding, &buf)
+	require.NoError(t, err)
+	_, err = io.WriteString(w, body)
+	require.NoError(t, err)
+	require.NoError(t, w.Close())
+	return buf.Bytes()
+}
+
+// setupProxyRouter 將 /api/users/* 轉發到 upstream
+func setupProxyRouter(upstream string) *gin.Engine {
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	r.Any("/api/users/*path", New().Forward(upstream, "/api/users"))
+	return r
+}
+
+func TestForwardCompressedUpstream(t *testing.T) {
+	const body = `{"id":"uuid-001","email":"user@example.com"}`
+
+	tests := []struct {
+		name             string
+		upstreamEncoding string
+		upstreamBody     []byte
+		acceptEncoding   string
+		wantCode         int
+		wantEncoding     string
+		wantBody         []byte
+	}{
+		{
+			name:             "client accepts upstream encoding",
+			upstreamEncoding: compression.Gzip,
+			upstreamBody:     encodeBody(t, compression.Gzip, body),
+			acceptEncoding:   "br, gzip",
+			wantCode:         http.StatusOK,
+			wantEncoding:     compression.Gzip,
+			wantBody:         encodeBody(t, compression.Gzip, body),
+		},
+		{
+			name:             "client does not accept upstream encoding",
+			upstreamEncoding: compression.Zstd,
+			upstreamBody:     encodeBody(t, compression.Zstd, body),
+			acceptEncoding:   "gzip",
+			wantCode:         http.StatusOK,
+			wantBody:         []byte(body),
+		},
+		{
+			name:             "client rejects upstream encoding with q=0",
+			upstreamEncoding: compression.Brotli,
+			upstreamBody:     encodeBody(t, compression.Brotli, body),
+			acceptEncoding:   "br;q=0, *",
+			wantCode:         http.StatusOK,
+			wantBody:         []byte(body),
+		},
+		{
+			name:             "no accept-encoding",
+			upstreamEncoding: "GZIP",
+			upstreamBody:     encodeBody(t, compression.Gzip, body),
+			wantCode:         http.StatusOK,
+			wantBody:         []byte(body),
+		},
+		{
+			name:             "identity is not decoded",
+			upstreamEncoding: "identity",
+			upstreamBody:     []byte(body),
+			wantCode:         http.StatusOK,
+			wantEncoding:     "identity",
+			wantBody:         []byte(body),
+		},
+		{
+			name:             "corrupt upstream body",
+			upstreamEncoding: compression.Gzip,
+			upstreamBody:     []byte("not gzip"),
+			wantCode:         http.StatusBadGateway,
+		},
+		{
+			name:             "unsupported upstream encoding",
+			upstreamEncoding: "compress",
+			upstreamBody:     []byte(body),
+			wantCode:         http.StatusBadGateway,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				w.Header().Set("Content-Type", "application/json")
+				w.Header().Set("Content-Encoding", tt.upstreamEncoding)
+				w.Write(tt.upstreamBody)
+			}))
+			defer upstream.Close()
+
+			w := httptest.NewRecorder()
+			r, _ := http.NewRequest("GET", "/api/users/me", nil)
+			if tt.acceptEncoding != "" {
+				r.Header.Set("Accept-Encoding", tt.acceptEncoding)
+			}
+			setupProxyRouter(upstream.URL).ServeHTTP(w, r)
+
+			assert.Equal(t, tt.wantCode, w.Code)
+			if tt.wantCode != http.StatusOK {
+				return
+			}
+			assert.Equal(t, tt.wantEncoding, w.Header().Get("Content-Encoding"))
+			assert.Equal(t, tt.wantBody, w.Body.Bytes())
+			if tt.upstreamEncoding != "identity" {
+				// 送出的編碼取決於 Accept-Encoding，快取需依此區分
+				assert.Equal(t, []string{"Accept-Encoding"}, w.Header().Values("Vary"))
+			}
+		})
+	}
+}
+
+func TestForwardClientIP(t *testing.T) {
+	tests := []struct {
+		name       string
+		remoteAddr string
+		header     http.Header
+		trusted    []string
+		want       string
+	}{
+		{
+			name:       "direct client",
+			remoteAddr: "203.0.113.7:51234",
+			want:       "203.0.113.7",
+		},
+		{
+			name:       "client supplied value is overwritten",
+			remoteAddr: "203.0.113.7:51234",
+			header:     http.Header{"X-Client-Ip": {"198.51.100.1"}, "X-Forwarded-For": {"198.51.100.1"}},
+			want:       "203.0.113.7",
+		},
+		{
+			name:       "resolved through a trusted proxy",
+			remoteAddr: "10.0.0.5:41234",
+			header:     http.Header{"X-Forwarded-For": {"198.51.100.1, 203.0.113.7"}},
+			trusted:    []string{"10.0.0.0/8"},
+			want:       "203.0.113.7",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var got string
+			upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				got = r.Header.Get(ClientIPHeader)
+			}))
+			defer upstream.Close()
+
+			nets, err := ParseTrustedProxies(tt.trusted)
+			require.NoError(t, err)
+			gin.SetMode(gin.TestMode)
+			router := gin.New()
+			require.NoError(t, router.SetTrustedProxies(tt.trusted))
+			router.Any("/api/users/*path", New(WithTrustedProxies(nets)).Forward(upstream.URL, "/api/users"))
+
+			w := httptest.NewRecorder()
+			r, _ := http.NewRequest("GET", "/api/users/me", nil)
+			r.RemoteAddr = tt.remoteAddr
+			for name, values := range tt.header {
+				r.Header[name] = values
+			}
+			router.ServeHTTP(w, r)
+
+			assert.Equal(t, http.StatusOK, w.Code)
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
+
+func TestSignCoversClientIP(t *testing.T) {
+	s := NewSigner("internal-secret")
+	fixed := time.Unix(1704110400, 0)
+	s.now = func() time.Time { return fixed }
+
+	header := http.Header{}
+	header.Set(ClientIPHeader, "203.0.113.7")
+	_, original := s.sign("GET", "/users/me", "", nil, header)
+	header.Set(ClientIPHeader, "198.51.100.1")
+	_, tampered := s.sign("GET", "/users/me", "", nil, header)
+
+	assert.NotEqual(t, original, tampered)
+}
+
+// failingBody 讀到一半失敗的請求 body，模擬客戶端在上傳途中斷線
+type failingBody struct{}
+
+func (failingBody) Read([]byte) (int, error) { return 0, io.ErrUnexpectedEOF }
+
+func TestForwardBodyReadError(t *testing.T) {
+	called := false
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		called = true
+	}))
+	defer upstream.Close()
+
+	w := httptest.NewRecorder()
+	r, _ := http.NewRequest("POST", "/api/users/register", failingBody{})
+	setupProxyRouter(upstream.URL).ServeHTTP(w, r)
+
+	assert.Equal(t, http.StatusBadGateway, w.Code)
+	assert.JSONEq(t, `{"error":"讀取請求失敗"}`, w.Body.String())
+	assert.False(t, called, "an incomplete body must not be forwarded")
+}
+
+func TestForwardStripsHopByHop(t *testing.T) {
+	var got http.Header
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		got = r.Header.Clone()
+		w.Header().Set("Connection", "X-Upstream-Debug")
+		w.Header().Set("X-Upstream-Debug", "1")
+		w.Header().Set("ETag", `"3"`)
+	}))
+	defer upstream.Close()
+
+	w := httptest.NewRecorder()
+	r, _ := http.NewRequest("GET", "/api/users/me", nil)
+	r.Header.Set("Connection", "X-Client-Debug")
+	r.Header.Set("X-Client-Debug", "1")
+	r.Header.Set("Proxy-Authorization", "Basic abc")
+	r.Header.Set("Authorization", "Bearer token")
+	setupProxyRouter(upstream.URL).ServeHTTP(w, r)
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Empty(t, got.Get("X-Client-Debug"))
+	assert.Empty(t, got.Get("Proxy-Authorization"))
+	assert.Equal(t, "Bearer token", got.Get("Authorization"))
+	assert.Empty(t, w.Header().Get("X-Upstream-Debug"))
+	assert.Equal(t, `"3"`, w.Header().Get("ETag"))
+}
@@ -0,0 +1,122 @@
+package proxy
+
+import (
+	"context"
+	"crypto/tls"
+	"net"
+	"net/http"
+	"sync"
+	"time"
+
+	"golang.org/x/net/http2"
+	"google.golang.org/grpc"
+)
+
+// TransportConfig 一個上游的連線設定
+//
+// HTTP 轉發套用全部欄位；gRPC 連線（見 GRPCDialOptions）只套用 DialTimeout 與 KeepAlive
+type TransportConfig struct {
+	DialTimeout time.Duration // 建立 TCP 連線的上限
+	KeepAlive   time.Duration // TCP keep-alive 間隔；HTTP/2 時也是送 PING 檢查連線的間隔
+	// 每個上游實例保留的閒置連線數；net/http 預設只有 2，高併發時大部分連線用完就關，
+	// 大量 TIME_WAIT 的 socket 會耗盡本機 port
+	MaxIdleConnsPerHost int
+	MaxConnsPerHost     int           // 每個上游實例的連線上限（含使用中），0 表示不限制
+	IdleConnTimeout     time.Duration // 閒置連線保留多久
+	// 以 HTTP/2 連到上游：http:// 上游使用 h2c（上游需開啟 h2c），https:// 上游以 ALPN 協商。
+	// HTTP/2 的請求共用同一條連線，MaxIdleConnsPerHost、MaxConnsPerHost 只對 HTTP/1.1 有效，
+	// IdleConnTimeout 對 h2c 無效
+	HTTP2 bool
+}
+
+// DefaultTransportConfig 未設定時的連線設定
+func DefaultTransportConfig() TransportConfig {
+	return TransportConfig{
+		DialTimeout:         2 * time.Second,
+		KeepAlive:           30 * time.Second,
+		MaxIdleConnsPerHost: 32,
+		MaxConnsPerHost:     0,
+		IdleConnTimeout:     90 * time.Second,
+		HTTP2:               false,
+	}
+}
+
+// newTransport 依設定建立 RoundTripper；metrics 不為 nil 時記錄連線的建立與關閉
+func newTransport(cfg TransportConfig, upstream string, metrics *Metrics) http.RoundTripper {
+	dialer := &net.Dialer{Timeout: cfg.DialTimeout, KeepAlive: cfg.KeepAlive}
+	dial := dialer.DialContext
+	if metrics != nil {
+		dial = metrics.dialer(upstream, dialer.DialContext)
+	}
+
+	t1 := http.DefaultTransport.(*http.Transport).Clone()
+	t1.DialContext = dial
+	t1.MaxIdleConns = 0 // 總數不限制，只以每個實例的上限控制
+	t1.MaxIdleConnsPerHost = cfg.MaxIdleConnsPerHost
+	t1.MaxConnsPerHost = cfg.MaxConnsPerHost
+	t1.IdleConnTimeout = cfg.IdleConnTimeout
+	t1.ForceAttemptHTTP2 = cfg.HTTP2
+	// 客戶端沒帶 Accept-Encoding 時不讓 Transport 自動要求 gzip 並偷偷解壓縮：
+	// 壓縮與解壓縮一律由 Forward 依客戶端的 Accept-Encoding 決定，Vary 與錯誤處理才會一致
+	t1.DisableCompression = true
+	if !cfg.HTTP2 {
+		return t1
+	}
+
+	// https 上游由 t1 以 ALPN 協商 HTTP/2；http:// 上游另外用 prior knowledge 的 h2c：
+	// AllowHTTP 加上不做 TLS 的 dial。h2c 連線不會因閒置而關閉，改以 PING 檢查連線是否還活著
+	t2 := &http2.Transport{
+		AllowHTTP: true,
+		DialTLSContext: func(ctx context.Context, network, addr string, _ *tls.Config) (net.Conn, error) {
+			return dial(ctx, network, addr)
+		},
+		ReadIdleTimeout:    cfg.KeepAlive,
+		DisableCompression: true,
+	}
+	return h2cTransport{h2c: t2, tls: t1}
+}
+
+// GRPCDialOptions 將 TransportConfig 中適用於 gRPC 的設定轉成 dial option：建立連線的逾時與 TCP keep-alive；
+// metrics 不為 nil 時記錄連線的建立與關閉，以及進行中的呼叫數
+//
+// gRPC 對每個上游實例只維持一條 HTTP/2 連線、所有呼叫在上面多工，連線池的設定
+// （MaxIdleConnsPerHost、MaxConnsPerHost、IdleConnTimeout、HTTP2）只對 HTTP 轉發有效；
+// 同樣的原因，gateway_upstream_connections_acquired_total 與 gateway_upstream_pool_limit 也只記錄 HTTP 轉發
+func GRPCDialOptions(cfg TransportConfig, upstream string, metrics *Metrics) []grpc.DialOption {
+	dialer := &net.Dialer{Timeout: cfg.DialTimeout, KeepAlive: cfg.KeepAlive}
+	dial := dialer.DialContext
+	var opts []grpc.DialOption
+	if metrics != nil {
+		dial = metrics.dialer(upstream, dialer.DialContext)
+		opts = append(opts, grpc.WithChainUnaryInterceptor(metrics.UnaryClientInterceptor(upstream)))
+	}
+	opts = append(opts, grpc.WithContextDialer(func(ctx context.Context, addr string) (net.Conn, error) {
+		return dial(ctx, "tcp", addr)
+	}))
+	return opts
+}
+
+// h2cTransport http:// 的請求走 h2c，其餘交給一般的 Transport
+type h2cTransport struct {
+	h2c *http2.Transport
+	tls *http.Transport
+}
+
+func (t h2cTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	if req.URL.Scheme == "http" {
+		return t.h2c.RoundTrip(req)
+	}
+	return t.tls.RoundTrip(req)
+}
+
+// countedConn 連線關閉時更新開啟中的連線數，Close 可能被呼叫多次，只算一次
+type countedConn struct {
+	net.Conn
+	once    sync.Once
+	onClose func()
+}
+
+func (c *countedConn) Close() error {
+	c.once.Do(c.onClose)
+	return c.Conn.Close()
+}
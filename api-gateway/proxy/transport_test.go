@@ -0,0 +1,87 @@
+package proxy
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/net/http2"
+	"golang.org/x/net/http2/h2c"
+)
+
+func TestNewTransport(t *testing.T) {
+	cfg := TransportConfig{
+		DialTimeout:         time.Second,
+		KeepAlive:           15 * time.Second,
+		MaxIdleConnsPerHost: 64,
+		MaxConnsPerHost:     128,
+		IdleConnTimeout:     time.Minute,
+	}
+
+	t.Run("http/1.1", func(t *testing.T) {
+		rt, ok := newTransport(cfg, "user-service", nil).(*http.Transport)
+		require.True(t, ok, "HTTP/1.1 uses a plain *http.Transport")
+
+		assert.Equal(t, 0, rt.MaxIdleConns)
+		assert.Equal(t, 64, rt.MaxIdleConnsPerHost)
+		assert.Equal(t, 128, rt.MaxConnsPerHost)
+		assert.Equal(t, time.Minute, rt.IdleConnTimeout)
+		assert.False(t, rt.ForceAttemptHTTP2)
+		assert.True(t, rt.DisableCompression)
+	})
+
+	t.Run("http/2", func(t *testing.T) {
+		h2 := cfg
+		h2.HTTP2 = true
+		rt, ok := newTransport(h2, "user-service", nil).(h2cTransport)
+		require.True(t, ok, "HTTP/2 routes http:// upstreams through h2c")
+
+		assert.True(t, rt.tls.ForceAttemptHTTP2)
+		assert.True(t, rt.tls.DisableCompression)
+		assert.True(t, rt.h2c.AllowHTTP)
+		assert.True(t, rt.h2c.DisableCompression)
+		// h2c 連線不會因閒置而關閉，以 PING 檢查連線
+		assert.Equal(t, 15*time.Second, rt.h2c.ReadIdleTimeout)
+	})
+}
+
+func TestForwardProtocol(t *testing.T) {
+	tests := []struct {
+		name      string
+		http2     bool
+		wantProto int
+	}{
+		{name: "http/1.1", wantProto: 1},
+		{name: "h2c", http2: true, wantProto: 2},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var proto int
+			var acceptEncoding []string
+			upstream := httptest.NewServer(h2c.NewHandler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				proto = r.ProtoMajor
+				acceptEncoding = r.Header.Values("Accept-Encoding")
+			}), &http2.Server{}))
+			defer upstream.Close()
+
+			cfg := DefaultTransportConfig()
+			cfg.HTTP2 = tt.http2
+			gin.SetMode(gin.TestMode)
+			router := gin.New()
+			router.Any("/api/users/*path", New(WithTransport(cfg)).Forward(upstream.URL, "/api/users"))
+
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, httptest.NewRequest("GET", "/api/users/me", nil))
+
+			assert.Equal(t, http.StatusOK, w.Code)
+			assert.Equal(t, tt.wantProto, proto)
+			// 客戶端沒帶 Accept-Encoding 時，Transport 不會自行要求 gzip
+			assert.Empty(t, acceptEncoding)
+		})
+	}
+}
@@ -2,57 +2,194 @@ package routes
 
 import (
 	"net/http"
-	"time"
+	"strings"
 
+	"api-gateway/cache"
 	"api-gateway/config"
+	"api-gateway/discovery"
+	"api-gateway/health"
 	"api-gateway/middleware"
 	"api-gateway/proxy"
+	"api-gateway/usergrpc"
 
 	"github.com/gin-contrib/cors"
 	"github.com/gin-gonic/gin"
 )
 
 // Setup 將所有 middleware 與路由掛載到 Gin engine 上。
-func Setup(r *gin.Engine, cfg *config.Config) {
-	p := proxy.New()
+// revocations 用於讓 RequireAuth 拒絕已被撤銷的 token。
+// userPool 提供 user-service 目前的實例，HTTP 轉發時每個請求輪流挑一個。
+// users 不為 nil 時，/api/users 路由改以 gRPC 呼叫 user-service，否則以 HTTP 直接轉發。
+// probes 提供 /livez、/readyz，關閉流程開始後 /health 也會回 503。
+// metrics 記錄轉發到 user-service 的連線池使用狀況。
+// responses 存放 cfg.CacheRoutes 開啟快取的 GET response。
+func Setup(r *gin.Engine, cfg *config.Config, revocations middleware.RevocationChecker, userPool *discovery.Pool, users *usergrpc.Translator, probes *health.Health, metrics *proxy.Metrics, responses cache.Store) {
+	// ClientIP 與轉發出去的 X-Forwarded-For 使用同一份信任清單；格式已由 config.Validate 檢查
+	_ = r.SetTrustedProxies(cfg.TrustedProxies)
+	trustedProxies, _ := proxy.ParseTrustedProxies(cfg.TrustedProxies)
+
+	p := proxy.New(
+		proxy.WithSigner(proxy.NewSigner(cfg.InternalSecret)),
+		proxy.WithTrustedProxies(trustedProxies),
+		proxy.WithTimeout(cfg.UserServiceTimeout),
+		proxy.WithTransport(proxy.TransportConfig{
+			DialTimeout:         cfg.UserServiceConnectTimeout,
+			KeepAlive:           cfg.UserServiceKeepAlive,
+			MaxIdleConnsPerHost: cfg.UserServiceMaxIdleConnsPerHost,
+			MaxConnsPerHost:     cfg.UserServiceMaxConnsPerHost,
+			IdleConnTimeout:     cfg.UserServiceIdleConnTimeout,
+			HTTP2:               cfg.UserServiceHTTP2,
+		}),
+		proxy.WithMetrics(metrics, cfg.UserServiceName),
+	)
+	forward := p.ForwardTo(userPool.NextHTTPAddr, "/api")
+
+	// user 選出 /api/users 路由的 handler：有 gRPC translator 時轉成對應的 RPC，否則轉發 HTTP
+	user := func(rpc func(*usergrpc.Translator, *gin.Context)) gin.HandlerFunc {
+		if users == nil {
+			return forward
+		}
+		return func(c *gin.Context) { rpc(users, c) }
+	}
 
 	// ── 全域 Middleware ──────────────────────────────────────────────────────
 	r.Use(gin.Recovery()) // 攔截 panic，回傳 500，避免整個服務崩潰
+	r.Use(middleware.StripIdentityHeaders()) // 身份 header 只能由 RequireAuth 寫入
+	r.Use(middleware.RequestID())            // 在 Logger 之前，讓 log 帶得到 request ID
 	r.Use(middleware.Logger())
-	r.Use(cors.New(cors.Config{
-		AllowOrigins:     []string{"http://localhost:3000"},
-		AllowMethods:     []string{"GET", "POST", "PUT", "DELETE", "OPTIONS"},
-		AllowHeaders:     []string{"Origin", "Content-Type", "Authorization"},
-		ExposeHeaders:    []string{"Content-Length"},
-		AllowCredentials: true,
-		MaxAge:           12 * time.Hour,
-	}))
+	if cfg.CompressionEnabled {
+		r.Use(middleware.Compress(middleware.CompressConfig{ // 包住之後所有 handler 寫出的 response
+			Encodings:    cfg.CompressionEncodings,
+			MinSize:      cfg.CompressionMinSize,
+			ContentTypes: cfg.CompressionContentTypes,
+		}))
+	}
+	corsRules := make([]middleware.CORSRule, 0, len(cfg.CORS.Routes))
+	for _, route := range cfg.CORS.Routes {
+		corsRules = append(corsRules, middleware.CORSRule{
+			PathPrefix: route.PathPrefix,
+			Config:     corsConfig(cfg.CORS.ForRoute(route)),
+		})
+	}
+	r.Use(middleware.CORS(corsConfig(cfg.CORS), corsRules...))
+	timeoutRules := make([]middleware.TimeoutRule, 0, len(cfg.RouteTimeouts))
+	for _, route := range cfg.RouteTimeouts {
+		timeoutRules = append(timeoutRules, middleware.TimeoutRule{PathPrefix: route.PathPrefix, Timeout: route.Timeout})
+	}
+	r.Use(middleware.Timeout(timeoutRules...)) // 路由的 deadline 優先於上游預設的逾時
+	bodyRules := make([]middleware.BodyRule, 0, len(cfg.BodyRoutes))
+	for _, route := range cfg.BodyRoutes {
+		route = cfg.ForBodyRoute(route)
+		bodyRules = append(bodyRules, middleware.BodyRule{
+			PathPrefix: route.PathPrefix,
+			Limit: middleware.BodyLimit{
+				MaxBytes:     int64(route.MaxBodyBytes),
+				ContentTypes: route.AllowedContentTypes,
+				MaxJSONDepth: route.MaxJSONDepth,
+			},
+		})
+	}
+	r.Use(middleware.BodyLimits(middleware.BodyLimit{ // 在轉發之前擋下過大或格式不符的 body
+		MaxBytes:     int64(cfg.MaxBodyBytes),
+		ContentTypes: cfg.AllowedContentTypes,
+		MaxJSONDepth: cfg.MaxJSONDepth,
+	}, bodyRules...))
+
+	// 快取掛在各個路由群組的驗證之後：KeyByUser 需要驗證過的用戶 ID，快取的內容也不能略過驗證
+	cacheRules := make([]middleware.CacheRule, 0, len(cfg.CacheRoutes))
+	for _, route := range cfg.CacheRoutes {
+		cacheRules = append(cacheRules, middleware.CacheRule{PathPrefix: route.PathPrefix, TTL: route.TTL, KeyByUser: route.KeyByUser})
+	}
+	cached := middleware.Cache(responses, cfg.CacheMaxEntryBytes, cacheRules...)
+	// 確認新 email 與還原用戶會改變 /api/users 底下的內容（含列表），但請求路徑不在其中，需另外清除
+	purgeUsers := middleware.PurgeCache(responses, "/api/users")
 
 	// ── Health Check ─────────────────────────────────────────────────────────
 	r.GET("/health", func(c *gin.Context) {
+		if !probes.Ready() {
+			c.JSON(http.StatusServiceUnavailable, gin.H{
+				"status":  "shutting down",
+				"service": "api-gateway",
+			})
+			return
+		}
 		c.JSON(http.StatusOK, gin.H{
 			"status":  "healthy",
 			"service": "api-gateway",
 		})
 	})
+	// /livez 只確認 process 還活著；/readyz 會檢查 Redis 與 user-service
+	r.GET("/livez",  probes.Livez)
+	r.GET("/readyz", probes.Readyz)
 
 	// ── User Service 路由 ─────────────────────────────────────────────────────
 	//
-	// 公開路由：不需要驗證身份（登入、註冊不可能先有 token）
+	// 公開路由：不需要驗證身份（登入、註冊、忘記密碼不可能先有 token，
+	// 確認新 email 的連結可能在其他裝置開啟；
+	// MFA 第二步帶的是 challenge token，由 user-service 自行驗證）
 	public := r.Group("/api")
 	{
-		public.POST("/users/login",    p.Forward(cfg.UserServiceURL, "/api"))
-		public.POST("/users/register", p.Forward(cfg.UserServiceURL, "/api"))
+		public.POST("/users/login",           user((*usergrpc.Translator).Login))
+		public.POST("/users/login/mfa",       user((*usergrpc.Translator).LoginMFA))
+		public.POST("/users/register",        user((*usergrpc.Translator).Register))
+		public.POST("/users/password/forgot", user((*usergrpc.Translator).ForgotPassword))
+		public.POST("/users/password/reset",  user((*usergrpc.Translator).ResetPassword))
+		public.POST("/users/email/confirm",   purgeUsers, user((*usergrpc.Translator).ConfirmEmailChange))
 	}
 
 	// 受保護路由：需要帶 Bearer token（透過 middleware/auth.go 驗證）
 	protected := r.Group("/api")
-	protected.Use(middleware.RequireAuth(cfg.JWTSecret))
+	protected.Use(middleware.RequireAuth(cfg.JWTSecret, revocations), cached)
+	{
+		protected.GET("/users",                       user((*usergrpc.Translator).ListUsers))
+		protected.GET("/users/me",                    user((*usergrpc.Translator).GetMe))
+		protected.PATCH("/users/me",                  user((*usergrpc.Translator).PatchMe))
+		protected.DELETE("/users/me",                 user((*usergrpc.Translator).DeleteMe))
+		protected.GET("/users/me/sessions",           user((*usergrpc.Translator).ListMySessions))
+		protected.DELETE("/users/me/sessions/:id",    user((*usergrpc.Translator).RevokeMySession))
+		protected.GET("/users/:id",                   user((*usergrpc.Translator).GetUser))
+		protected.POST("/users",                      forward) // user-service 沒有對應的 RPC
+		protected.PUT("/users/:id",                   user((*usergrpc.Translator).UpdateUser))
+		protected.PATCH("/users/:id",                 user((*usergrpc.Translator).PatchUser))
+		protected.PUT("/users/:id/password",          user((*usergrpc.Translator).ChangePassword))
+		protected.POST("/users/:id/email",            user((*usergrpc.Translator).RequestEmailChange))
+		protected.POST("/users/:id/mfa/totp",         user((*usergrpc.Translator).EnrollTOTP))
+		protected.POST("/users/:id/mfa/totp/confirm", user((*usergrpc.Translator).ConfirmTOTP))
+		protected.DELETE("/users/:id/mfa/totp",       user((*usergrpc.Translator).DisableMFA))
+		protected.DELETE("/users/:id",                user((*usergrpc.Translator).DeleteUser))
+	}
+
+	// 管理員路由：除了合法的 token，還要求 role 為 admin
+	admin := r.Group("/api/admin")
+	admin.Use(middleware.RequireAuth(cfg.JWTSecret, revocations), middleware.RequireRole("admin"), cached)
 	{
-		protected.GET("/users",        p.Forward(cfg.UserServiceURL, "/api"))
-		protected.GET("/users/:id",    p.Forward(cfg.UserServiceURL, "/api"))
-		protected.POST("/users",       p.Forward(cfg.UserServiceURL, "/api"))
-		protected.PUT("/users/:id",    p.Forward(cfg.UserServiceURL, "/api"))
-		protected.DELETE("/users/:id", p.Forward(cfg.UserServiceURL, "/api"))
+		admin.POST("/users/:id/restore", purgeUsers, forward)
+		admin.GET("/audit-events",       forward)
+		admin.POST("/cache/purge",       cache.PurgeHandler(responses)) // 由 gateway 處理，body 為 {"path_prefix": "..."}，留空清除全部
+	}
+}
+
+// corsConfig 將設定檔的 CORS 規則轉成 gin-contrib/cors 的設定：
+// "*" 代表允許所有 origin，含 * 的 origin（例如 https://*.example.com）以萬用字元比對
+func corsConfig(c config.CORSConfig) cors.Config {
+	out := cors.Config{
+		AllowMethods:     c.AllowMethods,
+		AllowHeaders:     c.AllowHeaders,
+		ExposeHeaders:    c.ExposeHeaders,
+		AllowCredentials: c.AllowCredentials,
+		MaxAge:           c.MaxAge,
+	}
+	for _, origin := range c.AllowOrigins {
+		if origin == "*" {
+			out.AllowAllOrigins = true
+			out.AllowOrigins = nil
+			out.AllowWildcard = false
+			return out
+		}
+		if strings.Contains(origin, "*") {
+			out.AllowWildcard = true
+		}
+		out.AllowOrigins = append(out.AllowOrigins, origin)
 	}
+	return out
 }
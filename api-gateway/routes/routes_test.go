@@ -0,0 +1,138 @@
+package routes
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gin-contrib/cors"
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+
+	"api-gateway/config"
+)
+
+func TestCORSConfig(t *testing.T) {
+	base := config.CORSConfig{
+		AllowMethods:     []string{"GET"},
+		AllowHeaders:     []string{"Authorization"},
+		ExposeHeaders:    []string{"ETag"},
+		AllowCredentials: true,
+		MaxAge:           time.Hour,
+	}
+
+	tests := []struct {
+		name         string
+		origins      []string
+		credentials  bool
+		wantAll      bool
+		wantOrigins  []string
+		wantWildcard bool
+	}{
+		{
+			name:        "exact origins",
+			origins:     []string{"https://app.example.com", "http://localhost:3000"},
+			credentials: true,
+			wantOrigins: []string{"https://app.example.com", "http://localhost:3000"},
+		},
+		{
+			name:         "subdomain wildcard",
+			origins:      []string{"https://app.example.com", "https://*.example.com"},
+			credentials:  true,
+			wantOrigins:  []string{"https://app.example.com", "https://*.example.com"},
+			wantWildcard: true,
+		},
+		{
+			name:    "star allows every origin",
+			origins: []string{"https://app.example.com", "*"},
+			wantAll: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			in := base
+			in.AllowOrigins = tt.origins
+			in.AllowCredentials = tt.credentials
+
+			out := corsConfig(in)
+
+			assert.Equal(t, tt.wantAll, out.AllowAllOrigins)
+			assert.Equal(t, tt.wantOrigins, out.AllowOrigins)
+			assert.Equal(t, tt.wantWildcard, out.AllowWildcard)
+			assert.Equal(t, tt.credentials, out.AllowCredentials)
+			assert.Equal(t, base.AllowMethods, out.AllowMethods)
+			assert.Equal(t, base.AllowHeaders, out.AllowHeaders)
+			assert.Equal(t, base.ExposeHeaders, out.ExposeHeaders)
+			assert.Equal(t, base.MaxAge, out.MaxAge)
+			// gin-contrib/cors 對不合法的設定會 panic
+			assert.NotPanics(t, func() { cors.New(out) })
+		})
+	}
+}
+
+func TestCORSConfigOrigins(t *testing.T) {
+	tests := []struct {
+		name       string
+		origins    []string
+		origin     string
+		wantCode   int
+		wantOrigin string
+	}{
+		{
+			name:       "exact match",
+			origins:    []string{"https://app.example.com"},
+			origin:     "https://app.example.com",
+			wantCode:   http.StatusNoContent,
+			wantOrigin: "https://app.example.com",
+		},
+		{
+			name:       "subdomain wildcard match",
+			origins:    []string{"https://*.example.com"},
+			origin:     "https://admin.example.com",
+			wantCode:   http.StatusNoContent,
+			wantOrigin: "https://admin.example.com",
+		},
+		{
+			name:     "subdomain wildcard does not match another domain",
+			origins:  []string{"https://*.example.com"},
+			origin:   "https://example.org",
+			wantCode: http.StatusForbidden,
+		},
+		{
+			name:     "subdomain wildcard does not match another scheme",
+			origins:  []string{"https://*.example.com"},
+			origin:   "http://admin.example.com",
+			wantCode: http.StatusForbidden,
+		},
+		{
+			// 允許所有 origin 時回 *，不會反射請求的 origin
+			name:       "star",
+			origins:    []string{"*"},
+			origin:     "https://anything.example.net",
+			wantCode:   http.StatusNoContent,
+			wantOrigin: "*",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			gin.SetMode(gin.TestMode)
+			router := gin.New()
+			router.Use(cors.New(corsConfig(config.CORSConfig{
+				AllowOrigins: tt.origins,
+				AllowMethods: []string{"GET"},
+			})))
+
+			w := httptest.NewRecorder()
+			r, _ := http.NewRequest("OPTIONS", "/api/users/me", nil)
+			r.Header.Set("Origin", tt.origin)
+			r.Header.Set("Access-Control-Request-Method", "GET")
+			router.ServeHTTP(w, r)
+
+			assert.Equal(t, tt.wantCode, w.Code)
+			assert.Equal(t, tt.wantOrigin, w.Header().Get("Access-Control-Allow-Origin"))
+		})
+	}
+}
@@ -0,0 +1,458 @@
+package usergrpc
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"mime"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+
+	"api-gateway/gen/userv1"
+)
+
+// mergePatchContentType RFC 7396 JSON Merge Patch 的 media type
+const mergePatchContentType = "application/merge-patch+json"
+
+// ── 註冊與登入 ───────────────────────────────────────────────────────────────
+
+// Register POST /users/register
+func (t *Translator) Register(c *gin.Context) {
+	var body struct {
+		Email    string `json:"email"`
+		Username string `json:"username"`
+		Password string `json:"password"`
+	}
+	if !bindJSON(c, &body) {
+		return
+	}
+
+	ctx, cancel := t.callContext(c)
+	defer cancel()
+	user, err := t.client.Register(ctx, &userv1.RegisterRequest{Email: body.Email, Username: body.Username, Password: body.Password})
+	if err != nil {
+		writeError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusCreated, toUserJSON(user))
+}
+
+// Login POST /users/login
+func (t *Translator) Login(c *gin.Context) {
+	var body struct {
+		Email    string `json:"email"`
+		Password string `json:"password"`
+	}
+	if !bindJSON(c, &body) {
+		return
+	}
+
+	ctx, cancel := t.callContext(c)
+	defer cancel()
+	resp, err := t.client.Login(ctx, &userv1.LoginRequest{Email: body.Email, Password: body.Password})
+	if err != nil {
+		writeError(c, err)
+		return
+	}
+
+	writeLoginResponse(c, resp)
+}
+
+// LoginMFA POST /users/login/mfa
+func (t *Translator) LoginMFA(c *gin.Context) {
+	var body struct {
+		MFAToken string `json:"mfa_token"`
+		Code     string `json:"code"`
+	}
+	if !bindJSON(c, &body) {
+		return
+	}
+
+	ctx, cancel := t.callContext(c)
+	defer cancel()
+	resp, err := t.client.LoginMFA(ctx, &userv1.LoginMFARequest{MfaToken: body.MFAToken, Code: body.Code})
+	if err != nil {
+		writeError(c, err)
+		return
+	}
+
+	writeLoginResponse(c, resp)
+}
+
+// writeLoginResponse 需要 MFA 時只回傳 challenge token，否則回傳 access token 與用戶資料
+func writeLoginResponse(c *gin.Context, resp *userv1.LoginResponse) {
+	if resp.GetMfaRequired() {
+		c.JSON(http.StatusOK, gin.H{
+			"message":      "MFA required",
+			"mfa_required": true,
+			"mfa_token":    resp.GetMfaToken(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": "Login successful",
+		"token":   resp.GetToken(),
+		"user":    toUserJSON(resp.GetUser()),
+	})
+}
+
+// ── 用戶資料 ─────────────────────────────────────────────────────────────────
+
+// ListUsers GET /users
+func (t *Translator) ListUsers(c *gin.Context) {
+	ctx, cancel := t.callContext(c)
+	defer cancel()
+	resp, err := t.client.ListUsers(ctx, &userv1.ListUsersRequest{})
+	if err != nil {
+		writeError(c, err)
+		return
+	}
+
+	users := make([]userJSON, len(resp.GetUsers()))
+	for i, u := range resp.GetUsers() {
+		users[i] = toUserJSON(u)
+	}
+	c.JSON(http.StatusOK, users)
+}
+
+// GetUser GET /users/:id
+func (t *Translator) GetUser(c *gin.Context) {
+	t.getUser(c, c.Param("id"))
+}
+
+// GetMe GET /users/me
+func (t *Translator) GetMe(c *gin.Context) {
+	t.getUser(c, c.GetString("user_id"))
+}
+
+func (t *Translator) getUser(c *gin.Context, id string) {
+	ctx, cancel := t.callContext(c)
+	defer cancel()
+	user, err := t.client.GetUser(ctx, &userv1.GetUserRequest{Id: id})
+	if err != nil {
+		writeError(c, err)
+		return
+	}
+
+	c.Header("ETag", userETag(user.GetVersion()))
+	c.JSON(http.StatusOK, toUserJSON(user))
+}
+
+// UpdateUser PUT /users/:id
+func (t *Translator) UpdateUser(c *gin.Context) {
+	var body struct {
+		Username string `json:"username"`
+	}
+	if !bindJSON(c, &body) {
+		return
+	}
+
+	ctx, cancel := t.callContext(c)
+	defer cancel()
+	_, err := t.client.UpdateUser(ctx, &userv1.UpdateUserRequest{
+		Id:       c.Param("id"),
+		Username: body.Username,
+		IfMatch:  c.GetHeader("If-Match"),
+	})
+	if err != nil {
+		writeError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "User updated successfully"})
+}
+
+// PatchUser PATCH /users/:id
+func (t *Translator) PatchUser(c *gin.Context) {
+	t.patchUser(c, c.Param("id"))
+}
+
+// PatchMe PATCH /users/me
+func (t *Translator) PatchMe(c *gin.Context) {
+	t.patchUser(c, c.GetString("user_id"))
+}
+
+// patchUser 將 JSON Merge Patch 轉成 PatchUserRequest，哪些欄位可以修改由 user-service 決定
+func (t *Translator) patchUser(c *gin.Context, id string) {
+	mediaType, _, _ := mime.ParseMediaType(c.ContentType())
+	if mediaType != mergePatchContentType && mediaType != "application/json" {
+		c.JSON(http.StatusUnsupportedMediaType, gin.H{"error": "Content-Type must be " + mergePatchContentType})
+		return
+	}
+
+	raw, err := c.GetRawData()
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	fields, err := parseMergePatch(raw)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	ctx, cancel := t.callContext(c)
+	defer cancel()
+	user, err := t.client.PatchUser(ctx, &userv1.PatchUserRequest{
+		Id:      id,
+		Fields:  fields,
+		IfMatch: c.GetHeader("If-Match"),
+	})
+	if err != nil {
+		writeError(c, err)
+		return
+	}
+
+	c.Header("ETag", userETag(user.GetVersion()))
+	c.JSON(http.StatusOK, toUserJSON(user))
+}
+
+// parseMergePatch 解析 merge patch 的 JSON object，值只能是字串或 null
+func parseMergePatch(data []byte) (map[string]*userv1.PatchValue, error) {
+	var raw map[string]json.RawMessage
+	if err := json.Unmarshal(data, &raw); err != nil || raw == nil {
+		return nil, fmt.Errorf("merge patch must be a JSON object")
+	}
+
+	fields := make(map[string]*userv1.PatchValue, len(raw))
+	for name, value := range raw {
+		if bytes.Equal(bytes.TrimSpace(value), []byte("null")) {
+			fields[name] = &userv1.PatchValue{Kind: &userv1.PatchValue_NullValue{NullValue: true}}
+			continue
+		}
+		var s string
+		if err := json.Unmarshal(value, &s); err != nil {
+			return nil, fmt.Errorf("field %q must be a string or null", name)
+		}
+		fields[name] = &userv1.PatchValue{Kind: &userv1.PatchValue_StringValue{StringValue: s}}
+	}
+	return fields, nil
+}
+
+// DeleteUser DELETE /users/:id
+func (t *Translator) DeleteUser(c *gin.Context) {
+	t.deleteUser(c, c.Param("id"))
+}
+
+// DeleteMe DELETE /users/me
+func (t *Translator) DeleteMe(c *gin.Context) {
+	t.deleteUser(c, c.GetString("user_id"))
+}
+
+func (t *Translator) deleteUser(c *gin.Context, id string) {
+	ctx, cancel := t.callContext(c)
+	defer cancel()
+	_, err := t.client.DeleteUser(ctx, &userv1.DeleteUserRequest{Id: id, IfMatch: c.GetHeader("If-Match")})
+	if err != nil {
+		writeError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "User deleted successfully"})
+}
+
+// ── 密碼與 email ─────────────────────────────────────────────────────────────
+
+// ForgotPassword POST /users/password/forgot
+func (t *Translator) ForgotPassword(c *gin.Context) {
+	var body struct {
+		Email string `json:"email"`
+	}
+	if !bindJSON(c, &body) {
+		return
+	}
+
+	ctx, cancel := t.callContext(c)
+	defer cancel()
+	if _, err := t.client.ForgotPassword(ctx, &userv1.ForgotPasswordRequest{Email: body.Email}); err != nil {
+		writeError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusAccepted, gin.H{
+		"message": "If the email is registered, a password reset link has been sent",
+	})
+}
+
+// ResetPassword POST /users/password/reset
+func (t *Translator) ResetPassword(c *gin.Context) {
+	var body struct {
+		Token       string `json:"token"`
+		NewPassword string `json:"new_password"`
+	}
+	if !bindJSON(c, &body) {
+		return
+	}
+
+	ctx, cancel := t.callContext(c)
+	defer cancel()
+	if _, err := t.client.ResetPassword(ctx, &userv1.ResetPasswordRequest{Token: body.Token, NewPassword: body.NewPassword}); err != nil {
+		writeError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Password reset successfully"})
+}
+
+// ChangePassword PUT /users/:id/password
+func (t *Translator) ChangePassword(c *gin.Context) {
+	var body struct {
+		CurrentPassword string `json:"current_password"`
+		NewPassword     string `json:"new_password"`
+	}
+	if !bindJSON(c, &body) {
+		return
+	}
+
+	ctx, cancel := t.callContext(c)
+	defer cancel()
+	_, err := t.client.ChangePassword(ctx, &userv1.ChangePasswordRequest{
+		Id:              c.Param("id"),
+		CurrentPassword: body.CurrentPassword,
+		NewPassword:     body.NewPassword,
+	})
+	if err != nil {
+		writeError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Password changed successfully, please log in again"})
+}
+
+// RequestEmailChange POST /users/:id/email
+func (t *Translator) RequestEmailChange(c *gin.Context) {
+	var body struct {
+		NewEmail        string `json:"new_email"`
+		CurrentPassword string `json:"current_password"`
+	}
+	if !bindJSON(c, &body) {
+		return
+	}
+
+	ctx, cancel := t.callContext(c)
+	defer cancel()
+	_, err := t.client.RequestEmailChange(ctx, &userv1.RequestEmailChangeRequest{
+		Id:              c.Param("id"),
+		NewEmail:        body.NewEmail,
+		CurrentPassword: body.CurrentPassword,
+	})
+	if err != nil {
+		writeError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusAccepted, gin.H{"message": "A confirmation link has been sent to the new email address"})
+}
+
+// ConfirmEmailChange POST /users/email/confirm
+func (t *Translator) ConfirmEmailChange(c *gin.Context) {
+	var body struct {
+		Token string `json:"token"`
+	}
+	if !bindJSON(c, &body) {
+		return
+	}
+
+	ctx, cancel := t.callContext(c)
+	defer cancel()
+	if _, err := t.client.ConfirmEmailChange(ctx, &userv1.ConfirmEmailChangeRequest{Token: body.Token}); err != nil {
+		writeError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Email changed successfully, please log in again"})
+}
+
+// ── MFA ──────────────────────────────────────────────────────────────────────
+
+// EnrollTOTP POST /users/:id/mfa/totp
+func (t *Translator) EnrollTOTP(c *gin.Context) {
+	ctx, cancel := t.callContext(c)
+	defer cancel()
+	enrollment, err := t.client.EnrollTOTP(ctx, &userv1.EnrollTOTPRequest{Id: c.Param("id")})
+	if err != nil {
+		writeError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"secret":           enrollment.GetSecret(),
+		"provisioning_uri": enrollment.GetProvisioningUri(),
+	})
+}
+
+// ConfirmTOTP POST /users/:id/mfa/totp/confirm
+func (t *Translator) ConfirmTOTP(c *gin.Context) {
+	var body struct {
+		Code string `json:"code"`
+	}
+	if !bindJSON(c, &body) {
+		return
+	}
+
+	ctx, cancel := t.callContext(c)
+	defer cancel()
+	resp, err := t.client.ConfirmTOTP(ctx, &userv1.ConfirmTOTPRequest{Id: c.Param("id"), Code: body.Code})
+	if err != nil {
+		writeError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message":        "MFA enabled, store these recovery codes somewhere safe",
+		"recovery_codes": resp.GetRecoveryCodes(),
+	})
+}
+
+// DisableMFA DELETE /users/:id/mfa/totp
+func (t *Translator) DisableMFA(c *gin.Context) {
+	var body struct {
+		Code string `json:"code"`
+	}
+	if !bindJSON(c, &body) {
+		return
+	}
+
+	ctx, cancel := t.callContext(c)
+	defer cancel()
+	if _, err := t.client.DisableMFA(ctx, &userv1.DisableMFARequest{Id: c.Param("id"), Code: body.Code}); err != nil {
+		writeError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "MFA disabled"})
+}
+
+// ── 登入 session ─────────────────────────────────────────────────────────────
+
+// ListMySessions GET /users/me/sessions
+func (t *Translator) ListMySessions(c *gin.Context) {
+	ctx, cancel := t.callContext(c)
+	defer cancel()
+	resp, err := t.client.ListSessions(ctx, &userv1.ListSessionsRequest{})
+	if err != nil {
+		writeError(c, err)
+		return
+	}
+
+	sessions := make([]sessionJSON, len(resp.GetSessions()))
+	for i, s := range resp.GetSessions() {
+		sessions[i] = toSessionJSON(s)
+	}
+	c.JSON(http.StatusOK, gin.H{"sessions": sessions})
+}
+
+// RevokeMySession DELETE /users/me/sessions/:id
+func (t *Translator) RevokeMySession(c *gin.Context) {
+	ctx, cancel := t.callContext(c)
+	defer cancel()
+	if _, err := t.client.RevokeSession(ctx, &userv1.RevokeSessionRequest{Id: c.Param("id")}); err != nil {
+		writeError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Session revoked"})
+}
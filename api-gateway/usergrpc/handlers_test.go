@@ -0,0 +1,480 @@
+package usergrpc
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/known/emptypb"
+	"google.golang.org/protobuf/types/known/timestamppb"
+
+	"api-gateway/gen/userv1"
+)
+
+// -------------------------------------------------------------------
+// fakeClient：記錄最後一次呼叫收到的請求與 metadata，回傳預先設定的 resp 或 err
+// 未實作的方法沿用嵌入的 nil interface（呼叫到會 panic）
+// -------------------------------------------------------------------
+
+type fakeClient struct {
+	userv1.UserServiceClient
+	resp proto.Message
+	err  error
+
+	req      proto.Message
+	md       metadata.MD
+	deadline time.Time
+}
+
+func (f *fakeClient) record(ctx context.Context, req proto.Message) error {
+	f.req = req
+	f.md, _ = metadata.FromOutgoingContext(ctx)
+	f.deadline, _ = ctx.Deadline()
+	return f.err
+}
+
+func (f *fakeClient) Register(ctx context.Context, in *userv1.RegisterRequest, _ ...grpc.CallOption) (*userv1.User, error) {
+	if err := f.record(ctx, in); err != nil {
+		return nil, err
+	}
+	return f.resp.(*userv1.User), nil
+}
+
+func (f *fakeClient) Login(ctx context.Context, in *userv1.LoginRequest, _ ...grpc.CallOption) (*userv1.LoginResponse, error) {
+	if err := f.record(ctx, in); err != nil {
+		return nil, err
+	}
+	return f.resp.(*userv1.LoginResponse), nil
+}
+
+func (f *fakeClient) ListUsers(ctx context.Context, in *userv1.ListUsersRequest, _ ...grpc.CallOption) (*userv1.ListUsersResponse, error) {
+	if err := f.record(ctx, in); err != nil {
+		return nil, err
+	}
+	return f.resp.(*userv1.ListUsersResponse), nil
+}
+
+func (f *fakeClient) GetUser(ctx context.Context, in *userv1.GetUserRequest, _ ...grpc.CallOption) (*userv1.User, error) {
+	if err := f.record(ctx, in); err != nil {
+		return nil, err
+	}
+	return f.resp.(*userv1.User), nil
+}
+
+func (f *fakeClient) UpdateUser(ctx context.Context, in *userv1.UpdateUserRequest, _ ...grpc.CallOption) (*emptypb.Empty, error) {
+	return &emptypb.Empty{}, f.record(ctx, in)
+}
+
+func (f *fakeClient) PatchUser(ctx context.Context, in *userv1.PatchUserRequest, _ ...grpc.CallOption) (*userv1.User, error) {
+	if err := f.record(ctx, in); err != nil {
+		return nil, err
+	}
+	return f.resp.(*userv1.User), nil
+}
+
+func (f *fakeClient) DeleteUser(ctx context.Context, in *userv1.DeleteUserRequest, _ ...grpc.CallOption) (*emptypb.Empty, error) {
+	return &emptypb.Empty{}, f.record(ctx, in)
+}
+
+func (f *fakeClient) ForgotPassword(ctx context.Context, in *userv1.ForgotPasswordRequest, _ ...grpc.CallOption) (*emptypb.Empty, error) {
+	return &emptypb.Empty{}, f.record(ctx, in)
+}
+
+func (f *fakeClient) ChangePassword(ctx context.Context, in *userv1.ChangePasswordRequest, _ ...grpc.CallOption) (*emptypb.Empty, error) {
+	return &emptypb.Empty{}, f.record(ctx, in)
+}
+
+func (f *fakeClient) RequestEmailChange(ctx context.Context, in *userv1.RequestEmailChangeRequest, _ ...grpc.CallOption) (*emptypb.Empty, error) {
+	return &emptypb.Empty{}, f.record(ctx, in)
+}
+
+func (f *fakeClient) ConfirmTOTP(ctx context.Context, in *userv1.ConfirmTOTPRequest, _ ...grpc.CallOption) (*userv1.RecoveryCodes, error) {
+	if err := f.record(ctx, in); err != nil {
+		return nil, err
+	}
+	return f.resp.(*userv1.RecoveryCodes), nil
+}
+
+func (f *fakeClient) ListSessions(ctx context.Context, in *userv1.ListSessionsRequest, _ ...grpc.CallOption) (*userv1.ListSessionsResponse, error) {
+	if err := f.record(ctx, in); err != nil {
+		return nil, err
+	}
+	return f.resp.(*userv1.ListSessionsResponse), nil
+}
+
+// testTime 測試用的固定時間
+var testTime = time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+
+// testUser user-service 回傳的用戶，testUserJSON 是轉成 HTTP 回應後的 JSON
+var testUser = &userv1.User{
+	Id:        "uuid-001",
+	Email:     "alice@example.com",
+	Username:  "alice",
+	Role:      "user",
+	Bio:       "hello",
+	Version:   3,
+	CreatedAt: timestamppb.New(testTime),
+	UpdatedAt: timestamppb.New(testTime.Add(time.Hour)),
+}
+
+const testUserJSON = `{"id":"uuid-001","email":"alice@example.com","username":"alice","role":"user",` +
+	`"display_name":"","bio":"hello","avatar_url":"","locale":"","timezone":"","mfa_enabled":false,"version":3,` +
+	`"created_at":"2024-01-01T12:00:00Z","updated_at":"2024-01-01T13:00:00Z"}`
+
+// setupTranslatorRouter 掛上與 routes.go 相同路徑的 handler；X-User-ID 模擬 RequireAuth 寫入的身份
+func setupTranslatorRouter(tr *Translator) *gin.Engine {
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	_ = r.SetTrustedProxies(nil) // 與正式環境相同，未設定信任的 proxy 時不採信 X-Forwarded-For
+	r.Use(func(c *gin.Context) {
+		if id := c.GetHeader("X-User-ID"); id != "" {
+			c.Set("user_id", id)
+		}
+	})
+	r.POST("/api/users/register", tr.Register)
+	r.POST("/api/users/login", tr.Login)
+	r.POST("/api/users/password/forgot", tr.ForgotPassword)
+	r.GET("/api/users", tr.ListUsers)
+	r.GET("/api/users/me", tr.GetMe)
+	r.PATCH("/api/users/me", tr.PatchMe)
+	r.GET("/api/users/me/sessions", tr.ListMySessions)
+	r.GET("/api/users/:id", tr.GetUser)
+	r.PUT("/api/users/:id", tr.UpdateUser)
+	r.PATCH("/api/users/:id", tr.PatchUser)
+	r.DELETE("/api/users/:id", tr.DeleteUser)
+	r.PUT("/api/users/:id/password", tr.ChangePassword)
+	r.POST("/api/users/:id/email", tr.RequestEmailChange)
+	r.POST("/api/users/:id/mfa/totp/confirm", tr.ConfirmTOTP)
+	return r
+}
+
+func stringPatch(s string) *userv1.PatchValue {
+	return &userv1.PatchValue{Kind: &userv1.PatchValue_StringValue{StringValue: s}}
+}
+
+func nullPatch() *userv1.PatchValue {
+	return &userv1.PatchValue{Kind: &userv1.PatchValue_NullValue{NullValue: true}}
+}
+
+func TestTranslator(t *testing.T) {
+	revokedAt := testTime.Add(30 * time.Minute)
+
+	tests := []struct {
+		name     string
+		method   string
+		path     string
+		body     string
+		header   http.Header
+		resp     proto.Message
+		err      error
+		wantReq  proto.Message // nil 表示請求不應送到 user-service
+		wantCode int
+		wantBody string
+		wantETag string
+	}{
+		{
+			name:   "register",
+			method: "POST", path: "/api/users/register",
+			body:     `{"email":"alice@example.com","username":"alice","password":"Password123!"}`,
+			resp:     testUser,
+			wantReq:  &userv1.RegisterRequest{Email: "alice@example.com", Username: "alice", Password: "Password123!"},
+			wantCode: http.StatusCreated,
+			wantBody: testUserJSON,
+		},
+		{
+			name:   "register with a taken email",
+			method: "POST", path: "/api/users/register",
+			body:     `{"email":"alice@example.com","username":"alice","password":"Password123!"}`,
+			err:      status.Error(codes.AlreadyExists, "email already exists"),
+			wantReq:  &userv1.RegisterRequest{Email: "alice@example.com", Username: "alice", Password: "Password123!"},
+			wantCode: http.StatusConflict,
+			wantBody: `{"error":"email already exists"}`,
+		},
+		{
+			name:   "malformed body is rejected before the call",
+			method: "POST", path: "/api/users/register",
+			body:     `{"email":`,
+			wantCode: http.StatusBadRequest,
+		},
+		{
+			name:   "login",
+			method: "POST", path: "/api/users/login",
+			body:     `{"email":"alice@example.com","password":"Password123!"}`,
+			resp:     &userv1.LoginResponse{Token: "jwt", User: testUser},
+			wantReq:  &userv1.LoginRequest{Email: "alice@example.com", Password: "Password123!"},
+			wantCode: http.StatusOK,
+			wantBody: `{"message":"Login successful","token":"jwt","user":` + testUserJSON + `}`,
+		},
+		{
+			name:   "login requiring mfa",
+			method: "POST", path: "/api/users/login",
+			body:     `{"email":"alice@example.com","password":"Password123!"}`,
+			resp:     &userv1.LoginResponse{MfaRequired: true, MfaToken: "challenge"},
+			wantReq:  &userv1.LoginRequest{Email: "alice@example.com", Password: "Password123!"},
+			wantCode: http.StatusOK,
+			wantBody: `{"message":"MFA required","mfa_required":true,"mfa_token":"challenge"}`,
+		},
+		{
+			name:   "forgot password",
+			method: "POST", path: "/api/users/password/forgot",
+			body:     `{"email":"alice@example.com"}`,
+			wantReq:  &userv1.ForgotPasswordRequest{Email: "alice@example.com"},
+			wantCode: http.StatusAccepted,
+			wantBody: `{"message":"If the email is registered, a password reset link has been sent"}`,
+		},
+		{
+			name:   "list users",
+			method: "GET", path: "/api/users",
+			resp:     &userv1.ListUsersResponse{Users: []*userv1.User{testUser}},
+			wantReq:  &userv1.ListUsersRequest{},
+			wantCode: http.StatusOK,
+			wantBody: `[` + testUserJSON + `]`,
+		},
+		{
+			name:   "list users with user-service down",
+			method: "GET", path: "/api/users",
+			err:      status.Error(codes.Unavailable, "connection refused"),
+			wantReq:  &userv1.ListUsersRequest{},
+			wantCode: http.StatusBadGateway,
+			wantBody: `{"error":"下游服務無法連線"}`,
+		},
+		{
+			name:   "get user",
+			method: "GET", path: "/api/users/uuid-001",
+			resp:     testUser,
+			wantReq:  &userv1.GetUserRequest{Id: "uuid-001"},
+			wantCode: http.StatusOK,
+			wantBody: testUserJSON,
+			wantETag: `"3"`,
+		},
+		{
+			name:   "get me uses the caller identity",
+			method: "GET", path: "/api/users/me",
+			header:   http.Header{"X-User-Id": {"uuid-001"}},
+			resp:     testUser,
+			wantReq:  &userv1.GetUserRequest{Id: "uuid-001"},
+			wantCode: http.StatusOK,
+			wantBody: testUserJSON,
+			wantETag: `"3"`,
+		},
+		{
+			name:   "get missing user",
+			method: "GET", path: "/api/users/ghost",
+			err:      status.Error(codes.NotFound, "user not found"),
+			wantReq:  &userv1.GetUserRequest{Id: "ghost"},
+			wantCode: http.StatusNotFound,
+			wantBody: `{"error":"user not found"}`,
+		},
+		{
+			name:   "update user forwards If-Match",
+			method: "PUT", path: "/api/users/uuid-001",
+			body:     `{"username":"alice2"}`,
+			header:   http.Header{"If-Match": {`"3"`}},
+			wantReq:  &userv1.UpdateUserRequest{Id: "uuid-001", Username: "alice2", IfMatch: `"3"`},
+			wantCode: http.StatusOK,
+			wantBody: `{"message":"User updated successfully"}`,
+		},
+		{
+			name:   "update another user",
+			method: "PUT", path: "/api/users/uuid-002",
+			body:     `{"username":"mallory"}`,
+			err:      status.Error(codes.PermissionDenied, "only the account owner or an admin can modify this user"),
+			wantReq:  &userv1.UpdateUserRequest{Id: "uuid-002", Username: "mallory"},
+			wantCode: http.StatusForbidden,
+			wantBody: `{"error":"only the account owner or an admin can modify this user"}`,
+		},
+		{
+			name:   "merge patch with null",
+			method: "PATCH", path: "/api/users/uuid-001",
+			body:     `{"bio":"hello","avatar_url":null}`,
+			header:   http.Header{"Content-Type": {mergePatchContentType}, "If-Match": {`"2"`}},
+			resp:     testUser,
+			wantReq:  &userv1.PatchUserRequest{Id: "uuid-001", Fields: map[string]*userv1.PatchValue{"bio": stringPatch("hello"), "avatar_url": nullPatch()}, IfMatch: `"2"`},
+			wantCode: http.StatusOK,
+			wantBody: testUserJSON,
+			wantETag: `"3"`,
+		},
+		{
+			name:   "patch me uses the caller identity",
+			method: "PATCH", path: "/api/users/me",
+			body:     `{"bio":"hello"}`,
+			header:   http.Header{"Content-Type": {"application/json"}, "X-User-Id": {"uuid-001"}},
+			resp:     testUser,
+			wantReq:  &userv1.PatchUserRequest{Id: "uuid-001", Fields: map[string]*userv1.PatchValue{"bio": stringPatch("hello")}},
+			wantCode: http.StatusOK,
+			wantBody: testUserJSON,
+			wantETag: `"3"`,
+		},
+		{
+			name:   "patch with unsupported content type",
+			method: "PATCH", path: "/api/users/uuid-001",
+			body:     `{"bio":"hello"}`,
+			header:   http.Header{"Content-Type": {"text/plain"}},
+			wantCode: http.StatusUnsupportedMediaType,
+		},
+		{
+			name:   "patch with a non-string value",
+			method: "PATCH", path: "/api/users/uuid-001",
+			body:     `{"bio":42}`,
+			header:   http.Header{"Content-Type": {mergePatchContentType}},
+			wantCode: http.StatusBadRequest,
+			wantBody: `{"error":"field \"bio\" must be a string or null"}`,
+		},
+		{
+			name:   "patch with a non-object body",
+			method: "PATCH", path: "/api/users/uuid-001",
+			body:     `["bio"]`,
+			header:   http.Header{"Content-Type": {mergePatchContentType}},
+			wantCode: http.StatusBadRequest,
+			wantBody: `{"error":"merge patch must be a JSON object"}`,
+		},
+		{
+			name:   "delete with a stale version",
+			method: "DELETE", path: "/api/users/uuid-001",
+			header:   http.Header{"If-Match": {`"1"`}},
+			err:      status.Error(codes.FailedPrecondition, "user version conflict"),
+			wantReq:  &userv1.DeleteUserRequest{Id: "uuid-001", IfMatch: `"1"`},
+			wantCode: http.StatusPreconditionFailed,
+			wantBody: `{"error":"user version conflict"}`,
+		},
+		{
+			name:   "change password",
+			method: "PUT", path: "/api/users/uuid-001/password",
+			body:     `{"current_password":"old-secret1","new_password":"new-secret2"}`,
+			wantReq:  &userv1.ChangePasswordRequest{Id: "uuid-001", CurrentPassword: "old-secret1", NewPassword: "new-secret2"},
+			wantCode: http.StatusOK,
+			wantBody: `{"message":"Password changed successfully, please log in again"}`,
+		},
+		{
+			name:   "request email change",
+			method: "POST", path: "/api/users/uuid-001/email",
+			body:     `{"new_email":"new@example.com","current_password":"secret1"}`,
+			wantReq:  &userv1.RequestEmailChangeRequest{Id: "uuid-001", NewEmail: "new@example.com", CurrentPassword: "secret1"},
+			wantCode: http.StatusAccepted,
+			wantBody: `{"message":"A confirmation link has been sent to the new email address"}`,
+		},
+		{
+			name:   "confirm totp",
+			method: "POST", path: "/api/users/uuid-001/mfa/totp/confirm",
+			body:     `{"code":"123456"}`,
+			resp:     &userv1.RecoveryCodes{RecoveryCodes: []string{"aaaa-bbbb", "cccc-dddd"}},
+			wantReq:  &userv1.ConfirmTOTPRequest{Id: "uuid-001", Code: "123456"},
+			wantCode: http.StatusOK,
+			wantBody: `{"message":"MFA enabled, store these recovery codes somewhere safe","recovery_codes":["aaaa-bbbb","cccc-dddd"]}`,
+		},
+		{
+			name:   "list sessions",
+			method: "GET", path: "/api/users/me/sessions",
+			resp: &userv1.ListSessionsResponse{Sessions: []*userv1.Session{
+				{Id: "sess-1", Ip: "203.0.113.7", UserAgent: "curl/8.0", CreatedAt: timestamppb.New(testTime), ExpiresAt: timestamppb.New(testTime.Add(24 * time.Hour)), Active: true, Current: true},
+				{Id: "sess-2", CreatedAt: timestamppb.New(testTime), ExpiresAt: timestamppb.New(testTime.Add(24 * time.Hour)), RevokedAt: timestamppb.New(revokedAt)},
+			}},
+			wantReq:  &userv1.ListSessionsRequest{},
+			wantCode: http.StatusOK,
+			wantBody: `{"sessions":[` +
+				`{"id":"sess-1","ip":"203.0.113.7","user_agent":"curl/8.0","created_at":"2024-01-01T12:00:00Z","expires_at":"2024-01-02T12:00:00Z","active":true,"current":true},` +
+				`{"id":"sess-2","ip":"","user_agent":"","created_at":"2024-01-01T12:00:00Z","expires_at":"2024-01-02T12:00:00Z","revoked_at":"2024-01-01T12:30:00Z","active":false,"current":false}]}`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			client := &fakeClient{resp: tt.resp, err: tt.err}
+			router := setupTranslatorRouter(&Translator{client: client, timeout: DefaultTimeout})
+
+			w := httptest.NewRecorder()
+			r, _ := http.NewRequest(tt.method, tt.path, strings.NewReader(tt.body))
+			if tt.body != "" {
+				r.Header.Set("Content-Type", "application/json")
+			}
+			for name, values := range tt.header {
+				r.Header[name] = values
+			}
+			router.ServeHTTP(w, r)
+
+			assert.Equal(t, tt.wantCode, w.Code)
+			if tt.wantBody != "" {
+				assert.JSONEq(t, tt.wantBody, w.Body.String())
+			}
+			assert.Equal(t, tt.wantETag, w.Header().Get("ETag"))
+			if tt.wantReq == nil {
+				assert.Nil(t, client.req, "request should not reach user-service")
+				return
+			}
+			assert.True(t, proto.Equal(tt.wantReq, client.req), "got request %v", client.req)
+		})
+	}
+}
+
+func TestCallContext(t *testing.T) {
+	t.Run("forwards identity and client metadata", func(t *testing.T) {
+		client := &fakeClient{resp: testUser}
+		router := setupTranslatorRouter(&Translator{client: client, timeout: DefaultTimeout})
+
+		w := httptest.NewRecorder()
+		r, _ := http.NewRequest("GET", "/api/users/uuid-001", nil)
+		r.Header.Set("X-User-ID", "uuid-001")
+		r.Header.Set("X-User-Email", "alice@example.com")
+		r.Header.Set("X-User-Roles", "user")
+		r.Header.Set("X-Session-ID", "sess-1")
+		r.Header.Set("X-Request-ID", "req-42")
+		r.Header.Set("Traceparent", "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01")
+		r.Header.Set("User-Agent", "curl/8.0")
+		r.Header.Set("Authorization", "Bearer secret-token")
+		r.Header.Set("X-Forwarded-For", "198.51.100.1")
+		r.RemoteAddr = "203.0.113.7:51234"
+		router.ServeHTTP(w, r)
+
+		require.Equal(t, http.StatusOK, w.Code)
+		assert.Equal(t, []string{"uuid-001"}, client.md.Get("x-user-id"))
+		assert.Equal(t, []string{"alice@example.com"}, client.md.Get("x-user-email"))
+		assert.Equal(t, []string{"user"}, client.md.Get("x-user-roles"))
+		assert.Equal(t, []string{"sess-1"}, client.md.Get("x-session-id"))
+		assert.Equal(t, []string{"req-42"}, client.md.Get("x-request-id"))
+		assert.Len(t, client.md.Get("traceparent"), 1)
+		assert.Equal(t, []string{"curl/8.0"}, client.md.Get(clientUserAgentKey))
+		assert.Equal(t, []string{"203.0.113.7"}, client.md.Get("x-client-ip"))
+		// 客戶端自帶的 X-Forwarded-For 不會原樣傳到下游
+		assert.Empty(t, client.md.Get("x-forwarded-for"))
+		// token 已由 gateway 驗證過，不再往下游傳
+		assert.Empty(t, client.md.Get("authorization"))
+	})
+
+	t.Run("applies the default timeout", func(t *testing.T) {
+		client := &fakeClient{resp: testUser}
+		router := setupTranslatorRouter(&Translator{client: client, timeout: 2 * time.Second})
+
+		w := httptest.NewRecorder()
+		r, _ := http.NewRequest("GET", "/api/users/uuid-001", nil)
+		start := time.Now()
+		router.ServeHTTP(w, r)
+
+		require.False(t, client.deadline.IsZero())
+		assert.WithinDuration(t, start.Add(2*time.Second), client.deadline, time.Second)
+	})
+
+	t.Run("keeps the route deadline", func(t *testing.T) {
+		client := &fakeClient{resp: testUser}
+		router := setupTranslatorRouter(&Translator{client: client, timeout: time.Hour})
+
+		w := httptest.NewRecorder()
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		r, _ := http.NewRequestWithContext(ctx, "GET", "/api/users/uuid-001", nil)
+		router.ServeHTTP(w, r)
+
+		want, _ := ctx.Deadline()
+		assert.Equal(t, want, client.deadline)
+	})
+}
@@ -0,0 +1,77 @@
+package usergrpc
+
+import (
+	"strconv"
+	"time"
+
+	"api-gateway/gen/userv1"
+)
+
+// userJSON 與 user-service models.User 的 JSON 格式相同
+type userJSON struct {
+	ID          string    `json:"id"`
+	Email       string    `json:"email"`
+	Username    string    `json:"username"`
+	Role        string    `json:"role"`
+	DisplayName string    `json:"display_name"`
+	Bio         string    `json:"bio"`
+	AvatarURL   string    `json:"avatar_url"`
+	Locale      string    `json:"locale"`
+	Timezone    string    `json:"timezone"`
+	MFAEnabled  bool      `json:"mfa_enabled"`
+	Version     int64     `json:"version"`
+	CreatedAt   time.Time `json:"created_at"`
+	UpdatedAt   time.Time `json:"updated_at"`
+}
+
+func toUserJSON(u *userv1.User) userJSON {
+	return userJSON{
+		ID:          u.GetId(),
+		Email:       u.GetEmail(),
+		Username:    u.GetUsername(),
+		Role:        u.GetRole(),
+		DisplayName: u.GetDisplayName(),
+		Bio:         u.GetBio(),
+		AvatarURL:   u.GetAvatarUrl(),
+		Locale:      u.GetLocale(),
+		Timezone:    u.GetTimezone(),
+		MFAEnabled:  u.GetMfaEnabled(),
+		Version:     u.GetVersion(),
+		CreatedAt:   u.GetCreatedAt().AsTime(),
+		UpdatedAt:   u.GetUpdatedAt().AsTime(),
+	}
+}
+
+// userETag 與 user-service 相同的 strong ETag，例如 "3"
+func userETag(version int64) string {
+	return `"` + strconv.FormatInt(version, 10) + `"`
+}
+
+// sessionJSON 與 user-service models.Session 的 JSON 格式相同
+type sessionJSON struct {
+	ID        string     `json:"id"`
+	IP        string     `json:"ip"`
+	UserAgent string     `json:"user_agent"`
+	CreatedAt time.Time  `json:"created_at"`
+	ExpiresAt time.Time  `json:"expires_at"`
+	RevokedAt *time.Time `json:"revoked_at,omitempty"`
+	Active    bool       `json:"active"`
+	Current   bool       `json:"current"`
+}
+
+func toSessionJSON(s *userv1.Session) sessionJSON {
+	session := sessionJSON{
+		ID:        s.GetId(),
+		IP:        s.GetIp(),
+		UserAgent: s.GetUserAgent(),
+		CreatedAt: s.GetCreatedAt().AsTime(),
+		ExpiresAt: s.GetExpiresAt().AsTime(),
+		Active:    s.GetActive(),
+		Current:   s.GetCurrent(),
+	}
+	if s.GetRevokedAt() != nil {
+		revokedAt := s.GetRevokedAt().AsTime()
+		session.RevokedAt = &revokedAt
+	}
+	return session
+}
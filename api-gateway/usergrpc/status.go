@@ -0,0 +1,86 @@
+package usergrpc
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	"api-gateway/gen/userv1"
+)
+
+// reasonPreconditionRequired user-service 要求帶 If-Match 但請求沒有帶，
+// 需與 user-service grpcserver.ReasonPreconditionRequired 相同
+const reasonPreconditionRequired = "PRECONDITION_REQUIRED"
+
+// httpStatus 將 gRPC status code 對應到 HTTP status，對應方式與 user-service HTTP handler 的回應一致
+func httpStatus(code codes.Code) int {
+	switch code {
+	case codes.OK:
+		return http.StatusOK
+	case codes.InvalidArgument, codes.OutOfRange:
+		return http.StatusBadRequest
+	case codes.Unauthenticated:
+		return http.StatusUnauthorized
+	case codes.PermissionDenied:
+		return http.StatusForbidden
+	case codes.NotFound:
+		return http.StatusNotFound
+	case codes.AlreadyExists, codes.Aborted:
+		return http.StatusConflict
+	case codes.FailedPrecondition:
+		return http.StatusPreconditionFailed // user-service 只用在 If-Match 版本不符
+	case codes.ResourceExhausted:
+		return http.StatusTooManyRequests
+	case codes.Unimplemented:
+		return http.StatusNotImplemented
+	case codes.Unavailable:
+		return http.StatusBadGateway
+	case codes.DeadlineExceeded:
+		return http.StatusGatewayTimeout
+	default:
+		return http.StatusInternalServerError
+	}
+}
+
+// writeError 將 gRPC 呼叫的錯誤寫成與 user-service HTTP API 相同格式的 JSON：
+// {"error": "..."}，欄位驗證與密碼規則錯誤另外附上 details
+func writeError(c *gin.Context, err error) {
+	st := status.Convert(err)
+	code := httpStatus(st.Code())
+	body := gin.H{"error": st.Message()}
+
+	switch st.Code() {
+	case codes.Unavailable:
+		body["error"] = "下游服務無法連線"
+	case codes.DeadlineExceeded:
+		body["error"] = "下游服務逾時"
+	}
+
+	for _, d := range st.Details() {
+		detail, ok := d.(*userv1.ErrorDetail)
+		if !ok {
+			continue
+		}
+		if detail.GetReason() == reasonPreconditionRequired {
+			code = http.StatusPreconditionRequired
+		}
+		if violations := detail.GetFieldViolations(); len(violations) > 0 {
+			details := make([]gin.H, len(violations))
+			for i, v := range violations {
+				details[i] = gin.H{"field": v.GetField(), "message": v.GetMessage()}
+			}
+			body["details"] = details
+		}
+		if violations := detail.GetPolicyViolations(); len(violations) > 0 {
+			details := make([]gin.H, len(violations))
+			for i, v := range violations {
+				details[i] = gin.H{"rule": v.GetRule(), "message": v.GetMessage()}
+			}
+			body["details"] = details
+		}
+	}
+
+	c.JSON(code, body)
+}
@@ -0,0 +1,144 @@
+package usergrpc
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	"api-gateway/gen/userv1"
+)
+
+func TestHTTPStatus(t *testing.T) {
+	tests := []struct {
+		code codes.Code
+		want int
+	}{
+		{code: codes.OK, want: http.StatusOK},
+		{code: codes.InvalidArgument, want: http.StatusBadRequest},
+		{code: codes.OutOfRange, want: http.StatusBadRequest},
+		{code: codes.Unauthenticated, want: http.StatusUnauthorized},
+		{code: codes.PermissionDenied, want: http.StatusForbidden},
+		{code: codes.NotFound, want: http.StatusNotFound},
+		{code: codes.AlreadyExists, want: http.StatusConflict},
+		{code: codes.Aborted, want: http.StatusConflict},
+		{code: codes.FailedPrecondition, want: http.StatusPreconditionFailed},
+		{code: codes.ResourceExhausted, want: http.StatusTooManyRequests},
+		{code: codes.Unimplemented, want: http.StatusNotImplemented},
+		{code: codes.Unavailable, want: http.StatusBadGateway},
+		{code: codes.DeadlineExceeded, want: http.StatusGatewayTimeout},
+		{code: codes.Internal, want: http.StatusInternalServerError},
+		{code: codes.Unknown, want: http.StatusInternalServerError},
+		{code: codes.DataLoss, want: http.StatusInternalServerError},
+		{code: codes.Canceled, want: http.StatusInternalServerError},
+	}
+	for _, tt := range tests {
+		t.Run(tt.code.String(), func(t *testing.T) {
+			assert.Equal(t, tt.want, httpStatus(tt.code))
+		})
+	}
+}
+
+// withDetail 建立附帶 ErrorDetail 的 gRPC 錯誤
+func withDetail(t *testing.T, code codes.Code, message string, detail *userv1.ErrorDetail) error {
+	t.Helper()
+	st, err := status.New(code, message).WithDetails(detail)
+	require.NoError(t, err)
+	return st.Err()
+}
+
+func TestWriteError(t *testing.T) {
+	tests := []struct {
+		name     string
+		err      func(t *testing.T) error
+		wantCode int
+		wantBody string
+	}{
+		{
+			name:     "message is passed through",
+			err:      func(*testing.T) error { return status.Error(codes.AlreadyExists, "email already exists") },
+			wantCode: http.StatusConflict,
+			wantBody: `{"error":"email already exists"}`,
+		},
+		{
+			name:     "unavailable hides the transport error",
+			err:      func(*testing.T) error { return status.Error(codes.Unavailable, "connection refused") },
+			wantCode: http.StatusBadGateway,
+			wantBody: `{"error":"下游服務無法連線"}`,
+		},
+		{
+			name:     "deadline exceeded",
+			err:      func(*testing.T) error { return status.Error(codes.DeadlineExceeded, "context deadline exceeded") },
+			wantCode: http.StatusGatewayTimeout,
+			wantBody: `{"error":"下游服務逾時"}`,
+		},
+		{
+			name:     "non-status error",
+			err:      func(*testing.T) error { return errors.New("boom") },
+			wantCode: http.StatusInternalServerError,
+			wantBody: `{"error":"boom"}`,
+		},
+		{
+			name: "version mismatch",
+			err: func(t *testing.T) error {
+				return withDetail(t, codes.FailedPrecondition, "user version conflict", &userv1.ErrorDetail{Reason: "VERSION_MISMATCH"})
+			},
+			wantCode: http.StatusPreconditionFailed,
+			wantBody: `{"error":"user version conflict"}`,
+		},
+		{
+			name: "precondition required",
+			err: func(t *testing.T) error {
+				return withDetail(t, codes.FailedPrecondition, "If-Match header is required", &userv1.ErrorDetail{Reason: reasonPreconditionRequired})
+			},
+			wantCode: http.StatusPreconditionRequired,
+			wantBody: `{"error":"If-Match header is required"}`,
+		},
+		{
+			name: "field violations",
+			err: func(t *testing.T) error {
+				return withDetail(t, codes.InvalidArgument, "invalid user fields", &userv1.ErrorDetail{
+					Reason:          "INVALID_USER_FIELDS",
+					FieldViolations: []*userv1.FieldViolation{{Field: "timezone", Message: "unknown time zone"}},
+				})
+			},
+			wantCode: http.StatusBadRequest,
+			wantBody: `{"error":"invalid user fields","details":[{"field":"timezone","message":"unknown time zone"}]}`,
+		},
+		{
+			name: "policy violations",
+			err: func(t *testing.T) error {
+				return withDetail(t, codes.InvalidArgument, "password does not meet policy", &userv1.ErrorDetail{
+					Reason: "PASSWORD_POLICY",
+					PolicyViolations: []*userv1.PolicyViolation{
+						{Rule: "min_length", Message: "password must be at least 8 characters"},
+						{Rule: "digit", Message: "password must contain a digit"},
+					},
+				})
+			},
+			wantCode: http.StatusBadRequest,
+			wantBody: `{"error":"password does not meet policy","details":[` +
+				`{"rule":"min_length","message":"password must be at least 8 characters"},` +
+				`{"rule":"digit","message":"password must contain a digit"}]}`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			gin.SetMode(gin.TestMode)
+			w := httptest.NewRecorder()
+			c, _ := gin.CreateTestContext(w)
+
+			writeError(c, tt.err(t))
+
+			assert.Equal(t, tt.wantCode, w.Code)
+			assert.JSONEq(t, tt.wantBody, w.Body.String())
+		})
+	}
+}
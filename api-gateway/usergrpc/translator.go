@@ -0,0 +1,99 @@
+// Package usergrpc 將 /api/users 的 REST 請求轉成對 user-service 的 gRPC 呼叫，
+// 回應的 HTTP status、header 與 JSON 格式與直接轉發 HTTP 時相同，前端不需要知道底層走哪一種協定。
+package usergrpc
+
+import (
+	"context"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/metadata"
+
+	"api-gateway/gen/userv1"
+	"api-gateway/proxy"
+)
+
+// 轉成 gRPC metadata 的請求 header，名稱需與 user-service grpcserver 中的 metadata key 相同
+// traceparent（W3C Trace Context）不在簽章範圍內，只用於 log 對應
+var forwardedHeaders = []string{
+	"X-User-ID",
+	"X-User-Email",
+	"X-User-Roles",
+	"X-Session-ID",
+	"X-Request-ID",
+	"Traceparent",
+}
+
+// clientUserAgentKey gRPC 的 user-agent 會被 client 改寫，瀏覽器原本的值改用這個 key 傳遞
+const clientUserAgentKey = "x-client-user-agent"
+
+// DefaultTimeout 未設定時每個 gRPC 呼叫的逾時
+const DefaultTimeout = 10 * time.Second
+
+// Translator 持有共用的 gRPC 連線，提供 /api/users 各路由的 Gin handler
+type Translator struct {
+	client  userv1.UserServiceClient
+	timeout time.Duration
+}
+
+// Option 用來調整 Translator 的選用設定
+type Option func(*Translator)
+
+// WithTimeout 設定每個 gRPC 呼叫的逾時；客戶端的連線先斷開時會提早取消。
+// 路由已經以 middleware.Timeout 設定 deadline 時，以路由的 deadline 為準
+func WithTimeout(timeout time.Duration) Option {
+	return func(t *Translator) {
+		t.timeout = timeout
+	}
+}
+
+// New 建立 Translator，conn 需已掛上 proxy.Signer 的 interceptor，user-service 才會接受請求
+func New(conn grpc.ClientConnInterface, opts ...Option) *Translator {
+	t := &Translator{
+		client:  userv1.NewUserServiceClient(conn),
+		timeout: DefaultTimeout,
+	}
+	for _, opt := range opts {
+		opt(t)
+	}
+	return t
+}
+
+// callContext 建立一次 gRPC 呼叫用的 context：
+//   - 以客戶端請求的 context 為基礎，沒有路由 deadline 時加上逾時；deadline 會隨 gRPC 傳到 user-service
+//   - 將 RequireAuth 寫入的身份 header、request ID 與客戶端 IP 轉成 metadata
+func (t *Translator) callContext(c *gin.Context) (context.Context, context.CancelFunc) {
+	var ctx context.Context
+	var cancel context.CancelFunc
+	if _, ok := c.Request.Context().Deadline(); ok {
+		ctx, cancel = context.WithCancel(c.Request.Context())
+	} else {
+		ctx, cancel = context.WithTimeout(c.Request.Context(), t.timeout)
+	}
+
+	md := metadata.MD{}
+	for _, name := range forwardedHeaders {
+		if value := c.GetHeader(name); value != "" {
+			md.Set(name, value)
+		}
+	}
+	// 下游看到的連線來源是 gateway，改由簽章過的 metadata 告知真正的客戶端 IP（寫入稽核紀錄用）
+	md.Set(proxy.ClientIPHeader, c.ClientIP())
+	if ua := c.Request.UserAgent(); ua != "" {
+		md.Set(clientUserAgentKey, ua)
+	}
+
+	return metadata.NewOutgoingContext(ctx, md), cancel
+}
+
+// bindJSON 解析請求 body，格式錯誤時回 400
+// 欄位是否必填由 user-service 依 binding 規則檢查，這裡只負責解析
+func bindJSON(c *gin.Context, dst interface{}) bool {
+	if err := c.ShouldBindJSON(dst); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return false
+	}
+	return true
+}
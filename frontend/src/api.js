@@ -12,10 +12,61 @@ const api = axios.create({
 export const userAPI = {
   register: (userData) => api.post('/api/users/register', userData),
   login: (credentials) => api.post('/api/users/login', credentials),
+  loginMFA: (mfaToken, code) => api.post('/api/users/login/mfa', { mfa_token: mfaToken, code }),
+  forgotPassword: (email) => api.post('/api/users/password/forgot', { email }),
+  resetPassword: (token, newPassword) =>
+    api.post('/api/users/password/reset', { token, new_password: newPassword }),
   getUsers: () => api.get('/api/users'),
   getUser: (id) => api.get(`/api/users/${id}`),
-  updateUser: (id, userData) => api.put(`/api/users/${id}`, userData),
-  deleteUser: (id) => api.delete(`/api/users/${id}`),
+  // 目前登入的用戶，由 gateway 依 token 判斷身份，不需要傳 ID
+  getMe: () => api.get('/api/users/me'),
+  patchMe: (changes, etag) =>
+    api.patch('/api/users/me', changes, {
+      headers: {
+        'Content-Type': 'application/merge-patch+json',
+        ...(etag ? { 'If-Match': etag } : {}),
+      },
+    }),
+  deleteMe: (etag) => api.delete('/api/users/me', etag ? { headers: { 'If-Match': etag } } : undefined),
+  // etag 來自 getUser 回應的 ETag header，帶上後若資料已被他人修改會收到 412
+  updateUser: (id, userData, etag) =>
+    api.put(`/api/users/${id}`, userData, etag ? { headers: { 'If-Match': etag } } : undefined),
+  // 只送出要修改的欄位，值為 null 代表清除（JSON Merge Patch）
+  patchUser: (id, changes, etag) =>
+    api.patch(`/api/users/${id}`, changes, {
+      headers: {
+        'Content-Type': 'application/merge-patch+json',
+        ...(etag ? { 'If-Match': etag } : {}),
+      },
+    }),
+  deleteUser: (id, etag) =>
+    api.delete(`/api/users/${id}`, etag ? { headers: { 'If-Match': etag } } : undefined),
+  requestEmailChange: (id, newEmail, currentPassword) =>
+    api.post(`/api/users/${id}/email`, { new_email: newEmail, current_password: currentPassword }),
+  confirmEmailChange: (token) => api.post('/api/users/email/confirm', { token }),
+  changePassword: (id, currentPassword, newPassword) =>
+    api.put(`/api/users/${id}/password`, {
+      current_password: currentPassword,
+      new_password: newPassword,
+    }),
+};
+
+export const mfaAPI = {
+  enrollTOTP: (id) => api.post(`/api/users/${id}/mfa/totp`),
+  confirmTOTP: (id, code) => api.post(`/api/users/${id}/mfa/totp/confirm`, { code }),
+  disable: (id, code) => api.delete(`/api/users/${id}/mfa/totp`, { data: { code } }),
+};
+
+export const sessionAPI = {
+  // 登入紀錄，current 為 true 的是目前這個裝置
+  list: () => api.get('/api/users/me/sessions'),
+  revoke: (sessionId) => api.delete(`/api/users/me/sessions/${sessionId}`),
+};
+
+export const adminAPI = {
+  restoreUser: (id) => api.post(`/api/admin/users/${id}/restore`),
+  // params: { actor_id, target_id, action, from, to, limit, cursor }
+  listAuditEvents: (params) => api.get('/api/admin/audit-events', { params }),
 };
 
 export default api;
@@ -0,0 +1,128 @@
+// Package auth 簽發登入用的 JWT，HTTP handler 與 gRPC server 共用，兩邊簽出的 token 格式完全相同
+package auth
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"user-service/models"
+	"user-service/services"
+)
+
+// TokenTTL JWT 的有效期限，撤銷紀錄的保存時間也以此為準
+const TokenTTL = 24 * time.Hour
+
+// MFAChallengeTTL 帳密驗證通過後，輸入 MFA code 的時間限制
+const MFAChallengeTTL = 5 * time.Minute
+
+// mfaChallengePurpose 寫在 challenge token 裡，避免與其他用途的 token 混用
+const mfaChallengePurpose = "mfa_challenge"
+
+// ErrSessionStart 記錄登入 session 失敗，token 不會被簽發
+var ErrSessionStart = errors.New("failed to start session")
+
+// ErrInvalidMFAChallenge challenge token 無效、過期或用途不符
+var ErrInvalidMFAChallenge = errors.New("invalid or expired mfa token")
+
+func init() {
+	// iat 預設只精確到秒；撤銷時間點記到微秒，iat 也要同樣精確，
+	// API Gateway 才分得出改密碼後同一秒內重新登入拿到的 token 是在撤銷之後簽發的
+	jwt.TimePrecision = time.Microsecond
+}
+
+// Claims 定義 JWT payload 的內容
+type Claims struct {
+	UserID    string `json:"user_id"`
+	Email     string `json:"email"`
+	Role      string `json:"role"`
+	SessionID string `json:"sid,omitempty"` // 對應 user_sessions，撤銷 session 時 gateway 依此拒絕 token
+	jwt.RegisteredClaims
+}
+
+// mfaChallengeClaims MFA challenge token 的 payload
+type mfaChallengeClaims struct {
+	UserID  string `json:"user_id"`
+	Purpose string `json:"purpose"`
+	jwt.RegisteredClaims
+}
+
+// Issuer 以 JWT_SECRET 簽發 access token 與 MFA challenge token
+type Issuer struct {
+	secret string
+	now    func() time.Time
+}
+
+// NewIssuer 創建 Issuer，now 為簽發與驗證 token 時使用的現在時間
+func NewIssuer(secret string, now func() time.Time) *Issuer {
+	return &Issuer{secret: secret, now: now}
+}
+
+// IssueAccessToken 記錄這次登入的 session 並簽發 access token
+// 未開啟 session 紀錄時照常簽發，只是 token 沒有 sid、無法個別撤銷
+func (i *Issuer) IssueAccessToken(ctx context.Context, svc services.UserServiceInterface, user *models.User) (string, error) {
+	now := i.now()
+	expiresAt := now.Add(TokenTTL)
+
+	var sessionID string
+	session, err := svc.StartSession(ctx, user.ID, expiresAt)
+	switch {
+	case err == nil:
+		sessionID = session.ID
+	case !errors.Is(err, services.ErrSessionsDisabled):
+		return "", fmt.Errorf("%w: %v", ErrSessionStart, err)
+	}
+
+	claims := &Claims{
+		UserID:    user.ID,
+		Email:     user.Email,
+		Role:      user.Role,
+		SessionID: sessionID,
+		RegisteredClaims: jwt.RegisteredClaims{
+			ExpiresAt: jwt.NewNumericDate(expiresAt),
+			IssuedAt:  jwt.NewNumericDate(now),
+		},
+	}
+	return jwt.NewWithClaims(jwt.SigningMethodHS256, claims).SignedString([]byte(i.secret))
+}
+
+// mfaSigningKey 由 JWT_SECRET 衍生出 challenge token 專用的簽章 key
+// API Gateway 只知道原本的 JWT_SECRET，因此 challenge token 無法被當成 access token 使用
+func (i *Issuer) mfaSigningKey() []byte {
+	mac := hmac.New(sha256.New, []byte(i.secret))
+	mac.Write([]byte(mfaChallengePurpose))
+	return mac.Sum(nil)
+}
+
+// IssueMFAChallenge 簽發短效的 MFA challenge token
+func (i *Issuer) IssueMFAChallenge(userID string) (string, error) {
+	now := i.now()
+	claims := &mfaChallengeClaims{
+		UserID:  userID,
+		Purpose: mfaChallengePurpose,
+		RegisteredClaims: jwt.RegisteredClaims{
+			ExpiresAt: jwt.NewNumericDate(now.Add(MFAChallengeTTL)),
+			IssuedAt:  jwt.NewNumericDate(now),
+		},
+	}
+	return jwt.NewWithClaims(jwt.SigningMethodHS256, claims).SignedString(i.mfaSigningKey())
+}
+
+// ParseMFAChallenge 驗證 challenge token 並取出 user_id
+func (i *Issuer) ParseMFAChallenge(tokenString string) (string, error) {
+	claims := &mfaChallengeClaims{}
+	token, err := jwt.ParseWithClaims(tokenString, claims, func(token *jwt.Token) (interface{}, error) {
+		return i.mfaSigningKey(), nil
+	}, jwt.WithValidMethods([]string{jwt.SigningMethodHS256.Alg()}), jwt.WithTimeFunc(i.now))
+	if err != nil || !token.Valid {
+		return "", ErrInvalidMFAChallenge
+	}
+	if claims.Purpose != mfaChallengePurpose || claims.UserID == "" {
+		return "", ErrInvalidMFAChallenge
+	}
+	return claims.UserID, nil
+}
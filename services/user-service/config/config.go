@@ -1,52 +1,294 @@
 package config
 
-import "os"
+import (
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"strconv"
+	"time"
+)
+
+// 執行環境，production 會拒絕使用預設的 secret 啟動
+const (
+	EnvDevelopment = "development"
+	EnvProduction  = "production"
+)
 
 // Config 應用配置
+//
+// 每個欄位的值依序來自：Defaults → CONFIG_FILE 指定的 YAML 檔 → 環境變數（env tag）。
+// 標記 secret 的欄位也可以改用 <env>_FILE 指向檔案（例如 Docker secrets），印出設定時會被遮蔽
 type Config struct {
-	Port      string
-	JWTSecret string
-	Database  DatabaseConfig
-	Redis     RedisConfig
+	Environment    string `yaml:"environment" env:"APP_ENV"`
+	Port           string `yaml:"port" env:"PORT"`
+	GRPCPort       string `yaml:"grpc_port" env:"GRPC_PORT"`             // gRPC API，供 API Gateway 呼叫
+	GRPCReflection bool   `yaml:"grpc_reflection" env:"GRPC_REFLECTION"` // 開放 gRPC server reflection，方便用 grpcurl 除錯
+	HTTP2Cleartext bool   `yaml:"http2_cleartext" env:"HTTP2_CLEARTEXT"` // HTTP port 也接受 h2c（不加密的 HTTP/2），供 gateway 設定 USER_SERVICE_HTTP2 時使用
+	MetricsPort    string `yaml:"metrics_port" env:"METRICS_PORT"`       // Prometheus 指標，只應對內部網路開放；留空表示不開啟
+	MaxBodyBytes   int    `yaml:"max_body_bytes" env:"MAX_BODY_BYTES"`   // 請求 body 上限，在驗證 gateway 簽章前就套用，超過回 413
+	// 直接連線的來源在這些網段（IP 或 CIDR）內時，ClientIP 才採信 X-Forwarded-For；
+	// 需涵蓋 API Gateway 以及 gateway 前面信任的 proxy，否則稽核紀錄的 IP 會是 proxy 的位址
+	TrustedProxies []string             `yaml:"trusted_proxies" env:"TRUSTED_PROXIES"`
+	JWTSecret      string               `yaml:"jwt_secret" env:"JWT_SECRET" secret:"true"`
+	GatewayAuth    GatewayAuthConfig    `yaml:"gateway_auth"`
+	Database       DatabaseConfig       `yaml:"database"`
+	Redis          RedisConfig          `yaml:"redis"`
+	PasswordReset  PasswordResetConfig  `yaml:"password_reset"`
+	EmailChange    EmailChangeConfig    `yaml:"email_change"`
+	PasswordPolicy PasswordPolicyConfig `yaml:"password_policy"`
+	MFAIssuer      string               `yaml:"mfa_issuer" env:"MFA_ISSUER"` // 顯示在 authenticator App 上的服務名稱
+	PasswordHash   PasswordHashConfig   `yaml:"password_hash"`
+	UserPurge      UserPurgeConfig      `yaml:"user_purge"`
+	RequireIfMatch bool                 `yaml:"require_if_match" env:"REQUIRE_IF_MATCH"` // PUT / DELETE 用戶時是否強制帶 If-Match
+	Registry       RegistryConfig       `yaml:"registry"`
+	Shutdown       ShutdownConfig       `yaml:"shutdown"`
+	Health         HealthConfig         `yaml:"health"`
+}
+
+// GatewayAuthConfig 驗證請求確實來自 API Gateway 的設定
+type GatewayAuthConfig struct {
+	Secret  string        `yaml:"secret" env:"INTERNAL_AUTH_SECRET" secret:"true"` // 與 API Gateway 共用的 HMAC key
+	MaxSkew time.Duration `yaml:"max_skew" env:"GATEWAY_SIGNATURE_MAX_SKEW"`       // 簽章時間可容許的誤差
 }
 
 // DatabaseConfig 資料庫配置
 type DatabaseConfig struct {
-	Host     string
-	Port     string
-	User     string
-	Password string
-	DBName   string
+	Host     string `yaml:"host" env:"DB_HOST"`
+	Port     string `yaml:"port" env:"DB_PORT"`
+	User     string `yaml:"user" env:"DB_USER"`
+	Password string `yaml:"password" env:"DB_PASSWORD" secret:"true"`
+	DBName   string `yaml:"name" env:"DB_NAME"`
 }
 
 // RedisConfig Redis 配置
 type RedisConfig struct {
-	Host string
-	Port string
+	Host string `yaml:"host" env:"REDIS_HOST"`
+	Port string `yaml:"port" env:"REDIS_PORT"`
+}
+
+// PasswordResetConfig 忘記密碼流程配置
+type PasswordResetConfig struct {
+	URL string        `yaml:"url" env:"PASSWORD_RESET_URL"` // 前端重設密碼頁面，token 會附加在 query string
+	TTL time.Duration `yaml:"ttl" env:"PASSWORD_RESET_TTL"` // token 有效期限
+}
+
+// EmailChangeConfig 變更 email 流程配置
+type EmailChangeConfig struct {
+	URL string        `yaml:"url" env:"EMAIL_CHANGE_URL"` // 前端確認新 email 頁面，token 會附加在 query string
+	TTL time.Duration `yaml:"ttl" env:"EMAIL_CHANGE_TTL"` // 確認連結有效期限
+}
+
+// PasswordPolicyConfig 密碼強度規則配置
+type PasswordPolicyConfig struct {
+	MinLength            int  `yaml:"min_length" env:"PASSWORD_MIN_LENGTH"`
+	MaxLength            int  `yaml:"max_length" env:"PASSWORD_MAX_LENGTH"`
+	RequireUppercase     bool `yaml:"require_uppercase" env:"PASSWORD_REQUIRE_UPPERCASE"`
+	RequireLowercase     bool `yaml:"require_lowercase" env:"PASSWORD_REQUIRE_LOWERCASE"`
+	RequireDigit         bool `yaml:"require_digit" env:"PASSWORD_REQUIRE_DIGIT"`
+	RequireSymbol        bool `yaml:"require_symbol" env:"PASSWORD_REQUIRE_SYMBOL"`
+	DisallowPersonalInfo bool `yaml:"disallow_personal_info" env:"PASSWORD_DISALLOW_PERSONAL_INFO"`
+	RejectCommon         bool `yaml:"reject_common" env:"PASSWORD_REJECT_COMMON"`
 }
 
-// Load 從環境變數載入配置
-func Load() *Config {
+// PasswordHashConfig 密碼 hash 演算法與成本配置
+// 調高成本後，舊 hash 會在用戶下次登入成功時自動升級
+type PasswordHashConfig struct {
+	Algorithm         string `yaml:"algorithm" env:"PASSWORD_HASH_ALGORITHM"` // bcrypt 或 argon2id
+	BcryptCost        int    `yaml:"bcrypt_cost" env:"BCRYPT_COST"`
+	Argon2Memory      int    `yaml:"argon2_memory_kib" env:"ARGON2_MEMORY_KIB"` // KiB
+	Argon2Iterations  int    `yaml:"argon2_iterations" env:"ARGON2_ITERATIONS"`
+	Argon2Parallelism int    `yaml:"argon2_parallelism" env:"ARGON2_PARALLELISM"`
+}
+
+// UserPurgeConfig 軟刪除用戶的保留與清除排程
+type UserPurgeConfig struct {
+	Retention time.Duration `yaml:"retention" env:"DELETED_USER_RETENTION"` // 軟刪除後保留多久才永久刪除
+	Interval  time.Duration `yaml:"interval" env:"USER_PURGE_INTERVAL"`     // purge job 的執行間隔
+}
+
+// RegistryConfig 向 service registry 登記實例的設定，API Gateway 依此找到可用的 user-service
+type RegistryConfig struct {
+	Enabled       bool          `yaml:"enabled" env:"REGISTRY_ENABLED"`
+	ServiceName   string        `yaml:"service_name" env:"SERVICE_NAME"`
+	AdvertiseHost string        `yaml:"advertise_host" env:"SERVICE_ADVERTISE_HOST"` // 其他服務連到這個實例用的 host，預設為 hostname
+	TTL           time.Duration `yaml:"ttl" env:"REGISTRY_TTL"`                      // 沒有 heartbeat 多久後紀錄失效
+}
+
+// ShutdownConfig 收到 SIGTERM 後的關閉流程設定
+// DrainPeriod + Timeout 需小於容器的 stop grace period，否則會在關閉途中被強制終止
+type ShutdownConfig struct {
+	DrainPeriod time.Duration `yaml:"drain_period" env:"SHUTDOWN_DRAIN_PERIOD"` // readiness 轉為失敗後，等多久才停止接受新連線
+	Timeout     time.Duration `yaml:"timeout" env:"SHUTDOWN_TIMEOUT"`           // 等待進行中請求與背景工作結束的上限
+}
+
+// HealthConfig /readyz 相依服務檢查的設定
+type HealthConfig struct {
+	CheckTimeout  time.Duration `yaml:"check_timeout" env:"HEALTH_CHECK_TIMEOUT"`   // 每一項檢查的逾時
+	CacheTTL      time.Duration `yaml:"cache_ttl" env:"HEALTH_CACHE_TTL"`           // 檢查結果快取多久
+	ExposeDetails bool          `yaml:"expose_details" env:"HEALTH_EXPOSE_DETAILS"` // /readyz 回傳每一項檢查的結果與錯誤訊息，只應在內部網路開啟
+}
+
+// Defaults 回傳開發環境可直接使用的預設值
+// 其中的 secret 只適用於本機開發，production 模式下沿用會拒絕啟動
+func Defaults() *Config {
 	return &Config{
-		Port:      getEnv("PORT", "8081"),
-		JWTSecret: getEnv("JWT_SECRET", "dev-secret-change-in-production"),
+		Environment:    EnvDevelopment,
+		Port:           "8081",
+		GRPCPort:       "9081",
+		GRPCReflection: false,
+		HTTP2Cleartext: false,
+		MetricsPort:    "9091",
+		MaxBodyBytes:   1 << 20, // 1 MiB，與 API Gateway 的預設上限相同
+		// gateway 經由內部網路連線，預設信任 loopback 與私有網段
+		TrustedProxies: []string{"127.0.0.0/8", "::1/128", "10.0.0.0/8", "172.16.0.0/12", "192.168.0.0/16", "fc00::/7"},
+		JWTSecret:      "dev-secret-change-in-production",
+		GatewayAuth: GatewayAuthConfig{
+			Secret:  "dev-internal-secret-change-in-production",
+			MaxSkew: 30 * time.Second,
+		},
 		Database: DatabaseConfig{
-			Host:     getEnv("DB_HOST", "localhost"),
-			Port:     getEnv("DB_PORT", "5432"),
-			User:     getEnv("DB_USER", "admin"),
-			Password: getEnv("DB_PASSWORD", "admin123"),
-			DBName:   getEnv("DB_NAME", "userdb"),
+			Host:     "localhost",
+			Port:     "5432",
+			User:     "admin",
+			Password: "admin123",
+			DBName:   "userdb",
 		},
 		Redis: RedisConfig{
-			Host: getEnv("REDIS_HOST", "localhost"),
-			Port: getEnv("REDIS_PORT", "6379"),
+			Host: "localhost",
+			Port: "6379",
+		},
+		PasswordReset: PasswordResetConfig{
+			URL: "http://localhost:3000/reset-password",
+			TTL: time.Hour,
+		},
+		EmailChange: EmailChangeConfig{
+			URL: "http://localhost:3000/confirm-email",
+			TTL: 24 * time.Hour,
+		},
+		PasswordPolicy: PasswordPolicyConfig{
+			MinLength:            8,
+			MaxLength:            72,
+			RequireUppercase:     false,
+			RequireLowercase:     false,
+			RequireDigit:         true,
+			RequireSymbol:        false,
+			DisallowPersonalInfo: true,
+			RejectCommon:         true,
 		},
+		MFAIssuer: "Microservices Core",
+		PasswordHash: PasswordHashConfig{
+			Algorithm:         "argon2id",
+			BcryptCost:        12,
+			Argon2Memory:      64 * 1024,
+			Argon2Iterations:  3,
+			Argon2Parallelism: 2,
+		},
+		RequireIfMatch: false,
+		UserPurge: UserPurgeConfig{
+			Retention: 30 * 24 * time.Hour,
+			Interval:  time.Hour,
+		},
+		Registry: RegistryConfig{
+			Enabled:       true,
+			ServiceName:   "user-service",
+			AdvertiseHost: hostname(),
+			TTL:           15 * time.Second,
+		},
+		Shutdown: ShutdownConfig{
+			DrainPeriod: 5 * time.Second,
+			Timeout:     20 * time.Second,
+		},
+		Health: HealthConfig{
+			CheckTimeout:  2 * time.Second,
+			CacheTTL:      2 * time.Second,
+			ExposeDetails: false,
+		},
+	}
+}
+
+// Validate 檢查設定值是否合理，回傳所有問題而不是只有第一個
+func (c *Config) Validate() error {
+	var errs []error
+	check := func(ok bool, format string, args ...interface{}) {
+		if !ok {
+			errs = append(errs, fmt.Errorf(format, args...))
+		}
+	}
+
+	check(c.Environment == EnvDevelopment || c.Environment == EnvProduction,
+		"APP_ENV must be %q or %q, got %q", EnvDevelopment, EnvProduction, c.Environment)
+	check(validPort(c.Port), "PORT must be a port number, got %q", c.Port)
+	check(validPort(c.GRPCPort), "GRPC_PORT must be a port number, got %q", c.GRPCPort)
+	check(c.Port != c.GRPCPort, "PORT and GRPC_PORT must differ")
+	check(c.MetricsPort == "" || validPort(c.MetricsPort), "METRICS_PORT must be a port number, got %q", c.MetricsPort)
+	check(c.MetricsPort != c.Port && c.MetricsPort != c.GRPCPort, "METRICS_PORT must differ from PORT and GRPC_PORT")
+	check(c.MaxBodyBytes > 0, "MAX_BODY_BYTES must be positive")
+	for _, cidr := range c.TrustedProxies {
+		check(validIPOrCIDR(cidr), "TRUSTED_PROXIES must be an IP or CIDR, got %q", cidr)
+	}
+	check(c.JWTSecret != "", "JWT_SECRET is required")
+	check(c.GatewayAuth.Secret != "", "INTERNAL_AUTH_SECRET is required")
+	check(c.GatewayAuth.MaxSkew > 0, "GATEWAY_SIGNATURE_MAX_SKEW must be positive")
+
+	check(c.Database.Host != "", "DB_HOST is required")
+	check(validPort(c.Database.Port), "DB_PORT must be a port number, got %q", c.Database.Port)
+	check(c.Database.User != "", "DB_USER is required")
+	check(c.Database.DBName != "", "DB_NAME is required")
+	check(c.Redis.Host != "", "REDIS_HOST is required")
+	check(validPort(c.Redis.Port), "REDIS_PORT must be a port number, got %q", c.Redis.Port)
+
+	check(c.PasswordReset.URL != "", "PASSWORD_RESET_URL is required")
+	check(c.PasswordReset.TTL > 0, "PASSWORD_RESET_TTL must be positive")
+	check(c.EmailChange.URL != "", "EMAIL_CHANGE_URL is required")
+	check(c.EmailChange.TTL > 0, "EMAIL_CHANGE_TTL must be positive")
+
+	check(c.PasswordPolicy.MinLength > 0, "PASSWORD_MIN_LENGTH must be positive")
+	check(c.PasswordPolicy.MaxLength >= c.PasswordPolicy.MinLength, "PASSWORD_MAX_LENGTH must not be less than PASSWORD_MIN_LENGTH")
+	check(c.PasswordHash.Algorithm == "bcrypt" || c.PasswordHash.Algorithm == "argon2id",
+		"PASSWORD_HASH_ALGORITHM must be bcrypt or argon2id, got %q", c.PasswordHash.Algorithm)
+	check(c.PasswordHash.BcryptCost >= 4 && c.PasswordHash.BcryptCost <= 31, "BCRYPT_COST must be between 4 and 31")
+	check(c.PasswordHash.Argon2Memory > 0, "ARGON2_MEMORY_KIB must be positive")
+	check(c.PasswordHash.Argon2Iterations > 0, "ARGON2_ITERATIONS must be positive")
+	check(c.PasswordHash.Argon2Parallelism > 0 && c.PasswordHash.Argon2Parallelism <= 255, "ARGON2_PARALLELISM must be between 1 and 255")
+
+	check(c.UserPurge.Retention >= 0, "DELETED_USER_RETENTION must not be negative")
+	check(c.UserPurge.Interval > 0, "USER_PURGE_INTERVAL must be positive")
+	if c.Registry.Enabled {
+		check(c.Registry.ServiceName != "", "SERVICE_NAME is required when the registry is enabled")
+		check(c.Registry.AdvertiseHost != "", "SERVICE_ADVERTISE_HOST is required when the registry is enabled")
+		check(c.Registry.TTL >= 3*time.Second, "REGISTRY_TTL must be at least 3s")
+	}
+	check(c.Shutdown.DrainPeriod >= 0, "SHUTDOWN_DRAIN_PERIOD must not be negative")
+	check(c.Shutdown.Timeout > 0, "SHUTDOWN_TIMEOUT must be positive")
+	check(c.Health.CheckTimeout > 0, "HEALTH_CHECK_TIMEOUT must be positive")
+	check(c.Health.CacheTTL >= 0, "HEALTH_CACHE_TTL must not be negative")
+
+	if c.Environment == EnvProduction {
+		for _, name := range defaultSecrets(c, Defaults()) {
+			errs = append(errs, fmt.Errorf("refusing to start in production with the default %s", name))
+		}
 	}
+	return errors.Join(errs...)
+}
+
+func validIPOrCIDR(value string) bool {
+	if _, _, err := net.ParseCIDR(value); err == nil {
+		return true
+	}
+	return net.ParseIP(value) != nil
+}
+
+func validPort(port string) bool {
+	n, err := strconv.Atoi(port)
+	return err == nil && n > 0 && n <= 65535
 }
 
-func getEnv(key, defaultValue string) string {
-	if value := os.Getenv(key); value != "" {
-		return value
+// hostname 取得主機名稱，容器內即為 container ID，同一個 Docker network 內可以解析
+func hostname() string {
+	host, err := os.Hostname()
+	if err != nil || host == "" {
+		return "localhost"
 	}
-	return defaultValue
+	return host
 }
@@ -0,0 +1,166 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// writeFile 在暫存目錄寫入檔案並回傳路徑
+func writeFile(t *testing.T, name, content string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), name)
+	require.NoError(t, os.WriteFile(path, []byte(content), 0o600))
+	return path
+}
+
+func TestLoad_Defaults(t *testing.T) {
+	cfg, err := Load()
+	require.NoError(t, err)
+
+	assert.Equal(t, EnvDevelopment, cfg.Environment)
+	assert.Equal(t, "8081", cfg.Port)
+	assert.Equal(t, 30*time.Second, cfg.GatewayAuth.MaxSkew)
+	assert.Equal(t, "argon2id", cfg.PasswordHash.Algorithm)
+}
+
+func TestLoad_Precedence(t *testing.T) {
+	t.Setenv("CONFIG_FILE", writeFile(t, "config.yaml", `
+port: "9000"
+database:
+  host: db.internal
+  name: users
+password_policy:
+  min_length: 12
+user_purge:
+  interval: 30m
+`))
+	// 環境變數優先於 YAML
+	t.Setenv("DB_HOST", "db.override")
+	t.Setenv("PASSWORD_RESET_TTL", "15m")
+	t.Setenv("TRUSTED_PROXIES", "10.1.0.0/16, 10.2.0.5")
+
+	cfg, err := Load()
+	require.NoError(t, err)
+
+	assert.Equal(t, "9000", cfg.Port)
+	assert.Equal(t, "db.override", cfg.Database.Host)
+	assert.Equal(t, "users", cfg.Database.DBName)
+	assert.Equal(t, 12, cfg.PasswordPolicy.MinLength)
+	assert.Equal(t, 30*time.Minute, cfg.UserPurge.Interval)
+	assert.Equal(t, 15*time.Minute, cfg.PasswordReset.TTL)
+	assert.Equal(t, []string{"10.1.0.0/16", "10.2.0.5"}, cfg.TrustedProxies)
+	// 檔案與環境變數都沒設定的欄位維持預設值
+	assert.Equal(t, "5432", cfg.Database.Port)
+}
+
+func TestLoad_UnknownYAMLKey(t *testing.T) {
+	t.Setenv("CONFIG_FILE", writeFile(t, "config.yaml", "databse:\n  host: typo\n"))
+
+	_, err := Load()
+	assert.ErrorContains(t, err, "databse")
+}
+
+func TestLoad_InvalidEnv(t *testing.T) {
+	t.Setenv("PASSWORD_RESET_TTL", "an hour")
+	t.Setenv("BCRYPT_COST", "high")
+
+	// 格式錯誤不再默默改用預設值，而是一次列出所有錯誤
+	_, err := Load()
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "PASSWORD_RESET_TTL")
+	assert.Contains(t, err.Error(), "BCRYPT_COST")
+}
+
+func TestLoad_SecretFile(t *testing.T) {
+	t.Run("reads secret from file", func(t *testing.T) {
+		t.Setenv("JWT_SECRET_FILE", writeFile(t, "jwt_secret", "from-docker-secret\n"))
+
+		cfg, err := Load()
+		require.NoError(t, err)
+		assert.Equal(t, "from-docker-secret", cfg.JWTSecret)
+	})
+
+	t.Run("rejects both value and file", func(t *testing.T) {
+		t.Setenv("JWT_SECRET", "inline")
+		t.Setenv("JWT_SECRET_FILE", writeFile(t, "jwt_secret", "from-file"))
+
+		_, err := Load()
+		assert.ErrorContains(t, err, "JWT_SECRET and JWT_SECRET_FILE are both set")
+	})
+
+	t.Run("missing file", func(t *testing.T) {
+		t.Setenv("DB_PASSWORD_FILE", filepath.Join(t.TempDir(), "missing"))
+
+		_, err := Load()
+		assert.ErrorContains(t, err, "DB_PASSWORD_FILE")
+	})
+}
+
+func TestValidate(t *testing.T) {
+	t.Run("collects every problem", func(t *testing.T) {
+		cfg := Defaults()
+		cfg.Port = "http"
+		cfg.PasswordHash.Algorithm = "md5"
+		cfg.PasswordPolicy.MaxLength = 4
+		cfg.TrustedProxies = []string{"10.0.0.0/8", "gateway"}
+		cfg.MaxBodyBytes = 0
+
+		err := cfg.Validate()
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "PORT must be a port number")
+		assert.Contains(t, err.Error(), "PASSWORD_HASH_ALGORITHM")
+		assert.Contains(t, err.Error(), "PASSWORD_MAX_LENGTH")
+		assert.Contains(t, err.Error(), `TRUSTED_PROXIES must be an IP or CIDR, got "gateway"`)
+		assert.Contains(t, err.Error(), "MAX_BODY_BYTES must be positive")
+	})
+
+	t.Run("metrics port", func(t *testing.T) {
+		cfg := Defaults()
+		cfg.MetricsPort = cfg.GRPCPort
+		assert.ErrorContains(t, cfg.Validate(), "METRICS_PORT must differ")
+
+		cfg.MetricsPort = ""
+		assert.NoError(t, cfg.Validate(), "empty METRICS_PORT disables the metrics server")
+	})
+
+	t.Run("production refuses default secrets", func(t *testing.T) {
+		cfg := Defaults()
+		cfg.Environment = EnvProduction
+		cfg.JWTSecret = "a-real-secret"
+
+		err := cfg.Validate()
+		require.Error(t, err)
+		assert.NotContains(t, err.Error(), "JWT_SECRET")
+		assert.Contains(t, err.Error(), "default INTERNAL_AUTH_SECRET")
+		assert.Contains(t, err.Error(), "default DB_PASSWORD")
+	})
+
+	t.Run("production with own secrets", func(t *testing.T) {
+		cfg := Defaults()
+		cfg.Environment = EnvProduction
+		cfg.JWTSecret = "a-real-secret"
+		cfg.GatewayAuth.Secret = "another-real-secret"
+		cfg.Database.Password = "strong-password"
+
+		assert.NoError(t, cfg.Validate())
+	})
+}
+
+func TestRedacted(t *testing.T) {
+	cfg := Defaults()
+	cfg.JWTSecret = "super-secret"
+
+	out := strings.Join(cfg.Redacted(), "\n")
+	assert.NotContains(t, out, "super-secret")
+	assert.NotContains(t, out, "admin123")
+	assert.Contains(t, out, "jwt_secret=******")
+	assert.Contains(t, out, "database.password=******")
+	assert.Contains(t, out, "database.host=localhost")
+	assert.Contains(t, out, "gateway_auth.max_skew=30s")
+}
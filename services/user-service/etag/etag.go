@@ -0,0 +1,56 @@
+// Package etag 處理用戶資源的 ETag 與 If-Match，HTTP handler 與 gRPC server 共用同一套規則
+package etag
+
+import (
+	"errors"
+	"strconv"
+	"strings"
+
+	"user-service/repository"
+)
+
+// ParseIfMatch 可能回傳的錯誤，訊息直接作為回應內容
+var (
+	ErrRequired  = errors.New("If-Match header is required")
+	ErrWeak      = errors.New("weak ETag cannot be used with If-Match")
+	ErrMalformed = errors.New("If-Match must be a single quoted ETag")
+	ErrMismatch  = errors.New("ETag does not match current version")
+)
+
+// Format 以用戶的版本號產生 strong ETag，例如 "3"
+func Format(version int) string {
+	return `"` + strconv.Itoa(version) + `"`
+}
+
+// ParseIfMatch 從 If-Match 的值取出客戶端預期的版本
+//
+//   - 沒帶值：required 時回 ErrRequired，否則不檢查版本
+//   - "*"：只要用戶存在就視為符合
+//   - weak ETag（W/"3"）依 RFC 7232 不能用於 If-Match，一律視為不符
+//   - 只支援單一 ETag，格式錯誤回 ErrMalformed
+func ParseIfMatch(value string, required bool) (int, error) {
+	value = strings.TrimSpace(value)
+	if value == "" {
+		if required {
+			return 0, ErrRequired
+		}
+		return repository.AnyVersion, nil
+	}
+	if value == "*" {
+		return repository.AnyVersion, nil
+	}
+	if strings.HasPrefix(value, "W/") {
+		return 0, ErrWeak
+	}
+
+	unquoted, err := strconv.Unquote(value)
+	if err != nil || !strings.HasPrefix(value, `"`) {
+		return 0, ErrMalformed
+	}
+	version, err := strconv.Atoi(unquoted)
+	if err != nil || version <= 0 {
+		// 不是本服務產生的 ETag，不可能符合目前版本
+		return 0, ErrMismatch
+	}
+	return version, nil
+}
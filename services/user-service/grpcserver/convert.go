@@ -0,0 +1,73 @@
+package grpcserver
+
+import (
+	"time"
+
+	"google.golang.org/protobuf/types/known/timestamppb"
+	"user-service/gen/userv1"
+	"user-service/models"
+)
+
+func toProtoUser(u *models.User) *userv1.User {
+	return &userv1.User{
+		Id:          u.ID,
+		Email:       u.Email,
+		Username:    u.Username,
+		Role:        u.Role,
+		DisplayName: u.DisplayName,
+		Bio:         u.Bio,
+		AvatarUrl:   u.AvatarURL,
+		Locale:      u.Locale,
+		Timezone:    u.Timezone,
+		MfaEnabled:  u.MFAEnabled,
+		Version:     int64(u.Version),
+		CreatedAt:   timestamppb.New(u.CreatedAt),
+		UpdatedAt:   timestamppb.New(u.UpdatedAt),
+	}
+}
+
+func toProtoSession(s *models.Session) *userv1.Session {
+	session := &userv1.Session{
+		Id:        s.ID,
+		Ip:        s.IP,
+		UserAgent: s.UserAgent,
+		CreatedAt: timestamppb.New(s.CreatedAt),
+		ExpiresAt: timestamppb.New(s.ExpiresAt),
+		Active:    s.Active,
+		Current:   s.Current,
+	}
+	if s.RevokedAt != nil {
+		session.RevokedAt = timestamppb.New(*s.RevokedAt)
+	}
+	return session
+}
+
+func toProtoAuditEvent(e *models.AuditEvent) *userv1.AuditEvent {
+	event := &userv1.AuditEvent{
+		Id:         e.ID,
+		OccurredAt: timestamppb.New(e.OccurredAt),
+		ActorId:    e.ActorID,
+		TargetId:   e.TargetID,
+		Action:     e.Action,
+		Ip:         e.IP,
+		UserAgent:  e.UserAgent,
+		RequestId:  e.RequestID,
+		Metadata:   e.Metadata,
+	}
+	if len(e.Changes) > 0 {
+		event.Changes = make(map[string]*userv1.FieldChange, len(e.Changes))
+		for field, change := range e.Changes {
+			event.Changes[field] = &userv1.FieldChange{From: change.From, To: change.To}
+		}
+	}
+	return event
+}
+
+// optionalTime 未設定的 Timestamp 代表不篩選
+func optionalTime(ts *timestamppb.Timestamp) *time.Time {
+	if ts == nil {
+		return nil
+	}
+	t := ts.AsTime()
+	return &t
+}
@@ -0,0 +1,106 @@
+package grpcserver
+
+import (
+	"errors"
+
+	"github.com/gin-gonic/gin/binding"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"user-service/etag"
+	"user-service/gen/userv1"
+	"user-service/security"
+	"user-service/services"
+)
+
+// ErrorDetail.reason 的值，API Gateway 依此決定 HTTP status 與錯誤格式
+const (
+	ReasonInvalidUserFields    = "INVALID_USER_FIELDS"
+	ReasonPasswordPolicy       = "PASSWORD_POLICY"
+	ReasonPreconditionRequired = "PRECONDITION_REQUIRED"
+	ReasonVersionMismatch      = "VERSION_MISMATCH"
+)
+
+// statusWithDetail 建立附帶 ErrorDetail 的錯誤
+func statusWithDetail(code codes.Code, message string, detail *userv1.ErrorDetail) error {
+	st, err := status.New(code, message).WithDetails(detail)
+	if err != nil {
+		return status.Error(code, message)
+	}
+	return st.Err()
+}
+
+// validate 以與 gin binding 相同的 struct tag 規則檢查請求，錯誤訊息也與 HTTP 相同
+func validate(req interface{}) error {
+	if err := binding.Validator.ValidateStruct(req); err != nil {
+		return status.Error(codes.InvalidArgument, err.Error())
+	}
+	return nil
+}
+
+// detailedError 欄位驗證與密碼規則錯誤逐項列出原因，對應 HTTP 回應的 details
+// 不是這兩種錯誤時回傳 nil
+func detailedError(err error) error {
+	var validationErr *services.ValidationError
+	if errors.As(err, &validationErr) {
+		detail := &userv1.ErrorDetail{Reason: ReasonInvalidUserFields}
+		for _, f := range validationErr.Fields {
+			detail.FieldViolations = append(detail.FieldViolations, &userv1.FieldViolation{Field: f.Field, Message: f.Message})
+		}
+		return statusWithDetail(codes.InvalidArgument, "invalid user fields", detail)
+	}
+
+	var policyErr *security.PolicyError
+	if errors.As(err, &policyErr) {
+		detail := &userv1.ErrorDetail{Reason: ReasonPasswordPolicy}
+		for _, v := range policyErr.Violations {
+			detail.PolicyViolations = append(detail.PolicyViolations, &userv1.PolicyViolation{Rule: v.Rule, Message: v.Message})
+		}
+		return statusWithDetail(codes.InvalidArgument, "password does not meet policy", detail)
+	}
+
+	return nil
+}
+
+// ifMatchError 將 If-Match 解析錯誤轉成 gRPC status
+func ifMatchError(err error) error {
+	switch {
+	case errors.Is(err, etag.ErrRequired):
+		return statusWithDetail(codes.FailedPrecondition, err.Error(), &userv1.ErrorDetail{Reason: ReasonPreconditionRequired})
+	case errors.Is(err, etag.ErrMalformed):
+		return status.Error(codes.InvalidArgument, err.Error())
+	default:
+		return statusWithDetail(codes.FailedPrecondition, err.Error(), &userv1.ErrorDetail{Reason: ReasonVersionMismatch})
+	}
+}
+
+// serviceError 將 service 層的錯誤轉成 gRPC status，對應關係與 HTTP handler 一致
+func serviceError(err error) error {
+	if detailed := detailedError(err); detailed != nil {
+		return detailed
+	}
+
+	code := codes.Internal
+	switch {
+	case errors.Is(err, services.ErrUserNotFound), errors.Is(err, services.ErrSessionNotFound):
+		code = codes.NotFound
+	case errors.Is(err, services.ErrVersionConflict):
+		return statusWithDetail(codes.FailedPrecondition, err.Error(), &userv1.ErrorDetail{Reason: ReasonVersionMismatch})
+	case errors.Is(err, services.ErrInvalidCurrentPassword):
+		code = codes.PermissionDenied
+	case errors.Is(err, services.ErrEmailTaken), errors.Is(err, services.ErrMFAAlreadyEnabled):
+		code = codes.AlreadyExists
+	case errors.Is(err, services.ErrMFANotEnrolled):
+		code = codes.Aborted
+	case errors.Is(err, services.ErrInvalidResetToken),
+		errors.Is(err, services.ErrInvalidEmailChangeToken),
+		errors.Is(err, services.ErrInvalidMFACode):
+		code = codes.InvalidArgument
+	case errors.Is(err, services.ErrPasswordResetDisabled),
+		errors.Is(err, services.ErrEmailChangeDisabled),
+		errors.Is(err, services.ErrMFADisabled),
+		errors.Is(err, services.ErrSessionsDisabled),
+		errors.Is(err, services.ErrAuditDisabled):
+		code = codes.Unimplemented
+	}
+	return status.Error(code, err.Error())
+}
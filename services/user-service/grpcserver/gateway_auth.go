@@ -0,0 +1,101 @@
+package grpcserver
+
+import (
+	"context"
+	"strconv"
+	"strings"
+	"time"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/proto"
+	"user-service/security"
+)
+
+// GatewaySignatureMethod 計算 gRPC 請求簽章時使用的 method，gRPC 在 HTTP/2 上一律是 POST
+const GatewaySignatureMethod = "POST"
+
+// unsignedServices 不需要 gateway 簽章的 service：
+// health check 由容器與負載平衡器直接呼叫，reflection 只提供 schema、不碰用戶資料
+var unsignedServices = []string{
+	"/grpc.health.v1.Health/",
+	"/grpc.reflection.v1.ServerReflection/",
+	"/grpc.reflection.v1alpha.ServerReflection/",
+}
+
+// RequireGatewaySignature 只接受經由 API Gateway 轉發、附有合法簽章的 gRPC 請求，
+// 與 HTTP 的 middleware.RequireGatewaySignature 相同，避免 gRPC port 成為繞過 gateway 的入口
+//
+// 簽章的 path 為完整的 method 名稱（例如 /user.v1.UserService/GetUser），
+// body 為請求 message 以 deterministic 方式序列化的結果
+func RequireGatewaySignature(secret string, maxSkew time.Duration) grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+		if isUnsigned(info.FullMethod) {
+			return handler(ctx, req)
+		}
+
+		msg, ok := req.(proto.Message)
+		if !ok {
+			return nil, status.Error(codes.Internal, "unexpected request type")
+		}
+		body, err := proto.MarshalOptions{Deterministic: true}.Marshal(msg)
+		if err != nil {
+			return nil, status.Error(codes.Internal, "failed to encode request")
+		}
+
+		if err := verifySignature(ctx, secret, maxSkew, info.FullMethod, body); err != nil {
+			return nil, err
+		}
+		return handler(ctx, req)
+	}
+}
+
+// StreamRequireGatewaySignature RequireGatewaySignature 的 stream 版本
+// 建立 stream 時還沒有任何 message，簽章的 body 為空，只保護 method 與 metadata 中的身份
+func StreamRequireGatewaySignature(secret string, maxSkew time.Duration) grpc.StreamServerInterceptor {
+	return func(srv interface{}, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+		if !isUnsigned(info.FullMethod) {
+			if err := verifySignature(ss.Context(), secret, maxSkew, info.FullMethod, nil); err != nil {
+				return err
+			}
+		}
+		return handler(srv, ss)
+	}
+}
+
+func isUnsigned(fullMethod string) bool {
+	for _, prefix := range unsignedServices {
+		if strings.HasPrefix(fullMethod, prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+// verifySignature 檢查 metadata 中的時間戳與簽章
+func verifySignature(ctx context.Context, secret string, maxSkew time.Duration, fullMethod string, body []byte) error {
+	md, _ := metadata.FromIncomingContext(ctx)
+	timestamp := firstValue(md, strings.ToLower(security.GatewayTimestampHeader))
+	signature := firstValue(md, strings.ToLower(security.GatewaySignatureHeader))
+	if timestamp == "" || signature == "" {
+		return status.Error(codes.Unauthenticated, "missing gateway signature")
+	}
+
+	// 限制時間窗，攔截到的請求無法在之後重送
+	signedAt, err := strconv.ParseInt(timestamp, 10, 64)
+	if err != nil {
+		return status.Error(codes.Unauthenticated, "invalid gateway timestamp")
+	}
+	if skew := time.Since(time.Unix(signedAt, 0)); skew > maxSkew || skew < -maxSkew {
+		return status.Error(codes.Unauthenticated, "gateway signature expired")
+	}
+
+	expected := security.GatewaySignature(secret, GatewaySignatureMethod, fullMethod, "",
+		timestamp, body, signedHeader(md))
+	if !security.VerifyGatewaySignature(expected, signature) {
+		return status.Error(codes.Unauthenticated, "invalid gateway signature")
+	}
+	return nil
+}
@@ -0,0 +1,100 @@
+package grpcserver
+
+import (
+	"context"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/proto"
+	"user-service/gen/userv1"
+	"user-service/security"
+)
+
+const testSecret = "internal-secret"
+
+var getUserInfo = &grpc.UnaryServerInfo{FullMethod: userv1.UserService_GetUser_FullMethodName}
+
+// signedContext 模擬 API Gateway 簽章後的 metadata
+func signedContext(secret string, signedAt time.Time, req proto.Message, md metadata.MD) context.Context {
+	body, _ := proto.MarshalOptions{Deterministic: true}.Marshal(req)
+	ts := strconv.FormatInt(signedAt.Unix(), 10)
+	md = md.Copy()
+	md.Set("x-gateway-timestamp", ts)
+	md.Set("x-gateway-signature", security.GatewaySignature(secret, GatewaySignatureMethod,
+		getUserInfo.FullMethod, "", ts, body, signedHeader(md)))
+	return metadata.NewIncomingContext(context.Background(), md)
+}
+
+// callInterceptor 以 GetUser 的資訊呼叫 interceptor，回傳 handler 是否被執行與錯誤
+func callInterceptor(ctx context.Context, req proto.Message) (bool, error) {
+	called := false
+	_, err := RequireGatewaySignature(testSecret, 30*time.Second)(ctx, req, getUserInfo,
+		func(ctx context.Context, req interface{}) (interface{}, error) {
+			called = true
+			return nil, nil
+		})
+	return called, err
+}
+
+func TestRequireGatewaySignature(t *testing.T) {
+	req := &userv1.GetUserRequest{Id: "abc-123"}
+	identity := metadata.Pairs(MetadataUserID, "uuid-001", MetadataRequestID, "req-42")
+
+	t.Run("valid signature", func(t *testing.T) {
+		called, err := callInterceptor(signedContext(testSecret, time.Now(), req, identity), req)
+
+		assert.NoError(t, err)
+		assert.True(t, called)
+	})
+
+	t.Run("unsigned request", func(t *testing.T) {
+		called, err := callInterceptor(metadata.NewIncomingContext(context.Background(), identity), req)
+
+		assert.Equal(t, codes.Unauthenticated, status.Code(err))
+		assert.False(t, called)
+	})
+
+	t.Run("wrong secret", func(t *testing.T) {
+		_, err := callInterceptor(signedContext("other-secret", time.Now(), req, identity), req)
+
+		assert.Equal(t, codes.Unauthenticated, status.Code(err))
+	})
+
+	t.Run("expired signature", func(t *testing.T) {
+		_, err := callInterceptor(signedContext(testSecret, time.Now().Add(-time.Minute), req, identity), req)
+
+		assert.Equal(t, codes.Unauthenticated, status.Code(err))
+	})
+
+	t.Run("tampered message", func(t *testing.T) {
+		ctx := signedContext(testSecret, time.Now(), req, identity)
+		_, err := callInterceptor(ctx, &userv1.GetUserRequest{Id: "someone-else"})
+
+		assert.Equal(t, codes.Unauthenticated, status.Code(err))
+	})
+
+	t.Run("tampered identity", func(t *testing.T) {
+		ctx := signedContext(testSecret, time.Now(), req, identity)
+		md, _ := metadata.FromIncomingContext(ctx)
+		md = md.Copy()
+		md.Set(MetadataUserID, "uuid-002")
+		_, err := callInterceptor(metadata.NewIncomingContext(context.Background(), md), req)
+
+		assert.Equal(t, codes.Unauthenticated, status.Code(err))
+	})
+	t.Run("tampered client ip", func(t *testing.T) {
+		ctx := signedContext(testSecret, time.Now(), req, metadata.Pairs(MetadataUserID, "uuid-001", MetadataClientIP, "203.0.113.7"))
+		md, _ := metadata.FromIncomingContext(ctx)
+		md = md.Copy()
+		md.Set(MetadataClientIP, "198.51.100.1")
+		_, err := callInterceptor(metadata.NewIncomingContext(context.Background(), md), req)
+
+		assert.Equal(t, codes.Unauthenticated, status.Code(err))
+	})
+}
@@ -0,0 +1,193 @@
+package grpcserver
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"log/slog"
+	"runtime/debug"
+	"strings"
+	"time"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/peer"
+	"google.golang.org/grpc/status"
+)
+
+// 與 HTTP 的 Gin middleware 對應的 interceptor：
+//
+//	Recovery          ↔ gin.Recovery
+//	RequestID         ↔ gateway 的 middleware.RequestID（直接連進來、沒帶 request ID 的呼叫也能追蹤）
+//	Logger            ↔ gin.Logger
+//	Metrics           每個 method 的呼叫次數與耗時
+//	RequireGatewaySignature ↔ middleware.RequireGatewaySignature
+//
+// 建議依上面的順序串接：recovery 在最外層才能攔到所有 panic，logger 與 metrics 在驗證之前才記錄得到被拒絕的呼叫
+
+// MetadataTraceParent W3C Trace Context，gateway 或客戶端有帶時原樣記錄，方便與其他系統的 trace 對應
+const MetadataTraceParent = "traceparent"
+
+// maxRequestIDLength 與 gateway 相同，過長的 request ID 重新產生
+const maxRequestIDLength = 128
+
+// wrappedStream 讓 stream interceptor 能替換 handler 看到的 context
+type wrappedStream struct {
+	grpc.ServerStream
+	ctx context.Context
+}
+
+func (s *wrappedStream) Context() context.Context {
+	return s.ctx
+}
+
+// ── Recovery ─────────────────────────────────────────────────────────────────
+
+// UnaryRecovery 攔截 handler 的 panic，記錄 stack 後回傳 Internal，避免整個服務崩潰
+func UnaryRecovery(logger *slog.Logger) grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (resp interface{}, err error) {
+		defer func() {
+			if r := recover(); r != nil {
+				err = recovered(ctx, logger, info.FullMethod, r)
+			}
+		}()
+		return handler(ctx, req)
+	}
+}
+
+// StreamRecovery UnaryRecovery 的 stream 版本
+func StreamRecovery(logger *slog.Logger) grpc.StreamServerInterceptor {
+	return func(srv interface{}, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) (err error) {
+		defer func() {
+			if r := recover(); r != nil {
+				err = recovered(ss.Context(), logger, info.FullMethod, r)
+			}
+		}()
+		return handler(srv, ss)
+	}
+}
+
+func recovered(ctx context.Context, logger *slog.Logger, method string, r interface{}) error {
+	logger.Error("grpc panic recovered",
+		"method", method,
+		"request_id", RequestIDFromContext(ctx),
+		"panic", r,
+		"stack", string(debug.Stack()),
+	)
+	return status.Error(codes.Internal, "internal server error")
+}
+
+// ── Request ID ───────────────────────────────────────────────────────────────
+
+// UnaryRequestID 確保每個呼叫都帶有 request ID：沿用 metadata 中的 x-request-id，沒有就產生一個，
+// 稽核紀錄與 log 都以此為準，並以 response header 回傳給呼叫端
+func UnaryRequestID() grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+		ctx, id := withRequestID(ctx)
+		_ = grpc.SetHeader(ctx, metadata.Pairs(MetadataRequestID, id))
+		return handler(ctx, req)
+	}
+}
+
+// StreamRequestID UnaryRequestID 的 stream 版本
+func StreamRequestID() grpc.StreamServerInterceptor {
+	return func(srv interface{}, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+		ctx, id := withRequestID(ss.Context())
+		_ = ss.SetHeader(metadata.Pairs(MetadataRequestID, id))
+		return handler(srv, &wrappedStream{ServerStream: ss, ctx: ctx})
+	}
+}
+
+// requestIDKey 存放這次呼叫 request ID 的 context key
+type requestIDKey struct{}
+
+// withRequestID 產生的 request ID 放在 context value，不改動 incoming metadata：
+// x-request-id 在 gateway 簽章範圍內，改動後簽章驗證會失敗
+func withRequestID(ctx context.Context) (context.Context, string) {
+	md, _ := metadata.FromIncomingContext(ctx)
+	id := firstValue(md, MetadataRequestID)
+	if id == "" || len(id) > maxRequestIDLength {
+		id = newRequestID()
+	}
+	return context.WithValue(ctx, requestIDKey{}, id), id
+}
+
+// newRequestID 產生 16 bytes 的隨機 hex 字串
+func newRequestID() string {
+	b := make([]byte, 16)
+	rand.Read(b)
+	return hex.EncodeToString(b)
+}
+
+// RequestIDFromContext 取出這次呼叫的 request ID，沒有掛 RequestID interceptor 時直接讀 metadata
+func RequestIDFromContext(ctx context.Context) string {
+	if id, ok := ctx.Value(requestIDKey{}).(string); ok {
+		return id
+	}
+	md, _ := metadata.FromIncomingContext(ctx)
+	return firstValue(md, MetadataRequestID)
+}
+
+// ── Logger ───────────────────────────────────────────────────────────────────
+
+// UnaryLogger 每個呼叫結束後記錄一行結構化 log：method、status code、耗時、呼叫者與 request ID
+func UnaryLogger(logger *slog.Logger) grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+		start := time.Now()
+		resp, err := handler(ctx, req)
+		logCall(ctx, logger, info.FullMethod, start, err)
+		return resp, err
+	}
+}
+
+// StreamLogger UnaryLogger 的 stream 版本，stream 結束時才記錄
+func StreamLogger(logger *slog.Logger) grpc.StreamServerInterceptor {
+	return func(srv interface{}, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+		start := time.Now()
+		err := handler(srv, ss)
+		logCall(ss.Context(), logger, info.FullMethod, start, err)
+		return err
+	}
+}
+
+func logCall(ctx context.Context, logger *slog.Logger, method string, start time.Time, err error) {
+	code := status.Code(err)
+	md, _ := metadata.FromIncomingContext(ctx)
+
+	attrs := []any{
+		"method", method,
+		"code", code.String(),
+		"latency", time.Since(start),
+		"request_id", RequestIDFromContext(ctx),
+	}
+	if userID := firstValue(md, MetadataUserID); userID != "" {
+		attrs = append(attrs, "user_id", userID)
+	}
+	if traceParent := firstValue(md, MetadataTraceParent); traceParent != "" {
+		attrs = append(attrs, "traceparent", traceParent)
+	}
+	if p, ok := peer.FromContext(ctx); ok {
+		attrs = append(attrs, "peer", p.Addr.String())
+	}
+	if err != nil {
+		attrs = append(attrs, "error", status.Convert(err).Message())
+	}
+
+	// 伺服器端的錯誤才需要注意，4xx 類的錯誤（找不到、驗證失敗等）只是一般的呼叫結果
+	level := slog.LevelInfo
+	switch code {
+	case codes.Internal, codes.Unknown, codes.DataLoss, codes.Unavailable:
+		level = slog.LevelError
+	}
+	logger.Log(ctx, level, "grpc request", attrs...)
+}
+
+// serviceAndMethod 將 /user.v1.UserService/GetUser 拆成 service 與 method 名稱
+func serviceAndMethod(fullMethod string) (string, string) {
+	fullMethod = strings.TrimPrefix(fullMethod, "/")
+	if i := strings.LastIndex(fullMethod, "/"); i >= 0 {
+		return fullMethod[:i], fullMethod[i+1:]
+	}
+	return "unknown", fullMethod
+}
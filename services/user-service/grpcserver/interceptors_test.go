@@ -0,0 +1,122 @@
+package grpcserver
+
+import (
+	"bytes"
+	"context"
+	"log/slog"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"user-service/gen/userv1"
+)
+
+// testLogger 將 log 寫進 buffer，方便檢查內容
+func testLogger() (*slog.Logger, *bytes.Buffer) {
+	var buf bytes.Buffer
+	return slog.New(slog.NewTextHandler(&buf, nil)), &buf
+}
+
+func TestUnaryRecovery(t *testing.T) {
+	logger, buf := testLogger()
+
+	_, err := UnaryRecovery(logger)(context.Background(), nil, getUserInfo,
+		func(ctx context.Context, req interface{}) (interface{}, error) {
+			panic("boom")
+		})
+
+	assert.Equal(t, codes.Internal, status.Code(err))
+	// 內部細節只寫進 log，不回傳給呼叫端
+	assert.NotContains(t, status.Convert(err).Message(), "boom")
+	assert.Contains(t, buf.String(), "boom")
+}
+
+func TestUnaryRequestID(t *testing.T) {
+	run := func(ctx context.Context) (seen string, meta string) {
+		UnaryRequestID()(ctx, nil, getUserInfo, func(ctx context.Context, req interface{}) (interface{}, error) {
+			seen = RequestIDFromContext(ctx)
+			meta = requestMeta(ctx).RequestID
+			return nil, nil
+		})
+		return seen, meta
+	}
+
+	t.Run("keeps request id from gateway", func(t *testing.T) {
+		seen, meta := run(callerContext(MetadataRequestID, "req-42"))
+
+		assert.Equal(t, "req-42", seen)
+		assert.Equal(t, "req-42", meta)
+	})
+
+	t.Run("generates one when missing", func(t *testing.T) {
+		seen, meta := run(context.Background())
+
+		assert.Len(t, seen, 32)
+		assert.Equal(t, seen, meta)
+	})
+
+	t.Run("does not break the gateway signature", func(t *testing.T) {
+		// 沒帶 request ID 的簽章請求：產生 request ID 後，簽章仍須以原本的 metadata 驗證
+		req := &userv1.GetUserRequest{Id: "abc-123"}
+		ctx := signedContext(testSecret, time.Now(), req, metadata.Pairs(MetadataUserID, "uuid-001"))
+
+		_, err := UnaryRequestID()(ctx, req, getUserInfo, func(ctx context.Context, req interface{}) (interface{}, error) {
+			return RequireGatewaySignature(testSecret, 30*time.Second)(ctx, req, getUserInfo,
+				func(ctx context.Context, req interface{}) (interface{}, error) { return nil, nil })
+		})
+
+		assert.NoError(t, err)
+	})
+}
+
+func TestUnaryLogger(t *testing.T) {
+	logger, buf := testLogger()
+	ctx := callerContext(MetadataUserID, "uuid-001", MetadataRequestID, "req-42")
+
+	UnaryLogger(logger)(ctx, nil, getUserInfo, func(ctx context.Context, req interface{}) (interface{}, error) {
+		return nil, status.Error(codes.NotFound, "user not found")
+	})
+
+	line := buf.String()
+	assert.Contains(t, line, "method=/user.v1.UserService/GetUser")
+	assert.Contains(t, line, "code=NotFound")
+	assert.Contains(t, line, "request_id=req-42")
+	assert.Contains(t, line, "user_id=uuid-001")
+	assert.Contains(t, line, "level=INFO")
+}
+
+func TestMetrics(t *testing.T) {
+	reg := prometheus.NewRegistry()
+	m := NewMetrics(reg)
+	interceptor := m.UnaryInterceptor()
+
+	ok := func(ctx context.Context, req interface{}) (interface{}, error) { return nil, nil }
+	notFound := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return nil, status.Error(codes.NotFound, "user not found")
+	}
+	interceptor(context.Background(), nil, getUserInfo, ok)
+	interceptor(context.Background(), nil, getUserInfo, ok)
+	interceptor(context.Background(), nil, getUserInfo, notFound)
+
+	assert.Equal(t, 2.0, testutil.ToFloat64(m.handled.WithLabelValues("user.v1.UserService", "GetUser", "OK")))
+	assert.Equal(t, 1.0, testutil.ToFloat64(m.handled.WithLabelValues("user.v1.UserService", "GetUser", "NotFound")))
+	assert.Equal(t, 1, testutil.CollectAndCount(m.latency))
+}
+
+func TestStreamRequireGatewaySignature_HealthIsExempt(t *testing.T) {
+	interceptor := StreamRequireGatewaySignature(testSecret, 30*time.Second)
+	stream := &wrappedStream{ctx: context.Background()}
+	handler := func(srv interface{}, ss grpc.ServerStream) error { return nil }
+
+	err := interceptor(nil, stream, &grpc.StreamServerInfo{FullMethod: "/grpc.health.v1.Health/Watch"}, handler)
+	assert.NoError(t, err)
+
+	err = interceptor(nil, stream, &grpc.StreamServerInfo{FullMethod: "/user.v1.UserService/Anything"}, handler)
+	assert.Equal(t, codes.Unauthenticated, status.Code(err))
+}
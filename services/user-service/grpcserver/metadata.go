@@ -0,0 +1,114 @@
+package grpcserver
+
+import (
+	"context"
+	"net"
+	"net/http"
+	"strings"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/peer"
+	"google.golang.org/grpc/status"
+	"user-service/models"
+	"user-service/security"
+)
+
+// API Gateway 透過 metadata 傳遞的呼叫者身份與來源資訊，名稱為對應 HTTP header 的小寫
+const (
+	MetadataUserID          = "x-user-id"
+	MetadataUserEmail       = "x-user-email"
+	MetadataUserRoles       = "x-user-roles" // 以逗號分隔
+	MetadataSessionID       = "x-session-id"
+	MetadataRequestID       = "x-request-id"
+	MetadataClientIP        = "x-client-ip"         // gateway 解析出的客戶端 IP，對應 security.ClientIPHeader
+	MetadataClientUserAgent = "x-client-user-agent" // gRPC 的 user-agent 會被 client 改寫，原始值另外傳
+)
+
+// firstValue 取出 metadata 中 key 的第一個值
+func firstValue(md metadata.MD, key string) string {
+	if values := md.Get(key); len(values) > 0 {
+		return values[0]
+	}
+	return ""
+}
+
+// currentIdentity 從 metadata 取出 gateway 驗證過的呼叫者身份，未登入的請求回傳 false
+func currentIdentity(ctx context.Context) (models.Identity, bool) {
+	md, _ := metadata.FromIncomingContext(ctx)
+	userID := firstValue(md, MetadataUserID)
+	if userID == "" {
+		return models.Identity{}, false
+	}
+
+	identity := models.Identity{
+		UserID:    userID,
+		Email:     firstValue(md, MetadataUserEmail),
+		SessionID: firstValue(md, MetadataSessionID),
+	}
+	for _, role := range strings.Split(firstValue(md, MetadataUserRoles), ",") {
+		if role = strings.TrimSpace(role); role != "" {
+			identity.Roles = append(identity.Roles, role)
+		}
+	}
+	return identity, true
+}
+
+// requestMeta 取出請求的來源資訊，寫入稽核紀錄用
+// 客戶端 IP 以 gateway 簽章過的 x-client-ip 為準，沒有時才用連線來源；
+// x-forwarded-for 最左邊的值由客戶端控制，不可採信
+func requestMeta(ctx context.Context) models.RequestMeta {
+	md, _ := metadata.FromIncomingContext(ctx)
+	meta := models.RequestMeta{
+		UserAgent: firstValue(md, MetadataClientUserAgent),
+		RequestID: RequestIDFromContext(ctx),
+	}
+
+	if ip := firstValue(md, MetadataClientIP); ip != "" {
+		meta.IP = ip
+	} else if p, ok := peer.FromContext(ctx); ok {
+		if host, _, err := net.SplitHostPort(p.Addr.String()); err == nil {
+			meta.IP = host
+		}
+	}
+
+	if identity, ok := currentIdentity(ctx); ok {
+		meta.ActorID = identity.UserID
+	}
+	return meta
+}
+
+// signedHeader 將納入簽章的 metadata 轉成 HTTP header，沿用與 HTTP 相同的簽章計算
+func signedHeader(md metadata.MD) http.Header {
+	header := http.Header{}
+	for _, name := range security.GatewaySignedHeaders {
+		if value := firstValue(md, strings.ToLower(name)); value != "" {
+			header.Set(name, value)
+		}
+	}
+	return header
+}
+
+// requireSelf 確認呼叫者就是 id 指向的用戶；MFA secret 與復原碼只能交給帳號本人
+func requireSelf(ctx context.Context, id string) error {
+	identity, ok := currentIdentity(ctx)
+	if !ok {
+		return status.Error(codes.Unauthenticated, "missing caller identity")
+	}
+	if identity.UserID != id {
+		return status.Error(codes.PermissionDenied, "only the account owner can manage MFA")
+	}
+	return nil
+}
+
+// requireSelfOrAdmin 確認呼叫者是 id 指向的用戶本人或管理員；用在修改、刪除用戶資料的 RPC
+func requireSelfOrAdmin(ctx context.Context, id string) error {
+	identity, ok := currentIdentity(ctx)
+	if !ok {
+		return status.Error(codes.Unauthenticated, "missing caller identity")
+	}
+	if identity.UserID != id && !identity.HasRole(models.RoleAdmin) {
+		return status.Error(codes.PermissionDenied, "only the account owner or an admin can modify this user")
+	}
+	return nil
+}
@@ -0,0 +1,404 @@
+// Package grpcserver 以 gRPC 提供 user.v1.UserService，與 HTTP handler 共用同一個 service 層
+//
+// 錯誤對應與 HTTP 一致，API Gateway 再把 gRPC status 轉回相同的 HTTP status 與 JSON 格式
+package grpcserver
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"log"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/emptypb"
+	"user-service/auth"
+	"user-service/etag"
+	"user-service/gen/userv1"
+	"user-service/models"
+	"user-service/services"
+)
+
+// Server 實作 userv1.UserServiceServer
+type Server struct {
+	userv1.UnimplementedUserServiceServer
+
+	service        services.UserServiceInterface
+	tokens         *auth.Issuer
+	requireIfMatch bool
+}
+
+// Option 用來調整 Server 的選用設定
+type Option func(*Server)
+
+// WithRequireIfMatch 要求 UpdateUser / DeleteUser 必須帶 if_match，與 HTTP 的 REQUIRE_IF_MATCH 相同
+func WithRequireIfMatch() Option {
+	return func(s *Server) {
+		s.requireIfMatch = true
+	}
+}
+
+// NewServer 創建 gRPC Server，tokens 需與 HTTP handler 使用相同的 JWT_SECRET
+func NewServer(service services.UserServiceInterface, tokens *auth.Issuer, opts ...Option) *Server {
+	s := &Server{service: service, tokens: tokens}
+	for _, opt := range opts {
+		opt(s)
+	}
+	return s
+}
+
+// svc 回傳帶有這次請求來源資訊的 service，寫入的稽核紀錄才能對應到操作者
+func (s *Server) svc(ctx context.Context) services.UserServiceInterface {
+	return s.service.ForRequest(requestMeta(ctx))
+}
+
+// ── 註冊與登入 ───────────────────────────────────────────────────────────────
+
+func (s *Server) Register(ctx context.Context, req *userv1.RegisterRequest) (*userv1.User, error) {
+	in := models.RegisterRequest{Email: req.GetEmail(), Username: req.GetUsername(), Password: req.GetPassword()}
+	if err := validate(&in); err != nil {
+		return nil, err
+	}
+
+	user, err := s.svc(ctx).Register(ctx, in)
+	if err != nil {
+		return nil, serviceError(err)
+	}
+	return toProtoUser(user), nil
+}
+
+// Login 驗證帳密，已啟用 MFA 的用戶只會拿到 challenge token
+func (s *Server) Login(ctx context.Context, req *userv1.LoginRequest) (*userv1.LoginResponse, error) {
+	in := models.LoginRequest{Email: req.GetEmail(), Password: req.GetPassword()}
+	if err := validate(&in); err != nil {
+		return nil, err
+	}
+
+	svc := s.svc(ctx)
+	user, err := svc.Login(ctx, in)
+	if err != nil {
+		return nil, status.Error(codes.Unauthenticated, err.Error())
+	}
+
+	if user.MFAEnabled {
+		mfaToken, err := s.tokens.IssueMFAChallenge(user.ID)
+		if err != nil {
+			return nil, status.Error(codes.Internal, "產生 token 失敗")
+		}
+		return &userv1.LoginResponse{MfaRequired: true, MfaToken: mfaToken}, nil
+	}
+
+	return s.accessTokenResponse(ctx, svc, user)
+}
+
+// LoginMFA 兩階段登入的第二步
+func (s *Server) LoginMFA(ctx context.Context, req *userv1.LoginMFARequest) (*userv1.LoginResponse, error) {
+	in := models.MFALoginRequest{MFAToken: req.GetMfaToken(), Code: req.GetCode()}
+	if err := validate(&in); err != nil {
+		return nil, err
+	}
+
+	userID, err := s.tokens.ParseMFAChallenge(in.MFAToken)
+	if err != nil {
+		return nil, status.Error(codes.Unauthenticated, err.Error())
+	}
+
+	svc := s.svc(ctx)
+	user, err := svc.VerifyMFA(ctx, userID, in.Code)
+	if err != nil {
+		if errors.Is(err, services.ErrInvalidMFACode) || errors.Is(err, services.ErrMFANotEnrolled) {
+			return nil, status.Error(codes.Unauthenticated, services.ErrInvalidMFACode.Error())
+		}
+		return nil, serviceError(err)
+	}
+
+	return s.accessTokenResponse(ctx, svc, user)
+}
+
+// accessTokenResponse 簽發 access token 並組成登入成功的響應
+func (s *Server) accessTokenResponse(ctx context.Context, svc services.UserServiceInterface, user *models.User) (*userv1.LoginResponse, error) {
+	token, err := s.tokens.IssueAccessToken(ctx, svc, user)
+	if err != nil {
+		if errors.Is(err, auth.ErrSessionStart) {
+			return nil, status.Error(codes.Internal, "建立 session 失敗")
+		}
+		return nil, status.Error(codes.Internal, "產生 token 失敗")
+	}
+	return &userv1.LoginResponse{Token: token, User: toProtoUser(user)}, nil
+}
+
+// ── 用戶資料 ─────────────────────────────────────────────────────────────────
+
+func (s *Server) ListUsers(ctx context.Context, _ *userv1.ListUsersRequest) (*userv1.ListUsersResponse, error) {
+	users, err := s.svc(ctx).GetUsers(ctx)
+	if err != nil {
+		return nil, serviceError(err)
+	}
+
+	resp := &userv1.ListUsersResponse{Users: make([]*userv1.User, len(users))}
+	for i := range users {
+		resp.Users[i] = toProtoUser(&users[i])
+	}
+	return resp, nil
+}
+
+func (s *Server) GetUser(ctx context.Context, req *userv1.GetUserRequest) (*userv1.User, error) {
+	user, err := s.svc(ctx).GetUserByID(ctx, req.GetId())
+	if err != nil {
+		return nil, status.Error(codes.NotFound, err.Error())
+	}
+	return toProtoUser(user), nil
+}
+
+func (s *Server) UpdateUser(ctx context.Context, req *userv1.UpdateUserRequest) (*emptypb.Empty, error) {
+	if err := requireSelfOrAdmin(ctx, req.GetId()); err != nil {
+		return nil, err
+	}
+	expectedVersion, err := etag.ParseIfMatch(req.GetIfMatch(), s.requireIfMatch)
+	if err != nil {
+		return nil, ifMatchError(err)
+	}
+
+	if err := s.svc(ctx).UpdateUser(ctx, req.GetId(), models.UpdateUserRequest{Username: req.GetUsername()}, expectedVersion); err != nil {
+		return nil, serviceError(err)
+	}
+	return &emptypb.Empty{}, nil
+}
+
+// PatchUser 把 fields 還原成 JSON Merge Patch，以與 HTTP 完全相同的規則解析
+func (s *Server) PatchUser(ctx context.Context, req *userv1.PatchUserRequest) (*userv1.User, error) {
+	if err := requireSelfOrAdmin(ctx, req.GetId()); err != nil {
+		return nil, err
+	}
+	patch := make(map[string]interface{}, len(req.GetFields()))
+	for name, value := range req.GetFields() {
+		if value.GetNullValue() {
+			patch[name] = nil
+		} else {
+			patch[name] = value.GetStringValue()
+		}
+	}
+	body, err := json.Marshal(patch)
+	if err != nil {
+		return nil, status.Error(codes.InvalidArgument, err.Error())
+	}
+	changes, err := models.ParseUserMergePatch(body)
+	if err != nil {
+		return nil, status.Error(codes.InvalidArgument, err.Error())
+	}
+
+	expectedVersion, err := etag.ParseIfMatch(req.GetIfMatch(), s.requireIfMatch)
+	if err != nil {
+		return nil, ifMatchError(err)
+	}
+
+	user, err := s.svc(ctx).PatchUser(ctx, req.GetId(), *changes, expectedVersion)
+	if err != nil {
+		return nil, serviceError(err)
+	}
+	return toProtoUser(user), nil
+}
+
+func (s *Server) DeleteUser(ctx context.Context, req *userv1.DeleteUserRequest) (*emptypb.Empty, error) {
+	if err := requireSelfOrAdmin(ctx, req.GetId()); err != nil {
+		return nil, err
+	}
+	expectedVersion, err := etag.ParseIfMatch(req.GetIfMatch(), s.requireIfMatch)
+	if err != nil {
+		return nil, ifMatchError(err)
+	}
+
+	if err := s.svc(ctx).DeleteUser(ctx, req.GetId(), expectedVersion); err != nil {
+		return nil, serviceError(err)
+	}
+	return &emptypb.Empty{}, nil
+}
+
+func (s *Server) RestoreUser(ctx context.Context, req *userv1.RestoreUserRequest) (*emptypb.Empty, error) {
+	if err := s.svc(ctx).RestoreUser(ctx, req.GetId()); err != nil {
+		return nil, serviceError(err)
+	}
+	return &emptypb.Empty{}, nil
+}
+
+// ── 密碼與 email ─────────────────────────────────────────────────────────────
+
+// ForgotPassword 不透露 email 是否存在，也不透露寄信是否成功
+func (s *Server) ForgotPassword(ctx context.Context, req *userv1.ForgotPasswordRequest) (*emptypb.Empty, error) {
+	in := models.ForgotPasswordRequest{Email: req.GetEmail()}
+	if err := validate(&in); err != nil {
+		return nil, err
+	}
+
+	if err := s.svc(ctx).ForgotPassword(ctx, in); err != nil {
+		log.Printf("forgot password failed: %v", err)
+	}
+	return &emptypb.Empty{}, nil
+}
+
+func (s *Server) ResetPassword(ctx context.Context, req *userv1.ResetPasswordRequest) (*emptypb.Empty, error) {
+	in := models.ResetPasswordRequest{Token: req.GetToken(), NewPassword: req.GetNewPassword()}
+	if err := validate(&in); err != nil {
+		return nil, err
+	}
+
+	if err := s.svc(ctx).ResetPassword(ctx, in); err != nil {
+		return nil, serviceError(err)
+	}
+	return &emptypb.Empty{}, nil
+}
+
+func (s *Server) ChangePassword(ctx context.Context, req *userv1.ChangePasswordRequest) (*emptypb.Empty, error) {
+	if err := requireSelfOrAdmin(ctx, req.GetId()); err != nil {
+		return nil, err
+	}
+	in := models.ChangePasswordRequest{CurrentPassword: req.GetCurrentPassword(), NewPassword: req.GetNewPassword()}
+	if err := validate(&in); err != nil {
+		return nil, err
+	}
+
+	if err := s.svc(ctx).ChangePassword(ctx, req.GetId(), in); err != nil {
+		return nil, serviceError(err)
+	}
+	return &emptypb.Empty{}, nil
+}
+
+func (s *Server) RequestEmailChange(ctx context.Context, req *userv1.RequestEmailChangeRequest) (*emptypb.Empty, error) {
+	if err := requireSelfOrAdmin(ctx, req.GetId()); err != nil {
+		return nil, err
+	}
+	in := models.EmailChangeRequest{NewEmail: req.GetNewEmail(), CurrentPassword: req.GetCurrentPassword()}
+	if err := validate(&in); err != nil {
+		return nil, err
+	}
+
+	if err := s.svc(ctx).RequestEmailChange(ctx, req.GetId(), in); err != nil {
+		return nil, serviceError(err)
+	}
+	return &emptypb.Empty{}, nil
+}
+
+func (s *Server) ConfirmEmailChange(ctx context.Context, req *userv1.ConfirmEmailChangeRequest) (*emptypb.Empty, error) {
+	in := models.ConfirmEmailChangeRequest{Token: req.GetToken()}
+	if err := validate(&in); err != nil {
+		return nil, err
+	}
+
+	if err := s.svc(ctx).ConfirmEmailChange(ctx, in); err != nil {
+		return nil, serviceError(err)
+	}
+	return &emptypb.Empty{}, nil
+}
+
+// ── MFA ──────────────────────────────────────────────────────────────────────
+
+func (s *Server) EnrollTOTP(ctx context.Context, req *userv1.EnrollTOTPRequest) (*userv1.TOTPEnrollment, error) {
+	if err := requireSelf(ctx, req.GetId()); err != nil {
+		return nil, err
+	}
+	enrollment, err := s.svc(ctx).EnrollTOTP(ctx, req.GetId())
+	if err != nil {
+		return nil, serviceError(err)
+	}
+	return &userv1.TOTPEnrollment{Secret: enrollment.Secret, ProvisioningUri: enrollment.ProvisioningURI}, nil
+}
+
+func (s *Server) ConfirmTOTP(ctx context.Context, req *userv1.ConfirmTOTPRequest) (*userv1.RecoveryCodes, error) {
+	if err := requireSelf(ctx, req.GetId()); err != nil {
+		return nil, err
+	}
+	in := models.MFACodeRequest{Code: req.GetCode()}
+	if err := validate(&in); err != nil {
+		return nil, err
+	}
+
+	recoveryCodes, err := s.svc(ctx).ConfirmTOTP(ctx, req.GetId(), in.Code)
+	if err != nil {
+		return nil, serviceError(err)
+	}
+	return &userv1.RecoveryCodes{RecoveryCodes: recoveryCodes}, nil
+}
+
+func (s *Server) DisableMFA(ctx context.Context, req *userv1.DisableMFARequest) (*emptypb.Empty, error) {
+	if err := requireSelf(ctx, req.GetId()); err != nil {
+		return nil, err
+	}
+	in := models.MFACodeRequest{Code: req.GetCode()}
+	if err := validate(&in); err != nil {
+		return nil, err
+	}
+
+	if err := s.svc(ctx).DisableMFA(ctx, req.GetId(), in.Code); err != nil {
+		return nil, serviceError(err)
+	}
+	return &emptypb.Empty{}, nil
+}
+
+// ── 登入 session ─────────────────────────────────────────────────────────────
+
+// ListSessions 列出呼叫者的登入紀錄，並標示發出這次請求的 session
+func (s *Server) ListSessions(ctx context.Context, _ *userv1.ListSessionsRequest) (*userv1.ListSessionsResponse, error) {
+	identity, ok := currentIdentity(ctx)
+	if !ok {
+		return nil, status.Error(codes.Unauthenticated, "missing caller identity")
+	}
+
+	sessions, err := s.svc(ctx).ListSessions(ctx, identity.UserID)
+	if err != nil {
+		return nil, serviceError(err)
+	}
+
+	resp := &userv1.ListSessionsResponse{Sessions: make([]*userv1.Session, len(sessions))}
+	for i := range sessions {
+		sessions[i].Current = identity.SessionID != "" && sessions[i].ID == identity.SessionID
+		resp.Sessions[i] = toProtoSession(&sessions[i])
+	}
+	return resp, nil
+}
+
+// RevokeSession 撤銷呼叫者的一個 session，撤銷自己這個 session 等同登出
+func (s *Server) RevokeSession(ctx context.Context, req *userv1.RevokeSessionRequest) (*emptypb.Empty, error) {
+	identity, ok := currentIdentity(ctx)
+	if !ok {
+		return nil, status.Error(codes.Unauthenticated, "missing caller identity")
+	}
+
+	if err := s.svc(ctx).RevokeSession(ctx, identity.UserID, req.GetId()); err != nil {
+		return nil, serviceError(err)
+	}
+	return &emptypb.Empty{}, nil
+}
+
+// ── 稽核紀錄 ─────────────────────────────────────────────────────────────────
+
+// ListAuditEvents 查詢稽核紀錄（管理員），權限由 API Gateway 檢查
+func (s *Server) ListAuditEvents(ctx context.Context, req *userv1.ListAuditEventsRequest) (*userv1.ListAuditEventsResponse, error) {
+	if req.GetLimit() < 0 {
+		return nil, status.Error(codes.InvalidArgument, "limit must be a positive integer")
+	}
+	if req.GetCursor() < 0 {
+		return nil, status.Error(codes.InvalidArgument, "cursor must be a positive integer")
+	}
+
+	page, err := s.svc(ctx).ListAuditEvents(ctx, models.AuditFilter{
+		ActorID:  req.GetActorId(),
+		TargetID: req.GetTargetId(),
+		Action:   req.GetAction(),
+		From:     optionalTime(req.GetFrom()),
+		To:       optionalTime(req.GetTo()),
+		Before:   req.GetCursor(),
+		Limit:    int(req.GetLimit()),
+	})
+	if err != nil {
+		return nil, serviceError(err)
+	}
+
+	resp := &userv1.ListAuditEventsResponse{
+		Events:     make([]*userv1.AuditEvent, len(page.Events)),
+		NextCursor: page.NextCursor,
+	}
+	for i := range page.Events {
+		resp.Events[i] = toProtoAuditEvent(&page.Events[i])
+	}
+	return resp, nil
+}
@@ -0,0 +1,528 @@
+package grpcserver
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"net"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/peer"
+	"google.golang.org/grpc/status"
+	"user-service/auth"
+	"user-service/gen/userv1"
+	"user-service/models"
+	"user-service/repository"
+	"user-service/services"
+)
+
+// -------------------------------------------------------------------
+// mockService：只實作測試會用到的方法，其餘方法沿用嵌入的 nil interface（呼叫到會 panic）
+// -------------------------------------------------------------------
+
+type mockService struct {
+	services.UserServiceInterface
+	mock.Mock
+	meta models.RequestMeta // 最後一次 ForRequest 收到的來源資訊
+}
+
+func (m *mockService) ForRequest(meta models.RequestMeta) services.UserServiceInterface {
+	m.meta = meta
+	return m
+}
+
+func (m *mockService) Register(ctx context.Context, req models.RegisterRequest) (*models.User, error) {
+	args := m.Called(ctx, req)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*models.User), args.Error(1)
+}
+
+func (m *mockService) Login(ctx context.Context, req models.LoginRequest) (*models.User, error) {
+	args := m.Called(ctx, req)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*models.User), args.Error(1)
+}
+
+func (m *mockService) GetUserByID(ctx context.Context, id string) (*models.User, error) {
+	args := m.Called(ctx, id)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*models.User), args.Error(1)
+}
+
+func (m *mockService) UpdateUser(ctx context.Context, id string, req models.UpdateUserRequest, expectedVersion int) error {
+	args := m.Called(ctx, id, req, expectedVersion)
+	return args.Error(0)
+}
+
+func (m *mockService) PatchUser(ctx context.Context, id string, changes models.UserChanges, expectedVersion int) (*models.User, error) {
+	args := m.Called(ctx, id, changes, expectedVersion)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*models.User), args.Error(1)
+}
+
+func (m *mockService) StartSession(ctx context.Context, userID string, expiresAt time.Time) (*models.Session, error) {
+	args := m.Called(ctx, userID, expiresAt)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*models.Session), args.Error(1)
+}
+
+func (m *mockService) EnrollTOTP(ctx context.Context, userID string) (*models.TOTPEnrollment, error) {
+	args := m.Called(ctx, userID)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*models.TOTPEnrollment), args.Error(1)
+}
+
+func (m *mockService) ListSessions(ctx context.Context, userID string) ([]models.Session, error) {
+	args := m.Called(ctx, userID)
+	return args.Get(0).([]models.Session), args.Error(1)
+}
+
+func (m *mockService) ListAuditEvents(ctx context.Context, filter models.AuditFilter) (*models.AuditEventPage, error) {
+	args := m.Called(ctx, filter)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*models.AuditEventPage), args.Error(1)
+}
+
+// testNow 測試用的固定時間
+var testNow = time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+
+func newTestServer(svc services.UserServiceInterface, opts ...Option) *Server {
+	return NewServer(svc, auth.NewIssuer("test-secret", func() time.Time { return testNow }), opts...)
+}
+
+// callerContext 模擬 API Gateway 帶上呼叫者身份的 metadata
+func callerContext(pairs ...string) context.Context {
+	return metadata.NewIncomingContext(context.Background(), metadata.Pairs(pairs...))
+}
+
+// errorReason 取出錯誤附帶的 ErrorDetail.reason
+func errorReason(t *testing.T, err error) string {
+	t.Helper()
+	for _, d := range status.Convert(err).Details() {
+		if detail, ok := d.(*userv1.ErrorDetail); ok {
+			return detail.GetReason()
+		}
+	}
+	return ""
+}
+
+// ===================================================================
+// 註冊測試
+// ===================================================================
+
+func TestRegister(t *testing.T) {
+	req := &userv1.RegisterRequest{Email: "user@example.com", Username: "user", Password: "Password123!"}
+	in := models.RegisterRequest{Email: "user@example.com", Username: "user", Password: "Password123!"}
+
+	tests := []struct {
+		name     string
+		err      error
+		wantCode codes.Code
+	}{
+		{name: "success", wantCode: codes.OK},
+		{name: "duplicate email", err: services.ErrEmailTaken, wantCode: codes.AlreadyExists},
+		{name: "unexpected error", err: assert.AnError, wantCode: codes.Internal},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			svc := new(mockService)
+			if tt.err != nil {
+				svc.On("Register", mock.Anything, in).Return(nil, tt.err)
+			} else {
+				svc.On("Register", mock.Anything, in).Return(&models.User{ID: "uuid-001", Email: in.Email}, nil)
+			}
+
+			resp, err := newTestServer(svc).Register(context.Background(), req)
+
+			assert.Equal(t, tt.wantCode, status.Code(err))
+			if tt.err == nil {
+				assert.Equal(t, "uuid-001", resp.GetId())
+			}
+			svc.AssertExpectations(t)
+		})
+	}
+}
+
+// ===================================================================
+// 登入測試
+// ===================================================================
+
+func TestLogin(t *testing.T) {
+	t.Run("issues access token with session id", func(t *testing.T) {
+		svc := new(mockService)
+		svc.On("Login", mock.Anything, models.LoginRequest{Email: "user@example.com", Password: "password123"}).
+			Return(&models.User{ID: "uuid-001", Email: "user@example.com", Role: models.RoleUser}, nil)
+		svc.On("StartSession", mock.Anything, "uuid-001", testNow.Add(auth.TokenTTL)).Return(&models.Session{ID: "sess-1"}, nil)
+
+		resp, err := newTestServer(svc).Login(context.Background(),
+			&userv1.LoginRequest{Email: "user@example.com", Password: "password123"})
+
+		require.NoError(t, err)
+		assert.Equal(t, "uuid-001", resp.GetUser().GetId())
+		assert.False(t, resp.GetMfaRequired())
+
+		claims := &auth.Claims{}
+		_, err = jwt.ParseWithClaims(resp.GetToken(), claims, func(*jwt.Token) (interface{}, error) {
+			return []byte("test-secret"), nil
+		}, jwt.WithTimeFunc(func() time.Time { return testNow }))
+		require.NoError(t, err)
+		assert.Equal(t, "sess-1", claims.SessionID)
+		svc.AssertExpectations(t)
+	})
+
+	t.Run("iat keeps sub-second precision", func(t *testing.T) {
+		// 撤銷時間點記到微秒，同一秒內撤銷後再登入的 token 要能被 gateway 判斷為較新的
+		issuedAt := testNow.Add(123456 * time.Microsecond)
+		svc := new(mockService)
+		svc.On("Login", mock.Anything, mock.Anything).Return(&models.User{ID: "uuid-001", Role: models.RoleUser}, nil)
+		svc.On("StartSession", mock.Anything, "uuid-001", issuedAt.Add(auth.TokenTTL)).Return(&models.Session{ID: "sess-1"}, nil)
+		server := NewServer(svc, auth.NewIssuer("test-secret", func() time.Time { return issuedAt }))
+
+		resp, err := server.Login(context.Background(), &userv1.LoginRequest{Email: "user@example.com", Password: "password123"})
+
+		require.NoError(t, err)
+		payload, err := base64.RawURLEncoding.DecodeString(strings.Split(resp.GetToken(), ".")[1])
+		require.NoError(t, err)
+		var raw struct {
+			IssuedAt json.Number `json:"iat"`
+		}
+		require.NoError(t, json.Unmarshal(payload, &raw))
+		assert.Equal(t, "1704110400.123456", raw.IssuedAt.String())
+	})
+
+	t.Run("mfa enabled returns challenge only", func(t *testing.T) {
+		svc := new(mockService)
+		svc.On("Login", mock.Anything, mock.Anything).Return(&models.User{ID: "uuid-001", MFAEnabled: true}, nil)
+
+		resp, err := newTestServer(svc).Login(context.Background(),
+			&userv1.LoginRequest{Email: "user@example.com", Password: "password123"})
+
+		require.NoError(t, err)
+		assert.True(t, resp.GetMfaRequired())
+		assert.NotEmpty(t, resp.GetMfaToken())
+		assert.Empty(t, resp.GetToken())
+	})
+
+	t.Run("invalid email uses binding rules", func(t *testing.T) {
+		_, err := newTestServer(new(mockService)).Login(context.Background(),
+			&userv1.LoginRequest{Email: "not-an-email", Password: "password123"})
+
+		assert.Equal(t, codes.InvalidArgument, status.Code(err))
+	})
+
+	t.Run("wrong password", func(t *testing.T) {
+		svc := new(mockService)
+		svc.On("Login", mock.Anything, mock.Anything).Return(nil, assert.AnError)
+
+		_, err := newTestServer(svc).Login(context.Background(),
+			&userv1.LoginRequest{Email: "user@example.com", Password: "wrong"})
+
+		assert.Equal(t, codes.Unauthenticated, status.Code(err))
+	})
+}
+
+// ===================================================================
+// If-Match 與錯誤對應測試
+// ===================================================================
+
+func TestUpdateUser(t *testing.T) {
+	req := models.UpdateUserRequest{Username: "newname"}
+
+	t.Run("matching version", func(t *testing.T) {
+		svc := new(mockService)
+		svc.On("UpdateUser", mock.Anything, "abc-123", req, 3).Return(nil)
+
+		_, err := newTestServer(svc).UpdateUser(callerContext(MetadataUserID, "abc-123"),
+			&userv1.UpdateUserRequest{Id: "abc-123", Username: "newname", IfMatch: `"3"`})
+
+		assert.NoError(t, err)
+		svc.AssertExpectations(t)
+	})
+
+	t.Run("stale version", func(t *testing.T) {
+		svc := new(mockService)
+		svc.On("UpdateUser", mock.Anything, "abc-123", req, 2).Return(services.ErrVersionConflict)
+
+		_, err := newTestServer(svc).UpdateUser(callerContext(MetadataUserID, "abc-123"),
+			&userv1.UpdateUserRequest{Id: "abc-123", Username: "newname", IfMatch: `"2"`})
+
+		assert.Equal(t, codes.FailedPrecondition, status.Code(err))
+		assert.Equal(t, ReasonVersionMismatch, errorReason(t, err))
+	})
+
+	t.Run("missing if_match when required", func(t *testing.T) {
+		svc := new(mockService)
+
+		_, err := newTestServer(svc, WithRequireIfMatch()).UpdateUser(callerContext(MetadataUserID, "abc-123"),
+			&userv1.UpdateUserRequest{Id: "abc-123", Username: "newname"})
+
+		assert.Equal(t, codes.FailedPrecondition, status.Code(err))
+		assert.Equal(t, ReasonPreconditionRequired, errorReason(t, err))
+		svc.AssertNotCalled(t, "UpdateUser", mock.Anything, mock.Anything, mock.Anything, mock.Anything)
+	})
+
+	t.Run("no if_match skips the check", func(t *testing.T) {
+		svc := new(mockService)
+		svc.On("UpdateUser", mock.Anything, "abc-123", req, repository.AnyVersion).Return(nil)
+
+		_, err := newTestServer(svc).UpdateUser(callerContext(MetadataUserID, "abc-123"),
+			&userv1.UpdateUserRequest{Id: "abc-123", Username: "newname"})
+
+		assert.NoError(t, err)
+		svc.AssertExpectations(t)
+	})
+}
+
+func TestPatchUser(t *testing.T) {
+	t.Run("null clears the field", func(t *testing.T) {
+		empty, bio := "", "hello"
+		svc := new(mockService)
+		svc.On("PatchUser", mock.Anything, "abc-123", models.UserChanges{Bio: &bio, AvatarURL: &empty}, repository.AnyVersion).
+			Return(&models.User{ID: "abc-123", Bio: "hello", Version: 4}, nil)
+
+		user, err := newTestServer(svc).PatchUser(callerContext(MetadataUserID, "abc-123"), &userv1.PatchUserRequest{
+			Id: "abc-123",
+			Fields: map[string]*userv1.PatchValue{
+				"bio":        {Kind: &userv1.PatchValue_StringValue{StringValue: "hello"}},
+				"avatar_url": {Kind: &userv1.PatchValue_NullValue{NullValue: true}},
+			},
+		})
+
+		require.NoError(t, err)
+		assert.Equal(t, int64(4), user.GetVersion())
+		svc.AssertExpectations(t)
+	})
+
+	t.Run("read-only field is rejected", func(t *testing.T) {
+		_, err := newTestServer(new(mockService)).PatchUser(callerContext(MetadataUserID, "abc-123"), &userv1.PatchUserRequest{
+			Id:     "abc-123",
+			Fields: map[string]*userv1.PatchValue{"email": {Kind: &userv1.PatchValue_StringValue{StringValue: "x@example.com"}}},
+		})
+
+		assert.Equal(t, codes.InvalidArgument, status.Code(err))
+	})
+
+	t.Run("validation errors carry field violations", func(t *testing.T) {
+		svc := new(mockService)
+		svc.On("PatchUser", mock.Anything, "abc-123", mock.Anything, repository.AnyVersion).Return(nil,
+			&services.ValidationError{Fields: []services.FieldError{{Field: "locale", Message: "invalid locale"}}})
+
+		_, err := newTestServer(svc).PatchUser(callerContext(MetadataUserID, "abc-123"), &userv1.PatchUserRequest{
+			Id:     "abc-123",
+			Fields: map[string]*userv1.PatchValue{"locale": {Kind: &userv1.PatchValue_StringValue{StringValue: "??"}}},
+		})
+
+		assert.Equal(t, codes.InvalidArgument, status.Code(err))
+		detail := status.Convert(err).Details()[0].(*userv1.ErrorDetail)
+		assert.Equal(t, ReasonInvalidUserFields, detail.GetReason())
+		assert.Equal(t, "locale", detail.GetFieldViolations()[0].GetField())
+	})
+}
+
+// ===================================================================
+// metadata 身份測試
+// ===================================================================
+
+func TestModifyUserRequiresSelfOrAdmin(t *testing.T) {
+	// 未設定預期呼叫的 mockService 被呼叫到就會 panic，被拒絕的請求不會碰到 service
+	calls := []struct {
+		name string
+		call func(s *Server, ctx context.Context) error
+	}{
+		{name: "update", call: func(s *Server, ctx context.Context) error {
+			_, err := s.UpdateUser(ctx, &userv1.UpdateUserRequest{Id: "user-b", Username: "mallory"})
+			return err
+		}},
+		{name: "patch", call: func(s *Server, ctx context.Context) error {
+			_, err := s.PatchUser(ctx, &userv1.PatchUserRequest{
+				Id:     "user-b",
+				Fields: map[string]*userv1.PatchValue{"avatar_url": {Kind: &userv1.PatchValue_StringValue{StringValue: "https://evil.example/x.png"}}},
+			})
+			return err
+		}},
+		{name: "delete", call: func(s *Server, ctx context.Context) error {
+			_, err := s.DeleteUser(ctx, &userv1.DeleteUserRequest{Id: "user-b"})
+			return err
+		}},
+		{name: "change password", call: func(s *Server, ctx context.Context) error {
+			_, err := s.ChangePassword(ctx, &userv1.ChangePasswordRequest{Id: "user-b", CurrentPassword: "oldpassword1", NewPassword: "brand-new-secret9"})
+			return err
+		}},
+		{name: "request email change", call: func(s *Server, ctx context.Context) error {
+			_, err := s.RequestEmailChange(ctx, &userv1.RequestEmailChangeRequest{Id: "user-b", NewEmail: "evil@example.com", CurrentPassword: "currentpassword"})
+			return err
+		}},
+	}
+
+	for _, tt := range calls {
+		t.Run(tt.name+"/other user", func(t *testing.T) {
+			err := tt.call(newTestServer(new(mockService)), callerContext(MetadataUserID, "user-a"))
+			assert.Equal(t, codes.PermissionDenied, status.Code(err))
+		})
+		t.Run(tt.name+"/missing identity", func(t *testing.T) {
+			err := tt.call(newTestServer(new(mockService)), context.Background())
+			assert.Equal(t, codes.Unauthenticated, status.Code(err))
+		})
+	}
+
+	t.Run("admin may modify another user", func(t *testing.T) {
+		svc := new(mockService)
+		svc.On("UpdateUser", mock.Anything, "user-b", models.UpdateUserRequest{Username: "renamed"}, repository.AnyVersion).Return(nil)
+
+		_, err := newTestServer(svc).UpdateUser(callerContext(MetadataUserID, "admin-1", MetadataUserRoles, models.RoleAdmin),
+			&userv1.UpdateUserRequest{Id: "user-b", Username: "renamed"})
+
+		assert.NoError(t, err)
+		svc.AssertExpectations(t)
+	})
+}
+
+func TestListSessions(t *testing.T) {
+	t.Run("marks current session and records request meta", func(t *testing.T) {
+		svc := new(mockService)
+		svc.On("ListSessions", mock.Anything, "uuid-001").Return([]models.Session{{ID: "sess-1"}, {ID: "sess-2"}}, nil)
+
+		ctx := callerContext(
+			MetadataUserID, "uuid-001",
+			MetadataSessionID, "sess-2",
+			MetadataRequestID, "req-42",
+			MetadataClientIP, "203.0.113.7",
+			MetadataClientUserAgent, "Mozilla/5.0",
+		)
+		resp, err := newTestServer(svc).ListSessions(ctx, &userv1.ListSessionsRequest{})
+
+		require.NoError(t, err)
+		assert.False(t, resp.GetSessions()[0].GetCurrent())
+		assert.True(t, resp.GetSessions()[1].GetCurrent())
+		assert.Equal(t, models.RequestMeta{
+			ActorID:   "uuid-001",
+			IP:        "203.0.113.7",
+			UserAgent: "Mozilla/5.0",
+			RequestID: "req-42",
+		}, svc.meta)
+	})
+
+	t.Run("missing identity", func(t *testing.T) {
+		_, err := newTestServer(new(mockService)).ListSessions(context.Background(), &userv1.ListSessionsRequest{})
+
+		assert.Equal(t, codes.Unauthenticated, status.Code(err))
+	})
+}
+
+func TestRequestMetaClientIP(t *testing.T) {
+	gatewayPeer := &peer.Peer{Addr: &net.TCPAddr{IP: net.ParseIP("10.0.0.5"), Port: 41234}}
+
+	tests := []struct {
+		name  string
+		pairs []string
+		want  string
+	}{
+		{name: "signed client ip", pairs: []string{MetadataClientIP, "203.0.113.7"}, want: "203.0.113.7"},
+		// 客戶端自帶的 x-forwarded-for 不納入判斷，避免偽造稽核與 session 的 IP
+		{name: "raw forwarded-for is ignored", pairs: []string{"x-forwarded-for", "198.51.100.1, 203.0.113.7"}, want: "10.0.0.5"},
+		{name: "falls back to the peer address", want: "10.0.0.5"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := peer.NewContext(callerContext(tt.pairs...), gatewayPeer)
+
+			assert.Equal(t, tt.want, requestMeta(ctx).IP)
+		})
+	}
+}
+
+// ===================================================================
+// MFA 綁定測試
+// ===================================================================
+
+func TestEnrollTOTP(t *testing.T) {
+	t.Run("owner", func(t *testing.T) {
+		svc := new(mockService)
+		svc.On("EnrollTOTP", mock.Anything, "uuid-001").Return(&models.TOTPEnrollment{Secret: "JBSWY3DPEHPK3PXP"}, nil)
+
+		resp, err := newTestServer(svc).EnrollTOTP(callerContext(MetadataUserID, "uuid-001"), &userv1.EnrollTOTPRequest{Id: "uuid-001"})
+
+		require.NoError(t, err)
+		assert.Equal(t, "JBSWY3DPEHPK3PXP", resp.GetSecret())
+		svc.AssertExpectations(t)
+	})
+
+	t.Run("another user's account", func(t *testing.T) {
+		svc := new(mockService)
+
+		_, err := newTestServer(svc).EnrollTOTP(callerContext(MetadataUserID, "user-a"), &userv1.EnrollTOTPRequest{Id: "user-b"})
+
+		assert.Equal(t, codes.PermissionDenied, status.Code(err))
+		svc.AssertNotCalled(t, "EnrollTOTP", mock.Anything, mock.Anything)
+	})
+
+	t.Run("missing identity", func(t *testing.T) {
+		_, err := newTestServer(new(mockService)).EnrollTOTP(context.Background(), &userv1.EnrollTOTPRequest{Id: "user-b"})
+
+		assert.Equal(t, codes.Unauthenticated, status.Code(err))
+	})
+}
+
+// ===================================================================
+// 請求 deadline 測試
+// ===================================================================
+
+func TestServiceRunsUnderCallDeadline(t *testing.T) {
+	var received context.Context
+	svc := new(mockService)
+	svc.On("GetUserByID", mock.Anything, "uuid-001").
+		Run(func(args mock.Arguments) { received = args.Get(0).(context.Context) }).
+		Return(&models.User{ID: "uuid-001"}, nil)
+
+	ctx, cancel := context.WithTimeout(callerContext(MetadataUserID, "uuid-001"), 5*time.Second)
+	defer cancel()
+	_, err := newTestServer(svc).GetUser(ctx, &userv1.GetUserRequest{Id: "uuid-001"})
+
+	require.NoError(t, err)
+	want, _ := ctx.Deadline()
+	got, ok := received.Deadline()
+	require.True(t, ok, "service must receive the gRPC call context")
+	assert.Equal(t, want, got)
+}
+
+func TestListAuditEventsRunsUnderCallDeadline(t *testing.T) {
+	var received context.Context
+	svc := new(mockService)
+	svc.On("ListAuditEvents", mock.Anything, models.AuditFilter{Action: models.AuditUserDeleted}).
+		Run(func(args mock.Arguments) { received = args.Get(0).(context.Context) }).
+		Return(&models.AuditEventPage{}, nil)
+
+	ctx, cancel := context.WithTimeout(callerContext(MetadataUserID, "admin-1"), 5*time.Second)
+	defer cancel()
+	_, err := newTestServer(svc).ListAuditEvents(ctx, &userv1.ListAuditEventsRequest{Action: models.AuditUserDeleted})
+
+	require.NoError(t, err)
+	want, _ := ctx.Deadline()
+	got, ok := received.Deadline()
+	require.True(t, ok, "audit query must receive the gRPC call context")
+	assert.Equal(t, want, got)
+	assert.Equal(t, "admin-1", svc.meta.ActorID)
+}
@@ -0,0 +1,99 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"user-service/models"
+	"user-service/security"
+	"user-service/services"
+)
+
+// RequestIDHeader API Gateway 為每個請求產生的追蹤 ID
+const RequestIDHeader = "X-Request-ID"
+
+// svc 回傳帶有這次請求來源資訊的 service，寫入的稽核紀錄才能對應到操作者
+func (h *UserHandler) svc(c *gin.Context) services.UserServiceInterface {
+	return h.service.ForRequest(h.requestMeta(c))
+}
+
+// requestMeta 取出請求的來源資訊
+// 操作者取自 gateway 注入的身份 header，未登入時留空；
+// 客戶端 IP 以 gateway 簽章過的 X-Client-IP 為準，與 gRPC 寫入的值一致
+func (h *UserHandler) requestMeta(c *gin.Context) models.RequestMeta {
+	meta := models.RequestMeta{
+		IP:        c.GetHeader(security.ClientIPHeader),
+		UserAgent: c.Request.UserAgent(),
+		RequestID: c.GetHeader(RequestIDHeader),
+	}
+	if meta.IP == "" {
+		meta.IP = c.ClientIP()
+	}
+	if identity, ok := currentIdentity(c); ok {
+		meta.ActorID = identity.UserID
+	}
+	return meta
+}
+
+// ListAuditEvents 查詢稽核紀錄（管理員）
+// 支援 actor_id、target_id、action、from、to（RFC 3339）篩選，以 cursor 與 limit 分頁
+func (h *UserHandler) ListAuditEvents(c *gin.Context) {
+	filter, err := parseAuditFilter(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	page, err := h.svc(c).ListAuditEvents(c.Request.Context(), filter)
+	if err != nil {
+		if errors.Is(err, services.ErrAuditDisabled) {
+			c.JSON(http.StatusNotImplemented, gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, page)
+}
+
+// parseAuditFilter 將 query string 轉成查詢條件
+func parseAuditFilter(c *gin.Context) (models.AuditFilter, error) {
+	filter := models.AuditFilter{
+		ActorID:  c.Query("actor_id"),
+		TargetID: c.Query("target_id"),
+		Action:   c.Query("action"),
+	}
+
+	for name, dst := range map[string]**time.Time{"from": &filter.From, "to": &filter.To} {
+		raw := c.Query(name)
+		if raw == "" {
+			continue
+		}
+		t, err := time.Parse(time.RFC3339, raw)
+		if err != nil {
+			return filter, errors.New(name + " must be an RFC 3339 timestamp")
+		}
+		*dst = &t
+	}
+
+	if raw := c.Query("limit"); raw != "" {
+		limit, err := strconv.Atoi(raw)
+		if err != nil || limit <= 0 {
+			return filter, errors.New("limit must be a positive integer")
+		}
+		filter.Limit = limit
+	}
+	if raw := c.Query("cursor"); raw != "" {
+		cursor, err := strconv.ParseInt(raw, 10, 64)
+		if err != nil || cursor <= 0 {
+			return filter, errors.New("cursor must be a positive integer")
+		}
+		filter.Before = cursor
+	}
+
+	return filter, nil
+}
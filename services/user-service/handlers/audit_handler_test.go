@@ -0,0 +1,170 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+	"user-service/middleware"
+	"user-service/models"
+	"user-service/security"
+	"user-service/services"
+)
+
+// ===================================================================
+// 請求來源資訊測試
+// ===================================================================
+
+func TestRequestMetaPassedToService(t *testing.T) {
+	mockSvc := new(MockUserService)
+	mockSvc.On("DeleteUser", mock.Anything, "abc-123", 0).Return(nil)
+
+	router := setupTestRouter(NewUserHandler(mockSvc, "test-secret"))
+	w := httptest.NewRecorder()
+	r, _ := http.NewRequest("DELETE", "/users/abc-123", nil)
+	r.Header.Set(HeaderUserID, "admin-1")
+	r.Header.Set(HeaderUserRoles, "admin")
+	r.Header.Set("User-Agent", "curl/8.0")
+	r.Header.Set("X-Request-ID", "req-42")
+	r.RemoteAddr = "203.0.113.7:51234"
+	router.ServeHTTP(w, r)
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, models.RequestMeta{
+		ActorID:   "admin-1",
+		IP:        "203.0.113.7",
+		UserAgent: "curl/8.0",
+		RequestID: "req-42",
+	}, mockSvc.meta)
+}
+
+func TestRequestMetaClientIP(t *testing.T) {
+	tests := []struct {
+		name     string
+		clientIP string
+		want     string
+	}{
+		// gateway 簽章過的 X-Client-IP 優先，與 gRPC 寫入的值一致
+		{name: "signed client ip", clientIP: "198.51.100.9", want: "198.51.100.9"},
+		{name: "falls back to the connection", want: "203.0.113.7"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mockSvc := new(MockUserService)
+			mockSvc.On("DeleteUser", mock.Anything, "abc-123", 0).Return(nil)
+
+			router := setupTestRouter(NewUserHandler(mockSvc, "test-secret"))
+			w := httptest.NewRecorder()
+			r, _ := http.NewRequest("DELETE", "/users/abc-123", nil)
+			r.Header.Set(HeaderUserID, "abc-123")
+			if tt.clientIP != "" {
+				r.Header.Set(security.ClientIPHeader, tt.clientIP)
+			}
+			r.RemoteAddr = "203.0.113.7:51234"
+			router.ServeHTTP(w, r)
+
+			assert.Equal(t, http.StatusOK, w.Code)
+			assert.Equal(t, tt.want, mockSvc.meta.IP)
+		})
+	}
+}
+
+func TestRequestMetaIgnoresAuthorizationHeader(t *testing.T) {
+	mockSvc := new(MockUserService)
+	mockSvc.On("DeleteUser", mock.Anything, "abc-123", 0).Return(nil)
+
+	// 操作者只看 gateway 注入的身份 header，不自行解析 token
+	router := setupTestRouter(NewUserHandler(mockSvc, "test-secret"))
+	w := httptest.NewRecorder()
+	r, _ := http.NewRequest("DELETE", "/users/abc-123", nil)
+	r.Header.Set("Authorization", "Bearer whatever")
+	router.ServeHTTP(w, r)
+
+	assert.Empty(t, mockSvc.meta.ActorID)
+}
+
+// ===================================================================
+// ListAuditEvents handler 測試
+// ===================================================================
+
+func TestListAuditEventsHandler(t *testing.T) {
+	t.Run("passes filters and returns page", func(t *testing.T) {
+		from := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+		mockSvc := new(MockUserService)
+		mockSvc.On("ListAuditEvents", mock.Anything, mock.MatchedBy(func(f models.AuditFilter) bool {
+			return f.TargetID == "user-1" && f.Action == models.AuditUserDeleted &&
+				f.From != nil && f.From.Equal(from) && f.To == nil &&
+				f.Limit == 10 && f.Before == 99
+		})).Return(&models.AuditEventPage{
+			Events:     []models.AuditEvent{{ID: 98, TargetID: "user-1", Action: models.AuditUserDeleted}},
+			NextCursor: 98,
+		}, nil)
+
+		router := setupTestRouter(NewUserHandler(mockSvc, "test-secret"))
+		w := httptest.NewRecorder()
+		r, _ := http.NewRequest("GET",
+			"/admin/audit-events?target_id=user-1&action=user.deleted&from=2024-01-01T00:00:00Z&limit=10&cursor=99", nil)
+		router.ServeHTTP(w, r)
+
+		assert.Equal(t, http.StatusOK, w.Code)
+		var resp models.AuditEventPage
+		json.Unmarshal(w.Body.Bytes(), &resp)
+		assert.Len(t, resp.Events, 1)
+		assert.Equal(t, int64(98), resp.NextCursor)
+		mockSvc.AssertExpectations(t)
+	})
+
+	t.Run("invalid query", func(t *testing.T) {
+		for _, query := range []string{"from=yesterday", "limit=-1", "cursor=abc"} {
+			mockSvc := new(MockUserService)
+			router := setupTestRouter(NewUserHandler(mockSvc, "test-secret"))
+			w := httptest.NewRecorder()
+			r, _ := http.NewRequest("GET", "/admin/audit-events?"+query, nil)
+			router.ServeHTTP(w, r)
+
+			assert.Equal(t, http.StatusBadRequest, w.Code, query)
+			mockSvc.AssertNotCalled(t, "ListAuditEvents", mock.Anything, mock.Anything)
+		}
+	})
+
+	t.Run("query runs under the gateway deadline", func(t *testing.T) {
+		var got context.Context
+		mockSvc := new(MockUserService)
+		mockSvc.On("ListAuditEvents", mock.Anything, mock.Anything).
+			Run(func(args mock.Arguments) { got = args.Get(0).(context.Context) }).
+			Return(&models.AuditEventPage{}, nil)
+
+		gin.SetMode(gin.TestMode)
+		router := gin.New()
+		router.GET("/admin/audit-events", middleware.RequestDeadline(), NewUserHandler(mockSvc, "test-secret").ListAuditEvents)
+
+		w := httptest.NewRecorder()
+		r, _ := http.NewRequest("GET", "/admin/audit-events", nil)
+		r.Header.Set(middleware.RequestTimeoutHeader, "5000")
+		router.ServeHTTP(w, r)
+
+		assert.Equal(t, http.StatusOK, w.Code)
+		deadline, ok := got.Deadline()
+		require.True(t, ok, "audit query must receive the request context")
+		assert.WithinDuration(t, time.Now().Add(5*time.Second), deadline, time.Second)
+	})
+
+	t.Run("not configured", func(t *testing.T) {
+		mockSvc := new(MockUserService)
+		mockSvc.On("ListAuditEvents", mock.Anything, mock.Anything).Return(nil, services.ErrAuditDisabled)
+
+		router := setupTestRouter(NewUserHandler(mockSvc, "test-secret"))
+		w := httptest.NewRecorder()
+		r, _ := http.NewRequest("GET", "/admin/audit-events", nil)
+		router.ServeHTTP(w, r)
+
+		assert.Equal(t, http.StatusNotImplemented, w.Code)
+	})
+}
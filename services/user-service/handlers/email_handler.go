@@ -0,0 +1,67 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"user-service/models"
+	"user-service/services"
+)
+
+// RequestEmailChange 申請變更 email，確認信會寄到新的 email
+func (h *UserHandler) RequestEmailChange(c *gin.Context) {
+	id := c.Param("id")
+	if !requireSelfOrAdmin(c, id) {
+		return
+	}
+	var req models.EmailChangeRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if err := h.svc(c).RequestEmailChange(c.Request.Context(), id, req); err != nil {
+		if writeValidationError(c, err) {
+			return
+		}
+		c.JSON(emailChangeErrorStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusAccepted, gin.H{"message": "A confirmation link has been sent to the new email address"})
+}
+
+// ConfirmEmailChange 以確認信中的 token 完成 email 變更
+func (h *UserHandler) ConfirmEmailChange(c *gin.Context) {
+	var req models.ConfirmEmailChangeRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if err := h.svc(c).ConfirmEmailChange(c.Request.Context(), req); err != nil {
+		c.JSON(emailChangeErrorStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Email changed successfully, please log in again"})
+}
+
+// emailChangeErrorStatus 將 email 變更相關錯誤對應到 HTTP status
+func emailChangeErrorStatus(err error) int {
+	switch {
+	case errors.Is(err, services.ErrUserNotFound):
+		return http.StatusNotFound
+	case errors.Is(err, services.ErrInvalidCurrentPassword):
+		return http.StatusForbidden
+	case errors.Is(err, services.ErrEmailTaken):
+		return http.StatusConflict
+	case errors.Is(err, services.ErrInvalidEmailChangeToken):
+		return http.StatusBadRequest
+	case errors.Is(err, services.ErrEmailChangeDisabled):
+		return http.StatusNotImplemented
+	default:
+		return http.StatusInternalServerError
+	}
+}
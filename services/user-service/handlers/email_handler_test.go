@@ -0,0 +1,80 @@
+package handlers
+
+import (
+	"net/http"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"user-service/models"
+	"user-service/services"
+)
+
+// ===================================================================
+// 變更 email handler 測試
+// ===================================================================
+
+func TestRequestEmailChangeHandler(t *testing.T) {
+	req := models.EmailChangeRequest{NewEmail: "new@example.com", CurrentPassword: "currentpassword"}
+
+	t.Run("accepted", func(t *testing.T) {
+		mockSvc := new(MockUserService)
+		mockSvc.On("RequestEmailChange", mock.Anything, "abc-123", req).Return(nil)
+
+		w := postJSONAs(setupTestRouter(NewUserHandler(mockSvc, "test-secret")), "abc-123", "POST", "/users/abc-123/email", req)
+
+		assert.Equal(t, http.StatusAccepted, w.Code)
+		mockSvc.AssertExpectations(t)
+	})
+
+	t.Run("invalid email", func(t *testing.T) {
+		mockSvc := new(MockUserService)
+
+		w := postJSONAs(setupTestRouter(NewUserHandler(mockSvc, "test-secret")), "abc-123", "POST", "/users/abc-123/email",
+			models.EmailChangeRequest{NewEmail: "not-an-email", CurrentPassword: "currentpassword"})
+
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+		mockSvc.AssertNotCalled(t, "RequestEmailChange", mock.Anything, mock.Anything, mock.Anything)
+	})
+
+	t.Run("email taken", func(t *testing.T) {
+		mockSvc := new(MockUserService)
+		mockSvc.On("RequestEmailChange", mock.Anything, "abc-123", req).Return(services.ErrEmailTaken)
+
+		w := postJSONAs(setupTestRouter(NewUserHandler(mockSvc, "test-secret")), "abc-123", "POST", "/users/abc-123/email", req)
+
+		assert.Equal(t, http.StatusConflict, w.Code)
+	})
+
+	t.Run("wrong current password", func(t *testing.T) {
+		mockSvc := new(MockUserService)
+		mockSvc.On("RequestEmailChange", mock.Anything, "abc-123", req).Return(services.ErrInvalidCurrentPassword)
+
+		w := postJSONAs(setupTestRouter(NewUserHandler(mockSvc, "test-secret")), "abc-123", "POST", "/users/abc-123/email", req)
+
+		assert.Equal(t, http.StatusForbidden, w.Code)
+	})
+}
+
+func TestConfirmEmailChangeHandler(t *testing.T) {
+	req := models.ConfirmEmailChangeRequest{Token: "raw-token"}
+
+	t.Run("success", func(t *testing.T) {
+		mockSvc := new(MockUserService)
+		mockSvc.On("ConfirmEmailChange", mock.Anything, req).Return(nil)
+
+		w := postJSON(setupTestRouter(NewUserHandler(mockSvc, "test-secret")), "POST", "/users/email/confirm", req)
+
+		assert.Equal(t, http.StatusOK, w.Code)
+		mockSvc.AssertExpectations(t)
+	})
+
+	t.Run("invalid token", func(t *testing.T) {
+		mockSvc := new(MockUserService)
+		mockSvc.On("ConfirmEmailChange", mock.Anything, req).Return(services.ErrInvalidEmailChangeToken)
+
+		w := postJSON(setupTestRouter(NewUserHandler(mockSvc, "test-secret")), "POST", "/users/email/confirm", req)
+
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+	})
+}
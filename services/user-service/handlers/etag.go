@@ -0,0 +1,42 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"user-service/etag"
+)
+
+// userETag 以用戶的版本號產生 strong ETag，例如 "3"
+func userETag(version int) string {
+	return etag.Format(version)
+}
+
+// expectedVersion 從 If-Match header 取出客戶端預期的版本，規則見 etag.ParseIfMatch
+//
+//   - 沒帶 header 且要求必須帶：428
+//   - weak ETag 或不是本服務產生的 ETag：412
+//   - 格式錯誤：400
+//
+// 回傳 false 時已寫入錯誤響應，呼叫端直接 return 即可
+func (h *UserHandler) expectedVersion(c *gin.Context) (int, bool) {
+	version, err := etag.ParseIfMatch(c.GetHeader("If-Match"), h.requireIfMatch)
+	if err != nil {
+		c.JSON(ifMatchErrorStatus(err), gin.H{"error": err.Error()})
+		return 0, false
+	}
+	return version, true
+}
+
+// ifMatchErrorStatus 將 If-Match 解析錯誤對應到 HTTP status
+func ifMatchErrorStatus(err error) int {
+	switch {
+	case errors.Is(err, etag.ErrRequired):
+		return http.StatusPreconditionRequired
+	case errors.Is(err, etag.ErrMalformed):
+		return http.StatusBadRequest
+	default:
+		return http.StatusPreconditionFailed
+	}
+}
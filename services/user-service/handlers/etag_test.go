@@ -0,0 +1,125 @@
+package handlers
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"user-service/models"
+	"user-service/services"
+)
+
+// sendWithIfMatch 以 abc-123 本人的身份送出帶 If-Match header 的請求，ifMatch 為空字串時不帶 header
+func sendWithIfMatch(handler *UserHandler, method, path, ifMatch string, payload interface{}) *httptest.ResponseRecorder {
+	body, _ := json.Marshal(payload)
+	w := httptest.NewRecorder()
+	r, _ := http.NewRequest(method, path, bytes.NewBuffer(body))
+	r.Header.Set("Content-Type", "application/json")
+	r.Header.Set(HeaderUserID, "abc-123")
+	if ifMatch != "" {
+		r.Header.Set("If-Match", ifMatch)
+	}
+	setupTestRouter(handler).ServeHTTP(w, r)
+	return w
+}
+
+// ===================================================================
+// If-Match 樂觀鎖測試
+// ===================================================================
+
+func TestUpdateUserHandler_IfMatch(t *testing.T) {
+	req := models.UpdateUserRequest{Username: "newname"}
+
+	t.Run("matching version", func(t *testing.T) {
+		mockSvc := new(MockUserService)
+		mockSvc.On("UpdateUser", mock.Anything, "abc-123", req, 3).Return(nil)
+
+		w := sendWithIfMatch(NewUserHandler(mockSvc, "test-secret"), "PUT", "/users/abc-123", `"3"`, req)
+
+		assert.Equal(t, http.StatusOK, w.Code)
+		mockSvc.AssertExpectations(t)
+	})
+
+	t.Run("stale version", func(t *testing.T) {
+		mockSvc := new(MockUserService)
+		mockSvc.On("UpdateUser", mock.Anything, "abc-123", req, 2).Return(services.ErrVersionConflict)
+
+		w := sendWithIfMatch(NewUserHandler(mockSvc, "test-secret"), "PUT", "/users/abc-123", `"2"`, req)
+
+		assert.Equal(t, http.StatusPreconditionFailed, w.Code)
+		mockSvc.AssertExpectations(t)
+	})
+
+	t.Run("no header skips the check", func(t *testing.T) {
+		mockSvc := new(MockUserService)
+		mockSvc.On("UpdateUser", mock.Anything, "abc-123", req, 0).Return(nil)
+
+		w := sendWithIfMatch(NewUserHandler(mockSvc, "test-secret"), "PUT", "/users/abc-123", "", req)
+
+		assert.Equal(t, http.StatusOK, w.Code)
+		mockSvc.AssertExpectations(t)
+	})
+
+	t.Run("wildcard", func(t *testing.T) {
+		mockSvc := new(MockUserService)
+		mockSvc.On("UpdateUser", mock.Anything, "abc-123", req, 0).Return(nil)
+
+		w := sendWithIfMatch(NewUserHandler(mockSvc, "test-secret"), "PUT", "/users/abc-123", "*", req)
+
+		assert.Equal(t, http.StatusOK, w.Code)
+		mockSvc.AssertExpectations(t)
+	})
+
+	t.Run("weak etag never matches", func(t *testing.T) {
+		mockSvc := new(MockUserService)
+
+		w := sendWithIfMatch(NewUserHandler(mockSvc, "test-secret"), "PUT", "/users/abc-123", `W/"3"`, req)
+
+		assert.Equal(t, http.StatusPreconditionFailed, w.Code)
+		mockSvc.AssertNotCalled(t, "UpdateUser", mock.Anything, mock.Anything, mock.Anything, mock.Anything)
+	})
+
+	t.Run("malformed header", func(t *testing.T) {
+		mockSvc := new(MockUserService)
+
+		w := sendWithIfMatch(NewUserHandler(mockSvc, "test-secret"), "PUT", "/users/abc-123", "3", req)
+
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+		mockSvc.AssertNotCalled(t, "UpdateUser", mock.Anything, mock.Anything, mock.Anything, mock.Anything)
+	})
+
+	t.Run("required but missing", func(t *testing.T) {
+		mockSvc := new(MockUserService)
+
+		w := sendWithIfMatch(NewUserHandler(mockSvc, "test-secret", WithRequireIfMatch()), "PUT", "/users/abc-123", "", req)
+
+		assert.Equal(t, http.StatusPreconditionRequired, w.Code)
+		mockSvc.AssertNotCalled(t, "UpdateUser", mock.Anything, mock.Anything, mock.Anything, mock.Anything)
+	})
+}
+
+func TestDeleteUserHandler_IfMatch(t *testing.T) {
+	t.Run("matching version", func(t *testing.T) {
+		mockSvc := new(MockUserService)
+		mockSvc.On("DeleteUser", mock.Anything, "abc-123", 5).Return(nil)
+
+		w := sendWithIfMatch(NewUserHandler(mockSvc, "test-secret"), "DELETE", "/users/abc-123", `"5"`, nil)
+
+		assert.Equal(t, http.StatusOK, w.Code)
+		mockSvc.AssertExpectations(t)
+	})
+
+	t.Run("stale version", func(t *testing.T) {
+		mockSvc := new(MockUserService)
+		mockSvc.On("DeleteUser", mock.Anything, "abc-123", 4).Return(services.ErrVersionConflict)
+
+		w := sendWithIfMatch(NewUserHandler(mockSvc, "test-secret"), "DELETE", "/users/abc-123", `"4"`, nil)
+
+		assert.Equal(t, http.StatusPreconditionFailed, w.Code)
+		mockSvc.AssertExpectations(t)
+	})
+}
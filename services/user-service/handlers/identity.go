@@ -0,0 +1,82 @@
+package handlers
+
+import (
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"user-service/models"
+)
+
+// API Gateway 在 RequireAuth 通過後注入的身份 header，客戶端自帶的同名 header 會先被 gateway 移除
+const (
+	HeaderUserID    = "X-User-ID"
+	HeaderUserEmail = "X-User-Email"
+	HeaderUserRoles = "X-User-Roles" // 以逗號分隔
+	HeaderSessionID = "X-Session-ID"
+)
+
+// currentIdentity 從 gateway 注入的 header 取出呼叫者身份，未登入的請求回傳 false
+func currentIdentity(c *gin.Context) (models.Identity, bool) {
+	userID := c.GetHeader(HeaderUserID)
+	if userID == "" {
+		return models.Identity{}, false
+	}
+
+	identity := models.Identity{
+		UserID:    userID,
+		Email:     c.GetHeader(HeaderUserEmail),
+		SessionID: c.GetHeader(HeaderSessionID),
+	}
+	for _, role := range strings.Split(c.GetHeader(HeaderUserRoles), ",") {
+		if role = strings.TrimSpace(role); role != "" {
+			identity.Roles = append(identity.Roles, role)
+		}
+	}
+	return identity, true
+}
+
+// ForCurrentUser 讓 /users/:id 的 handler 也能用在 /users/me：
+// 以呼叫者自己的 user ID 作為 :id 參數，再交給 next 處理
+func ForCurrentUser(next gin.HandlerFunc) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		identity, ok := currentIdentity(c)
+		if !ok {
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "missing caller identity"})
+			return
+		}
+
+		c.Params = append(c.Params, gin.Param{Key: "id", Value: identity.UserID})
+		next(c)
+	}
+}
+
+// requireSelf 確認呼叫者就是 :id 指向的用戶，否則回傳 401/403
+// MFA secret 與復原碼只能交給帳號本人，管理員也不能代為操作；回傳 false 時呼叫端應直接 return
+func requireSelf(c *gin.Context, id string) bool {
+	identity, ok := currentIdentity(c)
+	if !ok {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "missing caller identity"})
+		return false
+	}
+	if identity.UserID != id {
+		c.JSON(http.StatusForbidden, gin.H{"error": "only the account owner can manage MFA"})
+		return false
+	}
+	return true
+}
+
+// requireSelfOrAdmin 確認呼叫者是 :id 指向的用戶本人或管理員，否則回傳 401/403
+// 用在修改、刪除用戶資料的 handler；回傳 false 時呼叫端應直接 return
+func requireSelfOrAdmin(c *gin.Context, id string) bool {
+	identity, ok := currentIdentity(c)
+	if !ok {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "missing caller identity"})
+		return false
+	}
+	if identity.UserID != id && !identity.HasRole(models.RoleAdmin) {
+		c.JSON(http.StatusForbidden, gin.H{"error": "only the account owner or an admin can modify this user"})
+		return false
+	}
+	return true
+}
@@ -0,0 +1,183 @@
+package handlers
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"user-service/models"
+)
+
+// ===================================================================
+// /users/me 測試
+// ===================================================================
+
+func TestCurrentUserEndpoints(t *testing.T) {
+	t.Run("GET uses caller identity", func(t *testing.T) {
+		mockSvc := new(MockUserService)
+		mockSvc.On("GetUserByID", mock.Anything, "uuid-001").Return(&models.User{ID: "uuid-001", Version: 2}, nil)
+
+		w := httptest.NewRecorder()
+		r, _ := http.NewRequest("GET", "/users/me", nil)
+		r.Header.Set(HeaderUserID, "uuid-001")
+		setupTestRouter(NewUserHandler(mockSvc, "test-secret")).ServeHTTP(w, r)
+
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.Equal(t, `"2"`, w.Header().Get("ETag"))
+		mockSvc.AssertExpectations(t)
+	})
+
+	t.Run("PATCH uses caller identity", func(t *testing.T) {
+		mockSvc := new(MockUserService)
+		mockSvc.On("PatchUser", mock.Anything, "uuid-001", mock.Anything, 0).Return(&models.User{ID: "uuid-001", Bio: "hi"}, nil)
+
+		w := httptest.NewRecorder()
+		r, _ := http.NewRequest("PATCH", "/users/me", bytes.NewBufferString(`{"bio":"hi"}`))
+		r.Header.Set("Content-Type", models.MergePatchContentType)
+		r.Header.Set(HeaderUserID, "uuid-001")
+		setupTestRouter(NewUserHandler(mockSvc, "test-secret")).ServeHTTP(w, r)
+
+		assert.Equal(t, http.StatusOK, w.Code)
+		var resp models.User
+		json.Unmarshal(w.Body.Bytes(), &resp)
+		assert.Equal(t, "hi", resp.Bio)
+		mockSvc.AssertExpectations(t)
+	})
+
+	t.Run("DELETE uses caller identity", func(t *testing.T) {
+		mockSvc := new(MockUserService)
+		mockSvc.On("DeleteUser", mock.Anything, "uuid-001", 0).Return(nil)
+
+		w := httptest.NewRecorder()
+		r, _ := http.NewRequest("DELETE", "/users/me", nil)
+		r.Header.Set(HeaderUserID, "uuid-001")
+		setupTestRouter(NewUserHandler(mockSvc, "test-secret")).ServeHTTP(w, r)
+
+		assert.Equal(t, http.StatusOK, w.Code)
+		mockSvc.AssertExpectations(t)
+	})
+
+	t.Run("missing identity", func(t *testing.T) {
+		mockSvc := new(MockUserService)
+
+		w := httptest.NewRecorder()
+		r, _ := http.NewRequest("GET", "/users/me", nil)
+		setupTestRouter(NewUserHandler(mockSvc, "test-secret")).ServeHTTP(w, r)
+
+		assert.Equal(t, http.StatusUnauthorized, w.Code)
+		mockSvc.AssertNotCalled(t, "GetUserByID", mock.Anything, mock.Anything)
+	})
+}
+
+func TestCurrentIdentity_ParsesRoles(t *testing.T) {
+	r, _ := http.NewRequest("GET", "/", nil)
+	r.Header.Set(HeaderUserID, "uuid-001")
+	r.Header.Set(HeaderUserEmail, "user@example.com")
+	r.Header.Set(HeaderUserRoles, "admin, user")
+	c, _ := gin.CreateTestContext(httptest.NewRecorder())
+	c.Request = r
+
+	identity, ok := currentIdentity(c)
+
+	assert.True(t, ok)
+	assert.Equal(t, "user@example.com", identity.Email)
+	assert.Equal(t, []string{"admin", "user"}, identity.Roles)
+	assert.True(t, identity.HasRole("admin"))
+}
+
+// ===================================================================
+// 修改他人資料的權限測試
+// ===================================================================
+
+func TestModifyUserRequiresSelfOrAdmin(t *testing.T) {
+	endpoints := []struct {
+		name      string
+		method    string
+		path      string
+		body      string
+		svcMethod string
+		// expect 設定管理員代為操作時 service 的預期呼叫
+		expect func(m *MockUserService)
+	}{
+		{
+			name: "update", method: "PUT", path: "/users/user-b", body: `{"username":"mallory"}`, svcMethod: "UpdateUser",
+			expect: func(m *MockUserService) {
+				m.On("UpdateUser", mock.Anything, "user-b", mock.Anything, 0).Return(nil)
+			},
+		},
+		{
+			name: "patch", method: "PATCH", path: "/users/user-b", body: `{"avatar_url":"https://evil.example/x.png"}`, svcMethod: "PatchUser",
+			expect: func(m *MockUserService) {
+				m.On("PatchUser", mock.Anything, "user-b", mock.Anything, 0).Return(&models.User{ID: "user-b"}, nil)
+			},
+		},
+		{
+			name: "delete", method: "DELETE", path: "/users/user-b", svcMethod: "DeleteUser",
+			expect: func(m *MockUserService) {
+				m.On("DeleteUser", mock.Anything, "user-b", 0).Return(nil)
+			},
+		},
+		{
+			name: "change password", method: "PUT", path: "/users/user-b/password",
+			body: `{"current_password":"oldpassword1","new_password":"brand-new-secret9"}`, svcMethod: "ChangePassword",
+			expect: func(m *MockUserService) {
+				m.On("ChangePassword", mock.Anything, "user-b", mock.Anything).Return(nil)
+			},
+		},
+		{
+			name: "request email change", method: "POST", path: "/users/user-b/email",
+			body: `{"new_email":"evil@example.com","current_password":"currentpassword"}`, svcMethod: "RequestEmailChange",
+			expect: func(m *MockUserService) {
+				m.On("RequestEmailChange", mock.Anything, "user-b", mock.Anything).Return(nil)
+			},
+		},
+	}
+
+	callers := []struct {
+		name     string
+		userID   string
+		roles    string
+		allowed  bool
+		wantCode int // 被拒絕時的 status code
+	}{
+		{name: "other user", userID: "user-a", wantCode: http.StatusForbidden},
+		{name: "missing identity", wantCode: http.StatusUnauthorized},
+		{name: "admin", userID: "admin-1", roles: models.RoleAdmin, allowed: true},
+	}
+
+	for _, ep := range endpoints {
+		for _, caller := range callers {
+			t.Run(ep.name+"/"+caller.name, func(t *testing.T) {
+				mockSvc := new(MockUserService)
+				if caller.allowed {
+					ep.expect(mockSvc)
+				}
+
+				w := httptest.NewRecorder()
+				r, _ := http.NewRequest(ep.method, ep.path, bytes.NewBufferString(ep.body))
+				r.Header.Set("Content-Type", "application/json")
+				if caller.userID != "" {
+					r.Header.Set(HeaderUserID, caller.userID)
+				}
+				if caller.roles != "" {
+					r.Header.Set(HeaderUserRoles, caller.roles)
+				}
+				setupTestRouter(NewUserHandler(mockSvc, "test-secret")).ServeHTTP(w, r)
+
+				if caller.allowed {
+					assert.Less(t, w.Code, 300)
+					mockSvc.AssertExpectations(t)
+					return
+				}
+				assert.Equal(t, caller.wantCode, w.Code)
+				// 權限不足時 service 完全不應被呼叫
+				assert.Empty(t, mockSvc.Calls, ep.svcMethod)
+			})
+		}
+	}
+}
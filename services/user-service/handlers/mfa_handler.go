@@ -0,0 +1,116 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"user-service/auth"
+	"user-service/models"
+	"user-service/services"
+)
+
+// MFAChallengeTTL 帳密驗證通過後，輸入 MFA code 的時間限制
+const MFAChallengeTTL = auth.MFAChallengeTTL
+
+// LoginMFA 兩階段登入的第二步：驗證 challenge token 與 TOTP code（或復原碼）後簽發 access token
+func (h *UserHandler) LoginMFA(c *gin.Context) {
+	var req models.MFALoginRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	userID, err := h.tokens.ParseMFAChallenge(req.MFAToken)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": err.Error()})
+		return
+	}
+
+	user, err := h.svc(c).VerifyMFA(c.Request.Context(), userID, req.Code)
+	if err != nil {
+		if errors.Is(err, services.ErrInvalidMFACode) || errors.Is(err, services.ErrMFANotEnrolled) {
+			c.JSON(http.StatusUnauthorized, gin.H{"error": services.ErrInvalidMFACode.Error()})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	h.respondWithAccessToken(c, user)
+}
+
+// EnrollTOTP 開始綁定 TOTP，回傳 secret 與 provisioning URI
+func (h *UserHandler) EnrollTOTP(c *gin.Context) {
+	id := c.Param("id")
+	if !requireSelf(c, id) {
+		return
+	}
+	enrollment, err := h.svc(c).EnrollTOTP(c.Request.Context(), id)
+	if err != nil {
+		c.JSON(mfaErrorStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, enrollment)
+}
+
+// ConfirmTOTP 確認綁定並啟用 MFA，回傳一次性的復原碼
+func (h *UserHandler) ConfirmTOTP(c *gin.Context) {
+	id := c.Param("id")
+	if !requireSelf(c, id) {
+		return
+	}
+	var req models.MFACodeRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	codes, err := h.svc(c).ConfirmTOTP(c.Request.Context(), id, req.Code)
+	if err != nil {
+		c.JSON(mfaErrorStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, models.RecoveryCodesResponse{
+		Message:       "MFA enabled, store these recovery codes somewhere safe",
+		RecoveryCodes: codes,
+	})
+}
+
+// DisableMFA 停用 MFA，需提供目前有效的 TOTP code 或復原碼
+func (h *UserHandler) DisableMFA(c *gin.Context) {
+	id := c.Param("id")
+	if !requireSelf(c, id) {
+		return
+	}
+	var req models.MFACodeRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if err := h.svc(c).DisableMFA(c.Request.Context(), id, req.Code); err != nil {
+		c.JSON(mfaErrorStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "MFA disabled"})
+}
+
+// mfaErrorStatus 將 MFA 相關錯誤對應到 HTTP status
+func mfaErrorStatus(err error) int {
+	switch {
+	case errors.Is(err, services.ErrUserNotFound):
+		return http.StatusNotFound
+	case errors.Is(err, services.ErrMFAAlreadyEnabled), errors.Is(err, services.ErrMFANotEnrolled):
+		return http.StatusConflict
+	case errors.Is(err, services.ErrInvalidMFACode):
+		return http.StatusBadRequest
+	case errors.Is(err, services.ErrMFADisabled):
+		return http.StatusNotImplemented
+	default:
+		return http.StatusInternalServerError
+	}
+}
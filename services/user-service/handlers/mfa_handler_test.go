@@ -0,0 +1,257 @@
+package handlers
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+	"user-service/models"
+	"user-service/services"
+)
+
+// mfaTestNow 測試用的固定時間
+var mfaTestNow = time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+
+// postJSON 送出 JSON 請求並回傳 recorder
+func postJSON(router http.Handler, method, path string, payload interface{}) *httptest.ResponseRecorder {
+	return postJSONAs(router, "", method, path, payload)
+}
+
+// postJSONAs 以 userID 的身份送出 JSON 請求，userID 為空時不帶身份 header
+func postJSONAs(router http.Handler, userID, method, path string, payload interface{}) *httptest.ResponseRecorder {
+	body, _ := json.Marshal(payload)
+	w := httptest.NewRecorder()
+	r, _ := http.NewRequest(method, path, bytes.NewBuffer(body))
+	r.Header.Set("Content-Type", "application/json")
+	if userID != "" {
+		r.Header.Set(HeaderUserID, userID)
+	}
+	router.ServeHTTP(w, r)
+	return w
+}
+
+// ===================================================================
+// 兩階段登入測試
+// ===================================================================
+
+func TestLoginHandler_MFARequired(t *testing.T) {
+	mockSvc := new(MockUserService)
+	mockSvc.On("Login", mock.Anything, models.LoginRequest{Email: "user@example.com", Password: "password123"}).
+		Return(&models.User{ID: "uuid-001", Email: "user@example.com", MFAEnabled: true}, nil)
+
+	router := setupTestRouter(NewUserHandler(mockSvc, "test-secret"))
+	w := postJSON(router, "POST", "/users/login", models.LoginRequest{Email: "user@example.com", Password: "password123"})
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	var resp map[string]interface{}
+	json.Unmarshal(w.Body.Bytes(), &resp)
+	assert.Equal(t, true, resp["mfa_required"])
+	assert.NotEmpty(t, resp["mfa_token"])
+	// 還沒過 MFA，不能拿到 access token
+	assert.NotContains(t, resp, "token")
+
+	// challenge token 用原本的 JWT_SECRET 驗不過，gateway 不會把它當成 access token
+	_, err := jwt.Parse(resp["mfa_token"].(string), func(*jwt.Token) (interface{}, error) {
+		return []byte("test-secret"), nil
+	})
+	assert.Error(t, err)
+	mockSvc.AssertExpectations(t)
+}
+
+func TestLoginMFAHandler(t *testing.T) {
+	clock := mfaTestNow
+	newHandler := func(svc services.UserServiceInterface) *UserHandler {
+		return NewUserHandler(svc, "test-secret", WithHandlerClock(func() time.Time { return clock }))
+	}
+
+	t.Run("success issues access token", func(t *testing.T) {
+		clock = mfaTestNow
+		mockSvc := new(MockUserService)
+		mockSvc.On("VerifyMFA", mock.Anything, "uuid-001", "123456").Return(&models.User{ID: "uuid-001", Email: "user@example.com"}, nil)
+		// 未開啟 session 紀錄時仍照常簽發 token
+		mockSvc.On("StartSession", mock.Anything, "uuid-001", mfaTestNow.Add(TokenTTL)).Return(nil, services.ErrSessionsDisabled)
+
+		handler := newHandler(mockSvc)
+		challenge, err := handler.tokens.IssueMFAChallenge("uuid-001")
+		require.NoError(t, err)
+
+		w := postJSON(setupTestRouter(handler), "POST", "/users/login/mfa",
+			models.MFALoginRequest{MFAToken: challenge, Code: "123456"})
+
+		assert.Equal(t, http.StatusOK, w.Code)
+		var resp models.LoginResponse
+		json.Unmarshal(w.Body.Bytes(), &resp)
+		assert.NotEmpty(t, resp.Token)
+		mockSvc.AssertExpectations(t)
+	})
+
+	t.Run("expired challenge", func(t *testing.T) {
+		clock = mfaTestNow
+		mockSvc := new(MockUserService)
+		handler := newHandler(mockSvc)
+		challenge, err := handler.tokens.IssueMFAChallenge("uuid-001")
+		require.NoError(t, err)
+
+		// 超過 challenge 有效期限才送出 code
+		clock = mfaTestNow.Add(MFAChallengeTTL + time.Second)
+		w := postJSON(setupTestRouter(handler), "POST", "/users/login/mfa",
+			models.MFALoginRequest{MFAToken: challenge, Code: "123456"})
+
+		assert.Equal(t, http.StatusUnauthorized, w.Code)
+		mockSvc.AssertNotCalled(t, "VerifyMFA", mock.Anything, mock.Anything, mock.Anything)
+	})
+
+	t.Run("access token cannot be used as challenge", func(t *testing.T) {
+		clock = mfaTestNow
+		mockSvc := new(MockUserService)
+		handler := newHandler(mockSvc)
+		accessToken, _ := jwt.NewWithClaims(jwt.SigningMethodHS256, &Claims{
+			UserID: "uuid-001",
+			RegisteredClaims: jwt.RegisteredClaims{
+				ExpiresAt: jwt.NewNumericDate(mfaTestNow.Add(time.Hour)),
+			},
+		}).SignedString([]byte("test-secret"))
+
+		w := postJSON(setupTestRouter(handler), "POST", "/users/login/mfa",
+			models.MFALoginRequest{MFAToken: accessToken, Code: "123456"})
+
+		assert.Equal(t, http.StatusUnauthorized, w.Code)
+		mockSvc.AssertNotCalled(t, "VerifyMFA", mock.Anything, mock.Anything, mock.Anything)
+	})
+
+	t.Run("invalid code", func(t *testing.T) {
+		clock = mfaTestNow
+		mockSvc := new(MockUserService)
+		mockSvc.On("VerifyMFA", mock.Anything, "uuid-001", "000000").Return(nil, services.ErrInvalidMFACode)
+
+		handler := newHandler(mockSvc)
+		challenge, _ := handler.tokens.IssueMFAChallenge("uuid-001")
+
+		w := postJSON(setupTestRouter(handler), "POST", "/users/login/mfa",
+			models.MFALoginRequest{MFAToken: challenge, Code: "000000"})
+
+		assert.Equal(t, http.StatusUnauthorized, w.Code)
+		mockSvc.AssertExpectations(t)
+	})
+}
+
+// ===================================================================
+// TOTP 綁定測試
+// ===================================================================
+
+func TestEnrollTOTPHandler(t *testing.T) {
+	t.Run("success", func(t *testing.T) {
+		mockSvc := new(MockUserService)
+		mockSvc.On("EnrollTOTP", mock.Anything, "abc-123").Return(&models.TOTPEnrollment{
+			Secret:          "JBSWY3DPEHPK3PXP",
+			ProvisioningURI: "otpauth://totp/Core:alice?secret=JBSWY3DPEHPK3PXP",
+		}, nil)
+
+		w := postJSONAs(setupTestRouter(NewUserHandler(mockSvc, "test-secret")), "abc-123", "POST", "/users/abc-123/mfa/totp", nil)
+
+		assert.Equal(t, http.StatusOK, w.Code)
+		var resp models.TOTPEnrollment
+		json.Unmarshal(w.Body.Bytes(), &resp)
+		assert.Equal(t, "JBSWY3DPEHPK3PXP", resp.Secret)
+		mockSvc.AssertExpectations(t)
+	})
+
+	t.Run("already enabled", func(t *testing.T) {
+		mockSvc := new(MockUserService)
+		mockSvc.On("EnrollTOTP", mock.Anything, "abc-123").Return(nil, services.ErrMFAAlreadyEnabled)
+
+		w := postJSONAs(setupTestRouter(NewUserHandler(mockSvc, "test-secret")), "abc-123", "POST", "/users/abc-123/mfa/totp", nil)
+
+		assert.Equal(t, http.StatusConflict, w.Code)
+		mockSvc.AssertExpectations(t)
+	})
+	t.Run("another user's account is forbidden", func(t *testing.T) {
+		mockSvc := new(MockUserService)
+
+		w := postJSONAs(setupTestRouter(NewUserHandler(mockSvc, "test-secret")), "user-a", "POST", "/users/user-b/mfa/totp", nil)
+
+		assert.Equal(t, http.StatusForbidden, w.Code)
+		// 不是本人，service 不應該被呼叫
+		mockSvc.AssertNotCalled(t, "EnrollTOTP", mock.Anything, mock.Anything)
+	})
+
+	t.Run("admin cannot enroll for another user", func(t *testing.T) {
+		mockSvc := new(MockUserService)
+		router := setupTestRouter(NewUserHandler(mockSvc, "test-secret"))
+
+		w := httptest.NewRecorder()
+		r, _ := http.NewRequest("POST", "/users/user-b/mfa/totp", nil)
+		r.Header.Set(HeaderUserID, "admin-1")
+		r.Header.Set(HeaderUserRoles, "admin")
+		router.ServeHTTP(w, r)
+
+		assert.Equal(t, http.StatusForbidden, w.Code)
+		mockSvc.AssertNotCalled(t, "EnrollTOTP", mock.Anything, mock.Anything)
+	})
+
+	t.Run("missing identity", func(t *testing.T) {
+		mockSvc := new(MockUserService)
+
+		w := postJSON(setupTestRouter(NewUserHandler(mockSvc, "test-secret")), "POST", "/users/abc-123/mfa/totp", nil)
+
+		assert.Equal(t, http.StatusUnauthorized, w.Code)
+		mockSvc.AssertNotCalled(t, "EnrollTOTP", mock.Anything, mock.Anything)
+	})
+}
+
+func TestConfirmTOTPHandler(t *testing.T) {
+	t.Run("success returns recovery codes", func(t *testing.T) {
+		mockSvc := new(MockUserService)
+		mockSvc.On("ConfirmTOTP", mock.Anything, "abc-123", "123456").Return([]string{"aaaaa-bbbbb", "ccccc-ddddd"}, nil)
+
+		w := postJSONAs(setupTestRouter(NewUserHandler(mockSvc, "test-secret")), "abc-123", "POST",
+			"/users/abc-123/mfa/totp/confirm", models.MFACodeRequest{Code: "123456"})
+
+		assert.Equal(t, http.StatusOK, w.Code)
+		var resp models.RecoveryCodesResponse
+		json.Unmarshal(w.Body.Bytes(), &resp)
+		assert.Len(t, resp.RecoveryCodes, 2)
+		mockSvc.AssertExpectations(t)
+	})
+
+	t.Run("invalid code", func(t *testing.T) {
+		mockSvc := new(MockUserService)
+		mockSvc.On("ConfirmTOTP", mock.Anything, "abc-123", "000000").Return(nil, services.ErrInvalidMFACode)
+
+		w := postJSONAs(setupTestRouter(NewUserHandler(mockSvc, "test-secret")), "abc-123", "POST",
+			"/users/abc-123/mfa/totp/confirm", models.MFACodeRequest{Code: "000000"})
+
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+		mockSvc.AssertExpectations(t)
+	})
+}
+
+func TestDisableMFAHandler(t *testing.T) {
+	t.Run("success", func(t *testing.T) {
+		mockSvc := new(MockUserService)
+		mockSvc.On("DisableMFA", mock.Anything, "abc-123", "123456").Return(nil)
+
+		w := postJSONAs(setupTestRouter(NewUserHandler(mockSvc, "test-secret")), "abc-123", "DELETE",
+			"/users/abc-123/mfa/totp", models.MFACodeRequest{Code: "123456"})
+
+		assert.Equal(t, http.StatusOK, w.Code)
+		mockSvc.AssertExpectations(t)
+	})
+
+	t.Run("another user's account is forbidden", func(t *testing.T) {
+		mockSvc := new(MockUserService)
+
+		w := postJSONAs(setupTestRouter(NewUserHandler(mockSvc, "test-secret")), "user-a", "DELETE",
+			"/users/user-b/mfa/totp", models.MFACodeRequest{Code: "123456"})
+
+		assert.Equal(t, http.StatusForbidden, w.Code)
+		mockSvc.AssertNotCalled(t, "DisableMFA", mock.Anything, mock.Anything, mock.Anything)
+	})
+}
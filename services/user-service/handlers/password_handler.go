@@ -0,0 +1,97 @@
+package handlers
+
+import (
+	"errors"
+	"log"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"user-service/models"
+	"user-service/security"
+	"user-service/services"
+)
+
+// ForgotPassword 忘記密碼處理
+// 只要 body 格式正確一律回 202，不透露 email 是否存在，也不透露寄信是否成功
+func (h *UserHandler) ForgotPassword(c *gin.Context) {
+	var req models.ForgotPasswordRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if err := h.svc(c).ForgotPassword(c.Request.Context(), req); err != nil {
+		log.Printf("forgot password failed: %v", err)
+	}
+
+	c.JSON(http.StatusAccepted, gin.H{
+		"message": "If the email is registered, a password reset link has been sent",
+	})
+}
+
+// ResetPassword 以重設 token 設定新密碼
+func (h *UserHandler) ResetPassword(c *gin.Context) {
+	var req models.ResetPasswordRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if err := h.svc(c).ResetPassword(c.Request.Context(), req); err != nil {
+		if writePolicyError(c, err) {
+			return
+		}
+		if errors.Is(err, services.ErrInvalidResetToken) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Password reset successfully"})
+}
+
+// ChangePassword 已登入用戶修改密碼
+func (h *UserHandler) ChangePassword(c *gin.Context) {
+	id := c.Param("id")
+	if !requireSelfOrAdmin(c, id) {
+		return
+	}
+	var req models.ChangePasswordRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if err := h.svc(c).ChangePassword(c.Request.Context(), id, req); err != nil {
+		if writePolicyError(c, err) {
+			return
+		}
+		switch {
+		case errors.Is(err, services.ErrUserNotFound):
+			c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		case errors.Is(err, services.ErrInvalidCurrentPassword):
+			c.JSON(http.StatusForbidden, gin.H{"error": err.Error()})
+		default:
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		}
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Password changed successfully, please log in again"})
+}
+
+// writePolicyError 若 err 是密碼規則錯誤，回傳 400 並逐條列出違反的規則
+// 有處理時回傳 true，呼叫端應直接 return
+func writePolicyError(c *gin.Context, err error) bool {
+	var policyErr *security.PolicyError
+	if !errors.As(err, &policyErr) {
+		return false
+	}
+	c.JSON(http.StatusBadRequest, gin.H{
+		"error":   "password does not meet policy",
+		"details": policyErr.Violations,
+	})
+	return true
+}
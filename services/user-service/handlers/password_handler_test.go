@@ -0,0 +1,217 @@
+package handlers
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"user-service/models"
+	"user-service/security"
+	"user-service/services"
+)
+
+// ===================================================================
+// ForgotPassword handler 測試
+// ===================================================================
+
+func TestForgotPasswordHandler(t *testing.T) {
+	t.Run("accepted", func(t *testing.T) {
+		mockSvc := new(MockUserService)
+		mockSvc.On("ForgotPassword", mock.Anything, models.ForgotPasswordRequest{Email: "user@example.com"}).Return(nil)
+
+		router := setupTestRouter(NewUserHandler(mockSvc, "test-secret"))
+
+		body, _ := json.Marshal(models.ForgotPasswordRequest{Email: "user@example.com"})
+		w := httptest.NewRecorder()
+		r, _ := http.NewRequest("POST", "/users/password/forgot", bytes.NewBuffer(body))
+		r.Header.Set("Content-Type", "application/json")
+		router.ServeHTTP(w, r)
+
+		assert.Equal(t, http.StatusAccepted, w.Code)
+		mockSvc.AssertExpectations(t)
+	})
+
+	t.Run("service error still accepted", func(t *testing.T) {
+		// 即使寄信失敗也回 202，外部看不出任何差異
+		mockSvc := new(MockUserService)
+		mockSvc.On("ForgotPassword", mock.Anything, models.ForgotPasswordRequest{Email: "user@example.com"}).
+			Return(fmt.Errorf("smtp down"))
+
+		router := setupTestRouter(NewUserHandler(mockSvc, "test-secret"))
+
+		body, _ := json.Marshal(models.ForgotPasswordRequest{Email: "user@example.com"})
+		w := httptest.NewRecorder()
+		r, _ := http.NewRequest("POST", "/users/password/forgot", bytes.NewBuffer(body))
+		r.Header.Set("Content-Type", "application/json")
+		router.ServeHTTP(w, r)
+
+		assert.Equal(t, http.StatusAccepted, w.Code)
+		mockSvc.AssertExpectations(t)
+	})
+
+	t.Run("invalid body", func(t *testing.T) {
+		mockSvc := new(MockUserService)
+
+		router := setupTestRouter(NewUserHandler(mockSvc, "test-secret"))
+
+		body, _ := json.Marshal(map[string]string{"email": "not-an-email"})
+		w := httptest.NewRecorder()
+		r, _ := http.NewRequest("POST", "/users/password/forgot", bytes.NewBuffer(body))
+		r.Header.Set("Content-Type", "application/json")
+		router.ServeHTTP(w, r)
+
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+		mockSvc.AssertExpectations(t)
+	})
+}
+
+// ===================================================================
+// ResetPassword handler 測試
+// ===================================================================
+
+func TestResetPasswordHandler(t *testing.T) {
+	req := models.ResetPasswordRequest{Token: "raw-token", NewPassword: "newpassword"}
+
+	t.Run("success", func(t *testing.T) {
+		mockSvc := new(MockUserService)
+		mockSvc.On("ResetPassword", mock.Anything, req).Return(nil)
+
+		router := setupTestRouter(NewUserHandler(mockSvc, "test-secret"))
+
+		body, _ := json.Marshal(req)
+		w := httptest.NewRecorder()
+		r, _ := http.NewRequest("POST", "/users/password/reset", bytes.NewBuffer(body))
+		r.Header.Set("Content-Type", "application/json")
+		router.ServeHTTP(w, r)
+
+		assert.Equal(t, http.StatusOK, w.Code)
+		mockSvc.AssertExpectations(t)
+	})
+
+	t.Run("invalid token", func(t *testing.T) {
+		mockSvc := new(MockUserService)
+		mockSvc.On("ResetPassword", mock.Anything, req).Return(services.ErrInvalidResetToken)
+
+		router := setupTestRouter(NewUserHandler(mockSvc, "test-secret"))
+
+		body, _ := json.Marshal(req)
+		w := httptest.NewRecorder()
+		r, _ := http.NewRequest("POST", "/users/password/reset", bytes.NewBuffer(body))
+		r.Header.Set("Content-Type", "application/json")
+		router.ServeHTTP(w, r)
+
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+		mockSvc.AssertExpectations(t)
+	})
+
+	t.Run("service error", func(t *testing.T) {
+		mockSvc := new(MockUserService)
+		mockSvc.On("ResetPassword", mock.Anything, req).Return(fmt.Errorf("db error"))
+
+		router := setupTestRouter(NewUserHandler(mockSvc, "test-secret"))
+
+		body, _ := json.Marshal(req)
+		w := httptest.NewRecorder()
+		r, _ := http.NewRequest("POST", "/users/password/reset", bytes.NewBuffer(body))
+		r.Header.Set("Content-Type", "application/json")
+		router.ServeHTTP(w, r)
+
+		assert.Equal(t, http.StatusInternalServerError, w.Code)
+		mockSvc.AssertExpectations(t)
+	})
+}
+
+// ===================================================================
+// ChangePassword handler 測試
+// ===================================================================
+
+func TestChangePasswordHandler(t *testing.T) {
+	req := models.ChangePasswordRequest{CurrentPassword: "oldpassword1", NewPassword: "brand-new-secret9"}
+
+	send := func(router http.Handler) *httptest.ResponseRecorder {
+		body, _ := json.Marshal(req)
+		w := httptest.NewRecorder()
+		r, _ := http.NewRequest("PUT", "/users/abc-123/password", bytes.NewBuffer(body))
+		r.Header.Set("Content-Type", "application/json")
+		r.Header.Set(HeaderUserID, "abc-123")
+		router.ServeHTTP(w, r)
+		return w
+	}
+
+	t.Run("success", func(t *testing.T) {
+		mockSvc := new(MockUserService)
+		mockSvc.On("ChangePassword", mock.Anything, "abc-123", req).Return(nil)
+
+		w := send(setupTestRouter(NewUserHandler(mockSvc, "test-secret")))
+
+		assert.Equal(t, http.StatusOK, w.Code)
+		mockSvc.AssertExpectations(t)
+	})
+
+	t.Run("wrong current password", func(t *testing.T) {
+		mockSvc := new(MockUserService)
+		mockSvc.On("ChangePassword", mock.Anything, "abc-123", req).Return(services.ErrInvalidCurrentPassword)
+
+		w := send(setupTestRouter(NewUserHandler(mockSvc, "test-secret")))
+
+		assert.Equal(t, http.StatusForbidden, w.Code)
+		mockSvc.AssertExpectations(t)
+	})
+
+	t.Run("user not found", func(t *testing.T) {
+		mockSvc := new(MockUserService)
+		mockSvc.On("ChangePassword", mock.Anything, "abc-123", req).Return(services.ErrUserNotFound)
+
+		w := send(setupTestRouter(NewUserHandler(mockSvc, "test-secret")))
+
+		assert.Equal(t, http.StatusNotFound, w.Code)
+		mockSvc.AssertExpectations(t)
+	})
+
+	t.Run("policy violation returns per-rule details", func(t *testing.T) {
+		mockSvc := new(MockUserService)
+		mockSvc.On("ChangePassword", mock.Anything, "abc-123", req).Return(&security.PolicyError{
+			Violations: []security.Violation{
+				{Rule: security.RuleUppercase, Message: "password must contain an uppercase letter"},
+				{Rule: security.RuleSymbol, Message: "password must contain a symbol"},
+			},
+		})
+
+		w := send(setupTestRouter(NewUserHandler(mockSvc, "test-secret")))
+
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+		var resp struct {
+			Error   string               `json:"error"`
+			Details []security.Violation `json:"details"`
+		}
+		json.Unmarshal(w.Body.Bytes(), &resp)
+		assert.Len(t, resp.Details, 2)
+		assert.Equal(t, security.RuleUppercase, resp.Details[0].Rule)
+		mockSvc.AssertExpectations(t)
+	})
+}
+
+func TestRegisterHandler_PolicyViolation(t *testing.T) {
+	req := models.RegisterRequest{Email: "alice@example.com", Username: "alice", Password: "alice"}
+	mockSvc := new(MockUserService)
+	mockSvc.On("Register", mock.Anything, req).Return(nil, &security.PolicyError{
+		Violations: []security.Violation{{Rule: security.RuleMinLength, Message: "password must be at least 8 characters"}},
+	})
+
+	router := setupTestRouter(NewUserHandler(mockSvc, "test-secret"))
+
+	body, _ := json.Marshal(req)
+	w := httptest.NewRecorder()
+	r, _ := http.NewRequest("POST", "/users/register", bytes.NewBuffer(body))
+	r.Header.Set("Content-Type", "application/json")
+	router.ServeHTTP(w, r)
+
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+	assert.Contains(t, w.Body.String(), security.RuleMinLength)
+	mockSvc.AssertExpectations(t)
+}
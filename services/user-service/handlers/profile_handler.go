@@ -0,0 +1,75 @@
+package handlers
+
+import (
+	"errors"
+	"mime"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"user-service/models"
+	"user-service/services"
+)
+
+// PatchUser 以 JSON Merge Patch（RFC 7396）部分更新用戶資料
+// 接受 application/merge-patch+json，也相容一般的 application/json
+func (h *UserHandler) PatchUser(c *gin.Context) {
+	id := c.Param("id")
+	if !requireSelfOrAdmin(c, id) {
+		return
+	}
+
+	mediaType, _, _ := mime.ParseMediaType(c.ContentType())
+	if mediaType != models.MergePatchContentType && mediaType != "application/json" {
+		c.JSON(http.StatusUnsupportedMediaType, gin.H{"error": "Content-Type must be " + models.MergePatchContentType})
+		return
+	}
+
+	body, err := c.GetRawData()
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	changes, err := models.ParseUserMergePatch(body)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	expectedVersion, ok := h.expectedVersion(c)
+	if !ok {
+		return
+	}
+
+	user, err := h.svc(c).PatchUser(c.Request.Context(), id, *changes, expectedVersion)
+	if err != nil {
+		if writeValidationError(c, err) {
+			return
+		}
+		switch {
+		case errors.Is(err, services.ErrUserNotFound):
+			c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		case errors.Is(err, services.ErrVersionConflict):
+			c.JSON(http.StatusPreconditionFailed, gin.H{"error": err.Error()})
+		default:
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		}
+		return
+	}
+
+	c.Header("ETag", userETag(user.Version))
+	c.JSON(http.StatusOK, user)
+}
+
+// writeValidationError 若 err 是欄位驗證錯誤，回傳 400 並逐欄列出原因
+// 有處理時回傳 true，呼叫端應直接 return
+func writeValidationError(c *gin.Context, err error) bool {
+	var validationErr *services.ValidationError
+	if !errors.As(err, &validationErr) {
+		return false
+	}
+	c.JSON(http.StatusBadRequest, gin.H{
+		"error":   "invalid user fields",
+		"details": validationErr.Fields,
+	})
+	return true
+}
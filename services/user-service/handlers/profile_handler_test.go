@@ -0,0 +1,84 @@
+package handlers
+
+import (
+	"bytes"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"user-service/models"
+	"user-service/services"
+)
+
+// sendPatch 以 abc-123 本人的身份送出 PATCH 請求，body 為原始 JSON 字串
+func sendPatch(handler *UserHandler, path, contentType, body string) *httptest.ResponseRecorder {
+	w := httptest.NewRecorder()
+	r, _ := http.NewRequest("PATCH", path, bytes.NewBufferString(body))
+	r.Header.Set("Content-Type", contentType)
+	r.Header.Set(HeaderUserID, "abc-123")
+	setupTestRouter(handler).ServeHTTP(w, r)
+	return w
+}
+
+// ===================================================================
+// PatchUser handler 測試
+// ===================================================================
+
+func TestPatchUserHandler(t *testing.T) {
+	t.Run("merge patch with null", func(t *testing.T) {
+		bio, empty := "hello", ""
+		mockSvc := new(MockUserService)
+		mockSvc.On("PatchUser", mock.Anything, "abc-123", models.UserChanges{Bio: &bio, AvatarURL: &empty}, 0).
+			Return(&models.User{ID: "abc-123", Bio: "hello", Version: 4}, nil)
+
+		w := sendPatch(NewUserHandler(mockSvc, "test-secret"), "/users/abc-123",
+			models.MergePatchContentType, `{"bio": "hello", "avatar_url": null}`)
+
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.Equal(t, `"4"`, w.Header().Get("ETag"))
+		mockSvc.AssertExpectations(t)
+	})
+
+	t.Run("read-only field", func(t *testing.T) {
+		mockSvc := new(MockUserService)
+
+		w := sendPatch(NewUserHandler(mockSvc, "test-secret"), "/users/abc-123",
+			models.MergePatchContentType, `{"email": "evil@example.com"}`)
+
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+		mockSvc.AssertNotCalled(t, "PatchUser", mock.Anything, mock.Anything, mock.Anything, mock.Anything)
+	})
+
+	t.Run("non-object body", func(t *testing.T) {
+		mockSvc := new(MockUserService)
+
+		w := sendPatch(NewUserHandler(mockSvc, "test-secret"), "/users/abc-123",
+			models.MergePatchContentType, `["bio"]`)
+
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+		mockSvc.AssertNotCalled(t, "PatchUser", mock.Anything, mock.Anything, mock.Anything, mock.Anything)
+	})
+
+	t.Run("unsupported content type", func(t *testing.T) {
+		mockSvc := new(MockUserService)
+
+		w := sendPatch(NewUserHandler(mockSvc, "test-secret"), "/users/abc-123", "text/plain", `{"bio": "x"}`)
+
+		assert.Equal(t, http.StatusUnsupportedMediaType, w.Code)
+	})
+
+	t.Run("validation errors", func(t *testing.T) {
+		tz := "Mars/Olympus"
+		mockSvc := new(MockUserService)
+		mockSvc.On("PatchUser", mock.Anything, "abc-123", models.UserChanges{Timezone: &tz}, 0).
+			Return(nil, &services.ValidationError{Fields: []services.FieldError{{Field: "timezone", Message: "bad"}}})
+
+		w := sendPatch(NewUserHandler(mockSvc, "test-secret"), "/users/abc-123",
+			"application/json", `{"timezone": "Mars/Olympus"}`)
+
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+		assert.Contains(t, w.Body.String(), `"field":"timezone"`)
+	})
+}
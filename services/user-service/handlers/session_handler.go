@@ -0,0 +1,57 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"user-service/services"
+)
+
+// ListMySessions 列出目前登入用戶的登入紀錄，並標示發出這次請求的 session
+func (h *UserHandler) ListMySessions(c *gin.Context) {
+	identity, ok := currentIdentity(c)
+	if !ok {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "missing caller identity"})
+		return
+	}
+
+	sessions, err := h.svc(c).ListSessions(c.Request.Context(), identity.UserID)
+	if err != nil {
+		c.JSON(sessionErrorStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+
+	for i := range sessions {
+		sessions[i].Current = identity.SessionID != "" && sessions[i].ID == identity.SessionID
+	}
+	c.JSON(http.StatusOK, gin.H{"sessions": sessions})
+}
+
+// RevokeMySession 撤銷目前登入用戶的一個 session，撤銷自己這個 session 等同登出
+func (h *UserHandler) RevokeMySession(c *gin.Context) {
+	identity, ok := currentIdentity(c)
+	if !ok {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "missing caller identity"})
+		return
+	}
+
+	if err := h.svc(c).RevokeSession(c.Request.Context(), identity.UserID, c.Param("id")); err != nil {
+		c.JSON(sessionErrorStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Session revoked"})
+}
+
+// sessionErrorStatus 將 session 相關錯誤對應到 HTTP status
+func sessionErrorStatus(err error) int {
+	switch {
+	case errors.Is(err, services.ErrSessionNotFound):
+		return http.StatusNotFound
+	case errors.Is(err, services.ErrSessionsDisabled):
+		return http.StatusNotImplemented
+	default:
+		return http.StatusInternalServerError
+	}
+}
@@ -0,0 +1,94 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"user-service/models"
+	"user-service/services"
+)
+
+// setIdentity 模擬 API Gateway 注入的身份 header
+func setIdentity(r *http.Request, userID, sessionID string) {
+	r.Header.Set(HeaderUserID, userID)
+	r.Header.Set(HeaderSessionID, sessionID)
+}
+
+// ===================================================================
+// ListMySessions handler 測試
+// ===================================================================
+
+func TestListMySessionsHandler(t *testing.T) {
+	t.Run("marks current session", func(t *testing.T) {
+		mockSvc := new(MockUserService)
+		mockSvc.On("ListSessions", mock.Anything, "uuid-001").Return([]models.Session{
+			{ID: "session-2", Active: true},
+			{ID: "session-1", Active: true},
+		}, nil)
+
+		router := setupTestRouter(NewUserHandler(mockSvc, "test-secret"))
+		w := httptest.NewRecorder()
+		r, _ := http.NewRequest("GET", "/users/me/sessions", nil)
+		setIdentity(r, "uuid-001", "session-1")
+		router.ServeHTTP(w, r)
+
+		assert.Equal(t, http.StatusOK, w.Code)
+		var resp struct {
+			Sessions []models.Session `json:"sessions"`
+		}
+		json.Unmarshal(w.Body.Bytes(), &resp)
+		assert.False(t, resp.Sessions[0].Current)
+		assert.True(t, resp.Sessions[1].Current)
+		mockSvc.AssertExpectations(t)
+	})
+
+	t.Run("missing identity", func(t *testing.T) {
+		mockSvc := new(MockUserService)
+
+		router := setupTestRouter(NewUserHandler(mockSvc, "test-secret"))
+		w := httptest.NewRecorder()
+		r, _ := http.NewRequest("GET", "/users/me/sessions", nil)
+		router.ServeHTTP(w, r)
+
+		assert.Equal(t, http.StatusUnauthorized, w.Code)
+		mockSvc.AssertNotCalled(t, "ListSessions", mock.Anything, "uuid-001")
+	})
+}
+
+// ===================================================================
+// RevokeMySession handler 測試
+// ===================================================================
+
+func TestRevokeMySessionHandler(t *testing.T) {
+	t.Run("success", func(t *testing.T) {
+		mockSvc := new(MockUserService)
+		mockSvc.On("RevokeSession", mock.Anything, "uuid-001", "session-2").Return(nil)
+
+		router := setupTestRouter(NewUserHandler(mockSvc, "test-secret"))
+		w := httptest.NewRecorder()
+		r, _ := http.NewRequest("DELETE", "/users/me/sessions/session-2", nil)
+		setIdentity(r, "uuid-001", "session-1")
+		router.ServeHTTP(w, r)
+
+		assert.Equal(t, http.StatusOK, w.Code)
+		mockSvc.AssertExpectations(t)
+	})
+
+	t.Run("session of another user", func(t *testing.T) {
+		mockSvc := new(MockUserService)
+		mockSvc.On("RevokeSession", mock.Anything, "uuid-001", "someone-elses").Return(services.ErrSessionNotFound)
+
+		router := setupTestRouter(NewUserHandler(mockSvc, "test-secret"))
+		w := httptest.NewRecorder()
+		r, _ := http.NewRequest("DELETE", "/users/me/sessions/someone-elses", nil)
+		setIdentity(r, "uuid-001", "session-1")
+		router.ServeHTTP(w, r)
+
+		assert.Equal(t, http.StatusNotFound, w.Code)
+		mockSvc.AssertExpectations(t)
+	})
+}
@@ -1,31 +1,64 @@
 package handlers
 
 import (
+	"errors"
 	"net/http"
 	"time"
 
 	"github.com/gin-gonic/gin"
-	"github.com/golang-jwt/jwt/v5"
+	"user-service/auth"
 	"user-service/models"
 	"user-service/services"
 )
 
+// TokenTTL JWT 的有效期限，撤銷紀錄的保存時間也以此為準
+const TokenTTL = auth.TokenTTL
+
 // Claims 定義 JWT payload 的內容
-type Claims struct {
-	UserID string `json:"user_id"`
-	Email  string `json:"email"`
-	jwt.RegisteredClaims
-}
+type Claims = auth.Claims
 
 // UserHandler 用戶 HTTP 處理層
 type UserHandler struct {
-	service   services.UserServiceInterface
-	jwtSecret string
+	service        services.UserServiceInterface
+	jwtSecret      string
+	requireIfMatch bool
+	now            func() time.Time
+	tokens         *auth.Issuer
+	ready          func() bool
+}
+
+// HandlerOption 用來調整 UserHandler 的選用設定
+type HandlerOption func(*UserHandler)
+
+// WithHandlerClock 替換簽發與驗證 token 時使用的現在時間，測試時可注入固定時間
+func WithHandlerClock(now func() time.Time) HandlerOption {
+	return func(h *UserHandler) {
+		h.now = now
+	}
+}
+
+// WithRequireIfMatch 要求 PUT / DELETE 必須帶 If-Match header，沒帶時回 428
+func WithRequireIfMatch() HandlerOption {
+	return func(h *UserHandler) {
+		h.requireIfMatch = true
+	}
+}
+
+// WithReadiness 讓健康檢查在 ready 回傳 false 時（例如服務正在關閉）回報 503
+func WithReadiness(ready func() bool) HandlerOption {
+	return func(h *UserHandler) {
+		h.ready = ready
+	}
 }
 
 // NewUserHandler 創建用戶 Handler
-func NewUserHandler(service services.UserServiceInterface, jwtSecret string) *UserHandler {
-	return &UserHandler{service: service, jwtSecret: jwtSecret}
+func NewUserHandler(service services.UserServiceInterface, jwtSecret string, opts ...HandlerOption) *UserHandler {
+	h := &UserHandler{service: service, jwtSecret: jwtSecret, now: time.Now}
+	for _, opt := range opts {
+		opt(h)
+	}
+	h.tokens = auth.NewIssuer(jwtSecret, h.now)
+	return h
 }
 
 // Register 註冊處理
@@ -36,8 +69,15 @@ func (h *UserHandler) Register(c *gin.Context) {
 		return
 	}
 
-	user, err := h.service.Register(req)
+	user, err := h.svc(c).Register(c.Request.Context(), req)
 	if err != nil {
+		if writePolicyError(c, err) {
+			return
+		}
+		if errors.Is(err, services.ErrEmailTaken) {
+			c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+			return
+		}
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
 	}
@@ -46,6 +86,7 @@ func (h *UserHandler) Register(c *gin.Context) {
 }
 
 // Login 登入處理：驗證帳密，成功後簽發 JWT token
+// 已啟用 MFA 的用戶改為回傳 MFA challenge token，需再呼叫 LoginMFA 才會拿到 access token
 func (h *UserHandler) Login(c *gin.Context) {
 	var req models.LoginRequest
 	if err := c.ShouldBindJSON(&req); err != nil {
@@ -53,25 +94,37 @@ func (h *UserHandler) Login(c *gin.Context) {
 		return
 	}
 
-	user, err := h.service.Login(req)
+	user, err := h.svc(c).Login(c.Request.Context(), req)
 	if err != nil {
 		c.JSON(http.StatusUnauthorized, gin.H{"error": err.Error()})
 		return
 	}
 
-	// ── 產生 JWT token ────────────────────────────────────────────────────
-	claims := &Claims{
-		UserID: user.ID,
-		Email:  user.Email,
-		RegisteredClaims: jwt.RegisteredClaims{
-			ExpiresAt: jwt.NewNumericDate(time.Now().Add(24 * time.Hour)), // 24 小時後過期
-			IssuedAt:  jwt.NewNumericDate(time.Now()),
-		},
+	if user.MFAEnabled {
+		mfaToken, err := h.tokens.IssueMFAChallenge(user.ID)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "產生 token 失敗"})
+			return
+		}
+		c.JSON(http.StatusOK, models.MFAChallengeResponse{
+			Message:     "MFA required",
+			MFARequired: true,
+			MFAToken:    mfaToken,
+		})
+		return
 	}
 
-	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
-	tokenString, err := token.SignedString([]byte(h.jwtSecret))
+	h.respondWithAccessToken(c, user)
+}
+
+// respondWithAccessToken 簽發 access token 並回傳登入成功的響應
+func (h *UserHandler) respondWithAccessToken(c *gin.Context, user *models.User) {
+	tokenString, err := h.tokens.IssueAccessToken(c.Request.Context(), h.svc(c), user)
 	if err != nil {
+		if errors.Is(err, auth.ErrSessionStart) {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "建立 session 失敗"})
+			return
+		}
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "產生 token 失敗"})
 		return
 	}
@@ -85,7 +138,7 @@ func (h *UserHandler) Login(c *gin.Context) {
 
 // GetUsers 獲取用戶列表
 func (h *UserHandler) GetUsers(c *gin.Context) {
-	users, err := h.service.GetUsers()
+	users, err := h.svc(c).GetUsers(c.Request.Context())
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
@@ -97,25 +150,41 @@ func (h *UserHandler) GetUsers(c *gin.Context) {
 // GetUser 獲取單個用戶
 func (h *UserHandler) GetUser(c *gin.Context) {
 	id := c.Param("id")
-	user, err := h.service.GetUserByID(id)
+	user, err := h.svc(c).GetUserByID(c.Request.Context(), id)
 	if err != nil {
 		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
 		return
 	}
 
+	c.Header("ETag", userETag(user.Version))
 	c.JSON(http.StatusOK, user)
 }
 
 // UpdateUser 更新用戶
 func (h *UserHandler) UpdateUser(c *gin.Context) {
 	id := c.Param("id")
+	if !requireSelfOrAdmin(c, id) {
+		return
+	}
 	var req models.UpdateUserRequest
 	if err := c.ShouldBindJSON(&req); err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 		return
 	}
 
-	if err := h.service.UpdateUser(id, req); err != nil {
+	expectedVersion, ok := h.expectedVersion(c)
+	if !ok {
+		return
+	}
+
+	if err := h.svc(c).UpdateUser(c.Request.Context(), id, req, expectedVersion); err != nil {
+		if writeValidationError(c, err) {
+			return
+		}
+		if errors.Is(err, services.ErrVersionConflict) {
+			c.JSON(http.StatusPreconditionFailed, gin.H{"error": err.Error()})
+			return
+		}
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
 	}
@@ -126,7 +195,19 @@ func (h *UserHandler) UpdateUser(c *gin.Context) {
 // DeleteUser 刪除用戶
 func (h *UserHandler) DeleteUser(c *gin.Context) {
 	id := c.Param("id")
-	if err := h.service.DeleteUser(id); err != nil {
+	if !requireSelfOrAdmin(c, id) {
+		return
+	}
+	expectedVersion, ok := h.expectedVersion(c)
+	if !ok {
+		return
+	}
+
+	if err := h.svc(c).DeleteUser(c.Request.Context(), id, expectedVersion); err != nil {
+		if errors.Is(err, services.ErrVersionConflict) {
+			c.JSON(http.StatusPreconditionFailed, gin.H{"error": err.Error()})
+			return
+		}
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
 	}
@@ -134,8 +215,30 @@ func (h *UserHandler) DeleteUser(c *gin.Context) {
 	c.JSON(http.StatusOK, gin.H{"message": "User deleted successfully"})
 }
 
-// Health 健康檢查
+// RestoreUser 還原被軟刪除的用戶（管理員專用，權限由 API Gateway 檢查）
+func (h *UserHandler) RestoreUser(c *gin.Context) {
+	id := c.Param("id")
+	if err := h.svc(c).RestoreUser(c.Request.Context(), id); err != nil {
+		if errors.Is(err, services.ErrUserNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "User restored successfully"})
+}
+
+// Health 健康檢查，服務開始關閉後回 503，讓流量先轉移到其他實例
 func (h *UserHandler) Health(c *gin.Context) {
+	if h.ready != nil && !h.ready() {
+		c.JSON(http.StatusServiceUnavailable, gin.H{
+			"status":  "shutting down",
+			"service": "user-service",
+		})
+		return
+	}
 	c.JSON(http.StatusOK, gin.H{
 		"status":  "healthy",
 		"service": "user-service",
@@ -2,16 +2,22 @@ package handlers
 
 import (
 	"bytes"
+	"context"
 	"encoding/json"
 	"fmt"
 	"net/http"
 	"net/http/httptest"
 	"testing"
+	"time"
 
 	"github.com/gin-gonic/gin"
+	"github.com/golang-jwt/jwt/v5"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+	"user-service/middleware"
 	"user-service/models"
+	"user-service/services"
 )
 
 // -------------------------------------------------------------------
@@ -21,44 +27,147 @@ import (
 
 type MockUserService struct {
 	mock.Mock
+	meta models.RequestMeta // 最後一次 ForRequest 收到的來源資訊
 }
 
-func (m *MockUserService) Register(req models.RegisterRequest) (*models.User, error) {
-	args := m.Called(req)
+func (m *MockUserService) Register(ctx context.Context, req models.RegisterRequest) (*models.User, error) {
+	args := m.Called(ctx, req)
 	if args.Get(0) == nil {
 		return nil, args.Error(1)
 	}
 	return args.Get(0).(*models.User), args.Error(1)
 }
 
-func (m *MockUserService) Login(req models.LoginRequest) (*models.User, error) {
-	args := m.Called(req)
+func (m *MockUserService) Login(ctx context.Context, req models.LoginRequest) (*models.User, error) {
+	args := m.Called(ctx, req)
 	if args.Get(0) == nil {
 		return nil, args.Error(1)
 	}
 	return args.Get(0).(*models.User), args.Error(1)
 }
 
-func (m *MockUserService) GetUsers() ([]models.User, error) {
-	args := m.Called()
+func (m *MockUserService) GetUsers(ctx context.Context) ([]models.User, error) {
+	args := m.Called(ctx)
 	return args.Get(0).([]models.User), args.Error(1)
 }
 
-func (m *MockUserService) GetUserByID(id string) (*models.User, error) {
-	args := m.Called(id)
+func (m *MockUserService) GetUserByID(ctx context.Context, id string) (*models.User, error) {
+	args := m.Called(ctx, id)
 	if args.Get(0) == nil {
 		return nil, args.Error(1)
 	}
 	return args.Get(0).(*models.User), args.Error(1)
 }
 
-func (m *MockUserService) UpdateUser(id string, req models.UpdateUserRequest) error {
-	args := m.Called(id, req)
+func (m *MockUserService) UpdateUser(ctx context.Context, id string, req models.UpdateUserRequest, expectedVersion int) error {
+	args := m.Called(ctx, id, req, expectedVersion)
 	return args.Error(0)
 }
 
-func (m *MockUserService) DeleteUser(id string) error {
-	args := m.Called(id)
+func (m *MockUserService) PatchUser(ctx context.Context, id string, changes models.UserChanges, expectedVersion int) (*models.User, error) {
+	args := m.Called(ctx, id, changes, expectedVersion)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*models.User), args.Error(1)
+}
+
+func (m *MockUserService) DeleteUser(ctx context.Context, id string, expectedVersion int) error {
+	args := m.Called(ctx, id, expectedVersion)
+	return args.Error(0)
+}
+
+func (m *MockUserService) RestoreUser(ctx context.Context, id string) error {
+	args := m.Called(ctx, id)
+	return args.Error(0)
+}
+
+func (m *MockUserService) StartSession(ctx context.Context, userID string, expiresAt time.Time) (*models.Session, error) {
+	args := m.Called(ctx, userID, expiresAt)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*models.Session), args.Error(1)
+}
+
+func (m *MockUserService) ListSessions(ctx context.Context, userID string) ([]models.Session, error) {
+	args := m.Called(ctx, userID)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).([]models.Session), args.Error(1)
+}
+
+func (m *MockUserService) RevokeSession(ctx context.Context, userID, sessionID string) error {
+	args := m.Called(ctx, userID, sessionID)
+	return args.Error(0)
+}
+
+func (m *MockUserService) ListAuditEvents(ctx context.Context, filter models.AuditFilter) (*models.AuditEventPage, error) {
+	args := m.Called(ctx, filter)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*models.AuditEventPage), args.Error(1)
+}
+
+// ForRequest 不記錄成 mock 呼叫，每個 handler 都會經過這裡
+func (m *MockUserService) ForRequest(meta models.RequestMeta) services.UserServiceInterface {
+	m.meta = meta
+	return m
+}
+
+func (m *MockUserService) ForgotPassword(ctx context.Context, req models.ForgotPasswordRequest) error {
+	args := m.Called(ctx, req)
+	return args.Error(0)
+}
+
+func (m *MockUserService) ResetPassword(ctx context.Context, req models.ResetPasswordRequest) error {
+	args := m.Called(ctx, req)
+	return args.Error(0)
+}
+
+func (m *MockUserService) ChangePassword(ctx context.Context, id string, req models.ChangePasswordRequest) error {
+	args := m.Called(ctx, id, req)
+	return args.Error(0)
+}
+
+func (m *MockUserService) RequestEmailChange(ctx context.Context, userID string, req models.EmailChangeRequest) error {
+	args := m.Called(ctx, userID, req)
+	return args.Error(0)
+}
+
+func (m *MockUserService) ConfirmEmailChange(ctx context.Context, req models.ConfirmEmailChangeRequest) error {
+	args := m.Called(ctx, req)
+	return args.Error(0)
+}
+
+func (m *MockUserService) EnrollTOTP(ctx context.Context, userID string) (*models.TOTPEnrollment, error) {
+	args := m.Called(ctx, userID)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*models.TOTPEnrollment), args.Error(1)
+}
+
+func (m *MockUserService) ConfirmTOTP(ctx context.Context, userID, code string) ([]string, error) {
+	args := m.Called(ctx, userID, code)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).([]string), args.Error(1)
+}
+
+func (m *MockUserService) VerifyMFA(ctx context.Context, userID, code string) (*models.User, error) {
+	args := m.Called(ctx, userID, code)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*models.User), args.Error(1)
+}
+
+func (m *MockUserService) DisableMFA(ctx context.Context, userID, code string) error {
+	args := m.Called(ctx, userID, code)
 	return args.Error(0)
 }
 
@@ -71,10 +180,27 @@ func setupTestRouter(handler *UserHandler) *gin.Engine {
 	r := gin.New()
 	r.POST("/users/register", handler.Register)
 	r.POST("/users/login", handler.Login)
+	r.POST("/users/login/mfa", handler.LoginMFA)
+	r.POST("/users/password/forgot", handler.ForgotPassword)
+	r.POST("/users/password/reset", handler.ResetPassword)
+	r.POST("/users/email/confirm", handler.ConfirmEmailChange)
 	r.GET("/users", handler.GetUsers)
+	r.GET("/users/me", ForCurrentUser(handler.GetUser))
+	r.PATCH("/users/me", ForCurrentUser(handler.PatchUser))
+	r.DELETE("/users/me", ForCurrentUser(handler.DeleteUser))
+	r.GET("/users/me/sessions", handler.ListMySessions)
+	r.DELETE("/users/me/sessions/:id", handler.RevokeMySession)
 	r.GET("/users/:id", handler.GetUser)
 	r.PUT("/users/:id", handler.UpdateUser)
+	r.PATCH("/users/:id", handler.PatchUser)
+	r.PUT("/users/:id/password", handler.ChangePassword)
+	r.POST("/users/:id/email", handler.RequestEmailChange)
+	r.POST("/users/:id/mfa/totp", handler.EnrollTOTP)
+	r.POST("/users/:id/mfa/totp/confirm", handler.ConfirmTOTP)
+	r.DELETE("/users/:id/mfa/totp", handler.DisableMFA)
 	r.DELETE("/users/:id", handler.DeleteUser)
+	r.POST("/admin/users/:id/restore", handler.RestoreUser)
+	r.GET("/admin/audit-events", handler.ListAuditEvents)
 	r.GET("/health", handler.Health)
 	return r
 }
@@ -86,7 +212,7 @@ func setupTestRouter(handler *UserHandler) *gin.Engine {
 func TestRegisterHandler(t *testing.T) {
 	t.Run("success", func(t *testing.T) {
 		mockSvc := new(MockUserService)
-		mockSvc.On("Register", models.RegisterRequest{
+		mockSvc.On("Register", mock.Anything, models.RegisterRequest{
 			Email:    "test@example.com",
 			Username: "testuser",
 			Password: "password123",
@@ -129,11 +255,11 @@ func TestRegisterHandler(t *testing.T) {
 
 	t.Run("service error - email exists", func(t *testing.T) {
 		mockSvc := new(MockUserService)
-		mockSvc.On("Register", models.RegisterRequest{
+		mockSvc.On("Register", mock.Anything, models.RegisterRequest{
 			Email:    "exist@example.com",
 			Username: "someone",
 			Password: "password123",
-		}).Return(nil, fmt.Errorf("email already exists"))
+		}).Return(nil, services.ErrEmailTaken)
 
 		router := setupTestRouter(NewUserHandler(mockSvc, "test-secret"))
 
@@ -147,6 +273,30 @@ func TestRegisterHandler(t *testing.T) {
 		r.Header.Set("Content-Type", "application/json")
 		router.ServeHTTP(w, r)
 
+		assert.Equal(t, http.StatusConflict, w.Code)
+		mockSvc.AssertExpectations(t)
+	})
+
+	t.Run("service error - db failure", func(t *testing.T) {
+		mockSvc := new(MockUserService)
+		mockSvc.On("Register", mock.Anything, models.RegisterRequest{
+			Email:    "new@example.com",
+			Username: "someone",
+			Password: "password123",
+		}).Return(nil, fmt.Errorf("db connection failed"))
+
+		router := setupTestRouter(NewUserHandler(mockSvc, "test-secret"))
+
+		body, _ := json.Marshal(models.RegisterRequest{
+			Email:    "new@example.com",
+			Username: "someone",
+			Password: "password123",
+		})
+		w := httptest.NewRecorder()
+		r, _ := http.NewRequest("POST", "/users/register", bytes.NewBuffer(body))
+		r.Header.Set("Content-Type", "application/json")
+		router.ServeHTTP(w, r)
+
 		assert.Equal(t, http.StatusInternalServerError, w.Code)
 		mockSvc.AssertExpectations(t)
 	})
@@ -159,10 +309,12 @@ func TestRegisterHandler(t *testing.T) {
 func TestLoginHandler(t *testing.T) {
 	t.Run("success", func(t *testing.T) {
 		mockSvc := new(MockUserService)
-		mockSvc.On("Login", models.LoginRequest{
+		mockSvc.On("Login", mock.Anything, models.LoginRequest{
 			Email:    "user@example.com",
 			Password: "password123",
 		}).Return(&models.User{ID: "uuid-001", Email: "user@example.com"}, nil)
+		mockSvc.On("StartSession", mock.Anything, "uuid-001", mock.AnythingOfType("time.Time")).
+			Return(&models.Session{ID: "session-1"}, nil)
 
 		router := setupTestRouter(NewUserHandler(mockSvc, "test-secret"))
 
@@ -179,6 +331,14 @@ func TestLoginHandler(t *testing.T) {
 		var resp models.LoginResponse
 		json.Unmarshal(w.Body.Bytes(), &resp)
 		assert.Equal(t, "Login successful", resp.Message)
+
+		// session ID 寫進 token，gateway 才能拒絕被撤銷的 session
+		claims := &Claims{}
+		_, err := jwt.ParseWithClaims(resp.Token, claims, func(*jwt.Token) (interface{}, error) {
+			return []byte("test-secret"), nil
+		})
+		assert.NoError(t, err)
+		assert.Equal(t, "session-1", claims.SessionID)
 		mockSvc.AssertExpectations(t)
 	})
 
@@ -199,7 +359,7 @@ func TestLoginHandler(t *testing.T) {
 
 	t.Run("invalid credentials", func(t *testing.T) {
 		mockSvc := new(MockUserService)
-		mockSvc.On("Login", models.LoginRequest{
+		mockSvc.On("Login", mock.Anything, models.LoginRequest{
 			Email:    "user@example.com",
 			Password: "wrongpass",
 		}).Return(nil, fmt.Errorf("invalid credentials"))
@@ -227,7 +387,7 @@ func TestLoginHandler(t *testing.T) {
 func TestGetUserHandler(t *testing.T) {
 	t.Run("success", func(t *testing.T) {
 		mockSvc := new(MockUserService)
-		mockSvc.On("GetUserByID", "abc-123").Return(&models.User{ID: "abc-123", Email: "u@example.com"}, nil)
+		mockSvc.On("GetUserByID", mock.Anything, "abc-123").Return(&models.User{ID: "abc-123", Email: "u@example.com", Version: 3}, nil)
 
 		router := setupTestRouter(NewUserHandler(mockSvc, "test-secret"))
 
@@ -236,6 +396,7 @@ func TestGetUserHandler(t *testing.T) {
 		router.ServeHTTP(w, r)
 
 		assert.Equal(t, http.StatusOK, w.Code)
+		assert.Equal(t, `"3"`, w.Header().Get("ETag"))
 		var resp models.User
 		json.Unmarshal(w.Body.Bytes(), &resp)
 		assert.Equal(t, "abc-123", resp.ID)
@@ -244,7 +405,7 @@ func TestGetUserHandler(t *testing.T) {
 
 	t.Run("not found", func(t *testing.T) {
 		mockSvc := new(MockUserService)
-		mockSvc.On("GetUserByID", "no-such-id").Return(nil, fmt.Errorf("user not found"))
+		mockSvc.On("GetUserByID", mock.Anything, "no-such-id").Return(nil, fmt.Errorf("user not found"))
 
 		router := setupTestRouter(NewUserHandler(mockSvc, "test-secret"))
 
@@ -255,6 +416,29 @@ func TestGetUserHandler(t *testing.T) {
 		assert.Equal(t, http.StatusNotFound, w.Code)
 		mockSvc.AssertExpectations(t)
 	})
+
+	t.Run("service runs under the gateway deadline", func(t *testing.T) {
+		var got context.Context
+		mockSvc := new(MockUserService)
+		mockSvc.On("GetUserByID", mock.Anything, "abc-123").
+			Run(func(args mock.Arguments) { got = args.Get(0).(context.Context) }).
+			Return(&models.User{ID: "abc-123"}, nil)
+
+		gin.SetMode(gin.TestMode)
+		router := gin.New()
+		router.GET("/users/:id", middleware.RequestDeadline(), NewUserHandler(mockSvc, "test-secret").GetUser)
+
+		w := httptest.NewRecorder()
+		r, _ := http.NewRequest("GET", "/users/abc-123", nil)
+		r.Header.Set(middleware.RequestTimeoutHeader, "5000")
+		router.ServeHTTP(w, r)
+
+		assert.Equal(t, http.StatusOK, w.Code)
+		deadline, ok := got.Deadline()
+		require.True(t, ok, "service must receive the request context")
+		assert.WithinDuration(t, time.Now().Add(5*time.Second), deadline, time.Second)
+		mockSvc.AssertExpectations(t)
+	})
 }
 
 // ===================================================================
@@ -264,12 +448,13 @@ func TestGetUserHandler(t *testing.T) {
 func TestDeleteUserHandler(t *testing.T) {
 	t.Run("success", func(t *testing.T) {
 		mockSvc := new(MockUserService)
-		mockSvc.On("DeleteUser", "abc-123").Return(nil)
+		mockSvc.On("DeleteUser", mock.Anything, "abc-123", 0).Return(nil)
 
 		router := setupTestRouter(NewUserHandler(mockSvc, "test-secret"))
 
 		w := httptest.NewRecorder()
 		r, _ := http.NewRequest("DELETE", "/users/abc-123", nil)
+		r.Header.Set(HeaderUserID, "abc-123")
 		router.ServeHTTP(w, r)
 
 		assert.Equal(t, http.StatusOK, w.Code)
@@ -278,12 +463,13 @@ func TestDeleteUserHandler(t *testing.T) {
 
 	t.Run("not found", func(t *testing.T) {
 		mockSvc := new(MockUserService)
-		mockSvc.On("DeleteUser", "ghost-id").Return(fmt.Errorf("user not found"))
+		mockSvc.On("DeleteUser", mock.Anything, "ghost-id", 0).Return(fmt.Errorf("user not found"))
 
 		router := setupTestRouter(NewUserHandler(mockSvc, "test-secret"))
 
 		w := httptest.NewRecorder()
 		r, _ := http.NewRequest("DELETE", "/users/ghost-id", nil)
+		r.Header.Set(HeaderUserID, "ghost-id")
 		router.ServeHTTP(w, r)
 
 		assert.Equal(t, http.StatusInternalServerError, w.Code)
@@ -291,9 +477,42 @@ func TestDeleteUserHandler(t *testing.T) {
 	})
 }
 
+// ===================================================================
+// RestoreUser handler 測試
+// ===================================================================
+
+func TestRestoreUserHandler(t *testing.T) {
+	t.Run("success", func(t *testing.T) {
+		mockSvc := new(MockUserService)
+		mockSvc.On("RestoreUser", mock.Anything, "abc-123").Return(nil)
+
+		router := setupTestRouter(NewUserHandler(mockSvc, "test-secret"))
+
+		w := httptest.NewRecorder()
+		r, _ := http.NewRequest("POST", "/admin/users/abc-123/restore", nil)
+		router.ServeHTTP(w, r)
+
+		assert.Equal(t, http.StatusOK, w.Code)
+		mockSvc.AssertExpectations(t)
+	})
+
+	t.Run("not deleted or already purged", func(t *testing.T) {
+		mockSvc := new(MockUserService)
+		mockSvc.On("RestoreUser", mock.Anything, "ghost-id").Return(services.ErrUserNotFound)
+
+		router := setupTestRouter(NewUserHandler(mockSvc, "test-secret"))
+
+		w := httptest.NewRecorder()
+		r, _ := http.NewRequest("POST", "/admin/users/ghost-id/restore", nil)
+		router.ServeHTTP(w, r)
+
+		assert.Equal(t, http.StatusNotFound, w.Code)
+		mockSvc.AssertExpectations(t)
+	})
+}
+
 // ===================================================================
 // Health handler 測試
-// Health 只有一個情境，不用 t.Run
 // ===================================================================
 
 func TestHealthHandler(t *testing.T) {
@@ -311,3 +530,18 @@ func TestHealthHandler(t *testing.T) {
 	assert.Equal(t, "healthy", response["status"])
 	assert.Equal(t, "user-service", response["service"])
 }
+
+func TestHealthHandler_ShuttingDown(t *testing.T) {
+	mockSvc := new(MockUserService)
+	router := setupTestRouter(NewUserHandler(mockSvc, "test-secret", WithReadiness(func() bool { return false })))
+
+	w := httptest.NewRecorder()
+	r, _ := http.NewRequest("GET", "/health", nil)
+	router.ServeHTTP(w, r)
+
+	assert.Equal(t, http.StatusServiceUnavailable, w.Code)
+
+	var response map[string]string
+	json.Unmarshal(w.Body.Bytes(), &response)
+	assert.Equal(t, "shutting down", response["status"])
+}
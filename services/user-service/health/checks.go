@@ -0,0 +1,39 @@
+package health
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+
+	"github.com/redis/go-redis/v9"
+	"user-service/database"
+)
+
+// Postgres 確認資料庫連線可用
+func Postgres(db *sql.DB) CheckFunc {
+	return func(ctx context.Context) error {
+		return db.PingContext(ctx)
+	}
+}
+
+// Redis 確認 Redis 連線可用（token 撤銷、service registry 都依賴它）
+func Redis(client *redis.Client) CheckFunc {
+	return func(ctx context.Context) error {
+		return client.Ping(ctx).Err()
+	}
+}
+
+// SchemaVersion 確認資料庫的 schema 至少是程式碼預期的版本，
+// 避免新版程式接到尚未 migrate 的資料庫時開始接收流量
+func SchemaVersion(db *sql.DB, want int) CheckFunc {
+	return func(ctx context.Context) error {
+		got, err := database.CurrentSchemaVersion(ctx, db)
+		if err != nil {
+			return err
+		}
+		if got < want {
+			return fmt.Errorf("schema version %d is older than required %d", got, want)
+		}
+		return nil
+	}
+}
@@ -0,0 +1,146 @@
+package health
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// healthTestNow 測試用的固定時間
+var healthTestNow = time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+
+func setupHealthRouter(h *Health) *gin.Engine {
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	r.GET("/livez", h.Livez)
+	r.GET("/readyz", h.Readyz)
+	return r
+}
+
+func get(router http.Handler, path string) (*httptest.ResponseRecorder, map[string]interface{}) {
+	w := httptest.NewRecorder()
+	r, _ := http.NewRequest("GET", path, nil)
+	router.ServeHTTP(w, r)
+	var body map[string]interface{}
+	json.Unmarshal(w.Body.Bytes(), &body)
+	return w, body
+}
+
+func ok(context.Context) error { return nil }
+
+func TestLivez(t *testing.T) {
+	h := New(nil)
+	h.AddCheck("postgres", 0, func(context.Context) error { return errors.New("connection refused") })
+
+	// 相依服務故障不影響 liveness
+	w, body := get(setupHealthRouter(h), "/livez")
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, StatusUp, body["status"])
+}
+
+func TestReadyz(t *testing.T) {
+	t.Run("all checks pass", func(t *testing.T) {
+		h := New(nil)
+		h.AddCheck("postgres", 0, ok)
+		h.AddCheck("redis", 0, ok)
+
+		w, body := get(setupHealthRouter(h), "/readyz")
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.Equal(t, StatusUp, body["status"])
+		// 沒開啟 details 時不回傳各項檢查
+		assert.NotContains(t, body, "checks")
+	})
+
+	t.Run("failed check returns 503 with its name only", func(t *testing.T) {
+		h := New(nil)
+		h.AddCheck("postgres", 0, ok)
+		h.AddCheck("redis", 0, func(context.Context) error { return errors.New("dial tcp redis:6379: connection refused") })
+
+		w, body := get(setupHealthRouter(h), "/readyz")
+		assert.Equal(t, http.StatusServiceUnavailable, w.Code)
+		assert.Equal(t, StatusDown, body["status"])
+		assert.Equal(t, []interface{}{"redis"}, body["failed"])
+		assert.NotContains(t, w.Body.String(), "redis:6379")
+	})
+
+	t.Run("details include per-check results", func(t *testing.T) {
+		h := New(nil, WithDetails(true))
+		h.AddCheck("postgres", 0, ok)
+		h.AddCheck("redis", 0, func(context.Context) error { return errors.New("connection refused") })
+
+		w := httptest.NewRecorder()
+		r, _ := http.NewRequest("GET", "/readyz", nil)
+		setupHealthRouter(h).ServeHTTP(w, r)
+
+		var report Report
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), &report))
+		assert.Equal(t, StatusDown, report.Status)
+		assert.Equal(t, StatusUp, report.Checks["postgres"].Status)
+		assert.Equal(t, "connection refused", report.Checks["redis"].Error)
+	})
+
+	t.Run("shutting down skips checks", func(t *testing.T) {
+		var called atomic.Bool
+		h := New(func() bool { return false })
+		h.AddCheck("postgres", 0, func(context.Context) error {
+			called.Store(true)
+			return nil
+		})
+
+		w, body := get(setupHealthRouter(h), "/readyz")
+		assert.Equal(t, http.StatusServiceUnavailable, w.Code)
+		assert.Equal(t, "shutting down", body["status"])
+		assert.False(t, called.Load())
+	})
+}
+
+func TestCheck(t *testing.T) {
+	t.Run("per-check timeout", func(t *testing.T) {
+		h := New(nil)
+		h.AddCheck("slow", 10*time.Millisecond, func(ctx context.Context) error {
+			<-ctx.Done()
+			return ctx.Err()
+		})
+
+		report := h.Check(context.Background())
+		assert.Equal(t, StatusDown, report.Status)
+		assert.Equal(t, context.DeadlineExceeded.Error(), report.Checks["slow"].Error)
+	})
+
+	t.Run("results are cached until ttl expires", func(t *testing.T) {
+		clock := healthTestNow
+		var calls atomic.Int32
+		h := New(nil, WithCacheTTL(5*time.Second), WithClock(func() time.Time { return clock }))
+		h.AddCheck("postgres", 0, func(context.Context) error {
+			calls.Add(1)
+			return nil
+		})
+
+		h.Check(context.Background())
+		clock = healthTestNow.Add(4 * time.Second)
+		h.Check(context.Background())
+		assert.Equal(t, int32(1), calls.Load())
+
+		clock = healthTestNow.Add(5 * time.Second)
+		h.Check(context.Background())
+		assert.Equal(t, int32(2), calls.Load())
+	})
+
+	t.Run("cancelled caller does not poison the cache", func(t *testing.T) {
+		h := New(nil)
+		h.AddCheck("postgres", 0, func(ctx context.Context) error { return ctx.Err() })
+
+		ctx, cancel := context.WithCancel(context.Background())
+		cancel()
+		assert.Equal(t, StatusUp, h.Check(ctx).Status)
+	})
+}
@@ -0,0 +1,56 @@
+package lifecycle
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestReadiness(t *testing.T) {
+	var r Readiness
+	assert.True(t, r.Ready())
+
+	r.SetDraining()
+	assert.False(t, r.Ready())
+}
+
+func TestShutdown(t *testing.T) {
+	t.Run("runs steps in order and continues after failure", func(t *testing.T) {
+		var order []string
+		var s Shutdown
+		s.Add("http", func(context.Context) error {
+			order = append(order, "http")
+			return errors.New("boom")
+		})
+		s.Add("postgres", func(context.Context) error {
+			order = append(order, "postgres")
+			return nil
+		})
+
+		err := s.Run(time.Second)
+
+		assert.Equal(t, []string{"http", "postgres"}, order)
+		assert.ErrorContains(t, err, "http: boom")
+	})
+
+	t.Run("steps share one deadline", func(t *testing.T) {
+		var s Shutdown
+		s.Add("drain", func(ctx context.Context) error {
+			return Wait(ctx, time.Hour)
+		})
+		var remaining error
+		s.Add("close", func(ctx context.Context) error {
+			remaining = ctx.Err()
+			return nil
+		})
+
+		err := s.Run(10 * time.Millisecond)
+
+		assert.ErrorIs(t, err, context.DeadlineExceeded)
+		// 前一步耗盡時間後，後面的步驟仍會執行，只是拿到已逾時的 ctx
+		assert.ErrorIs(t, remaining, context.DeadlineExceeded)
+	})
+}
@@ -0,0 +1,22 @@
+package mailer
+
+import "log"
+
+// Mailer 定義寄送信件的契約，讓 service 層不需要知道信件實際怎麼送出
+type Mailer interface {
+	Send(to, subject, body string) error
+}
+
+// LogMailer 只把信件內容寫進 log，用於本地開發或尚未串接 SMTP 的環境
+type LogMailer struct{}
+
+// NewLogMailer 創建 LogMailer
+func NewLogMailer() *LogMailer {
+	return &LogMailer{}
+}
+
+// Send 將信件內容輸出到 log
+func (m *LogMailer) Send(to, subject, body string) error {
+	log.Printf("[Mailer] to=%s | subject=%s\n%s", to, subject, body)
+	return nil
+}
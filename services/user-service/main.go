@@ -1,27 +1,57 @@
 package main
 
 import (
+	"context"
+	"errors"
 	"log"
+	"log/slog"
+	"net"
+	"net/http"
+	"os"
+	"os/signal"
+	"sync"
+	"syscall"
+	"time"
 
 	"github.com/gin-gonic/gin"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+	"google.golang.org/grpc"
+	grpchealth "google.golang.org/grpc/health"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+	"google.golang.org/grpc/reflection"
+	"user-service/auth"
 	"user-service/config"
 	"user-service/database"
+	"user-service/gen/userv1"
+	"user-service/grpcserver"
 	"user-service/handlers"
+	"user-service/health"
+	"user-service/lifecycle"
+	"user-service/mailer"
+	"user-service/middleware"
+	"user-service/registry"
 	"user-service/repository"
 	"user-service/routes"
+	"user-service/security"
 	"user-service/services"
 )
 
 func main() {
-	// 載入配置
-	cfg := config.Load()
+	// 載入配置：預設值 → CONFIG_FILE → 環境變數，設定有誤時直接拒絕啟動
+	cfg, err := config.Load()
+	if err != nil {
+		log.Fatal(err)
+	}
+	for _, line := range cfg.Redacted() {
+		log.Printf("config %s", line)
+	}
 
 	// 初始化資料庫
 	db, err := database.InitPostgres(cfg.Database)
 	if err != nil {
 		log.Fatal(err)
 	}
-	defer db.Close()
 
 	// 創建資料表
 	if err := database.CreateTables(db); err != nil {
@@ -30,20 +60,230 @@ func main() {
 
 	// 初始化 Redis
 	redisClient := database.InitRedis(cfg.Redis)
-	defer redisClient.Close()
+
+	// 密碼 hash 設定
+	argon2Params := security.DefaultArgon2idParams()
+	argon2Params.Memory = uint32(cfg.PasswordHash.Argon2Memory)
+	argon2Params.Iterations = uint32(cfg.PasswordHash.Argon2Iterations)
+	argon2Params.Parallelism = uint8(cfg.PasswordHash.Argon2Parallelism)
+	hasher, err := security.NewPasswordHasher(cfg.PasswordHash.Algorithm, cfg.PasswordHash.BcryptCost, argon2Params)
+	if err != nil {
+		log.Fatal(err)
+	}
 
 	// 初始化各層
 	userRepo := repository.NewUserRepository(db)
-	userService := services.NewUserService(userRepo)
-	userHandler := handlers.NewUserHandler(userService, cfg.JWTSecret)
+	resetRepo := repository.NewPasswordResetRepository(db)
+	revocationRepo := repository.NewTokenRevocationRepository(redisClient, handlers.TokenTTL)
+	mfaRepo := repository.NewMFARepository(db)
+	emailChangeRepo := repository.NewEmailChangeRepository(db)
+	auditRepo := repository.NewAuditRepository(db)
+	sessionRepo := repository.NewSessionRepository(db)
+	m := mailer.NewLogMailer()
+	userService := services.NewUserService(userRepo,
+		services.WithPasswordReset(resetRepo, m, cfg.PasswordReset.URL, cfg.PasswordReset.TTL),
+		services.WithEmailChange(emailChangeRepo, m, cfg.EmailChange.URL, cfg.EmailChange.TTL),
+		services.WithTokenRevocation(revocationRepo),
+		services.WithPasswordPolicy(security.PasswordPolicy(cfg.PasswordPolicy)),
+		services.WithMFA(mfaRepo, cfg.MFAIssuer),
+		services.WithPasswordHasher(hasher),
+		services.WithDeletedUserRetention(cfg.UserPurge.Retention),
+		services.WithAudit(auditRepo),
+		services.WithSessions(sessionRepo),
+	)
+	var handlerOpts []handlers.HandlerOption
+	if cfg.RequireIfMatch {
+		handlerOpts = append(handlerOpts, handlers.WithRequireIfMatch())
+	}
+	// 關閉流程一開始 readiness 就轉為失敗，健康檢查回 503
+	readiness := &lifecycle.Readiness{}
+	handlerOpts = append(handlerOpts, handlers.WithReadiness(readiness.Ready))
+	userHandler := handlers.NewUserHandler(userService, cfg.JWTSecret, handlerOpts...)
+
+	// 背景定期清除超過保留期間的軟刪除用戶，關閉時等目前這一輪跑完
+	workerCtx, stopWorkers := context.WithCancel(context.Background())
+	var workers sync.WaitGroup
+	workers.Add(1)
+	go func() {
+		defer workers.Done()
+		userService.RunPurgeJob(workerCtx, cfg.UserPurge.Interval)
+	}()
+
+	// gRPC API：與 HTTP 共用同一個 service 層，同樣只接受 API Gateway 簽過的請求
+	// interceptor 依序為：panic recovery → request ID → log → metrics → gateway 簽章驗證
+	var grpcOpts []grpcserver.Option
+	if cfg.RequireIfMatch {
+		grpcOpts = append(grpcOpts, grpcserver.WithRequireIfMatch())
+	}
+	logger := slog.Default()
+	grpcMetrics := grpcserver.NewMetrics(prometheus.DefaultRegisterer)
+	grpcServer := grpc.NewServer(
+		grpc.ChainUnaryInterceptor(
+			grpcserver.UnaryRecovery(logger),
+			grpcserver.UnaryRequestID(),
+			grpcserver.UnaryLogger(logger),
+			grpcMetrics.UnaryInterceptor(),
+			grpcserver.RequireGatewaySignature(cfg.GatewayAuth.Secret, cfg.GatewayAuth.MaxSkew),
+		),
+		grpc.ChainStreamInterceptor(
+			grpcserver.StreamRecovery(logger),
+			grpcserver.StreamRequestID(),
+			grpcserver.StreamLogger(logger),
+			grpcMetrics.StreamInterceptor(),
+			grpcserver.StreamRequireGatewaySignature(cfg.GatewayAuth.Secret, cfg.GatewayAuth.MaxSkew),
+		),
+	)
+	userv1.RegisterUserServiceServer(grpcServer,
+		grpcserver.NewServer(userService, auth.NewIssuer(cfg.JWTSecret, time.Now), grpcOpts...))
+
+	// 標準的 gRPC health checking protocol，"" 代表整個 server
+	healthServer := grpchealth.NewServer()
+	healthServer.SetServingStatus("", healthpb.HealthCheckResponse_SERVING)
+	healthServer.SetServingStatus(userv1.UserService_ServiceDesc.ServiceName, healthpb.HealthCheckResponse_SERVING)
+	healthpb.RegisterHealthServer(grpcServer, healthServer)
+
+	// reflection 讓 grpcurl 等工具不需要 .proto 檔就能呼叫，預設關閉
+	if cfg.GRPCReflection {
+		reflection.Register(grpcServer)
+	}
+
+	lis, err := net.Listen("tcp", ":"+cfg.GRPCPort)
+	if err != nil {
+		log.Fatal("Failed to listen for gRPC:", err)
+	}
+	go func() {
+		log.Printf("User Service gRPC starting on port %s", cfg.GRPCPort)
+		if err := grpcServer.Serve(lis); err != nil {
+			log.Fatal("Failed to start gRPC server:", err)
+		}
+	}()
 
 	// 設定路由
 	router := gin.Default()
-	routes.SetupRoutes(router, userHandler)
+	// gin 預設信任所有 proxy，任何人都能以 X-Forwarded-For 偽造 ClientIP；格式已由 config.Validate 檢查
+	if err := router.SetTrustedProxies(cfg.TrustedProxies); err != nil {
+		log.Fatal("Invalid TRUSTED_PROXIES:", err)
+	}
+	probes := health.New(readiness.Ready,
+		health.WithTimeout(cfg.Health.CheckTimeout),
+		health.WithCacheTTL(cfg.Health.CacheTTL),
+		health.WithDetails(cfg.Health.ExposeDetails),
+	)
+	probes.AddCheck("postgres", 0, health.Postgres(db))
+	probes.AddCheck("redis", 0, health.Redis(redisClient))
+	probes.AddCheck("schema_version", 0, health.SchemaVersion(db, database.SchemaVersion))
+	routes.SetupRoutes(router, userHandler, probes, middleware.RequireGatewaySignature(cfg.GatewayAuth.Secret, cfg.GatewayAuth.MaxSkew, int64(cfg.MaxBodyBytes)))
+
+	// 啟動服務；用 http.Server 而不是 router.Run，收到 SIGTERM 時才能等進行中的請求結束
+	// router.Handler() 在開啟 h2c 時會包上 h2c handler，直接用 router 的話只會接受 HTTP/1.1
+	router.UseH2C = cfg.HTTP2Cleartext
+	httpServer := &http.Server{Addr: ":" + cfg.Port, Handler: router.Handler()}
+	go func() {
+		log.Printf("User Service starting on port %s", cfg.Port)
+		if err := httpServer.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			log.Fatal("Failed to start server:", err)
+		}
+	}()
+
+	// Prometheus 指標（gRPC 每個 method 的呼叫次數與耗時）只在內部的 port 提供，不經過對外的 router
+	var metricsServer *http.Server
+	if cfg.MetricsPort != "" {
+		mux := http.NewServeMux()
+		mux.Handle("/metrics", promhttp.Handler())
+		metricsServer = &http.Server{Addr: ":" + cfg.MetricsPort, Handler: mux}
+		go func() {
+			log.Printf("User Service metrics listening on port %s", cfg.MetricsPort)
+			if err := metricsServer.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+				log.Fatal("Failed to start metrics server:", err)
+			}
+		}()
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	// 向 service registry 登記，API Gateway 會從 registry 找到這個實例
+	var registrar *registry.Registrar
+	heartbeatCtx, stopHeartbeat := context.WithCancel(context.Background())
+	defer stopHeartbeat()
+	if cfg.Registry.Enabled {
+		registrar = registry.NewRegistrar(redisClient, registry.Instance{
+			ID:       registry.NewInstanceID(),
+			Service:  cfg.Registry.ServiceName,
+			HTTPAddr: "http://" + net.JoinHostPort(cfg.Registry.AdvertiseHost, cfg.Port),
+			GRPCAddr: net.JoinHostPort(cfg.Registry.AdvertiseHost, cfg.GRPCPort),
+		}, cfg.Registry.TTL)
+		// Redis 暫時連不上時不中止啟動，heartbeat 會持續重試
+		if err := registrar.Register(ctx); err != nil {
+			log.Printf("Failed to register instance: %v", err)
+		}
+		go registrar.Run(heartbeatCtx)
+		log.Printf("Registered %s instance %s", cfg.Registry.ServiceName, registrar.Instance().ID)
+	}
+
+	<-ctx.Done()
+	stop() // 恢復預設的 signal 處理，關閉途中再收到一次 SIGINT / SIGTERM 就直接結束
+	log.Println("Shutting down User Service")
+
+	// 關閉順序：
+	//  1. readiness 轉為失敗、從 registry 移除，gateway 與 load balancer 不再送新請求
+	//  2. 等待 drain 期間，讓 gateway 刷新上游清單
+	//  3. 停止 HTTP / gRPC / 指標 server，等進行中的請求結束
+	//  4. 停止背景工作
+	//  5. 最後才關閉 Redis 與 Postgres，避免還在處理的請求拿到已關閉的連線
+	var shutdown lifecycle.Shutdown
+	shutdown.Add("mark not ready", func(ctx context.Context) error {
+		readiness.SetDraining()
+		healthServer.Shutdown()
+		stopHeartbeat()
+		if registrar == nil {
+			return nil
+		}
+		return registrar.Deregister(ctx)
+	})
+	shutdown.Add("drain", func(ctx context.Context) error {
+		return lifecycle.Wait(ctx, cfg.Shutdown.DrainPeriod)
+	})
+	shutdown.Add("http server", httpServer.Shutdown)
+	shutdown.Add("grpc server", func(ctx context.Context) error {
+		done := make(chan struct{})
+		go func() {
+			grpcServer.GracefulStop()
+			close(done)
+		}()
+		select {
+		case <-done:
+			return nil
+		case <-ctx.Done():
+			// 逾時仍有 stream 未結束就強制中斷
+			grpcServer.Stop()
+			return ctx.Err()
+		}
+	})
+	if metricsServer != nil {
+		shutdown.Add("metrics server", metricsServer.Shutdown)
+	}
+	shutdown.Add("background workers", func(ctx context.Context) error {
+		stopWorkers()
+		done := make(chan struct{})
+		go func() {
+			workers.Wait()
+			close(done)
+		}()
+		select {
+		case <-done:
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+		// 還在寄送中的重設密碼信也要等完，之後才關閉 Redis 與 Postgres
+		return userService.WaitBackground(ctx)
+	})
+	shutdown.Add("redis", func(context.Context) error { return redisClient.Close() })
+	shutdown.Add("postgres", func(context.Context) error { return db.Close() })
 
-	// 啟動服務
-	log.Printf("User Service starting on port %s", cfg.Port)
-	if err := router.Run(":" + cfg.Port); err != nil {
-		log.Fatal("Failed to start server:", err)
+	if err := shutdown.Run(cfg.Shutdown.DrainPeriod + cfg.Shutdown.Timeout); err != nil {
+		log.Printf("User Service stopped with errors: %v", err)
+		os.Exit(1)
 	}
+	log.Println("User Service stopped")
 }
@@ -0,0 +1,37 @@
+package middleware
+
+import (
+	"context"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// RequestTimeoutHeader API Gateway 告知這個請求還剩多少毫秒，需與 api-gateway proxy.RequestTimeoutHeader 相同
+const RequestTimeoutHeader = "X-Request-Timeout"
+
+// RequestDeadline 將 gateway 傳來的剩餘時間轉成 request context 的 deadline
+//
+// gateway 逾時後已經回 504 給客戶端，繼續處理只是浪費資源；
+// 剩餘時間為 0 時直接回 504，不再進入 handler。沒有帶 header 或格式錯誤時不設定 deadline。
+// 必須掛在 RequireGatewaySignature 之後，只信任 gateway 轉發的請求
+func RequestDeadline() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		ms, err := strconv.ParseInt(c.GetHeader(RequestTimeoutHeader), 10, 64)
+		if err != nil || ms < 0 {
+			c.Next()
+			return
+		}
+		if ms == 0 {
+			c.AbortWithStatusJSON(http.StatusGatewayTimeout, gin.H{"error": "request deadline exceeded"})
+			return
+		}
+
+		ctx, cancel := context.WithTimeout(c.Request.Context(), time.Duration(ms)*time.Millisecond)
+		defer cancel()
+		c.Request = c.Request.WithContext(ctx)
+		c.Next()
+	}
+}
@@ -0,0 +1,53 @@
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+)
+
+// setupDeadlineRouter handler 回傳 request context 剩餘的時間（沒有 deadline 時回 "none"）
+func setupDeadlineRouter() *gin.Engine {
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	r.GET("/users", RequestDeadline(), func(c *gin.Context) {
+		deadline, ok := c.Request.Context().Deadline()
+		if !ok {
+			c.String(http.StatusOK, "none")
+			return
+		}
+		c.String(http.StatusOK, time.Until(deadline).Round(time.Second).String())
+	})
+	return r
+}
+
+func TestRequestDeadline(t *testing.T) {
+	tests := []struct {
+		name     string
+		header   string
+		wantCode int
+		wantBody string
+	}{
+		{"sets deadline from header", "5000", http.StatusOK, "5s"},
+		{"no header", "", http.StatusOK, "none"},
+		{"invalid header is ignored", "soon", http.StatusOK, "none"},
+		{"exhausted budget", "0", http.StatusGatewayTimeout, `{"error":"request deadline exceeded"}`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req, _ := http.NewRequest("GET", "/users", nil)
+			if tt.header != "" {
+				req.Header.Set(RequestTimeoutHeader, tt.header)
+			}
+			w := httptest.NewRecorder()
+			setupDeadlineRouter().ServeHTTP(w, req)
+
+			assert.Equal(t, tt.wantCode, w.Code)
+			assert.Equal(t, tt.wantBody, w.Body.String())
+		})
+	}
+}
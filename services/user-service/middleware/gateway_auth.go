@@ -0,0 +1,70 @@
+package middleware
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"user-service/security"
+)
+
+// RequireGatewaySignature 只接受經由 API Gateway 轉發、附有合法簽章的請求
+// maxSkew 為簽章時間與收到請求時間可容許的最大差距，maxBodyBytes 為請求 body 的上限
+//
+// user-service 的 port 可能被直接連到，而身份 header 是由 gateway 驗證 token 後寫入的；
+// 沒有這層檢查的話，任何人都能繞過 RequireAuth、自己帶上 X-User-ID 冒充其他用戶
+func RequireGatewaySignature(secret string, maxSkew time.Duration, maxBodyBytes int64) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		timestamp := c.GetHeader(security.GatewayTimestampHeader)
+		signature := c.GetHeader(security.GatewaySignatureHeader)
+		if timestamp == "" || signature == "" {
+			rejectUnsigned(c, "missing gateway signature")
+			return
+		}
+
+		// 限制時間窗，攔截到的請求無法在之後重送
+		signedAt, err := strconv.ParseInt(timestamp, 10, 64)
+		if err != nil {
+			rejectUnsigned(c, "invalid gateway timestamp")
+			return
+		}
+		if skew := time.Since(time.Unix(signedAt, 0)); skew > maxSkew || skew < -maxSkew {
+			rejectUnsigned(c, "gateway signature expired")
+			return
+		}
+
+		// body 也在簽章範圍內，讀完後放回去讓 handler 可以再讀一次
+		// 此時還沒驗證簽章，先限制大小，避免直接連到 port 的人送來無限大的 body
+		var body []byte
+		if c.Request.Body != nil {
+			body, err = io.ReadAll(http.MaxBytesReader(c.Writer, c.Request.Body, maxBodyBytes))
+			if err != nil {
+				var tooLarge *http.MaxBytesError
+				if errors.As(err, &tooLarge) {
+					c.AbortWithStatusJSON(http.StatusRequestEntityTooLarge, gin.H{"error": "request body too large"})
+					return
+				}
+				c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": "failed to read request body"})
+				return
+			}
+			c.Request.Body = io.NopCloser(bytes.NewReader(body))
+		}
+
+		expected := security.GatewaySignature(secret, c.Request.Method, c.Request.URL.Path, c.Request.URL.RawQuery,
+			timestamp, body, c.Request.Header)
+		if !security.VerifyGatewaySignature(expected, signature) {
+			rejectUnsigned(c, "invalid gateway signature")
+			return
+		}
+
+		c.Next()
+	}
+}
+
+func rejectUnsigned(c *gin.Context, reason string) {
+	c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": reason})
+}
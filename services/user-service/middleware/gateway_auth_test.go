@@ -0,0 +1,118 @@
+package middleware
+
+import (
+	"bytes"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"user-service/security"
+)
+
+const testSecret = "internal-secret"
+
+// testMaxBodyBytes 測試用的 body 上限
+const testMaxBodyBytes = 64
+
+// setupRouter 掛上 RequireGatewaySignature，handler 回傳讀到的 body 以確認 body 仍可讀取
+func setupRouter() *gin.Engine {
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	r.POST("/users/:id", RequireGatewaySignature(testSecret, 30*time.Second, testMaxBodyBytes), func(c *gin.Context) {
+		body, _ := io.ReadAll(c.Request.Body)
+		c.String(http.StatusOK, string(body))
+	})
+	return r
+}
+
+// signedRequest 模擬 API Gateway 簽章後的請求
+func signedRequest(secret string, signedAt time.Time, body string) *http.Request {
+	r, _ := http.NewRequest("POST", "/users/abc-123?x=1", bytes.NewBufferString(body))
+	r.Header.Set("X-User-ID", "uuid-001")
+	r.Header.Set("X-Request-ID", "req-42")
+	ts := strconv.FormatInt(signedAt.Unix(), 10)
+	r.Header.Set(security.GatewayTimestampHeader, ts)
+	r.Header.Set(security.GatewaySignatureHeader,
+		security.GatewaySignature(secret, "POST", "/users/abc-123", "x=1", ts, []byte(body), r.Header))
+	return r
+}
+
+func TestRequireGatewaySignature(t *testing.T) {
+	t.Run("valid signature", func(t *testing.T) {
+		w := httptest.NewRecorder()
+		setupRouter().ServeHTTP(w, signedRequest(testSecret, time.Now(), `{"a":1}`))
+
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.Equal(t, `{"a":1}`, w.Body.String())
+	})
+
+	t.Run("unsigned request", func(t *testing.T) {
+		w := httptest.NewRecorder()
+		r, _ := http.NewRequest("POST", "/users/abc-123", nil)
+		r.Header.Set("X-User-ID", "uuid-001")
+		setupRouter().ServeHTTP(w, r)
+
+		assert.Equal(t, http.StatusUnauthorized, w.Code)
+	})
+
+	t.Run("wrong secret", func(t *testing.T) {
+		w := httptest.NewRecorder()
+		setupRouter().ServeHTTP(w, signedRequest("guessed-secret", time.Now(), ""))
+
+		assert.Equal(t, http.StatusUnauthorized, w.Code)
+	})
+
+	t.Run("tampered identity header", func(t *testing.T) {
+		r := signedRequest(testSecret, time.Now(), "")
+		r.Header.Set("X-User-ID", "someone-else")
+
+		w := httptest.NewRecorder()
+		setupRouter().ServeHTTP(w, r)
+
+		assert.Equal(t, http.StatusUnauthorized, w.Code)
+	})
+
+	t.Run("tampered body", func(t *testing.T) {
+		r := signedRequest(testSecret, time.Now(), `{"role":"user"}`)
+		r.Body = io.NopCloser(bytes.NewBufferString(`{"role":"admin"}`))
+
+		w := httptest.NewRecorder()
+		setupRouter().ServeHTTP(w, r)
+
+		assert.Equal(t, http.StatusUnauthorized, w.Code)
+	})
+
+	t.Run("body at the limit", func(t *testing.T) {
+		body := strings.Repeat("x", testMaxBodyBytes)
+		w := httptest.NewRecorder()
+		setupRouter().ServeHTTP(w, signedRequest(testSecret, time.Now(), body))
+
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.Equal(t, body, w.Body.String())
+	})
+
+	t.Run("body over the limit is rejected before the signature check", func(t *testing.T) {
+		// 沒有簽章也一樣：大小限制在驗證之前就套用
+		r, _ := http.NewRequest("POST", "/users/abc-123", strings.NewReader(strings.Repeat("x", testMaxBodyBytes+1)))
+		r.Header.Set(security.GatewayTimestampHeader, strconv.FormatInt(time.Now().Unix(), 10))
+		r.Header.Set(security.GatewaySignatureHeader, "forged")
+
+		w := httptest.NewRecorder()
+		setupRouter().ServeHTTP(w, r)
+
+		assert.Equal(t, http.StatusRequestEntityTooLarge, w.Code)
+	})
+
+	t.Run("expired signature", func(t *testing.T) {
+		w := httptest.NewRecorder()
+		setupRouter().ServeHTTP(w, signedRequest(testSecret, time.Now().Add(-time.Minute), ""))
+
+		assert.Equal(t, http.StatusUnauthorized, w.Code)
+	})
+}
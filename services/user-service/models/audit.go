@@ -0,0 +1,72 @@
+package models
+
+import "time"
+
+// 稽核事件的 action 名稱
+const (
+	AuditUserRegistered         = "user.registered"
+	AuditUserUpdated            = "user.updated"
+	AuditUserDeleted            = "user.deleted"
+	AuditUserRestored           = "user.restored"
+	AuditUserPurged             = "user.purged"
+	AuditEmailChangeRequested   = "user.email_change_requested"
+	AuditEmailChanged           = "user.email_changed"
+	AuditLoginSucceeded         = "auth.login_succeeded"
+	AuditLoginFailed            = "auth.login_failed"
+	AuditSessionRevoked         = "auth.session_revoked"
+	AuditMFAVerified            = "auth.mfa_verified"
+	AuditMFAFailed              = "auth.mfa_failed"
+	AuditMFAEnabled             = "auth.mfa_enabled"
+	AuditMFADisabled            = "auth.mfa_disabled"
+	AuditPasswordChanged        = "auth.password_changed"
+	AuditPasswordResetRequested = "auth.password_reset_requested"
+	AuditPasswordReset          = "auth.password_reset"
+)
+
+// AuditActorSystem 背景工作（例如 purge job）寫入稽核紀錄時使用的操作者
+const AuditActorSystem = "system"
+
+// FieldChange 單一欄位修改前後的值
+type FieldChange struct {
+	From string `json:"from"`
+	To   string `json:"to"`
+}
+
+// AuditEvent 一筆稽核紀錄，寫入後不可修改或刪除
+type AuditEvent struct {
+	ID         int64                  `json:"id"`
+	OccurredAt time.Time              `json:"occurred_at"`
+	ActorID    string                 `json:"actor_id,omitempty"`  // 執行操作的用戶，未登入時為空
+	TargetID   string                 `json:"target_id,omitempty"` // 被操作的用戶
+	Action     string                 `json:"action"`
+	IP         string                 `json:"ip,omitempty"`
+	UserAgent  string                 `json:"user_agent,omitempty"`
+	RequestID  string                 `json:"request_id,omitempty"`
+	Changes    map[string]FieldChange `json:"changes,omitempty"`
+	Metadata   map[string]string      `json:"metadata,omitempty"`
+}
+
+// AuditFilter 查詢稽核紀錄的條件，零值欄位代表不篩選
+type AuditFilter struct {
+	ActorID  string
+	TargetID string
+	Action   string
+	From     *time.Time
+	To       *time.Time
+	Before   int64 // 分頁游標：只回傳 id 小於此值的紀錄
+	Limit    int
+}
+
+// AuditEventPage 一頁稽核紀錄，由新到舊排列
+type AuditEventPage struct {
+	Events     []AuditEvent `json:"events"`
+	NextCursor int64        `json:"next_cursor,omitempty"` // 傳給下一次查詢的 cursor，沒有下一頁時省略
+}
+
+// RequestMeta 發出請求的來源資訊，寫入稽核紀錄用
+type RequestMeta struct {
+	ActorID   string
+	IP        string
+	UserAgent string
+	RequestID string
+}
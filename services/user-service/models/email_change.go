@@ -0,0 +1,26 @@
+package models
+
+import "time"
+
+// EmailChangeToken 代表一筆待確認的 email 變更
+// 新 email 在確認前只存在這裡，users 表上的 email 維持不變
+type EmailChangeToken struct {
+	ID        string
+	UserID    string
+	NewEmail  string
+	TokenHash string
+	ExpiresAt time.Time
+	UsedAt    *time.Time
+	CreatedAt time.Time
+}
+
+// EmailChangeRequest 申請變更 email（需提供目前的密碼）
+type EmailChangeRequest struct {
+	NewEmail        string `json:"new_email" binding:"required,email"`
+	CurrentPassword string `json:"current_password" binding:"required"`
+}
+
+// ConfirmEmailChangeRequest 以寄到新 email 的 token 確認變更
+type ConfirmEmailChangeRequest struct {
+	Token string `json:"token" binding:"required"`
+}
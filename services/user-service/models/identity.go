@@ -0,0 +1,19 @@
+package models
+
+// Identity API Gateway 驗證 access token 後，透過 header 傳給下游服務的呼叫者身份
+type Identity struct {
+	UserID    string
+	Email     string
+	Roles     []string
+	SessionID string
+}
+
+// HasRole 判斷呼叫者是否擁有指定角色
+func (i Identity) HasRole(role string) bool {
+	for _, r := range i.Roles {
+		if r == role {
+			return true
+		}
+	}
+	return false
+}
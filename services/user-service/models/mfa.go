@@ -0,0 +1,44 @@
+package models
+
+import "time"
+
+// MFASettings 用戶的 TOTP 設定
+// Enabled 為 false 代表已產生 secret 但尚未完成確認
+type MFASettings struct {
+	UserID       string
+	Secret       string
+	Enabled      bool
+	LastUsedStep int64 // 最後一次驗證成功的時間區間，用來拒絕重複使用同一個 code
+	ConfirmedAt  *time.Time
+	CreatedAt    time.Time
+}
+
+// TOTPEnrollment 開始綁定 TOTP 時回傳給前端的資料
+type TOTPEnrollment struct {
+	Secret          string `json:"secret"`
+	ProvisioningURI string `json:"provisioning_uri"` // otpauth:// URI，前端轉成 QR code
+}
+
+// MFACodeRequest 帶有 TOTP code 或復原碼的請求
+type MFACodeRequest struct {
+	Code string `json:"code" binding:"required"`
+}
+
+// MFALoginRequest 兩階段登入的第二步
+type MFALoginRequest struct {
+	MFAToken string `json:"mfa_token" binding:"required"`
+	Code     string `json:"code" binding:"required"`
+}
+
+// MFAChallengeResponse 帳密正確但需要 MFA 時的登入響應
+type MFAChallengeResponse struct {
+	Message     string `json:"message"`
+	MFARequired bool   `json:"mfa_required"`
+	MFAToken    string `json:"mfa_token"` // 只能用於 /users/login/mfa，不能當作 access token
+}
+
+// RecoveryCodesResponse 啟用 MFA 後回傳的復原碼（只會顯示這一次）
+type RecoveryCodesResponse struct {
+	Message       string   `json:"message"`
+	RecoveryCodes []string `json:"recovery_codes"`
+}
@@ -0,0 +1,25 @@
+package models
+
+import "time"
+
+// PasswordResetToken 代表一筆重設密碼的 token 紀錄
+// DB 只存 token 的 SHA-256 hash，原始 token 只會出現在寄給用戶的信件中
+type PasswordResetToken struct {
+	ID        string
+	UserID    string
+	TokenHash string
+	ExpiresAt time.Time
+	UsedAt    *time.Time
+	CreatedAt time.Time
+}
+
+// ForgotPasswordRequest 忘記密碼請求
+type ForgotPasswordRequest struct {
+	Email string `json:"email" binding:"required,email"`
+}
+
+// ResetPasswordRequest 重設密碼請求
+type ResetPasswordRequest struct {
+	Token       string `json:"token" binding:"required"`
+	NewPassword string `json:"new_password" binding:"required"`
+}
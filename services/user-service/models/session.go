@@ -0,0 +1,16 @@
+package models
+
+import "time"
+
+// Session 一次成功登入所建立的工作階段，session ID 會寫進 JWT 的 sid claim
+type Session struct {
+	ID        string     `json:"id"`
+	UserID    string     `json:"-"`
+	IP        string     `json:"ip"`
+	UserAgent string     `json:"user_agent"`
+	CreatedAt time.Time  `json:"created_at"`
+	ExpiresAt time.Time  `json:"expires_at"`
+	RevokedAt *time.Time `json:"revoked_at,omitempty"`
+	Active    bool       `json:"active"`  // 未過期且未撤銷，查詢時計算，不存 DB
+	Current   bool       `json:"current"` // 是否為發出這次請求的 session，不存 DB
+}
@@ -2,21 +2,36 @@ package models
 
 import "time"
 
+// 用戶角色，admin 可以使用 /admin 底下的管理 API
+// 目前沒有提供升級角色的 API，需直接更新資料庫的 role 欄位
+const (
+	RoleUser  = "user"
+	RoleAdmin = "admin"
+)
+
 // User 代表用戶資料模型
 type User struct {
-	ID        string    `json:"id"`
-	Email     string    `json:"email"`
-	Username  string    `json:"username"`
-	Password  string    `json:"-"` // 不在 JSON 中顯示
-	CreatedAt time.Time `json:"created_at"`
-	UpdatedAt time.Time `json:"updated_at"`
+	ID          string    `json:"id"`
+	Email       string    `json:"email"`
+	Username    string    `json:"username"`
+	Password    string    `json:"-"` // 不在 JSON 中顯示
+	Role        string    `json:"role"`
+	DisplayName string    `json:"display_name"`
+	Bio         string    `json:"bio"`
+	AvatarURL   string    `json:"avatar_url"`
+	Locale      string    `json:"locale"`   // BCP 47 語言標籤，例如 zh-TW
+	Timezone    string    `json:"timezone"` // IANA 時區名稱，例如 Asia/Taipei
+	MFAEnabled  bool      `json:"mfa_enabled"`
+	Version     int       `json:"version"` // 每次更新遞增，用於 ETag / If-Match 樂觀鎖
+	CreatedAt   time.Time `json:"created_at"`
+	UpdatedAt   time.Time `json:"updated_at"`
 }
 
 // RegisterRequest 註冊請求
 type RegisterRequest struct {
 	Email    string `json:"email" binding:"required,email"`
 	Username string `json:"username" binding:"required"`
-	Password string `json:"password" binding:"required,min=6"`
+	Password string `json:"password" binding:"required"` // 強度規則由 security.PasswordPolicy 檢查
 }
 
 // LoginRequest 登入請求
@@ -30,6 +45,12 @@ type UpdateUserRequest struct {
 	Username string `json:"username"`
 }
 
+// ChangePasswordRequest 修改密碼請求（需提供目前的密碼）
+type ChangePasswordRequest struct {
+	CurrentPassword string `json:"current_password" binding:"required"`
+	NewPassword     string `json:"new_password" binding:"required"`
+}
+
 // LoginResponse 登入響應
 type LoginResponse struct {
 	Message string `json:"message"`
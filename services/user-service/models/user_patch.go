@@ -0,0 +1,65 @@
+package models
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+)
+
+// MergePatchContentType RFC 7396 JSON Merge Patch 的 media type
+const MergePatchContentType = "application/merge-patch+json"
+
+// UserChanges 部分更新用戶時要修改的欄位，nil 代表不修改
+type UserChanges struct {
+	Username    *string
+	DisplayName *string
+	Bio         *string
+	AvatarURL   *string
+	Locale      *string
+	Timezone    *string
+}
+
+// IsEmpty 沒有任何要修改的欄位
+func (c UserChanges) IsEmpty() bool {
+	return c.Username == nil && c.DisplayName == nil && c.Bio == nil &&
+		c.AvatarURL == nil && c.Locale == nil && c.Timezone == nil
+}
+
+// ParseUserMergePatch 依 RFC 7396 解析用戶資料的 merge patch
+//
+//   - 沒出現的欄位不修改
+//   - 值為 null 代表清除該欄位（設為空字串，是否允許由 service 層驗證）
+//   - email、id 等唯讀或不存在的欄位直接拒絕，避免客戶端誤以為已更新
+func ParseUserMergePatch(data []byte) (*UserChanges, error) {
+	var fields map[string]json.RawMessage
+	if err := json.Unmarshal(data, &fields); err != nil || fields == nil {
+		return nil, fmt.Errorf("merge patch must be a JSON object")
+	}
+
+	changes := &UserChanges{}
+	targets := map[string]**string{
+		"username":     &changes.Username,
+		"display_name": &changes.DisplayName,
+		"bio":          &changes.Bio,
+		"avatar_url":   &changes.AvatarURL,
+		"locale":       &changes.Locale,
+		"timezone":     &changes.Timezone,
+	}
+
+	for name, raw := range fields {
+		target, ok := targets[name]
+		if !ok {
+			return nil, fmt.Errorf("field %q cannot be updated", name)
+		}
+
+		value := ""
+		if !bytes.Equal(bytes.TrimSpace(raw), []byte("null")) {
+			if err := json.Unmarshal(raw, &value); err != nil {
+				return nil, fmt.Errorf("field %q must be a string or null", name)
+			}
+		}
+		*target = &value
+	}
+
+	return changes, nil
+}
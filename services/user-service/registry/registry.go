@@ -0,0 +1,111 @@
+package registry
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// InstanceKeyPrefix 是 Redis 中服務實例紀錄的 key 前綴，完整 key 為 <prefix><service>:<instance id>
+// API Gateway 以同一個前綴掃描可用的實例，兩邊必須保持一致
+const InstanceKeyPrefix = "registry:instances:"
+
+// DefaultTTL 實例紀錄的存活時間，超過這段時間沒有 heartbeat 就會自動從 registry 消失
+const DefaultTTL = 15 * time.Second
+
+// Instance 一個服務實例對外公告的位址
+type Instance struct {
+	ID           string    `json:"id"`
+	Service      string    `json:"service"`
+	HTTPAddr     string    `json:"http_addr,omitempty"` // 含 scheme，例如 http://10.0.0.5:8081
+	GRPCAddr     string    `json:"grpc_addr,omitempty"` // host:port
+	RegisteredAt time.Time `json:"registered_at"`
+}
+
+// Registrar 在 Redis 登記一個服務實例，並以 heartbeat 持續延長紀錄的 TTL
+//
+// 實例異常終止、來不及 Deregister 時，紀錄會在 TTL 到期後自然消失，
+// gateway 最多只會在這段時間內把請求送到已經不存在的實例
+type Registrar struct {
+	client   *redis.Client
+	instance Instance
+	ttl      time.Duration
+}
+
+// NewRegistrar 創建 Registrar，ttl <= 0 時使用 DefaultTTL
+func NewRegistrar(client *redis.Client, instance Instance, ttl time.Duration) *Registrar {
+	if ttl <= 0 {
+		ttl = DefaultTTL
+	}
+	if instance.RegisteredAt.IsZero() {
+		instance.RegisteredAt = time.Now().UTC()
+	}
+	return &Registrar{client: client, instance: instance, ttl: ttl}
+}
+
+// Instance 回傳登記的實例資訊
+func (r *Registrar) Instance() Instance {
+	return r.instance
+}
+
+// Register 寫入（或刷新）實例紀錄
+func (r *Registrar) Register(ctx context.Context) error {
+	value, err := json.Marshal(r.instance)
+	if err != nil {
+		return fmt.Errorf("failed to encode instance: %w", err)
+	}
+	if err := r.client.Set(ctx, r.key(), value, r.ttl).Err(); err != nil {
+		return fmt.Errorf("failed to register instance: %w", err)
+	}
+	return nil
+}
+
+// Run 每隔 TTL 的三分之一重新寫入紀錄，直到 ctx 結束
+// 用 SET 而不是 EXPIRE，Redis 重啟後紀錄遺失也能在下一次 heartbeat 補回來
+func (r *Registrar) Run(ctx context.Context) {
+	ticker := time.NewTicker(r.ttl / 3)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if err := r.Register(ctx); err != nil && ctx.Err() == nil {
+				log.Printf("registry heartbeat failed: %v", err)
+			}
+		}
+	}
+}
+
+// Deregister 移除實例紀錄，讓 gateway 在下一次刷新時就不再把請求送過來
+func (r *Registrar) Deregister(ctx context.Context) error {
+	if err := r.client.Del(ctx, r.key()).Err(); err != nil {
+		return fmt.Errorf("failed to deregister instance: %w", err)
+	}
+	return nil
+}
+
+func (r *Registrar) key() string {
+	return InstanceKeyPrefix + r.instance.Service + ":" + r.instance.ID
+}
+
+// NewInstanceID 以 hostname 加上隨機後綴產生實例 ID，同一台主機重啟後也不會和殘留的舊紀錄衝突
+func NewInstanceID() string {
+	host, err := os.Hostname()
+	if err != nil || host == "" {
+		host = "unknown"
+	}
+	suffix := make([]byte, 4)
+	if _, err := rand.Read(suffix); err != nil {
+		return host
+	}
+	return host + "-" + hex.EncodeToString(suffix)
+}
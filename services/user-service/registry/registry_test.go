@@ -0,0 +1,81 @@
+//go:build integration
+
+package registry
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// setupIntegrationRedis 連到測試用的 Redis，需先啟動 Redis（docker compose up redis）
+func setupIntegrationRedis(t *testing.T) *redis.Client {
+	t.Helper()
+
+	host := getEnvOrDefault("REDIS_HOST", "localhost")
+	port := getEnvOrDefault("REDIS_PORT", "6379")
+	client := redis.NewClient(&redis.Options{Addr: fmt.Sprintf("%s:%s", host, port)})
+	require.NoError(t, client.Ping(context.Background()).Err(), "failed to ping redis — is redis running?")
+
+	t.Cleanup(func() { client.Close() })
+	return client
+}
+
+func getEnvOrDefault(key, defaultValue string) string {
+	if value := os.Getenv(key); value != "" {
+		return value
+	}
+	return defaultValue
+}
+
+func TestRegistrar(t *testing.T) {
+	client := setupIntegrationRedis(t)
+	ctx := context.Background()
+
+	registrar := NewRegistrar(client, Instance{
+		ID:       NewInstanceID(),
+		Service:  "integration-test",
+		HTTPAddr: "http://10.0.0.5:8081",
+		GRPCAddr: "10.0.0.5:9081",
+	}, 3*time.Second)
+	key := InstanceKeyPrefix + "integration-test:" + registrar.Instance().ID
+	t.Cleanup(func() { client.Del(ctx, key) })
+
+	t.Run("register writes instance with ttl", func(t *testing.T) {
+		require.NoError(t, registrar.Register(ctx))
+
+		raw, err := client.Get(ctx, key).Bytes()
+		require.NoError(t, err)
+		var got Instance
+		require.NoError(t, json.Unmarshal(raw, &got))
+		assert.Equal(t, "http://10.0.0.5:8081", got.HTTPAddr)
+		assert.Equal(t, "10.0.0.5:9081", got.GRPCAddr)
+
+		ttl, err := client.TTL(ctx, key).Result()
+		require.NoError(t, err)
+		assert.True(t, ttl > 0 && ttl <= 3*time.Second)
+	})
+
+	t.Run("heartbeat restores expired record", func(t *testing.T) {
+		runCtx, cancel := context.WithCancel(ctx)
+		defer cancel()
+		client.Del(ctx, key)
+		go registrar.Run(runCtx)
+
+		assert.Eventually(t, func() bool {
+			return client.Exists(ctx, key).Val() == 1
+		}, 3*time.Second, 100*time.Millisecond)
+	})
+
+	t.Run("deregister removes instance", func(t *testing.T) {
+		require.NoError(t, registrar.Deregister(ctx))
+		assert.Equal(t, int64(0), client.Exists(ctx, key).Val())
+	})
+}
@@ -0,0 +1,134 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"user-service/models"
+)
+
+// AuditRepositoryInterface 定義稽核紀錄的資料存取契約
+// 只提供新增與查詢，沒有修改或刪除
+type AuditRepositoryInterface interface {
+	Append(ctx context.Context, event *models.AuditEvent) error
+	Query(ctx context.Context, filter models.AuditFilter) ([]models.AuditEvent, error)
+}
+
+// AuditRepository 稽核紀錄資料訪問層
+type AuditRepository struct {
+	db *sql.DB
+}
+
+// NewAuditRepository 創建稽核紀錄 Repository
+func NewAuditRepository(db *sql.DB) *AuditRepository {
+	return &AuditRepository{db: db}
+}
+
+// Append 新增一筆稽核紀錄
+func (r *AuditRepository) Append(ctx context.Context, event *models.AuditEvent) error {
+	changes, err := nullableJSON(event.Changes, len(event.Changes))
+	if err != nil {
+		return err
+	}
+	metadata, err := nullableJSON(event.Metadata, len(event.Metadata))
+	if err != nil {
+		return err
+	}
+
+	query := `INSERT INTO audit_events
+	          (occurred_at, actor_id, target_id, action, ip, user_agent, request_id, changes, metadata)
+	          VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9)
+	          RETURNING id`
+	err = r.db.QueryRowContext(ctx, query,
+		event.OccurredAt, event.ActorID, event.TargetID, event.Action,
+		event.IP, event.UserAgent, event.RequestID, changes, metadata,
+	).Scan(&event.ID)
+	if err != nil {
+		return fmt.Errorf("failed to append audit event: %w", err)
+	}
+	return nil
+}
+
+// Query 依條件查詢稽核紀錄，由新到舊排列，最多回傳 filter.Limit 筆
+func (r *AuditRepository) Query(ctx context.Context, filter models.AuditFilter) ([]models.AuditEvent, error) {
+	var conds []string
+	var args []interface{}
+	where := func(cond string, value interface{}) {
+		args = append(args, value)
+		conds = append(conds, fmt.Sprintf(cond, len(args)))
+	}
+	if filter.ActorID != "" {
+		where("actor_id = $%d", filter.ActorID)
+	}
+	if filter.TargetID != "" {
+		where("target_id = $%d", filter.TargetID)
+	}
+	if filter.Action != "" {
+		where("action = $%d", filter.Action)
+	}
+	if filter.From != nil {
+		where("occurred_at >= $%d", *filter.From)
+	}
+	if filter.To != nil {
+		where("occurred_at < $%d", *filter.To)
+	}
+	if filter.Before > 0 {
+		where("id < $%d", filter.Before)
+	}
+
+	query := `SELECT id, occurred_at, actor_id, target_id, action, ip, user_agent, request_id, changes, metadata
+	          FROM audit_events`
+	if len(conds) > 0 {
+		query += " WHERE " + strings.Join(conds, " AND ")
+	}
+	args = append(args, filter.Limit)
+	query += fmt.Sprintf(" ORDER BY id DESC LIMIT $%d", len(args))
+
+	rows, err := r.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query audit events: %w", err)
+	}
+	defer rows.Close()
+
+	events := []models.AuditEvent{}
+	for rows.Next() {
+		var event models.AuditEvent
+		var changes, metadata []byte
+		err := rows.Scan(&event.ID, &event.OccurredAt, &event.ActorID, &event.TargetID, &event.Action,
+			&event.IP, &event.UserAgent, &event.RequestID, &changes, &metadata)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan audit event: %w", err)
+		}
+		if changes != nil {
+			if err := json.Unmarshal(changes, &event.Changes); err != nil {
+				return nil, fmt.Errorf("failed to decode audit changes: %w", err)
+			}
+		}
+		if metadata != nil {
+			if err := json.Unmarshal(metadata, &event.Metadata); err != nil {
+				return nil, fmt.Errorf("failed to decode audit metadata: %w", err)
+			}
+		}
+		events = append(events, event)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to query audit events: %w", err)
+	}
+
+	return events, nil
+}
+
+// nullableJSON 將 map 編碼成 JSON，空的 map 存成 NULL
+func nullableJSON(value interface{}, size int) (interface{}, error) {
+	if size == 0 {
+		return nil, nil
+	}
+	b, err := json.Marshal(value)
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode audit event: %w", err)
+	}
+	return string(b), nil
+}
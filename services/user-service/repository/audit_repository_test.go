@@ -0,0 +1,87 @@
+//go:build integration
+
+package repository
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	"user-service/models"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// ===================================================================
+// AuditRepository 測試
+// audit_events 是 append-only，測試資料無法清除，每次用不同的 target_id 避免互相干擾
+// ===================================================================
+
+func TestAuditRepository(t *testing.T) {
+	db := setupIntegrationDB(t)
+	ctx := context.Background()
+	repo := NewAuditRepository(db)
+	target := fmt.Sprintf("audit-target-%d", time.Now().UnixNano())
+	now := time.Now().UTC().Truncate(time.Microsecond)
+
+	first := &models.AuditEvent{
+		OccurredAt: now.Add(-time.Hour),
+		ActorID:    "admin-1",
+		TargetID:   target,
+		Action:     models.AuditUserUpdated,
+		IP:         "203.0.113.7",
+		RequestID:  "req-1",
+		Changes:    map[string]models.FieldChange{"username": {From: "old", To: "new"}},
+	}
+	second := &models.AuditEvent{OccurredAt: now, TargetID: target, Action: models.AuditUserDeleted}
+	third := &models.AuditEvent{
+		OccurredAt: now,
+		TargetID:   target,
+		Action:     models.AuditLoginFailed,
+		Metadata:   map[string]string{"reason": "wrong_password"},
+	}
+	for _, e := range []*models.AuditEvent{first, second, third} {
+		require.NoError(t, repo.Append(ctx, e))
+		assert.NotZero(t, e.ID)
+	}
+
+	t.Run("newest first with keyset pagination", func(t *testing.T) {
+		page, err := repo.Query(ctx, models.AuditFilter{TargetID: target, Limit: 2})
+		require.NoError(t, err)
+		require.Len(t, page, 2)
+		assert.Equal(t, third.ID, page[0].ID)
+		assert.Equal(t, "wrong_password", page[0].Metadata["reason"])
+
+		rest, err := repo.Query(ctx, models.AuditFilter{TargetID: target, Before: page[1].ID, Limit: 2})
+		require.NoError(t, err)
+		require.Len(t, rest, 1)
+		assert.Equal(t, first.ID, rest[0].ID)
+		assert.Equal(t, first.Changes, rest[0].Changes)
+		assert.Equal(t, "req-1", rest[0].RequestID)
+	})
+
+	t.Run("filters", func(t *testing.T) {
+		events, err := repo.Query(ctx, models.AuditFilter{TargetID: target, Action: models.AuditUserDeleted, Limit: 10})
+		require.NoError(t, err)
+		require.Len(t, events, 1)
+		assert.Equal(t, second.ID, events[0].ID)
+
+		from := now.Add(-time.Minute)
+		events, err = repo.Query(ctx, models.AuditFilter{TargetID: target, From: &from, Limit: 10})
+		require.NoError(t, err)
+		assert.Len(t, events, 2)
+
+		events, err = repo.Query(ctx, models.AuditFilter{TargetID: target, ActorID: "admin-1", Limit: 10})
+		require.NoError(t, err)
+		assert.Len(t, events, 1)
+	})
+
+	t.Run("append-only", func(t *testing.T) {
+		_, err := db.Exec("UPDATE audit_events SET action = 'tampered' WHERE id = $1", first.ID)
+		assert.Error(t, err)
+		_, err = db.Exec("DELETE FROM audit_events WHERE id = $1", first.ID)
+		assert.Error(t, err)
+	})
+}
@@ -0,0 +1,135 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/lib/pq"
+	"user-service/models"
+)
+
+// EmailChangeRepositoryInterface 定義 email 變更 token 的資料存取契約
+type EmailChangeRepositoryInterface interface {
+	Create(ctx context.Context, token *models.EmailChangeToken) error
+	FindByTokenHash(ctx context.Context, tokenHash string) (*models.EmailChangeToken, error)
+	ConfirmEmailChange(ctx context.Context, tokenID, userID, newEmail string, at time.Time) (bool, error)
+	InvalidateAllForUser(ctx context.Context, userID string, at time.Time) error
+}
+
+// EmailChangeRepository email 變更 token 資料訪問層
+type EmailChangeRepository struct {
+	db *sql.DB
+}
+
+// NewEmailChangeRepository 創建 email 變更 token Repository
+func NewEmailChangeRepository(db *sql.DB) *EmailChangeRepository {
+	return &EmailChangeRepository{db: db}
+}
+
+// Create 儲存一筆新的 token（只存 hash）
+func (r *EmailChangeRepository) Create(ctx context.Context, token *models.EmailChangeToken) error {
+	query := `INSERT INTO email_change_tokens (id, user_id, new_email, token_hash, expires_at)
+	          VALUES ($1, $2, $3, $4, $5)
+	          RETURNING created_at`
+
+	err := r.db.QueryRowContext(ctx, query, token.ID, token.UserID, token.NewEmail, token.TokenHash, token.ExpiresAt).
+		Scan(&token.CreatedAt)
+	if err != nil {
+		return fmt.Errorf("failed to create email change token: %w", err)
+	}
+	return nil
+}
+
+// FindByTokenHash 根據 token hash 查找 token，找不到時回傳 nil, nil
+func (r *EmailChangeRepository) FindByTokenHash(ctx context.Context, tokenHash string) (*models.EmailChangeToken, error) {
+	var token models.EmailChangeToken
+	var usedAt sql.NullTime
+	query := `SELECT id, user_id, new_email, token_hash, expires_at, used_at, created_at
+	          FROM email_change_tokens WHERE token_hash = $1`
+
+	err := r.db.QueryRowContext(ctx, query, tokenHash).Scan(
+		&token.ID, &token.UserID, &token.NewEmail, &token.TokenHash,
+		&token.ExpiresAt, &usedAt, &token.CreatedAt,
+	)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("failed to find email change token: %w", err)
+	}
+	if usedAt.Valid {
+		token.UsedAt = &usedAt.Time
+	}
+	return &token, nil
+}
+
+// ConfirmEmailChange 消耗 token 並寫入新的 email，同時讓該用戶其餘的 token 失效，全部在同一個 transaction 內完成：
+// 新 email 已被別人使用時回傳 ErrDuplicateEmail，token 不會被消耗。
+// 以 used_at IS NULL 作為條件，併發時只有一個請求能成功消耗同一個 token；回傳 false 代表 token 早已被使用
+func (r *EmailChangeRepository) ConfirmEmailChange(ctx context.Context, tokenID, userID, newEmail string, at time.Time) (bool, error) {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return false, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	result, err := tx.ExecContext(ctx,
+		`UPDATE email_change_tokens SET used_at = $1 WHERE id = $2 AND used_at IS NULL`,
+		at, tokenID,
+	)
+	if err != nil {
+		return false, fmt.Errorf("failed to mark email change token as used: %w", err)
+	}
+	rows, err := result.RowsAffected()
+	if err != nil {
+		return false, fmt.Errorf("failed to get affected rows: %w", err)
+	}
+	if rows == 0 {
+		return false, nil
+	}
+
+	result, err = tx.ExecContext(ctx,
+		`UPDATE users SET email = $1, version = version + 1, updated_at = CURRENT_TIMESTAMP
+		 WHERE id = $2 AND deleted_at IS NULL`,
+		newEmail, userID,
+	)
+	if err != nil {
+		var pqErr *pq.Error
+		if errors.As(err, &pqErr) && pqErr.Code == pgUniqueViolation {
+			return false, ErrDuplicateEmail
+		}
+		return false, fmt.Errorf("failed to update email: %w", err)
+	}
+	if rows, err = result.RowsAffected(); err != nil {
+		return false, fmt.Errorf("failed to get affected rows: %w", err)
+	}
+	if rows == 0 {
+		return false, fmt.Errorf("user not found")
+	}
+
+	// 其他尚未確認的新 email 一併失效
+	if _, err := tx.ExecContext(ctx,
+		`UPDATE email_change_tokens SET used_at = $1 WHERE user_id = $2 AND used_at IS NULL`,
+		at, userID,
+	); err != nil {
+		return false, fmt.Errorf("failed to invalidate email change tokens: %w", err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return false, fmt.Errorf("failed to commit transaction: %w", err)
+	}
+	return true, nil
+}
+
+// InvalidateAllForUser 讓該用戶所有尚未使用的 token 失效
+// 重新申請或變更完成時呼叫，同一時間只會有一個待確認的新 email
+func (r *EmailChangeRepository) InvalidateAllForUser(ctx context.Context, userID string, at time.Time) error {
+	query := `UPDATE email_change_tokens SET used_at = $1 WHERE user_id = $2 AND used_at IS NULL`
+	if _, err := r.db.ExecContext(ctx, query, at, userID); err != nil {
+		return fmt.Errorf("failed to invalidate email change tokens: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,75 @@
+//go:build integration
+
+package repository
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"user-service/models"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// ===================================================================
+// EmailChangeRepository 測試
+// ===================================================================
+
+func TestEmailChangeRepository(t *testing.T) {
+	db := setupIntegrationDB(t)
+	ctx := context.Background()
+	userRepo := NewUserRepository(db)
+	user := &models.User{
+		ID:       "aaaaaaaa-aaaa-aaaa-aaaa-aaaaaaaaaaaa",
+		Email:    "emailchange@integration.test",
+		Username: "emailchange",
+		Password: "hashedpassword",
+	}
+	require.NoError(t, userRepo.Create(ctx, user))
+	repo := NewEmailChangeRepository(db)
+
+	token := &models.EmailChangeToken{
+		ID:        "bbbbbbbb-bbbb-bbbb-bbbb-bbbbbbbbbbbb",
+		UserID:    user.ID,
+		NewEmail:  "emailchange-new@integration.test",
+		TokenHash: "hash-email-change",
+		ExpiresAt: time.Now().Add(time.Hour),
+	}
+	require.NoError(t, repo.Create(ctx, token))
+
+	found, err := repo.FindByTokenHash(ctx, "hash-email-change")
+	require.NoError(t, err)
+	require.NotNil(t, found)
+	assert.Equal(t, "emailchange-new@integration.test", found.NewEmail)
+	assert.Nil(t, found.UsedAt)
+
+	// 新 email 已被別人使用：transaction 回滾，token 仍可再用
+	other := &models.User{
+		ID:       "cccccccc-cccc-cccc-cccc-cccccccccccc",
+		Email:    "emailchange-new@integration.test",
+		Username: "other",
+		Password: "hashedpassword",
+	}
+	require.NoError(t, userRepo.Create(ctx, other))
+	changed, err := repo.ConfirmEmailChange(ctx, token.ID, user.ID, token.NewEmail, time.Now())
+	assert.ErrorIs(t, err, ErrDuplicateEmail)
+	assert.False(t, changed)
+	found, err = repo.FindByTokenHash(ctx, "hash-email-change")
+	require.NoError(t, err)
+	assert.Nil(t, found.UsedAt)
+
+	// 對方刪除並清除帳號後，同一個 token 就能完成變更
+	_, err = db.ExecContext(ctx, `DELETE FROM users WHERE id = $1`, other.ID)
+	require.NoError(t, err)
+	changed, err = repo.ConfirmEmailChange(ctx, token.ID, user.ID, token.NewEmail, time.Now())
+	assert.NoError(t, err)
+	assert.True(t, changed)
+	updated, _ := userRepo.FindByID(ctx, user.ID)
+	assert.Equal(t, "emailchange-new@integration.test", updated.Email)
+
+	changed, err = repo.ConfirmEmailChange(ctx, token.ID, user.ID, token.NewEmail, time.Now())
+	assert.NoError(t, err)
+	assert.False(t, changed)
+}
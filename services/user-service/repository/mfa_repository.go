@@ -0,0 +1,163 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"time"
+
+	"user-service/models"
+)
+
+// MFARepositoryInterface 定義 TOTP 設定與復原碼的資料存取契約
+type MFARepositoryInterface interface {
+	FindByUserID(ctx context.Context, userID string) (*models.MFASettings, error)
+	SavePending(ctx context.Context, userID, secret string) error
+	Enable(ctx context.Context, userID string, confirmedAt time.Time, usedStep int64, recoveryCodeHashes []string) error
+	MarkStepUsed(ctx context.Context, userID string, step int64) (bool, error)
+	UseRecoveryCode(ctx context.Context, userID, codeHash string, usedAt time.Time) (bool, error)
+	Delete(ctx context.Context, userID string) error
+}
+
+// MFARepository MFA 資料訪問層
+type MFARepository struct {
+	db *sql.DB
+}
+
+// NewMFARepository 創建 MFA Repository
+func NewMFARepository(db *sql.DB) *MFARepository {
+	return &MFARepository{db: db}
+}
+
+// FindByUserID 查找用戶的 TOTP 設定，沒有設定時回傳 nil, nil
+func (r *MFARepository) FindByUserID(ctx context.Context, userID string) (*models.MFASettings, error) {
+	var settings models.MFASettings
+	var confirmedAt sql.NullTime
+	query := `SELECT user_id, secret, enabled, last_used_step, confirmed_at, created_at
+	          FROM user_mfa WHERE user_id = $1`
+
+	err := r.db.QueryRowContext(ctx, query, userID).Scan(
+		&settings.UserID, &settings.Secret, &settings.Enabled,
+		&settings.LastUsedStep, &confirmedAt, &settings.CreatedAt,
+	)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("failed to find mfa settings: %w", err)
+	}
+	if confirmedAt.Valid {
+		settings.ConfirmedAt = &confirmedAt.Time
+	}
+	return &settings, nil
+}
+
+// SavePending 儲存尚未確認的 secret，重複綁定時覆蓋前一次未完成的 secret
+// 已啟用的設定不會被覆蓋
+func (r *MFARepository) SavePending(ctx context.Context, userID, secret string) error {
+	query := `INSERT INTO user_mfa (user_id, secret, enabled, last_used_step)
+	          VALUES ($1, $2, FALSE, 0)
+	          ON CONFLICT (user_id) DO UPDATE
+	          SET secret = EXCLUDED.secret, last_used_step = 0, created_at = CURRENT_TIMESTAMP
+	          WHERE user_mfa.enabled = FALSE`
+
+	if _, err := r.db.ExecContext(ctx, query, userID, secret); err != nil {
+		return fmt.Errorf("failed to save mfa secret: %w", err)
+	}
+	return nil
+}
+
+// Enable 啟用 TOTP 並寫入新的復原碼（取代舊的），兩者在同一個 transaction 內完成
+func (r *MFARepository) Enable(ctx context.Context, userID string, confirmedAt time.Time, usedStep int64, recoveryCodeHashes []string) error {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	result, err := tx.ExecContext(ctx,
+		`UPDATE user_mfa SET enabled = TRUE, confirmed_at = $1, last_used_step = $2
+		 WHERE user_id = $3 AND enabled = FALSE`,
+		confirmedAt, usedStep, userID,
+	)
+	if err != nil {
+		return fmt.Errorf("failed to enable mfa: %w", err)
+	}
+	rows, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("failed to get affected rows: %w", err)
+	}
+	if rows == 0 {
+		return fmt.Errorf("mfa enrollment not found")
+	}
+
+	if _, err := tx.ExecContext(ctx, `DELETE FROM mfa_recovery_codes WHERE user_id = $1`, userID); err != nil {
+		return fmt.Errorf("failed to clear recovery codes: %w", err)
+	}
+	for _, hash := range recoveryCodeHashes {
+		if _, err := tx.ExecContext(ctx,
+			`INSERT INTO mfa_recovery_codes (user_id, code_hash) VALUES ($1, $2)`,
+			userID, hash,
+		); err != nil {
+			return fmt.Errorf("failed to save recovery code: %w", err)
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit transaction: %w", err)
+	}
+	return nil
+}
+
+// MarkStepUsed 記錄最後一次使用的時間區間
+// 只有比已記錄的區間更新時才會成功，回傳 false 代表這個 code 已被用過（重放）
+func (r *MFARepository) MarkStepUsed(ctx context.Context, userID string, step int64) (bool, error) {
+	query := `UPDATE user_mfa SET last_used_step = $1 WHERE user_id = $2 AND last_used_step < $1`
+	result, err := r.db.ExecContext(ctx, query, step, userID)
+	if err != nil {
+		return false, fmt.Errorf("failed to mark totp step as used: %w", err)
+	}
+
+	rows, err := result.RowsAffected()
+	if err != nil {
+		return false, fmt.Errorf("failed to get affected rows: %w", err)
+	}
+	return rows == 1, nil
+}
+
+// UseRecoveryCode 消耗一組復原碼，回傳 false 代表復原碼不存在或已使用
+func (r *MFARepository) UseRecoveryCode(ctx context.Context, userID, codeHash string, usedAt time.Time) (bool, error) {
+	query := `UPDATE mfa_recovery_codes SET used_at = $1
+	          WHERE user_id = $2 AND code_hash = $3 AND used_at IS NULL`
+	result, err := r.db.ExecContext(ctx, query, usedAt, userID, codeHash)
+	if err != nil {
+		return false, fmt.Errorf("failed to use recovery code: %w", err)
+	}
+
+	rows, err := result.RowsAffected()
+	if err != nil {
+		return false, fmt.Errorf("failed to get affected rows: %w", err)
+	}
+	return rows > 0, nil
+}
+
+// Delete 移除用戶的 TOTP 設定與所有復原碼（停用 MFA）
+func (r *MFARepository) Delete(ctx context.Context, userID string) error {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.ExecContext(ctx, `DELETE FROM mfa_recovery_codes WHERE user_id = $1`, userID); err != nil {
+		return fmt.Errorf("failed to delete recovery codes: %w", err)
+	}
+	if _, err := tx.ExecContext(ctx, `DELETE FROM user_mfa WHERE user_id = $1`, userID); err != nil {
+		return fmt.Errorf("failed to delete mfa settings: %w", err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit transaction: %w", err)
+	}
+	return nil
+}
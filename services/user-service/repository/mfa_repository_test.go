@@ -0,0 +1,75 @@
+//go:build integration
+
+package repository
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"user-service/models"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// ===================================================================
+// MFARepository 測試
+// ===================================================================
+
+func TestMFARepository_EnrollmentLifecycle(t *testing.T) {
+	db := setupIntegrationDB(t)
+	ctx := context.Background()
+	userRepo := NewUserRepository(db)
+	repo := NewMFARepository(db)
+
+	user := &models.User{
+		ID:       "aaaaaaaa-aaaa-aaaa-aaaa-aaaaaaaaaaaa",
+		Email:    "mfa@integration.test",
+		Username: "mfauser",
+		Password: "hashedpassword",
+	}
+	require.NoError(t, userRepo.Create(ctx, user))
+
+	// 尚未綁定
+	settings, err := repo.FindByUserID(ctx, user.ID)
+	assert.NoError(t, err)
+	assert.Nil(t, settings)
+
+	// 產生 secret，但還沒確認
+	require.NoError(t, repo.SavePending(ctx, user.ID, "SECRETONE"))
+	settings, err = repo.FindByUserID(ctx, user.ID)
+	require.NoError(t, err)
+	assert.False(t, settings.Enabled)
+
+	// 確認後啟用，用戶資料上的 mfa_enabled 也跟著變成 true
+	require.NoError(t, repo.Enable(ctx, user.ID, time.Now(), 100, []string{"hash-a", "hash-b"}))
+	found, _ := userRepo.FindByEmail(ctx, user.Email)
+	assert.True(t, found.MFAEnabled)
+
+	// 已啟用後再 SavePending 不會覆蓋 secret
+	require.NoError(t, repo.SavePending(ctx, user.ID, "SECRETTWO"))
+	settings, _ = repo.FindByUserID(ctx, user.ID)
+	assert.Equal(t, "SECRETONE", settings.Secret)
+
+	// 同一個時間區間不能用兩次
+	fresh, err := repo.MarkStepUsed(ctx, user.ID, 100)
+	assert.NoError(t, err)
+	assert.False(t, fresh)
+	fresh, err = repo.MarkStepUsed(ctx, user.ID, 101)
+	assert.NoError(t, err)
+	assert.True(t, fresh)
+
+	// 復原碼只能用一次
+	used, err := repo.UseRecoveryCode(ctx, user.ID, "hash-a", time.Now())
+	assert.NoError(t, err)
+	assert.True(t, used)
+	used, err = repo.UseRecoveryCode(ctx, user.ID, "hash-a", time.Now())
+	assert.NoError(t, err)
+	assert.False(t, used)
+
+	// 停用
+	require.NoError(t, repo.Delete(ctx, user.ID))
+	settings, _ = repo.FindByUserID(ctx, user.ID)
+	assert.Nil(t, settings)
+}
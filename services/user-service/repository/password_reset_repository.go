@@ -0,0 +1,118 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"time"
+
+	"user-service/models"
+)
+
+// PasswordResetRepositoryInterface 定義重設密碼 token 的資料存取契約
+type PasswordResetRepositoryInterface interface {
+	Create(ctx context.Context, token *models.PasswordResetToken) error
+	FindByTokenHash(ctx context.Context, tokenHash string) (*models.PasswordResetToken, error)
+	ResetPassword(ctx context.Context, tokenID, userID, hashedPassword string, at time.Time) (bool, error)
+}
+
+// PasswordResetRepository 重設密碼 token 資料訪問層
+type PasswordResetRepository struct {
+	db *sql.DB
+}
+
+// NewPasswordResetRepository 創建重設密碼 token Repository
+func NewPasswordResetRepository(db *sql.DB) *PasswordResetRepository {
+	return &PasswordResetRepository{db: db}
+}
+
+// Create 儲存一筆新的 token（只存 hash）
+func (r *PasswordResetRepository) Create(ctx context.Context, token *models.PasswordResetToken) error {
+	query := `INSERT INTO password_reset_tokens (id, user_id, token_hash, expires_at)
+	          VALUES ($1, $2, $3, $4)
+	          RETURNING created_at`
+
+	err := r.db.QueryRowContext(ctx, query, token.ID, token.UserID, token.TokenHash, token.ExpiresAt).
+		Scan(&token.CreatedAt)
+	if err != nil {
+		return fmt.Errorf("failed to create reset token: %w", err)
+	}
+	return nil
+}
+
+// FindByTokenHash 根據 token hash 查找 token，找不到時回傳 nil, nil
+func (r *PasswordResetRepository) FindByTokenHash(ctx context.Context, tokenHash string) (*models.PasswordResetToken, error) {
+	var token models.PasswordResetToken
+	var usedAt sql.NullTime
+	query := `SELECT id, user_id, token_hash, expires_at, used_at, created_at
+	          FROM password_reset_tokens WHERE token_hash = $1`
+
+	err := r.db.QueryRowContext(ctx, query, tokenHash).Scan(
+		&token.ID, &token.UserID, &token.TokenHash,
+		&token.ExpiresAt, &usedAt, &token.CreatedAt,
+	)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("failed to find reset token: %w", err)
+	}
+	if usedAt.Valid {
+		token.UsedAt = &usedAt.Time
+	}
+	return &token, nil
+}
+
+// ResetPassword 消耗 token 並寫入新密碼，同時讓該用戶其餘的 token 失效，全部在同一個 transaction 內完成：
+// 寫入密碼失敗時 token 不會被消耗，用戶可以用同一個連結再試一次。
+// 以 used_at IS NULL 作為條件，併發時只有一個請求能成功消耗同一個 token；回傳 false 代表 token 早已被使用
+func (r *PasswordResetRepository) ResetPassword(ctx context.Context, tokenID, userID, hashedPassword string, at time.Time) (bool, error) {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return false, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	result, err := tx.ExecContext(ctx,
+		`UPDATE password_reset_tokens SET used_at = $1 WHERE id = $2 AND used_at IS NULL`,
+		at, tokenID,
+	)
+	if err != nil {
+		return false, fmt.Errorf("failed to mark reset token as used: %w", err)
+	}
+	rows, err := result.RowsAffected()
+	if err != nil {
+		return false, fmt.Errorf("failed to get affected rows: %w", err)
+	}
+	if rows == 0 {
+		return false, nil
+	}
+
+	result, err = tx.ExecContext(ctx,
+		`UPDATE users SET password = $1, version = version + 1, updated_at = CURRENT_TIMESTAMP
+		 WHERE id = $2 AND deleted_at IS NULL`,
+		hashedPassword, userID,
+	)
+	if err != nil {
+		return false, fmt.Errorf("failed to update password: %w", err)
+	}
+	if rows, err = result.RowsAffected(); err != nil {
+		return false, fmt.Errorf("failed to get affected rows: %w", err)
+	}
+	if rows == 0 {
+		return false, fmt.Errorf("user not found")
+	}
+
+	// 先前寄出的其他連結一併失效
+	if _, err := tx.ExecContext(ctx,
+		`UPDATE password_reset_tokens SET used_at = $1 WHERE user_id = $2 AND used_at IS NULL`,
+		at, userID,
+	); err != nil {
+		return false, fmt.Errorf("failed to invalidate reset tokens: %w", err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return false, fmt.Errorf("failed to commit transaction: %w", err)
+	}
+	return true, nil
+}
@@ -0,0 +1,125 @@
+//go:build integration
+
+package repository
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"user-service/models"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// createResetTestUser 建立重設密碼 token 所屬的用戶（token 有 FK 指向 users）
+func createResetTestUser(t *testing.T, userRepo *UserRepository) *models.User {
+	ctx := context.Background()
+	t.Helper()
+	user := &models.User{
+		ID:       "77777777-7777-7777-7777-777777777777",
+		Email:    "reset@integration.test",
+		Username: "resetuser",
+		Password: "hashedpassword",
+	}
+	require.NoError(t, userRepo.Create(ctx, user))
+	return user
+}
+
+// ===================================================================
+// PasswordResetRepository 測試
+// ===================================================================
+
+func TestPasswordResetRepository_CreateAndFind(t *testing.T) {
+	db := setupIntegrationDB(t)
+	ctx := context.Background()
+	user := createResetTestUser(t, NewUserRepository(db))
+	repo := NewPasswordResetRepository(db)
+
+	token := &models.PasswordResetToken{
+		ID:        "88888888-8888-8888-8888-888888888888",
+		UserID:    user.ID,
+		TokenHash: "hash-create-and-find",
+		ExpiresAt: time.Now().Add(time.Hour),
+	}
+	require.NoError(t, repo.Create(ctx, token))
+	assert.False(t, token.CreatedAt.IsZero())
+
+	found, err := repo.FindByTokenHash(ctx, "hash-create-and-find")
+
+	assert.NoError(t, err)
+	require.NotNil(t, found)
+	assert.Equal(t, user.ID, found.UserID)
+	assert.Nil(t, found.UsedAt)
+
+	missing, err := repo.FindByTokenHash(ctx, "no-such-hash")
+	assert.NoError(t, err)
+	assert.Nil(t, missing)
+}
+
+func TestPasswordResetRepository_ResetPassword(t *testing.T) {
+	db := setupIntegrationDB(t)
+	ctx := context.Background()
+	userRepo := NewUserRepository(db)
+	user := createResetTestUser(t, userRepo)
+	repo := NewPasswordResetRepository(db)
+
+	token := &models.PasswordResetToken{
+		ID:        "99999999-9999-9999-9999-999999999999",
+		UserID:    user.ID,
+		TokenHash: "hash-reset-password",
+		ExpiresAt: time.Now().Add(time.Hour),
+	}
+	other := &models.PasswordResetToken{
+		ID:        "99999999-9999-9999-9999-999999999990",
+		UserID:    user.ID,
+		TokenHash: "hash-reset-password-other",
+		ExpiresAt: time.Now().Add(time.Hour),
+	}
+	require.NoError(t, repo.Create(ctx, token))
+	require.NoError(t, repo.Create(ctx, other))
+
+	// 第一次成功：密碼寫入，這個 token 與其他 token 都被標記為已使用
+	consumed, err := repo.ResetPassword(ctx, token.ID, user.ID, "newhash", time.Now())
+	assert.NoError(t, err)
+	assert.True(t, consumed)
+
+	updated, err := userRepo.FindByID(ctx, user.ID)
+	require.NoError(t, err)
+	assert.Equal(t, "newhash", updated.Password)
+	found, _ := repo.FindByTokenHash(ctx, "hash-reset-password")
+	assert.NotNil(t, found.UsedAt)
+	found, _ = repo.FindByTokenHash(ctx, "hash-reset-password-other")
+	assert.NotNil(t, found.UsedAt)
+
+	// 第二次代表 token 已被用過
+	consumed, err = repo.ResetPassword(ctx, token.ID, user.ID, "otherhash", time.Now())
+	assert.NoError(t, err)
+	assert.False(t, consumed)
+}
+
+func TestPasswordResetRepository_ResetPassword_FailedUpdateKeepsToken(t *testing.T) {
+	db := setupIntegrationDB(t)
+	ctx := context.Background()
+	userRepo := NewUserRepository(db)
+	user := createResetTestUser(t, userRepo)
+	repo := NewPasswordResetRepository(db)
+
+	token := &models.PasswordResetToken{
+		ID:        "99999999-9999-9999-9999-999999999991",
+		UserID:    user.ID,
+		TokenHash: "hash-reset-password-rollback",
+		ExpiresAt: time.Now().Add(time.Hour),
+	}
+	require.NoError(t, repo.Create(ctx, token))
+
+	// 寫入密碼失敗（用戶 ID 不存在）時整個 transaction rollback，token 不會被消耗
+	consumed, err := repo.ResetPassword(ctx, token.ID, "00000000-0000-0000-0000-000000000000", "newhash", time.Now())
+	assert.Error(t, err)
+	assert.False(t, consumed)
+
+	found, _ := repo.FindByTokenHash(ctx, "hash-reset-password-rollback")
+	require.NotNil(t, found)
+	assert.Nil(t, found.UsedAt)
+}
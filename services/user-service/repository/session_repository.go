@@ -0,0 +1,104 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"time"
+
+	"user-service/models"
+)
+
+// SessionRepositoryInterface 定義登入 session 的資料存取契約
+type SessionRepositoryInterface interface {
+	Create(ctx context.Context, session *models.Session) error
+	FindByUserID(ctx context.Context, userID string, limit int) ([]models.Session, error)
+	Revoke(ctx context.Context, userID, sessionID string, at time.Time) (*models.Session, error)
+	RevokeAllForUser(ctx context.Context, userID string, at time.Time) error
+}
+
+// SessionRepository 登入 session 資料訪問層
+type SessionRepository struct {
+	db *sql.DB
+}
+
+// NewSessionRepository 創建 session Repository
+func NewSessionRepository(db *sql.DB) *SessionRepository {
+	return &SessionRepository{db: db}
+}
+
+// Create 記錄一次成功的登入
+func (r *SessionRepository) Create(ctx context.Context, session *models.Session) error {
+	query := `INSERT INTO user_sessions (id, user_id, ip, user_agent, created_at, expires_at)
+	          VALUES ($1, $2, $3, $4, $5, $6)`
+
+	_, err := r.db.ExecContext(ctx, query, session.ID, session.UserID, session.IP, session.UserAgent, session.CreatedAt, session.ExpiresAt)
+	if err != nil {
+		return fmt.Errorf("failed to create session: %w", err)
+	}
+	return nil
+}
+
+// FindByUserID 取得用戶最近的 limit 筆登入紀錄（含已過期與已撤銷），由新到舊排列
+func (r *SessionRepository) FindByUserID(ctx context.Context, userID string, limit int) ([]models.Session, error) {
+	query := `SELECT id, user_id, ip, user_agent, created_at, expires_at, revoked_at
+	          FROM user_sessions WHERE user_id = $1
+	          ORDER BY created_at DESC LIMIT $2`
+
+	rows, err := r.db.QueryContext(ctx, query, userID, limit)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query sessions: %w", err)
+	}
+	defer rows.Close()
+
+	sessions := []models.Session{}
+	for rows.Next() {
+		var session models.Session
+		var revokedAt sql.NullTime
+		err := rows.Scan(&session.ID, &session.UserID, &session.IP, &session.UserAgent,
+			&session.CreatedAt, &session.ExpiresAt, &revokedAt)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan session: %w", err)
+		}
+		if revokedAt.Valid {
+			session.RevokedAt = &revokedAt.Time
+		}
+		sessions = append(sessions, session)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to query sessions: %w", err)
+	}
+
+	return sessions, nil
+}
+
+// Revoke 撤銷用戶自己的一個 session，回傳被撤銷的 session
+// session 不存在、不屬於該用戶或早已撤銷時回傳 nil, nil
+func (r *SessionRepository) Revoke(ctx context.Context, userID, sessionID string, at time.Time) (*models.Session, error) {
+	var session models.Session
+	query := `UPDATE user_sessions SET revoked_at = $1
+	          WHERE id = $2 AND user_id = $3 AND revoked_at IS NULL
+	          RETURNING id, user_id, ip, user_agent, created_at, expires_at`
+
+	err := r.db.QueryRowContext(ctx, query, at, sessionID, userID).Scan(
+		&session.ID, &session.UserID, &session.IP, &session.UserAgent, &session.CreatedAt, &session.ExpiresAt,
+	)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("failed to revoke session: %w", err)
+	}
+	session.RevokedAt = &at
+	return &session, nil
+}
+
+// RevokeAllForUser 將該用戶所有尚未撤銷的 session 標記為已撤銷
+// 與 TokenRevocationRepository.RevokeAllForUser 搭配使用，讓 session 列表與實際可用的 token 一致
+func (r *SessionRepository) RevokeAllForUser(ctx context.Context, userID string, at time.Time) error {
+	query := `UPDATE user_sessions SET revoked_at = $1 WHERE user_id = $2 AND revoked_at IS NULL`
+	if _, err := r.db.ExecContext(ctx, query, at, userID); err != nil {
+		return fmt.Errorf("failed to revoke sessions: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,71 @@
+//go:build integration
+
+package repository
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"user-service/models"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// ===================================================================
+// SessionRepository 測試
+// ===================================================================
+
+func TestSessionRepository(t *testing.T) {
+	db := setupIntegrationDB(t)
+	ctx := context.Background()
+	userRepo := NewUserRepository(db)
+	user := &models.User{
+		ID:       "dddddddd-dddd-dddd-dddd-dddddddddddd",
+		Email:    "sessions@integration.test",
+		Username: "sessions",
+		Password: "hashedpassword",
+	}
+	require.NoError(t, userRepo.Create(ctx, user))
+	repo := NewSessionRepository(db)
+
+	now := time.Now().UTC().Truncate(time.Microsecond)
+	older := &models.Session{
+		ID: "eeeeeeee-eeee-eeee-eeee-eeeeeeeeeeee", UserID: user.ID,
+		IP: "203.0.113.7", UserAgent: "Firefox", CreatedAt: now.Add(-time.Hour), ExpiresAt: now.Add(time.Hour),
+	}
+	newer := &models.Session{
+		ID: "ffffffff-ffff-ffff-ffff-ffffffffffff", UserID: user.ID,
+		IP: "198.51.100.1", UserAgent: "Safari", CreatedAt: now, ExpiresAt: now.Add(2 * time.Hour),
+	}
+	require.NoError(t, repo.Create(ctx, older))
+	require.NoError(t, repo.Create(ctx, newer))
+
+	sessions, err := repo.FindByUserID(ctx, user.ID, 10)
+	require.NoError(t, err)
+	require.Len(t, sessions, 2)
+	assert.Equal(t, newer.ID, sessions[0].ID)
+	assert.Equal(t, "Safari", sessions[0].UserAgent)
+
+	// 別人的 session 不能撤銷
+	revoked, err := repo.Revoke(ctx, "00000000-0000-0000-0000-000000000000", older.ID, now)
+	assert.NoError(t, err)
+	assert.Nil(t, revoked)
+
+	revoked, err = repo.Revoke(ctx, user.ID, older.ID, now)
+	require.NoError(t, err)
+	require.NotNil(t, revoked)
+	assert.True(t, revoked.ExpiresAt.Equal(older.ExpiresAt))
+
+	// 重複撤銷視為找不到
+	revoked, err = repo.Revoke(ctx, user.ID, older.ID, now)
+	assert.NoError(t, err)
+	assert.Nil(t, revoked)
+
+	require.NoError(t, repo.RevokeAllForUser(ctx, user.ID, now))
+	sessions, _ = repo.FindByUserID(ctx, user.ID, 10)
+	for _, s := range sessions {
+		assert.NotNil(t, s.RevokedAt)
+	}
+}
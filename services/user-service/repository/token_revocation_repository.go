@@ -0,0 +1,66 @@
+package repository
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// RevokedBeforeKeyPrefix 是 Redis 中記錄「此時間點之前簽發的 token 一律失效」的 key 前綴
+// API Gateway 驗證 JWT 時會讀取同一個 key，兩邊必須保持一致
+const RevokedBeforeKeyPrefix = "auth:revoked_before:"
+
+// RevokedSessionKeyPrefix 是 Redis 中記錄單一 session 已被撤銷的 key 前綴，
+// 對應 JWT 的 sid claim，API Gateway 同樣會讀取
+const RevokedSessionKeyPrefix = "auth:revoked_session:"
+
+// TokenRevocationRepositoryInterface 定義撤銷已簽發 JWT 的契約
+type TokenRevocationRepositoryInterface interface {
+	RevokeAllForUser(ctx context.Context, userID string, before time.Time) error
+	RevokeSession(ctx context.Context, sessionID string, expiresAt time.Time) error
+}
+
+// TokenRevocationRepository 以 Redis 記錄 token 撤銷狀態
+type TokenRevocationRepository struct {
+	client *redis.Client
+	ttl    time.Duration
+}
+
+// NewTokenRevocationRepository 創建 token 撤銷 Repository
+// ttl 應大於等於 JWT 的有效期限，過了這段時間舊 token 本身也已過期，紀錄即可清除
+func NewTokenRevocationRepository(client *redis.Client, ttl time.Duration) *TokenRevocationRepository {
+	return &TokenRevocationRepository{client: client, ttl: ttl}
+}
+
+// RevokeAllForUser 讓該用戶在 before 之前（含）簽發的所有 token 失效
+// 撤銷時間點與 JWT 的 iat 同樣精確到微秒，API Gateway 以 iat <= 撤銷時間點判斷，
+// 撤銷後同一秒內重新登入拿到的 token 不受影響
+func (r *TokenRevocationRepository) RevokeAllForUser(ctx context.Context, userID string, before time.Time) error {
+	key := RevokedBeforeKeyPrefix + userID
+	value := formatRevokedBefore(before)
+	if err := r.client.Set(ctx, key, value, r.ttl).Err(); err != nil {
+		return fmt.Errorf("failed to revoke tokens: %w", err)
+	}
+	return nil
+}
+
+// formatRevokedBefore 將撤銷時間點格式化為「秒.微秒」，例如 1704110400.123456
+// 不用浮點數，避免 gateway 解析時失去微秒的精確度
+func formatRevokedBefore(t time.Time) string {
+	return fmt.Sprintf("%d.%06d", t.Unix(), t.Nanosecond()/int(time.Microsecond))
+}
+
+// RevokeSession 讓單一 session 的 token 失效
+// 紀錄保留到 session 原本的到期時間，之後 token 本身也已過期
+func (r *TokenRevocationRepository) RevokeSession(ctx context.Context, sessionID string, expiresAt time.Time) error {
+	ttl := time.Until(expiresAt)
+	if ttl <= 0 {
+		return nil
+	}
+	if err := r.client.Set(ctx, RevokedSessionKeyPrefix+sessionID, "1", ttl).Err(); err != nil {
+		return fmt.Errorf("failed to revoke session: %w", err)
+	}
+	return nil
+}
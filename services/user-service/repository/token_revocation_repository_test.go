@@ -0,0 +1,26 @@
+package repository
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestFormatRevokedBefore(t *testing.T) {
+	tests := []struct {
+		name string
+		at   time.Time
+		want string
+	}{
+		{name: "whole second", at: time.Unix(1704110400, 0), want: "1704110400.000000"},
+		{name: "microseconds", at: time.Unix(1704110400, 123456000), want: "1704110400.123456"},
+		{name: "nanoseconds are truncated", at: time.Unix(1704110400, 999999999), want: "1704110400.999999"},
+		{name: "leading zeros", at: time.Unix(1704110400, 42000), want: "1704110400.000042"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, formatRevokedBefore(tt.at))
+		})
+	}
+}
@@ -1,20 +1,41 @@
 package repository
 
 import (
+	"context"
 	"database/sql"
+	"errors"
 	"fmt"
+	"strings"
+	"time"
 
+	"github.com/lib/pq"
 	"user-service/models"
 )
 
+// pgUniqueViolation PostgreSQL unique constraint 違反的錯誤碼
+const pgUniqueViolation = "23505"
+
+// AnyVersion 傳給 Update / Delete 的 expectedVersion，代表不檢查版本
+const AnyVersion = 0
+
+// ErrDuplicateEmail email 已被其他用戶（包含尚未清除的已刪除用戶）使用
+var ErrDuplicateEmail = errors.New("email already exists")
+
+// ErrVersionConflict 用戶存在，但版本已被其他請求更新
+var ErrVersionConflict = errors.New("user version conflict")
+
 // UserRepositoryInterface 定義 repository 層的契約，讓 service 層依賴 interface 而非具體實作
 type UserRepositoryInterface interface {
-	Create(user *models.User) error
-	FindByEmail(email string) (*models.User, error)
-	FindByID(id string) (*models.User, error)
-	FindAll() ([]models.User, error)
-	Update(id string, username string) error
-	Delete(id string) error
+	Create(ctx context.Context, user *models.User) error
+	FindByEmail(ctx context.Context, email string) (*models.User, error)
+	FindByID(ctx context.Context, id string) (*models.User, error)
+	FindAll(ctx context.Context) ([]models.User, error)
+	Update(ctx context.Context, id string, username string, expectedVersion int) error
+	Patch(ctx context.Context, id string, changes models.UserChanges, expectedVersion int) error
+	UpdatePassword(ctx context.Context, id string, hashedPassword string) error
+	Delete(ctx context.Context, id string, expectedVersion int) error
+	Restore(ctx context.Context, id string) (bool, error)
+	PurgeDeleted(ctx context.Context, retention time.Duration) (int64, error)
 }
 
 // UserRepository 用戶資料訪問層
@@ -28,30 +49,50 @@ func NewUserRepository(db *sql.DB) *UserRepository {
 }
 
 // Create 創建用戶
-func (r *UserRepository) Create(user *models.User) error {
-	query := `INSERT INTO users (id, email, username, password)
-	          VALUES ($1, $2, $3, $4)
-	          RETURNING created_at, updated_at`
+func (r *UserRepository) Create(ctx context.Context, user *models.User) error {
+	query := `INSERT INTO users (id, email, username, password, role)
+	          VALUES ($1, $2, $3, $4, $5)
+	          RETURNING version, created_at, updated_at`
 
-	err := r.db.QueryRow(query, user.ID, user.Email, user.Username, user.Password).
-		Scan(&user.CreatedAt, &user.UpdatedAt)
+	if user.Role == "" {
+		user.Role = models.RoleUser
+	}
+	err := r.db.QueryRowContext(ctx, query, user.ID, user.Email, user.Username, user.Password, user.Role).
+		Scan(&user.Version, &user.CreatedAt, &user.UpdatedAt)
 
 	if err != nil {
+		// 已軟刪除但尚未清除的帳號仍佔用 email
+		var pqErr *pq.Error
+		if errors.As(err, &pqErr) && pqErr.Code == pgUniqueViolation {
+			return ErrDuplicateEmail
+		}
 		return fmt.Errorf("failed to create user: %w", err)
 	}
 	return nil
 }
 
+// userColumns 查詢單一用戶時的欄位，順序需與 userFields 一致
+const userColumns = `u.id, u.email, u.username, u.password, u.role,
+	u.display_name, u.bio, u.avatar_url, u.locale, u.timezone,
+	COALESCE(m.enabled, FALSE), u.version, u.created_at, u.updated_at`
+
+// userFields 回傳 Scan 用的欄位指標，對應 userColumns
+func userFields(user *models.User) []interface{} {
+	return []interface{}{
+		&user.ID, &user.Email, &user.Username, &user.Password, &user.Role,
+		&user.DisplayName, &user.Bio, &user.AvatarURL, &user.Locale, &user.Timezone,
+		&user.MFAEnabled, &user.Version, &user.CreatedAt, &user.UpdatedAt,
+	}
+}
+
 // FindByEmail 根據 email 查找用戶
-func (r *UserRepository) FindByEmail(email string) (*models.User, error) {
+func (r *UserRepository) FindByEmail(ctx context.Context, email string) (*models.User, error) {
 	var user models.User
-	query := `SELECT id, email, username, password, created_at, updated_at
-	          FROM users WHERE email = $1`
+	query := `SELECT ` + userColumns + `
+	          FROM users u LEFT JOIN user_mfa m ON m.user_id = u.id
+	          WHERE u.email = $1 AND u.deleted_at IS NULL`
 
-	err := r.db.QueryRow(query, email).Scan(
-		&user.ID, &user.Email, &user.Username, &user.Password,
-		&user.CreatedAt, &user.UpdatedAt,
-	)
+	err := r.db.QueryRowContext(ctx, query, email).Scan(userFields(&user)...)
 
 	if err != nil {
 		if err == sql.ErrNoRows {
@@ -63,15 +104,13 @@ func (r *UserRepository) FindByEmail(email string) (*models.User, error) {
 }
 
 // FindByID 根據 ID 查找用戶
-func (r *UserRepository) FindByID(id string) (*models.User, error) {
+func (r *UserRepository) FindByID(ctx context.Context, id string) (*models.User, error) {
 	var user models.User
-	query := `SELECT id, email, username, created_at, updated_at
-	          FROM users WHERE id = $1`
+	query := `SELECT ` + userColumns + `
+	          FROM users u LEFT JOIN user_mfa m ON m.user_id = u.id
+	          WHERE u.id = $1 AND u.deleted_at IS NULL`
 
-	err := r.db.QueryRow(query, id).Scan(
-		&user.ID, &user.Email, &user.Username,
-		&user.CreatedAt, &user.UpdatedAt,
-	)
+	err := r.db.QueryRowContext(ctx, query, id).Scan(userFields(&user)...)
 
 	if err != nil {
 		if err == sql.ErrNoRows {
@@ -83,9 +122,11 @@ func (r *UserRepository) FindByID(id string) (*models.User, error) {
 }
 
 // FindAll 獲取所有用戶
-func (r *UserRepository) FindAll() ([]models.User, error) {
-	query := `SELECT id, email, username, created_at, updated_at FROM users`
-	rows, err := r.db.Query(query)
+func (r *UserRepository) FindAll(ctx context.Context) ([]models.User, error) {
+	query := `SELECT ` + userColumns + `
+	          FROM users u LEFT JOIN user_mfa m ON m.user_id = u.id
+	          WHERE u.deleted_at IS NULL`
+	rows, err := r.db.QueryContext(ctx, query)
 	if err != nil {
 		return nil, fmt.Errorf("failed to query users: %w", err)
 	}
@@ -94,24 +135,87 @@ func (r *UserRepository) FindAll() ([]models.User, error) {
 	var users []models.User
 	for rows.Next() {
 		var user models.User
-		err := rows.Scan(&user.ID, &user.Email, &user.Username, &user.CreatedAt, &user.UpdatedAt)
-		if err != nil {
+		if err := rows.Scan(userFields(&user)...); err != nil {
 			continue
 		}
+		// 列表不需要密碼 hash，不讓它在記憶體中多停留
+		user.Password = ""
 		users = append(users, user)
 	}
 
 	return users, nil
 }
 
-// Update 更新用戶
-func (r *UserRepository) Update(id string, username string) error {
-	query := `UPDATE users SET username = $1, updated_at = CURRENT_TIMESTAMP WHERE id = $2`
-	result, err := r.db.Exec(query, username, id)
+// Update 更新用戶，expectedVersion 不是 AnyVersion 時只在版本相符時更新
+func (r *UserRepository) Update(ctx context.Context, id string, username string, expectedVersion int) error {
+	query := `UPDATE users SET username = $1, version = version + 1, updated_at = CURRENT_TIMESTAMP
+	          WHERE id = $2 AND deleted_at IS NULL AND ($3 = 0 OR version = $3)`
+	result, err := r.db.ExecContext(ctx, query, username, id, expectedVersion)
 	if err != nil {
 		return fmt.Errorf("failed to update user: %w", err)
 	}
 
+	rows, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("failed to get affected rows: %w", err)
+	}
+	if rows == 0 {
+		return r.notUpdatedError(ctx, id, expectedVersion)
+	}
+
+	return nil
+}
+
+// Patch 只更新 changes 中有指定的欄位，expectedVersion 不是 AnyVersion 時只在版本相符時更新
+func (r *UserRepository) Patch(ctx context.Context, id string, changes models.UserChanges, expectedVersion int) error {
+	var sets []string
+	var args []interface{}
+	set := func(column string, value *string) {
+		if value == nil {
+			return
+		}
+		args = append(args, *value)
+		sets = append(sets, fmt.Sprintf("%s = $%d", column, len(args)))
+	}
+	set("username", changes.Username)
+	set("display_name", changes.DisplayName)
+	set("bio", changes.Bio)
+	set("avatar_url", changes.AvatarURL)
+	set("locale", changes.Locale)
+	set("timezone", changes.Timezone)
+	if len(sets) == 0 {
+		return nil
+	}
+
+	args = append(args, id, expectedVersion)
+	query := fmt.Sprintf(`UPDATE users SET %s, version = version + 1, updated_at = CURRENT_TIMESTAMP
+	          WHERE id = $%d AND deleted_at IS NULL AND ($%d = 0 OR version = $%d)`,
+		strings.Join(sets, ", "), len(args)-1, len(args), len(args))
+	result, err := r.db.ExecContext(ctx, query, args...)
+	if err != nil {
+		return fmt.Errorf("failed to update user: %w", err)
+	}
+
+	rows, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("failed to get affected rows: %w", err)
+	}
+	if rows == 0 {
+		return r.notUpdatedError(ctx, id, expectedVersion)
+	}
+
+	return nil
+}
+
+// UpdatePassword 更新用戶密碼（傳入的必須是已 hash 過的密碼）
+func (r *UserRepository) UpdatePassword(ctx context.Context, id string, hashedPassword string) error {
+	query := `UPDATE users SET password = $1, version = version + 1, updated_at = CURRENT_TIMESTAMP
+	          WHERE id = $2 AND deleted_at IS NULL`
+	result, err := r.db.ExecContext(ctx, query, hashedPassword, id)
+	if err != nil {
+		return fmt.Errorf("failed to update password: %w", err)
+	}
+
 	rows, err := result.RowsAffected()
 	if err != nil {
 		return fmt.Errorf("failed to get affected rows: %w", err)
@@ -123,10 +227,12 @@ func (r *UserRepository) Update(id string, username string) error {
 	return nil
 }
 
-// Delete 刪除用戶
-func (r *UserRepository) Delete(id string) error {
-	query := `DELETE FROM users WHERE id = $1`
-	result, err := r.db.Exec(query, id)
+// Delete 軟刪除用戶：標記 deleted_at，資料保留到 PurgeDeleted 清除為止
+// expectedVersion 不是 AnyVersion 時只在版本相符時刪除
+func (r *UserRepository) Delete(ctx context.Context, id string, expectedVersion int) error {
+	query := `UPDATE users SET deleted_at = CURRENT_TIMESTAMP, version = version + 1
+	          WHERE id = $1 AND deleted_at IS NULL AND ($2 = 0 OR version = $2)`
+	result, err := r.db.ExecContext(ctx, query, id, expectedVersion)
 	if err != nil {
 		return fmt.Errorf("failed to delete user: %w", err)
 	}
@@ -136,8 +242,59 @@ func (r *UserRepository) Delete(id string) error {
 		return fmt.Errorf("failed to get affected rows: %w", err)
 	}
 	if rows == 0 {
-		return fmt.Errorf("user not found")
+		return r.notUpdatedError(ctx, id, expectedVersion)
 	}
 
 	return nil
 }
+
+// notUpdatedError 條件式更新沒有影響任何資料時，區分是用戶不存在還是版本不符
+func (r *UserRepository) notUpdatedError(ctx context.Context, id string, expectedVersion int) error {
+	if expectedVersion == AnyVersion {
+		return fmt.Errorf("user not found")
+	}
+
+	var exists bool
+	query := `SELECT EXISTS(SELECT 1 FROM users WHERE id = $1 AND deleted_at IS NULL)`
+	if err := r.db.QueryRowContext(ctx, query, id).Scan(&exists); err != nil {
+		return fmt.Errorf("failed to check user version: %w", err)
+	}
+	if !exists {
+		return fmt.Errorf("user not found")
+	}
+	return ErrVersionConflict
+}
+
+// Restore 還原已軟刪除的用戶，找不到已刪除的用戶時回傳 false
+func (r *UserRepository) Restore(ctx context.Context, id string) (bool, error) {
+	query := `UPDATE users SET deleted_at = NULL, version = version + 1, updated_at = CURRENT_TIMESTAMP
+	          WHERE id = $1 AND deleted_at IS NOT NULL`
+	result, err := r.db.ExecContext(ctx, query, id)
+	if err != nil {
+		return false, fmt.Errorf("failed to restore user: %w", err)
+	}
+
+	rows, err := result.RowsAffected()
+	if err != nil {
+		return false, fmt.Errorf("failed to get affected rows: %w", err)
+	}
+	return rows > 0, nil
+}
+
+// PurgeDeleted 永久刪除軟刪除超過 retention 的用戶，回傳刪除筆數
+// 關聯資料（重設 token、MFA 設定）由 ON DELETE CASCADE 一併清除，email 也因此可以重新註冊
+func (r *UserRepository) PurgeDeleted(ctx context.Context, retention time.Duration) (int64, error) {
+	query := `DELETE FROM users
+	          WHERE deleted_at IS NOT NULL
+	            AND deleted_at < CURRENT_TIMESTAMP - $1 * INTERVAL '1 second'`
+	result, err := r.db.ExecContext(ctx, query, retention.Seconds())
+	if err != nil {
+		return 0, fmt.Errorf("failed to purge deleted users: %w", err)
+	}
+
+	rows, err := result.RowsAffected()
+	if err != nil {
+		return 0, fmt.Errorf("failed to get affected rows: %w", err)
+	}
+	return rows, nil
+}
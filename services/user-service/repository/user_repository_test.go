@@ -3,11 +3,14 @@
 package repository
 
 import (
+	"context"
 	"database/sql"
 	"fmt"
 	"os"
 	"testing"
+	"time"
 
+	"user-service/database"
 	"user-service/models"
 
 	_ "github.com/lib/pq"
@@ -43,19 +46,8 @@ func setupIntegrationDB(t *testing.T) *sql.DB {
 	require.NoError(t, err, "failed to open db connection")
 	require.NoError(t, db.Ping(), "failed to ping db — is postgres running?")
 
-	// 建立 table（idempotent，重複跑不會壞）
-	_, err = db.Exec(`
-		CREATE TABLE IF NOT EXISTS users (
-			id UUID PRIMARY KEY,
-			email VARCHAR(255) UNIQUE NOT NULL,
-			username VARCHAR(100) NOT NULL,
-			password VARCHAR(255) NOT NULL,
-			created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
-			updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
-		);
-		CREATE INDEX IF NOT EXISTS idx_users_email ON users(email);
-	`)
-	require.NoError(t, err, "failed to create tables")
+	// 建立 table（與正式環境共用同一份 schema，idempotent，重複跑不會壞）
+	require.NoError(t, database.CreateTables(db), "failed to create tables")
 
 	// test 結束後清掉所有測試資料，保持 DB 乾淨
 	t.Cleanup(func() {
@@ -80,6 +72,7 @@ func getEnvOrDefault(key, defaultValue string) string {
 func TestUserRepository_Create(t *testing.T) {
 	t.Run("success", func(t *testing.T) {
 		db := setupIntegrationDB(t)
+		ctx := context.Background()
 		repo := NewUserRepository(db)
 
 		user := &models.User{
@@ -89,7 +82,7 @@ func TestUserRepository_Create(t *testing.T) {
 			Password: "hashedpassword",
 		}
 
-		err := repo.Create(user)
+		err := repo.Create(ctx, user)
 
 		assert.NoError(t, err)
 		// DB 有回填 created_at / updated_at
@@ -99,6 +92,7 @@ func TestUserRepository_Create(t *testing.T) {
 
 	t.Run("duplicate email", func(t *testing.T) {
 		db := setupIntegrationDB(t)
+		ctx := context.Background()
 		repo := NewUserRepository(db)
 
 		// 先建第一筆
@@ -108,7 +102,7 @@ func TestUserRepository_Create(t *testing.T) {
 			Username: "createuser",
 			Password: "hashedpassword",
 		}
-		require.NoError(t, repo.Create(first))
+		require.NoError(t, repo.Create(ctx, first))
 
 		// 用同一個 email 再建一筆，應該要失敗
 		duplicate := &models.User{
@@ -118,7 +112,7 @@ func TestUserRepository_Create(t *testing.T) {
 			Password: "hashedpassword",
 		}
 
-		err := repo.Create(duplicate)
+		err := repo.Create(ctx, duplicate)
 
 		assert.Error(t, err)
 	})
@@ -131,6 +125,7 @@ func TestUserRepository_Create(t *testing.T) {
 func TestUserRepository_FindByEmail(t *testing.T) {
 	t.Run("found", func(t *testing.T) {
 		db := setupIntegrationDB(t)
+		ctx := context.Background()
 		repo := NewUserRepository(db)
 
 		// 先建一筆資料供查詢用
@@ -140,9 +135,9 @@ func TestUserRepository_FindByEmail(t *testing.T) {
 			Username: "finduser",
 			Password: "hashedpassword",
 		}
-		require.NoError(t, repo.Create(existing))
+		require.NoError(t, repo.Create(ctx, existing))
 
-		user, err := repo.FindByEmail("find@integration.test")
+		user, err := repo.FindByEmail(ctx, "find@integration.test")
 
 		assert.NoError(t, err)
 		assert.NotNil(t, user)
@@ -153,9 +148,10 @@ func TestUserRepository_FindByEmail(t *testing.T) {
 
 	t.Run("not found", func(t *testing.T) {
 		db := setupIntegrationDB(t)
+		ctx := context.Background()
 		repo := NewUserRepository(db)
 
-		user, err := repo.FindByEmail("nobody@integration.test")
+		user, err := repo.FindByEmail(ctx, "nobody@integration.test")
 
 		assert.NoError(t, err)
 		assert.Nil(t, user)
@@ -169,6 +165,7 @@ func TestUserRepository_FindByEmail(t *testing.T) {
 func TestUserRepository_FindByID(t *testing.T) {
 	t.Run("found", func(t *testing.T) {
 		db := setupIntegrationDB(t)
+		ctx := context.Background()
 		repo := NewUserRepository(db)
 
 		existing := &models.User{
@@ -177,9 +174,9 @@ func TestUserRepository_FindByID(t *testing.T) {
 			Username: "findbyiduser",
 			Password: "hashedpassword",
 		}
-		require.NoError(t, repo.Create(existing))
+		require.NoError(t, repo.Create(ctx, existing))
 
-		user, err := repo.FindByID("44444444-4444-4444-4444-444444444444")
+		user, err := repo.FindByID(ctx, "44444444-4444-4444-4444-444444444444")
 
 		assert.NoError(t, err)
 		assert.NotNil(t, user)
@@ -188,9 +185,10 @@ func TestUserRepository_FindByID(t *testing.T) {
 
 	t.Run("not found", func(t *testing.T) {
 		db := setupIntegrationDB(t)
+		ctx := context.Background()
 		repo := NewUserRepository(db)
 
-		user, err := repo.FindByID("00000000-0000-0000-0000-000000000000")
+		user, err := repo.FindByID(ctx, "00000000-0000-0000-0000-000000000000")
 
 		assert.NoError(t, err)
 		assert.Nil(t, user)
@@ -204,6 +202,7 @@ func TestUserRepository_FindByID(t *testing.T) {
 func TestUserRepository_Update(t *testing.T) {
 	t.Run("success", func(t *testing.T) {
 		db := setupIntegrationDB(t)
+		ctx := context.Background()
 		repo := NewUserRepository(db)
 
 		existing := &models.User{
@@ -212,24 +211,86 @@ func TestUserRepository_Update(t *testing.T) {
 			Username: "oldname",
 			Password: "hashedpassword",
 		}
-		require.NoError(t, repo.Create(existing))
+		require.NoError(t, repo.Create(ctx, existing))
 
-		err := repo.Update("55555555-5555-5555-5555-555555555555", "newname")
+		err := repo.Update(ctx, "55555555-5555-5555-5555-555555555555", "newname", AnyVersion)
 
 		assert.NoError(t, err)
 		// 查回來確認真的有更新
-		updated, _ := repo.FindByID("55555555-5555-5555-5555-555555555555")
+		updated, _ := repo.FindByID(ctx, "55555555-5555-5555-5555-555555555555")
 		assert.Equal(t, "newname", updated.Username)
 	})
 
 	t.Run("user not found", func(t *testing.T) {
 		db := setupIntegrationDB(t)
+		ctx := context.Background()
 		repo := NewUserRepository(db)
 
-		err := repo.Update("00000000-0000-0000-0000-000000000000", "newname")
+		err := repo.Update(ctx, "00000000-0000-0000-0000-000000000000", "newname", AnyVersion)
 
 		assert.Error(t, err)
 	})
+
+	t.Run("version check", func(t *testing.T) {
+		db := setupIntegrationDB(t)
+		ctx := context.Background()
+		repo := NewUserRepository(db)
+
+		existing := &models.User{
+			ID:       "55555555-5555-5555-5555-555555555555",
+			Email:    "update@integration.test",
+			Username: "oldname",
+			Password: "hashedpassword",
+		}
+		require.NoError(t, repo.Create(ctx, existing))
+		assert.Equal(t, 1, existing.Version)
+
+		// 第一個請求用目前版本更新成功，版本遞增
+		require.NoError(t, repo.Update(ctx, existing.ID, "first", 1))
+		// 第二個請求仍拿著舊版本，應該被拒絕
+		err := repo.Update(ctx, existing.ID, "second", 1)
+		assert.ErrorIs(t, err, ErrVersionConflict)
+
+		updated, _ := repo.FindByID(ctx, existing.ID)
+		assert.Equal(t, "first", updated.Username)
+		assert.Equal(t, 2, updated.Version)
+
+		// 刪除同樣檢查版本
+		assert.ErrorIs(t, repo.Delete(ctx, existing.ID, 1), ErrVersionConflict)
+		assert.NoError(t, repo.Delete(ctx, existing.ID, 2))
+	})
+}
+
+// ===================================================================
+// Patch 測試
+// ===================================================================
+
+func TestUserRepository_Patch(t *testing.T) {
+	db := setupIntegrationDB(t)
+	ctx := context.Background()
+	repo := NewUserRepository(db)
+
+	existing := &models.User{
+		ID:       "99999999-9999-9999-9999-999999999999",
+		Email:    "patch@integration.test",
+		Username: "patchuser",
+		Password: "hashedpassword",
+	}
+	require.NoError(t, repo.Create(ctx, existing))
+
+	bio, locale := "hello", "zh-TW"
+	require.NoError(t, repo.Patch(ctx, existing.ID, models.UserChanges{Bio: &bio, Locale: &locale}, AnyVersion))
+
+	updated, err := repo.FindByID(ctx, existing.ID)
+	require.NoError(t, err)
+	// 沒有指定的欄位保持原值
+	assert.Equal(t, "patchuser", updated.Username)
+	assert.Equal(t, "hello", updated.Bio)
+	assert.Equal(t, "zh-TW", updated.Locale)
+	assert.Equal(t, 2, updated.Version)
+
+	err = repo.Patch(ctx, existing.ID, models.UserChanges{Bio: &bio}, 1)
+	assert.ErrorIs(t, err, ErrVersionConflict)
 }
 
 // ===================================================================
@@ -239,6 +300,7 @@ func TestUserRepository_Update(t *testing.T) {
 func TestUserRepository_Delete(t *testing.T) {
 	t.Run("success", func(t *testing.T) {
 		db := setupIntegrationDB(t)
+		ctx := context.Background()
 		repo := NewUserRepository(db)
 
 		existing := &models.User{
@@ -247,18 +309,19 @@ func TestUserRepository_Delete(t *testing.T) {
 			Username: "deleteuser",
 			Password: "hashedpassword",
 		}
-		require.NoError(t, repo.Create(existing))
+		require.NoError(t, repo.Create(ctx, existing))
 
-		err := repo.Delete("66666666-6666-6666-6666-666666666666")
+		err := repo.Delete(ctx, "66666666-6666-6666-6666-666666666666", AnyVersion)
 
 		assert.NoError(t, err)
 		// 查回來確認真的不見了
-		deleted, _ := repo.FindByID("66666666-6666-6666-6666-666666666666")
+		deleted, _ := repo.FindByID(ctx, "66666666-6666-6666-6666-666666666666")
 		assert.Nil(t, deleted)
 	})
 
 	t.Run("already deleted", func(t *testing.T) {
 		db := setupIntegrationDB(t)
+		ctx := context.Background()
 		repo := NewUserRepository(db)
 
 		existing := &models.User{
@@ -267,22 +330,158 @@ func TestUserRepository_Delete(t *testing.T) {
 			Username: "deleteuser",
 			Password: "hashedpassword",
 		}
-		require.NoError(t, repo.Create(existing))
+		require.NoError(t, repo.Create(ctx, existing))
 		// 先刪一次
-		require.NoError(t, repo.Delete("66666666-6666-6666-6666-666666666666"))
+		require.NoError(t, repo.Delete(ctx, "66666666-6666-6666-6666-666666666666", AnyVersion))
 
 		// 再刪一次，應該要失敗
-		err := repo.Delete("66666666-6666-6666-6666-666666666666")
+		err := repo.Delete(ctx, "66666666-6666-6666-6666-666666666666", AnyVersion)
 
 		assert.Error(t, err)
 	})
 
 	t.Run("not found", func(t *testing.T) {
 		db := setupIntegrationDB(t)
+		ctx := context.Background()
+		repo := NewUserRepository(db)
+
+		err := repo.Delete(ctx, "00000000-0000-0000-0000-000000000000", AnyVersion)
+
+		assert.Error(t, err)
+	})
+}
+
+// ===================================================================
+// Restore / PurgeDeleted 測試
+// ===================================================================
+
+func TestUserRepository_Restore(t *testing.T) {
+	db := setupIntegrationDB(t)
+	ctx := context.Background()
+	repo := NewUserRepository(db)
+
+	existing := &models.User{
+		ID:       "77777777-7777-7777-7777-777777777777",
+		Email:    "restore@integration.test",
+		Username: "restoreuser",
+		Password: "hashedpassword",
+	}
+	require.NoError(t, repo.Create(ctx, existing))
+
+	// 還沒刪除的用戶不能還原
+	restored, err := repo.Restore(ctx, existing.ID)
+	assert.NoError(t, err)
+	assert.False(t, restored)
+
+	require.NoError(t, repo.Delete(ctx, existing.ID, AnyVersion))
+
+	// 軟刪除期間 email 仍被佔用
+	err = repo.Create(ctx, &models.User{
+		ID:       "77777777-7777-7777-7777-777777777778",
+		Email:    "restore@integration.test",
+		Username: "other",
+		Password: "hashedpassword",
+	})
+	assert.EqualError(t, err, "email already exists")
+
+	restored, err = repo.Restore(ctx, existing.ID)
+	assert.NoError(t, err)
+	assert.True(t, restored)
+
+	found, err := repo.FindByEmail(ctx, "restore@integration.test")
+	assert.NoError(t, err)
+	require.NotNil(t, found)
+	assert.Equal(t, existing.ID, found.ID)
+}
+
+func TestUserRepository_PurgeDeleted(t *testing.T) {
+	db := setupIntegrationDB(t)
+	ctx := context.Background()
+	repo := NewUserRepository(db)
+
+	existing := &models.User{
+		ID:       "88888888-8888-8888-8888-888888888888",
+		Email:    "purge@integration.test",
+		Username: "purgeuser",
+		Password: "hashedpassword",
+	}
+	require.NoError(t, repo.Create(ctx, existing))
+	require.NoError(t, repo.Delete(ctx, existing.ID, AnyVersion))
+
+	// 剛刪除的用戶還在保留期間內
+	purged, err := repo.PurgeDeleted(ctx, 24*time.Hour)
+	assert.NoError(t, err)
+	assert.Zero(t, purged)
+
+	// 把刪除時間往前推，模擬已超過保留期間
+	_, err = db.Exec(`UPDATE users SET deleted_at = deleted_at - INTERVAL '2 days' WHERE id = $1`, existing.ID)
+	require.NoError(t, err)
+
+	purged, err = repo.PurgeDeleted(ctx, 24*time.Hour)
+	assert.NoError(t, err)
+	assert.GreaterOrEqual(t, purged, int64(1))
+
+	// 永久刪除後無法還原，email 可以重新註冊
+	restored, err := repo.Restore(ctx, existing.ID)
+	assert.NoError(t, err)
+	assert.False(t, restored)
+	assert.NoError(t, repo.Create(ctx, &models.User{
+		ID:       "88888888-8888-8888-8888-888888888889",
+		Email:    "purge@integration.test",
+		Username: "purgeuser",
+		Password: "hashedpassword",
+	}))
+}
+
+// ===================================================================
+// 請求 deadline 測試
+// ===================================================================
+
+func TestUserRepository_ContextDeadline(t *testing.T) {
+	t.Run("expired deadline skips the query", func(t *testing.T) {
+		db := setupIntegrationDB(t)
+		repo := NewUserRepository(db)
+
+		ctx, cancel := context.WithDeadline(context.Background(), time.Now().Add(-time.Second))
+		defer cancel()
+
+		user, err := repo.FindByID(ctx, "00000000-0000-0000-0000-000000000000")
+
+		assert.Nil(t, user)
+		assert.ErrorIs(t, err, context.DeadlineExceeded)
+	})
+
+	t.Run("deadline cancels a blocked query", func(t *testing.T) {
+		db := setupIntegrationDB(t)
+		ctx := context.Background()
 		repo := NewUserRepository(db)
 
-		err := repo.Delete("00000000-0000-0000-0000-000000000000")
+		existing := &models.User{
+			ID:       "77777777-7777-7777-7777-777777777777",
+			Email:    "deadline@integration.test",
+			Username: "oldname",
+			Password: "hashedpassword",
+		}
+		require.NoError(t, repo.Create(ctx, existing))
+
+		// 另一個交易鎖住這一列，UPDATE 會一直等到鎖釋放或被取消
+		tx, err := db.Begin()
+		require.NoError(t, err)
+		defer tx.Rollback()
+		_, err = tx.Exec(`SELECT id FROM users WHERE id = $1 FOR UPDATE`, existing.ID)
+		require.NoError(t, err)
+
+		deadlineCtx, cancel := context.WithTimeout(ctx, 200*time.Millisecond)
+		defer cancel()
+		start := time.Now()
+		err = repo.Update(deadlineCtx, existing.ID, "newname", AnyVersion)
 
 		assert.Error(t, err)
+		assert.Less(t, time.Since(start), 5*time.Second, "query must be cancelled at the deadline, not when the lock is released")
+		require.NoError(t, tx.Rollback())
+
+		unchanged, err := repo.FindByID(ctx, existing.ID)
+		require.NoError(t, err)
+		assert.Equal(t, "oldname", unchanged.Username)
 	})
 }
@@ -3,18 +3,47 @@ package routes
 import (
 	"github.com/gin-gonic/gin"
 	"user-service/handlers"
+	"user-service/health"
+	"user-service/middleware"
 )
 
 // SetupRoutes 設定所有路由
-func SetupRoutes(router *gin.Engine, userHandler *handlers.UserHandler) {
-	// 健康檢查
+// requireGateway 掛在除了健康檢查以外的所有路由上，拒絕沒有經過 API Gateway 的請求；
+// 通過驗證的請求再套用 gateway 傳來的剩餘時間作為 deadline
+func SetupRoutes(router *gin.Engine, userHandler *handlers.UserHandler, probes *health.Health, requireGateway gin.HandlerFunc) {
+	// 健康檢查（容器 healthcheck 直接呼叫，不經過 gateway）
+	// /livez 只確認 process 還活著；/readyz 會檢查 Postgres、Redis 與 schema 版本
 	router.GET("/health", userHandler.Health)
+	router.GET("/livez", probes.Livez)
+	router.GET("/readyz", probes.Readyz)
+
+	api := router.Group("/", requireGateway, middleware.RequestDeadline())
 
 	// 用戶路由
-	router.POST("/users/register", userHandler.Register)
-	router.POST("/users/login", userHandler.Login)
-	router.GET("/users", userHandler.GetUsers)
-	router.GET("/users/:id", userHandler.GetUser)
-	router.PUT("/users/:id", userHandler.UpdateUser)
-	router.DELETE("/users/:id", userHandler.DeleteUser)
+	api.POST("/users/register", userHandler.Register)
+	api.POST("/users/login", userHandler.Login)
+	api.POST("/users/login/mfa", userHandler.LoginMFA)
+	api.POST("/users/password/forgot", userHandler.ForgotPassword)
+	api.POST("/users/password/reset", userHandler.ResetPassword)
+	api.POST("/users/email/confirm", userHandler.ConfirmEmailChange)
+	api.GET("/users", userHandler.GetUsers)
+	// /users/me 作用在 gateway 注入的呼叫者身份上，前端不需要先知道自己的 ID
+	api.GET("/users/me", handlers.ForCurrentUser(userHandler.GetUser))
+	api.PATCH("/users/me", handlers.ForCurrentUser(userHandler.PatchUser))
+	api.DELETE("/users/me", handlers.ForCurrentUser(userHandler.DeleteUser))
+	api.GET("/users/me/sessions", userHandler.ListMySessions)
+	api.DELETE("/users/me/sessions/:id", userHandler.RevokeMySession)
+	api.GET("/users/:id", userHandler.GetUser)
+	api.PUT("/users/:id", userHandler.UpdateUser)
+	api.PATCH("/users/:id", userHandler.PatchUser)
+	api.PUT("/users/:id/password", userHandler.ChangePassword)
+	api.POST("/users/:id/email", userHandler.RequestEmailChange)
+	api.POST("/users/:id/mfa/totp", userHandler.EnrollTOTP)
+	api.POST("/users/:id/mfa/totp/confirm", userHandler.ConfirmTOTP)
+	api.DELETE("/users/:id/mfa/totp", userHandler.DisableMFA)
+	api.DELETE("/users/:id", userHandler.DeleteUser)
+
+	// 管理員路由（API Gateway 只讓 role 為 admin 的 token 通過）
+	api.POST("/admin/users/:id/restore", userHandler.RestoreUser)
+	api.GET("/admin/audit-events", userHandler.ListAuditEvents)
 }
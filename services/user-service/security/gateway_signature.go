@@ -0,0 +1,52 @@
+package security
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"net/http"
+	"strings"
+)
+
+// API Gateway 轉發請求時附上的簽章 header
+const (
+	GatewayTimestampHeader = "X-Gateway-Timestamp" // Unix 秒數
+	GatewaySignatureHeader = "X-Gateway-Signature" // hex 編碼的 HMAC-SHA256
+)
+
+// ClientIPHeader gateway 依信任的 proxy 清單解析出的客戶端 IP，納入簽章，
+// 稽核與 session 的 IP 以它為準，不必再各自解析客戶端可控制的 X-Forwarded-For
+const ClientIPHeader = "X-Client-IP"
+
+// GatewaySignedHeaders 納入簽章的 header，下游服務會信任這些值，因此都必須受到保護
+// 順序與 api-gateway proxy.signedHeaders 相同，兩邊必須保持一致
+var GatewaySignedHeaders = []string{
+	"X-User-ID",
+	"X-User-Email",
+	"X-User-Roles",
+	"X-Session-ID",
+	"X-Request-ID",
+	"X-Forwarded-For",
+	ClientIPHeader,
+}
+
+// GatewaySignature 計算一個請求的簽章
+//
+// 簽章內容以換行串接：method、path、raw query、timestamp、body 的 SHA-256，
+// 以及 GatewaySignedHeaders 依序的值，竄改其中任何一項簽章都會不符
+func GatewaySignature(secret string, method, path, rawQuery, timestamp string, body []byte, header http.Header) string {
+	bodyHash := sha256.Sum256(body)
+	parts := []string{method, path, rawQuery, timestamp, hex.EncodeToString(bodyHash[:])}
+	for _, name := range GatewaySignedHeaders {
+		parts = append(parts, header.Get(name))
+	}
+
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(strings.Join(parts, "\n")))
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// VerifyGatewaySignature 以固定時間比較簽章
+func VerifyGatewaySignature(expected, actual string) bool {
+	return hmac.Equal([]byte(expected), []byte(actual))
+}
@@ -0,0 +1,254 @@
+package security
+
+import (
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"strings"
+
+	"golang.org/x/crypto/argon2"
+	"golang.org/x/crypto/bcrypt"
+)
+
+// 支援的演算法名稱，用於設定檔
+const (
+	AlgorithmBcrypt   = "bcrypt"
+	AlgorithmArgon2id = "argon2id"
+)
+
+// ErrUnknownHashFormat 儲存的 hash 不屬於任何已知演算法
+var ErrUnknownHashFormat = errors.New("unknown password hash format")
+
+// PasswordHasher 定義密碼 hash 的契約
+//
+// 儲存的字串本身帶有演算法與參數（例如 "$2a$12$..."、"$argon2id$v=19$m=65536,t=3,p=2$..."），
+// 因此驗證時不需要知道當初用什麼設定，也能判斷是否該用目前的設定重新 hash
+type PasswordHasher interface {
+	Hash(password string) (string, error)
+	Verify(password, encoded string) (bool, error)
+	// NeedsRehash 回傳 true 代表 encoded 不是用目前的演算法或參數產生的
+	NeedsRehash(encoded string) bool
+	// Matches 判斷 encoded 是否屬於這個 hasher 的格式
+	Matches(encoded string) bool
+}
+
+// ── bcrypt ───────────────────────────────────────────────────────────────────
+
+// BcryptHasher 以 bcrypt 做 hash，cost 直接記錄在 bcrypt 的輸出格式中
+type BcryptHasher struct {
+	Cost int
+}
+
+// NewBcryptHasher 創建 BcryptHasher
+func NewBcryptHasher(cost int) *BcryptHasher {
+	return &BcryptHasher{Cost: cost}
+}
+
+func (h *BcryptHasher) Hash(password string) (string, error) {
+	hashed, err := bcrypt.GenerateFromPassword([]byte(password), h.Cost)
+	if err != nil {
+		return "", fmt.Errorf("failed to hash password: %w", err)
+	}
+	return string(hashed), nil
+}
+
+func (h *BcryptHasher) Verify(password, encoded string) (bool, error) {
+	err := bcrypt.CompareHashAndPassword([]byte(encoded), []byte(password))
+	if err == nil {
+		return true, nil
+	}
+	if errors.Is(err, bcrypt.ErrMismatchedHashAndPassword) {
+		return false, nil
+	}
+	return false, err
+}
+
+func (h *BcryptHasher) NeedsRehash(encoded string) bool {
+	if !h.Matches(encoded) {
+		return true
+	}
+	cost, err := bcrypt.Cost([]byte(encoded))
+	return err != nil || cost != h.Cost
+}
+
+func (h *BcryptHasher) Matches(encoded string) bool {
+	return strings.HasPrefix(encoded, "$2a$") ||
+		strings.HasPrefix(encoded, "$2b$") ||
+		strings.HasPrefix(encoded, "$2y$")
+}
+
+// ── argon2id ─────────────────────────────────────────────────────────────────
+
+// Argon2idParams argon2id 的成本參數
+type Argon2idParams struct {
+	Memory      uint32 // KiB
+	Iterations  uint32
+	Parallelism uint8
+	SaltLength  uint32
+	KeyLength   uint32
+}
+
+// DefaultArgon2idParams OWASP 建議的基準設定（64 MiB、3 次迭代）
+func DefaultArgon2idParams() Argon2idParams {
+	return Argon2idParams{
+		Memory:      64 * 1024,
+		Iterations:  3,
+		Parallelism: 2,
+		SaltLength:  16,
+		KeyLength:   32,
+	}
+}
+
+// Argon2idHasher 以 argon2id 做 hash，輸出為 PHC 字串格式：
+//
+//	$argon2id$v=19$m=65536,t=3,p=2$<salt>$<hash>
+type Argon2idHasher struct {
+	Params Argon2idParams
+}
+
+// NewArgon2idHasher 創建 Argon2idHasher
+func NewArgon2idHasher(params Argon2idParams) *Argon2idHasher {
+	return &Argon2idHasher{Params: params}
+}
+
+const argon2idPrefix = "$argon2id$"
+
+func (h *Argon2idHasher) Hash(password string) (string, error) {
+	salt := make([]byte, h.Params.SaltLength)
+	if _, err := rand.Read(salt); err != nil {
+		return "", fmt.Errorf("failed to generate salt: %w", err)
+	}
+
+	key := argon2.IDKey([]byte(password), salt, h.Params.Iterations, h.Params.Memory, h.Params.Parallelism, h.Params.KeyLength)
+	return fmt.Sprintf("%sv=%d$m=%d,t=%d,p=%d$%s$%s",
+		argon2idPrefix, argon2.Version,
+		h.Params.Memory, h.Params.Iterations, h.Params.Parallelism,
+		base64.RawStdEncoding.EncodeToString(salt),
+		base64.RawStdEncoding.EncodeToString(key),
+	), nil
+}
+
+func (h *Argon2idHasher) Verify(password, encoded string) (bool, error) {
+	params, salt, key, err := decodeArgon2id(encoded)
+	if err != nil {
+		return false, err
+	}
+
+	// 用儲存的參數重算，而不是目前的設定，舊參數產生的 hash 仍能驗證
+	candidate := argon2.IDKey([]byte(password), salt, params.Iterations, params.Memory, params.Parallelism, params.KeyLength)
+	return subtle.ConstantTimeCompare(candidate, key) == 1, nil
+}
+
+func (h *Argon2idHasher) NeedsRehash(encoded string) bool {
+	params, _, _, err := decodeArgon2id(encoded)
+	if err != nil {
+		return true
+	}
+	return params.Memory != h.Params.Memory ||
+		params.Iterations != h.Params.Iterations ||
+		params.Parallelism != h.Params.Parallelism ||
+		params.SaltLength != h.Params.SaltLength ||
+		params.KeyLength != h.Params.KeyLength
+}
+
+func (h *Argon2idHasher) Matches(encoded string) bool {
+	return strings.HasPrefix(encoded, argon2idPrefix)
+}
+
+// decodeArgon2id 解析 PHC 字串，取回參數、salt 與 hash
+func decodeArgon2id(encoded string) (Argon2idParams, []byte, []byte, error) {
+	var params Argon2idParams
+
+	// "", "argon2id", "v=19", "m=...,t=...,p=...", salt, hash
+	parts := strings.Split(encoded, "$")
+	if len(parts) != 6 || parts[1] != AlgorithmArgon2id {
+		return params, nil, nil, ErrUnknownHashFormat
+	}
+
+	var version int
+	if _, err := fmt.Sscanf(parts[2], "v=%d", &version); err != nil {
+		return params, nil, nil, fmt.Errorf("invalid argon2id version: %w", err)
+	}
+	if version != argon2.Version {
+		return params, nil, nil, fmt.Errorf("unsupported argon2id version %d", version)
+	}
+
+	if _, err := fmt.Sscanf(parts[3], "m=%d,t=%d,p=%d", &params.Memory, &params.Iterations, &params.Parallelism); err != nil {
+		return params, nil, nil, fmt.Errorf("invalid argon2id parameters: %w", err)
+	}
+
+	salt, err := base64.RawStdEncoding.DecodeString(parts[4])
+	if err != nil {
+		return params, nil, nil, fmt.Errorf("invalid argon2id salt: %w", err)
+	}
+	key, err := base64.RawStdEncoding.DecodeString(parts[5])
+	if err != nil {
+		return params, nil, nil, fmt.Errorf("invalid argon2id hash: %w", err)
+	}
+	params.SaltLength = uint32(len(salt))
+	params.KeyLength = uint32(len(key))
+
+	return params, salt, key, nil
+}
+
+// ── 升級用的組合 hasher ─────────────────────────────────────────────────────
+
+// UpgradingHasher 新密碼一律用 primary 產生，驗證時依 hash 格式交給對應的 hasher；
+// 只要儲存的 hash 不是 primary 以目前參數產生的，NeedsRehash 就會回傳 true，
+// 讓 service 層在用戶下次登入成功時順手升級
+type UpgradingHasher struct {
+	primary PasswordHasher
+	known   []PasswordHasher
+}
+
+// NewUpgradingHasher 創建 UpgradingHasher，legacy 為仍需能驗證的舊演算法
+func NewUpgradingHasher(primary PasswordHasher, legacy ...PasswordHasher) *UpgradingHasher {
+	return &UpgradingHasher{
+		primary: primary,
+		known:   append([]PasswordHasher{primary}, legacy...),
+	}
+}
+
+func (h *UpgradingHasher) Hash(password string) (string, error) {
+	return h.primary.Hash(password)
+}
+
+func (h *UpgradingHasher) Verify(password, encoded string) (bool, error) {
+	for _, hasher := range h.known {
+		if hasher.Matches(encoded) {
+			return hasher.Verify(password, encoded)
+		}
+	}
+	return false, ErrUnknownHashFormat
+}
+
+func (h *UpgradingHasher) NeedsRehash(encoded string) bool {
+	return h.primary.NeedsRehash(encoded)
+}
+
+func (h *UpgradingHasher) Matches(encoded string) bool {
+	for _, hasher := range h.known {
+		if hasher.Matches(encoded) {
+			return true
+		}
+	}
+	return false
+}
+
+// NewPasswordHasher 依設定的演算法建立 hasher：新密碼使用 algorithm，
+// 另一種演算法保留為可驗證的舊格式，切換設定後舊用戶仍能登入並在登入時自動升級
+func NewPasswordHasher(algorithm string, bcryptCost int, argon2Params Argon2idParams) (PasswordHasher, error) {
+	bcryptHasher := NewBcryptHasher(bcryptCost)
+	argon2Hasher := NewArgon2idHasher(argon2Params)
+
+	switch algorithm {
+	case AlgorithmArgon2id:
+		return NewUpgradingHasher(argon2Hasher, bcryptHasher), nil
+	case AlgorithmBcrypt:
+		return NewUpgradingHasher(bcryptHasher, argon2Hasher), nil
+	default:
+		return nil, fmt.Errorf("unsupported password hash algorithm %q", algorithm)
+	}
+}
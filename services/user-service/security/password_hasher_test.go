@@ -0,0 +1,101 @@
+package security
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/crypto/bcrypt"
+)
+
+// fastArgon2 測試用的低成本參數，避免每個測試都吃 64 MiB
+var fastArgon2 = Argon2idParams{Memory: 1024, Iterations: 1, Parallelism: 1, SaltLength: 16, KeyLength: 32}
+
+func TestBcryptHasher(t *testing.T) {
+	h := NewBcryptHasher(bcrypt.MinCost)
+
+	encoded, err := h.Hash("correct horse")
+	require.NoError(t, err)
+	assert.True(t, h.Matches(encoded))
+
+	ok, err := h.Verify("correct horse", encoded)
+	assert.NoError(t, err)
+	assert.True(t, ok)
+
+	ok, err = h.Verify("wrong horse", encoded)
+	assert.NoError(t, err)
+	assert.False(t, ok)
+
+	assert.False(t, h.NeedsRehash(encoded))
+	// 提高 cost 後，舊 hash 需要升級
+	assert.True(t, NewBcryptHasher(bcrypt.MinCost+1).NeedsRehash(encoded))
+}
+
+func TestArgon2idHasher(t *testing.T) {
+	h := NewArgon2idHasher(fastArgon2)
+
+	encoded, err := h.Hash("correct horse")
+	require.NoError(t, err)
+	assert.True(t, strings.HasPrefix(encoded, "$argon2id$v=19$m=1024,t=1,p=1$"))
+
+	t.Run("verify", func(t *testing.T) {
+		ok, err := h.Verify("correct horse", encoded)
+		assert.NoError(t, err)
+		assert.True(t, ok)
+
+		ok, err = h.Verify("wrong horse", encoded)
+		assert.NoError(t, err)
+		assert.False(t, ok)
+	})
+
+	t.Run("same password produces different hashes", func(t *testing.T) {
+		again, err := h.Hash("correct horse")
+		require.NoError(t, err)
+		assert.NotEqual(t, encoded, again)
+	})
+
+	t.Run("old parameters still verify but need rehash", func(t *testing.T) {
+		stronger := fastArgon2
+		stronger.Iterations = 2
+		upgraded := NewArgon2idHasher(stronger)
+
+		ok, err := upgraded.Verify("correct horse", encoded)
+		assert.NoError(t, err)
+		assert.True(t, ok)
+		assert.True(t, upgraded.NeedsRehash(encoded))
+		assert.False(t, h.NeedsRehash(encoded))
+	})
+
+	t.Run("malformed hash", func(t *testing.T) {
+		_, err := h.Verify("correct horse", "$argon2id$v=19$garbage")
+		assert.Error(t, err)
+	})
+}
+
+func TestUpgradingHasher(t *testing.T) {
+	legacy := NewBcryptHasher(bcrypt.MinCost)
+	h := NewUpgradingHasher(NewArgon2idHasher(fastArgon2), legacy)
+
+	bcryptHash, err := legacy.Hash("correct horse")
+	require.NoError(t, err)
+
+	t.Run("verifies legacy bcrypt hash and asks for rehash", func(t *testing.T) {
+		ok, err := h.Verify("correct horse", bcryptHash)
+		assert.NoError(t, err)
+		assert.True(t, ok)
+		assert.True(t, h.NeedsRehash(bcryptHash))
+	})
+
+	t.Run("new hashes use primary algorithm", func(t *testing.T) {
+		encoded, err := h.Hash("correct horse")
+		require.NoError(t, err)
+		assert.True(t, strings.HasPrefix(encoded, "$argon2id$"))
+		assert.False(t, h.NeedsRehash(encoded))
+	})
+
+	t.Run("unknown format", func(t *testing.T) {
+		_, err := h.Verify("correct horse", "plaintext")
+		assert.ErrorIs(t, err, ErrUnknownHashFormat)
+	})
+}
@@ -0,0 +1,153 @@
+package security
+
+import (
+	"bufio"
+	_ "embed"
+	"fmt"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// 各條規則的代號，會出現在 API 錯誤回應的 details 裡，前端可依此顯示對應提示
+const (
+	RuleMinLength    = "min_length"
+	RuleMaxLength    = "max_length"
+	RuleUppercase    = "uppercase"
+	RuleLowercase    = "lowercase"
+	RuleDigit        = "digit"
+	RuleSymbol       = "symbol"
+	RulePersonalInfo = "personal_info"
+	RuleCommon       = "common_password"
+)
+
+// bcryptMaxBytes bcrypt 只會使用前 72 bytes，超過的部分會被忽略甚至直接報錯
+const bcryptMaxBytes = 72
+
+// minPersonalInfoLength email 前綴或用戶名短於此長度時不做子字串比對，避免誤判
+const minPersonalInfoLength = 3
+
+//go:embed common_passwords.txt
+var commonPasswordsFile string
+
+// commonPasswords 內建的常見／外洩密碼清單（全部轉小寫）
+var commonPasswords = loadCommonPasswords(commonPasswordsFile)
+
+// PasswordPolicy 密碼強度規則
+type PasswordPolicy struct {
+	MinLength            int  // 最少字元數
+	MaxLength            int  // 最多 bytes 數，0 代表不限制（bcrypt 仍有 72 bytes 上限）
+	RequireUppercase     bool // 至少一個大寫字母
+	RequireLowercase     bool // 至少一個小寫字母
+	RequireDigit         bool // 至少一個數字
+	RequireSymbol        bool // 至少一個符號
+	DisallowPersonalInfo bool // 不可包含 email 前綴或用戶名
+	RejectCommon         bool // 不可為常見／外洩密碼
+}
+
+// LegacyPasswordPolicy 與原本 binding:"min=6" 等價的規則，未設定 policy 時使用
+func LegacyPasswordPolicy() PasswordPolicy {
+	return PasswordPolicy{MinLength: 6, MaxLength: bcryptMaxBytes}
+}
+
+// Violation 代表違反的單一規則
+type Violation struct {
+	Rule    string `json:"rule"`
+	Message string `json:"message"`
+}
+
+// PolicyError 密碼不符合規則時回傳，內含所有違反的規則
+type PolicyError struct {
+	Violations []Violation
+}
+
+func (e *PolicyError) Error() string {
+	rules := make([]string, len(e.Violations))
+	for i, v := range e.Violations {
+		rules[i] = v.Rule
+	}
+	return "password does not meet policy: " + strings.Join(rules, ", ")
+}
+
+// Validate 依規則檢查密碼，email 與 username 用於個人資訊比對
+// 通過時回傳 nil，否則回傳 *PolicyError
+func (p PasswordPolicy) Validate(password, email, username string) error {
+	var violations []Violation
+	add := func(rule, format string, args ...interface{}) {
+		violations = append(violations, Violation{Rule: rule, Message: fmt.Sprintf(format, args...)})
+	}
+
+	if utf8.RuneCountInString(password) < p.MinLength {
+		add(RuleMinLength, "password must be at least %d characters", p.MinLength)
+	}
+	if p.MaxLength > 0 && len(password) > p.MaxLength {
+		add(RuleMaxLength, "password must be at most %d bytes", p.MaxLength)
+	}
+
+	var hasUpper, hasLower, hasDigit, hasSymbol bool
+	for _, r := range password {
+		switch {
+		case unicode.IsUpper(r):
+			hasUpper = true
+		case unicode.IsLower(r):
+			hasLower = true
+		case unicode.IsDigit(r):
+			hasDigit = true
+		case unicode.IsPunct(r) || unicode.IsSymbol(r) || unicode.IsSpace(r):
+			hasSymbol = true
+		}
+	}
+	if p.RequireUppercase && !hasUpper {
+		add(RuleUppercase, "password must contain an uppercase letter")
+	}
+	if p.RequireLowercase && !hasLower {
+		add(RuleLowercase, "password must contain a lowercase letter")
+	}
+	if p.RequireDigit && !hasDigit {
+		add(RuleDigit, "password must contain a digit")
+	}
+	if p.RequireSymbol && !hasSymbol {
+		add(RuleSymbol, "password must contain a symbol")
+	}
+
+	lowered := strings.ToLower(password)
+	if p.DisallowPersonalInfo && containsPersonalInfo(lowered, email, username) {
+		add(RulePersonalInfo, "password must not contain your email or username")
+	}
+	if p.RejectCommon && commonPasswords[lowered] {
+		add(RuleCommon, "password is too common")
+	}
+
+	if len(violations) > 0 {
+		return &PolicyError{Violations: violations}
+	}
+	return nil
+}
+
+// containsPersonalInfo 檢查（已轉小寫的）密碼是否包含 email 前綴或用戶名
+func containsPersonalInfo(lowered, email, username string) bool {
+	candidates := []string{strings.ToLower(username)}
+	if local, _, ok := strings.Cut(strings.ToLower(email), "@"); ok {
+		candidates = append(candidates, local)
+	}
+
+	for _, c := range candidates {
+		if utf8.RuneCountInString(c) >= minPersonalInfoLength && strings.Contains(lowered, c) {
+			return true
+		}
+	}
+	return false
+}
+
+func loadCommonPasswords(content string) map[string]bool {
+	set := make(map[string]bool)
+	scanner := bufio.NewScanner(strings.NewReader(content))
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		set[strings.ToLower(line)] = true
+	}
+	return set
+}
@@ -0,0 +1,76 @@
+package security
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// rulesOf 取出錯誤中所有違反的規則代號，方便比對
+func rulesOf(t *testing.T, err error) []string {
+	t.Helper()
+	policyErr, ok := err.(*PolicyError)
+	require.True(t, ok, "expected *PolicyError, got %v", err)
+	rules := make([]string, len(policyErr.Violations))
+	for i, v := range policyErr.Violations {
+		rules[i] = v.Rule
+	}
+	return rules
+}
+
+func TestPasswordPolicy_Validate(t *testing.T) {
+	strict := PasswordPolicy{
+		MinLength:            10,
+		MaxLength:            72,
+		RequireUppercase:     true,
+		RequireLowercase:     true,
+		RequireDigit:         true,
+		RequireSymbol:        true,
+		DisallowPersonalInfo: true,
+		RejectCommon:         true,
+	}
+
+	t.Run("strong password passes", func(t *testing.T) {
+		err := strict.Validate("Tr0ub4dor&3-horse", "alice@example.com", "alice")
+		assert.NoError(t, err)
+	})
+
+	t.Run("reports every violated rule", func(t *testing.T) {
+		err := strict.Validate("abc", "alice@example.com", "alice")
+		assert.ElementsMatch(t, []string{RuleMinLength, RuleUppercase, RuleDigit, RuleSymbol}, rulesOf(t, err))
+	})
+
+	t.Run("too long for bcrypt", func(t *testing.T) {
+		err := strict.Validate("Aa1!"+strings.Repeat("x", 80), "alice@example.com", "alice")
+		assert.Equal(t, []string{RuleMaxLength}, rulesOf(t, err))
+	})
+
+	t.Run("contains username case-insensitively", func(t *testing.T) {
+		err := strict.Validate("My-ALICE-pass-99", "someone@example.com", "alice")
+		assert.Equal(t, []string{RulePersonalInfo}, rulesOf(t, err))
+	})
+
+	t.Run("contains email local part", func(t *testing.T) {
+		err := strict.Validate("Xbob.smith#2024", "bob.smith@example.com", "bob")
+		assert.Contains(t, rulesOf(t, err), RulePersonalInfo)
+	})
+
+	t.Run("short username is not matched", func(t *testing.T) {
+		// 只有兩個字的用戶名太容易誤判，不做比對
+		err := strict.Validate("Jo-unrelated-77!", "x@example.com", "jo")
+		assert.NoError(t, err)
+	})
+
+	t.Run("common password rejected", func(t *testing.T) {
+		policy := PasswordPolicy{MinLength: 6, RejectCommon: true}
+		err := policy.Validate("PassWord123", "alice@example.com", "alice")
+		assert.Equal(t, []string{RuleCommon}, rulesOf(t, err))
+	})
+
+	t.Run("rules disabled", func(t *testing.T) {
+		err := LegacyPasswordPolicy().Validate("password123", "alice@example.com", "alice")
+		assert.NoError(t, err)
+	})
+}
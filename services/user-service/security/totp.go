@@ -0,0 +1,127 @@
+package security
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha1"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base32"
+	"encoding/binary"
+	"encoding/hex"
+	"fmt"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// RFC 6238 參數，與 Google Authenticator 等常見 App 的預設值相同
+const (
+	TOTPPeriod      = 30 // 每個 code 的有效秒數
+	TOTPDigits      = 6  // code 位數
+	totpSecretBytes = 20 // 160 bits，RFC 4226 建議的 HMAC-SHA1 key 長度
+)
+
+// 復原碼格式：兩組 5 個 base32 字元，例如 "k7q2m-xz4pa"（50 bits 亂度）
+const (
+	recoveryCodeGroupLen = 5
+	recoveryCodeBytes    = 7
+)
+
+// base32NoPad 大部分 authenticator App 預期的 secret 格式（無 '=' padding）
+var base32NoPad = base32.StdEncoding.WithPadding(base32.NoPadding)
+
+// GenerateTOTPSecret 產生 base32 編碼的隨機 TOTP secret
+func GenerateTOTPSecret() (string, error) {
+	b := make([]byte, totpSecretBytes)
+	if _, err := rand.Read(b); err != nil {
+		return "", fmt.Errorf("failed to generate totp secret: %w", err)
+	}
+	return base32NoPad.EncodeToString(b), nil
+}
+
+// TOTPStep 回傳 t 所在的時間區間編號（RFC 6238 的 T）
+func TOTPStep(t time.Time) int64 {
+	return t.Unix() / TOTPPeriod
+}
+
+// TOTPCode 計算指定時間區間的 code（RFC 4226 HOTP，counter 為時間區間編號）
+func TOTPCode(secret string, step int64) (string, error) {
+	key, err := base32NoPad.DecodeString(strings.ToUpper(strings.TrimRight(secret, "=")))
+	if err != nil {
+		return "", fmt.Errorf("invalid totp secret: %w", err)
+	}
+
+	var counter [8]byte
+	binary.BigEndian.PutUint64(counter[:], uint64(step))
+	mac := hmac.New(sha1.New, key)
+	mac.Write(counter[:])
+	sum := mac.Sum(nil)
+
+	// dynamic truncation：取最後一個 byte 的低 4 bits 當 offset，往後取 31 bits
+	offset := sum[len(sum)-1] & 0x0f
+	value := binary.BigEndian.Uint32(sum[offset:offset+4]) & 0x7fffffff
+
+	mod := uint32(1)
+	for i := 0; i < TOTPDigits; i++ {
+		mod *= 10
+	}
+	return fmt.Sprintf("%0*d", TOTPDigits, value%mod), nil
+}
+
+// ValidateTOTP 檢查 code 是否符合 t 前後 skew 個時間區間內的任一 code
+// 允許誤差是為了容忍手機與伺服器的時鐘差；成功時回傳符合的時間區間編號，
+// 呼叫端應記錄下來，拒絕同一區間的 code 被重複使用
+func ValidateTOTP(secret, code string, t time.Time, skew int64) (int64, bool) {
+	if len(code) != TOTPDigits {
+		return 0, false
+	}
+
+	current := TOTPStep(t)
+	for step := current - skew; step <= current+skew; step++ {
+		expected, err := TOTPCode(secret, step)
+		if err != nil {
+			return 0, false
+		}
+		if subtle.ConstantTimeCompare([]byte(expected), []byte(code)) == 1 {
+			return step, true
+		}
+	}
+	return 0, false
+}
+
+// TOTPProvisioningURI 產生 otpauth:// URI，前端轉成 QR code 讓 authenticator App 掃描
+// 格式參考 https://github.com/google/google-authenticator/wiki/Key-Uri-Format
+func TOTPProvisioningURI(issuer, account, secret string) string {
+	params := url.Values{}
+	params.Set("secret", secret)
+	params.Set("issuer", issuer)
+	params.Set("algorithm", "SHA1")
+	params.Set("digits", fmt.Sprint(TOTPDigits))
+	params.Set("period", fmt.Sprint(TOTPPeriod))
+
+	label := url.PathEscape(issuer + ":" + account)
+	return "otpauth://totp/" + label + "?" + params.Encode()
+}
+
+// GenerateRecoveryCodes 產生 n 組一次性復原碼（明文，只在啟用當下顯示給用戶一次）
+func GenerateRecoveryCodes(n int) ([]string, error) {
+	codes := make([]string, n)
+	for i := range codes {
+		b := make([]byte, recoveryCodeBytes)
+		if _, err := rand.Read(b); err != nil {
+			return nil, fmt.Errorf("failed to generate recovery code: %w", err)
+		}
+		raw := strings.ToLower(base32NoPad.EncodeToString(b))[:recoveryCodeGroupLen*2]
+		codes[i] = raw[:recoveryCodeGroupLen] + "-" + raw[recoveryCodeGroupLen:]
+	}
+	return codes, nil
+}
+
+// HashRecoveryCode 計算復原碼的 SHA-256，DB 只存這個值
+// 比對前會先去掉空白與 '-' 並轉小寫，讓用戶輸入時不必在意格式
+func HashRecoveryCode(code string) string {
+	normalized := strings.ToLower(strings.NewReplacer("-", "", " ", "").Replace(code))
+	sum := sha256.Sum256([]byte(normalized))
+	return hex.EncodeToString(sum[:])
+}
@@ -0,0 +1,89 @@
+package security
+
+import (
+	"encoding/base32"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// rfcSecret RFC 6238 附錄 B 的 SHA1 測試用 key："12345678901234567890"
+var rfcSecret = base32.StdEncoding.WithPadding(base32.NoPadding).EncodeToString([]byte("12345678901234567890"))
+
+func TestTOTPCode_RFC6238Vectors(t *testing.T) {
+	// RFC 給的是 8 位數，這裡取後 6 位
+	vectors := map[int64]string{
+		59:          "287082",
+		1111111109:  "081804",
+		1111111111:  "050471",
+		1234567890:  "005924",
+		2000000000:  "279037",
+		20000000000: "353130",
+	}
+
+	for unix, want := range vectors {
+		code, err := TOTPCode(rfcSecret, TOTPStep(time.Unix(unix, 0)))
+		require.NoError(t, err)
+		assert.Equal(t, want, code, "unix time %d", unix)
+	}
+}
+
+func TestValidateTOTP(t *testing.T) {
+	now := time.Unix(1234567890, 0)
+	code, err := TOTPCode(rfcSecret, TOTPStep(now))
+	require.NoError(t, err)
+
+	t.Run("current step", func(t *testing.T) {
+		step, ok := ValidateTOTP(rfcSecret, code, now, 1)
+		assert.True(t, ok)
+		assert.Equal(t, TOTPStep(now), step)
+	})
+
+	t.Run("within skew", func(t *testing.T) {
+		_, ok := ValidateTOTP(rfcSecret, code, now.Add(TOTPPeriod*time.Second), 1)
+		assert.True(t, ok)
+	})
+
+	t.Run("outside skew", func(t *testing.T) {
+		_, ok := ValidateTOTP(rfcSecret, code, now.Add(2*TOTPPeriod*time.Second), 1)
+		assert.False(t, ok)
+	})
+
+	t.Run("wrong length", func(t *testing.T) {
+		_, ok := ValidateTOTP(rfcSecret, "12345", now, 1)
+		assert.False(t, ok)
+	})
+}
+
+func TestTOTPProvisioningURI(t *testing.T) {
+	uri := TOTPProvisioningURI("Microservices Core", "alice@example.com", "JBSWY3DPEHPK3PXP")
+
+	parsed, err := url.Parse(uri)
+	require.NoError(t, err)
+	assert.Equal(t, "otpauth", parsed.Scheme)
+	assert.Equal(t, "totp", parsed.Host)
+	assert.Equal(t, "/Microservices Core:alice@example.com", parsed.Path)
+	assert.Equal(t, "JBSWY3DPEHPK3PXP", parsed.Query().Get("secret"))
+	assert.Equal(t, "Microservices Core", parsed.Query().Get("issuer"))
+}
+
+func TestGenerateRecoveryCodes(t *testing.T) {
+	codes, err := GenerateRecoveryCodes(10)
+	require.NoError(t, err)
+	assert.Len(t, codes, 10)
+
+	seen := map[string]bool{}
+	for _, c := range codes {
+		assert.Len(t, c, 11)
+		assert.Equal(t, "-", c[5:6])
+		assert.False(t, seen[c], "duplicate recovery code")
+		seen[c] = true
+	}
+
+	// 不同大小寫、少了 '-' 都應該視為同一組
+	assert.Equal(t, HashRecoveryCode(codes[0]), HashRecoveryCode(strings.ToUpper(strings.ReplaceAll(codes[0], "-", ""))))
+}
@@ -0,0 +1,89 @@
+package services
+
+import (
+	"context"
+	"log"
+
+	"user-service/models"
+)
+
+// 查詢稽核紀錄的分頁大小
+const (
+	DefaultAuditPageSize = 50
+	MaxAuditPageSize     = 200
+)
+
+// ForRequest 回傳帶有請求來源資訊的 service，寫入的稽核紀錄會記下操作者、IP、User-Agent 與 request ID
+// 回傳的是淺拷貝，共用同一組 repository，不影響原本的 service
+func (s *UserService) ForRequest(meta models.RequestMeta) UserServiceInterface {
+	scoped := *s
+	scoped.meta = meta
+	return &scoped
+}
+
+// recordAudit 寫入一筆稽核紀錄，未設定的操作者與來源資訊從目前的請求補上
+// 寫入失敗只記 log，不讓稽核影響業務操作本身
+func (s *UserService) recordAudit(ctx context.Context, event models.AuditEvent) {
+	if s.audit == nil {
+		return
+	}
+
+	event.OccurredAt = s.now()
+	if event.ActorID == "" {
+		event.ActorID = s.meta.ActorID
+	}
+	event.IP = s.meta.IP
+	event.UserAgent = s.meta.UserAgent
+	event.RequestID = s.meta.RequestID
+
+	// 操作本身已經完成，請求剛好逾時或被取消也要留下紀錄
+	if err := s.audit.Append(context.WithoutCancel(ctx), &event); err != nil {
+		log.Printf("failed to record audit event %s for user %s: %v", event.Action, event.TargetID, err)
+	}
+}
+
+// ListAuditEvents 依條件查詢稽核紀錄，由新到舊分頁
+func (s *UserService) ListAuditEvents(ctx context.Context, filter models.AuditFilter) (*models.AuditEventPage, error) {
+	if s.audit == nil {
+		return nil, ErrAuditDisabled
+	}
+
+	if filter.Limit <= 0 {
+		filter.Limit = DefaultAuditPageSize
+	}
+	if filter.Limit > MaxAuditPageSize {
+		filter.Limit = MaxAuditPageSize
+	}
+
+	// 多查一筆，用來判斷是否還有下一頁
+	pageSize := filter.Limit
+	filter.Limit++
+	events, err := s.audit.Query(ctx, filter)
+	if err != nil {
+		return nil, err
+	}
+
+	page := &models.AuditEventPage{Events: events}
+	if len(events) > pageSize {
+		page.Events = events[:pageSize]
+		page.NextCursor = page.Events[pageSize-1].ID
+	}
+	return page, nil
+}
+
+// diffUser 比對 changes 與目前的用戶資料，只留下實際改變的欄位
+func diffUser(user *models.User, changes models.UserChanges) map[string]models.FieldChange {
+	diff := map[string]models.FieldChange{}
+	compare := func(field, before string, after *string) {
+		if after != nil && *after != before {
+			diff[field] = models.FieldChange{From: before, To: *after}
+		}
+	}
+	compare("username", user.Username, changes.Username)
+	compare("display_name", user.DisplayName, changes.DisplayName)
+	compare("bio", user.Bio, changes.Bio)
+	compare("avatar_url", user.AvatarURL, changes.AvatarURL)
+	compare("locale", user.Locale, changes.Locale)
+	compare("timezone", user.Timezone, changes.Timezone)
+	return diff
+}
@@ -0,0 +1,226 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+	"user-service/models"
+)
+
+// -------------------------------------------------------------------
+// MockAuditRepository：手動實作 AuditRepositoryInterface 供測試用
+// -------------------------------------------------------------------
+
+type MockAuditRepository struct {
+	mock.Mock
+}
+
+func (m *MockAuditRepository) Append(ctx context.Context, event *models.AuditEvent) error {
+	args := m.Called(ctx, event)
+	return args.Error(0)
+}
+
+func (m *MockAuditRepository) Query(ctx context.Context, filter models.AuditFilter) ([]models.AuditEvent, error) {
+	args := m.Called(ctx, filter)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).([]models.AuditEvent), args.Error(1)
+}
+
+// captureAudit 讓 Append 成功並收集寫入的稽核紀錄
+func captureAudit(m *MockAuditRepository) *[]models.AuditEvent {
+	var events []models.AuditEvent
+	m.On("Append", mock.Anything, mock.Anything).Run(func(args mock.Arguments) {
+		events = append(events, *args.Get(1).(*models.AuditEvent))
+	}).Return(nil)
+	return &events
+}
+
+// ===================================================================
+// 稽核紀錄寫入測試
+// ===================================================================
+
+func TestAudit_UpdateUserRecordsDiffAndRequestMeta(t *testing.T) {
+	mockRepo := new(MockUserRepository)
+	mockRepo.On("FindByID", mock.Anything, "user-1").Return(&models.User{ID: "user-1", Username: "oldname"}, nil)
+	mockRepo.On("Update", mock.Anything, "user-1", "newname", 3).Return(nil)
+	mockAudit := new(MockAuditRepository)
+	events := captureAudit(mockAudit)
+
+	svc := NewUserService(mockRepo, WithAudit(mockAudit), WithClock(fixedClock)).ForRequest(models.RequestMeta{
+		ActorID: "admin-1", IP: "203.0.113.7", UserAgent: "curl/8.0", RequestID: "req-42",
+	})
+	err := svc.UpdateUser(context.Background(), "user-1", models.UpdateUserRequest{Username: "newname"}, 3)
+
+	assert.NoError(t, err)
+	require.Len(t, *events, 1)
+	assert.Equal(t, models.AuditEvent{
+		OccurredAt: fixedNow,
+		ActorID:    "admin-1",
+		TargetID:   "user-1",
+		Action:     models.AuditUserUpdated,
+		IP:         "203.0.113.7",
+		UserAgent:  "curl/8.0",
+		RequestID:  "req-42",
+		Changes:    map[string]models.FieldChange{"username": {From: "oldname", To: "newname"}},
+	}, (*events)[0])
+}
+
+func TestAudit_PatchUserRecordsOnlyChangedFields(t *testing.T) {
+	mockRepo := new(MockUserRepository)
+	mockRepo.On("FindByID", mock.Anything, "user-1").Return(&models.User{ID: "user-1", Username: "alice", Bio: "old", Version: 1}, nil)
+	mockRepo.On("Patch", mock.Anything, "user-1", mock.Anything, 1).Return(nil)
+	mockAudit := new(MockAuditRepository)
+	events := captureAudit(mockAudit)
+
+	svc := NewUserService(mockRepo, WithAudit(mockAudit))
+	_, err := svc.PatchUser(context.Background(), "user-1", models.UserChanges{Username: strPtr("alice"), Bio: strPtr("new")}, 1)
+
+	assert.NoError(t, err)
+	require.Len(t, *events, 1)
+	assert.Equal(t, map[string]models.FieldChange{"bio": {From: "old", To: "new"}}, (*events)[0].Changes)
+}
+
+func TestAudit_LoginFailureIsRecorded(t *testing.T) {
+	mockRepo := new(MockUserRepository)
+	mockRepo.On("FindByEmail", mock.Anything, "ghost@example.com").Return(nil, nil)
+	mockAudit := new(MockAuditRepository)
+	events := captureAudit(mockAudit)
+
+	svc := NewUserService(mockRepo, WithAudit(mockAudit))
+	_, err := svc.Login(context.Background(), models.LoginRequest{Email: "ghost@example.com", Password: "whatever"})
+
+	assert.Error(t, err)
+	require.Len(t, *events, 1)
+	assert.Equal(t, models.AuditLoginFailed, (*events)[0].Action)
+	assert.Equal(t, "ghost@example.com", (*events)[0].Metadata["email"])
+}
+
+func TestAudit_AppendFailureDoesNotFailOperation(t *testing.T) {
+	mockRepo := new(MockUserRepository)
+	mockRepo.On("Restore", mock.Anything, "user-1").Return(true, nil)
+	mockAudit := new(MockAuditRepository)
+	mockAudit.On("Append", mock.Anything, mock.Anything).Return(errors.New("db down"))
+
+	svc := NewUserService(mockRepo, WithAudit(mockAudit))
+	err := svc.RestoreUser(context.Background(), "user-1")
+
+	assert.NoError(t, err)
+	mockAudit.AssertExpectations(t)
+}
+
+func TestAudit_ForRequestDoesNotChangeOriginal(t *testing.T) {
+	mockRepo := new(MockUserRepository)
+	mockRepo.On("Restore", mock.Anything, "user-1").Return(true, nil)
+	mockAudit := new(MockAuditRepository)
+	events := captureAudit(mockAudit)
+
+	svc := NewUserService(mockRepo, WithAudit(mockAudit))
+	svc.ForRequest(models.RequestMeta{ActorID: "admin-1"})
+	svc.RestoreUser(context.Background(), "user-1")
+
+	require.Len(t, *events, 1)
+	assert.Empty(t, (*events)[0].ActorID)
+}
+
+// ===================================================================
+// 請求 context 測試
+// ===================================================================
+
+// expiredContext 回傳 deadline 已經過去的 context，模擬 gateway 逾時後才送到的請求
+func expiredContext(t *testing.T) context.Context {
+	ctx, cancel := context.WithDeadline(context.Background(), fixedNow)
+	t.Cleanup(cancel)
+	return ctx
+}
+
+// isExpired 比對 repository 收到的是否為已逾時的請求 context
+var isExpired = mock.MatchedBy(func(ctx context.Context) bool {
+	return errors.Is(ctx.Err(), context.DeadlineExceeded)
+})
+
+func TestRequestContextIsPassedToRepository(t *testing.T) {
+	mockRepo := new(MockUserRepository)
+	// 實際的 repository 會以 QueryRowContext 送出查詢，ctx 已逾時時由 database/sql 直接回傳 ctx.Err()
+	mockRepo.On("FindByID", isExpired, "user-1").Return(nil, context.DeadlineExceeded)
+
+	svc := NewUserService(mockRepo)
+	user, err := svc.GetUserByID(expiredContext(t), "user-1")
+
+	assert.Nil(t, user)
+	assert.ErrorIs(t, err, context.DeadlineExceeded)
+	mockRepo.AssertExpectations(t)
+}
+
+func TestAuditAndRevocationOutliveRequest(t *testing.T) {
+	mockRepo := new(MockUserRepository)
+	mockRepo.On("Delete", mock.Anything, "user-1", 2).Return(nil)
+	mockRevocations := new(MockTokenRevocationRepository)
+	live := mock.MatchedBy(func(ctx context.Context) bool { return ctx.Err() == nil })
+	mockRevocations.On("RevokeAllForUser", live, "user-1", fixedNow).Return(nil)
+	mockAudit := new(MockAuditRepository)
+	mockAudit.On("Append", live, mock.Anything).Return(nil)
+
+	// 刪除已經寫入後請求才逾時，token 撤銷與稽核紀錄仍要完成
+	svc := NewUserService(mockRepo, WithTokenRevocation(mockRevocations), WithAudit(mockAudit), WithClock(fixedClock))
+	err := svc.DeleteUser(expiredContext(t), "user-1", 2)
+
+	assert.NoError(t, err)
+	mockRevocations.AssertExpectations(t)
+	mockAudit.AssertExpectations(t)
+}
+
+// ===================================================================
+// ListAuditEvents 測試
+// ===================================================================
+
+func TestListAuditEvents(t *testing.T) {
+	t.Run("returns next cursor when more events exist", func(t *testing.T) {
+		mockAudit := new(MockAuditRepository)
+		mockAudit.On("Query", mock.Anything, models.AuditFilter{Action: models.AuditUserDeleted, Limit: 3}).
+			Return([]models.AuditEvent{{ID: 9}, {ID: 7}, {ID: 4}}, nil)
+
+		svc := NewUserService(new(MockUserRepository), WithAudit(mockAudit))
+		page, err := svc.ListAuditEvents(context.Background(), models.AuditFilter{Action: models.AuditUserDeleted, Limit: 2})
+
+		assert.NoError(t, err)
+		assert.Len(t, page.Events, 2)
+		assert.Equal(t, int64(7), page.NextCursor)
+	})
+
+	t.Run("last page has no cursor", func(t *testing.T) {
+		mockAudit := new(MockAuditRepository)
+		mockAudit.On("Query", mock.Anything, models.AuditFilter{Limit: DefaultAuditPageSize + 1}).
+			Return([]models.AuditEvent{{ID: 1}}, nil)
+
+		svc := NewUserService(new(MockUserRepository), WithAudit(mockAudit))
+		page, err := svc.ListAuditEvents(context.Background(), models.AuditFilter{})
+
+		assert.NoError(t, err)
+		assert.Len(t, page.Events, 1)
+		assert.Zero(t, page.NextCursor)
+	})
+
+	t.Run("limit is capped", func(t *testing.T) {
+		mockAudit := new(MockAuditRepository)
+		mockAudit.On("Query", mock.Anything, models.AuditFilter{Limit: MaxAuditPageSize + 1}).Return([]models.AuditEvent{}, nil)
+
+		svc := NewUserService(new(MockUserRepository), WithAudit(mockAudit))
+		_, err := svc.ListAuditEvents(context.Background(), models.AuditFilter{Limit: 10000})
+
+		assert.NoError(t, err)
+		mockAudit.AssertExpectations(t)
+	})
+
+	t.Run("not configured", func(t *testing.T) {
+		svc := NewUserService(new(MockUserRepository))
+		_, err := svc.ListAuditEvents(context.Background(), models.AuditFilter{})
+
+		assert.ErrorIs(t, err, ErrAuditDisabled)
+	})
+}
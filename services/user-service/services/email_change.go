@@ -0,0 +1,153 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/url"
+	"strings"
+
+	"github.com/google/uuid"
+	"user-service/models"
+	"user-service/repository"
+)
+
+// RequestEmailChange 申請變更 email
+//
+// 新 email 在確認前不會生效：確認信寄到新 email，
+// 同時通知舊 email，讓帳號被盜用時本人能察覺
+func (s *UserService) RequestEmailChange(ctx context.Context, userID string, req models.EmailChangeRequest) error {
+	if s.emailChangeRepo == nil {
+		return ErrEmailChangeDisabled
+	}
+
+	user, err := s.GetUserByID(ctx, userID)
+	if err != nil {
+		return err
+	}
+	if ok, err := s.hasher.Verify(req.CurrentPassword, user.Password); err != nil || !ok {
+		return ErrInvalidCurrentPassword
+	}
+
+	newEmail := strings.TrimSpace(req.NewEmail)
+	if strings.EqualFold(newEmail, user.Email) {
+		return &ValidationError{Fields: []FieldError{{Field: "new_email", Message: "new email is the same as the current one"}}}
+	}
+	// 這裡先擋一次讓用戶早點知道，確認時還會再檢查
+	if err := s.ensureEmailAvailable(ctx, newEmail); err != nil {
+		return err
+	}
+
+	rawToken, err := generateResetToken()
+	if err != nil {
+		return err
+	}
+
+	now := s.now()
+	// 同一時間只保留最新的一筆申請，先前寄出的確認連結一律作廢
+	if err := s.emailChangeRepo.InvalidateAllForUser(ctx, user.ID, now); err != nil {
+		return err
+	}
+	token := &models.EmailChangeToken{
+		ID:        uuid.New().String(),
+		UserID:    user.ID,
+		NewEmail:  newEmail,
+		TokenHash: hashResetToken(rawToken),
+		ExpiresAt: now.Add(s.emailChangeTTL),
+	}
+	if err := s.emailChangeRepo.Create(ctx, token); err != nil {
+		return err
+	}
+
+	link := s.emailChangeURL + "?token=" + url.QueryEscape(rawToken)
+	confirmBody := fmt.Sprintf(
+		"我們收到了將帳號 email 變更為此信箱的請求，請在 %s 內點擊以下連結確認：\n%s\n\n如果這不是你本人的操作，請忽略這封信。",
+		s.emailChangeTTL, link,
+	)
+	if err := s.mailer.Send(newEmail, "確認新的 email", confirmBody); err != nil {
+		return fmt.Errorf("failed to send confirmation email: %w", err)
+	}
+
+	noticeBody := fmt.Sprintf(
+		"你的帳號申請將 email 變更為 %s，變更會在新信箱確認後生效。\n\n如果這不是你本人的操作，請立即修改密碼。",
+		newEmail,
+	)
+	if err := s.mailer.Send(user.Email, "帳號 email 變更通知", noticeBody); err != nil {
+		return fmt.Errorf("failed to send email change notice: %w", err)
+	}
+
+	s.recordAudit(ctx, models.AuditEvent{
+		TargetID: user.ID,
+		Action:   models.AuditEmailChangeRequested,
+		Metadata: map[string]string{"new_email": newEmail},
+	})
+	return nil
+}
+
+// ConfirmEmailChange 以確認信中的 token 完成 email 變更
+//
+// 變更完成後撤銷該用戶所有已簽發的 JWT，避免 claims 中仍帶著舊 email 的 token 繼續被使用
+func (s *UserService) ConfirmEmailChange(ctx context.Context, req models.ConfirmEmailChangeRequest) error {
+	if s.emailChangeRepo == nil {
+		return ErrEmailChangeDisabled
+	}
+
+	token, err := s.emailChangeRepo.FindByTokenHash(ctx, hashResetToken(req.Token))
+	if err != nil {
+		return err
+	}
+	now := s.now()
+	if token == nil || token.UsedAt != nil || !now.Before(token.ExpiresAt) {
+		return ErrInvalidEmailChangeToken
+	}
+
+	// 申請之後這個 email 可能已被別人註冊走
+	if err := s.ensureEmailAvailable(ctx, token.NewEmail); err != nil {
+		return err
+	}
+
+	// 只有開啟稽核時才需要舊的 email 來記錄 diff
+	var oldEmail string
+	if s.audit != nil {
+		user, err := s.GetUserByID(ctx, token.UserID)
+		if err != nil {
+			return err
+		}
+		oldEmail = user.Email
+	}
+
+	// 消耗 token 與寫入 email 在同一個 transaction，email 被搶先註冊時 token 仍可再用
+	changed, err := s.emailChangeRepo.ConfirmEmailChange(ctx, token.ID, token.UserID, token.NewEmail, now)
+	if err != nil {
+		if errors.Is(err, repository.ErrDuplicateEmail) {
+			return ErrEmailTaken
+		}
+		return err
+	}
+	if !changed {
+		return ErrInvalidEmailChangeToken
+	}
+	s.recordAudit(ctx, models.AuditEvent{
+		TargetID: token.UserID,
+		Action:   models.AuditEmailChanged,
+		Changes:  map[string]models.FieldChange{"email": {From: oldEmail, To: token.NewEmail}},
+	})
+
+	if err := s.revokeAllTokens(ctx, token.UserID, now); err != nil {
+		return err
+	}
+
+	return nil
+}
+
+// ensureEmailAvailable 確認 email 沒有被其他用戶使用
+func (s *UserService) ensureEmailAvailable(ctx context.Context, email string) error {
+	existing, err := s.repo.FindByEmail(ctx, email)
+	if err != nil {
+		return fmt.Errorf("failed to check existing user: %w", err)
+	}
+	if existing != nil {
+		return ErrEmailTaken
+	}
+	return nil
+}
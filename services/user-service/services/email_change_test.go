@@ -0,0 +1,265 @@
+package services
+
+import (
+	"context"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/crypto/bcrypt"
+	"user-service/models"
+	"user-service/repository"
+)
+
+// -------------------------------------------------------------------
+// 變更 email 流程用到的 mock
+// -------------------------------------------------------------------
+
+type MockEmailChangeRepository struct {
+	mock.Mock
+}
+
+func (m *MockEmailChangeRepository) Create(ctx context.Context, token *models.EmailChangeToken) error {
+	args := m.Called(ctx, token)
+	return args.Error(0)
+}
+
+func (m *MockEmailChangeRepository) FindByTokenHash(ctx context.Context, tokenHash string) (*models.EmailChangeToken, error) {
+	args := m.Called(ctx, tokenHash)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*models.EmailChangeToken), args.Error(1)
+}
+
+func (m *MockEmailChangeRepository) ConfirmEmailChange(ctx context.Context, tokenID, userID, newEmail string, at time.Time) (bool, error) {
+	args := m.Called(ctx, tokenID, userID, newEmail, at)
+	return args.Bool(0), args.Error(1)
+}
+
+func (m *MockEmailChangeRepository) InvalidateAllForUser(ctx context.Context, userID string, at time.Time) error {
+	args := m.Called(ctx, userID, at)
+	return args.Error(0)
+}
+
+// emailChangeUser 變更 email 測試用的用戶，密碼為 "currentpassword"
+func emailChangeUser(t *testing.T) *models.User {
+	t.Helper()
+	hashed, err := bcrypt.GenerateFromPassword([]byte("currentpassword"), bcrypt.MinCost)
+	require.NoError(t, err)
+	return &models.User{ID: "user-1", Email: "old@example.com", Username: "alice", Password: string(hashed)}
+}
+
+// ===================================================================
+// RequestEmailChange 測試
+// ===================================================================
+
+func TestRequestEmailChange(t *testing.T) {
+	req := models.EmailChangeRequest{NewEmail: "new@example.com", CurrentPassword: "currentpassword"}
+
+	t.Run("success - mails new address and notifies old", func(t *testing.T) {
+		mockRepo := new(MockUserRepository)
+		mockRepo.On("FindByID", mock.Anything, "user-1").Return(emailChangeUser(t), nil)
+		mockRepo.On("FindByEmail", mock.Anything, "new@example.com").Return(nil, nil)
+
+		var stored *models.EmailChangeToken
+		mockChange := new(MockEmailChangeRepository)
+		mockChange.On("InvalidateAllForUser", mock.Anything, "user-1", fixedNow).Return(nil)
+		mockChange.On("Create", mock.Anything, mock.AnythingOfType("*models.EmailChangeToken")).
+			Run(func(args mock.Arguments) { stored = args.Get(1).(*models.EmailChangeToken) }).
+			Return(nil)
+
+		var confirmBody string
+		mockMail := new(MockMailer)
+		mockMail.On("Send", "new@example.com", mock.Anything, mock.Anything).
+			Run(func(args mock.Arguments) { confirmBody = args.String(2) }).
+			Return(nil)
+		mockMail.On("Send", "old@example.com", mock.Anything, mock.Anything).Return(nil)
+
+		svc := NewUserService(mockRepo,
+			WithEmailChange(mockChange, mockMail, "http://app/confirm-email", 24*time.Hour),
+			WithClock(fixedClock),
+		)
+		err := svc.RequestEmailChange(context.Background(), "user-1", req)
+
+		assert.NoError(t, err)
+		require.NotNil(t, stored)
+		assert.Equal(t, "new@example.com", stored.NewEmail)
+		assert.Equal(t, fixedNow.Add(24*time.Hour), stored.ExpiresAt)
+
+		// 信中的 token 與 DB 存的 hash 對得上，DB 裡沒有明文
+		match := tokenInLink.FindStringSubmatch(confirmBody)
+		require.Len(t, match, 2)
+		rawToken, _ := url.QueryUnescape(match[1])
+		assert.Equal(t, hashResetToken(rawToken), stored.TokenHash)
+		assert.NotEqual(t, rawToken, stored.TokenHash)
+		mockMail.AssertExpectations(t)
+	})
+
+	t.Run("wrong current password", func(t *testing.T) {
+		mockRepo := new(MockUserRepository)
+		mockRepo.On("FindByID", mock.Anything, "user-1").Return(emailChangeUser(t), nil)
+		mockChange := new(MockEmailChangeRepository)
+
+		svc := NewUserService(mockRepo, WithEmailChange(mockChange, new(MockMailer), "http://app", time.Hour))
+		err := svc.RequestEmailChange(context.Background(), "user-1", models.EmailChangeRequest{NewEmail: "new@example.com", CurrentPassword: "wrong"})
+
+		assert.ErrorIs(t, err, ErrInvalidCurrentPassword)
+		mockChange.AssertNotCalled(t, "Create", mock.Anything, mock.Anything)
+	})
+
+	t.Run("email already taken", func(t *testing.T) {
+		mockRepo := new(MockUserRepository)
+		mockRepo.On("FindByID", mock.Anything, "user-1").Return(emailChangeUser(t), nil)
+		mockRepo.On("FindByEmail", mock.Anything, "new@example.com").Return(&models.User{ID: "user-2"}, nil)
+		mockChange := new(MockEmailChangeRepository)
+
+		svc := NewUserService(mockRepo, WithEmailChange(mockChange, new(MockMailer), "http://app", time.Hour))
+		err := svc.RequestEmailChange(context.Background(), "user-1", req)
+
+		assert.ErrorIs(t, err, ErrEmailTaken)
+		mockChange.AssertNotCalled(t, "Create", mock.Anything, mock.Anything)
+	})
+
+	t.Run("same as current", func(t *testing.T) {
+		mockRepo := new(MockUserRepository)
+		mockRepo.On("FindByID", mock.Anything, "user-1").Return(emailChangeUser(t), nil)
+
+		svc := NewUserService(mockRepo, WithEmailChange(new(MockEmailChangeRepository), new(MockMailer), "http://app", time.Hour))
+		err := svc.RequestEmailChange(context.Background(), "user-1", models.EmailChangeRequest{NewEmail: "OLD@example.com", CurrentPassword: "currentpassword"})
+
+		var validationErr *ValidationError
+		assert.ErrorAs(t, err, &validationErr)
+	})
+
+	t.Run("not configured", func(t *testing.T) {
+		svc := NewUserService(new(MockUserRepository))
+		err := svc.RequestEmailChange(context.Background(), "user-1", req)
+
+		assert.ErrorIs(t, err, ErrEmailChangeDisabled)
+	})
+}
+
+// ===================================================================
+// ConfirmEmailChange 測試
+// ===================================================================
+
+func TestConfirmEmailChange(t *testing.T) {
+	pending := func() *models.EmailChangeToken {
+		return &models.EmailChangeToken{
+			ID:        "token-1",
+			UserID:    "user-1",
+			NewEmail:  "new@example.com",
+			TokenHash: hashResetToken("raw-token"),
+			ExpiresAt: fixedNow.Add(time.Hour),
+		}
+	}
+
+	t.Run("success - updates email and revokes old tokens", func(t *testing.T) {
+		mockRepo := new(MockUserRepository)
+		mockRepo.On("FindByEmail", mock.Anything, "new@example.com").Return(nil, nil)
+		mockChange := new(MockEmailChangeRepository)
+		mockChange.On("FindByTokenHash", mock.Anything, hashResetToken("raw-token")).Return(pending(), nil)
+		mockChange.On("ConfirmEmailChange", mock.Anything, "token-1", "user-1", "new@example.com", fixedNow).Return(true, nil)
+		mockRevocations := new(MockTokenRevocationRepository)
+		mockRevocations.On("RevokeAllForUser", mock.Anything, "user-1", fixedNow).Return(nil)
+
+		svc := NewUserService(mockRepo,
+			WithEmailChange(mockChange, new(MockMailer), "http://app", time.Hour),
+			WithTokenRevocation(mockRevocations),
+			WithClock(fixedClock),
+		)
+		err := svc.ConfirmEmailChange(context.Background(), models.ConfirmEmailChangeRequest{Token: "raw-token"})
+
+		assert.NoError(t, err)
+		mockChange.AssertExpectations(t)
+		mockRevocations.AssertExpectations(t)
+	})
+
+	t.Run("email taken since the request", func(t *testing.T) {
+		mockRepo := new(MockUserRepository)
+		mockRepo.On("FindByEmail", mock.Anything, "new@example.com").Return(&models.User{ID: "user-2"}, nil)
+		mockChange := new(MockEmailChangeRepository)
+		mockChange.On("FindByTokenHash", mock.Anything, hashResetToken("raw-token")).Return(pending(), nil)
+
+		svc := NewUserService(mockRepo,
+			WithEmailChange(mockChange, new(MockMailer), "http://app", time.Hour),
+			WithClock(fixedClock),
+		)
+		err := svc.ConfirmEmailChange(context.Background(), models.ConfirmEmailChangeRequest{Token: "raw-token"})
+
+		assert.ErrorIs(t, err, ErrEmailTaken)
+		mockChange.AssertNotCalled(t, "ConfirmEmailChange", mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything)
+	})
+
+	t.Run("unique constraint wins a race", func(t *testing.T) {
+		mockRepo := new(MockUserRepository)
+		mockRepo.On("FindByEmail", mock.Anything, "new@example.com").Return(nil, nil)
+		mockChange := new(MockEmailChangeRepository)
+		mockChange.On("FindByTokenHash", mock.Anything, hashResetToken("raw-token")).Return(pending(), nil)
+		// transaction 因 unique constraint 回滾，token 沒有被消耗
+		mockChange.On("ConfirmEmailChange", mock.Anything, "token-1", "user-1", "new@example.com", fixedNow).
+			Return(false, repository.ErrDuplicateEmail)
+		mockRevocations := new(MockTokenRevocationRepository)
+
+		svc := NewUserService(mockRepo,
+			WithEmailChange(mockChange, new(MockMailer), "http://app", time.Hour),
+			WithTokenRevocation(mockRevocations),
+			WithClock(fixedClock),
+		)
+		err := svc.ConfirmEmailChange(context.Background(), models.ConfirmEmailChangeRequest{Token: "raw-token"})
+
+		assert.ErrorIs(t, err, ErrEmailTaken)
+		mockRevocations.AssertNotCalled(t, "RevokeAllForUser", mock.Anything, mock.Anything, mock.Anything)
+	})
+
+	t.Run("token consumed by a concurrent request", func(t *testing.T) {
+		mockRepo := new(MockUserRepository)
+		mockRepo.On("FindByEmail", mock.Anything, "new@example.com").Return(nil, nil)
+		mockChange := new(MockEmailChangeRepository)
+		mockChange.On("FindByTokenHash", mock.Anything, hashResetToken("raw-token")).Return(pending(), nil)
+		mockChange.On("ConfirmEmailChange", mock.Anything, "token-1", "user-1", "new@example.com", fixedNow).Return(false, nil)
+
+		svc := NewUserService(mockRepo,
+			WithEmailChange(mockChange, new(MockMailer), "http://app", time.Hour),
+			WithClock(fixedClock),
+		)
+		err := svc.ConfirmEmailChange(context.Background(), models.ConfirmEmailChangeRequest{Token: "raw-token"})
+
+		assert.ErrorIs(t, err, ErrInvalidEmailChangeToken)
+	})
+
+	t.Run("expired token", func(t *testing.T) {
+		expired := pending()
+		expired.ExpiresAt = fixedNow.Add(-time.Second)
+		mockChange := new(MockEmailChangeRepository)
+		mockChange.On("FindByTokenHash", mock.Anything, hashResetToken("raw-token")).Return(expired, nil)
+
+		svc := NewUserService(new(MockUserRepository),
+			WithEmailChange(mockChange, new(MockMailer), "http://app", time.Hour),
+			WithClock(fixedClock),
+		)
+		err := svc.ConfirmEmailChange(context.Background(), models.ConfirmEmailChangeRequest{Token: "raw-token"})
+
+		assert.ErrorIs(t, err, ErrInvalidEmailChangeToken)
+	})
+
+	t.Run("already used", func(t *testing.T) {
+		used := pending()
+		usedAt := fixedNow.Add(-time.Minute)
+		used.UsedAt = &usedAt
+		mockChange := new(MockEmailChangeRepository)
+		mockChange.On("FindByTokenHash", mock.Anything, hashResetToken("raw-token")).Return(used, nil)
+
+		svc := NewUserService(new(MockUserRepository),
+			WithEmailChange(mockChange, new(MockMailer), "http://app", time.Hour),
+			WithClock(fixedClock),
+		)
+		err := svc.ConfirmEmailChange(context.Background(), models.ConfirmEmailChangeRequest{Token: "raw-token"})
+
+		assert.ErrorIs(t, err, ErrInvalidEmailChangeToken)
+	})
+}
@@ -0,0 +1,22 @@
+package services
+
+import "errors"
+
+// 可被 handler 層以 errors.Is 判斷、對應到不同 HTTP status 的錯誤
+var (
+	ErrUserNotFound            = errors.New("user not found")
+	ErrInvalidResetToken       = errors.New("invalid or expired reset token")
+	ErrPasswordResetDisabled   = errors.New("password reset is not configured")
+	ErrInvalidCurrentPassword  = errors.New("current password is incorrect")
+	ErrMFADisabled             = errors.New("mfa is not configured")
+	ErrMFAAlreadyEnabled       = errors.New("mfa is already enabled")
+	ErrMFANotEnrolled          = errors.New("mfa is not enrolled")
+	ErrInvalidMFACode          = errors.New("invalid mfa code")
+	ErrVersionConflict         = errors.New("user has been modified by another request")
+	ErrEmailChangeDisabled     = errors.New("email change is not configured")
+	ErrInvalidEmailChangeToken = errors.New("invalid or expired email change token")
+	ErrEmailTaken              = errors.New("email already exists")
+	ErrAuditDisabled           = errors.New("audit log is not configured")
+	ErrSessionsDisabled        = errors.New("session tracking is not configured")
+	ErrSessionNotFound         = errors.New("session not found")
+)
@@ -0,0 +1,186 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"unicode"
+
+	"user-service/models"
+	"user-service/security"
+)
+
+// recoveryCodeCount 啟用 MFA 時產生的復原碼數量
+const recoveryCodeCount = 10
+
+// totpSkew 驗證 TOTP 時允許前後各一個時間區間的時鐘誤差
+const totpSkew = 1
+
+// EnrollTOTP 開始綁定 TOTP：產生新的 secret 並回傳 provisioning URI
+// 此時 MFA 尚未生效，必須呼叫 ConfirmTOTP 驗證一次 code 才會啟用
+func (s *UserService) EnrollTOTP(ctx context.Context, userID string) (*models.TOTPEnrollment, error) {
+	if s.mfaRepo == nil {
+		return nil, ErrMFADisabled
+	}
+
+	user, err := s.repo.FindByID(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	if user == nil {
+		return nil, ErrUserNotFound
+	}
+
+	settings, err := s.mfaRepo.FindByUserID(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	if settings != nil && settings.Enabled {
+		return nil, ErrMFAAlreadyEnabled
+	}
+
+	secret, err := security.GenerateTOTPSecret()
+	if err != nil {
+		return nil, err
+	}
+	if err := s.mfaRepo.SavePending(ctx, userID, secret); err != nil {
+		return nil, err
+	}
+
+	return &models.TOTPEnrollment{
+		Secret:          secret,
+		ProvisioningURI: security.TOTPProvisioningURI(s.mfaIssuer, user.Email, secret),
+	}, nil
+}
+
+// ConfirmTOTP 以 authenticator App 產生的 code 確認綁定，成功後啟用 MFA
+// 回傳的復原碼為明文，只會在這裡出現一次，DB 只存 hash
+func (s *UserService) ConfirmTOTP(ctx context.Context, userID, code string) ([]string, error) {
+	if s.mfaRepo == nil {
+		return nil, ErrMFADisabled
+	}
+
+	settings, err := s.mfaRepo.FindByUserID(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	if settings == nil {
+		return nil, ErrMFANotEnrolled
+	}
+	if settings.Enabled {
+		return nil, ErrMFAAlreadyEnabled
+	}
+
+	now := s.now()
+	step, ok := security.ValidateTOTP(settings.Secret, code, now, totpSkew)
+	if !ok {
+		return nil, ErrInvalidMFACode
+	}
+
+	codes, err := security.GenerateRecoveryCodes(recoveryCodeCount)
+	if err != nil {
+		return nil, err
+	}
+	hashes := make([]string, len(codes))
+	for i, c := range codes {
+		hashes[i] = security.HashRecoveryCode(c)
+	}
+
+	if err := s.mfaRepo.Enable(ctx, userID, now, step, hashes); err != nil {
+		return nil, err
+	}
+
+	s.recordAudit(ctx, models.AuditEvent{TargetID: userID, Action: models.AuditMFAEnabled})
+	return codes, nil
+}
+
+// VerifyMFA 兩階段登入的第二步：驗證 TOTP code 或復原碼
+func (s *UserService) VerifyMFA(ctx context.Context, userID, code string) (*models.User, error) {
+	if s.mfaRepo == nil {
+		return nil, ErrMFADisabled
+	}
+
+	if err := s.verifyMFACode(ctx, userID, code); err != nil {
+		if errors.Is(err, ErrInvalidMFACode) {
+			s.recordAudit(ctx, models.AuditEvent{TargetID: userID, Action: models.AuditMFAFailed})
+		}
+		return nil, err
+	}
+
+	user, err := s.repo.FindByID(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	if user == nil {
+		return nil, ErrUserNotFound
+	}
+
+	s.recordAudit(ctx, models.AuditEvent{ActorID: userID, TargetID: userID, Action: models.AuditMFAVerified})
+	s.recordAudit(ctx, models.AuditEvent{ActorID: userID, TargetID: userID, Action: models.AuditLoginSucceeded})
+	return user, nil
+}
+
+// DisableMFA 停用 MFA，需提供目前有效的 TOTP code 或復原碼
+func (s *UserService) DisableMFA(ctx context.Context, userID, code string) error {
+	if s.mfaRepo == nil {
+		return ErrMFADisabled
+	}
+
+	if err := s.verifyMFACode(ctx, userID, code); err != nil {
+		return err
+	}
+	if err := s.mfaRepo.Delete(ctx, userID); err != nil {
+		return err
+	}
+
+	s.recordAudit(ctx, models.AuditEvent{TargetID: userID, Action: models.AuditMFADisabled})
+	return nil
+}
+
+// verifyMFACode 6 位數字視為 TOTP code，其餘視為復原碼
+// 兩者都是一次性的：TOTP 記錄已使用的時間區間，復原碼直接標記為已使用
+func (s *UserService) verifyMFACode(ctx context.Context, userID, code string) error {
+	settings, err := s.mfaRepo.FindByUserID(ctx, userID)
+	if err != nil {
+		return err
+	}
+	if settings == nil || !settings.Enabled {
+		return ErrMFANotEnrolled
+	}
+
+	now := s.now()
+	if isTOTPCode(code) {
+		step, ok := security.ValidateTOTP(settings.Secret, code, now, totpSkew)
+		if !ok {
+			return ErrInvalidMFACode
+		}
+		fresh, err := s.mfaRepo.MarkStepUsed(ctx, userID, step)
+		if err != nil {
+			return err
+		}
+		if !fresh {
+			return ErrInvalidMFACode
+		}
+		return nil
+	}
+
+	used, err := s.mfaRepo.UseRecoveryCode(ctx, userID, security.HashRecoveryCode(code), now)
+	if err != nil {
+		return err
+	}
+	if !used {
+		return ErrInvalidMFACode
+	}
+	return nil
+}
+
+func isTOTPCode(code string) bool {
+	if len(code) != security.TOTPDigits {
+		return false
+	}
+	for _, r := range code {
+		if !unicode.IsDigit(r) {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,264 @@
+package services
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+	"user-service/models"
+	"user-service/security"
+)
+
+// -------------------------------------------------------------------
+// MockMFARepository：手動實作 MFARepositoryInterface 供測試用
+// -------------------------------------------------------------------
+
+type MockMFARepository struct {
+	mock.Mock
+}
+
+func (m *MockMFARepository) FindByUserID(ctx context.Context, userID string) (*models.MFASettings, error) {
+	args := m.Called(ctx, userID)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*models.MFASettings), args.Error(1)
+}
+
+func (m *MockMFARepository) SavePending(ctx context.Context, userID, secret string) error {
+	args := m.Called(ctx, userID, secret)
+	return args.Error(0)
+}
+
+func (m *MockMFARepository) Enable(ctx context.Context, userID string, confirmedAt time.Time, usedStep int64, recoveryCodeHashes []string) error {
+	args := m.Called(ctx, userID, confirmedAt, usedStep, recoveryCodeHashes)
+	return args.Error(0)
+}
+
+func (m *MockMFARepository) MarkStepUsed(ctx context.Context, userID string, step int64) (bool, error) {
+	args := m.Called(ctx, userID, step)
+	return args.Bool(0), args.Error(1)
+}
+
+func (m *MockMFARepository) UseRecoveryCode(ctx context.Context, userID, codeHash string, usedAt time.Time) (bool, error) {
+	args := m.Called(ctx, userID, codeHash, usedAt)
+	return args.Bool(0), args.Error(1)
+}
+
+func (m *MockMFARepository) Delete(ctx context.Context, userID string) error {
+	args := m.Called(ctx, userID)
+	return args.Error(0)
+}
+
+// testTOTPSecret 固定的 secret，搭配 fixedNow 可以算出確定的 code
+const testTOTPSecret = "JBSWY3DPEHPK3PXP"
+
+// codeAt 計算 testTOTPSecret 在時間 t 的 code
+func codeAt(t *testing.T, at time.Time) string {
+	t.Helper()
+	code, err := security.TOTPCode(testTOTPSecret, security.TOTPStep(at))
+	require.NoError(t, err)
+	return code
+}
+
+// ===================================================================
+// EnrollTOTP 測試
+// ===================================================================
+
+func TestEnrollTOTP(t *testing.T) {
+	t.Run("success", func(t *testing.T) {
+		mockRepo := new(MockUserRepository)
+		mockRepo.On("FindByID", mock.Anything, "user-1").Return(&models.User{ID: "user-1", Email: "alice@example.com"}, nil)
+		mockMFA := new(MockMFARepository)
+		mockMFA.On("FindByUserID", mock.Anything, "user-1").Return(nil, nil)
+		mockMFA.On("SavePending", mock.Anything, "user-1", mock.AnythingOfType("string")).Return(nil)
+
+		svc := NewUserService(mockRepo, WithMFA(mockMFA, "Core"))
+		enrollment, err := svc.EnrollTOTP(context.Background(), "user-1")
+
+		assert.NoError(t, err)
+		require.NotNil(t, enrollment)
+		assert.NotEmpty(t, enrollment.Secret)
+		assert.Contains(t, enrollment.ProvisioningURI, "otpauth://totp/Core:alice@example.com")
+		assert.Contains(t, enrollment.ProvisioningURI, "secret="+enrollment.Secret)
+		mockMFA.AssertExpectations(t)
+	})
+
+	t.Run("already enabled", func(t *testing.T) {
+		mockRepo := new(MockUserRepository)
+		mockRepo.On("FindByID", mock.Anything, "user-1").Return(&models.User{ID: "user-1"}, nil)
+		mockMFA := new(MockMFARepository)
+		mockMFA.On("FindByUserID", mock.Anything, "user-1").Return(&models.MFASettings{UserID: "user-1", Enabled: true}, nil)
+
+		svc := NewUserService(mockRepo, WithMFA(mockMFA, "Core"))
+		_, err := svc.EnrollTOTP(context.Background(), "user-1")
+
+		assert.ErrorIs(t, err, ErrMFAAlreadyEnabled)
+		mockMFA.AssertNotCalled(t, "SavePending", mock.Anything, mock.Anything, mock.Anything)
+	})
+
+	t.Run("not configured", func(t *testing.T) {
+		svc := NewUserService(new(MockUserRepository))
+		_, err := svc.EnrollTOTP(context.Background(), "user-1")
+
+		assert.ErrorIs(t, err, ErrMFADisabled)
+	})
+}
+
+// ===================================================================
+// ConfirmTOTP 測試
+// ===================================================================
+
+func TestConfirmTOTP(t *testing.T) {
+	pending := func() *models.MFASettings {
+		return &models.MFASettings{UserID: "user-1", Secret: testTOTPSecret}
+	}
+
+	t.Run("success - enables and returns hashed recovery codes", func(t *testing.T) {
+		var storedHashes []string
+		mockMFA := new(MockMFARepository)
+		mockMFA.On("FindByUserID", mock.Anything, "user-1").Return(pending(), nil)
+		mockMFA.On("Enable", mock.Anything, "user-1", fixedNow, security.TOTPStep(fixedNow), mock.Anything).
+			Run(func(args mock.Arguments) { storedHashes = args.Get(4).([]string) }).
+			Return(nil)
+
+		svc := NewUserService(new(MockUserRepository), WithMFA(mockMFA, "Core"), WithClock(fixedClock))
+		codes, err := svc.ConfirmTOTP(context.Background(), "user-1", codeAt(t, fixedNow))
+
+		assert.NoError(t, err)
+		assert.Len(t, codes, recoveryCodeCount)
+		require.Len(t, storedHashes, recoveryCodeCount)
+		// DB 存的是 hash，不是明文
+		assert.Equal(t, security.HashRecoveryCode(codes[0]), storedHashes[0])
+		assert.NotEqual(t, codes[0], storedHashes[0])
+		mockMFA.AssertExpectations(t)
+	})
+
+	t.Run("code from a minute ago is rejected", func(t *testing.T) {
+		mockMFA := new(MockMFARepository)
+		mockMFA.On("FindByUserID", mock.Anything, "user-1").Return(pending(), nil)
+
+		svc := NewUserService(new(MockUserRepository), WithMFA(mockMFA, "Core"), WithClock(fixedClock))
+		_, err := svc.ConfirmTOTP(context.Background(), "user-1", codeAt(t, fixedNow.Add(-time.Minute)))
+
+		assert.ErrorIs(t, err, ErrInvalidMFACode)
+		mockMFA.AssertNotCalled(t, "Enable", mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything)
+	})
+
+	t.Run("not enrolled", func(t *testing.T) {
+		mockMFA := new(MockMFARepository)
+		mockMFA.On("FindByUserID", mock.Anything, "user-1").Return(nil, nil)
+
+		svc := NewUserService(new(MockUserRepository), WithMFA(mockMFA, "Core"), WithClock(fixedClock))
+		_, err := svc.ConfirmTOTP(context.Background(), "user-1", "123456")
+
+		assert.ErrorIs(t, err, ErrMFANotEnrolled)
+	})
+}
+
+// ===================================================================
+// VerifyMFA 測試
+// ===================================================================
+
+func TestVerifyMFA(t *testing.T) {
+	enabled := func() *models.MFASettings {
+		return &models.MFASettings{UserID: "user-1", Secret: testTOTPSecret, Enabled: true}
+	}
+
+	t.Run("valid totp code", func(t *testing.T) {
+		mockMFA := new(MockMFARepository)
+		mockMFA.On("FindByUserID", mock.Anything, "user-1").Return(enabled(), nil)
+		mockMFA.On("MarkStepUsed", mock.Anything, "user-1", security.TOTPStep(fixedNow)).Return(true, nil)
+		mockRepo := new(MockUserRepository)
+		mockRepo.On("FindByID", mock.Anything, "user-1").Return(&models.User{ID: "user-1"}, nil)
+
+		svc := NewUserService(mockRepo, WithMFA(mockMFA, "Core"), WithClock(fixedClock))
+		user, err := svc.VerifyMFA(context.Background(), "user-1", codeAt(t, fixedNow))
+
+		assert.NoError(t, err)
+		assert.Equal(t, "user-1", user.ID)
+		mockMFA.AssertExpectations(t)
+	})
+
+	t.Run("clock drift within one step is tolerated", func(t *testing.T) {
+		// 伺服器時間比手機快 30 秒，手機上顯示的仍是上一個區間的 code
+		serverNow := fixedNow.Add(security.TOTPPeriod * time.Second)
+		mockMFA := new(MockMFARepository)
+		mockMFA.On("FindByUserID", mock.Anything, "user-1").Return(enabled(), nil)
+		mockMFA.On("MarkStepUsed", mock.Anything, "user-1", security.TOTPStep(fixedNow)).Return(true, nil)
+		mockRepo := new(MockUserRepository)
+		mockRepo.On("FindByID", mock.Anything, "user-1").Return(&models.User{ID: "user-1"}, nil)
+
+		svc := NewUserService(mockRepo, WithMFA(mockMFA, "Core"), WithClock(func() time.Time { return serverNow }))
+		_, err := svc.VerifyMFA(context.Background(), "user-1", codeAt(t, fixedNow))
+
+		assert.NoError(t, err)
+	})
+
+	t.Run("replayed totp code", func(t *testing.T) {
+		mockMFA := new(MockMFARepository)
+		mockMFA.On("FindByUserID", mock.Anything, "user-1").Return(enabled(), nil)
+		mockMFA.On("MarkStepUsed", mock.Anything, "user-1", security.TOTPStep(fixedNow)).Return(false, nil)
+
+		svc := NewUserService(new(MockUserRepository), WithMFA(mockMFA, "Core"), WithClock(fixedClock))
+		_, err := svc.VerifyMFA(context.Background(), "user-1", codeAt(t, fixedNow))
+
+		assert.ErrorIs(t, err, ErrInvalidMFACode)
+	})
+
+	t.Run("recovery code", func(t *testing.T) {
+		mockMFA := new(MockMFARepository)
+		mockMFA.On("FindByUserID", mock.Anything, "user-1").Return(enabled(), nil)
+		mockMFA.On("UseRecoveryCode", mock.Anything, "user-1", security.HashRecoveryCode("abcde-fghij"), fixedNow).Return(true, nil)
+		mockRepo := new(MockUserRepository)
+		mockRepo.On("FindByID", mock.Anything, "user-1").Return(&models.User{ID: "user-1"}, nil)
+
+		svc := NewUserService(mockRepo, WithMFA(mockMFA, "Core"), WithClock(fixedClock))
+		_, err := svc.VerifyMFA(context.Background(), "user-1", "ABCDE-FGHIJ")
+
+		assert.NoError(t, err)
+		mockMFA.AssertExpectations(t)
+	})
+
+	t.Run("used recovery code", func(t *testing.T) {
+		mockMFA := new(MockMFARepository)
+		mockMFA.On("FindByUserID", mock.Anything, "user-1").Return(enabled(), nil)
+		mockMFA.On("UseRecoveryCode", mock.Anything, "user-1", mock.Anything, fixedNow).Return(false, nil)
+
+		svc := NewUserService(new(MockUserRepository), WithMFA(mockMFA, "Core"), WithClock(fixedClock))
+		_, err := svc.VerifyMFA(context.Background(), "user-1", "abcde-fghij")
+
+		assert.ErrorIs(t, err, ErrInvalidMFACode)
+	})
+
+	t.Run("mfa not enabled", func(t *testing.T) {
+		mockMFA := new(MockMFARepository)
+		mockMFA.On("FindByUserID", mock.Anything, "user-1").Return(&models.MFASettings{UserID: "user-1", Secret: testTOTPSecret}, nil)
+
+		svc := NewUserService(new(MockUserRepository), WithMFA(mockMFA, "Core"), WithClock(fixedClock))
+		_, err := svc.VerifyMFA(context.Background(), "user-1", codeAt(t, fixedNow))
+
+		assert.ErrorIs(t, err, ErrMFANotEnrolled)
+	})
+}
+
+// ===================================================================
+// DisableMFA 測試
+// ===================================================================
+
+func TestDisableMFA(t *testing.T) {
+	mockMFA := new(MockMFARepository)
+	mockMFA.On("FindByUserID", mock.Anything, "user-1").
+		Return(&models.MFASettings{UserID: "user-1", Secret: testTOTPSecret, Enabled: true}, nil)
+	mockMFA.On("MarkStepUsed", mock.Anything, "user-1", security.TOTPStep(fixedNow)).Return(true, nil)
+	mockMFA.On("Delete", mock.Anything, "user-1").Return(nil)
+
+	svc := NewUserService(new(MockUserRepository), WithMFA(mockMFA, "Core"), WithClock(fixedClock))
+	err := svc.DisableMFA(context.Background(), "user-1", codeAt(t, fixedNow))
+
+	assert.NoError(t, err)
+	mockMFA.AssertExpectations(t)
+}
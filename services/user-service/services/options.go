@@ -0,0 +1,99 @@
+package services
+
+import (
+	"time"
+
+	"user-service/mailer"
+	"user-service/repository"
+	"user-service/security"
+)
+
+// Option 用來注入 UserService 的選用依賴
+// 核心的 UserRepository 仍由 NewUserService 的參數傳入，其餘功能按需開啟
+type Option func(*UserService)
+
+// WithPasswordReset 開啟忘記密碼流程
+// resetURL 是前端重設密碼頁面的網址，token 會以 query string 附加在後面
+func WithPasswordReset(repo repository.PasswordResetRepositoryInterface, m mailer.Mailer, resetURL string, ttl time.Duration) Option {
+	return func(s *UserService) {
+		s.resetRepo = repo
+		s.mailer = m
+		s.resetURL = resetURL
+		s.resetTTL = ttl
+	}
+}
+
+// WithEmailChange 開啟變更 email 流程
+// confirmURL 是前端確認新 email 頁面的網址，token 會以 query string 附加在後面
+func WithEmailChange(repo repository.EmailChangeRepositoryInterface, m mailer.Mailer, confirmURL string, ttl time.Duration) Option {
+	return func(s *UserService) {
+		s.emailChangeRepo = repo
+		s.mailer = m
+		s.emailChangeURL = confirmURL
+		s.emailChangeTTL = ttl
+	}
+}
+
+// WithTokenRevocation 讓密碼變更後能撤銷該用戶已簽發的 token
+func WithTokenRevocation(repo repository.TokenRevocationRepositoryInterface) Option {
+	return func(s *UserService) {
+		s.revocations = repo
+	}
+}
+
+// WithAudit 開啟稽核紀錄，用戶資料與認證相關的操作都會寫入 audit_events
+func WithAudit(repo repository.AuditRepositoryInterface) Option {
+	return func(s *UserService) {
+		s.audit = repo
+	}
+}
+
+// WithSessions 開啟登入 session 紀錄，每次登入成功都會建立一筆 session 並可個別撤銷
+func WithSessions(repo repository.SessionRepositoryInterface) Option {
+	return func(s *UserService) {
+		s.sessions = repo
+	}
+}
+
+// WithMFA 開啟 TOTP 多因素驗證，issuer 會顯示在 authenticator App 上
+func WithMFA(repo repository.MFARepositoryInterface, issuer string) Option {
+	return func(s *UserService) {
+		s.mfaRepo = repo
+		s.mfaIssuer = issuer
+	}
+}
+
+// WithPasswordPolicy 設定註冊、修改密碼與重設密碼共用的密碼規則
+func WithPasswordPolicy(policy security.PasswordPolicy) Option {
+	return func(s *UserService) {
+		s.policy = policy
+	}
+}
+
+// WithPasswordHasher 設定密碼 hash 的方式，預設為 bcrypt.DefaultCost
+func WithPasswordHasher(hasher security.PasswordHasher) Option {
+	return func(s *UserService) {
+		s.hasher = hasher
+	}
+}
+
+// WithDeletedUserRetention 設定軟刪除的用戶保留多久後才永久清除
+func WithDeletedUserRetention(retention time.Duration) Option {
+	return func(s *UserService) {
+		s.retention = retention
+	}
+}
+
+// WithClock 替換取得現在時間的函式，測試時可注入固定時間
+func WithClock(now func() time.Time) Option {
+	return func(s *UserService) {
+		s.now = now
+	}
+}
+
+// WithBackground 替換執行背景工作（例如寄信）的方式，預設開一個 WaitBackground 會等待的 goroutine；測試時可改為同步執行
+func WithBackground(run func(func())) Option {
+	return func(s *UserService) {
+		s.background = run
+	}
+}
@@ -0,0 +1,145 @@
+package services
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"fmt"
+	"log"
+	"net/url"
+
+	"github.com/google/uuid"
+	"user-service/models"
+)
+
+// resetTokenBytes 重設密碼 token 的隨機位元組長度（256 bits）
+const resetTokenBytes = 32
+
+// ForgotPassword 產生一次性的重設密碼 token 並寄給用戶
+//
+// email 不存在時同樣回傳 nil，呼叫端無法從結果分辨帳號是否存在，
+// 避免「忘記密碼」被拿來探測哪些 email 有註冊；
+// 產生 token 與寄信在背景進行，回應時間也不會因帳號是否存在而不同
+func (s *UserService) ForgotPassword(ctx context.Context, req models.ForgotPasswordRequest) error {
+	if s.resetRepo == nil {
+		return ErrPasswordResetDisabled
+	}
+
+	user, err := s.repo.FindByEmail(ctx, req.Email)
+	if err != nil {
+		return fmt.Errorf("failed to find user: %w", err)
+	}
+	if user == nil {
+		return nil
+	}
+
+	// 寄信在回應之後才進行，改用不會隨請求結束而取消的 context
+	detached := context.WithoutCancel(ctx)
+	s.background(func() {
+		if err := s.sendResetLink(detached, user); err != nil {
+			log.Printf("Failed to send password reset link to user %s: %v", user.ID, err)
+		}
+	})
+	return nil
+}
+
+// sendResetLink 產生 token 並寄出重設密碼的連結
+func (s *UserService) sendResetLink(ctx context.Context, user *models.User) error {
+	rawToken, err := generateResetToken()
+	if err != nil {
+		return err
+	}
+
+	token := &models.PasswordResetToken{
+		ID:        uuid.New().String(),
+		UserID:    user.ID,
+		TokenHash: hashResetToken(rawToken),
+		ExpiresAt: s.now().Add(s.resetTTL),
+	}
+	if err := s.resetRepo.Create(ctx, token); err != nil {
+		return err
+	}
+
+	link := s.resetURL + "?token=" + url.QueryEscape(rawToken)
+	body := fmt.Sprintf(
+		"我們收到了重設密碼的請求，請在 %s 內點擊以下連結設定新密碼：\n%s\n\n如果這不是你本人的操作，請忽略這封信。",
+		s.resetTTL, link,
+	)
+	if err := s.mailer.Send(user.Email, "重設密碼", body); err != nil {
+		return fmt.Errorf("failed to send reset email: %w", err)
+	}
+
+	s.recordAudit(ctx, models.AuditEvent{TargetID: user.ID, Action: models.AuditPasswordResetRequested})
+	return nil
+}
+
+// ResetPassword 使用 token 設定新密碼
+//
+// 流程：
+//  1. 以 token 的 hash 查找紀錄，確認未使用且未過期
+//  2. 檢查新密碼是否符合規則（不符合時 token 仍可再用）
+//  3. 重新 hash 新密碼
+//  4. 在同一個 transaction 內消耗 token（併發時只有一個請求會成功）、寫回密碼並讓該用戶其餘的 token 失效
+//  5. 撤銷所有已簽發的 JWT
+func (s *UserService) ResetPassword(ctx context.Context, req models.ResetPasswordRequest) error {
+	if s.resetRepo == nil {
+		return ErrPasswordResetDisabled
+	}
+
+	token, err := s.resetRepo.FindByTokenHash(ctx, hashResetToken(req.Token))
+	if err != nil {
+		return err
+	}
+	now := s.now()
+	if token == nil || token.UsedAt != nil || !now.Before(token.ExpiresAt) {
+		return ErrInvalidResetToken
+	}
+
+	user, err := s.repo.FindByID(ctx, token.UserID)
+	if err != nil {
+		return err
+	}
+	if user == nil {
+		return ErrInvalidResetToken
+	}
+	if err := s.policy.Validate(req.NewPassword, user.Email, user.Username); err != nil {
+		return err
+	}
+
+	hashedPassword, err := s.hasher.Hash(req.NewPassword)
+	if err != nil {
+		return err
+	}
+	consumed, err := s.resetRepo.ResetPassword(ctx, token.ID, token.UserID, hashedPassword, now)
+	if err != nil {
+		return err
+	}
+	if !consumed {
+		return ErrInvalidResetToken
+	}
+	s.recordAudit(ctx, models.AuditEvent{TargetID: token.UserID, Action: models.AuditPasswordReset})
+
+	if err := s.revokeAllTokens(ctx, token.UserID, now); err != nil {
+		return err
+	}
+
+	return nil
+}
+
+// generateResetToken 產生 URL-safe 的隨機 token
+func generateResetToken() (string, error) {
+	b := make([]byte, resetTokenBytes)
+	if _, err := rand.Read(b); err != nil {
+		return "", fmt.Errorf("failed to generate reset token: %w", err)
+	}
+	return base64.RawURLEncoding.EncodeToString(b), nil
+}
+
+// hashResetToken 計算 token 的 SHA-256，DB 只存這個值
+// token 本身已有 256 bits 的亂度，不需要 bcrypt 這類慢速 hash
+func hashResetToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
@@ -0,0 +1,396 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+	"regexp"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/crypto/bcrypt"
+	"user-service/models"
+	"user-service/security"
+)
+
+// -------------------------------------------------------------------
+// 重設密碼流程用到的 mock
+// -------------------------------------------------------------------
+
+type MockPasswordResetRepository struct {
+	mock.Mock
+}
+
+func (m *MockPasswordResetRepository) Create(ctx context.Context, token *models.PasswordResetToken) error {
+	args := m.Called(ctx, token)
+	return args.Error(0)
+}
+
+func (m *MockPasswordResetRepository) FindByTokenHash(ctx context.Context, tokenHash string) (*models.PasswordResetToken, error) {
+	args := m.Called(ctx, tokenHash)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*models.PasswordResetToken), args.Error(1)
+}
+
+func (m *MockPasswordResetRepository) ResetPassword(ctx context.Context, tokenID, userID, hashedPassword string, at time.Time) (bool, error) {
+	args := m.Called(ctx, tokenID, userID, hashedPassword, at)
+	return args.Bool(0), args.Error(1)
+}
+
+type MockTokenRevocationRepository struct {
+	mock.Mock
+}
+
+func (m *MockTokenRevocationRepository) RevokeAllForUser(ctx context.Context, userID string, before time.Time) error {
+	args := m.Called(ctx, userID, before)
+	return args.Error(0)
+}
+
+func (m *MockTokenRevocationRepository) RevokeSession(ctx context.Context, sessionID string, expiresAt time.Time) error {
+	args := m.Called(ctx, sessionID, expiresAt)
+	return args.Error(0)
+}
+
+type MockMailer struct {
+	mock.Mock
+}
+
+func (m *MockMailer) Send(to, subject, body string) error {
+	args := m.Called(to, subject, body)
+	return args.Error(0)
+}
+
+// runNow 讓背景工作同步執行，測試結束前就能檢查結果
+func runNow(f func()) { f() }
+
+// fixedNow 測試用的固定時間
+var fixedNow = time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+
+func fixedClock() time.Time { return fixedNow }
+
+// resetUser 重設密碼測試中 token 所屬的用戶
+func resetUser() *models.User {
+	return &models.User{ID: "user-1", Email: "resetuser@example.com", Username: "resetuser"}
+}
+
+// tokenInLink 從信件內容中取出重設連結上的 token
+var tokenInLink = regexp.MustCompile(`\?token=(\S+)`)
+
+// ===================================================================
+// ForgotPassword 測試
+// ===================================================================
+
+func TestForgotPassword(t *testing.T) {
+	t.Run("success - stores hashed token and sends link", func(t *testing.T) {
+		mockRepo := new(MockUserRepository)
+		mockRepo.On("FindByEmail", mock.Anything, "user@example.com").
+			Return(&models.User{ID: "user-1", Email: "user@example.com"}, nil)
+
+		var stored *models.PasswordResetToken
+		mockReset := new(MockPasswordResetRepository)
+		mockReset.On("Create", mock.Anything, mock.AnythingOfType("*models.PasswordResetToken")).
+			Run(func(args mock.Arguments) { stored = args.Get(1).(*models.PasswordResetToken) }).
+			Return(nil)
+
+		var mailBody string
+		mockMail := new(MockMailer)
+		mockMail.On("Send", "user@example.com", mock.Anything, mock.Anything).
+			Run(func(args mock.Arguments) { mailBody = args.String(2) }).
+			Return(nil)
+
+		svc := NewUserService(mockRepo,
+			WithPasswordReset(mockReset, mockMail, "http://app/reset", time.Hour),
+			WithClock(fixedClock),
+			WithBackground(runNow),
+		)
+		err := svc.ForgotPassword(context.Background(), models.ForgotPasswordRequest{Email: "user@example.com"})
+
+		assert.NoError(t, err)
+		require.NotNil(t, stored)
+		assert.Equal(t, "user-1", stored.UserID)
+		assert.Equal(t, fixedNow.Add(time.Hour), stored.ExpiresAt)
+
+		// 信裡的是原始 token，DB 裡的是它的 hash，兩者不能相同
+		match := tokenInLink.FindStringSubmatch(mailBody)
+		require.Len(t, match, 2)
+		rawToken, err := url.QueryUnescape(match[1])
+		require.NoError(t, err)
+		assert.NotEqual(t, rawToken, stored.TokenHash)
+		assert.Equal(t, hashResetToken(rawToken), stored.TokenHash)
+		mockRepo.AssertExpectations(t)
+		mockReset.AssertExpectations(t)
+		mockMail.AssertExpectations(t)
+	})
+
+	t.Run("existing email - link is sent in the background", func(t *testing.T) {
+		// 帳號存在時產生 token 與寄信都不在請求內進行，回應時間與帳號不存在時相同
+		mockRepo := new(MockUserRepository)
+		mockRepo.On("FindByEmail", mock.Anything, "user@example.com").
+			Return(&models.User{ID: "user-1", Email: "user@example.com"}, nil)
+		mockReset := new(MockPasswordResetRepository)
+		mockReset.On("Create", mock.Anything, mock.Anything).Return(nil)
+		mockMail := new(MockMailer)
+		mockMail.On("Send", "user@example.com", mock.Anything, mock.Anything).Return(nil)
+
+		var pending []func()
+		svc := NewUserService(mockRepo,
+			WithPasswordReset(mockReset, mockMail, "http://app/reset", time.Hour),
+			WithBackground(func(f func()) { pending = append(pending, f) }),
+		)
+		err := svc.ForgotPassword(context.Background(), models.ForgotPasswordRequest{Email: "user@example.com"})
+
+		assert.NoError(t, err)
+		mockReset.AssertNotCalled(t, "Create", mock.Anything, mock.Anything)
+		mockMail.AssertNotCalled(t, "Send", mock.Anything, mock.Anything, mock.Anything)
+
+		require.Len(t, pending, 1)
+		pending[0]()
+		mockReset.AssertExpectations(t)
+		mockMail.AssertExpectations(t)
+	})
+
+	t.Run("delivery failure is not reported to the caller", func(t *testing.T) {
+		mockRepo := new(MockUserRepository)
+		mockRepo.On("FindByEmail", mock.Anything, "user@example.com").
+			Return(&models.User{ID: "user-1", Email: "user@example.com"}, nil)
+		mockReset := new(MockPasswordResetRepository)
+		mockReset.On("Create", mock.Anything, mock.Anything).Return(nil)
+		mockMail := new(MockMailer)
+		mockMail.On("Send", mock.Anything, mock.Anything, mock.Anything).Return(fmt.Errorf("smtp down"))
+
+		svc := NewUserService(mockRepo,
+			WithPasswordReset(mockReset, mockMail, "http://app/reset", time.Hour),
+			WithBackground(runNow),
+		)
+		err := svc.ForgotPassword(context.Background(), models.ForgotPasswordRequest{Email: "user@example.com"})
+
+		assert.NoError(t, err)
+		mockMail.AssertExpectations(t)
+	})
+
+	t.Run("unknown email - no error and nothing sent", func(t *testing.T) {
+		mockRepo := new(MockUserRepository)
+		mockRepo.On("FindByEmail", mock.Anything, "ghost@example.com").Return(nil, nil)
+		mockReset := new(MockPasswordResetRepository)
+		mockMail := new(MockMailer)
+
+		svc := NewUserService(mockRepo,
+			WithPasswordReset(mockReset, mockMail, "http://app/reset", time.Hour),
+			WithBackground(runNow),
+		)
+		err := svc.ForgotPassword(context.Background(), models.ForgotPasswordRequest{Email: "ghost@example.com"})
+
+		assert.NoError(t, err)
+		mockReset.AssertNotCalled(t, "Create", mock.Anything, mock.Anything)
+		mockMail.AssertNotCalled(t, "Send", mock.Anything, mock.Anything, mock.Anything)
+	})
+
+	t.Run("not configured", func(t *testing.T) {
+		svc := NewUserService(new(MockUserRepository))
+		err := svc.ForgotPassword(context.Background(), models.ForgotPasswordRequest{Email: "user@example.com"})
+
+		assert.ErrorIs(t, err, ErrPasswordResetDisabled)
+	})
+}
+
+func TestWaitBackground(t *testing.T) {
+	mockRepo := new(MockUserRepository)
+	mockRepo.On("FindByEmail", mock.Anything, "user@example.com").
+		Return(&models.User{ID: "user-1", Email: "user@example.com"}, nil)
+	mockReset := new(MockPasswordResetRepository)
+	mockReset.On("Create", mock.Anything, mock.Anything).Return(nil)
+	release := make(chan struct{})
+	mockMail := new(MockMailer)
+	mockMail.On("Send", "user@example.com", mock.Anything, mock.Anything).
+		Run(func(mock.Arguments) { <-release }).
+		Return(nil)
+
+	// 使用預設的 goroutine 執行方式；透過 ForRequest 排入的工作也要被等待
+	svc := NewUserService(mockRepo, WithPasswordReset(mockReset, mockMail, "http://app/reset", time.Hour))
+	err := svc.ForRequest(models.RequestMeta{RequestID: "req-1"}).
+		ForgotPassword(context.Background(), models.ForgotPasswordRequest{Email: "user@example.com"})
+	require.NoError(t, err)
+
+	t.Run("times out while mail is in flight", func(t *testing.T) {
+		ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+		defer cancel()
+
+		assert.ErrorIs(t, svc.WaitBackground(ctx), context.DeadlineExceeded)
+	})
+
+	t.Run("returns once mail is sent", func(t *testing.T) {
+		close(release)
+		ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+		defer cancel()
+
+		assert.NoError(t, svc.WaitBackground(ctx))
+		mockMail.AssertExpectations(t)
+	})
+}
+
+// ===================================================================
+// ResetPassword 測試
+// ===================================================================
+
+func TestResetPassword(t *testing.T) {
+	validToken := func() *models.PasswordResetToken {
+		return &models.PasswordResetToken{
+			ID:        "token-1",
+			UserID:    "user-1",
+			TokenHash: hashResetToken("raw-token"),
+			ExpiresAt: fixedNow.Add(30 * time.Minute),
+		}
+	}
+
+	t.Run("success - rehashes password and revokes sessions", func(t *testing.T) {
+		mockReset := new(MockPasswordResetRepository)
+		var newHash string
+		mockReset.On("FindByTokenHash", mock.Anything, hashResetToken("raw-token")).Return(validToken(), nil)
+		mockReset.On("ResetPassword", mock.Anything, "token-1", "user-1", mock.AnythingOfType("string"), fixedNow).
+			Run(func(args mock.Arguments) { newHash = args.String(3) }).
+			Return(true, nil)
+
+		mockRepo := new(MockUserRepository)
+		mockRepo.On("FindByID", mock.Anything, "user-1").Return(resetUser(), nil)
+
+		mockRevoke := new(MockTokenRevocationRepository)
+		mockRevoke.On("RevokeAllForUser", mock.Anything, "user-1", fixedNow).Return(nil)
+
+		svc := NewUserService(mockRepo,
+			WithPasswordReset(mockReset, new(MockMailer), "http://app/reset", time.Hour),
+			WithTokenRevocation(mockRevoke),
+			WithClock(fixedClock),
+		)
+		err := svc.ResetPassword(context.Background(), models.ResetPasswordRequest{Token: "raw-token", NewPassword: "newpassword"})
+
+		assert.NoError(t, err)
+		assert.NoError(t, bcrypt.CompareHashAndPassword([]byte(newHash), []byte("newpassword")))
+		mockReset.AssertExpectations(t)
+		mockRepo.AssertExpectations(t)
+		mockRevoke.AssertExpectations(t)
+	})
+
+	t.Run("unknown token", func(t *testing.T) {
+		mockReset := new(MockPasswordResetRepository)
+		mockReset.On("FindByTokenHash", mock.Anything, hashResetToken("bogus")).Return(nil, nil)
+
+		svc := NewUserService(new(MockUserRepository),
+			WithPasswordReset(mockReset, new(MockMailer), "http://app/reset", time.Hour),
+			WithClock(fixedClock),
+		)
+		err := svc.ResetPassword(context.Background(), models.ResetPasswordRequest{Token: "bogus", NewPassword: "newpassword"})
+
+		assert.ErrorIs(t, err, ErrInvalidResetToken)
+	})
+
+	t.Run("expired token", func(t *testing.T) {
+		expired := validToken()
+		expired.ExpiresAt = fixedNow.Add(-time.Second)
+		mockReset := new(MockPasswordResetRepository)
+		mockReset.On("FindByTokenHash", mock.Anything, hashResetToken("raw-token")).Return(expired, nil)
+
+		svc := NewUserService(new(MockUserRepository),
+			WithPasswordReset(mockReset, new(MockMailer), "http://app/reset", time.Hour),
+			WithClock(fixedClock),
+		)
+		err := svc.ResetPassword(context.Background(), models.ResetPasswordRequest{Token: "raw-token", NewPassword: "newpassword"})
+
+		assert.ErrorIs(t, err, ErrInvalidResetToken)
+		mockReset.AssertNotCalled(t, "ResetPassword", mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything)
+	})
+
+	t.Run("already used token", func(t *testing.T) {
+		used := validToken()
+		usedAt := fixedNow.Add(-time.Minute)
+		used.UsedAt = &usedAt
+		mockReset := new(MockPasswordResetRepository)
+		mockReset.On("FindByTokenHash", mock.Anything, hashResetToken("raw-token")).Return(used, nil)
+
+		svc := NewUserService(new(MockUserRepository),
+			WithPasswordReset(mockReset, new(MockMailer), "http://app/reset", time.Hour),
+			WithClock(fixedClock),
+		)
+		err := svc.ResetPassword(context.Background(), models.ResetPasswordRequest{Token: "raw-token", NewPassword: "newpassword"})
+
+		assert.ErrorIs(t, err, ErrInvalidResetToken)
+	})
+
+	t.Run("consumed concurrently", func(t *testing.T) {
+		// 查詢時還沒被用，但標記時另一個請求已經搶先使用
+		mockReset := new(MockPasswordResetRepository)
+		mockReset.On("FindByTokenHash", mock.Anything, hashResetToken("raw-token")).Return(validToken(), nil)
+		mockReset.On("ResetPassword", mock.Anything, "token-1", "user-1", mock.Anything, fixedNow).Return(false, nil)
+		mockRepo := new(MockUserRepository)
+		mockRepo.On("FindByID", mock.Anything, "user-1").Return(resetUser(), nil)
+		mockRevoke := new(MockTokenRevocationRepository)
+
+		svc := NewUserService(mockRepo,
+			WithPasswordReset(mockReset, new(MockMailer), "http://app/reset", time.Hour),
+			WithTokenRevocation(mockRevoke),
+			WithClock(fixedClock),
+		)
+		err := svc.ResetPassword(context.Background(), models.ResetPasswordRequest{Token: "raw-token", NewPassword: "newpassword"})
+
+		assert.ErrorIs(t, err, ErrInvalidResetToken)
+		mockRevoke.AssertNotCalled(t, "RevokeAllForUser", mock.Anything, mock.Anything, mock.Anything)
+	})
+
+	t.Run("password update failure is returned", func(t *testing.T) {
+		// 寫入密碼與消耗 token 在同一個 transaction，失敗時 token 仍可再用（見 repository 的整合測試）
+		mockReset := new(MockPasswordResetRepository)
+		mockReset.On("FindByTokenHash", mock.Anything, hashResetToken("raw-token")).Return(validToken(), nil)
+		mockReset.On("ResetPassword", mock.Anything, "token-1", "user-1", mock.Anything, fixedNow).Return(false, fmt.Errorf("db error"))
+		mockRepo := new(MockUserRepository)
+		mockRepo.On("FindByID", mock.Anything, "user-1").Return(resetUser(), nil)
+		mockRevoke := new(MockTokenRevocationRepository)
+
+		svc := NewUserService(mockRepo,
+			WithPasswordReset(mockReset, new(MockMailer), "http://app/reset", time.Hour),
+			WithTokenRevocation(mockRevoke),
+			WithClock(fixedClock),
+		)
+		err := svc.ResetPassword(context.Background(), models.ResetPasswordRequest{Token: "raw-token", NewPassword: "newpassword"})
+
+		assert.Error(t, err)
+		assert.NotErrorIs(t, err, ErrInvalidResetToken)
+		mockRevoke.AssertNotCalled(t, "RevokeAllForUser", mock.Anything, mock.Anything, mock.Anything)
+	})
+
+	t.Run("weak password keeps token usable", func(t *testing.T) {
+		mockReset := new(MockPasswordResetRepository)
+		mockReset.On("FindByTokenHash", mock.Anything, hashResetToken("raw-token")).Return(validToken(), nil)
+		mockRepo := new(MockUserRepository)
+		mockRepo.On("FindByID", mock.Anything, "user-1").Return(resetUser(), nil)
+
+		svc := NewUserService(mockRepo,
+			WithPasswordReset(mockReset, new(MockMailer), "http://app/reset", time.Hour),
+			WithPasswordPolicy(security.PasswordPolicy{MinLength: 8, DisallowPersonalInfo: true}),
+			WithClock(fixedClock),
+		)
+		err := svc.ResetPassword(context.Background(), models.ResetPasswordRequest{Token: "raw-token", NewPassword: "resetuser1"})
+
+		var policyErr *security.PolicyError
+		assert.ErrorAs(t, err, &policyErr)
+		// 規則沒過就不能消耗 token，用戶還可以用同一個連結再試一次
+		mockReset.AssertNotCalled(t, "ResetPassword", mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything)
+	})
+
+	t.Run("db error", func(t *testing.T) {
+		mockReset := new(MockPasswordResetRepository)
+		mockReset.On("FindByTokenHash", mock.Anything, hashResetToken("raw-token")).Return(nil, fmt.Errorf("db error"))
+
+		svc := NewUserService(new(MockUserRepository),
+			WithPasswordReset(mockReset, new(MockMailer), "http://app/reset", time.Hour),
+			WithClock(fixedClock),
+		)
+		err := svc.ResetPassword(context.Background(), models.ResetPasswordRequest{Token: "raw-token", NewPassword: "newpassword"})
+
+		assert.Error(t, err)
+		assert.NotErrorIs(t, err, ErrInvalidResetToken)
+	})
+}
@@ -0,0 +1,127 @@
+package services
+
+import (
+	"context"
+	"net/url"
+	"strings"
+	"time"
+	_ "time/tzdata" // 內嵌時區資料，驗證結果不受部署環境影響
+	"unicode/utf8"
+
+	"golang.org/x/text/language"
+	"user-service/models"
+	"user-service/repository"
+)
+
+// 各欄位的長度上限，需與資料表欄位長度一致
+const (
+	maxUsernameLength    = 100
+	maxDisplayNameLength = 100
+	maxBioLength         = 500
+	maxAvatarURLLength   = 2048
+	maxLocaleLength      = 35
+	maxTimezoneLength    = 64
+)
+
+// FieldError 單一欄位的驗證錯誤
+type FieldError struct {
+	Field   string `json:"field"`
+	Message string `json:"message"`
+}
+
+// ValidationError 用戶資料驗證失敗時回傳，內含所有不合法的欄位
+type ValidationError struct {
+	Fields []FieldError
+}
+
+func (e *ValidationError) Error() string {
+	names := make([]string, len(e.Fields))
+	for i, f := range e.Fields {
+		names[i] = f.Field
+	}
+	return "invalid user fields: " + strings.Join(names, ", ")
+}
+
+// validateChanges 逐欄檢查要更新的值，locale 會順便正規化（例如 zh-tw → zh-TW）
+func validateChanges(changes *models.UserChanges) error {
+	var fields []FieldError
+	add := func(field, message string) {
+		fields = append(fields, FieldError{Field: field, Message: message})
+	}
+	tooLong := func(value *string, max int) bool {
+		return utf8.RuneCountInString(*value) > max
+	}
+
+	if v := changes.Username; v != nil {
+		*v = strings.TrimSpace(*v)
+		switch {
+		case *v == "":
+			add("username", "username cannot be empty")
+		case tooLong(v, maxUsernameLength):
+			add("username", "username is too long")
+		}
+	}
+	if v := changes.DisplayName; v != nil {
+		*v = strings.TrimSpace(*v)
+		if tooLong(v, maxDisplayNameLength) {
+			add("display_name", "display name is too long")
+		}
+	}
+	if v := changes.Bio; v != nil && tooLong(v, maxBioLength) {
+		add("bio", "bio is too long")
+	}
+	if v := changes.AvatarURL; v != nil && *v != "" {
+		u, err := url.Parse(*v)
+		switch {
+		case tooLong(v, maxAvatarURLLength):
+			add("avatar_url", "avatar URL is too long")
+		case err != nil || (u.Scheme != "https" && u.Scheme != "http") || u.Host == "":
+			add("avatar_url", "avatar URL must be an absolute http(s) URL")
+		}
+	}
+	if v := changes.Locale; v != nil && *v != "" {
+		tag, err := language.Parse(*v)
+		if err != nil || tooLong(v, maxLocaleLength) {
+			add("locale", "locale must be a BCP 47 language tag, e.g. zh-TW")
+		} else {
+			*v = tag.String()
+		}
+	}
+	if v := changes.Timezone; v != nil && *v != "" {
+		// LoadLocation 也接受 "Local"，但那是伺服器的時區，對用戶沒有意義
+		if _, err := time.LoadLocation(*v); err != nil || *v == "Local" || tooLong(v, maxTimezoneLength) {
+			add("timezone", "timezone must be an IANA time zone name, e.g. Asia/Taipei")
+		}
+	}
+
+	if len(fields) > 0 {
+		return &ValidationError{Fields: fields}
+	}
+	return nil
+}
+
+// PatchUser 部分更新用戶資料，只會寫入 changes 中有指定的欄位，回傳更新後的用戶
+// expectedVersion 為 repository.AnyVersion 時不檢查版本，否則版本不符回傳 ErrVersionConflict
+func (s *UserService) PatchUser(ctx context.Context, id string, changes models.UserChanges, expectedVersion int) (*models.User, error) {
+	if err := validateChanges(&changes); err != nil {
+		return nil, err
+	}
+
+	user, err := s.GetUserByID(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if expectedVersion != repository.AnyVersion && user.Version != expectedVersion {
+		return nil, ErrVersionConflict
+	}
+	if changes.IsEmpty() {
+		return user, nil
+	}
+
+	if err := s.repo.Patch(ctx, id, changes, expectedVersion); err != nil {
+		return nil, versionError(err)
+	}
+	s.recordAudit(ctx, models.AuditEvent{TargetID: id, Action: models.AuditUserUpdated, Changes: diffUser(user, changes)})
+
+	return s.GetUserByID(ctx, id)
+}
@@ -0,0 +1,110 @@
+package services
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+	"user-service/models"
+)
+
+func strPtr(s string) *string { return &s }
+
+// ===================================================================
+// PatchUser 測試
+// ===================================================================
+
+func TestPatchUser(t *testing.T) {
+	current := func() *models.User {
+		return &models.User{ID: "abc-123", Username: "alice", Version: 2}
+	}
+
+	t.Run("writes only the given fields", func(t *testing.T) {
+		mockRepo := new(MockUserRepository)
+		mockRepo.On("FindByID", mock.Anything, "abc-123").Return(current(), nil).Once()
+		mockRepo.On("Patch", mock.Anything, "abc-123", models.UserChanges{
+			DisplayName: strPtr("Alice"),
+			Locale:      strPtr("zh-TW"),
+		}, 2).Return(nil)
+		mockRepo.On("FindByID", mock.Anything, "abc-123").
+			Return(&models.User{ID: "abc-123", Username: "alice", DisplayName: "Alice", Locale: "zh-TW", Version: 3}, nil).Once()
+
+		svc := NewUserService(mockRepo)
+		// locale 會被正規化成標準大小寫
+		user, err := svc.PatchUser(context.Background(), "abc-123", models.UserChanges{
+			DisplayName: strPtr("  Alice "),
+			Locale:      strPtr("zh-tw"),
+		}, 2)
+
+		require.NoError(t, err)
+		assert.Equal(t, 3, user.Version)
+		mockRepo.AssertExpectations(t)
+	})
+
+	t.Run("null clears optional fields", func(t *testing.T) {
+		mockRepo := new(MockUserRepository)
+		mockRepo.On("FindByID", mock.Anything, "abc-123").Return(current(), nil)
+		mockRepo.On("Patch", mock.Anything, "abc-123", models.UserChanges{Bio: strPtr("")}, 0).Return(nil)
+
+		svc := NewUserService(mockRepo)
+		_, err := svc.PatchUser(context.Background(), "abc-123", models.UserChanges{Bio: strPtr("")}, 0)
+
+		assert.NoError(t, err)
+		mockRepo.AssertExpectations(t)
+	})
+
+	t.Run("per-field validation", func(t *testing.T) {
+		mockRepo := new(MockUserRepository)
+
+		svc := NewUserService(mockRepo)
+		_, err := svc.PatchUser(context.Background(), "abc-123", models.UserChanges{
+			Username:  strPtr("   "),
+			AvatarURL: strPtr("javascript:alert(1)"),
+			Locale:    strPtr("not a locale"),
+			Timezone:  strPtr("Mars/Olympus"),
+		}, 0)
+
+		var validationErr *ValidationError
+		require.ErrorAs(t, err, &validationErr)
+		fields := make([]string, len(validationErr.Fields))
+		for i, f := range validationErr.Fields {
+			fields[i] = f.Field
+		}
+		assert.ElementsMatch(t, []string{"username", "avatar_url", "locale", "timezone"}, fields)
+		mockRepo.AssertNotCalled(t, "Patch", mock.Anything, mock.Anything, mock.Anything, mock.Anything)
+	})
+
+	t.Run("stale version", func(t *testing.T) {
+		mockRepo := new(MockUserRepository)
+		mockRepo.On("FindByID", mock.Anything, "abc-123").Return(current(), nil)
+
+		svc := NewUserService(mockRepo)
+		_, err := svc.PatchUser(context.Background(), "abc-123", models.UserChanges{Bio: strPtr("hi")}, 1)
+
+		assert.ErrorIs(t, err, ErrVersionConflict)
+		mockRepo.AssertNotCalled(t, "Patch", mock.Anything, mock.Anything, mock.Anything, mock.Anything)
+	})
+
+	t.Run("user not found", func(t *testing.T) {
+		mockRepo := new(MockUserRepository)
+		mockRepo.On("FindByID", mock.Anything, "ghost-id").Return(nil, nil)
+
+		svc := NewUserService(mockRepo)
+		_, err := svc.PatchUser(context.Background(), "ghost-id", models.UserChanges{Bio: strPtr("hi")}, 0)
+
+		assert.ErrorIs(t, err, ErrUserNotFound)
+	})
+}
+
+func TestUpdateUser_RejectsEmptyUsername(t *testing.T) {
+	mockRepo := new(MockUserRepository)
+
+	svc := NewUserService(mockRepo)
+	err := svc.UpdateUser(context.Background(), "abc-123", models.UpdateUserRequest{Username: ""}, 0)
+
+	var validationErr *ValidationError
+	assert.ErrorAs(t, err, &validationErr)
+	mockRepo.AssertNotCalled(t, "Update", mock.Anything, mock.Anything, mock.Anything, mock.Anything)
+}
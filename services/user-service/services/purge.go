@@ -0,0 +1,49 @@
+package services
+
+import (
+	"context"
+	"log"
+	"strconv"
+	"time"
+
+	"user-service/models"
+)
+
+// PurgeDeletedUsers 永久刪除軟刪除超過保留期間的用戶，回傳刪除筆數
+func (s *UserService) PurgeDeletedUsers(ctx context.Context) (int64, error) {
+	purged, err := s.repo.PurgeDeleted(ctx, s.retention)
+	if err != nil {
+		return 0, err
+	}
+
+	if purged > 0 {
+		s.recordAudit(ctx, models.AuditEvent{
+			ActorID:  models.AuditActorSystem,
+			Action:   models.AuditUserPurged,
+			Metadata: map[string]string{"count": strconv.FormatInt(purged, 10), "retention": s.retention.String()},
+		})
+	}
+	return purged, nil
+}
+
+// RunPurgeJob 每隔 interval 執行一次 PurgeDeletedUsers，直到 ctx 結束
+// 失敗只記 log，下一輪會再試一次
+func (s *UserService) RunPurgeJob(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		purged, err := s.PurgeDeletedUsers(ctx)
+		if err != nil {
+			log.Printf("failed to purge deleted users: %v", err)
+		} else if purged > 0 {
+			log.Printf("purged %d deleted users", purged)
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
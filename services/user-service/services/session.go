@@ -0,0 +1,99 @@
+package services
+
+import (
+	"context"
+	"time"
+
+	"github.com/google/uuid"
+	"user-service/models"
+)
+
+// MaxSessionHistory 查詢登入紀錄時最多回傳的筆數
+const MaxSessionHistory = 100
+
+// StartSession 為登入成功的用戶建立 session，IP 與 User-Agent 取自目前的請求
+// 回傳的 session ID 需寫進 access token 的 sid claim，之後才能個別撤銷
+func (s *UserService) StartSession(ctx context.Context, userID string, expiresAt time.Time) (*models.Session, error) {
+	if s.sessions == nil {
+		return nil, ErrSessionsDisabled
+	}
+
+	session := &models.Session{
+		ID:        uuid.New().String(),
+		UserID:    userID,
+		IP:        s.meta.IP,
+		UserAgent: s.meta.UserAgent,
+		CreatedAt: s.now(),
+		ExpiresAt: expiresAt,
+	}
+	if err := s.sessions.Create(ctx, session); err != nil {
+		return nil, err
+	}
+	session.Active = true
+	return session, nil
+}
+
+// ListSessions 取得用戶的登入紀錄，由新到舊排列，包含已過期與已撤銷的 session
+func (s *UserService) ListSessions(ctx context.Context, userID string) ([]models.Session, error) {
+	if s.sessions == nil {
+		return nil, ErrSessionsDisabled
+	}
+
+	sessions, err := s.sessions.FindByUserID(ctx, userID, MaxSessionHistory)
+	if err != nil {
+		return nil, err
+	}
+	now := s.now()
+	for i := range sessions {
+		sessions[i].Active = sessions[i].RevokedAt == nil && now.Before(sessions[i].ExpiresAt)
+	}
+	return sessions, nil
+}
+
+// RevokeSession 撤銷用戶自己的一個 session，該 session 的 token 會被 API Gateway 拒絕
+func (s *UserService) RevokeSession(ctx context.Context, userID, sessionID string) error {
+	if s.sessions == nil {
+		return ErrSessionsDisabled
+	}
+	if _, err := uuid.Parse(sessionID); err != nil {
+		return ErrSessionNotFound
+	}
+
+	session, err := s.sessions.Revoke(ctx, userID, sessionID, s.now())
+	if err != nil {
+		return err
+	}
+	if session == nil {
+		return ErrSessionNotFound
+	}
+
+	if s.revocations != nil {
+		if err := s.revocations.RevokeSession(ctx, session.ID, session.ExpiresAt); err != nil {
+			return err
+		}
+	}
+
+	s.recordAudit(ctx, models.AuditEvent{
+		TargetID: userID,
+		Action:   models.AuditSessionRevoked,
+		Metadata: map[string]string{"session_id": session.ID},
+	})
+	return nil
+}
+
+// revokeAllTokens 撤銷用戶在 at 之前簽發的所有 token，並把所有 session 標記為已撤銷
+// 呼叫時密碼或 email 通常已經變更，請求逾時或被取消也要撤銷完，因此不受請求 ctx 的取消影響
+func (s *UserService) revokeAllTokens(ctx context.Context, userID string, at time.Time) error {
+	ctx = context.WithoutCancel(ctx)
+	if s.revocations != nil {
+		if err := s.revocations.RevokeAllForUser(ctx, userID, at); err != nil {
+			return err
+		}
+	}
+	if s.sessions != nil {
+		if err := s.sessions.RevokeAllForUser(ctx, userID, at); err != nil {
+			return err
+		}
+	}
+	return nil
+}
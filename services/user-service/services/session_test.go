@@ -0,0 +1,166 @@
+package services
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+	"user-service/models"
+)
+
+// -------------------------------------------------------------------
+// MockSessionRepository：手動實作 SessionRepositoryInterface 供測試用
+// -------------------------------------------------------------------
+
+type MockSessionRepository struct {
+	mock.Mock
+}
+
+func (m *MockSessionRepository) Create(ctx context.Context, session *models.Session) error {
+	args := m.Called(ctx, session)
+	return args.Error(0)
+}
+
+func (m *MockSessionRepository) FindByUserID(ctx context.Context, userID string, limit int) ([]models.Session, error) {
+	args := m.Called(ctx, userID, limit)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).([]models.Session), args.Error(1)
+}
+
+func (m *MockSessionRepository) Revoke(ctx context.Context, userID, sessionID string, at time.Time) (*models.Session, error) {
+	args := m.Called(ctx, userID, sessionID, at)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*models.Session), args.Error(1)
+}
+
+func (m *MockSessionRepository) RevokeAllForUser(ctx context.Context, userID string, at time.Time) error {
+	args := m.Called(ctx, userID, at)
+	return args.Error(0)
+}
+
+// testSessionID 合法的 UUID，RevokeSession 會先檢查格式
+const testSessionID = "11111111-1111-1111-1111-111111111111"
+
+// ===================================================================
+// StartSession 測試
+// ===================================================================
+
+func TestStartSession(t *testing.T) {
+	t.Run("records request ip and user agent", func(t *testing.T) {
+		var created *models.Session
+		mockSessions := new(MockSessionRepository)
+		mockSessions.On("Create", mock.Anything, mock.Anything).
+			Run(func(args mock.Arguments) { created = args.Get(1).(*models.Session) }).
+			Return(nil)
+
+		svc := NewUserService(new(MockUserRepository), WithSessions(mockSessions), WithClock(fixedClock)).
+			ForRequest(models.RequestMeta{IP: "203.0.113.7", UserAgent: "Firefox"})
+		session, err := svc.StartSession(context.Background(), "user-1", fixedNow.Add(time.Hour))
+
+		assert.NoError(t, err)
+		require.NotNil(t, created)
+		assert.NotEmpty(t, session.ID)
+		assert.Equal(t, "user-1", created.UserID)
+		assert.Equal(t, "203.0.113.7", created.IP)
+		assert.Equal(t, "Firefox", created.UserAgent)
+		assert.Equal(t, fixedNow, created.CreatedAt)
+		assert.Equal(t, fixedNow.Add(time.Hour), created.ExpiresAt)
+	})
+
+	t.Run("not configured", func(t *testing.T) {
+		svc := NewUserService(new(MockUserRepository))
+		_, err := svc.StartSession(context.Background(), "user-1", fixedNow.Add(time.Hour))
+
+		assert.ErrorIs(t, err, ErrSessionsDisabled)
+	})
+}
+
+// ===================================================================
+// ListSessions 測試
+// ===================================================================
+
+func TestListSessions(t *testing.T) {
+	revokedAt := fixedNow.Add(-time.Minute)
+	mockSessions := new(MockSessionRepository)
+	mockSessions.On("FindByUserID", mock.Anything, "user-1", MaxSessionHistory).Return([]models.Session{
+		{ID: "active", ExpiresAt: fixedNow.Add(time.Hour)},
+		{ID: "revoked", ExpiresAt: fixedNow.Add(time.Hour), RevokedAt: &revokedAt},
+		{ID: "expired", ExpiresAt: fixedNow.Add(-time.Hour)},
+	}, nil)
+
+	svc := NewUserService(new(MockUserRepository), WithSessions(mockSessions), WithClock(fixedClock))
+	sessions, err := svc.ListSessions(context.Background(), "user-1")
+
+	assert.NoError(t, err)
+	require.Len(t, sessions, 3)
+	assert.True(t, sessions[0].Active)
+	assert.False(t, sessions[1].Active)
+	assert.False(t, sessions[2].Active)
+}
+
+// ===================================================================
+// RevokeSession 測試
+// ===================================================================
+
+func TestRevokeSession(t *testing.T) {
+	t.Run("success - revokes token until session expiry", func(t *testing.T) {
+		expiresAt := fixedNow.Add(time.Hour)
+		mockSessions := new(MockSessionRepository)
+		mockSessions.On("Revoke", mock.Anything, "user-1", testSessionID, fixedNow).
+			Return(&models.Session{ID: testSessionID, UserID: "user-1", ExpiresAt: expiresAt}, nil)
+		mockRevocations := new(MockTokenRevocationRepository)
+		mockRevocations.On("RevokeSession", mock.Anything, testSessionID, expiresAt).Return(nil)
+
+		svc := NewUserService(new(MockUserRepository),
+			WithSessions(mockSessions), WithTokenRevocation(mockRevocations), WithClock(fixedClock))
+		err := svc.RevokeSession(context.Background(), "user-1", testSessionID)
+
+		assert.NoError(t, err)
+		mockRevocations.AssertExpectations(t)
+	})
+
+	t.Run("not found or owned by another user", func(t *testing.T) {
+		mockSessions := new(MockSessionRepository)
+		mockSessions.On("Revoke", mock.Anything, "user-1", testSessionID, fixedNow).Return(nil, nil)
+		mockRevocations := new(MockTokenRevocationRepository)
+
+		svc := NewUserService(new(MockUserRepository),
+			WithSessions(mockSessions), WithTokenRevocation(mockRevocations), WithClock(fixedClock))
+		err := svc.RevokeSession(context.Background(), "user-1", testSessionID)
+
+		assert.ErrorIs(t, err, ErrSessionNotFound)
+		mockRevocations.AssertNotCalled(t, "RevokeSession", mock.Anything, mock.Anything, mock.Anything)
+	})
+
+	t.Run("malformed id", func(t *testing.T) {
+		mockSessions := new(MockSessionRepository)
+
+		svc := NewUserService(new(MockUserRepository), WithSessions(mockSessions))
+		err := svc.RevokeSession(context.Background(), "user-1", "not-a-uuid")
+
+		assert.ErrorIs(t, err, ErrSessionNotFound)
+		mockSessions.AssertNotCalled(t, "Revoke", mock.Anything, mock.Anything, mock.Anything, mock.Anything)
+	})
+}
+
+func TestChangePassword_RevokesAllSessions(t *testing.T) {
+	hashed, _ := NewUserService(nil).hasher.Hash("OldPassword1")
+	mockRepo := new(MockUserRepository)
+	mockRepo.On("FindByID", mock.Anything, "user-1").Return(&models.User{ID: "user-1", Password: hashed}, nil)
+	mockRepo.On("UpdatePassword", mock.Anything, "user-1", mock.AnythingOfType("string")).Return(nil)
+	mockSessions := new(MockSessionRepository)
+	mockSessions.On("RevokeAllForUser", mock.Anything, "user-1", fixedNow).Return(nil)
+
+	svc := NewUserService(mockRepo, WithSessions(mockSessions), WithClock(fixedClock))
+	err := svc.ChangePassword(context.Background(), "user-1", models.ChangePasswordRequest{CurrentPassword: "OldPassword1", NewPassword: "NewPassword1"})
+
+	assert.NoError(t, err)
+	mockSessions.AssertExpectations(t)
+}
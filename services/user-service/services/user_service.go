@@ -1,49 +1,134 @@
 package services
 
 import (
+	"context"
+	"errors"
 	"fmt"
+	"log"
+	"sync"
+	"time"
 
 	"github.com/google/uuid"
 	"golang.org/x/crypto/bcrypt"
+	"user-service/mailer"
 	"user-service/models"
 	"user-service/repository"
+	"user-service/security"
 )
 
 // UserServiceInterface 定義 service 層的契約，讓 handler 層依賴 interface 而非具體實作
 type UserServiceInterface interface {
-	Register(req models.RegisterRequest) (*models.User, error)
-	Login(req models.LoginRequest) (*models.User, error)
-	GetUsers() ([]models.User, error)
-	GetUserByID(id string) (*models.User, error)
-	UpdateUser(id string, req models.UpdateUserRequest) error
-	DeleteUser(id string) error
+	Register(ctx context.Context, req models.RegisterRequest) (*models.User, error)
+	Login(ctx context.Context, req models.LoginRequest) (*models.User, error)
+	GetUsers(ctx context.Context) ([]models.User, error)
+	GetUserByID(ctx context.Context, id string) (*models.User, error)
+	UpdateUser(ctx context.Context, id string, req models.UpdateUserRequest, expectedVersion int) error
+	PatchUser(ctx context.Context, id string, changes models.UserChanges, expectedVersion int) (*models.User, error)
+	DeleteUser(ctx context.Context, id string, expectedVersion int) error
+	RestoreUser(ctx context.Context, id string) error
+	ForgotPassword(ctx context.Context, req models.ForgotPasswordRequest) error
+	ResetPassword(ctx context.Context, req models.ResetPasswordRequest) error
+	ChangePassword(ctx context.Context, id string, req models.ChangePasswordRequest) error
+	RequestEmailChange(ctx context.Context, userID string, req models.EmailChangeRequest) error
+	ConfirmEmailChange(ctx context.Context, req models.ConfirmEmailChangeRequest) error
+	EnrollTOTP(ctx context.Context, userID string) (*models.TOTPEnrollment, error)
+	ConfirmTOTP(ctx context.Context, userID, code string) ([]string, error)
+	VerifyMFA(ctx context.Context, userID, code string) (*models.User, error)
+	DisableMFA(ctx context.Context, userID, code string) error
+	StartSession(ctx context.Context, userID string, expiresAt time.Time) (*models.Session, error)
+	ListSessions(ctx context.Context, userID string) ([]models.Session, error)
+	RevokeSession(ctx context.Context, userID, sessionID string) error
+	ListAuditEvents(ctx context.Context, filter models.AuditFilter) (*models.AuditEventPage, error)
+	ForRequest(meta models.RequestMeta) UserServiceInterface
 }
 
 // UserService 用戶業務邏輯層
 type UserService struct {
-	repo repository.UserRepositoryInterface
+	repo            repository.UserRepositoryInterface
+	resetRepo       repository.PasswordResetRepositoryInterface
+	revocations     repository.TokenRevocationRepositoryInterface
+	mfaRepo         repository.MFARepositoryInterface
+	mailer          mailer.Mailer
+	resetURL        string
+	resetTTL        time.Duration
+	emailChangeRepo repository.EmailChangeRepositoryInterface
+	emailChangeURL  string
+	emailChangeTTL  time.Duration
+	mfaIssuer       string
+	policy          security.PasswordPolicy
+	hasher          security.PasswordHasher
+	retention       time.Duration
+	audit           repository.AuditRepositoryInterface
+	sessions        repository.SessionRepositoryInterface
+	meta            models.RequestMeta
+	now             func() time.Time
+	background      func(func())
+	jobs            *sync.WaitGroup
 }
 
+// DefaultDeletedUserRetention 軟刪除的用戶保留多久後才永久清除
+const DefaultDeletedUserRetention = 30 * 24 * time.Hour
+
 // NewUserService 創建用戶 Service
-func NewUserService(repo repository.UserRepositoryInterface) *UserService {
-	return &UserService{repo: repo}
+func NewUserService(repo repository.UserRepositoryInterface, opts ...Option) *UserService {
+	jobs := &sync.WaitGroup{}
+	s := &UserService{
+		repo:      repo,
+		policy:    security.LegacyPasswordPolicy(),
+		hasher:    security.NewUpgradingHasher(security.NewBcryptHasher(bcrypt.DefaultCost)),
+		retention: DefaultDeletedUserRetention,
+		now:       time.Now,
+		jobs:      jobs,
+		background: func(f func()) {
+			jobs.Add(1)
+			go func() {
+				defer jobs.Done()
+				f()
+			}()
+		},
+	}
+	for _, opt := range opts {
+		opt(s)
+	}
+	return s
+}
+
+// WaitBackground 等待已排入的背景工作（例如寄信）全部結束，ctx 逾時則先返回
+// 關閉時要在關閉資料庫與 Redis 之前呼叫，避免背景工作用到已關閉的連線
+func (s *UserService) WaitBackground(ctx context.Context) error {
+	done := make(chan struct{})
+	go func() {
+		s.jobs.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
 }
 
 // Register 註冊新用戶
-func (s *UserService) Register(req models.RegisterRequest) (*models.User, error) {
+func (s *UserService) Register(ctx context.Context, req models.RegisterRequest) (*models.User, error) {
+	// 檢查密碼強度
+	if err := s.policy.Validate(req.Password, req.Email, req.Username); err != nil {
+		return nil, err
+	}
+
 	// 檢查 email 是否已存在
-	existingUser, err := s.repo.FindByEmail(req.Email)
+	existingUser, err := s.repo.FindByEmail(ctx, req.Email)
 	if err != nil {
 		return nil, fmt.Errorf("failed to check existing user: %w", err)
 	}
 	if existingUser != nil {
-		return nil, fmt.Errorf("email already exists")
+		return nil, ErrEmailTaken
 	}
 
 	// 加密密碼
-	hashedPassword, err := bcrypt.GenerateFromPassword([]byte(req.Password), bcrypt.DefaultCost)
+	hashedPassword, err := s.hasher.Hash(req.Password)
 	if err != nil {
-		return nil, fmt.Errorf("failed to hash password: %w", err)
+		return nil, err
 	}
 
 	// 創建用戶
@@ -51,59 +136,182 @@ func (s *UserService) Register(req models.RegisterRequest) (*models.User, error)
 		ID:       uuid.New().String(),
 		Email:    req.Email,
 		Username: req.Username,
-		Password: string(hashedPassword),
+		Password: hashedPassword,
+		Role:     models.RoleUser,
 	}
 
-	if err := s.repo.Create(user); err != nil {
+	if err := s.repo.Create(ctx, user); err != nil {
+		// 檢查與寫入之間被其他請求搶先註冊，同樣視為 email 已被使用
+		if errors.Is(err, repository.ErrDuplicateEmail) {
+			return nil, ErrEmailTaken
+		}
 		return nil, err
 	}
 
+	s.recordAudit(ctx, models.AuditEvent{ActorID: user.ID, TargetID: user.ID, Action: models.AuditUserRegistered})
 	return user, nil
 }
 
 // Login 用戶登入
-func (s *UserService) Login(req models.LoginRequest) (*models.User, error) {
+func (s *UserService) Login(ctx context.Context, req models.LoginRequest) (*models.User, error) {
 	// 查找用戶
-	user, err := s.repo.FindByEmail(req.Email)
+	user, err := s.repo.FindByEmail(ctx, req.Email)
 	if err != nil {
 		return nil, fmt.Errorf("failed to find user: %w", err)
 	}
 	if user == nil {
+		s.recordAudit(ctx, models.AuditEvent{
+			Action:   models.AuditLoginFailed,
+			Metadata: map[string]string{"email": req.Email, "reason": "unknown_email"},
+		})
 		return nil, fmt.Errorf("invalid credentials")
 	}
 
 	// 驗證密碼
-	err = bcrypt.CompareHashAndPassword([]byte(user.Password), []byte(req.Password))
-	if err != nil {
+	ok, err := s.hasher.Verify(req.Password, user.Password)
+	if err != nil || !ok {
+		s.recordAudit(ctx, models.AuditEvent{
+			TargetID: user.ID,
+			Action:   models.AuditLoginFailed,
+			Metadata: map[string]string{"reason": "wrong_password"},
+		})
 		return nil, fmt.Errorf("invalid credentials")
 	}
 
+	// 舊演算法或舊參數產生的 hash：趁現在拿得到明文，順手升級成目前的設定
+	// 升級失敗不影響這次登入，下次登入會再試一次
+	if s.hasher.NeedsRehash(user.Password) {
+		if err := s.rehashPassword(ctx, user, req.Password); err != nil {
+			log.Printf("failed to upgrade password hash for user %s: %v", user.ID, err)
+		}
+	}
+
+	// 啟用 MFA 的用戶要等 VerifyMFA 通過才算登入成功
+	if !user.MFAEnabled {
+		s.recordAudit(ctx, models.AuditEvent{ActorID: user.ID, TargetID: user.ID, Action: models.AuditLoginSucceeded})
+	}
 	return user, nil
 }
 
+// rehashPassword 以目前的 hasher 設定重新 hash 並寫回
+func (s *UserService) rehashPassword(ctx context.Context, user *models.User, password string) error {
+	hashed, err := s.hasher.Hash(password)
+	if err != nil {
+		return err
+	}
+	if err := s.repo.UpdatePassword(ctx, user.ID, hashed); err != nil {
+		return err
+	}
+	user.Password = hashed
+	return nil
+}
+
 // GetUsers 獲取所有用戶
-func (s *UserService) GetUsers() ([]models.User, error) {
-	return s.repo.FindAll()
+func (s *UserService) GetUsers(ctx context.Context) ([]models.User, error) {
+	return s.repo.FindAll(ctx)
 }
 
 // GetUserByID 根據 ID 獲取用戶
-func (s *UserService) GetUserByID(id string) (*models.User, error) {
-	user, err := s.repo.FindByID(id)
+func (s *UserService) GetUserByID(ctx context.Context, id string) (*models.User, error) {
+	user, err := s.repo.FindByID(ctx, id)
 	if err != nil {
 		return nil, err
 	}
 	if user == nil {
-		return nil, fmt.Errorf("user not found")
+		return nil, ErrUserNotFound
 	}
 	return user, nil
 }
 
 // UpdateUser 更新用戶
-func (s *UserService) UpdateUser(id string, req models.UpdateUserRequest) error {
-	return s.repo.Update(id, req.Username)
+// expectedVersion 為 repository.AnyVersion 時不檢查版本，否則版本不符回傳 ErrVersionConflict
+func (s *UserService) UpdateUser(ctx context.Context, id string, req models.UpdateUserRequest, expectedVersion int) error {
+	// 空的 username 不可以覆蓋掉原本的值
+	changes := models.UserChanges{Username: &req.Username}
+	if err := validateChanges(&changes); err != nil {
+		return err
+	}
+
+	// 只有開啟稽核時才需要修改前的資料來計算 diff
+	var before *models.User
+	if s.audit != nil {
+		user, err := s.repo.FindByID(ctx, id)
+		if err != nil {
+			return err
+		}
+		before = user
+	}
+
+	if err := s.repo.Update(ctx, id, *changes.Username, expectedVersion); err != nil {
+		return versionError(err)
+	}
+
+	if before != nil {
+		s.recordAudit(ctx, models.AuditEvent{TargetID: id, Action: models.AuditUserUpdated, Changes: diffUser(before, changes)})
+	}
+	return nil
 }
 
-// DeleteUser 刪除用戶
-func (s *UserService) DeleteUser(id string) error {
-	return s.repo.Delete(id)
+// ChangePassword 已登入用戶修改密碼，需驗證目前的密碼
+// 修改成功後撤銷該用戶所有已簽發的 token，其他裝置需要重新登入
+func (s *UserService) ChangePassword(ctx context.Context, id string, req models.ChangePasswordRequest) error {
+	user, err := s.repo.FindByID(ctx, id)
+	if err != nil {
+		return err
+	}
+	if user == nil {
+		return ErrUserNotFound
+	}
+
+	if ok, err := s.hasher.Verify(req.CurrentPassword, user.Password); err != nil || !ok {
+		return ErrInvalidCurrentPassword
+	}
+	if err := s.policy.Validate(req.NewPassword, user.Email, user.Username); err != nil {
+		return err
+	}
+
+	hashedPassword, err := s.hasher.Hash(req.NewPassword)
+	if err != nil {
+		return err
+	}
+	if err := s.repo.UpdatePassword(ctx, id, hashedPassword); err != nil {
+		return err
+	}
+	s.recordAudit(ctx, models.AuditEvent{TargetID: id, Action: models.AuditPasswordChanged})
+
+	return s.revokeAllTokens(ctx, id, s.now())
+}
+
+// DeleteUser 軟刪除用戶，並撤銷該用戶所有已簽發的 token
+// 資料在保留期間內可由管理員還原，過期後由 purge job 永久刪除
+func (s *UserService) DeleteUser(ctx context.Context, id string, expectedVersion int) error {
+	if err := s.repo.Delete(ctx, id, expectedVersion); err != nil {
+		return versionError(err)
+	}
+	s.recordAudit(ctx, models.AuditEvent{TargetID: id, Action: models.AuditUserDeleted})
+
+	return s.revokeAllTokens(ctx, id, s.now())
+}
+
+// RestoreUser 還原保留期間內被軟刪除的用戶
+// 刪除時撤銷的 token 不會恢復，用戶需要重新登入
+func (s *UserService) RestoreUser(ctx context.Context, id string) error {
+	restored, err := s.repo.Restore(ctx, id)
+	if err != nil {
+		return err
+	}
+	if !restored {
+		return ErrUserNotFound
+	}
+
+	s.recordAudit(ctx, models.AuditEvent{TargetID: id, Action: models.AuditUserRestored})
+	return nil
+}
+
+// versionError 將 repository 的版本衝突轉成 service 層的錯誤
+func versionError(err error) error {
+	if errors.Is(err, repository.ErrVersionConflict) {
+		return ErrVersionConflict
+	}
+	return err
 }
@@ -1,12 +1,17 @@
 package services
 
 import (
+	"context"
 	"fmt"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/mock"
+	"golang.org/x/crypto/bcrypt"
 	"user-service/models"
+	"user-service/repository"
+	"user-service/security"
 )
 
 // -------------------------------------------------------------------
@@ -18,51 +23,71 @@ type MockUserRepository struct {
 	mock.Mock
 }
 
-func (m *MockUserRepository) Create(user *models.User) error {
-	args := m.Called(user)
+func (m *MockUserRepository) Create(ctx context.Context, user *models.User) error {
+	args := m.Called(ctx, user)
 	return args.Error(0)
 }
 
-func (m *MockUserRepository) FindByEmail(email string) (*models.User, error) {
-	args := m.Called(email)
+func (m *MockUserRepository) FindByEmail(ctx context.Context, email string) (*models.User, error) {
+	args := m.Called(ctx, email)
 	if args.Get(0) == nil {
 		return nil, args.Error(1)
 	}
 	return args.Get(0).(*models.User), args.Error(1)
 }
 
-func (m *MockUserRepository) FindByID(id string) (*models.User, error) {
-	args := m.Called(id)
+func (m *MockUserRepository) FindByID(ctx context.Context, id string) (*models.User, error) {
+	args := m.Called(ctx, id)
 	if args.Get(0) == nil {
 		return nil, args.Error(1)
 	}
 	return args.Get(0).(*models.User), args.Error(1)
 }
 
-func (m *MockUserRepository) FindAll() ([]models.User, error) {
-	args := m.Called()
+func (m *MockUserRepository) FindAll(ctx context.Context) ([]models.User, error) {
+	args := m.Called(ctx)
 	return args.Get(0).([]models.User), args.Error(1)
 }
 
-func (m *MockUserRepository) Update(id string, username string) error {
-	args := m.Called(id, username)
+func (m *MockUserRepository) Update(ctx context.Context, id string, username string, expectedVersion int) error {
+	args := m.Called(ctx, id, username, expectedVersion)
 	return args.Error(0)
 }
 
-func (m *MockUserRepository) Delete(id string) error {
-	args := m.Called(id)
+func (m *MockUserRepository) Patch(ctx context.Context, id string, changes models.UserChanges, expectedVersion int) error {
+	args := m.Called(ctx, id, changes, expectedVersion)
 	return args.Error(0)
 }
 
+func (m *MockUserRepository) UpdatePassword(ctx context.Context, id string, hashedPassword string) error {
+	args := m.Called(ctx, id, hashedPassword)
+	return args.Error(0)
+}
+
+func (m *MockUserRepository) Delete(ctx context.Context, id string, expectedVersion int) error {
+	args := m.Called(ctx, id, expectedVersion)
+	return args.Error(0)
+}
+
+func (m *MockUserRepository) Restore(ctx context.Context, id string) (bool, error) {
+	args := m.Called(ctx, id)
+	return args.Bool(0), args.Error(1)
+}
+
+func (m *MockUserRepository) PurgeDeleted(ctx context.Context, retention time.Duration) (int64, error) {
+	args := m.Called(ctx, retention)
+	return args.Get(0).(int64), args.Error(1)
+}
+
 // setupHashedUser：呼叫真實 Register 取得已 bcrypt hash 過的 user
 // Login 測試需要 hash 過的密碼，用這個 helper 產生，避免重複寫 register 流程
 func setupHashedUser(t *testing.T, email, username, password string) *models.User {
 	t.Helper()
 	mockRepo := new(MockUserRepository)
-	mockRepo.On("FindByEmail", email).Return(nil, nil)
-	mockRepo.On("Create", mock.AnythingOfType("*models.User")).Return(nil)
+	mockRepo.On("FindByEmail", mock.Anything, email).Return(nil, nil)
+	mockRepo.On("Create", mock.Anything, mock.AnythingOfType("*models.User")).Return(nil)
 	svc := NewUserService(mockRepo)
-	user, err := svc.Register(models.RegisterRequest{Email: email, Username: username, Password: password})
+	user, err := svc.Register(context.Background(), models.RegisterRequest{Email: email, Username: username, Password: password})
 	assert.NoError(t, err)
 	return user
 }
@@ -75,12 +100,12 @@ func TestRegister(t *testing.T) {
 	t.Run("success", func(t *testing.T) {
 		mockRepo := new(MockUserRepository)
 		// email 不存在 → 回傳 nil, nil
-		mockRepo.On("FindByEmail", "new@example.com").Return(nil, nil)
+		mockRepo.On("FindByEmail", mock.Anything, "new@example.com").Return(nil, nil)
 		// Create 被呼叫時，接受任意 *models.User，成功不回錯誤
-		mockRepo.On("Create", mock.AnythingOfType("*models.User")).Return(nil)
+		mockRepo.On("Create", mock.Anything, mock.AnythingOfType("*models.User")).Return(nil)
 
 		svc := NewUserService(mockRepo)
-		user, err := svc.Register(models.RegisterRequest{
+		user, err := svc.Register(context.Background(), models.RegisterRequest{
 			Email:    "new@example.com",
 			Username: "newuser",
 			Password: "password123",
@@ -99,10 +124,10 @@ func TestRegister(t *testing.T) {
 		mockRepo := new(MockUserRepository)
 		// email 已存在 → 回傳一個有資料的 user
 		existing := &models.User{Email: "exist@example.com"}
-		mockRepo.On("FindByEmail", "exist@example.com").Return(existing, nil)
+		mockRepo.On("FindByEmail", mock.Anything, "exist@example.com").Return(existing, nil)
 
 		svc := NewUserService(mockRepo)
-		user, err := svc.Register(models.RegisterRequest{
+		user, err := svc.Register(context.Background(), models.RegisterRequest{
 			Email:    "exist@example.com",
 			Username: "someone",
 			Password: "password123",
@@ -115,13 +140,31 @@ func TestRegister(t *testing.T) {
 		mockRepo.AssertExpectations(t)
 	})
 
+	t.Run("email taken between check and insert", func(t *testing.T) {
+		mockRepo := new(MockUserRepository)
+		// 檢查時還不存在，寫入時被 unique constraint 擋下
+		mockRepo.On("FindByEmail", mock.Anything, "race@example.com").Return(nil, nil)
+		mockRepo.On("Create", mock.Anything, mock.AnythingOfType("*models.User")).Return(repository.ErrDuplicateEmail)
+
+		svc := NewUserService(mockRepo)
+		user, err := svc.Register(context.Background(), models.RegisterRequest{
+			Email:    "race@example.com",
+			Username: "someone",
+			Password: "password123",
+		})
+
+		assert.ErrorIs(t, err, ErrEmailTaken)
+		assert.Nil(t, user)
+		mockRepo.AssertExpectations(t)
+	})
+
 	t.Run("db error on find", func(t *testing.T) {
 		mockRepo := new(MockUserRepository)
 		// FindByEmail 本身就出錯（DB 連線問題等）
-		mockRepo.On("FindByEmail", "error@example.com").Return(nil, fmt.Errorf("db connection failed"))
+		mockRepo.On("FindByEmail", mock.Anything, "error@example.com").Return(nil, fmt.Errorf("db connection failed"))
 
 		svc := NewUserService(mockRepo)
-		user, err := svc.Register(models.RegisterRequest{
+		user, err := svc.Register(context.Background(), models.RegisterRequest{
 			Email:    "error@example.com",
 			Username: "someone",
 			Password: "password123",
@@ -144,10 +187,10 @@ func TestLogin(t *testing.T) {
 		hashedUser := setupHashedUser(t, "user@example.com", "user", "correctpassword")
 
 		mockRepo := new(MockUserRepository)
-		mockRepo.On("FindByEmail", "user@example.com").Return(hashedUser, nil)
+		mockRepo.On("FindByEmail", mock.Anything, "user@example.com").Return(hashedUser, nil)
 
 		svc := NewUserService(mockRepo)
-		user, err := svc.Login(models.LoginRequest{
+		user, err := svc.Login(context.Background(), models.LoginRequest{
 			Email:    "user@example.com",
 			Password: "correctpassword",
 		})
@@ -161,10 +204,10 @@ func TestLogin(t *testing.T) {
 	t.Run("user not found", func(t *testing.T) {
 		mockRepo := new(MockUserRepository)
 		// email 查不到 → 回傳 nil, nil（不是 error，只是找不到）
-		mockRepo.On("FindByEmail", "ghost@example.com").Return(nil, nil)
+		mockRepo.On("FindByEmail", mock.Anything, "ghost@example.com").Return(nil, nil)
 
 		svc := NewUserService(mockRepo)
-		user, err := svc.Login(models.LoginRequest{
+		user, err := svc.Login(context.Background(), models.LoginRequest{
 			Email:    "ghost@example.com",
 			Password: "somepassword",
 		})
@@ -180,10 +223,10 @@ func TestLogin(t *testing.T) {
 		hashedUser := setupHashedUser(t, "user@example.com", "user", "correctpassword")
 
 		mockRepo := new(MockUserRepository)
-		mockRepo.On("FindByEmail", "user@example.com").Return(hashedUser, nil)
+		mockRepo.On("FindByEmail", mock.Anything, "user@example.com").Return(hashedUser, nil)
 
 		svc := NewUserService(mockRepo)
-		user, err := svc.Login(models.LoginRequest{
+		user, err := svc.Login(context.Background(), models.LoginRequest{
 			Email:    "user@example.com",
 			Password: "wrongpassword",
 		})
@@ -196,10 +239,10 @@ func TestLogin(t *testing.T) {
 
 	t.Run("db error", func(t *testing.T) {
 		mockRepo := new(MockUserRepository)
-		mockRepo.On("FindByEmail", "user@example.com").Return(nil, fmt.Errorf("db connection failed"))
+		mockRepo.On("FindByEmail", mock.Anything, "user@example.com").Return(nil, fmt.Errorf("db connection failed"))
 
 		svc := NewUserService(mockRepo)
-		user, err := svc.Login(models.LoginRequest{
+		user, err := svc.Login(context.Background(), models.LoginRequest{
 			Email:    "user@example.com",
 			Password: "correctpassword",
 		})
@@ -218,10 +261,10 @@ func TestLogin(t *testing.T) {
 func TestGetUserByID(t *testing.T) {
 	t.Run("success", func(t *testing.T) {
 		mockRepo := new(MockUserRepository)
-		mockRepo.On("FindByID", "abc-123").Return(&models.User{ID: "abc-123", Email: "u@example.com"}, nil)
+		mockRepo.On("FindByID", mock.Anything, "abc-123").Return(&models.User{ID: "abc-123", Email: "u@example.com"}, nil)
 
 		svc := NewUserService(mockRepo)
-		user, err := svc.GetUserByID("abc-123")
+		user, err := svc.GetUserByID(context.Background(), "abc-123")
 
 		assert.NoError(t, err)
 		assert.NotNil(t, user)
@@ -232,10 +275,10 @@ func TestGetUserByID(t *testing.T) {
 	t.Run("not found", func(t *testing.T) {
 		mockRepo := new(MockUserRepository)
 		// DB 查無此 ID → 回傳 nil, nil
-		mockRepo.On("FindByID", "not-exist").Return(nil, nil)
+		mockRepo.On("FindByID", mock.Anything, "not-exist").Return(nil, nil)
 
 		svc := NewUserService(mockRepo)
-		user, err := svc.GetUserByID("not-exist")
+		user, err := svc.GetUserByID(context.Background(), "not-exist")
 
 		assert.Error(t, err)
 		assert.Nil(t, user)
@@ -245,10 +288,10 @@ func TestGetUserByID(t *testing.T) {
 
 	t.Run("db error", func(t *testing.T) {
 		mockRepo := new(MockUserRepository)
-		mockRepo.On("FindByID", "error-id").Return(nil, fmt.Errorf("db error"))
+		mockRepo.On("FindByID", mock.Anything, "error-id").Return(nil, fmt.Errorf("db error"))
 
 		svc := NewUserService(mockRepo)
-		user, err := svc.GetUserByID("error-id")
+		user, err := svc.GetUserByID(context.Background(), "error-id")
 
 		assert.Error(t, err)
 		assert.Nil(t, user)
@@ -264,10 +307,10 @@ func TestGetUserByID(t *testing.T) {
 func TestDeleteUser(t *testing.T) {
 	t.Run("success", func(t *testing.T) {
 		mockRepo := new(MockUserRepository)
-		mockRepo.On("Delete", "abc-123").Return(nil)
+		mockRepo.On("Delete", mock.Anything, "abc-123", 0).Return(nil)
 
 		svc := NewUserService(mockRepo)
-		err := svc.DeleteUser("abc-123")
+		err := svc.DeleteUser(context.Background(), "abc-123", 0)
 
 		assert.NoError(t, err)
 		mockRepo.AssertExpectations(t)
@@ -275,13 +318,271 @@ func TestDeleteUser(t *testing.T) {
 
 	t.Run("not found", func(t *testing.T) {
 		mockRepo := new(MockUserRepository)
-		mockRepo.On("Delete", "ghost-id").Return(fmt.Errorf("user not found"))
+		mockRepo.On("Delete", mock.Anything, "ghost-id", 0).Return(fmt.Errorf("user not found"))
 
 		svc := NewUserService(mockRepo)
-		err := svc.DeleteUser("ghost-id")
+		err := svc.DeleteUser(context.Background(), "ghost-id", 0)
 
 		assert.Error(t, err)
 		assert.EqualError(t, err, "user not found")
 		mockRepo.AssertExpectations(t)
 	})
+
+	t.Run("revokes existing tokens", func(t *testing.T) {
+		mockRepo := new(MockUserRepository)
+		mockRepo.On("Delete", mock.Anything, "abc-123", 0).Return(nil)
+		mockRevocations := new(MockTokenRevocationRepository)
+		mockRevocations.On("RevokeAllForUser", mock.Anything, "abc-123", fixedNow).Return(nil)
+
+		svc := NewUserService(mockRepo, WithTokenRevocation(mockRevocations), WithClock(fixedClock))
+		err := svc.DeleteUser(context.Background(), "abc-123", 0)
+
+		assert.NoError(t, err)
+		mockRevocations.AssertExpectations(t)
+	})
+}
+
+// ===================================================================
+// UpdateUser 測試
+// ===================================================================
+
+func TestUpdateUser(t *testing.T) {
+	t.Run("success", func(t *testing.T) {
+		mockRepo := new(MockUserRepository)
+		mockRepo.On("Update", mock.Anything, "abc-123", "newname", 2).Return(nil)
+
+		svc := NewUserService(mockRepo)
+		err := svc.UpdateUser(context.Background(), "abc-123", models.UpdateUserRequest{Username: "newname"}, 2)
+
+		assert.NoError(t, err)
+		mockRepo.AssertExpectations(t)
+	})
+
+	t.Run("version conflict", func(t *testing.T) {
+		mockRepo := new(MockUserRepository)
+		mockRepo.On("Update", mock.Anything, "abc-123", "newname", 2).Return(repository.ErrVersionConflict)
+
+		svc := NewUserService(mockRepo)
+		err := svc.UpdateUser(context.Background(), "abc-123", models.UpdateUserRequest{Username: "newname"}, 2)
+
+		assert.ErrorIs(t, err, ErrVersionConflict)
+	})
+}
+
+// ===================================================================
+// RestoreUser / PurgeDeletedUsers 測試
+// ===================================================================
+
+func TestRestoreUser(t *testing.T) {
+	t.Run("success", func(t *testing.T) {
+		mockRepo := new(MockUserRepository)
+		mockRepo.On("Restore", mock.Anything, "abc-123").Return(true, nil)
+
+		svc := NewUserService(mockRepo)
+		err := svc.RestoreUser(context.Background(), "abc-123")
+
+		assert.NoError(t, err)
+		mockRepo.AssertExpectations(t)
+	})
+
+	t.Run("not deleted", func(t *testing.T) {
+		mockRepo := new(MockUserRepository)
+		mockRepo.On("Restore", mock.Anything, "abc-123").Return(false, nil)
+
+		svc := NewUserService(mockRepo)
+		err := svc.RestoreUser(context.Background(), "abc-123")
+
+		assert.ErrorIs(t, err, ErrUserNotFound)
+	})
+}
+
+func TestPurgeDeletedUsers(t *testing.T) {
+	t.Run("uses default retention", func(t *testing.T) {
+		mockRepo := new(MockUserRepository)
+		mockRepo.On("PurgeDeleted", mock.Anything, DefaultDeletedUserRetention).Return(int64(3), nil)
+
+		svc := NewUserService(mockRepo)
+		purged, err := svc.PurgeDeletedUsers(context.Background())
+
+		assert.NoError(t, err)
+		assert.Equal(t, int64(3), purged)
+		mockRepo.AssertExpectations(t)
+	})
+
+	t.Run("uses configured retention", func(t *testing.T) {
+		mockRepo := new(MockUserRepository)
+		mockRepo.On("PurgeDeleted", mock.Anything, 7*24*time.Hour).Return(int64(0), nil)
+
+		svc := NewUserService(mockRepo, WithDeletedUserRetention(7*24*time.Hour))
+		_, err := svc.PurgeDeletedUsers(context.Background())
+
+		assert.NoError(t, err)
+		mockRepo.AssertExpectations(t)
+	})
+}
+
+// ===================================================================
+// ChangePassword 測試
+// ===================================================================
+
+func TestChangePassword(t *testing.T) {
+	t.Run("success - updates hash and revokes tokens", func(t *testing.T) {
+		hashedUser := setupHashedUser(t, "user@example.com", "user", "oldpassword1")
+		hashedUser.ID = "abc-123"
+
+		var newHash string
+		mockRepo := new(MockUserRepository)
+		mockRepo.On("FindByID", mock.Anything, "abc-123").Return(hashedUser, nil)
+		mockRepo.On("UpdatePassword", mock.Anything, "abc-123", mock.AnythingOfType("string")).
+			Run(func(args mock.Arguments) { newHash = args.String(2) }).
+			Return(nil)
+		mockRevoke := new(MockTokenRevocationRepository)
+		mockRevoke.On("RevokeAllForUser", mock.Anything, "abc-123", fixedNow).Return(nil)
+
+		svc := NewUserService(mockRepo, WithTokenRevocation(mockRevoke), WithClock(fixedClock))
+		err := svc.ChangePassword(context.Background(), "abc-123", models.ChangePasswordRequest{
+			CurrentPassword: "oldpassword1",
+			NewPassword:     "brand-new-secret9",
+		})
+
+		assert.NoError(t, err)
+		assert.NoError(t, bcrypt.CompareHashAndPassword([]byte(newHash), []byte("brand-new-secret9")))
+		mockRepo.AssertExpectations(t)
+		mockRevoke.AssertExpectations(t)
+	})
+
+	t.Run("wrong current password", func(t *testing.T) {
+		hashedUser := setupHashedUser(t, "user@example.com", "user", "oldpassword1")
+		hashedUser.ID = "abc-123"
+
+		mockRepo := new(MockUserRepository)
+		mockRepo.On("FindByID", mock.Anything, "abc-123").Return(hashedUser, nil)
+
+		svc := NewUserService(mockRepo)
+		err := svc.ChangePassword(context.Background(), "abc-123", models.ChangePasswordRequest{
+			CurrentPassword: "not-my-password",
+			NewPassword:     "brand-new-secret9",
+		})
+
+		assert.ErrorIs(t, err, ErrInvalidCurrentPassword)
+		mockRepo.AssertNotCalled(t, "UpdatePassword", mock.Anything, mock.Anything, mock.Anything)
+	})
+
+	t.Run("new password violates policy", func(t *testing.T) {
+		hashedUser := setupHashedUser(t, "user@example.com", "user", "oldpassword1")
+		hashedUser.ID = "abc-123"
+
+		mockRepo := new(MockUserRepository)
+		mockRepo.On("FindByID", mock.Anything, "abc-123").Return(hashedUser, nil)
+
+		svc := NewUserService(mockRepo, WithPasswordPolicy(security.PasswordPolicy{MinLength: 8, RejectCommon: true}))
+		err := svc.ChangePassword(context.Background(), "abc-123", models.ChangePasswordRequest{
+			CurrentPassword: "oldpassword1",
+			NewPassword:     "password123",
+		})
+
+		var policyErr *security.PolicyError
+		assert.ErrorAs(t, err, &policyErr)
+		mockRepo.AssertNotCalled(t, "UpdatePassword", mock.Anything, mock.Anything, mock.Anything)
+	})
+
+	t.Run("user not found", func(t *testing.T) {
+		mockRepo := new(MockUserRepository)
+		mockRepo.On("FindByID", mock.Anything, "ghost-id").Return(nil, nil)
+
+		svc := NewUserService(mockRepo)
+		err := svc.ChangePassword(context.Background(), "ghost-id", models.ChangePasswordRequest{
+			CurrentPassword: "oldpassword1",
+			NewPassword:     "brand-new-secret9",
+		})
+
+		assert.ErrorIs(t, err, ErrUserNotFound)
+	})
+}
+
+func TestRegister_PasswordPolicy(t *testing.T) {
+	mockRepo := new(MockUserRepository)
+
+	svc := NewUserService(mockRepo, WithPasswordPolicy(security.PasswordPolicy{MinLength: 8, DisallowPersonalInfo: true}))
+	user, err := svc.Register(context.Background(), models.RegisterRequest{
+		Email:    "alice@example.com",
+		Username: "alice",
+		Password: "alice2024!",
+	})
+
+	var policyErr *security.PolicyError
+	assert.ErrorAs(t, err, &policyErr)
+	assert.Nil(t, user)
+	// 規則沒過就不該查 DB
+	mockRepo.AssertNotCalled(t, "FindByEmail", mock.Anything, mock.Anything)
+}
+
+// ===================================================================
+// 密碼 hash 升級測試
+// ===================================================================
+
+func TestLogin_RehashesLegacyPassword(t *testing.T) {
+	legacy := security.NewBcryptHasher(bcrypt.MinCost)
+	legacyHash, err := legacy.Hash("correctpassword")
+	assert.NoError(t, err)
+	argon2Params := security.Argon2idParams{Memory: 1024, Iterations: 1, Parallelism: 1, SaltLength: 16, KeyLength: 32}
+	hasher := security.NewUpgradingHasher(security.NewArgon2idHasher(argon2Params), legacy)
+
+	t.Run("upgrades to primary algorithm", func(t *testing.T) {
+		var upgraded string
+		mockRepo := new(MockUserRepository)
+		mockRepo.On("FindByEmail", mock.Anything, "user@example.com").
+			Return(&models.User{ID: "abc-123", Email: "user@example.com", Password: legacyHash}, nil)
+		mockRepo.On("UpdatePassword", mock.Anything, "abc-123", mock.AnythingOfType("string")).
+			Run(func(args mock.Arguments) { upgraded = args.String(2) }).
+			Return(nil)
+
+		svc := NewUserService(mockRepo, WithPasswordHasher(hasher))
+		user, err := svc.Login(context.Background(), models.LoginRequest{Email: "user@example.com", Password: "correctpassword"})
+
+		assert.NoError(t, err)
+		assert.NotNil(t, user)
+		assert.Contains(t, upgraded, "$argon2id$")
+		ok, _ := hasher.Verify("correctpassword", upgraded)
+		assert.True(t, ok)
+		mockRepo.AssertExpectations(t)
+	})
+
+	t.Run("upgrade failure does not block login", func(t *testing.T) {
+		mockRepo := new(MockUserRepository)
+		mockRepo.On("FindByEmail", mock.Anything, "user@example.com").
+			Return(&models.User{ID: "abc-123", Email: "user@example.com", Password: legacyHash}, nil)
+		mockRepo.On("UpdatePassword", mock.Anything, "abc-123", mock.AnythingOfType("string")).Return(fmt.Errorf("db error"))
+
+		svc := NewUserService(mockRepo, WithPasswordHasher(hasher))
+		user, err := svc.Login(context.Background(), models.LoginRequest{Email: "user@example.com", Password: "correctpassword"})
+
+		assert.NoError(t, err)
+		assert.NotNil(t, user)
+	})
+
+	t.Run("current hash is left alone", func(t *testing.T) {
+		currentHash, _ := hasher.Hash("correctpassword")
+		mockRepo := new(MockUserRepository)
+		mockRepo.On("FindByEmail", mock.Anything, "user@example.com").
+			Return(&models.User{ID: "abc-123", Email: "user@example.com", Password: currentHash}, nil)
+
+		svc := NewUserService(mockRepo, WithPasswordHasher(hasher))
+		_, err := svc.Login(context.Background(), models.LoginRequest{Email: "user@example.com", Password: "correctpassword"})
+
+		assert.NoError(t, err)
+		mockRepo.AssertNotCalled(t, "UpdatePassword", mock.Anything, mock.Anything, mock.Anything)
+	})
+
+	t.Run("wrong password never rehashes", func(t *testing.T) {
+		mockRepo := new(MockUserRepository)
+		mockRepo.On("FindByEmail", mock.Anything, "user@example.com").
+			Return(&models.User{ID: "abc-123", Email: "user@example.com", Password: legacyHash}, nil)
+
+		svc := NewUserService(mockRepo, WithPasswordHasher(hasher))
+		_, err := svc.Login(context.Background(), models.LoginRequest{Email: "user@example.com", Password: "wrongpassword"})
+
+		assert.Error(t, err)
+		mockRepo.AssertNotCalled(t, "UpdatePassword", mock.Anything, mock.Anything, mock.Anything)
+	})
 }